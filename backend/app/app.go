@@ -0,0 +1,323 @@
+// Package app wires the API's services, handlers, middleware and background
+// workers together and runs them with ordered startup and shutdown.
+package app
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"log"
+	"net/http"
+	"os"
+	"os/signal"
+	"strconv"
+	"syscall"
+	"time"
+
+	"rice-monitor-api/handlers"
+	"rice-monitor-api/middleware"
+	"rice-monitor-api/services"
+	"rice-monitor-api/utils"
+
+	"github.com/gin-gonic/gin"
+)
+
+// Hook is a subsystem started when the app runs and stopped when it shuts down.
+// Hooks start in the order they were added and stop in reverse order, so a
+// subsystem is stopped before the ones it depends on. Either function may be nil.
+type Hook struct {
+	Name  string
+	Start func(ctx context.Context) error
+	Stop  func(ctx context.Context) error
+}
+
+// Services holds the shared services
+type Services struct {
+	Firestore      *services.FirestoreService
+	Storage        *services.StorageService
+	CKAN           *services.CKANService
+	Mailer         *services.MailerService
+	OAuthProviders map[string]services.OAuthProvider
+	Jobs           *services.JobService
+	Reputation     *services.ReputationService
+	Notifications  *services.NotificationSettingsService
+	Quality        *services.QualityService
+	Reminders      *services.ReminderService
+	Overdue        *services.OverdueService
+	Media          *services.MediaReconciler
+	UserPurge      *services.UserPurger
+	Weather        *services.WeatherService
+	Vocabulary     *services.VocabularyService
+	Traits         *services.TraitService
+	Transcription  *services.TranscriptionService
+	Config         *services.ConfigService
+	ChatOps        *services.ChatOpsService
+	Webhooks       *services.WebhookService
+	DataAgreements *services.DataAgreementService
+	Exports        *services.ExportScheduler // built with the handlers, it exports through the submission handler
+	Errors         services.ErrorReporter
+}
+
+// Handlers holds the HTTP handlers
+type Handlers struct {
+	Auth           *handlers.AuthHandler
+	User           *handlers.UserHandler
+	Submission     *handlers.SubmissionHandler
+	Image          *handlers.ImageHandler
+	Field          *handlers.FieldHandler
+	Analytics      *handlers.AnalyticsHandler
+	APIKey         *handlers.APIKeyHandler
+	Dataset        *handlers.DatasetHandler
+	InboundEmail   *handlers.InboundEmailHandler
+	ServiceAccount *handlers.ServiceAccountHandler
+	Embed          *handlers.EmbedHandler
+	QualityReport  *handlers.QualityReportHandler
+	Invite         *handlers.InviteHandler
+	AuthEvent      *handlers.AuthEventHandler
+	StressEvent    *handlers.StressEventHandler
+	InboundSMS     *handlers.InboundSMSHandler
+	ShareToken     *handlers.ShareTokenHandler
+	Integration    *handlers.IntegrationHandler
+	Webhook        *handlers.WebhookHandler
+	Organization   *handlers.OrganizationHandler
+	Farm           *handlers.FarmHandler
+	PersonalToken  *handlers.PersonalTokenHandler
+	Calibration    *handlers.CalibrationHandler
+	Trait          *handlers.TraitHandler
+	Meta           *handlers.MetaHandler
+	DataAgreement  *handlers.DataAgreementHandler
+	ExportSchedule *handlers.ExportScheduleHandler
+}
+
+// App is the assembled API server
+type App struct {
+	Services       *Services
+	Handlers       *Handlers
+	AuthMiddleware *middleware.AuthMiddleware
+	Router         *gin.Engine
+
+	hooks      []Hook
+	serverErr  chan error
+	routeTable *routeTable
+}
+
+// New connects to the backing services and wires every component. Nothing
+// runs until Run is called.
+func New(ctx context.Context) (*App, error) {
+	a := &App{serverErr: make(chan error, 1)}
+
+	utils.LoadJWTSettings()
+	if err := utils.LoadTokenLifetimes(); err != nil {
+		return nil, err
+	}
+
+	svc, err := a.newServices(ctx)
+	if err != nil {
+		return nil, err
+	}
+	a.Services = svc
+	a.Handlers = newHandlers(svc)
+	svc.Exports = services.NewExportScheduler(svc.Firestore, svc.Storage, svc.Mailer, svc.DataAgreements, a.Handlers.Submission.ScheduledExport)
+	a.AuthMiddleware = middleware.NewAuthMiddleware(svc.Firestore, svc.DataAgreements)
+	a.Router = a.routes()
+	if err := middleware.ConfigureClientIP(a.Router); err != nil {
+		return nil, fmt.Errorf("invalid TRUSTED_PROXIES: %w", err)
+	}
+
+	a.addHook(Hook{Name: "job workers", Start: svc.Jobs.Start, Stop: svc.Jobs.Stop})
+	a.addHook(Hook{Name: "quality reports", Start: svc.Quality.Start, Stop: svc.Quality.Stop})
+	a.addHook(Hook{Name: "weather backfill", Start: svc.Weather.Start, Stop: svc.Weather.Stop})
+	a.addHook(Hook{Name: "visit reminders", Start: svc.Reminders.Start, Stop: svc.Reminders.Stop})
+	a.addHook(Hook{Name: "overdue visit reminders", Start: svc.Overdue.Start, Stop: svc.Overdue.Stop})
+	a.addHook(Hook{Name: "media reconciler", Start: svc.Media.Start, Stop: svc.Media.Stop})
+	a.addHook(Hook{Name: "user purge", Start: svc.UserPurge.Start, Stop: svc.UserPurge.Stop})
+	a.addHook(Hook{Name: "chat notifications", Start: svc.ChatOps.Start, Stop: svc.ChatOps.Stop})
+	a.addHook(Hook{Name: "webhook retries", Start: svc.Webhooks.Start, Stop: svc.Webhooks.Stop})
+	a.addHook(Hook{Name: "scheduled exports", Start: svc.Exports.Start, Stop: svc.Exports.Stop})
+	a.addHook(Hook{Name: "field geohash backfill", Start: func(context.Context) error {
+		go func() {
+			migrate := func() (int, error) { return services.BackfillFieldGeohashes(svc.Firestore) }
+			if err := services.RunMigration(svc.Firestore, "field_geohash", migrate); err != nil {
+				log.Printf("Failed to backfill field geohashes: %v", err)
+			}
+		}()
+		return nil
+	}})
+	a.addHook(Hook{Name: "field listing backfill", Start: func(context.Context) error {
+		go func() {
+			migrate := func() (int, error) { return services.BackfillFieldListing(svc.Firestore) }
+			if err := services.RunMigration(svc.Firestore, "field_listing", migrate); err != nil {
+				log.Printf("Failed to backfill field search terms: %v", err)
+			}
+		}()
+		return nil
+	}})
+	a.appendServer()
+
+	return a, nil
+}
+
+// addHook registers a hook after the existing ones
+func (a *App) addHook(hook Hook) {
+	a.hooks = append(a.hooks, hook)
+}
+
+// Run starts every hook, serves until SIGINT or SIGTERM (or until the server
+// fails), then stops the hooks in reverse order within SHUTDOWN_TIMEOUT seconds
+func (a *App) Run() error {
+	ctx, cancel := signal.NotifyContext(context.Background(), os.Interrupt, syscall.SIGTERM)
+	defer cancel()
+
+	started, err := a.start(ctx)
+	if err != nil {
+		a.stop(started)
+		return err
+	}
+
+	select {
+	case <-ctx.Done():
+		log.Println("Shutting down")
+	case err = <-a.serverErr:
+		log.Printf("Server failed: %v", err)
+	}
+
+	a.stop(started)
+	return err
+}
+
+func (a *App) newServices(ctx context.Context) (*Services, error) {
+	firestoreService, err := services.NewFirestoreService(ctx)
+	if err != nil {
+		return nil, fmt.Errorf("failed to initialize Firestore service: %w", err)
+	}
+	a.addHook(Hook{Name: "firestore", Stop: func(context.Context) error { return firestoreService.Close() }})
+
+	storageService, err := services.NewStorageService(ctx)
+	if err != nil {
+		firestoreService.Close()
+		return nil, fmt.Errorf("failed to initialize Storage service: %w", err)
+	}
+	a.addHook(Hook{Name: "storage", Stop: func(context.Context) error { return storageService.Close() }})
+
+	mailerService := services.NewMailerService()
+	vocabularyService := services.NewVocabularyService()
+	notificationService := services.NewNotificationSettingsService(firestoreService)
+
+	return &Services{
+		Firestore:      firestoreService,
+		Storage:        storageService,
+		CKAN:           services.NewCKANService(),
+		Mailer:         mailerService,
+		OAuthProviders: services.NewOAuthProviders(),
+		Jobs:           services.NewJobService(firestoreService, storageService),
+		Reputation:     services.NewReputationService(firestoreService),
+		Notifications:  notificationService,
+		Quality:        services.NewQualityService(firestoreService, mailerService, vocabularyService),
+		Reminders:      services.NewReminderService(firestoreService, mailerService),
+		Overdue:        services.NewOverdueService(firestoreService, mailerService, notificationService),
+		Media:          services.NewMediaReconciler(firestoreService, storageService),
+		UserPurge:      services.NewUserPurger(firestoreService),
+		Weather:        services.NewWeatherService(firestoreService),
+		Vocabulary:     vocabularyService,
+		Traits:         services.NewTraitService(firestoreService),
+		Transcription:  services.NewTranscriptionService(),
+		Config:         services.NewConfigService(vocabularyService),
+		ChatOps:        services.NewChatOpsService(firestoreService),
+		Webhooks:       services.NewWebhookService(firestoreService),
+		DataAgreements: services.NewDataAgreementService(firestoreService),
+		Errors:         services.NewErrorReporter(),
+	}, nil
+}
+
+func newHandlers(svc *Services) *Handlers {
+	return &Handlers{
+		Auth:           handlers.NewAuthHandler(svc.Firestore, svc.Mailer, svc.OAuthProviders),
+		User:           handlers.NewUserHandler(svc.Firestore, svc.Reputation, svc.Notifications),
+		Submission:     handlers.NewSubmissionHandler(svc.Firestore, svc.Reputation, svc.ChatOps, svc.Weather, svc.Vocabulary, svc.Storage, svc.Traits, svc.Transcription, svc.Webhooks, svc.Overdue),
+		Image:          handlers.NewImageHandler(svc.Storage, svc.Firestore),
+		Field:          handlers.NewFieldHandler(svc.Firestore),
+		Analytics:      handlers.NewAnalyticsHandler(svc.Firestore, svc.Jobs, svc.Reputation, svc.Vocabulary),
+		APIKey:         handlers.NewAPIKeyHandler(svc.Firestore),
+		Dataset:        handlers.NewDatasetHandler(svc.Firestore, svc.Storage, svc.CKAN),
+		InboundEmail:   handlers.NewInboundEmailHandler(svc.Firestore, svc.Storage, svc.Vocabulary),
+		ServiceAccount: handlers.NewServiceAccountHandler(svc.Firestore),
+		Embed:          handlers.NewEmbedHandler(svc.Firestore, svc.Vocabulary),
+		QualityReport:  handlers.NewQualityReportHandler(svc.Firestore),
+		Invite:         handlers.NewInviteHandler(svc.Firestore, svc.Mailer),
+		AuthEvent:      handlers.NewAuthEventHandler(svc.Firestore),
+		StressEvent:    handlers.NewStressEventHandler(svc.Firestore),
+		InboundSMS:     handlers.NewInboundSMSHandler(svc.Firestore, svc.Vocabulary),
+		ShareToken:     handlers.NewShareTokenHandler(svc.Firestore),
+		Integration:    handlers.NewIntegrationHandler(svc.Firestore, svc.ChatOps),
+		Webhook:        handlers.NewWebhookHandler(svc.Firestore, svc.Webhooks),
+		Organization:   handlers.NewOrganizationHandler(svc.Firestore),
+		Farm:           handlers.NewFarmHandler(svc.Firestore),
+		PersonalToken:  handlers.NewPersonalTokenHandler(svc.Firestore),
+		Calibration:    handlers.NewCalibrationHandler(svc.Firestore),
+		Trait:          handlers.NewTraitHandler(svc.Firestore, svc.Traits),
+		Meta:           handlers.NewMetaHandler(svc.Vocabulary, svc.Config),
+		DataAgreement:  handlers.NewDataAgreementHandler(svc.Firestore, svc.DataAgreements),
+		ExportSchedule: handlers.NewExportScheduleHandler(svc.Firestore),
+	}
+}
+
+// appendServer registers the HTTP server as the last hook, so it stops
+// accepting requests before anything it uses is shut down
+func (a *App) appendServer() {
+	server := &http.Server{
+		Addr:    ":" + utils.GetEnvOrDefault("PORT", "8080"),
+		Handler: serveHEAD(a.Router, a.routeTable),
+	}
+
+	a.addHook(Hook{
+		Name: "http server",
+		Start: func(ctx context.Context) error {
+			log.Printf("Server starting on port %s", server.Addr[1:])
+			go func() {
+				if err := server.ListenAndServe(); err != nil && !errors.Is(err, http.ErrServerClosed) {
+					a.serverErr <- err
+				}
+			}()
+			return nil
+		},
+		Stop: server.Shutdown,
+	})
+}
+
+// start runs the start hooks in order and returns the hooks that started
+func (a *App) start(ctx context.Context) ([]Hook, error) {
+	var started []Hook
+	for _, hook := range a.hooks {
+		if hook.Start != nil {
+			if err := hook.Start(ctx); err != nil {
+				return started, fmt.Errorf("failed to start %s: %w", hook.Name, err)
+			}
+		}
+		started = append(started, hook)
+	}
+	return started, nil
+}
+
+// stop runs the stop hooks of started in reverse order, logging failures
+func (a *App) stop(started []Hook) {
+	ctx, cancel := context.WithTimeout(context.Background(), shutdownTimeout())
+	defer cancel()
+
+	for i := len(started) - 1; i >= 0; i-- {
+		hook := started[i]
+		if hook.Stop == nil {
+			continue
+		}
+		if err := hook.Stop(ctx); err != nil {
+			log.Printf("Failed to stop %s: %v", hook.Name, err)
+		}
+	}
+}
+
+func shutdownTimeout() time.Duration {
+	seconds, err := strconv.Atoi(os.Getenv("SHUTDOWN_TIMEOUT"))
+	if err != nil || seconds <= 0 {
+		seconds = 15
+	}
+	return time.Duration(seconds) * time.Second
+}
@@ -0,0 +1,124 @@
+package app
+
+import (
+	"net/http"
+	"sort"
+	"strings"
+
+	"rice-monitor-api/models"
+
+	"github.com/gin-gonic/gin"
+)
+
+// routeTable answers which methods the router serves for a path, so 405
+// responses and OPTIONS requests can list them in an Allow header
+type routeTable struct {
+	routes []gin.RouteInfo
+}
+
+func newRouteTable(routes gin.RoutesInfo) *routeTable {
+	return &routeTable{routes: routes}
+}
+
+// allowed returns the methods served for path, with HEAD wherever GET is
+// served and OPTIONS for every existing path
+func (rt *routeTable) allowed(path string) []string {
+	methods := map[string]bool{}
+	for _, route := range rt.routes {
+		if matchRoute(route.Path, path) {
+			methods[route.Method] = true
+		}
+	}
+	if len(methods) == 0 {
+		return nil
+	}
+	if methods[http.MethodGet] {
+		methods[http.MethodHead] = true
+	}
+	methods[http.MethodOptions] = true
+
+	allowed := make([]string, 0, len(methods))
+	for method := range methods {
+		allowed = append(allowed, method)
+	}
+	sort.Strings(allowed)
+	return allowed
+}
+
+// serves reports whether the router has a method route matching path
+func (rt *routeTable) serves(method, path string) bool {
+	for _, route := range rt.routes {
+		if route.Method == method && matchRoute(route.Path, path) {
+			return true
+		}
+	}
+	return false
+}
+
+// matchRoute reports whether path matches a gin route pattern with :param
+// and *catchAll segments
+func matchRoute(pattern, path string) bool {
+	patternParts := strings.Split(strings.Trim(pattern, "/"), "/")
+	pathParts := strings.Split(strings.Trim(path, "/"), "/")
+
+	for i, part := range patternParts {
+		if strings.HasPrefix(part, "*") {
+			return true
+		}
+		if i >= len(pathParts) {
+			return false
+		}
+		if strings.HasPrefix(part, ":") {
+			if pathParts[i] == "" {
+				return false
+			}
+			continue
+		}
+		if part != pathParts[i] {
+			return false
+		}
+	}
+	return len(patternParts) == len(pathParts)
+}
+
+// methodNotAllowed handles requests whose path exists under other methods.
+// OPTIONS requests that CORS did not answer as a preflight get 204 and the
+// Allow header; other methods get 405.
+func methodNotAllowed(rt *routeTable) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		allowed := rt.allowed(c.Request.URL.Path)
+		c.Header("Allow", strings.Join(allowed, ", "))
+
+		if c.Request.Method == http.MethodOptions {
+			c.AbortWithStatus(http.StatusNoContent)
+			return
+		}
+
+		c.AbortWithStatusJSON(http.StatusMethodNotAllowed, models.ErrorResponse{
+			Error:   "method_not_allowed",
+			Message: c.Request.Method + " is not supported here, use " + strings.Join(allowed, ", "),
+		})
+	}
+}
+
+// routeNotFound answers unknown paths with the API's error format
+func routeNotFound(c *gin.Context) {
+	c.AbortWithStatusJSON(http.StatusNotFound, models.ErrorResponse{
+		Error:   "not_found",
+		Message: "No route for " + c.Request.URL.Path,
+	})
+}
+
+// serveHEAD answers HEAD requests with the response headers of the matching
+// GET route. net/http drops the body because the original request is HEAD.
+func serveHEAD(router *gin.Engine, rt *routeTable) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.Method == http.MethodHead && !rt.serves(http.MethodHead, r.URL.Path) && rt.serves(http.MethodGet, r.URL.Path) {
+			get := r.Clone(r.Context())
+			get.Method = http.MethodGet
+			router.ServeHTTP(w, get)
+			return
+		}
+		router.ServeHTTP(w, r)
+	})
+}
@@ -0,0 +1,378 @@
+package app
+
+import (
+	"log"
+
+	_ "rice-monitor-api/docs"
+	"rice-monitor-api/middleware"
+	"rice-monitor-api/utils"
+
+	"github.com/gin-gonic/gin"
+	swaggerFiles "github.com/swaggo/files"
+	ginSwagger "github.com/swaggo/gin-swagger"
+)
+
+// routes builds the router from the app's handlers and middleware
+func (a *App) routes() *gin.Engine {
+	h := a.Handlers
+	authMiddleware := a.AuthMiddleware
+
+	router := gin.New()
+	router.HandleMethodNotAllowed = true
+	router.Use(gin.Logger())
+
+	// Report panics with request context instead of gin's default recovery
+	router.Use(middleware.Recovery(a.Services.Errors))
+
+	// Use CORS middleware. It answers preflight requests from allowed origins;
+	// other OPTIONS requests get the Allow header from methodNotAllowed.
+	router.Use(middleware.CORSMiddleware())
+
+	// Health check
+	router.GET("/health", func(c *gin.Context) {
+		log.Println("Health check endpoint hit")
+		c.JSON(200, gin.H{
+			"status":    "healthy",
+			"timestamp": "2024-01-01T00:00:00Z",
+			"version":   "1.0.0",
+		})
+	})
+
+	// API routes
+	api := router.Group("/api/v1")
+	api.Use(middleware.CSRFProtect())
+	{
+		// Authentication routes. Credential endpoints are rate limited per client IP.
+		auth := api.Group("/auth")
+		{
+			limited := auth.Group("", middleware.NewAuthRateLimiter().Limit())
+			limited.POST("/google", func(c *gin.Context) {
+				log.Println("=== GOOGLE LOGIN ENDPOINT HIT ===")
+				h.Auth.GoogleLogin(c)
+			})
+			limited.POST("/oauth/:provider", h.Auth.OAuthLogin)
+			limited.POST("/signup", h.Auth.Signup)
+			limited.POST("/verify-email", h.Auth.VerifyEmail)
+			limited.POST("/login", h.Auth.Login)
+			limited.POST("/password/forgot", h.Auth.ForgotPassword)
+			limited.POST("/password/reset", h.Auth.ResetPassword)
+			limited.POST("/magic-link", h.Auth.RequestMagicLink)
+			limited.POST("/magic-link/verify", h.Auth.VerifyMagicLink)
+			limited.POST("/refresh", h.Auth.RefreshToken)
+			limited.POST("/token", h.ServiceAccount.IssueToken)
+
+			auth.POST("/logout", authMiddleware.RequireAuth(), h.Auth.Logout)
+			auth.POST("/logout-all", authMiddleware.RequireAuth(), h.Auth.LogoutAll)
+			auth.GET("/me", authMiddleware.RequireAuth(), h.Auth.GetCurrentUser)
+			auth.GET("/sessions", authMiddleware.RequireAuth(), h.Auth.GetSessions)
+			auth.DELETE("/sessions/:id", authMiddleware.RequireAuth(), h.Auth.RevokeSession)
+		}
+
+		// Public open data feed (DCAT/CKAN compatible)
+		openData := api.Group("/open-data")
+		{
+			openData.GET("/catalog", h.Dataset.GetCatalog)
+			openData.GET("/datasets/:id/submissions.csv", h.Dataset.DownloadDataset)
+		}
+
+		// Machine-readable documentation of submissions and exports
+		api.GET("/meta/data-dictionary", h.Meta.GetDataDictionary)
+		api.GET("/meta/config", h.Meta.GetConfig)
+		api.GET("/meta/config/version", h.Meta.GetConfigVersion)
+		api.GET("/vocabularies", h.Meta.GetVocabularies)
+
+		// Inbound email webhook (authenticated by shared secret)
+		api.POST("/inbound/email", h.InboundEmail.ReceiveEmail)
+
+		// Inbound SMS webhook (authenticated by shared secret)
+		api.POST("/inbound/sms", h.InboundSMS.ReceiveSMS)
+
+		// Read-only exports for collaborators, scoped by share token
+		api.GET("/shared/submissions/export", h.Submission.ExportSharedSubmissions)
+		api.GET("/shared/submission", h.Submission.GetSharedSubmission)
+
+		// Public widget data for partner websites, scoped by embed token
+		api.GET("/embed/widgets/:widget", h.Embed.GetWidget)
+
+		// Protected routes
+		protected := api.Group("/")
+		protected.Use(authMiddleware.RequireAuth())
+		{
+			// Users
+			users := protected.Group("/users")
+			{
+				users.GET("", authMiddleware.RequirePermission(utils.PermUsersRead), h.User.GetUsers)
+				users.GET("/pending", authMiddleware.RequirePermission(utils.PermUsersManage), h.User.GetPendingUsers)
+				users.GET("/search", authMiddleware.RequirePermission(utils.PermUsersRead), h.User.SearchUsers)
+				users.GET("/export", authMiddleware.RequirePermission(utils.PermUsersRead), h.User.ExportUsers)
+				users.GET("/:id", h.User.GetUser)
+				users.GET("/:id/reputation", h.User.GetUserReputation)
+				users.GET("/:id/stats", h.User.GetUserStats)
+				users.GET("/:id/assigned-fields", h.User.GetAssignedFields)
+				users.GET("/:id/preferences", h.User.GetPreferences)
+				users.PUT("/:id/preferences", h.User.UpdatePreferences)
+				users.GET("/:id/notification-settings", h.User.GetNotificationSettings)
+				users.PUT("/:id/notification-settings", h.User.UpdateNotificationSettings)
+				users.DELETE("/:id/notification-settings", h.User.ResetNotificationSettings)
+				users.GET("/:id/tokens", h.PersonalToken.GetTokens)
+				users.POST("/:id/tokens", h.PersonalToken.CreateToken)
+				users.DELETE("/:id/tokens/:tokenId", h.PersonalToken.RevokeToken)
+				users.PUT("/:id", h.User.UpdateUser)
+				users.POST("/:id/approve", authMiddleware.RequirePermission(utils.PermUsersManage), h.User.ApproveUser)
+				users.PUT("/:id/role", authMiddleware.RequirePermission(utils.PermUsersManage), h.User.UpdateUserRole)
+				users.PUT("/:id/suspend", authMiddleware.RequirePermission(utils.PermUsersManage), h.User.SuspendUser)
+				users.PUT("/:id/reactivate", authMiddleware.RequirePermission(utils.PermUsersManage), h.User.ReactivateUser)
+				users.DELETE("/:id", authMiddleware.RequirePermission(utils.PermUsersManage), h.User.DeleteUser)
+				users.GET("/:id/deletion", authMiddleware.RequirePermission(utils.PermUsersManage), h.User.GetUserDeletion)
+				users.POST("/:id/restore", authMiddleware.RequirePermission(utils.PermUsersManage), h.User.RestoreUser)
+				users.GET("/:id/export", h.User.ExportUserData)
+				users.POST("/:id/erase", h.User.EraseUser)
+			}
+
+			// Organizations sharing the deployment
+			organizations := protected.Group("/organizations")
+			{
+				organizations.GET("", h.Organization.GetOrganizations)
+				organizations.POST("", authMiddleware.RequirePermission(utils.PermOrganizationsManage), h.Organization.CreateOrganization)
+				organizations.GET("/:id", h.Organization.GetOrganization)
+				organizations.PUT("/:id", authMiddleware.RequirePermission(utils.PermOrganizationsManage), h.Organization.UpdateOrganization)
+				organizations.DELETE("/:id", authMiddleware.RequirePermission(utils.PermOrganizationsManage), h.Organization.DeleteOrganization)
+				organizations.GET("/:id/members", h.Organization.GetMembers)
+				organizations.PUT("/:id/members/:userId", authMiddleware.RequirePermission(utils.PermOrganizationsManage), h.Organization.AddMember)
+				organizations.DELETE("/:id/members/:userId", h.Organization.RemoveMember)
+			}
+
+			// Monitoring submissions
+			submissions := protected.Group("/submissions")
+			{
+				submissions.GET("", h.Submission.GetSubmissions)
+				submissions.POST("", authMiddleware.RequireApproved(), h.Submission.CreateSubmission)
+				submissions.POST("/community", authMiddleware.RequireApproved(), h.Submission.CreateCommunitySubmission)
+				submissions.POST("/batch", authMiddleware.RequireApproved(), h.Submission.CreateSubmissionBatch)
+				submissions.POST("/sync", h.Submission.SyncSubmissions)
+				submissions.POST("/bulk", authMiddleware.RequirePermission(utils.PermSubmissionsApprove), h.Submission.BulkUpdateSubmissions)
+				submissions.GET("/:id", h.Submission.GetSubmission)
+				submissions.PUT("/:id", h.Submission.UpdateSubmission)
+				submissions.DELETE("/:id", h.Submission.DeleteSubmission)
+				submissions.PUT("/:id/evidence", h.Submission.UpdateEvidence)
+				submissions.GET("/:id/report.pdf", h.Submission.GetSubmissionReport)
+				submissions.POST("/:id/attachments", h.Submission.UploadAttachment)
+				submissions.GET("/:id/attachments/:attachmentId", h.Submission.GetAttachment)
+				submissions.DELETE("/:id/attachments/:attachmentId", h.Submission.DeleteAttachment)
+				submissions.POST("/:id/attachments/:attachmentId/transcribe", h.Submission.TranscribeAttachment)
+				submissions.POST("/:id/duplicate", authMiddleware.RequireApproved(), h.Submission.DuplicateSubmission)
+				submissions.POST("/:id/share", authMiddleware.RequireAgreement(), h.Submission.CreateSubmissionShare)
+				submissions.GET("/:id/shares", h.Submission.GetSubmissionShares)
+				submissions.DELETE("/:id/shares/:shareId", h.Submission.RevokeSubmissionShare)
+				submissions.GET("/:id/audit", authMiddleware.RequirePermission(utils.PermSubmissionsAuditRead), h.Submission.GetSubmissionAudit)
+				submissions.GET("/:id/stage-comparison", authMiddleware.RequirePermission(utils.PermSubmissionsApprove), h.Submission.GetStageComparison)
+				submissions.PUT("/:id/status", authMiddleware.RequirePermission(utils.PermSubmissionsApprove), h.Submission.ReviewSubmission)
+				submissions.POST("/:id/approve", authMiddleware.RequirePermission(utils.PermSubmissionsApprove), h.Submission.ApproveSubmission)
+				submissions.POST("/:id/reject", authMiddleware.RequirePermission(utils.PermSubmissionsApprove), h.Submission.RejectSubmission)
+				submissions.GET("/search", h.Submission.SearchSubmissions)
+				submissions.GET("/tags", h.Submission.GetSubmissionTags)
+				submissions.GET("/overdue", h.Submission.GetOverdueFields)
+				submissions.GET("/export", authMiddleware.RequireAgreement(), h.Submission.ExportSubmissions)
+				submissions.GET("/reviews/export", authMiddleware.RequirePermission(utils.PermSubmissionsReadAll), authMiddleware.RequireAgreement(), h.Submission.ExportReviewDecisions)
+			}
+
+			// Image upload
+			images := protected.Group("/images")
+			{
+				images.POST("/upload", authMiddleware.RequireApproved(), h.Image.UploadImage)
+				images.GET("/:filename", h.Image.GetImage)
+				images.DELETE("/:filename", authMiddleware.RequirePermission(utils.PermImagesDelete), h.Image.DeleteImage)
+			}
+
+			// Analytics
+			analytics := protected.Group("/analytics")
+			{
+				analytics.GET("/dashboard", h.Analytics.GetDashboardData)
+				analytics.GET("/trends", h.Analytics.GetTrends)
+				analytics.GET("/reports", h.Analytics.GetReports)
+				analytics.GET("/jobs/:id", h.Analytics.GetJob)
+				analytics.GET("/corrections", authMiddleware.RequirePermission(utils.PermAnalyticsReadAll), h.Analytics.GetCorrections)
+				analytics.GET("/hills", h.Analytics.GetHillTrajectories)
+				analytics.GET("/red-flags", authMiddleware.RequirePermission(utils.PermAnalyticsReadAll), h.Analytics.GetRedFlags)
+			}
+
+			// Overview for the current user's visits today
+			protected.GET("/me/today", h.Field.GetToday)
+
+			// Farms grouping fields by site
+			farms := protected.Group("/farms")
+			{
+				farms.GET("", h.Farm.GetFarms)
+				farms.POST("", authMiddleware.RequirePermission(utils.PermFarmsManage), h.Farm.CreateFarm)
+				farms.GET("/:id", h.Farm.GetFarm)
+				farms.PUT("/:id", h.Farm.UpdateFarm)
+				farms.DELETE("/:id", authMiddleware.RequirePermission(utils.PermFarmsManage), h.Farm.DeleteFarm)
+			}
+
+			// Fields management
+			fields := protected.Group("/fields")
+			{
+				fields.GET("", h.Field.GetFields)
+				fields.POST("", h.Field.CreateField)
+				fields.GET("/export", authMiddleware.RequireAgreement(), h.Field.ExportFields)
+				fields.GET("/nearby", h.Field.GetNearbyFields)
+				fields.GET("/:id", h.Field.GetField)
+				fields.GET("/:id/submissions", h.Submission.GetFieldSubmissions)
+				fields.GET("/:id/critical-windows", h.Field.GetCriticalWindows)
+				fields.GET("/:id/notes", h.Field.GetFieldNotes)
+				fields.POST("/:id/notes", h.Field.CreateFieldNote)
+				fields.PUT("/:id/notes/:noteId", h.Field.UpdateFieldNote)
+				fields.DELETE("/:id/notes/:noteId", h.Field.DeleteFieldNote)
+				fields.GET("/:id/seasons", h.Field.GetFieldSeasons)
+				fields.POST("/:id/seasons", h.Field.CreateFieldSeason)
+				fields.PUT("/:id/seasons/:seasonId", h.Field.UpdateFieldSeason)
+				fields.DELETE("/:id/seasons/:seasonId", h.Field.DeleteFieldSeason)
+				fields.PUT("/:id", h.Field.UpdateField)
+				fields.DELETE("/:id", h.Field.DeleteField)
+				fields.POST("/:id/archive", h.Field.ArchiveField)
+				fields.POST("/:id/unarchive", h.Field.UnarchiveField)
+				fields.GET("/:id/observers", h.Field.GetFieldObservers)
+				fields.GET("/:id/collaborators", h.Field.GetFieldCollaborators)
+				fields.PUT("/:id/collaborators/:userId", h.Field.SetFieldCollaborator)
+				fields.DELETE("/:id/collaborators/:userId", h.Field.RemoveFieldCollaborator)
+				fields.POST("/:id/observers", authMiddleware.RequirePermission(utils.PermFieldsAssign), h.Field.AssignObserver)
+				fields.DELETE("/:id/observers/:userId", authMiddleware.RequirePermission(utils.PermFieldsAssign), h.Field.UnassignObserver)
+			}
+
+			// API keys for programmatic clients
+			apiKeys := protected.Group("/api-keys")
+			{
+				apiKeys.GET("", h.APIKey.GetAPIKeys)
+				apiKeys.POST("", h.APIKey.CreateAPIKey)
+				apiKeys.DELETE("/:id", h.APIKey.RevokeAPIKey)
+			}
+
+			// Data access agreements external collaborators accept before exporting
+			agreements := protected.Group("/agreements")
+			{
+				agreements.GET("", authMiddleware.RequirePermission(utils.PermAgreementsManage), h.DataAgreement.GetAgreements)
+				agreements.POST("", authMiddleware.RequirePermission(utils.PermAgreementsManage), h.DataAgreement.CreateAgreement)
+				agreements.GET("/current", h.DataAgreement.GetCurrentAgreement)
+				agreements.POST("/current/accept", h.DataAgreement.AcceptAgreement)
+				agreements.GET("/:id/acceptances", authMiddleware.RequirePermission(utils.PermAgreementsManage), h.DataAgreement.GetAcceptances)
+			}
+
+			// Scheduled submissions exports and their past runs
+			exports := protected.Group("/exports")
+			{
+				exports.GET("", h.ExportSchedule.GetExportRuns)
+				exports.GET("/schedules", h.ExportSchedule.GetExportSchedules)
+				exports.POST("/schedules", authMiddleware.RequireAgreement(), h.ExportSchedule.CreateExportSchedule)
+				exports.DELETE("/schedules/:id", h.ExportSchedule.DeleteExportSchedule)
+			}
+
+			// Share links for collaborators without an account
+			protected.POST("/share-tokens", authMiddleware.RequireAgreement(), h.ShareToken.CreateShareToken)
+
+			// Dataset releases (admin only)
+			datasets := protected.Group("/datasets")
+			datasets.Use(authMiddleware.RequirePermission(utils.PermDatasetsManage))
+			{
+				datasets.GET("", h.Dataset.GetDatasets)
+				datasets.POST("", h.Dataset.CreateDataset)
+				datasets.POST("/:id/publish", h.Dataset.PublishDataset)
+			}
+
+			// Service accounts (admin only)
+			serviceAccounts := protected.Group("/service-accounts")
+			serviceAccounts.Use(authMiddleware.RequirePermission(utils.PermServiceAccountsManage))
+			{
+				serviceAccounts.GET("", h.ServiceAccount.GetServiceAccounts)
+				serviceAccounts.POST("", h.ServiceAccount.CreateServiceAccount)
+				serviceAccounts.DELETE("/:id", h.ServiceAccount.DisableServiceAccount)
+			}
+
+			// Embed tokens for dashboard widgets (admin only)
+			embedTokens := protected.Group("/embed-tokens")
+			embedTokens.Use(authMiddleware.RequirePermission(utils.PermEmbedsManage))
+			{
+				embedTokens.GET("", h.Embed.GetEmbedTokens)
+				embedTokens.POST("", h.Embed.CreateEmbedToken)
+				embedTokens.DELETE("/:id", h.Embed.RevokeEmbedToken)
+			}
+
+			// Invites for new users (admin only)
+			invites := protected.Group("/invites")
+			invites.Use(authMiddleware.RequirePermission(utils.PermInvitesManage))
+			{
+				invites.GET("", h.Invite.GetInvites)
+				invites.POST("", h.Invite.CreateInvite)
+				invites.DELETE("/:id", h.Invite.RevokeInvite)
+			}
+
+			// Drought and flood events
+			stressEvents := protected.Group("/stress-events")
+			{
+				stressEvents.GET("", h.StressEvent.GetStressEvents)
+				stressEvents.POST("", authMiddleware.RequirePermission(utils.PermStressEventsManage), h.StressEvent.CreateStressEvent)
+				stressEvents.DELETE("/:id", authMiddleware.RequirePermission(utils.PermStressEventsManage), h.StressEvent.DeleteStressEvent)
+				stressEvents.GET("/:id/impact", authMiddleware.RequirePermission(utils.PermAnalyticsReadAll), h.StressEvent.GetImpactReport)
+			}
+
+			// Instrument calibrations
+			calibrations := protected.Group("/calibrations")
+			calibrations.Use(authMiddleware.RequirePermission(utils.PermCalibrationsManage))
+			{
+				calibrations.GET("", h.Calibration.GetCalibrations)
+				calibrations.POST("", h.Calibration.CreateCalibration)
+				calibrations.DELETE("/:id", h.Calibration.RetireCalibration)
+			}
+
+			// Trait definitions, custom ones are defined per deployment
+			traits := protected.Group("/traits")
+			{
+				traits.GET("", h.Trait.GetTraits)
+				traits.POST("", authMiddleware.RequirePermission(utils.PermTraitsManage), h.Trait.CreateTrait)
+				traits.PUT("/:key", authMiddleware.RequirePermission(utils.PermTraitsManage), h.Trait.UpdateTrait)
+				traits.DELETE("/:key", authMiddleware.RequirePermission(utils.PermTraitsManage), h.Trait.DeleteTrait)
+			}
+
+			// Administration
+			admin := protected.Group("/admin")
+			{
+				admin.GET("/quality-reports", authMiddleware.RequirePermission(utils.PermQualityReportsRead), h.QualityReport.GetQualityReports)
+				admin.GET("/quality-reports/:id", authMiddleware.RequirePermission(utils.PermQualityReportsRead), h.QualityReport.GetQualityReport)
+				admin.GET("/auth-events", authMiddleware.RequirePermission(utils.PermAuthEventsRead), h.AuthEvent.GetAuthEvents)
+				admin.GET("/app-versions", authMiddleware.RequirePermission(utils.PermSubmissionsReadAll), h.Submission.GetAppVersionStats)
+
+				integrations := admin.Group("/integrations")
+				integrations.Use(authMiddleware.RequirePermission(utils.PermIntegrationsManage))
+				{
+					integrations.GET("", h.Integration.GetIntegrations)
+					integrations.POST("", h.Integration.CreateIntegration)
+					integrations.PUT("/:id", h.Integration.UpdateIntegration)
+					integrations.DELETE("/:id", h.Integration.DeleteIntegration)
+					integrations.POST("/:id/test", h.Integration.TestIntegration)
+				}
+
+				webhooks := admin.Group("/webhooks")
+				webhooks.Use(authMiddleware.RequirePermission(utils.PermIntegrationsManage))
+				{
+					webhooks.GET("", h.Webhook.GetWebhooks)
+					webhooks.POST("", h.Webhook.CreateWebhook)
+					webhooks.PUT("/:id", h.Webhook.UpdateWebhook)
+					webhooks.DELETE("/:id", h.Webhook.DeleteWebhook)
+					webhooks.POST("/:id/rotate-secret", h.Webhook.RotateWebhookSecret)
+					webhooks.POST("/:id/test", h.Webhook.TestWebhook)
+					webhooks.GET("/:id/deliveries", h.Webhook.GetWebhookDeliveries)
+					webhooks.POST("/:id/deliveries/:deliveryId/redeliver", h.Webhook.RedeliverWebhookDelivery)
+				}
+			}
+		}
+	}
+
+	// Swagger endpoint
+	router.GET("/swagger/*any", ginSwagger.WrapHandler(swaggerFiles.Handler))
+
+	// Unsupported methods get 405 with the methods the route table allows
+	a.routeTable = newRouteTable(router.Routes())
+	router.NoMethod(methodNotAllowed(a.routeTable))
+	router.NoRoute(routeNotFound)
+
+	return router
+}
@@ -1,25 +1,38 @@
 package handlers
 
 import (
+	"context"
+	"errors"
+	"fmt"
+	"math"
 	"net/http"
 	"strconv"
+	"strings"
+	"sync"
 	"time"
 
 	"rice-monitor-api/models"
 	"rice-monitor-api/services"
+	"rice-monitor-api/utils"
 
 	"cloud.google.com/go/firestore"
+	"cloud.google.com/go/firestore/apiv1/firestorepb"
 	"github.com/gin-gonic/gin"
-	"google.golang.org/api/iterator"
 )
 
 type AnalyticsHandler struct {
-	firestoreService *services.FirestoreService
+	firestoreService  *services.FirestoreService
+	jobService        *services.JobService
+	reputationService *services.ReputationService
+	vocabularyService *services.VocabularyService
 }
 
-func NewAnalyticsHandler(firestoreService *services.FirestoreService) *AnalyticsHandler {
+func NewAnalyticsHandler(firestoreService *services.FirestoreService, jobService *services.JobService, reputationService *services.ReputationService, vocabularyService *services.VocabularyService) *AnalyticsHandler {
 	return &AnalyticsHandler{
-		firestoreService: firestoreService,
+		firestoreService:  firestoreService,
+		jobService:        jobService,
+		reputationService: reputationService,
+		vocabularyService: vocabularyService,
 	}
 }
 
@@ -28,58 +41,89 @@ func NewAnalyticsHandler(firestoreService *services.FirestoreService) *Analytics
 // @Tags analytics
 // @Produce  json
 // @Security ApiKeyAuth
+// @Param farm_id query string false "Only include submissions of this farm's fields"
+// @Param season_id query string false "Only include submissions of this season"
+// @Param provenance query string false "Only include research or community submissions"
+// @Param min_tier query string false "Exclude community submissions below this reputation tier (low, new, standard, trusted)"
+// @Param localize query string false "Include display labels for statuses, stages and conditions in this locale (en, bn)"
 // @Success 200 {object} models.SuccessResponse
+// @Failure 400 {object} models.ErrorResponse
 // @Failure 500 {object} models.ErrorResponse
 // @Router /analytics/dashboard [get]
 func (ah *AnalyticsHandler) GetDashboardData(c *gin.Context) {
 	currentUser, _ := c.Get("user")
 	user := currentUser.(*models.User)
 
+	filter, err := parseAnalyticsFilter(c)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, models.ErrorResponse{
+			Error:   "invalid_request",
+			Message: err.Error(),
+		})
+		return
+	}
+	labels, err := ah.parseLocalize(c)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, models.ErrorResponse{
+			Error:   "invalid_locale",
+			Message: err.Error(),
+		})
+		return
+	}
+
 	ctx := ah.firestoreService.Context()
 
-	// Get submissions count
 	submissionsQuery := ah.firestoreService.Submissions().Query
-	if user.Role != "admin" {
+	if !utils.HasPermissionIn(user, utils.PermAnalyticsReadAll, user.OrgID) {
 		submissionsQuery = submissionsQuery.Where("user_id", "==", user.ID)
+	} else {
+		submissionsQuery = orgScope(submissionsQuery, user)
 	}
-
-	totalSubmissions := 0
-	submissionsByStatus := make(map[string]int)
-	submissionsByStage := make(map[string]int)
-
-	iter := submissionsQuery.Documents(ctx)
-	for {
-		doc, err := iter.Next()
-		if err == iterator.Done {
-			break
-		}
-		if err != nil {
-			c.JSON(http.StatusInternalServerError, models.ErrorResponse{
-				Error:   "internal_error",
-				Message: "Failed to retrieve dashboard data",
-			})
-			return
-		}
-
-		var submission models.Submission
-		doc.DataTo(&submission)
-
-		totalSubmissions++
-		submissionsByStatus[submission.Status]++
-		submissionsByStage[submission.GrowthStage]++
+	submissionsQuery = filter.scope(submissionsQuery)
+
+	// Provenance and reputation tiers can only be filtered on the documents
+	var dashboardData models.DashboardData
+	if filter.Provenance != "" || filter.MinTier != "" {
+		dashboardData, err = ah.filteredDashboard(ctx, submissionsQuery, filter)
+	} else {
+		dashboardData, err = ah.countedDashboard(ctx, submissionsQuery)
 	}
-
-	// Get recent submissions (last 5)
-	recentQuery := submissionsQuery.OrderBy("created_at", firestore.Desc).Limit(5)
-	recentDocs, err := recentQuery.Documents(ctx).GetAll()
 	if err != nil {
 		c.JSON(http.StatusInternalServerError, models.ErrorResponse{
 			Error:   "internal_error",
-			Message: "Failed to retrieve recent submissions",
+			Message: "Failed to retrieve dashboard data",
 		})
 		return
 	}
+	dashboardData.LastUpdated = time.Now()
+	dashboardData.Labels = labels
 
+	c.JSON(http.StatusOK, models.SuccessResponse{
+		Success: true,
+		Data:    dashboardData,
+	})
+}
+
+// countedDashboard counts the submissions of query with aggregation queries
+// instead of reading every submission, and reads the last 5
+func (ah *AnalyticsHandler) countedDashboard(ctx context.Context, query firestore.Query) (models.DashboardData, error) {
+	totalSubmissions, err := countDocuments(ctx, query)
+	if err != nil {
+		return models.DashboardData{}, err
+	}
+	submissionsByStatus, err := countByValue(ctx, query, "status", utils.SubmissionStatuses)
+	if err != nil {
+		return models.DashboardData{}, err
+	}
+	submissionsByStage, err := countByValue(ctx, query, "growth_stage", ah.vocabularyService.GrowthStages())
+	if err != nil {
+		return models.DashboardData{}, err
+	}
+
+	recentDocs, err := query.OrderBy("created_at", firestore.Desc).Limit(5).Documents(ctx).GetAll()
+	if err != nil {
+		return models.DashboardData{}, err
+	}
 	var recentSubmissions []models.Submission
 	for _, doc := range recentDocs {
 		var submission models.Submission
@@ -87,27 +131,65 @@ func (ah *AnalyticsHandler) GetDashboardData(c *gin.Context) {
 		recentSubmissions = append(recentSubmissions, submission)
 	}
 
-	dashboardData := models.DashboardData{
-		TotalSubmissions:    totalSubmissions,
+	return models.DashboardData{
+		TotalSubmissions:    int(totalSubmissions),
 		SubmissionsByStatus: submissionsByStatus,
 		SubmissionsByStage:  submissionsByStage,
 		RecentSubmissions:   recentSubmissions,
-		LastUpdated:         time.Now(),
+	}, nil
+}
+
+// filteredDashboard reads the submissions of query, drops those excluded by
+// the provenance and min_tier of filter and counts the rest
+func (ah *AnalyticsHandler) filteredDashboard(ctx context.Context, query firestore.Query, filter analyticsFilter) (models.DashboardData, error) {
+	docs, err := query.OrderBy("created_at", firestore.Desc).Documents(ctx).GetAll()
+	if err != nil {
+		return models.DashboardData{}, err
+	}
+	docs, _, err = ah.filterDocuments(docs, filter)
+	if err != nil {
+		return models.DashboardData{}, err
 	}
 
-	c.JSON(http.StatusOK, models.SuccessResponse{
-		Success: true,
-		Data:    dashboardData,
-	})
+	stages := ah.vocabularyService.GrowthStages()
+	dashboardData := models.DashboardData{
+		TotalSubmissions:    len(docs),
+		SubmissionsByStatus: make(map[string]int),
+		SubmissionsByStage:  make(map[string]int),
+	}
+	for _, doc := range docs {
+		var submission models.Submission
+		doc.DataTo(&submission)
+
+		// Only the known codes are counted, as with aggregation queries
+		if utils.Contains(utils.SubmissionStatuses, submission.Status) {
+			dashboardData.SubmissionsByStatus[submission.Status]++
+		}
+		if utils.Contains(stages, submission.GrowthStage) {
+			dashboardData.SubmissionsByStage[submission.GrowthStage]++
+		}
+		if len(dashboardData.RecentSubmissions) < 5 {
+			dashboardData.RecentSubmissions = append(dashboardData.RecentSubmissions, submission)
+		}
+	}
+	return dashboardData, nil
 }
 
 // @Summary Get Trends Data
-// @Description Get trends analytics data
+// @Description Get trends analytics data. Queries estimated to scan more than
+// @Description ANALYTICS_ASYNC_THRESHOLD documents return 202 with a job to poll.
 // @Tags analytics
 // @Produce  json
 // @Security ApiKeyAuth
-// @Param days query int false "Number of days to look back"
+// @Param days query int false "Number of days to look back, or the whole season when season_id is given"
+// @Param farm_id query string false "Only include submissions of this farm's fields"
+// @Param season_id query string false "Only include submissions of this season"
+// @Param provenance query string false "Only include research or community submissions"
+// @Param min_tier query string false "Exclude community submissions below this reputation tier (low, new, standard, trusted)"
+// @Param localize query string false "Include display labels for statuses, stages and conditions in this locale (en, bn)"
 // @Success 200 {object} models.SuccessResponse
+// @Success 202 {object} models.SuccessResponse
+// @Failure 400 {object} models.ErrorResponse
 // @Failure 500 {object} models.ErrorResponse
 // @Router /analytics/trends [get]
 func (ah *AnalyticsHandler) GetTrends(c *gin.Context) {
@@ -116,78 +198,92 @@ func (ah *AnalyticsHandler) GetTrends(c *gin.Context) {
 
 	// Parse query parameters
 	days, _ := strconv.Atoi(c.DefaultQuery("days", "30"))
-
-	ctx := ah.firestoreService.Context()
+	filter, err := parseAnalyticsFilter(c)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, models.ErrorResponse{
+			Error:   "invalid_request",
+			Message: err.Error(),
+		})
+		return
+	}
+	labels, err := ah.parseLocalize(c)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, models.ErrorResponse{
+			Error:   "invalid_locale",
+			Message: err.Error(),
+		})
+		return
+	}
 
 	// Calculate date range
 	endDate := time.Now()
 	startDate := endDate.AddDate(0, 0, -days)
 
-	submissionsQuery := ah.firestoreService.Submissions().
-		Where("created_at", ">=", startDate).
-		Where("created_at", "<=", endDate)
-
-	if user.Role != "admin" {
-		submissionsQuery = submissionsQuery.Where("user_id", "==", user.ID)
-	}
-
-	iter := submissionsQuery.Documents(ctx)
-	dailySubmissions := make(map[string]int)
-	stageProgression := make(map[string][]string)
-
-	for {
-		doc, err := iter.Next()
-		if err == iterator.Done {
-			break
-		}
+	submissionsQuery := ah.firestoreService.Submissions().Query
+	if filter.SeasonID != "" && c.Query("days") == "" {
+		// The whole season, including observations synced after its harvest
+		season, err := getSeason(ah.firestoreService, filter.SeasonID)
 		if err != nil {
-			c.JSON(http.StatusInternalServerError, models.ErrorResponse{
-				Error:   "internal_error",
-				Message: "Failed to retrieve trends data",
+			c.JSON(http.StatusBadRequest, models.ErrorResponse{
+				Error:   "invalid_request",
+				Message: "season_id must be an existing season",
 			})
 			return
 		}
-
-		var submission models.Submission
-		doc.DataTo(&submission)
-
-		// Group by date
-		dateKey := submission.CreatedAt.Format("2006-01-02")
-		dailySubmissions[dateKey]++
-
-		// Track stage progression by field
-		if submission.FieldID != "" {
-			stageProgression[submission.FieldID] = append(
-				stageProgression[submission.FieldID],
-				submission.GrowthStage)
-		}
+		startDate, endDate = seasonSpan(season, endDate)
+		days = int(math.Ceil(endDate.Sub(startDate).Hours() / 24))
+	} else {
+		submissionsQuery = submissionsQuery.
+			Where("created_at", ">=", startDate).
+			Where("created_at", "<=", endDate)
 	}
 
-	trendsData := models.TrendsData{
-		DailySubmissions: dailySubmissions,
-		StageProgression: stageProgression,
-		Period: map[string]interface{}{
-			"start_date": startDate.Format("2006-01-02"),
-			"end_date":   endDate.Format("2006-01-02"),
-			"days":       days,
-		},
+	if !utils.HasPermissionIn(user, utils.PermAnalyticsReadAll, user.OrgID) {
+		submissionsQuery = submissionsQuery.Where("user_id", "==", user.ID)
+	} else {
+		submissionsQuery = orgScope(submissionsQuery, user)
 	}
-
-	c.JSON(http.StatusOK, models.SuccessResponse{
-		Success: true,
-		Data:    trendsData,
+	submissionsQuery = filter.scope(submissionsQuery)
+
+	// Days are bucketed in the user's timezone
+	loc := utils.UserLocation(user)
+
+	params := map[string]string{
+		"days":       strconv.Itoa(days),
+		"farm_id":    filter.FarmID,
+		"season_id":  filter.SeasonID,
+		"provenance": filter.Provenance,
+		"min_tier":   filter.MinTier,
+		"localize":   c.Query("localize"),
+		"timezone":   loc.String(),
+	}
+	ah.runAnalytics(c, user, "trends", params, submissionsQuery, func(ctx context.Context) (interface{}, error) {
+		trends, err := ah.buildTrends(ctx, submissionsQuery, filter, startDate.In(loc), endDate.In(loc), days)
+		if err != nil {
+			return nil, err
+		}
+		trends.Labels = labels
+		return trends, nil
 	})
 }
 
 // @Summary Get Reports
-// @Description Generate and retrieve reports
+// @Description Generate and retrieve reports. Queries estimated to scan more than
+// @Description ANALYTICS_ASYNC_THRESHOLD documents return 202 with a job to poll.
 // @Tags analytics
 // @Produce  json
 // @Security ApiKeyAuth
 // @Param type query string false "Report type (summary, detailed, field_analysis)"
 // @Param start_date query string false "Start date for the report (YYYY-MM-DD)"
 // @Param end_date query string false "End date for the report (YYYY-MM-DD)"
+// @Param farm_id query string false "Only include submissions of this farm's fields"
+// @Param season_id query string false "Only include submissions of this season"
+// @Param provenance query string false "Only include research or community submissions"
+// @Param min_tier query string false "Exclude community submissions below this reputation tier (low, new, standard, trusted)"
+// @Param localize query string false "Include display labels for statuses, stages and conditions in this locale (en, bn)"
 // @Success 200 {object} models.SuccessResponse
+// @Success 202 {object} models.SuccessResponse
+// @Failure 400 {object} models.ErrorResponse
 // @Failure 500 {object} models.ErrorResponse
 // @Router /analytics/reports [get]
 func (ah *AnalyticsHandler) GetReports(c *gin.Context) {
@@ -198,68 +294,410 @@ func (ah *AnalyticsHandler) GetReports(c *gin.Context) {
 	reportType := c.DefaultQuery("type", "summary")
 	startDate := c.Query("start_date")
 	endDate := c.Query("end_date")
+	filter, err := parseAnalyticsFilter(c)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, models.ErrorResponse{
+			Error:   "invalid_request",
+			Message: err.Error(),
+		})
+		return
+	}
+	labels, err := ah.parseLocalize(c)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, models.ErrorResponse{
+			Error:   "invalid_locale",
+			Message: err.Error(),
+		})
+		return
+	}
 
-	ctx := ah.firestoreService.Context()
 	query := ah.firestoreService.Submissions().Query
 
-	if user.Role != "admin" {
+	if !utils.HasPermissionIn(user, utils.PermAnalyticsReadAll, user.OrgID) {
 		query = query.Where("user_id", "==", user.ID)
+	} else {
+		query = orgScope(query, user)
 	}
+	query = filter.scope(query)
 
-	// Apply date filters if provided
+	// Apply date filters if provided, as days in the user's timezone
+	loc := utils.UserLocation(user)
 	if startDate != "" {
-		if start, err := time.Parse("2006-01-02", startDate); err == nil {
+		if start, err := time.ParseInLocation("2006-01-02", startDate, loc); err == nil {
 			query = query.Where("created_at", ">=", start)
 		}
 	}
 	if endDate != "" {
-		if end, err := time.Parse("2006-01-02", endDate); err == nil {
+		if end, err := time.ParseInLocation("2006-01-02", endDate, loc); err == nil {
 			query = query.Where("created_at", "<=", end)
 		}
 	}
 
-	docs, err := query.Documents(ctx).GetAll()
+	params := map[string]string{
+		"type":       reportType,
+		"start_date": startDate,
+		"end_date":   endDate,
+		"farm_id":    filter.FarmID,
+		"season_id":  filter.SeasonID,
+		"provenance": filter.Provenance,
+		"min_tier":   filter.MinTier,
+		"localize":   c.Query("localize"),
+		"timezone":   loc.String(),
+	}
+	ah.runAnalytics(c, user, "report", params, query, func(ctx context.Context) (interface{}, error) {
+		report, err := ah.buildReport(ctx, query, filter, reportType)
+		if err != nil {
+			return nil, err
+		}
+		if labels != nil {
+			report["labels"] = labels
+		}
+		return report, nil
+	})
+}
+
+// @Summary Get an analytics job
+// @Description Get the status of a long-running analytics job, including its result once done
+// @Tags analytics
+// @Produce  json
+// @Security ApiKeyAuth
+// @Param id path string true "Job ID"
+// @Success 200 {object} models.SuccessResponse
+// @Failure 403 {object} models.ErrorResponse
+// @Failure 404 {object} models.ErrorResponse
+// @Failure 500 {object} models.ErrorResponse
+// @Router /analytics/jobs/{id} [get]
+func (ah *AnalyticsHandler) GetJob(c *gin.Context) {
+	jobID := c.Param("id")
+	currentUser, _ := c.Get("user")
+	user := currentUser.(*models.User)
+
+	ctx := ah.firestoreService.Context()
+	doc, err := ah.firestoreService.AnalyticsJobs().Doc(jobID).Get(ctx)
+	if err != nil {
+		c.JSON(http.StatusNotFound, models.ErrorResponse{
+			Error:   "not_found",
+			Message: "Job not found",
+		})
+		return
+	}
+
+	var job models.AnalyticsJob
+	doc.DataTo(&job)
+
+	if job.UserID != user.ID && !utils.HasPermissionIn(user, utils.PermAnalyticsReadAll, job.OrgID) {
+		c.JSON(http.StatusForbidden, models.ErrorResponse{
+			Error:   "forbidden",
+			Message: "Access denied",
+		})
+		return
+	}
+
+	if job.Status == "done" {
+		job.Result, err = ah.jobService.Result(&job)
+		if err != nil {
+			c.JSON(http.StatusInternalServerError, models.ErrorResponse{
+				Error:   "internal_error",
+				Message: "Failed to retrieve job result",
+			})
+			return
+		}
+	}
+
+	c.JSON(http.StatusOK, models.SuccessResponse{
+		Success: true,
+		Data:    job,
+	})
+}
+
+// runAnalytics answers small queries inline and hands queries estimated to scan
+// more than ANALYTICS_ASYNC_THRESHOLD documents to the job workers
+func (ah *AnalyticsHandler) runAnalytics(c *gin.Context, user *models.User, jobType string, params map[string]string, query firestore.Query, run services.JobFunc) {
+	ctx := ah.firestoreService.Context()
+
+	estimated, err := countDocuments(ctx, query)
 	if err != nil {
 		c.JSON(http.StatusInternalServerError, models.ErrorResponse{
 			Error:   "internal_error",
-			Message: "Failed to generate report",
+			Message: "Failed to estimate query size",
 		})
 		return
 	}
 
-	var reportData interface{}
+	threshold, err := strconv.ParseInt(utils.GetEnvOrDefault("ANALYTICS_ASYNC_THRESHOLD", "5000"), 10, 64)
+	if err != nil {
+		threshold = 5000
+	}
+
+	if estimated <= threshold {
+		result, err := run(ctx)
+		if err != nil {
+			c.JSON(http.StatusInternalServerError, models.ErrorResponse{
+				Error:   "internal_error",
+				Message: "Failed to retrieve analytics data",
+			})
+			return
+		}
+
+		c.JSON(http.StatusOK, models.SuccessResponse{
+			Success: true,
+			Data:    result,
+		})
+		return
+	}
+
+	job := &models.AnalyticsJob{
+		ID:        utils.GenerateID(),
+		UserID:    user.ID,
+		OrgID:     user.OrgID,
+		Type:      jobType,
+		Params:    params,
+		Estimated: estimated,
+	}
+	if err := ah.jobService.Enqueue(job, run); err != nil {
+		if errors.Is(err, services.ErrJobQueueFull) {
+			c.JSON(http.StatusServiceUnavailable, models.ErrorResponse{
+				Error:   "queue_full",
+				Message: "Too many analytics jobs are running, try again later",
+			})
+			return
+		}
+		c.JSON(http.StatusInternalServerError, models.ErrorResponse{
+			Error:   "internal_error",
+			Message: "Failed to queue analytics job",
+		})
+		return
+	}
+
+	c.Header("Location", "/api/v1/analytics/jobs/"+job.ID)
+	c.JSON(http.StatusAccepted, models.SuccessResponse{
+		Success: true,
+		Data:    job,
+		Message: "Query is large and is being processed in the background",
+	})
+}
+
+// parseLocalize returns the display labels for the ?localize= locale, or nil
+// when no locale was requested
+func (ah *AnalyticsHandler) parseLocalize(c *gin.Context) (*models.LocalizedLabels, error) {
+	locale := c.Query("localize")
+	if locale == "" {
+		return nil, nil
+	}
+	return ah.vocabularyService.Labels(locale)
+}
+
+// countDocuments returns how many documents query matches using a count aggregation
+func countDocuments(ctx context.Context, query firestore.Query) (int64, error) {
+	result, err := query.NewAggregationQuery().WithCount("count").Get(ctx)
+	if err != nil {
+		return 0, err
+	}
+
+	value, ok := result["count"].(*firestorepb.Value)
+	if !ok {
+		return 0, errors.New("unexpected count aggregation result")
+	}
+
+	return value.GetIntegerValue(), nil
+}
+
+// countByValue counts the documents of query whose key has each of values,
+// running the counts concurrently. Values without documents are left out.
+func countByValue(ctx context.Context, query firestore.Query, key string, values []string) (map[string]int, error) {
+	counts := make([]int64, len(values))
+	errs := make([]error, len(values))
+	var wg sync.WaitGroup
+	for i, value := range values {
+		wg.Add(1)
+		go func(i int, value string) {
+			defer wg.Done()
+			counts[i], errs[i] = countDocuments(ctx, query.Where(key, "==", value))
+		}(i, value)
+	}
+	wg.Wait()
+
+	byValue := make(map[string]int)
+	for i, value := range values {
+		if errs[i] != nil {
+			return nil, errs[i]
+		}
+		if counts[i] > 0 {
+			byValue[value] = int(counts[i])
+		}
+	}
+	return byValue, nil
+}
+
+// analyticsFilter narrows analytics to one farm, one season, one provenance
+// and, for community observations, to contributors at or above a reputation tier
+type analyticsFilter struct {
+	FarmID     string
+	SeasonID   string
+	Provenance string
+	MinTier    string
+}
+
+// parseAnalyticsFilter reads the farm_id, season_id, provenance and min_tier query parameters
+func parseAnalyticsFilter(c *gin.Context) (analyticsFilter, error) {
+	filter := analyticsFilter{
+		FarmID:     c.Query("farm_id"),
+		SeasonID:   c.Query("season_id"),
+		Provenance: c.Query("provenance"),
+		MinTier:    c.Query("min_tier"),
+	}
+	if filter.Provenance != "" && filter.Provenance != "research" && filter.Provenance != "community" {
+		return filter, errors.New("provenance must be research or community")
+	}
+	if filter.MinTier != "" && services.TierRank(filter.MinTier) < 0 {
+		return filter, fmt.Errorf("min_tier must be one of: %s", strings.Join(services.ReputationTiers, ", "))
+	}
+	return filter, nil
+}
+
+// scope narrows a submissions query to the farm and season of the filter, if any
+func (filter analyticsFilter) scope(query firestore.Query) firestore.Query {
+	if filter.FarmID != "" {
+		query = query.Where("farm_id", "==", filter.FarmID)
+	}
+	if filter.SeasonID != "" {
+		query = query.Where("season_id", "==", filter.SeasonID)
+	}
+	return query
+}
+
+// filterDocuments drops documents excluded by filter and returns the reputations
+// of the remaining community contributors, used to weight their observations.
+// Provenance is filtered here rather than in the query so that records created
+// before provenance was tracked count as research.
+func (ah *AnalyticsHandler) filterDocuments(docs []*firestore.DocumentSnapshot, filter analyticsFilter) ([]*firestore.DocumentSnapshot, map[string]models.Reputation, error) {
+	submissions := make([]models.Submission, len(docs))
+	var contributors []string
+	seen := make(map[string]bool)
+	for i, doc := range docs {
+		doc.DataTo(&submissions[i])
+		if submissionProvenance(submissions[i]) == "community" && !seen[submissions[i].UserID] {
+			seen[submissions[i].UserID] = true
+			contributors = append(contributors, submissions[i].UserID)
+		}
+	}
+
+	reputations, err := ah.reputationService.GetMany(contributors)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	minRank := services.TierRank(filter.MinTier)
+	var filtered []*firestore.DocumentSnapshot
+	for i, doc := range docs {
+		provenance := submissionProvenance(submissions[i])
+		if filter.Provenance != "" && provenance != filter.Provenance {
+			continue
+		}
+		if provenance == "community" && minRank > 0 &&
+			services.TierRank(contributorTier(reputations, submissions[i].UserID)) < minRank {
+			continue
+		}
+		filtered = append(filtered, doc)
+	}
+
+	return filtered, reputations, nil
+}
+
+// contributorTier returns the reputation tier of userID, "new" when not yet scored
+func contributorTier(reputations map[string]models.Reputation, userID string) string {
+	if reputation, ok := reputations[userID]; ok {
+		return reputation.Tier
+	}
+	return "new"
+}
+
+func (ah *AnalyticsHandler) buildTrends(ctx context.Context, query firestore.Query, filter analyticsFilter, startDate, endDate time.Time, days int) (models.TrendsData, error) {
+	docs, err := query.Documents(ctx).GetAll()
+	if err != nil {
+		return models.TrendsData{}, err
+	}
+
+	docs, _, err = ah.filterDocuments(docs, filter)
+	if err != nil {
+		return models.TrendsData{}, err
+	}
+
+	dailySubmissions := make(map[string]int)
+	stageProgression := make(map[string][]string)
+
+	for _, doc := range docs {
+		var submission models.Submission
+		doc.DataTo(&submission)
+
+		// Group by date
+		dateKey := submission.CreatedAt.In(startDate.Location()).Format("2006-01-02")
+		dailySubmissions[dateKey]++
+
+		// Track stage progression by field
+		if submission.FieldID != "" {
+			stageProgression[submission.FieldID] = append(
+				stageProgression[submission.FieldID],
+				submission.GrowthStage)
+		}
+	}
+
+	return models.TrendsData{
+		DailySubmissions: dailySubmissions,
+		StageProgression: stageProgression,
+		Period: map[string]interface{}{
+			"start_date": startDate.Format("2006-01-02"),
+			"end_date":   endDate.Format("2006-01-02"),
+			"days":       days,
+		},
+	}, nil
+}
+
+func (ah *AnalyticsHandler) buildReport(ctx context.Context, query firestore.Query, filter analyticsFilter, reportType string) (map[string]interface{}, error) {
+	docs, err := query.Documents(ctx).GetAll()
+	if err != nil {
+		return nil, err
+	}
+
+	docs, reputations, err := ah.filterDocuments(docs, filter)
+	if err != nil {
+		return nil, err
+	}
 
 	switch reportType {
-	case "summary":
-		reportData = ah.generateSummaryReport(docs)
 	case "detailed":
-		reportData = ah.generateDetailedReport(docs)
+		return ah.generateDetailedReport(docs), nil
 	case "field_analysis":
-		reportData = ah.generateFieldAnalysisReport(docs)
+		return ah.generateFieldAnalysisReport(docs), nil
 	default:
-		reportData = ah.generateSummaryReport(docs)
+		return ah.generateSummaryReport(docs, reputations), nil
 	}
-
-	c.JSON(http.StatusOK, models.SuccessResponse{
-		Success: true,
-		Data:    reportData,
-	})
 }
 
 // Report generation functions
-func (ah *AnalyticsHandler) generateSummaryReport(docs []*firestore.DocumentSnapshot) map[string]interface{} {
+func (ah *AnalyticsHandler) generateSummaryReport(docs []*firestore.DocumentSnapshot, reputations map[string]models.Reputation) map[string]interface{} {
 	totalSubmissions := len(docs)
 	statusCounts := make(map[string]int)
 	stageCounts := make(map[string]int)
 	conditionCounts := make(map[string]int)
+	provenanceCounts := make(map[string]int)
+	// Community observations count by their contributor's reputation
+	weightedStageCounts := make(map[string]float64)
 
 	for _, doc := range docs {
 		var submission models.Submission
 		doc.DataTo(&submission)
 
 		statusCounts[submission.Status]++
+		provenanceCounts[submissionProvenance(submission)]++
 		stageCounts[submission.GrowthStage]++
 
+		weight := 1.0
+		if submissionProvenance(submission) == "community" {
+			weight = services.Weight(contributorTier(reputations, submission.UserID))
+		}
+		weightedStageCounts[submission.GrowthStage] += weight
+
 		for _, condition := range submission.PlantConditions {
 			conditionCounts[condition]++
 		}
@@ -270,6 +708,8 @@ func (ah *AnalyticsHandler) generateSummaryReport(docs []*firestore.DocumentSnap
 		"status_distribution": statusCounts,
 		"stage_distribution":  stageCounts,
 		"condition_frequency": conditionCounts,
+		"provenance_counts":   provenanceCounts,
+		"weighted_stages":     weightedStageCounts,
 		"generated_at":        time.Now(),
 	}
 }
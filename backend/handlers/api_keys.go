@@ -0,0 +1,196 @@
+package handlers
+
+import (
+	"net/http"
+	"time"
+
+	"rice-monitor-api/models"
+	"rice-monitor-api/services"
+	"rice-monitor-api/utils"
+
+	"cloud.google.com/go/firestore"
+	"github.com/gin-gonic/gin"
+)
+
+type APIKeyHandler struct {
+	firestoreService *services.FirestoreService
+}
+
+func NewAPIKeyHandler(firestoreService *services.FirestoreService) *APIKeyHandler {
+	return &APIKeyHandler{
+		firestoreService: firestoreService,
+	}
+}
+
+// @Summary List API keys
+// @Description List the API keys belonging to the current user. Admins can pass user_id to list another user's keys.
+// @Tags api-keys
+// @Produce  json
+// @Security ApiKeyAuth
+// @Param user_id query string false "User ID (admin only)"
+// @Success 200 {object} models.SuccessResponse
+// @Failure 403 {object} models.ErrorResponse
+// @Failure 500 {object} models.ErrorResponse
+// @Router /api-keys [get]
+func (kh *APIKeyHandler) GetAPIKeys(c *gin.Context) {
+	currentUser, _ := c.Get("user")
+	user := currentUser.(*models.User)
+
+	userID := c.DefaultQuery("user_id", user.ID)
+	if userID != user.ID && !utils.HasPermission(user.Role, utils.PermAPIKeysManage) {
+		c.JSON(http.StatusForbidden, models.ErrorResponse{
+			Error:   "forbidden",
+			Message: "Access denied",
+		})
+		return
+	}
+
+	ctx := kh.firestoreService.Context()
+	docs, err := kh.firestoreService.APIKeys().Where("user_id", "==", userID).Documents(ctx).GetAll()
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, models.ErrorResponse{
+			Error:   "internal_error",
+			Message: "Failed to retrieve API keys",
+		})
+		return
+	}
+
+	keys := []models.APIKey{}
+	for _, doc := range docs {
+		var key models.APIKey
+		doc.DataTo(&key)
+		keys = append(keys, key)
+	}
+
+	c.JSON(http.StatusOK, models.SuccessResponse{
+		Success: true,
+		Data:    keys,
+	})
+}
+
+// @Summary Create an API key
+// @Description Create a new API key for the current user. The key is only returned once.
+// @Tags api-keys
+// @Accept  json
+// @Produce  json
+// @Security ApiKeyAuth
+// @Param key body models.CreateAPIKeyRequest true "API key details"
+// @Success 201 {object} models.SuccessResponse
+// @Failure 400 {object} models.ErrorResponse
+// @Failure 500 {object} models.ErrorResponse
+// @Router /api-keys [post]
+func (kh *APIKeyHandler) CreateAPIKey(c *gin.Context) {
+	var req models.CreateAPIKeyRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, models.ErrorResponse{
+			Error:   "invalid_request",
+			Message: err.Error(),
+		})
+		return
+	}
+
+	currentUser, _ := c.Get("user")
+	user := currentUser.(*models.User)
+
+	rawKey, err := utils.GenerateAPIKey()
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, models.ErrorResponse{
+			Error:   "internal_error",
+			Message: "Failed to generate API key",
+		})
+		return
+	}
+
+	key := models.APIKey{
+		ID:        utils.GenerateID(),
+		UserID:    user.ID,
+		Name:      req.Name,
+		Prefix:    rawKey[:len(utils.APIKeyPrefix)+8],
+		KeyHash:   utils.HashToken(rawKey),
+		CreatedAt: time.Now(),
+	}
+
+	ctx := kh.firestoreService.Context()
+	_, err = kh.firestoreService.APIKeys().Doc(key.ID).Set(ctx, key)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, models.ErrorResponse{
+			Error:   "internal_error",
+			Message: "Failed to create API key",
+		})
+		return
+	}
+
+	c.JSON(http.StatusCreated, models.SuccessResponse{
+		Success: true,
+		Data: models.CreateAPIKeyResponse{
+			APIKey: key,
+			Key:    rawKey,
+		},
+		Message: "API key created successfully. Store it now, it will not be shown again",
+	})
+}
+
+// @Summary Revoke an API key
+// @Description Revoke an API key by its ID
+// @Tags api-keys
+// @Produce  json
+// @Security ApiKeyAuth
+// @Param id path string true "API key ID"
+// @Success 200 {object} models.SuccessResponse
+// @Failure 403 {object} models.ErrorResponse
+// @Failure 404 {object} models.ErrorResponse
+// @Failure 409 {object} models.ErrorResponse
+// @Failure 500 {object} models.ErrorResponse
+// @Router /api-keys/{id} [delete]
+func (kh *APIKeyHandler) RevokeAPIKey(c *gin.Context) {
+	keyID := c.Param("id")
+	currentUser, _ := c.Get("user")
+	user := currentUser.(*models.User)
+
+	ctx := kh.firestoreService.Context()
+	doc, err := kh.firestoreService.APIKeys().Doc(keyID).Get(ctx)
+	if err != nil {
+		c.JSON(http.StatusNotFound, models.ErrorResponse{
+			Error:   "not_found",
+			Message: "API key not found",
+		})
+		return
+	}
+
+	var key models.APIKey
+	doc.DataTo(&key)
+
+	// Check permissions
+	if !utils.HasPermission(user.Role, utils.PermAPIKeysManage) && key.UserID != user.ID {
+		c.JSON(http.StatusForbidden, models.ErrorResponse{
+			Error:   "forbidden",
+			Message: "Access denied",
+		})
+		return
+	}
+
+	if key.Revoked {
+		c.JSON(http.StatusConflict, models.ErrorResponse{
+			Error:   "already_revoked",
+			Message: "API key is already revoked",
+		})
+		return
+	}
+
+	_, err = kh.firestoreService.APIKeys().Doc(keyID).Update(ctx, []firestore.Update{
+		{Path: "revoked", Value: true},
+		{Path: "revoked_at", Value: time.Now()},
+	})
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, models.ErrorResponse{
+			Error:   "internal_error",
+			Message: "Failed to revoke API key",
+		})
+		return
+	}
+
+	c.JSON(http.StatusOK, models.SuccessResponse{
+		Success: true,
+		Message: "API key revoked successfully",
+	})
+}
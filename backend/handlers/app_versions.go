@@ -0,0 +1,106 @@
+package handlers
+
+import (
+	"net/http"
+	"sort"
+	"time"
+
+	"rice-monitor-api/models"
+	"rice-monitor-api/utils"
+
+	"github.com/gin-gonic/gin"
+)
+
+// @Summary App version statistics
+// @Description Review outcomes of submissions grouped by the app version that made them, to spot
+// @Description data issues tied to a release. Covers submissions created since the given time (30 days by default).
+// @Tags admin
+// @Produce  json
+// @Security ApiKeyAuth
+// @Param since query string false "RFC 3339 time or YYYY-MM-DD date"
+// @Success 200 {object} models.SuccessResponse
+// @Failure 400 {object} models.ErrorResponse
+// @Failure 500 {object} models.ErrorResponse
+// @Router /admin/app-versions [get]
+func (sh *SubmissionHandler) GetAppVersionStats(c *gin.Context) {
+	currentUser, _ := c.Get("user")
+	user := currentUser.(*models.User)
+
+	since := time.Now().AddDate(0, 0, -30)
+	if value := c.Query("since"); value != "" {
+		t, err := parseEventTime(value, utils.UserLocation(user))
+		if err != nil {
+			c.JSON(http.StatusBadRequest, models.ErrorResponse{
+				Error:   "invalid_request",
+				Message: "since must be an RFC 3339 time or a YYYY-MM-DD date",
+			})
+			return
+		}
+		since = t
+	}
+
+	query := orgScope(sh.firestoreService.Submissions().Query, user)
+
+	ctx := sh.firestoreService.Context()
+	docs, err := query.Where("created_at", ">=", since).Documents(ctx).GetAll()
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, models.ErrorResponse{
+			Error:   "internal_error",
+			Message: "Failed to retrieve submissions",
+		})
+		return
+	}
+
+	byVersion := map[string]*models.AppVersionStats{}
+	for _, doc := range docs {
+		var submission models.Submission
+		doc.DataTo(&submission)
+
+		version, platform := "unknown", ""
+		if submission.Device != nil {
+			if submission.Device.AppVersion != "" {
+				version = submission.Device.AppVersion
+			}
+			platform = submission.Device.OS
+		}
+
+		stats, ok := byVersion[version]
+		if !ok {
+			stats = &models.AppVersionStats{AppVersion: version, Platforms: []string{}, FirstSeen: submission.CreatedAt}
+			byVersion[version] = stats
+		}
+		stats.Submissions++
+		switch submission.Status {
+		case "approved":
+			stats.Approved++
+		case "rejected":
+			stats.Rejected++
+		}
+		if platform != "" && !utils.Contains(stats.Platforms, platform) {
+			stats.Platforms = append(stats.Platforms, platform)
+		}
+		if submission.CreatedAt.Before(stats.FirstSeen) {
+			stats.FirstSeen = submission.CreatedAt
+		}
+		if submission.CreatedAt.After(stats.LastSeen) {
+			stats.LastSeen = submission.CreatedAt
+		}
+	}
+
+	versions := make([]models.AppVersionStats, 0, len(byVersion))
+	for _, stats := range byVersion {
+		if reviewed := stats.Approved + stats.Rejected; reviewed > 0 {
+			stats.RejectionRate = float64(stats.Rejected) / float64(reviewed)
+		}
+		sort.Strings(stats.Platforms)
+		versions = append(versions, *stats)
+	}
+	sort.Slice(versions, func(i, j int) bool {
+		return versions[i].LastSeen.After(versions[j].LastSeen)
+	})
+
+	c.JSON(http.StatusOK, models.SuccessResponse{
+		Success: true,
+		Data:    versions,
+	})
+}
@@ -1,9 +1,15 @@
 package handlers
 
 import (
+	"context"
+	"errors"
 	"fmt"
 	"log"
+	"math"
 	"net/http"
+	"os"
+	"strconv"
+	"strings"
 	"time"
 
 	"rice-monitor-api/models"
@@ -12,32 +18,766 @@ import (
 
 	"cloud.google.com/go/firestore"
 	"github.com/gin-gonic/gin"
-	"google.golang.org/api/idtoken"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+)
+
+// errInvalidToken is returned from transactions when a one-time token is used or expired
+var errInvalidToken = errors.New("invalid or expired token")
+
+// magicLinkTTL is how long an emailed login link stays valid
+const magicLinkTTL = 15 * time.Minute
+
+var (
+	// errInviteRequired is returned when a login would create an account without an invite code
+	errInviteRequired = errors.New("an invite is required to create an account")
+	// errInvalidInvite is returned when an invite code is unknown, used, revoked,
+	// expired or issued to another email address
+	errInvalidInvite = errors.New("invalid invite")
 )
 
 type AuthHandler struct {
 	firestoreService *services.FirestoreService
+	mailerService    *services.MailerService
+	oauthProviders   map[string]services.OAuthProvider
+	maxFailedLogins  int           // wrong passwords per account allowed within loginLockout
+	loginLockout     time.Duration // how long an account stays locked after too many wrong passwords
+}
+
+func NewAuthHandler(firestoreService *services.FirestoreService, mailerService *services.MailerService, oauthProviders map[string]services.OAuthProvider) *AuthHandler {
+	maxFailedLogins, err := strconv.Atoi(os.Getenv("AUTH_MAX_FAILED_LOGINS"))
+	if err != nil || maxFailedLogins <= 0 {
+		maxFailedLogins = 5
+	}
+	minutes, err := strconv.Atoi(os.Getenv("AUTH_LOCKOUT_MINUTES"))
+	if err != nil || minutes <= 0 {
+		minutes = 15
+	}
+
+	return &AuthHandler{
+		firestoreService: firestoreService,
+		mailerService:    mailerService,
+		oauthProviders:   oauthProviders,
+		maxFailedLogins:  maxFailedLogins,
+		loginLockout:     time.Duration(minutes) * time.Minute,
+	}
+}
+
+// @Summary Google Login
+// @Description Authenticate with Google and get JWT tokens. New accounts are only created with a valid invite_code.
+// @Tags auth
+// @Accept  json
+// @Produce  json
+// @Param   token  body  models.GoogleTokenRequest  true  "Google Token"
+// @Success 200 {object} models.AuthResponse
+// @Failure 400 {object} models.ErrorResponse
+// @Failure 401 {object} models.ErrorResponse
+// @Failure 403 {object} models.ErrorResponse
+// @Failure 500 {object} models.ErrorResponse
+// @Router /auth/google [post]
+func (ah *AuthHandler) GoogleLogin(c *gin.Context) {
+	var req models.GoogleTokenRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, models.ErrorResponse{
+			Error:   "invalid_request",
+			Message: err.Error(),
+		})
+		return
+	}
+
+	ah.oauthLogin(c, ah.oauthProviders["google"], models.OAuthLoginRequest{Token: req.Token, InviteCode: req.InviteCode})
+}
+
+// @Summary Social login
+// @Description Authenticate with a social login provider (google, apple, facebook) and get JWT tokens.
+// @Description Google and Apple expect an ID token, Facebook a user access token. Accounts are matched by email.
+// @Description New accounts are only created with a valid invite_code.
+// @Tags auth
+// @Accept  json
+// @Produce  json
+// @Param   provider  path  string  true  "Provider name"
+// @Param   token  body  models.OAuthLoginRequest  true  "Provider token"
+// @Success 200 {object} models.AuthResponse
+// @Failure 400 {object} models.ErrorResponse
+// @Failure 401 {object} models.ErrorResponse
+// @Failure 403 {object} models.ErrorResponse
+// @Failure 404 {object} models.ErrorResponse
+// @Failure 500 {object} models.ErrorResponse
+// @Router /auth/oauth/{provider} [post]
+func (ah *AuthHandler) OAuthLogin(c *gin.Context) {
+	provider, ok := ah.oauthProviders[c.Param("provider")]
+	if !ok {
+		c.JSON(http.StatusNotFound, models.ErrorResponse{
+			Error:   "unknown_provider",
+			Message: "Login provider is not supported or not configured",
+		})
+		return
+	}
+
+	var req models.OAuthLoginRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, models.ErrorResponse{
+			Error:   "invalid_request",
+			Message: err.Error(),
+		})
+		return
+	}
+
+	ah.oauthLogin(c, provider, req)
+}
+
+// oauthLogin validates the provider token, maps it to a user by email and issues JWT tokens
+func (ah *AuthHandler) oauthLogin(c *gin.Context, provider services.OAuthProvider, req models.OAuthLoginRequest) {
+	ctx := ah.firestoreService.Context()
+	event := models.AuthEvent{Type: "login", Method: provider.Name()}
+
+	userInfo, err := provider.Verify(ctx, req.Token)
+	if errors.Is(err, services.ErrEmailNotVerified) {
+		ah.authFailed(c, event, http.StatusUnauthorized, models.ErrorResponse{
+			Error:   "email_not_verified",
+			Message: "The login provider did not share a verified email address",
+		})
+		return
+	}
+	if errors.Is(err, services.ErrDomainNotAllowed) {
+		ah.authFailed(c, event, http.StatusForbidden, models.ErrorResponse{
+			Error:   "domain_not_allowed",
+			Message: "This deployment only accepts Google accounts from its organization's email domains. Log in with your organization account",
+		})
+		return
+	}
+	if err != nil {
+		log.Printf("%s token validation failed: %v", provider.Name(), err)
+		ah.authFailed(c, event, http.StatusUnauthorized, models.ErrorResponse{
+			Error:   "invalid_token",
+			Message: fmt.Sprintf("Invalid %s token", provider.Name()),
+		})
+		return
+	}
+	if userInfo.Name == "" {
+		userInfo.Name = req.Name
+	}
+	event.Email = userInfo.Email
+
+	// Get or create user
+	user, err := ah.getOrCreateUser(*userInfo, req.InviteCode)
+	if err == errInviteRequired {
+		ah.authFailed(c, event, http.StatusForbidden, models.ErrorResponse{
+			Error:   "invite_required",
+			Message: "No account exists for this email. An invite code is required to create one",
+		})
+		return
+	}
+	if err == errInvalidInvite {
+		ah.authFailed(c, event, http.StatusForbidden, models.ErrorResponse{
+			Error:   "invalid_invite",
+			Message: "Invite code is invalid, expired or issued to another email address",
+		})
+		return
+	}
+	if err != nil {
+		fmt.Println(err)
+		ah.authFailed(c, event, http.StatusInternalServerError, models.ErrorResponse{
+			Error:   "internal_error",
+			Message: "Failed to process user",
+		})
+		return
+	}
+	event.UserID = user.ID
+
+	if utils.AccountSuspended(user) {
+		ah.authFailed(c, event, http.StatusForbidden, models.ErrorResponse{
+			Error:   "account_suspended",
+			Message: utils.SuspensionMessage(user),
+		})
+		return
+	}
+
+	// Start a session and generate JWT tokens for it
+	session, err := ah.startSession(c, user)
+	if err != nil {
+		ah.authFailed(c, event, http.StatusInternalServerError, models.ErrorResponse{
+			Error:   "internal_error",
+			Message: "Failed to start session",
+		})
+		return
+	}
+
+	accessToken, refreshToken, err := utils.GenerateTokens(user, session.ID)
+	if err != nil {
+		ah.authFailed(c, event, http.StatusInternalServerError, models.ErrorResponse{
+			Error:   "internal_error",
+			Message: "Failed to generate tokens",
+		})
+		return
+	}
+
+	// Update last login
+	user.LastLoginAt = time.Now()
+	ah.updateUserLastLogin(user.ID)
+
+	event.Success = true
+	event.SessionID = session.ID
+	ah.recordAuthEvent(c, event)
+
+	sendAuthResponse(c, models.AuthResponse{
+		User:         *user,
+		AccessToken:  accessToken,
+		RefreshToken: refreshToken,
+		ExpiresIn:    int64(utils.AccessTokenTTL.Seconds()),
+	})
 }
 
-func NewAuthHandler(firestoreService *services.FirestoreService) *AuthHandler {
-	return &AuthHandler{
-		firestoreService: firestoreService,
+// @Summary Signup
+// @Description Create an account with email and password using an invite code. A verification link is emailed and must be used before logging in.
+// @Tags auth
+// @Accept  json
+// @Produce  json
+// @Param   user  body  models.SignupRequest  true  "Signup details"
+// @Success 201 {object} models.SuccessResponse
+// @Failure 400 {object} models.ErrorResponse
+// @Failure 403 {object} models.ErrorResponse
+// @Failure 409 {object} models.ErrorResponse
+// @Failure 500 {object} models.ErrorResponse
+// @Router /auth/signup [post]
+func (ah *AuthHandler) Signup(c *gin.Context) {
+	var req models.SignupRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, models.ErrorResponse{
+			Error:   "invalid_request",
+			Message: err.Error(),
+		})
+		return
+	}
+
+	email := normalizeEmail(req.Email)
+
+	// Accounts created before email reservations existed are only found by query
+	existing, err := ah.getUserByEmail(email)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, models.ErrorResponse{
+			Error:   "internal_error",
+			Message: "Failed to process user",
+		})
+		return
+	}
+	if existing != nil {
+		c.JSON(http.StatusConflict, models.ErrorResponse{
+			Error:   "email_taken",
+			Message: "An account with this email already exists",
+		})
+		return
+	}
+
+	invite, err := ah.getInvite(req.InviteCode, email)
+	if err == errInvalidInvite {
+		c.JSON(http.StatusForbidden, models.ErrorResponse{
+			Error:   "invalid_invite",
+			Message: "Invite code is invalid, expired or issued to another email address",
+		})
+		return
+	}
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, models.ErrorResponse{
+			Error:   "internal_error",
+			Message: "Failed to check invite",
+		})
+		return
+	}
+
+	passwordHash, err := utils.HashPassword(req.Password)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, models.ErrorResponse{
+			Error:   "internal_error",
+			Message: "Failed to process password",
+		})
+		return
+	}
+
+	user := &models.User{
+		ID:           utils.GenerateID(),
+		Email:        email,
+		Name:         req.Name,
+		Role:         invite.Role,
+		FieldIDs:     invite.FieldIDs,
+		PasswordHash: passwordHash,
+		Pending:      needsApproval(invite.Role),
+		CreatedAt:    time.Now(),
+		UpdatedAt:    time.Now(),
+	}
+
+	err = ah.createUser(user, invite.ID)
+	if err == errInvalidInvite {
+		// Another account used the invite first
+		c.JSON(http.StatusForbidden, models.ErrorResponse{
+			Error:   "invalid_invite",
+			Message: "Invite code is invalid, expired or issued to another email address",
+		})
+		return
+	}
+	if status.Code(err) == codes.AlreadyExists {
+		c.JSON(http.StatusConflict, models.ErrorResponse{
+			Error:   "email_taken",
+			Message: "An account with this email already exists",
+		})
+		return
+	}
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, models.ErrorResponse{
+			Error:   "internal_error",
+			Message: "Failed to create user",
+		})
+		return
+	}
+
+	if err := ah.sendVerificationEmail(user); err != nil {
+		log.Printf("Failed to send verification email: %v", err)
+	}
+
+	c.JSON(http.StatusCreated, models.SuccessResponse{
+		Success: true,
+		Data:    user,
+		Message: "Account created. Check your email to verify your address before logging in",
+	})
+}
+
+// @Summary Verify Email
+// @Description Verify an email address using the token from the verification link
+// @Tags auth
+// @Accept  json
+// @Produce  json
+// @Param   token  body  models.VerifyEmailRequest  true  "Verification token"
+// @Success 200 {object} models.SuccessResponse
+// @Failure 400 {object} models.ErrorResponse
+// @Failure 500 {object} models.ErrorResponse
+// @Router /auth/verify-email [post]
+func (ah *AuthHandler) VerifyEmail(c *gin.Context) {
+	var req models.VerifyEmailRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, models.ErrorResponse{
+			Error:   "invalid_request",
+			Message: err.Error(),
+		})
+		return
+	}
+
+	ctx := ah.firestoreService.Context()
+	docs, err := ah.firestoreService.EmailVerifications().
+		Where("token_hash", "==", utils.HashToken(req.Token)).
+		Limit(1).
+		Documents(ctx).GetAll()
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, models.ErrorResponse{
+			Error:   "internal_error",
+			Message: "Failed to verify token",
+		})
+		return
+	}
+	if len(docs) == 0 {
+		c.JSON(http.StatusBadRequest, models.ErrorResponse{
+			Error:   "invalid_token",
+			Message: "Verification token is invalid or has expired",
+		})
+		return
+	}
+
+	err = ah.firestoreService.Client.RunTransaction(ctx, func(ctx context.Context, tx *firestore.Transaction) error {
+		doc, err := tx.Get(docs[0].Ref)
+		if err != nil {
+			return err
+		}
+
+		var verification models.EmailVerification
+		doc.DataTo(&verification)
+		if verification.Used || time.Now().After(verification.ExpiresAt) {
+			return errInvalidToken
+		}
+
+		if err := tx.Update(docs[0].Ref, []firestore.Update{{Path: "used", Value: true}}); err != nil {
+			return err
+		}
+		return tx.Update(ah.firestoreService.Users().Doc(verification.UserID), []firestore.Update{
+			{Path: "email_verified", Value: true},
+			{Path: "updated_at", Value: time.Now()},
+		})
+	})
+	if err == errInvalidToken {
+		c.JSON(http.StatusBadRequest, models.ErrorResponse{
+			Error:   "invalid_token",
+			Message: "Verification token is invalid or has expired",
+		})
+		return
+	}
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, models.ErrorResponse{
+			Error:   "internal_error",
+			Message: "Failed to verify email",
+		})
+		return
+	}
+
+	c.JSON(http.StatusOK, models.SuccessResponse{
+		Success: true,
+		Message: "Email verified successfully",
+	})
+}
+
+// @Summary Login
+// @Description Authenticate with email and password and get JWT tokens
+// @Tags auth
+// @Accept  json
+// @Produce  json
+// @Param   credentials  body  models.LoginRequest  true  "Login credentials"
+// @Success 200 {object} models.AuthResponse
+// @Failure 400 {object} models.ErrorResponse
+// @Failure 401 {object} models.ErrorResponse
+// @Failure 403 {object} models.ErrorResponse
+// @Failure 500 {object} models.ErrorResponse
+// @Router /auth/login [post]
+func (ah *AuthHandler) Login(c *gin.Context) {
+	var req models.LoginRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, models.ErrorResponse{
+			Error:   "invalid_request",
+			Message: err.Error(),
+		})
+		return
+	}
+
+	event := models.AuthEvent{Type: "login", Method: "password", Email: normalizeEmail(req.Email)}
+
+	if retryAfter := ah.accountLockedOut(event.Email); retryAfter > 0 {
+		c.Header("Retry-After", strconv.Itoa(int(math.Ceil(retryAfter.Seconds()))))
+		ah.authFailed(c, event, http.StatusTooManyRequests, models.ErrorResponse{
+			Error:   "account_locked",
+			Message: "Too many failed logins, try again later",
+		})
+		return
+	}
+
+	user, err := ah.getUserByEmail(event.Email)
+	if err != nil {
+		ah.authFailed(c, event, http.StatusInternalServerError, models.ErrorResponse{
+			Error:   "internal_error",
+			Message: "Failed to process user",
+		})
+		return
+	}
+	if user != nil {
+		event.UserID = user.ID
+	}
+
+	// Google-only accounts have no password hash and cannot log in this way
+	if user == nil || user.PasswordHash == "" || !utils.CheckPassword(user.PasswordHash, req.Password) {
+		ah.authFailed(c, event, http.StatusUnauthorized, models.ErrorResponse{
+			Error:   "invalid_credentials",
+			Message: "Invalid email or password",
+		})
+		return
+	}
+
+	if !user.EmailVerified {
+		ah.authFailed(c, event, http.StatusForbidden, models.ErrorResponse{
+			Error:   "email_not_verified",
+			Message: "Verify your email address before logging in",
+		})
+		return
+	}
+
+	if utils.AccountSuspended(user) {
+		ah.authFailed(c, event, http.StatusForbidden, models.ErrorResponse{
+			Error:   "account_suspended",
+			Message: utils.SuspensionMessage(user),
+		})
+		return
+	}
+
+	session, err := ah.startSession(c, user)
+	if err != nil {
+		ah.authFailed(c, event, http.StatusInternalServerError, models.ErrorResponse{
+			Error:   "internal_error",
+			Message: "Failed to start session",
+		})
+		return
+	}
+
+	accessToken, refreshToken, err := utils.GenerateTokens(user, session.ID)
+	if err != nil {
+		ah.authFailed(c, event, http.StatusInternalServerError, models.ErrorResponse{
+			Error:   "internal_error",
+			Message: "Failed to generate tokens",
+		})
+		return
+	}
+
+	user.LastLoginAt = time.Now()
+	ah.updateUserLastLogin(user.ID)
+
+	event.Success = true
+	event.SessionID = session.ID
+	ah.recordAuthEvent(c, event)
+
+	sendAuthResponse(c, models.AuthResponse{
+		User:         *user,
+		AccessToken:  accessToken,
+		RefreshToken: refreshToken,
+		ExpiresIn:    int64(utils.AccessTokenTTL.Seconds()),
+	})
+}
+
+// @Summary Forgot Password
+// @Description Send a password reset link to the given email if an account exists
+// @Tags auth
+// @Accept  json
+// @Produce  json
+// @Param   email  body  models.ForgotPasswordRequest  true  "Account email"
+// @Success 200 {object} models.SuccessResponse
+// @Failure 400 {object} models.ErrorResponse
+// @Router /auth/password/forgot [post]
+func (ah *AuthHandler) ForgotPassword(c *gin.Context) {
+	var req models.ForgotPasswordRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, models.ErrorResponse{
+			Error:   "invalid_request",
+			Message: err.Error(),
+		})
+		return
+	}
+
+	// Always respond the same way so the endpoint cannot be used to discover accounts
+	response := models.SuccessResponse{
+		Success: true,
+		Message: "If an account exists for this email, a reset link has been sent",
+	}
+
+	user, err := ah.getUserByEmail(normalizeEmail(req.Email))
+	if err != nil || user == nil {
+		if err != nil {
+			log.Printf("Failed to look up user for password reset: %v", err)
+		}
+		c.JSON(http.StatusOK, response)
+		return
+	}
+
+	token, err := utils.GenerateSecureToken(32)
+	if err != nil {
+		log.Printf("Failed to generate password reset token: %v", err)
+		c.JSON(http.StatusOK, response)
+		return
+	}
+
+	reset := models.PasswordReset{
+		ID:        utils.GenerateID(),
+		UserID:    user.ID,
+		TokenHash: utils.HashToken(token),
+		ExpiresAt: time.Now().Add(time.Hour),
+		CreatedAt: time.Now(),
+	}
+
+	ctx := ah.firestoreService.Context()
+	if _, err := ah.firestoreService.PasswordResets().Doc(reset.ID).Set(ctx, reset); err != nil {
+		log.Printf("Failed to store password reset: %v", err)
+		c.JSON(http.StatusOK, response)
+		return
+	}
+
+	resetURL := fmt.Sprintf("%s/reset-password?token=%s",
+		utils.GetEnvOrDefault("FRONTEND_URL", "http://localhost:3000"), token)
+	body := fmt.Sprintf("Hello %s,\n\nUse the link below to reset your Rice Monitor password. It expires in one hour.\n\n%s\n\nIf you did not request this, you can ignore this email.\n",
+		user.Name, resetURL)
+	if err := ah.mailerService.Send([]string{user.Email}, "Reset your Rice Monitor password", body); err != nil {
+		log.Printf("Failed to send password reset email: %v", err)
+	}
+
+	c.JSON(http.StatusOK, response)
+}
+
+// @Summary Reset Password
+// @Description Set a new password using a reset token. Existing sessions and other reset links are invalidated.
+// @Tags auth
+// @Accept  json
+// @Produce  json
+// @Param   reset  body  models.ResetPasswordRequest  true  "Reset token and new password"
+// @Success 200 {object} models.SuccessResponse
+// @Failure 400 {object} models.ErrorResponse
+// @Failure 500 {object} models.ErrorResponse
+// @Router /auth/password/reset [post]
+func (ah *AuthHandler) ResetPassword(c *gin.Context) {
+	var req models.ResetPasswordRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, models.ErrorResponse{
+			Error:   "invalid_request",
+			Message: err.Error(),
+		})
+		return
+	}
+
+	ctx := ah.firestoreService.Context()
+	docs, err := ah.firestoreService.PasswordResets().
+		Where("token_hash", "==", utils.HashToken(req.Token)).
+		Limit(1).
+		Documents(ctx).GetAll()
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, models.ErrorResponse{
+			Error:   "internal_error",
+			Message: "Failed to verify reset token",
+		})
+		return
+	}
+	if len(docs) == 0 {
+		c.JSON(http.StatusBadRequest, models.ErrorResponse{
+			Error:   "invalid_token",
+			Message: "Reset token is invalid or has expired",
+		})
+		return
+	}
+
+	passwordHash, err := utils.HashPassword(req.Password)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, models.ErrorResponse{
+			Error:   "internal_error",
+			Message: "Failed to process password",
+		})
+		return
+	}
+
+	err = ah.firestoreService.Client.RunTransaction(ctx, func(ctx context.Context, tx *firestore.Transaction) error {
+		doc, err := tx.Get(docs[0].Ref)
+		if err != nil {
+			return err
+		}
+
+		var reset models.PasswordReset
+		doc.DataTo(&reset)
+		if reset.Used || time.Now().After(reset.ExpiresAt) {
+			return errInvalidToken
+		}
+
+		// Every pending link for the user is spent, not just this one
+		pending, err := tx.Documents(ah.firestoreService.PasswordResets().
+			Where("user_id", "==", reset.UserID).
+			Where("used", "==", false)).GetAll()
+		if err != nil {
+			return err
+		}
+
+		for _, p := range pending {
+			if err := tx.Update(p.Ref, []firestore.Update{{Path: "used", Value: true}}); err != nil {
+				return err
+			}
+		}
+
+		// Receiving the reset email proves ownership of the address
+		return tx.Update(ah.firestoreService.Users().Doc(reset.UserID), []firestore.Update{
+			{Path: "password_hash", Value: passwordHash},
+			{Path: "email_verified", Value: true},
+			{Path: "tokens_valid_after", Value: time.Now()},
+			{Path: "updated_at", Value: time.Now()},
+		})
+	})
+	if err == errInvalidToken {
+		c.JSON(http.StatusBadRequest, models.ErrorResponse{
+			Error:   "invalid_token",
+			Message: "Reset token is invalid or has expired",
+		})
+		return
+	}
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, models.ErrorResponse{
+			Error:   "internal_error",
+			Message: "Failed to update password",
+		})
+		return
+	}
+
+	c.JSON(http.StatusOK, models.SuccessResponse{
+		Success: true,
+		Message: "Password reset successfully",
+	})
+}
+
+// @Summary Request a magic link
+// @Description Email a single-use login link to the given address if an account exists.
+// @Description The link opens the app, which exchanges it at /auth/magic-link/verify.
+// @Tags auth
+// @Accept  json
+// @Produce  json
+// @Param   email  body  models.MagicLinkRequest  true  "Account email"
+// @Success 200 {object} models.SuccessResponse
+// @Failure 400 {object} models.ErrorResponse
+// @Router /auth/magic-link [post]
+func (ah *AuthHandler) RequestMagicLink(c *gin.Context) {
+	var req models.MagicLinkRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, models.ErrorResponse{
+			Error:   "invalid_request",
+			Message: err.Error(),
+		})
+		return
+	}
+
+	// Always respond the same way so the endpoint cannot be used to discover accounts
+	response := models.SuccessResponse{
+		Success: true,
+		Message: "If an account exists for this email, a login link has been sent",
+	}
+
+	user, err := ah.getUserByEmail(normalizeEmail(req.Email))
+	if err != nil || user == nil || utils.AccountSuspended(user) {
+		if err != nil {
+			log.Printf("Failed to look up user for magic link: %v", err)
+		}
+		c.JSON(http.StatusOK, response)
+		return
+	}
+
+	token, err := utils.GenerateSecureToken(32)
+	if err != nil {
+		log.Printf("Failed to generate magic link token: %v", err)
+		c.JSON(http.StatusOK, response)
+		return
 	}
+
+	link := models.MagicLink{
+		ID:        utils.GenerateID(),
+		UserID:    user.ID,
+		TokenHash: utils.HashToken(token),
+		ExpiresAt: time.Now().Add(magicLinkTTL),
+		CreatedAt: time.Now(),
+	}
+
+	ctx := ah.firestoreService.Context()
+	if _, err := ah.firestoreService.MagicLinks().Doc(link.ID).Set(ctx, link); err != nil {
+		log.Printf("Failed to store magic link: %v", err)
+		c.JSON(http.StatusOK, response)
+		return
+	}
+
+	loginURL := fmt.Sprintf("%s/?magic=%s",
+		utils.GetEnvOrDefault("FRONTEND_URL", "http://localhost:3000"), token)
+	body := fmt.Sprintf("Hello %s,\n\nUse the link below to log in to Rice Monitor. It can be used once and expires in %d minutes.\n\n%s\n\nIf you did not request this, you can ignore this email.\n",
+		user.Name, int(magicLinkTTL.Minutes()), loginURL)
+	if err := ah.mailerService.Send([]string{user.Email}, "Your Rice Monitor login link", body); err != nil {
+		log.Printf("Failed to send magic link email: %v", err)
+	}
+
+	c.JSON(http.StatusOK, response)
 }
 
-// @Summary Google Login
-// @Description Authenticate with Google and get JWT tokens
+// @Summary Log in with a magic link
+// @Description Exchange a magic link token for access and refresh tokens. Each link works once.
 // @Tags auth
 // @Accept  json
 // @Produce  json
-// @Param   token  body  models.GoogleTokenRequest  true  "Google Token"
+// @Param   token  body  models.VerifyMagicLinkRequest  true  "Magic link token"
 // @Success 200 {object} models.AuthResponse
 // @Failure 400 {object} models.ErrorResponse
 // @Failure 401 {object} models.ErrorResponse
+// @Failure 403 {object} models.ErrorResponse
 // @Failure 500 {object} models.ErrorResponse
-// @Router /auth/google [post]
-func (ah *AuthHandler) GoogleLogin(c *gin.Context) {
-	var req models.GoogleTokenRequest
+// @Router /auth/magic-link/verify [post]
+func (ah *AuthHandler) VerifyMagicLink(c *gin.Context) {
+	var req models.VerifyMagicLinkRequest
 	if err := c.ShouldBindJSON(&req); err != nil {
 		c.JSON(http.StatusBadRequest, models.ErrorResponse{
 			Error:   "invalid_request",
@@ -46,60 +786,113 @@ func (ah *AuthHandler) GoogleLogin(c *gin.Context) {
 		return
 	}
 
-	// Verify Google token
-	ctx := ah.firestoreService.Context()
+	event := models.AuthEvent{Type: "login", Method: "magic_link"}
 
-	// Validate the ID token - replace "YOUR_GOOGLE_CLIENT_ID" with your actual client ID or fetch from config/env
-	payload, err := idtoken.Validate(ctx, req.Token, utils.GetEnvOrDefault("GOOGLE_CLIENT_ID", ""))
+	ctx := ah.firestoreService.Context()
+	docs, err := ah.firestoreService.MagicLinks().
+		Where("token_hash", "==", utils.HashToken(req.Token)).
+		Limit(1).
+		Documents(ctx).GetAll()
 	if err != nil {
-		c.JSON(http.StatusUnauthorized, models.ErrorResponse{
+		ah.authFailed(c, event, http.StatusInternalServerError, models.ErrorResponse{
+			Error:   "internal_error",
+			Message: "Failed to verify login link",
+		})
+		return
+	}
+	if len(docs) == 0 {
+		ah.authFailed(c, event, http.StatusUnauthorized, models.ErrorResponse{
 			Error:   "invalid_token",
-			Message: "Invalid Google ID token",
+			Message: "Login link is invalid or has expired",
 		})
 		return
 	}
 
-	email, _ := payload.Claims["email"].(string)
-	name, _ := payload.Claims["name"].(string)
-	picture, _ := payload.Claims["picture"].(string)
+	var link models.MagicLink
+	err = ah.firestoreService.Client.RunTransaction(ctx, func(ctx context.Context, tx *firestore.Transaction) error {
+		doc, err := tx.Get(docs[0].Ref)
+		if err != nil {
+			return err
+		}
+
+		doc.DataTo(&link)
+		if link.Used || time.Now().After(link.ExpiresAt) {
+			return errInvalidToken
+		}
 
-	// Construct a simplified tokenInfo-like struct or map to pass to your getOrCreateUser
-	tokenInfo := models.GoogleUserInfo{
-		Email:   email,
-		Name:    name,
-		Picture: picture,
+		if err := tx.Update(doc.Ref, []firestore.Update{{Path: "used", Value: true}}); err != nil {
+			return err
+		}
+		// Receiving the link proves ownership of the address
+		return tx.Update(ah.firestoreService.Users().Doc(link.UserID), []firestore.Update{
+			{Path: "email_verified", Value: true},
+		})
+	})
+	event.UserID = link.UserID
+	if err == errInvalidToken {
+		ah.authFailed(c, event, http.StatusUnauthorized, models.ErrorResponse{
+			Error:   "invalid_token",
+			Message: "Login link is invalid or has expired",
+		})
+		return
+	}
+	if err != nil {
+		ah.authFailed(c, event, http.StatusInternalServerError, models.ErrorResponse{
+			Error:   "internal_error",
+			Message: "Failed to verify login link",
+		})
+		return
 	}
 
-	// Get or create user
-	user, err := ah.getOrCreateUser(tokenInfo)
+	user, err := ah.getUserByID(link.UserID)
 	if err != nil {
-		fmt.Println(err)
-		c.JSON(http.StatusInternalServerError, models.ErrorResponse{
+		ah.authFailed(c, event, http.StatusInternalServerError, models.ErrorResponse{
 			Error:   "internal_error",
 			Message: "Failed to process user",
 		})
 		return
 	}
+	event.Email = user.Email
+
+	if utils.AccountSuspended(user) {
+		ah.authFailed(c, event, http.StatusForbidden, models.ErrorResponse{
+			Error:   "account_suspended",
+			Message: utils.SuspensionMessage(user),
+		})
+		return
+	}
 
-	// Generate JWT tokens
-	accessToken, refreshToken, err := utils.GenerateTokens(user)
+	session, err := ah.startSession(c, user)
 	if err != nil {
-		c.JSON(http.StatusInternalServerError, models.ErrorResponse{
+		ah.authFailed(c, event, http.StatusInternalServerError, models.ErrorResponse{
+			Error:   "internal_error",
+			Message: "Failed to start session",
+		})
+		return
+	}
+
+	accessToken, refreshToken, err := utils.GenerateTokens(user, session.ID)
+	if err != nil {
+		ah.authFailed(c, event, http.StatusInternalServerError, models.ErrorResponse{
 			Error:   "internal_error",
 			Message: "Failed to generate tokens",
 		})
 		return
 	}
 
-	// Update last login
+	user.EmailVerified = true
 	user.LastLoginAt = time.Now()
 	ah.updateUserLastLogin(user.ID)
 
-	c.JSON(http.StatusOK, models.AuthResponse{
+	event.Success = true
+	event.SessionID = session.ID
+	ah.recordAuthEvent(c, event)
+
+	sendAuthResponse(c, models.AuthResponse{
 		User:         *user,
 		AccessToken:  accessToken,
 		RefreshToken: refreshToken,
-		ExpiresIn:    3600, // 1 hour
+		ExpiresIn:    int64(utils.AccessTokenTTL.Seconds()),
 	})
 }
 
@@ -116,7 +909,9 @@ func (ah *AuthHandler) GoogleLogin(c *gin.Context) {
 // @Router /auth/refresh [post]
 func (ah *AuthHandler) RefreshToken(c *gin.Context) {
 	var req models.RefreshTokenRequest
-	if err := c.ShouldBindJSON(&req); err != nil {
+	if cookie, err := c.Cookie(utils.RefreshTokenCookie); err == nil && utils.CookieSessionMode() {
+		req.RefreshToken = cookie
+	} else if err := c.ShouldBindJSON(&req); err != nil {
 		c.JSON(http.StatusBadRequest, models.ErrorResponse{
 			Error:   "invalid_request",
 			Message: err.Error(),
@@ -124,58 +919,270 @@ func (ah *AuthHandler) RefreshToken(c *gin.Context) {
 		return
 	}
 
+	event := models.AuthEvent{Type: "refresh", Method: "refresh_token"}
+
 	// Validate refresh token
-	claims, err := utils.ValidateToken(req.RefreshToken)
+	claims, err := utils.ValidateRefreshToken(req.RefreshToken)
 	if err != nil {
-		c.JSON(http.StatusUnauthorized, models.ErrorResponse{
+		ah.authFailed(c, event, http.StatusUnauthorized, models.ErrorResponse{
 			Error:   "invalid_token",
 			Message: "Invalid refresh token",
 		})
 		return
 	}
+	event.UserID = claims.UserID
+	event.SessionID = claims.SessionID
 
 	// Get user
 	user, err := ah.getUserByID(claims.UserID)
 	if err != nil {
-		c.JSON(http.StatusNotFound, models.ErrorResponse{
+		ah.authFailed(c, event, http.StatusNotFound, models.ErrorResponse{
 			Error:   "user_not_found",
 			Message: "User not found",
 		})
 		return
 	}
+	event.Email = user.Email
+
+	if utils.TokenRevoked(claims, user) {
+		ah.authFailed(c, event, http.StatusUnauthorized, models.ErrorResponse{
+			Error:   "invalid_token",
+			Message: "Refresh token has been revoked",
+		})
+		return
+	}
+
+	if utils.AccountSuspended(user) {
+		ah.authFailed(c, event, http.StatusForbidden, models.ErrorResponse{
+			Error:   "account_suspended",
+			Message: utils.SuspensionMessage(user),
+		})
+		return
+	}
+
+	// Tokens issued before sessions existed get a session on their first refresh
+	sessionID := claims.SessionID
+	if sessionID == "" {
+		session, err := ah.startSession(c, user)
+		if err != nil {
+			ah.authFailed(c, event, http.StatusInternalServerError, models.ErrorResponse{
+				Error:   "internal_error",
+				Message: "Failed to start session",
+			})
+			return
+		}
+		sessionID = session.ID
+		event.SessionID = sessionID
+	} else if err := ah.extendSession(sessionID, user.ID); err != nil {
+		ah.authFailed(c, event, http.StatusUnauthorized, models.ErrorResponse{
+			Error:   "invalid_token",
+			Message: "Session has been revoked",
+		})
+		return
+	}
 
 	// Generate new tokens
-	accessToken, refreshToken, err := utils.GenerateTokens(user)
+	accessToken, refreshToken, err := utils.GenerateTokens(user, sessionID)
 	if err != nil {
-		c.JSON(http.StatusInternalServerError, models.ErrorResponse{
+		ah.authFailed(c, event, http.StatusInternalServerError, models.ErrorResponse{
 			Error:   "internal_error",
 			Message: "Failed to generate tokens",
 		})
 		return
 	}
 
-	c.JSON(http.StatusOK, models.AuthResponse{
+	event.Success = true
+	ah.recordAuthEvent(c, event)
+
+	sendAuthResponse(c, models.AuthResponse{
 		User:         *user,
 		AccessToken:  accessToken,
 		RefreshToken: refreshToken,
-		ExpiresIn:    3600,
+		ExpiresIn:    int64(utils.AccessTokenTTL.Seconds()),
 	})
 }
 
 // @Summary Logout
-// @Description Logout the current user
+// @Description Logout the current user, revoking the session the token belongs to
 // @Tags auth
 // @Security ApiKeyAuth
 // @Success 200 {object} models.SuccessResponse
 // @Router /auth/logout [post]
 func (ah *AuthHandler) Logout(c *gin.Context) {
-	// In a production system, you might want to blacklist the token
+	currentUser, _ := c.Get("user")
+	user := currentUser.(*models.User)
+
+	event := models.AuthEvent{
+		Type:      "logout",
+		Method:    c.GetString("auth_method"),
+		UserID:    user.ID,
+		Email:     user.Email,
+		SessionID: c.GetString("session_id"),
+		Success:   true,
+	}
+
+	if event.SessionID != "" {
+		if err := ah.revokeSession(event.SessionID); err != nil {
+			log.Printf("Failed to revoke session %s: %v", event.SessionID, err)
+			event.Success = false
+			event.Reason = "session_not_revoked"
+		}
+	}
+	ah.recordAuthEvent(c, event)
+	clearSessionCookies(c)
+
 	c.JSON(http.StatusOK, models.SuccessResponse{
 		Success: true,
 		Message: "Logged out successfully",
 	})
 }
 
+// @Summary Logout everywhere
+// @Description Invalidate every access and refresh token issued to the current user by bumping their token version
+// @Tags auth
+// @Security ApiKeyAuth
+// @Success 200 {object} models.SuccessResponse
+// @Failure 500 {object} models.ErrorResponse
+// @Router /auth/logout-all [post]
+func (ah *AuthHandler) LogoutAll(c *gin.Context) {
+	currentUser, _ := c.Get("user")
+	user := currentUser.(*models.User)
+
+	ctx := ah.firestoreService.Context()
+	_, err := ah.firestoreService.Users().Doc(user.ID).Update(ctx, []firestore.Update{
+		{Path: "token_version", Value: firestore.Increment(1)},
+		{Path: "updated_at", Value: time.Now()},
+	})
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, models.ErrorResponse{
+			Error:   "internal_error",
+			Message: "Failed to log out of all sessions",
+		})
+		return
+	}
+
+	event := models.AuthEvent{
+		Type:      "logout_all",
+		Method:    c.GetString("auth_method"),
+		UserID:    user.ID,
+		Email:     user.Email,
+		SessionID: c.GetString("session_id"),
+		Success:   true,
+	}
+
+	// The version bump already rejects every token; revoking the sessions
+	// keeps the device list accurate
+	docs, err := ah.firestoreService.Sessions().
+		Where("user_id", "==", user.ID).
+		Where("revoked", "==", false).
+		Documents(ctx).GetAll()
+	if err == nil && len(docs) > 0 {
+		batch := ah.firestoreService.Client.Batch()
+		for _, doc := range docs {
+			batch.Update(doc.Ref, []firestore.Update{{Path: "revoked", Value: true}})
+		}
+		_, err = batch.Commit(ctx)
+	}
+	if err != nil {
+		log.Printf("Failed to revoke sessions of user %s: %v", user.ID, err)
+		event.Reason = "sessions_not_revoked"
+	}
+	ah.recordAuthEvent(c, event)
+	clearSessionCookies(c)
+
+	c.JSON(http.StatusOK, models.SuccessResponse{
+		Success: true,
+		Message: "Logged out of all sessions successfully",
+	})
+}
+
+// @Summary List sessions
+// @Description List the devices the current user is logged in on
+// @Tags auth
+// @Produce  json
+// @Security ApiKeyAuth
+// @Success 200 {object} models.SuccessResponse
+// @Failure 500 {object} models.ErrorResponse
+// @Router /auth/sessions [get]
+func (ah *AuthHandler) GetSessions(c *gin.Context) {
+	currentUser, _ := c.Get("user")
+	user := currentUser.(*models.User)
+
+	ctx := ah.firestoreService.Context()
+	docs, err := ah.firestoreService.Sessions().
+		Where("user_id", "==", user.ID).
+		Where("revoked", "==", false).
+		Documents(ctx).GetAll()
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, models.ErrorResponse{
+			Error:   "internal_error",
+			Message: "Failed to retrieve sessions",
+		})
+		return
+	}
+
+	sessions := []models.Session{}
+	for _, doc := range docs {
+		var session models.Session
+		doc.DataTo(&session)
+		if time.Now().After(session.ExpiresAt) {
+			continue
+		}
+		session.Current = session.ID == c.GetString("session_id")
+		sessions = append(sessions, session)
+	}
+
+	c.JSON(http.StatusOK, models.SuccessResponse{
+		Success: true,
+		Data:    sessions,
+	})
+}
+
+// @Summary Revoke a session
+// @Description Log out a device by revoking its session. Its access and refresh tokens stop working immediately.
+// @Tags auth
+// @Produce  json
+// @Security ApiKeyAuth
+// @Param id path string true "Session ID"
+// @Success 200 {object} models.SuccessResponse
+// @Failure 404 {object} models.ErrorResponse
+// @Failure 500 {object} models.ErrorResponse
+// @Router /auth/sessions/{id} [delete]
+func (ah *AuthHandler) RevokeSession(c *gin.Context) {
+	sessionID := c.Param("id")
+	currentUser, _ := c.Get("user")
+	user := currentUser.(*models.User)
+
+	ctx := ah.firestoreService.Context()
+	doc, err := ah.firestoreService.Sessions().Doc(sessionID).Get(ctx)
+
+	var session models.Session
+	if err == nil {
+		doc.DataTo(&session)
+	}
+	// Other users' sessions are reported as missing rather than forbidden
+	if err != nil || session.UserID != user.ID || session.Revoked {
+		c.JSON(http.StatusNotFound, models.ErrorResponse{
+			Error:   "not_found",
+			Message: "Session not found",
+		})
+		return
+	}
+
+	if err := ah.revokeSession(sessionID); err != nil {
+		c.JSON(http.StatusInternalServerError, models.ErrorResponse{
+			Error:   "internal_error",
+			Message: "Failed to revoke session",
+		})
+		return
+	}
+
+	c.JSON(http.StatusOK, models.SuccessResponse{
+		Success: true,
+		Message: "Session revoked successfully",
+	})
+}
+
 // @Summary Get Current User
 // @Description Get the currently authenticated user's details
 // @Tags auth
@@ -201,39 +1208,70 @@ func (ah *AuthHandler) GetCurrentUser(c *gin.Context) {
 }
 
 // Helper functions
-func (ah *AuthHandler) getOrCreateUser(tokenInfo models.GoogleUserInfo) (*models.User, error) {
+// getOrCreateUser returns the account for the provider's email. Creating a new
+// account consumes the invite, which sets its role and field assignments.
+func (ah *AuthHandler) getOrCreateUser(tokenInfo models.OAuthUserInfo, inviteCode string) (*models.User, error) {
 	ctx := ah.firestoreService.Context()
 
-	email := tokenInfo.Email
+	email := normalizeEmail(tokenInfo.Email)
 	name := tokenInfo.Name
 	picture := tokenInfo.Picture
 
 	// Check if user exists
-	docs, err := ah.firestoreService.Users().Where("email", "==", tokenInfo.Email).Documents(ctx).GetAll()
+	existing, err := ah.getUserByEmail(email)
 	if err != nil {
 		return nil, err
 	}
 
-	if len(docs) > 0 {
-		// User exists, return it
-		var user models.User
-		docs[0].DataTo(&user)
-		return &user, nil
+	if existing != nil {
+		if existing.EmailVerified {
+			return existing, nil
+		}
+
+		// The provider has proven ownership of the address. A password set by an
+		// unverified signup may belong to someone else, so it is discarded.
+		existing.PasswordHash = ""
+		existing.EmailVerified = true
+		_, err := ah.firestoreService.Users().Doc(existing.ID).Update(ctx, []firestore.Update{
+			{Path: "password_hash", Value: firestore.Delete},
+			{Path: "email_verified", Value: true},
+			{Path: "tokens_valid_after", Value: time.Now()},
+			{Path: "updated_at", Value: time.Now()},
+		})
+		if err != nil {
+			return nil, err
+		}
+		return existing, nil
+	}
+
+	if inviteCode == "" {
+		return nil, errInviteRequired
+	}
+	invite, err := ah.getInvite(inviteCode, email)
+	if err != nil {
+		return nil, err
 	}
 
 	// Create new user
 	user := &models.User{
-		ID:          utils.GenerateID(),
-		Email:       email,
-		Name:        name,       // Will be updated from Google profile if available
-		Picture:     picture,    // Will be updated from Google profile if available
-		Role:        "observer", // Default role
-		CreatedAt:   time.Now(),
-		UpdatedAt:   time.Now(),
-		LastLoginAt: time.Now(),
+		ID:            utils.GenerateID(),
+		Email:         email,
+		Name:          name,    // Will be updated from provider profile if available
+		Picture:       picture, // Will be updated from provider profile if available
+		Role:          invite.Role,
+		FieldIDs:      invite.FieldIDs,
+		EmailVerified: true,
+		Pending:       needsApproval(invite.Role),
+		CreatedAt:     time.Now(),
+		UpdatedAt:     time.Now(),
+		LastLoginAt:   time.Now(),
 	}
 
-	_, err = ah.firestoreService.Users().Doc(user.ID).Set(ctx, user)
+	err = ah.createUser(user, invite.ID)
+	if status.Code(err) == codes.AlreadyExists {
+		// A concurrent login or signup created the account first
+		return ah.getUserByEmail(email)
+	}
 	if err != nil {
 		return nil, err
 	}
@@ -241,6 +1279,109 @@ func (ah *AuthHandler) getOrCreateUser(tokenInfo models.GoogleUserInfo) (*models
 	return user, nil
 }
 
+// createUser stores the user, reserves its email and marks the invite used in
+// one transaction. It fails with codes.AlreadyExists when the email is already
+// taken and with errInvalidInvite when the invite was used in the meantime.
+func (ah *AuthHandler) createUser(user *models.User, inviteID string) error {
+	ctx := ah.firestoreService.Context()
+	emailRef := ah.firestoreService.UserEmails().Doc(user.Email)
+	userRef := ah.firestoreService.Users().Doc(user.ID)
+	inviteRef := ah.firestoreService.Invites().Doc(inviteID)
+
+	return ah.firestoreService.Client.RunTransaction(ctx, func(ctx context.Context, tx *firestore.Transaction) error {
+		doc, err := tx.Get(inviteRef)
+		if err != nil {
+			return err
+		}
+		var invite models.Invite
+		doc.DataTo(&invite)
+		if !inviteUsable(&invite, user.Email) {
+			return errInvalidInvite
+		}
+
+		if err := tx.Create(emailRef, map[string]interface{}{"user_id": user.ID}); err != nil {
+			return err
+		}
+		if err := tx.Set(userRef, user); err != nil {
+			return err
+		}
+		return tx.Update(inviteRef, []firestore.Update{
+			{Path: "used_by", Value: user.ID},
+			{Path: "used_at", Value: time.Now()},
+		})
+	})
+}
+
+// getInvite finds the invite for code, returning errInvalidInvite unless email may use it
+func (ah *AuthHandler) getInvite(code, email string) (*models.Invite, error) {
+	if !strings.HasPrefix(code, utils.InviteCodePrefix) {
+		return nil, errInvalidInvite
+	}
+
+	ctx := ah.firestoreService.Context()
+	docs, err := ah.firestoreService.Invites().
+		Where("code_hash", "==", utils.HashToken(code)).
+		Limit(1).
+		Documents(ctx).GetAll()
+	if err != nil {
+		return nil, err
+	}
+	if len(docs) == 0 {
+		return nil, errInvalidInvite
+	}
+
+	var invite models.Invite
+	if err := docs[0].DataTo(&invite); err != nil {
+		return nil, err
+	}
+	if !inviteUsable(&invite, email) {
+		return nil, errInvalidInvite
+	}
+
+	return &invite, nil
+}
+
+// needsApproval reports whether a new account with role must be approved by an
+// admin before it can submit data. Invites for other roles are already a
+// deliberate grant of access.
+func needsApproval(role string) bool {
+	return role == "observer"
+}
+
+// inviteUsable reports whether invite can still create an account for email
+func inviteUsable(invite *models.Invite, email string) bool {
+	if invite.Revoked || invite.UsedBy != "" || time.Now().After(invite.ExpiresAt) {
+		return false
+	}
+	return invite.Email == "" || invite.Email == email
+}
+
+func (ah *AuthHandler) sendVerificationEmail(user *models.User) error {
+	token, err := utils.GenerateSecureToken(32)
+	if err != nil {
+		return err
+	}
+
+	verification := models.EmailVerification{
+		ID:        utils.GenerateID(),
+		UserID:    user.ID,
+		TokenHash: utils.HashToken(token),
+		ExpiresAt: time.Now().Add(24 * time.Hour),
+		CreatedAt: time.Now(),
+	}
+
+	ctx := ah.firestoreService.Context()
+	if _, err := ah.firestoreService.EmailVerifications().Doc(verification.ID).Set(ctx, verification); err != nil {
+		return err
+	}
+
+	verifyURL := fmt.Sprintf("%s/verify-email?token=%s",
+		utils.GetEnvOrDefault("FRONTEND_URL", "http://localhost:3000"), token)
+	body := fmt.Sprintf("Hello %s,\n\nConfirm your email address for Rice Monitor using the link below. It expires in 24 hours.\n\n%s\n\nIf you did not create an account, you can ignore this email.\n",
+		user.Name, verifyURL)
+	return ah.mailerService.Send([]string{user.Email}, "Verify your Rice Monitor email", body)
+}
+
 func (ah *AuthHandler) getUserByID(userID string) (*models.User, error) {
 	ctx := ah.firestoreService.Context()
 	doc, err := ah.firestoreService.Users().Doc(userID).Get(ctx)
@@ -257,6 +1398,132 @@ func (ah *AuthHandler) getUserByID(userID string) (*models.User, error) {
 	return &user, nil
 }
 
+// getUserByEmail returns nil without an error when no user has the email
+func (ah *AuthHandler) getUserByEmail(email string) (*models.User, error) {
+	ctx := ah.firestoreService.Context()
+	docs, err := ah.firestoreService.Users().Where("email", "==", email).Limit(1).Documents(ctx).GetAll()
+	if err != nil {
+		return nil, err
+	}
+	if len(docs) == 0 {
+		return nil, nil
+	}
+
+	var user models.User
+	if err := docs[0].DataTo(&user); err != nil {
+		return nil, err
+	}
+
+	return &user, nil
+}
+
+func normalizeEmail(email string) string {
+	return strings.ToLower(strings.TrimSpace(email))
+}
+
+// startSession records the device logging in and returns the new session
+func (ah *AuthHandler) startSession(c *gin.Context, user *models.User) (*models.Session, error) {
+	session := &models.Session{
+		ID:         utils.GenerateID(),
+		UserID:     user.ID,
+		UserAgent:  c.Request.UserAgent(),
+		IPAddress:  c.ClientIP(),
+		CreatedAt:  time.Now(),
+		LastUsedAt: time.Now(),
+		ExpiresAt:  time.Now().Add(utils.RefreshTokenTTL),
+	}
+
+	ctx := ah.firestoreService.Context()
+	if _, err := ah.firestoreService.Sessions().Doc(session.ID).Set(ctx, session); err != nil {
+		return nil, err
+	}
+
+	return session, nil
+}
+
+// extendSession marks a session as used by a refresh. It fails if the session
+// is revoked or belongs to another user.
+func (ah *AuthHandler) extendSession(sessionID, userID string) error {
+	ctx := ah.firestoreService.Context()
+	docRef := ah.firestoreService.Sessions().Doc(sessionID)
+
+	doc, err := docRef.Get(ctx)
+	if err != nil {
+		return err
+	}
+
+	var session models.Session
+	doc.DataTo(&session)
+	if session.Revoked || session.UserID != userID {
+		return errInvalidToken
+	}
+
+	_, err = docRef.Update(ctx, []firestore.Update{
+		{Path: "last_used_at", Value: time.Now()},
+		{Path: "expires_at", Value: time.Now().Add(utils.RefreshTokenTTL)},
+	})
+	return err
+}
+
+func (ah *AuthHandler) revokeSession(sessionID string) error {
+	ctx := ah.firestoreService.Context()
+	_, err := ah.firestoreService.Sessions().Doc(sessionID).Update(ctx, []firestore.Update{
+		{Path: "revoked", Value: true},
+	})
+	return err
+}
+
+// recordAuthEvent adds a login, refresh or logout attempt to the audit log.
+// Failing to record an event is logged and never blocks authentication.
+func (ah *AuthHandler) recordAuthEvent(c *gin.Context, event models.AuthEvent) {
+	event.ID = utils.GenerateID()
+	event.IPAddress = c.ClientIP()
+	event.UserAgent = c.Request.UserAgent()
+	event.CreatedAt = time.Now()
+
+	ctx := ah.firestoreService.Context()
+	if _, err := ah.firestoreService.AuthEvents().Doc(event.ID).Set(ctx, event); err != nil {
+		log.Printf("Failed to record %s auth event: %v", event.Type, err)
+	}
+}
+
+// accountLockedOut returns how long password logins to email are locked after
+// maxFailedLogins wrong passwords within loginLockout, or zero. The failures are
+// read from the auth audit log, so the lockout holds across instances.
+func (ah *AuthHandler) accountLockedOut(email string) time.Duration {
+	ctx := ah.firestoreService.Context()
+	docs, err := ah.firestoreService.AuthEvents().
+		Where("email", "==", email).
+		Where("reason", "==", "invalid_credentials").
+		Where("created_at", ">=", time.Now().Add(-ah.loginLockout)).
+		OrderBy("created_at", firestore.Desc).
+		Limit(ah.maxFailedLogins).
+		Documents(ctx).GetAll()
+	if err != nil {
+		// The per-IP rate limit still applies, so a log outage does not block every login
+		log.Printf("Failed to check login failures: %v", err)
+		return 0
+	}
+	if len(docs) < ah.maxFailedLogins {
+		return 0
+	}
+
+	// Locked until the oldest of the recent failures leaves the window
+	var oldest models.AuthEvent
+	docs[len(docs)-1].DataTo(&oldest)
+	return time.Until(oldest.CreatedAt.Add(ah.loginLockout))
+}
+
+// authFailed records a failed attempt with the error code as its reason, then
+// sends the error response
+func (ah *AuthHandler) authFailed(c *gin.Context, event models.AuthEvent, status int, response models.ErrorResponse) {
+	event.Success = false
+	event.Reason = response.Error
+	ah.recordAuthEvent(c, event)
+
+	c.JSON(status, response)
+}
+
 func (ah *AuthHandler) updateUserLastLogin(userID string) {
 	ctx := ah.firestoreService.Context()
 	_, err := ah.firestoreService.Users().Doc(userID).Update(ctx,
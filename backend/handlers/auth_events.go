@@ -0,0 +1,123 @@
+package handlers
+
+import (
+	"net/http"
+	"strconv"
+	"time"
+
+	"rice-monitor-api/models"
+	"rice-monitor-api/services"
+	"rice-monitor-api/utils"
+
+	"cloud.google.com/go/firestore"
+	"github.com/gin-gonic/gin"
+)
+
+type AuthEventHandler struct {
+	firestoreService *services.FirestoreService
+}
+
+func NewAuthEventHandler(firestoreService *services.FirestoreService) *AuthEventHandler {
+	return &AuthEventHandler{
+		firestoreService: firestoreService,
+	}
+}
+
+// @Summary Query the auth audit log
+// @Description List logins, token refreshes and logouts, newest first, including failed attempts
+// @Tags admin
+// @Produce  json
+// @Security ApiKeyAuth
+// @Param user_id query string false "Filter by user ID"
+// @Param email query string false "Filter by email address"
+// @Param type query string false "Filter by event type (login, refresh, logout)"
+// @Param success query bool false "Only successful (true) or failed (false) attempts"
+// @Param ip_address query string false "Filter by client IP address"
+// @Param since query string false "Only events at or after this time (RFC 3339 or YYYY-MM-DD)"
+// @Param until query string false "Only events before this time (RFC 3339 or YYYY-MM-DD)"
+// @Param limit query int false "Number of events to return (default 100, max 1000)"
+// @Success 200 {object} models.SuccessResponse
+// @Failure 400 {object} models.ErrorResponse
+// @Failure 500 {object} models.ErrorResponse
+// @Router /admin/auth-events [get]
+func (eh *AuthEventHandler) GetAuthEvents(c *gin.Context) {
+	limit, err := strconv.Atoi(c.DefaultQuery("limit", "100"))
+	if err != nil || limit <= 0 {
+		limit = 100
+	}
+	if limit > 1000 {
+		limit = 1000
+	}
+
+	currentUser, _ := c.Get("user")
+	loc := utils.UserLocation(currentUser.(*models.User))
+
+	query := eh.firestoreService.AuthEvents().Query
+	for _, param := range []string{"user_id", "email", "type", "ip_address"} {
+		if value := c.Query(param); value != "" {
+			if param == "email" {
+				value = normalizeEmail(value)
+			}
+			query = query.Where(param, "==", value)
+		}
+	}
+
+	if success := c.Query("success"); success != "" {
+		value, err := strconv.ParseBool(success)
+		if err != nil {
+			c.JSON(http.StatusBadRequest, models.ErrorResponse{
+				Error:   "invalid_request",
+				Message: "success must be true or false",
+			})
+			return
+		}
+		query = query.Where("success", "==", value)
+	}
+
+	for _, bound := range []struct{ param, op string }{{"since", ">="}, {"until", "<"}} {
+		value := c.Query(bound.param)
+		if value == "" {
+			continue
+		}
+		t, err := parseEventTime(value, loc)
+		if err != nil {
+			c.JSON(http.StatusBadRequest, models.ErrorResponse{
+				Error:   "invalid_request",
+				Message: bound.param + " must be an RFC 3339 time or a YYYY-MM-DD date",
+			})
+			return
+		}
+		query = query.Where("created_at", bound.op, t)
+	}
+
+	ctx := eh.firestoreService.Context()
+	docs, err := query.OrderBy("created_at", firestore.Desc).Limit(limit).Documents(ctx).GetAll()
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, models.ErrorResponse{
+			Error:   "internal_error",
+			Message: "Failed to retrieve auth events",
+		})
+		return
+	}
+
+	events := []models.AuthEvent{}
+	for _, doc := range docs {
+		var event models.AuthEvent
+		doc.DataTo(&event)
+		events = append(events, event)
+	}
+
+	c.JSON(http.StatusOK, models.SuccessResponse{
+		Success: true,
+		Data:    events,
+	})
+}
+
+// parseEventTime accepts an RFC 3339 time or a plain date, which starts at
+// midnight in loc
+func parseEventTime(value string, loc *time.Location) (time.Time, error) {
+	if t, err := time.Parse(time.RFC3339, value); err == nil {
+		return t, nil
+	}
+	return time.ParseInLocation("2006-01-02", value, loc)
+}
@@ -0,0 +1,314 @@
+package handlers
+
+import (
+	"math"
+	"net/http"
+	"sort"
+	"strings"
+	"time"
+
+	"rice-monitor-api/models"
+	"rice-monitor-api/services"
+	"rice-monitor-api/utils"
+
+	"cloud.google.com/go/firestore"
+	"github.com/gin-gonic/gin"
+)
+
+// calibratedTraits are the measurements calibrations can correct, in the order they are applied
+var calibratedTraits = []string{"culm_length", "panicle_length"}
+
+type CalibrationHandler struct {
+	firestoreService *services.FirestoreService
+}
+
+func NewCalibrationHandler(firestoreService *services.FirestoreService) *CalibrationHandler {
+	return &CalibrationHandler{
+		firestoreService: firestoreService,
+	}
+}
+
+// @Summary List calibrations
+// @Description List the registered instrument calibrations, most recent first
+// @Tags calibrations
+// @Produce  json
+// @Security ApiKeyAuth
+// @Param user_id query string false "Only calibrations of this observer"
+// @Param device_id query string false "Only calibrations of this device"
+// @Param active query bool false "Only active calibrations"
+// @Success 200 {object} models.SuccessResponse
+// @Failure 500 {object} models.ErrorResponse
+// @Router /calibrations [get]
+func (ch *CalibrationHandler) GetCalibrations(c *gin.Context) {
+	currentUser, _ := c.Get("user")
+	user := currentUser.(*models.User)
+
+	query := orgScope(ch.firestoreService.Calibrations().Query, user)
+	if userID := c.Query("user_id"); userID != "" {
+		query = query.Where("user_id", "==", userID)
+	}
+	if deviceID := c.Query("device_id"); deviceID != "" {
+		query = query.Where("device_id", "==", deviceID)
+	}
+	if c.Query("active") == "true" {
+		query = query.Where("active", "==", true)
+	}
+
+	ctx := ch.firestoreService.Context()
+	docs, err := query.Documents(ctx).GetAll()
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, models.ErrorResponse{
+			Error:   "internal_error",
+			Message: "Failed to retrieve calibrations",
+		})
+		return
+	}
+
+	calibrations := []models.Calibration{}
+	for _, doc := range docs {
+		var calibration models.Calibration
+		doc.DataTo(&calibration)
+		calibrations = append(calibrations, calibration)
+	}
+	sort.Slice(calibrations, func(i, j int) bool {
+		return calibrations[i].CreatedAt.After(calibrations[j].CreatedAt)
+	})
+
+	c.JSON(http.StatusOK, models.SuccessResponse{
+		Success: true,
+		Data:    calibrations,
+	})
+}
+
+// @Summary Register a calibration
+// @Description Register a known bias of one device or one observer's instrument. New and edited
+// @Description submissions from that device or observer store the measured value as raw_measurements
+// @Description and raw*factor + offset as trait_measurements. A device calibration takes precedence
+// @Description over an observer calibration for the same trait. Give either device_id or user_id.
+// @Tags calibrations
+// @Accept  json
+// @Produce  json
+// @Security ApiKeyAuth
+// @Param calibration body models.CalibrationRequest true "Calibration"
+// @Success 201 {object} models.SuccessResponse
+// @Failure 400 {object} models.ErrorResponse
+// @Failure 403 {object} models.ErrorResponse
+// @Failure 404 {object} models.ErrorResponse
+// @Failure 500 {object} models.ErrorResponse
+// @Router /calibrations [post]
+func (ch *CalibrationHandler) CreateCalibration(c *gin.Context) {
+	var req models.CalibrationRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, models.ErrorResponse{
+			Error:   "invalid_request",
+			Message: err.Error(),
+		})
+		return
+	}
+
+	req.DeviceID = strings.TrimSpace(req.DeviceID)
+	req.UserID = strings.TrimSpace(req.UserID)
+	if (req.DeviceID == "") == (req.UserID == "") {
+		c.JSON(http.StatusBadRequest, models.ErrorResponse{
+			Error:   "invalid_request",
+			Message: "Give either device_id or user_id",
+		})
+		return
+	}
+
+	currentUser, _ := c.Get("user")
+	user := currentUser.(*models.User)
+
+	ctx := ch.firestoreService.Context()
+	orgID := user.OrgID
+	if req.UserID != "" {
+		doc, err := ch.firestoreService.Users().Doc(req.UserID).Get(ctx)
+		if err != nil {
+			c.JSON(http.StatusNotFound, models.ErrorResponse{
+				Error:   "not_found",
+				Message: "User not found",
+			})
+			return
+		}
+
+		var observer models.User
+		doc.DataTo(&observer)
+		if !utils.HasPermissionIn(user, utils.PermCalibrationsManage, observer.OrgID) {
+			c.JSON(http.StatusForbidden, models.ErrorResponse{
+				Error:   "forbidden",
+				Message: "Access denied",
+			})
+			return
+		}
+		orgID = observer.OrgID
+	}
+
+	factor := 1.0
+	if req.Factor != nil {
+		factor = *req.Factor
+	}
+
+	calibration := models.Calibration{
+		ID:        utils.GenerateID(),
+		OrgID:     orgID,
+		DeviceID:  req.DeviceID,
+		UserID:    req.UserID,
+		Trait:     req.Trait,
+		Factor:    factor,
+		Offset:    req.Offset,
+		Reason:    req.Reason,
+		Active:    true,
+		CreatedBy: user.ID,
+		CreatedAt: time.Now(),
+	}
+
+	if _, err := ch.firestoreService.Calibrations().Doc(calibration.ID).Set(ctx, calibration); err != nil {
+		c.JSON(http.StatusInternalServerError, models.ErrorResponse{
+			Error:   "internal_error",
+			Message: "Failed to create calibration",
+		})
+		return
+	}
+
+	c.JSON(http.StatusCreated, models.SuccessResponse{
+		Success: true,
+		Data:    calibration,
+		Message: "Calibration registered successfully",
+	})
+}
+
+// @Summary Retire a calibration
+// @Description Stop applying a calibration to new measurements. Submissions it was applied to keep
+// @Description their corrected values, and the calibration stays listed so their provenance resolves.
+// @Tags calibrations
+// @Produce  json
+// @Security ApiKeyAuth
+// @Param id path string true "Calibration ID"
+// @Success 200 {object} models.SuccessResponse
+// @Failure 404 {object} models.ErrorResponse
+// @Failure 409 {object} models.ErrorResponse
+// @Failure 500 {object} models.ErrorResponse
+// @Router /calibrations/{id} [delete]
+func (ch *CalibrationHandler) RetireCalibration(c *gin.Context) {
+	currentUser, _ := c.Get("user")
+	user := currentUser.(*models.User)
+
+	ctx := ch.firestoreService.Context()
+	ref := ch.firestoreService.Calibrations().Doc(c.Param("id"))
+	doc, err := ref.Get(ctx)
+	if err != nil {
+		c.JSON(http.StatusNotFound, models.ErrorResponse{
+			Error:   "not_found",
+			Message: "Calibration not found",
+		})
+		return
+	}
+
+	var calibration models.Calibration
+	doc.DataTo(&calibration)
+	if !utils.HasPermissionIn(user, utils.PermCalibrationsManage, calibration.OrgID) {
+		c.JSON(http.StatusNotFound, models.ErrorResponse{
+			Error:   "not_found",
+			Message: "Calibration not found",
+		})
+		return
+	}
+
+	if !calibration.Active {
+		c.JSON(http.StatusConflict, models.ErrorResponse{
+			Error:   "already_retired",
+			Message: "Calibration is already retired",
+		})
+		return
+	}
+
+	_, err = ref.Update(ctx, []firestore.Update{
+		{Path: "active", Value: false},
+		{Path: "retired_at", Value: time.Now()},
+	})
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, models.ErrorResponse{
+			Error:   "internal_error",
+			Message: "Failed to retire calibration",
+		})
+		return
+	}
+
+	c.JSON(http.StatusOK, models.SuccessResponse{
+		Success: true,
+		Message: "Calibration retired successfully",
+	})
+}
+
+// applyCalibrations treats the submission's trait measurements as raw values
+// and corrects them with the active calibrations of its observer and device.
+// The raw values and the calibrations used are kept on the submission; both
+// are cleared when no calibration applies.
+func applyCalibrations(firestoreService *services.FirestoreService, submission *models.Submission) error {
+	ctx := firestoreService.Context()
+	byTrait := map[string]models.Calibration{}
+
+	docs, err := firestoreService.Calibrations().
+		Where("user_id", "==", submission.UserID).
+		Where("active", "==", true).
+		Documents(ctx).GetAll()
+	if err != nil {
+		return err
+	}
+	for _, doc := range docs {
+		var calibration models.Calibration
+		doc.DataTo(&calibration)
+		byTrait[calibration.Trait] = calibration
+	}
+
+	// Device IDs are reported by the client, so only calibrations registered
+	// within the submission's organization are trusted
+	if submission.Device != nil && submission.Device.DeviceID != "" {
+		docs, err := firestoreService.Calibrations().
+			Where("device_id", "==", submission.Device.DeviceID).
+			Where("active", "==", true).
+			Documents(ctx).GetAll()
+		if err != nil {
+			return err
+		}
+		for _, doc := range docs {
+			var calibration models.Calibration
+			doc.DataTo(&calibration)
+			if calibration.OrgID == "" || calibration.OrgID == submission.OrgID {
+				byTrait[calibration.Trait] = calibration
+			}
+		}
+	}
+
+	raw := submission.TraitMeasurements
+	corrected := raw
+	var applied []string
+	for _, trait := range calibratedTraits {
+		calibration, ok := byTrait[trait]
+		if !ok {
+			continue
+		}
+
+		var value *float64
+		switch trait {
+		case "culm_length":
+			value = &corrected.CulmLength
+		case "panicle_length":
+			value = &corrected.PanicleLength
+		}
+		// Zero means the trait was not measured
+		if *value == 0 {
+			continue
+		}
+		*value = math.Round((*value*calibration.Factor+calibration.Offset)*100) / 100
+		applied = append(applied, calibration.ID)
+	}
+
+	submission.RawMeasurements = nil
+	submission.Calibrations = applied
+	if len(applied) > 0 {
+		submission.RawMeasurements = &raw
+		submission.TraitMeasurements = corrected
+	}
+	return nil
+}
@@ -0,0 +1,230 @@
+package handlers
+
+import (
+	"net/http"
+	"sort"
+	"time"
+
+	"rice-monitor-api/models"
+	"rice-monitor-api/utils"
+
+	"cloud.google.com/go/firestore"
+	"github.com/gin-gonic/gin"
+)
+
+// correctionEvent is a review decision or a correction of one submission
+type correctionEvent struct {
+	at         time.Time
+	decision   *models.ReviewDecision
+	correction *models.SubmissionCorrection
+}
+
+// @Summary Correction and reversal rates
+// @Description Count the approvals each reviewer made, and those of each observer's submissions, in the period,
+// @Description with how many were later edited (corrected) or overturned by another review decision (reversed)
+// @Description within it. High approval counts with no rejections or frequent corrections point at rubber-stamping.
+// @Tags analytics
+// @Produce  json
+// @Security ApiKeyAuth
+// @Param since query string false "RFC 3339 time or YYYY-MM-DD date, 90 days ago by default"
+// @Param until query string false "RFC 3339 time or YYYY-MM-DD date, now by default"
+// @Success 200 {object} models.SuccessResponse
+// @Failure 400 {object} models.ErrorResponse
+// @Failure 500 {object} models.ErrorResponse
+// @Router /analytics/corrections [get]
+func (ah *AnalyticsHandler) GetCorrections(c *gin.Context) {
+	currentUser, _ := c.Get("user")
+	user := currentUser.(*models.User)
+	loc := utils.UserLocation(user)
+
+	until := time.Now()
+	since := until.AddDate(0, 0, -90)
+	for _, bound := range []struct {
+		param string
+		value *time.Time
+	}{{"since", &since}, {"until", &until}} {
+		value := c.Query(bound.param)
+		if value == "" {
+			continue
+		}
+		t, err := parseEventTime(value, loc)
+		if err != nil {
+			c.JSON(http.StatusBadRequest, models.ErrorResponse{
+				Error:   "invalid_request",
+				Message: bound.param + " must be an RFC 3339 time or a YYYY-MM-DD date",
+			})
+			return
+		}
+		*bound.value = t
+	}
+
+	ctx := ah.firestoreService.Context()
+	decisionDocs, err := orgScope(ah.firestoreService.ReviewDecisions().Query, user).
+		Where("created_at", ">=", since).
+		Where("created_at", "<", until).
+		Documents(ctx).GetAll()
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, models.ErrorResponse{
+			Error:   "internal_error",
+			Message: "Failed to retrieve review decisions",
+		})
+		return
+	}
+	correctionDocs, err := orgScope(ah.firestoreService.SubmissionCorrections().Query, user).
+		Where("created_at", ">=", since).
+		Where("created_at", "<", until).
+		Documents(ctx).GetAll()
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, models.ErrorResponse{
+			Error:   "internal_error",
+			Message: "Failed to retrieve corrections",
+		})
+		return
+	}
+
+	events := map[string][]correctionEvent{}
+	var unknownObservers []*firestore.DocumentRef
+	for _, doc := range decisionDocs {
+		var decision models.ReviewDecision
+		doc.DataTo(&decision)
+		events[decision.SubmissionID] = append(events[decision.SubmissionID], correctionEvent{at: decision.CreatedAt, decision: &decision})
+		if decision.ObserverID == "" {
+			unknownObservers = append(unknownObservers, ah.firestoreService.Submissions().Doc(decision.SubmissionID))
+		}
+	}
+	for _, doc := range correctionDocs {
+		var correction models.SubmissionCorrection
+		doc.DataTo(&correction)
+		events[correction.SubmissionID] = append(events[correction.SubmissionID], correctionEvent{at: correction.CreatedAt, correction: &correction})
+	}
+
+	// Decisions recorded before observers were logged with them are matched to their submissions
+	observers := map[string]string{}
+	if len(unknownObservers) > 0 {
+		docs, err := ah.firestoreService.Client.GetAll(ctx, unknownObservers)
+		if err != nil {
+			c.JSON(http.StatusInternalServerError, models.ErrorResponse{
+				Error:   "internal_error",
+				Message: "Failed to retrieve submissions",
+			})
+			return
+		}
+		for _, doc := range docs {
+			if !doc.Exists() {
+				continue
+			}
+			var submission models.Submission
+			doc.DataTo(&submission)
+			observers[doc.Ref.ID] = submission.UserID
+		}
+	}
+
+	byReviewer, byObserver := tallyCorrections(events, observers)
+
+	totals := models.CorrectionStats{}
+	for _, stats := range byReviewer {
+		totals.Approved += stats.Approved
+		totals.Rejected += stats.Rejected
+		totals.Corrected += stats.Corrected
+		totals.Reversed += stats.Reversed
+	}
+	setCorrectionRates(&totals)
+
+	c.JSON(http.StatusOK, models.SuccessResponse{
+		Success: true,
+		Data: gin.H{
+			"since":       since,
+			"until":       until,
+			"totals":      totals,
+			"by_reviewer": sortedCorrectionStats(byReviewer),
+			"by_observer": sortedCorrectionStats(byObserver),
+		},
+	})
+}
+
+// tallyCorrections walks each submission's decisions and corrections in time
+// order. An approval counts as corrected once when the submission is edited
+// while approved, and as reversed when a later decision moves it out of approved.
+func tallyCorrections(events map[string][]correctionEvent, observers map[string]string) (map[string]*models.CorrectionStats, map[string]*models.CorrectionStats) {
+	byReviewer := map[string]*models.CorrectionStats{}
+	byObserver := map[string]*models.CorrectionStats{}
+	entry := func(stats map[string]*models.CorrectionStats, userID string) *models.CorrectionStats {
+		if stats[userID] == nil {
+			stats[userID] = &models.CorrectionStats{UserID: userID}
+		}
+		return stats[userID]
+	}
+
+	for submissionID, submissionEvents := range events {
+		sort.Slice(submissionEvents, func(i, j int) bool {
+			return submissionEvents[i].at.Before(submissionEvents[j].at)
+		})
+
+		var reviewer, observer *models.CorrectionStats
+		corrected := false
+		for _, event := range submissionEvents {
+			if event.correction != nil {
+				if reviewer != nil && !corrected {
+					reviewer.Corrected++
+					observer.Corrected++
+					corrected = true
+				}
+				continue
+			}
+
+			decision := event.decision
+			observerID := decision.ObserverID
+			if observerID == "" {
+				observerID = observers[submissionID]
+			}
+
+			if decision.Reversal && decision.PreviousStatus == "approved" && reviewer != nil {
+				reviewer.Reversed++
+				observer.Reversed++
+				reviewer, observer = nil, nil
+			}
+
+			switch decision.Status {
+			case "approved":
+				if decision.PreviousStatus == "approved" {
+					continue
+				}
+				reviewer = entry(byReviewer, decision.ReviewerID)
+				reviewer.Name = decision.ReviewerName
+				observer = entry(byObserver, observerID)
+				reviewer.Approved++
+				observer.Approved++
+				corrected = false
+			case "rejected":
+				entry(byReviewer, decision.ReviewerID).Rejected++
+				entry(byObserver, observerID).Rejected++
+			}
+		}
+	}
+
+	return byReviewer, byObserver
+}
+
+func setCorrectionRates(stats *models.CorrectionStats) {
+	if stats.Approved == 0 {
+		return
+	}
+	stats.CorrectionRate = float64(stats.Corrected) / float64(stats.Approved)
+	stats.ReversalRate = float64(stats.Reversed) / float64(stats.Approved)
+}
+
+// sortedCorrectionStats lists stats with rates filled in, most approvals first
+func sortedCorrectionStats(stats map[string]*models.CorrectionStats) []models.CorrectionStats {
+	list := make([]models.CorrectionStats, 0, len(stats))
+	for _, entry := range stats {
+		setCorrectionRates(entry)
+		list = append(list, *entry)
+	}
+	sort.Slice(list, func(i, j int) bool {
+		if list[i].Approved != list[j].Approved {
+			return list[i].Approved > list[j].Approved
+		}
+		return list[i].UserID < list[j].UserID
+	})
+	return list
+}
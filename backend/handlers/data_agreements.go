@@ -0,0 +1,337 @@
+package handlers
+
+import (
+	"net/http"
+	"sort"
+	"time"
+
+	"rice-monitor-api/models"
+	"rice-monitor-api/services"
+	"rice-monitor-api/utils"
+
+	"cloud.google.com/go/firestore"
+	"github.com/gin-gonic/gin"
+)
+
+type DataAgreementHandler struct {
+	firestoreService     *services.FirestoreService
+	dataAgreementService *services.DataAgreementService
+}
+
+func NewDataAgreementHandler(firestoreService *services.FirestoreService, dataAgreementService *services.DataAgreementService) *DataAgreementHandler {
+	return &DataAgreementHandler{
+		firestoreService:     firestoreService,
+		dataAgreementService: dataAgreementService,
+	}
+}
+
+// @Summary List data access agreements
+// @Description List every published version of your organization's data access agreement, newest first
+// @Tags agreements
+// @Produce  json
+// @Security ApiKeyAuth
+// @Success 200 {object} models.SuccessResponse
+// @Failure 403 {object} models.ErrorResponse
+// @Failure 500 {object} models.ErrorResponse
+// @Router /agreements [get]
+func (ah *DataAgreementHandler) GetAgreements(c *gin.Context) {
+	currentUser, _ := c.Get("user")
+	user := currentUser.(*models.User)
+
+	ctx := ah.firestoreService.Context()
+	query := orgScope(ah.firestoreService.DataAgreements().Query, user)
+	docs, err := query.Documents(ctx).GetAll()
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, models.ErrorResponse{
+			Error:   "internal_error",
+			Message: "Failed to retrieve agreements",
+		})
+		return
+	}
+
+	agreements := []models.DataAgreement{}
+	for _, doc := range docs {
+		var agreement models.DataAgreement
+		doc.DataTo(&agreement)
+		agreements = append(agreements, agreement)
+	}
+	sortAgreements(agreements)
+
+	c.JSON(http.StatusOK, models.SuccessResponse{
+		Success: true,
+		Data:    agreements,
+	})
+}
+
+// @Summary Publish a data access agreement
+// @Description Publish a new version of your organization's data access agreement. External collaborators
+// @Description cannot export data again until they accept it.
+// @Tags agreements
+// @Accept  json
+// @Produce  json
+// @Security ApiKeyAuth
+// @Param agreement body models.DataAgreementRequest true "Agreement text"
+// @Success 201 {object} models.SuccessResponse
+// @Failure 400 {object} models.ErrorResponse
+// @Failure 403 {object} models.ErrorResponse
+// @Failure 500 {object} models.ErrorResponse
+// @Router /agreements [post]
+func (ah *DataAgreementHandler) CreateAgreement(c *gin.Context) {
+	currentUser, _ := c.Get("user")
+	user := currentUser.(*models.User)
+
+	var req models.DataAgreementRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, models.ErrorResponse{
+			Error:   "invalid_request",
+			Message: err.Error(),
+		})
+		return
+	}
+
+	orgID := user.OrgID
+	if orgID == "" && req.OrgID != "" {
+		ctx := ah.firestoreService.Context()
+		if _, err := ah.firestoreService.Organizations().Doc(req.OrgID).Get(ctx); err != nil {
+			c.JSON(http.StatusBadRequest, models.ErrorResponse{
+				Error:   "invalid_request",
+				Message: "Organization not found",
+			})
+			return
+		}
+		orgID = req.OrgID
+	}
+
+	current, err := ah.dataAgreementService.Current(orgID)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, models.ErrorResponse{
+			Error:   "internal_error",
+			Message: "Failed to retrieve the current agreement",
+		})
+		return
+	}
+
+	agreement := models.DataAgreement{
+		ID:        utils.GenerateID(),
+		OrgID:     orgID,
+		Version:   1,
+		Title:     req.Title,
+		Text:      req.Text,
+		CreatedBy: user.ID,
+		CreatedAt: time.Now(),
+	}
+	if current != nil {
+		agreement.Version = current.Version + 1
+	}
+
+	ctx := ah.firestoreService.Context()
+	if _, err := ah.firestoreService.DataAgreements().Doc(agreement.ID).Set(ctx, agreement); err != nil {
+		c.JSON(http.StatusInternalServerError, models.ErrorResponse{
+			Error:   "internal_error",
+			Message: "Failed to publish agreement",
+		})
+		return
+	}
+
+	c.JSON(http.StatusCreated, models.SuccessResponse{
+		Success: true,
+		Data:    agreement,
+		Message: "Agreement published successfully",
+	})
+}
+
+// @Summary Get the current data access agreement
+// @Description Get the current version of your organization's data access agreement and whether you accepted it
+// @Tags agreements
+// @Produce  json
+// @Security ApiKeyAuth
+// @Success 200 {object} models.SuccessResponse
+// @Failure 404 {object} models.ErrorResponse
+// @Failure 500 {object} models.ErrorResponse
+// @Router /agreements/current [get]
+func (ah *DataAgreementHandler) GetCurrentAgreement(c *gin.Context) {
+	currentUser, _ := c.Get("user")
+	user := currentUser.(*models.User)
+
+	agreement, ok := ah.currentAgreement(c, user)
+	if !ok {
+		return
+	}
+
+	acceptance, err := ah.dataAgreementService.Acceptance(user.ID, agreement)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, models.ErrorResponse{
+			Error:   "internal_error",
+			Message: "Failed to retrieve acceptance",
+		})
+		return
+	}
+
+	data := map[string]interface{}{
+		"agreement": agreement,
+		"accepted":  acceptance != nil,
+		"required":  user.External,
+	}
+	if acceptance != nil {
+		data["accepted_at"] = acceptance.AcceptedAt
+	}
+	c.JSON(http.StatusOK, models.SuccessResponse{
+		Success: true,
+		Data:    data,
+	})
+}
+
+// @Summary Accept the current data access agreement
+// @Description Accept the current version of your organization's data access agreement. The version
+// @Description must be the one you were shown; 409 means a newer version was published meanwhile.
+// @Tags agreements
+// @Accept  json
+// @Produce  json
+// @Security ApiKeyAuth
+// @Param acceptance body models.AcceptAgreementRequest true "Accepted version"
+// @Success 200 {object} models.SuccessResponse
+// @Failure 400 {object} models.ErrorResponse
+// @Failure 404 {object} models.ErrorResponse
+// @Failure 409 {object} models.ErrorResponse
+// @Failure 500 {object} models.ErrorResponse
+// @Router /agreements/current/accept [post]
+func (ah *DataAgreementHandler) AcceptAgreement(c *gin.Context) {
+	currentUser, _ := c.Get("user")
+	user := currentUser.(*models.User)
+
+	var req models.AcceptAgreementRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, models.ErrorResponse{
+			Error:   "invalid_request",
+			Message: err.Error(),
+		})
+		return
+	}
+
+	agreement, ok := ah.currentAgreement(c, user)
+	if !ok {
+		return
+	}
+	if req.Version != agreement.Version {
+		c.JSON(http.StatusConflict, models.ErrorResponse{
+			Error:   "agreement_outdated",
+			Message: "A newer version of the agreement was published; review and accept it instead",
+		})
+		return
+	}
+
+	acceptance := models.AgreementAcceptance{
+		ID:          services.AcceptanceID(agreement.ID, user.ID),
+		AgreementID: agreement.ID,
+		OrgID:       agreement.OrgID,
+		Version:     agreement.Version,
+		UserID:      user.ID,
+		IPAddress:   c.ClientIP(),
+		AcceptedAt:  time.Now(),
+	}
+
+	ctx := ah.firestoreService.Context()
+	if _, err := ah.firestoreService.AgreementAcceptances().Doc(acceptance.ID).Set(ctx, acceptance); err != nil {
+		c.JSON(http.StatusInternalServerError, models.ErrorResponse{
+			Error:   "internal_error",
+			Message: "Failed to record acceptance",
+		})
+		return
+	}
+
+	c.JSON(http.StatusOK, models.SuccessResponse{
+		Success: true,
+		Data:    acceptance,
+		Message: "Agreement accepted",
+	})
+}
+
+// @Summary List agreement acceptances
+// @Description List who accepted a version of the data access agreement and when
+// @Tags agreements
+// @Produce  json
+// @Security ApiKeyAuth
+// @Param id path string true "Agreement ID"
+// @Success 200 {object} models.SuccessResponse
+// @Failure 404 {object} models.ErrorResponse
+// @Failure 500 {object} models.ErrorResponse
+// @Router /agreements/{id}/acceptances [get]
+func (ah *DataAgreementHandler) GetAcceptances(c *gin.Context) {
+	currentUser, _ := c.Get("user")
+	user := currentUser.(*models.User)
+
+	ctx := ah.firestoreService.Context()
+	doc, err := ah.firestoreService.DataAgreements().Doc(c.Param("id")).Get(ctx)
+	if err != nil {
+		c.JSON(http.StatusNotFound, models.ErrorResponse{
+			Error:   "not_found",
+			Message: "Agreement not found",
+		})
+		return
+	}
+
+	var agreement models.DataAgreement
+	doc.DataTo(&agreement)
+	if !utils.HasPermissionIn(user, utils.PermAgreementsManage, agreement.OrgID) {
+		c.JSON(http.StatusNotFound, models.ErrorResponse{
+			Error:   "not_found",
+			Message: "Agreement not found",
+		})
+		return
+	}
+
+	docs, err := ah.firestoreService.AgreementAcceptances().
+		Where("agreement_id", "==", agreement.ID).
+		OrderBy("accepted_at", firestore.Asc).
+		Documents(ctx).GetAll()
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, models.ErrorResponse{
+			Error:   "internal_error",
+			Message: "Failed to retrieve acceptances",
+		})
+		return
+	}
+
+	acceptances := []models.AgreementAcceptance{}
+	for _, doc := range docs {
+		var acceptance models.AgreementAcceptance
+		doc.DataTo(&acceptance)
+		acceptances = append(acceptances, acceptance)
+	}
+
+	c.JSON(http.StatusOK, models.SuccessResponse{
+		Success: true,
+		Data:    acceptances,
+	})
+}
+
+// currentAgreement loads the current agreement of the user's organization,
+// responding with an error and returning false when there is none
+func (ah *DataAgreementHandler) currentAgreement(c *gin.Context, user *models.User) (*models.DataAgreement, bool) {
+	agreement, err := ah.dataAgreementService.Current(user.OrgID)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, models.ErrorResponse{
+			Error:   "internal_error",
+			Message: "Failed to retrieve the current agreement",
+		})
+		return nil, false
+	}
+	if agreement == nil {
+		c.JSON(http.StatusNotFound, models.ErrorResponse{
+			Error:   "not_found",
+			Message: "No data access agreement has been published for your organization",
+		})
+		return nil, false
+	}
+	return agreement, true
+}
+
+// sortAgreements orders agreements by organization, newest version first
+func sortAgreements(agreements []models.DataAgreement) {
+	sort.Slice(agreements, func(i, j int) bool {
+		if agreements[i].OrgID != agreements[j].OrgID {
+			return agreements[i].OrgID < agreements[j].OrgID
+		}
+		return agreements[i].Version > agreements[j].Version
+	})
+}
@@ -0,0 +1,533 @@
+package handlers
+
+import (
+	"bytes"
+	"encoding/csv"
+	"fmt"
+	"io"
+	"log"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+
+	"rice-monitor-api/models"
+	"rice-monitor-api/services"
+	"rice-monitor-api/utils"
+
+	"cloud.google.com/go/firestore"
+	"github.com/gin-gonic/gin"
+	"google.golang.org/api/iterator"
+)
+
+type DatasetHandler struct {
+	firestoreService *services.FirestoreService
+	storageService   *services.StorageService
+	ckanService      *services.CKANService
+}
+
+// datasetLicense maps an SPDX identifier to the CKAN license register ID and a license URI
+type datasetLicense struct {
+	CKANID string
+	URL    string
+}
+
+var datasetLicenses = map[string]datasetLicense{
+	"CC-BY-4.0":    {CKANID: "cc-by", URL: "https://creativecommons.org/licenses/by/4.0/"},
+	"CC-BY-SA-4.0": {CKANID: "cc-by-sa", URL: "https://creativecommons.org/licenses/by-sa/4.0/"},
+	"CC-BY-NC-4.0": {CKANID: "cc-nc", URL: "https://creativecommons.org/licenses/by-nc/4.0/"},
+	"CC0-1.0":      {CKANID: "cc-zero", URL: "https://creativecommons.org/publicdomain/zero/1.0/"},
+	"ODbL-1.0":     {CKANID: "odc-odbl", URL: "https://opendatacommons.org/licenses/odbl/1-0/"},
+	"ODC-By-1.0":   {CKANID: "odc-by", URL: "https://opendatacommons.org/licenses/by/1-0/"},
+	"PDDL-1.0":     {CKANID: "odc-pddl", URL: "https://opendatacommons.org/licenses/pddl/1-0/"},
+}
+
+// firestoreInLimit is the maximum number of values Firestore accepts in an "in" filter
+const firestoreInLimit = 30
+
+func NewDatasetHandler(firestoreService *services.FirestoreService, storageService *services.StorageService, ckanService *services.CKANService) *DatasetHandler {
+	return &DatasetHandler{
+		firestoreService: firestoreService,
+		storageService:   storageService,
+		ckanService:      ckanService,
+	}
+}
+
+// @Summary List datasets
+// @Description List all dataset releases, including drafts
+// @Tags datasets
+// @Produce  json
+// @Security ApiKeyAuth
+// @Success 200 {object} models.SuccessResponse
+// @Failure 500 {object} models.ErrorResponse
+// @Router /datasets [get]
+func (dh *DatasetHandler) GetDatasets(c *gin.Context) {
+	ctx := dh.firestoreService.Context()
+	docs, err := dh.firestoreService.Datasets().OrderBy("created_at", firestore.Desc).Documents(ctx).GetAll()
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, models.ErrorResponse{
+			Error:   "internal_error",
+			Message: "Failed to retrieve datasets",
+		})
+		return
+	}
+
+	datasets := []models.Dataset{}
+	for _, doc := range docs {
+		var dataset models.Dataset
+		doc.DataTo(&dataset)
+		datasets = append(datasets, dataset)
+	}
+
+	c.JSON(http.StatusOK, models.SuccessResponse{
+		Success: true,
+		Data:    datasets,
+	})
+}
+
+// @Summary Create a dataset
+// @Description Create a draft dataset release
+// @Tags datasets
+// @Accept  json
+// @Produce  json
+// @Security ApiKeyAuth
+// @Param dataset body models.CreateDatasetRequest true "Dataset details"
+// @Success 201 {object} models.SuccessResponse
+// @Failure 400 {object} models.ErrorResponse
+// @Failure 500 {object} models.ErrorResponse
+// @Router /datasets [post]
+func (dh *DatasetHandler) CreateDataset(c *gin.Context) {
+	var req models.CreateDatasetRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, models.ErrorResponse{
+			Error:   "invalid_request",
+			Message: err.Error(),
+		})
+		return
+	}
+
+	for _, date := range []string{req.StartDate, req.EndDate} {
+		if date == "" {
+			continue
+		}
+		if _, err := utils.ParseDate(date); err != nil {
+			c.JSON(http.StatusBadRequest, models.ErrorResponse{
+				Error:   "invalid_request",
+				Message: "Dates must use the YYYY-MM-DD format",
+			})
+			return
+		}
+	}
+
+	currentUser, _ := c.Get("user")
+	user := currentUser.(*models.User)
+
+	dataset := models.Dataset{
+		ID:          utils.GenerateID(),
+		Title:       req.Title,
+		Description: req.Description,
+		Keywords:    req.Keywords,
+		License:     utils.GetEnvOrDefault("OPEN_DATA_DEFAULT_LICENSE", "CC-BY-4.0"),
+		Publisher:   utils.GetEnvOrDefault("OPEN_DATA_PUBLISHER", "Rice Monitor"),
+		FieldIDs:    req.FieldIDs,
+		StartDate:   req.StartDate,
+		EndDate:     req.EndDate,
+		Status:      "draft",
+		CreatedBy:   user.ID,
+		CreatedAt:   time.Now(),
+		UpdatedAt:   time.Now(),
+	}
+	if req.License != "" {
+		dataset.License = req.License
+	}
+	if req.Publisher != "" {
+		dataset.Publisher = req.Publisher
+	}
+
+	ctx := dh.firestoreService.Context()
+	_, err := dh.firestoreService.Datasets().Doc(dataset.ID).Set(ctx, dataset)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, models.ErrorResponse{
+			Error:   "internal_error",
+			Message: "Failed to create dataset",
+		})
+		return
+	}
+
+	c.JSON(http.StatusCreated, models.SuccessResponse{
+		Success: true,
+		Data:    dataset,
+		Message: "Dataset created successfully",
+	})
+}
+
+// @Summary Publish a dataset
+// @Description Freeze the dataset records into a CSV snapshot, publish it to the open data catalog and push it to CKAN when configured
+// @Tags datasets
+// @Produce  json
+// @Security ApiKeyAuth
+// @Param id path string true "Dataset ID"
+// @Success 200 {object} models.SuccessResponse
+// @Failure 404 {object} models.ErrorResponse
+// @Failure 500 {object} models.ErrorResponse
+// @Router /datasets/{id}/publish [post]
+func (dh *DatasetHandler) PublishDataset(c *gin.Context) {
+	datasetID := c.Param("id")
+
+	dataset, err := dh.getDatasetByID(datasetID)
+	if err != nil {
+		c.JSON(http.StatusNotFound, models.ErrorResponse{
+			Error:   "not_found",
+			Message: "Dataset not found",
+		})
+		return
+	}
+
+	// The release is frozen here so later reviews do not change what portals have indexed
+	recordCount, err := dh.writeSnapshot(dataset)
+	if err != nil {
+		log.Printf("Failed to write snapshot for dataset %s: %v", dataset.ID, err)
+		c.JSON(http.StatusInternalServerError, models.ErrorResponse{
+			Error:   "internal_error",
+			Message: "Failed to write dataset snapshot",
+		})
+		return
+	}
+
+	now := time.Now()
+	dataset.Status = "published"
+	dataset.RecordCount = recordCount
+	dataset.UpdatedAt = now
+	if dataset.PublishedAt == nil {
+		dataset.PublishedAt = &now
+	}
+
+	message := "Dataset published successfully"
+	if dh.ckanService.Enabled() {
+		ckanID, err := dh.ckanService.PushPackage(c.Request.Context(), dh.toCKANPackage(publicBaseURL(c), dataset))
+		if err != nil {
+			log.Printf("Failed to push dataset %s to CKAN: %v", dataset.ID, err)
+			message = "Dataset published, but pushing to the CKAN portal failed"
+		} else {
+			dataset.CKANID = ckanID
+		}
+	}
+
+	ctx := dh.firestoreService.Context()
+	_, err = dh.firestoreService.Datasets().Doc(datasetID).Set(ctx, dataset)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, models.ErrorResponse{
+			Error:   "internal_error",
+			Message: "Failed to publish dataset",
+		})
+		return
+	}
+
+	c.JSON(http.StatusOK, models.SuccessResponse{
+		Success: true,
+		Data:    dataset,
+		Message: message,
+	})
+}
+
+// @Summary DCAT catalog
+// @Description Public DCAT (JSON-LD) catalog of published datasets for open data portals
+// @Tags open-data
+// @Produce  json
+// @Success 200 {object} object
+// @Failure 500 {object} models.ErrorResponse
+// @Router /open-data/catalog [get]
+func (dh *DatasetHandler) GetCatalog(c *gin.Context) {
+	ctx := dh.firestoreService.Context()
+	docs, err := dh.firestoreService.Datasets().Where("status", "==", "published").Documents(ctx).GetAll()
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, models.ErrorResponse{
+			Error:   "internal_error",
+			Message: "Failed to retrieve catalog",
+		})
+		return
+	}
+
+	baseURL := publicBaseURL(c)
+	datasets := []map[string]interface{}{}
+	for _, doc := range docs {
+		var dataset models.Dataset
+		doc.DataTo(&dataset)
+		datasets = append(datasets, dh.toDCATDataset(baseURL, &dataset))
+	}
+
+	c.Header("Content-Type", "application/ld+json")
+	c.JSON(http.StatusOK, map[string]interface{}{
+		"@context": map[string]string{
+			"dcat": "http://www.w3.org/ns/dcat#",
+			"dct":  "http://purl.org/dc/terms/",
+			"foaf": "http://xmlns.com/foaf/0.1/",
+		},
+		"@id":             baseURL + "/api/v1/open-data/catalog",
+		"@type":           "dcat:Catalog",
+		"dct:title":       utils.GetEnvOrDefault("OPEN_DATA_CATALOG_TITLE", "Rice Monitor Open Data"),
+		"dct:description": "Published rice field monitoring datasets",
+		"dct:publisher": map[string]string{
+			"@type":     "foaf:Organization",
+			"foaf:name": utils.GetEnvOrDefault("OPEN_DATA_PUBLISHER", "Rice Monitor"),
+		},
+		"dcat:dataset": datasets,
+	})
+}
+
+// @Summary Download dataset
+// @Description Public CSV distribution of a published dataset, as frozen at publish time
+// @Tags open-data
+// @Produce  text/csv
+// @Param id path string true "Dataset ID"
+// @Success 200 {string} string "CSV content"
+// @Failure 404 {object} models.ErrorResponse
+// @Failure 500 {object} models.ErrorResponse
+// @Router /open-data/datasets/{id}/submissions.csv [get]
+func (dh *DatasetHandler) DownloadDataset(c *gin.Context) {
+	dataset, err := dh.getDatasetByID(c.Param("id"))
+	if err != nil || dataset.Status != "published" {
+		c.JSON(http.StatusNotFound, models.ErrorResponse{
+			Error:   "not_found",
+			Message: "Dataset not found",
+		})
+		return
+	}
+
+	ctx := dh.storageService.Context()
+	reader, err := dh.storageService.Bucket().Object(datasetSnapshotPath(dataset.ID)).NewReader(ctx)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, models.ErrorResponse{
+			Error:   "internal_error",
+			Message: "Failed to retrieve dataset records",
+		})
+		return
+	}
+	defer reader.Close()
+
+	c.Header("Content-Type", "text/csv")
+	c.Header("Content-Disposition", fmt.Sprintf("attachment; filename=dataset-%s.csv", dataset.ID))
+	c.Status(http.StatusOK)
+	if _, err := io.Copy(c.Writer, reader); err != nil {
+		log.Printf("Failed to stream dataset %s: %v", dataset.ID, err)
+	}
+}
+
+// Helper functions
+func (dh *DatasetHandler) getDatasetByID(datasetID string) (*models.Dataset, error) {
+	ctx := dh.firestoreService.Context()
+	doc, err := dh.firestoreService.Datasets().Doc(datasetID).Get(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	var dataset models.Dataset
+	err = doc.DataTo(&dataset)
+	if err != nil {
+		return nil, err
+	}
+
+	return &dataset, nil
+}
+
+// writeSnapshot writes the dataset records to storage as CSV and returns the number of rows
+func (dh *DatasetHandler) writeSnapshot(dataset *models.Dataset) (int, error) {
+	submissions, err := dh.datasetSubmissions(dataset)
+	if err != nil {
+		return 0, err
+	}
+
+	var buf bytes.Buffer
+	w := csv.NewWriter(&buf)
+	w.Write(columnNames(datasetExportColumns))
+	for _, s := range submissions {
+		w.Write(append([]string{
+			s.ID,
+			s.FieldID,
+			utils.FormatDate(s.Date),
+			s.GrowthStage,
+			strings.Join(s.PlantConditions, ";"),
+			strconv.FormatFloat(s.TraitMeasurements.CulmLength, 'f', -1, 64),
+			strconv.FormatFloat(s.TraitMeasurements.PanicleLength, 'f', -1, 64),
+			strconv.Itoa(s.TraitMeasurements.PaniclesPerHill),
+			strconv.Itoa(s.TraitMeasurements.HillsObserved),
+			s.HillID,
+		}, weatherColumns(s.Weather)...))
+	}
+	w.Flush()
+	if err := w.Error(); err != nil {
+		return 0, err
+	}
+
+	ctx := dh.storageService.Context()
+	wc := dh.storageService.Bucket().Object(datasetSnapshotPath(dataset.ID)).NewWriter(ctx)
+	wc.ContentType = "text/csv"
+	if _, err := io.Copy(wc, &buf); err != nil {
+		wc.Close()
+		return 0, err
+	}
+	if err := wc.Close(); err != nil {
+		return 0, err
+	}
+
+	return len(submissions), nil
+}
+
+// datasetSubmissions returns the approved submissions that fall within the dataset scope
+func (dh *DatasetHandler) datasetSubmissions(dataset *models.Dataset) ([]models.Submission, error) {
+	query := dh.firestoreService.Submissions().Where("status", "==", "approved")
+	if dataset.StartDate != "" {
+		start, _ := utils.ParseDate(dataset.StartDate)
+		query = query.Where("date", ">=", start)
+	}
+	if dataset.EndDate != "" {
+		end, _ := utils.ParseDate(dataset.EndDate)
+		query = query.Where("date", "<", end.AddDate(0, 0, 1))
+	}
+
+	if len(dataset.FieldIDs) == 0 {
+		return dh.collectSubmissions(query)
+	}
+
+	// Firestore caps "in" filters, so large field lists are queried in chunks
+	var submissions []models.Submission
+	for i := 0; i < len(dataset.FieldIDs); i += firestoreInLimit {
+		end := i + firestoreInLimit
+		if end > len(dataset.FieldIDs) {
+			end = len(dataset.FieldIDs)
+		}
+
+		chunk, err := dh.collectSubmissions(query.Where("field_id", "in", dataset.FieldIDs[i:end]))
+		if err != nil {
+			return nil, err
+		}
+		submissions = append(submissions, chunk...)
+	}
+
+	return submissions, nil
+}
+
+func (dh *DatasetHandler) collectSubmissions(query firestore.Query) ([]models.Submission, error) {
+	ctx := dh.firestoreService.Context()
+	iter := query.Documents(ctx)
+
+	var submissions []models.Submission
+	for {
+		doc, err := iter.Next()
+		if err == iterator.Done {
+			break
+		}
+		if err != nil {
+			return nil, err
+		}
+
+		var submission models.Submission
+		doc.DataTo(&submission)
+		submissions = append(submissions, submission)
+	}
+
+	return submissions, nil
+}
+
+func (dh *DatasetHandler) toDCATDataset(baseURL string, dataset *models.Dataset) map[string]interface{} {
+	entry := map[string]interface{}{
+		"@id":              baseURL + "/api/v1/open-data/datasets/" + dataset.ID,
+		"@type":            "dcat:Dataset",
+		"dct:identifier":   dataset.ID,
+		"dct:title":        dataset.Title,
+		"dct:description":  dataset.Description,
+		"dcat:keyword":     dataset.Keywords,
+		"dcat:recordCount": dataset.RecordCount,
+		"dct:license":      map[string]string{"@id": licenseURL(dataset.License)},
+		"dct:modified":     dataset.UpdatedAt.Format(time.RFC3339),
+		"dct:publisher": map[string]string{
+			"@type":     "foaf:Organization",
+			"foaf:name": dataset.Publisher,
+		},
+		"dcat:distribution": []map[string]string{
+			{
+				"@type":            "dcat:Distribution",
+				"dct:title":        dataset.Title + " (CSV)",
+				"dcat:downloadURL": dh.downloadURL(baseURL, dataset),
+				"dcat:mediaType":   "text/csv",
+				"dct:format":       "CSV",
+			},
+		},
+	}
+	if dataset.PublishedAt != nil {
+		entry["dct:issued"] = dataset.PublishedAt.Format(time.RFC3339)
+	}
+	if dataset.StartDate != "" || dataset.EndDate != "" {
+		entry["dct:temporal"] = map[string]string{
+			"@type":          "dct:PeriodOfTime",
+			"dcat:startDate": dataset.StartDate,
+			"dcat:endDate":   dataset.EndDate,
+		}
+	}
+
+	return entry
+}
+
+func (dh *DatasetHandler) toCKANPackage(baseURL string, dataset *models.Dataset) services.CKANPackage {
+	tags := []services.CKANTag{}
+	for _, keyword := range dataset.Keywords {
+		tags = append(tags, services.CKANTag{Name: keyword})
+	}
+
+	return services.CKANPackage{
+		ID:        dataset.CKANID,
+		Name:      "rice-monitor-" + dataset.ID,
+		Title:     dataset.Title,
+		Notes:     dataset.Description,
+		LicenseID: ckanLicenseID(dataset.License),
+		Tags:      tags,
+		Resources: []services.CKANResource{
+			{
+				Name:   dataset.Title + " (CSV)",
+				URL:    dh.downloadURL(baseURL, dataset),
+				Format: "CSV",
+			},
+		},
+	}
+}
+
+func (dh *DatasetHandler) downloadURL(baseURL string, dataset *models.Dataset) string {
+	return baseURL + "/api/v1/open-data/datasets/" + dataset.ID + "/submissions.csv"
+}
+
+// datasetSnapshotPath is the storage object holding the frozen CSV for a dataset
+func datasetSnapshotPath(datasetID string) string {
+	return "datasets/" + datasetID + "/submissions.csv"
+}
+
+// ckanLicenseID returns the CKAN license register ID for an SPDX identifier
+func ckanLicenseID(license string) string {
+	if known, ok := datasetLicenses[license]; ok {
+		return known.CKANID
+	}
+	return license
+}
+
+// licenseURL returns a URI for the license, as DCAT expects
+func licenseURL(license string) string {
+	if known, ok := datasetLicenses[license]; ok {
+		return known.URL
+	}
+	if strings.HasPrefix(license, "http://") || strings.HasPrefix(license, "https://") {
+		return license
+	}
+	return "https://spdx.org/licenses/" + license + ".html"
+}
+
+// publicBaseURL returns the externally reachable base URL used in links
+func publicBaseURL(c *gin.Context) string {
+	if baseURL := utils.GetEnvOrDefault("PUBLIC_BASE_URL", ""); baseURL != "" {
+		return strings.TrimSuffix(baseURL, "/")
+	}
+
+	scheme := "http"
+	if c.Request.TLS != nil || c.GetHeader("X-Forwarded-Proto") == "https" {
+		scheme = "https"
+	}
+	return scheme + "://" + c.Request.Host
+}
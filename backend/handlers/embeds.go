@@ -0,0 +1,477 @@
+package handlers
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"rice-monitor-api/models"
+	"rice-monitor-api/services"
+	"rice-monitor-api/utils"
+
+	"cloud.google.com/go/firestore"
+	"github.com/gin-gonic/gin"
+)
+
+// EmbedHandler issues embed tokens and serves the dashboard widgets partner
+// websites embed with them. Widgets only aggregate approved submissions, and
+// their filters come from the token, never from the request.
+type EmbedHandler struct {
+	firestoreService  *services.FirestoreService
+	vocabularyService *services.VocabularyService
+
+	mu    sync.Mutex
+	cache map[string]widgetCacheEntry
+}
+
+// widgetCacheTTL is how long a widget's numbers are served from memory, as
+// long as browsers are told to cache them
+const widgetCacheTTL = 5 * time.Minute
+
+// widgetCacheEntry is the data of one token's widget and when it was computed
+type widgetCacheEntry struct {
+	data     map[string]interface{}
+	loadedAt time.Time
+}
+
+func NewEmbedHandler(firestoreService *services.FirestoreService, vocabularyService *services.VocabularyService) *EmbedHandler {
+	return &EmbedHandler{
+		firestoreService:  firestoreService,
+		vocabularyService: vocabularyService,
+		cache:             make(map[string]widgetCacheEntry),
+	}
+}
+
+// @Summary List embed tokens
+// @Description List all dashboard widget embed tokens
+// @Tags embeds
+// @Produce  json
+// @Security ApiKeyAuth
+// @Success 200 {object} models.SuccessResponse
+// @Failure 500 {object} models.ErrorResponse
+// @Router /embed-tokens [get]
+func (eh *EmbedHandler) GetEmbedTokens(c *gin.Context) {
+	ctx := eh.firestoreService.Context()
+	docs, err := eh.firestoreService.EmbedTokens().OrderBy("created_at", firestore.Desc).Documents(ctx).GetAll()
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, models.ErrorResponse{
+			Error:   "internal_error",
+			Message: "Failed to retrieve embed tokens",
+		})
+		return
+	}
+
+	tokens := []models.EmbedToken{}
+	for _, doc := range docs {
+		var token models.EmbedToken
+		doc.DataTo(&token)
+		tokens = append(tokens, token)
+	}
+
+	c.JSON(http.StatusOK, models.SuccessResponse{
+		Success: true,
+		Data:    tokens,
+	})
+}
+
+// @Summary Create an embed token
+// @Description Create an expiring token that lets a partner website show one widget.
+// @Description Params: region (field location) or field_ids (comma separated), and days for daily_submissions.
+// @Tags embeds
+// @Accept  json
+// @Produce  json
+// @Security ApiKeyAuth
+// @Param token body models.CreateEmbedTokenRequest true "Embed token details"
+// @Success 201 {object} models.SuccessResponse
+// @Failure 400 {object} models.ErrorResponse
+// @Failure 500 {object} models.ErrorResponse
+// @Router /embed-tokens [post]
+func (eh *EmbedHandler) CreateEmbedToken(c *gin.Context) {
+	var req models.CreateEmbedTokenRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, models.ErrorResponse{
+			Error:   "invalid_request",
+			Message: err.Error(),
+		})
+		return
+	}
+
+	if days, ok := req.Params["days"]; ok {
+		if n, err := strconv.Atoi(days); err != nil || n < 1 || n > 365 {
+			c.JSON(http.StatusBadRequest, models.ErrorResponse{
+				Error:   "invalid_request",
+				Message: "days must be between 1 and 365",
+			})
+			return
+		}
+	}
+
+	currentUser, _ := c.Get("user")
+	user := currentUser.(*models.User)
+
+	rawToken, err := utils.GenerateSecureToken(32)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, models.ErrorResponse{
+			Error:   "internal_error",
+			Message: "Failed to generate embed token",
+		})
+		return
+	}
+	rawToken = utils.EmbedTokenPrefix + rawToken
+
+	if req.Params == nil {
+		req.Params = map[string]string{}
+	}
+
+	token := models.EmbedToken{
+		ID:             utils.GenerateID(),
+		Name:           req.Name,
+		Widget:         req.Widget,
+		Params:         req.Params,
+		AllowedOrigins: req.AllowedOrigins,
+		Prefix:         rawToken[:len(utils.EmbedTokenPrefix)+8],
+		TokenHash:      utils.HashToken(rawToken),
+		OrgID:          user.OrgID,
+		CreatedBy:      user.ID,
+		CreatedAt:      time.Now(),
+		ExpiresAt:      time.Now().AddDate(0, 0, req.ExpiresInDays),
+	}
+
+	ctx := eh.firestoreService.Context()
+	_, err = eh.firestoreService.EmbedTokens().Doc(token.ID).Set(ctx, token)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, models.ErrorResponse{
+			Error:   "internal_error",
+			Message: "Failed to create embed token",
+		})
+		return
+	}
+
+	c.JSON(http.StatusCreated, models.SuccessResponse{
+		Success: true,
+		Data: models.CreateEmbedTokenResponse{
+			EmbedToken: token,
+			Token:      rawToken,
+		},
+		Message: "Embed token created successfully. Store it now, it will not be shown again",
+	})
+}
+
+// @Summary Revoke an embed token
+// @Description Revoke an embed token by its ID
+// @Tags embeds
+// @Produce  json
+// @Security ApiKeyAuth
+// @Param id path string true "Embed token ID"
+// @Success 200 {object} models.SuccessResponse
+// @Failure 404 {object} models.ErrorResponse
+// @Failure 409 {object} models.ErrorResponse
+// @Failure 500 {object} models.ErrorResponse
+// @Router /embed-tokens/{id} [delete]
+func (eh *EmbedHandler) RevokeEmbedToken(c *gin.Context) {
+	tokenID := c.Param("id")
+
+	ctx := eh.firestoreService.Context()
+	doc, err := eh.firestoreService.EmbedTokens().Doc(tokenID).Get(ctx)
+	if err != nil {
+		c.JSON(http.StatusNotFound, models.ErrorResponse{
+			Error:   "not_found",
+			Message: "Embed token not found",
+		})
+		return
+	}
+
+	var token models.EmbedToken
+	doc.DataTo(&token)
+
+	if token.Revoked {
+		c.JSON(http.StatusConflict, models.ErrorResponse{
+			Error:   "already_revoked",
+			Message: "Embed token is already revoked",
+		})
+		return
+	}
+
+	_, err = eh.firestoreService.EmbedTokens().Doc(tokenID).Update(ctx, []firestore.Update{
+		{Path: "revoked", Value: true},
+	})
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, models.ErrorResponse{
+			Error:   "internal_error",
+			Message: "Failed to revoke embed token",
+		})
+		return
+	}
+
+	c.JSON(http.StatusOK, models.SuccessResponse{
+		Success: true,
+		Message: "Embed token revoked successfully",
+	})
+}
+
+// @Summary Embedded widget data
+// @Description Public JSON for an embedded dashboard widget. The token must be valid for the requested widget.
+// @Description Widgets only count the submissions of the token creator's organization, and are refreshed every 5 minutes.
+// @Tags embeds
+// @Produce  json
+// @Param widget path string true "Widget name (stage_distribution, daily_submissions)"
+// @Param token query string true "Embed token"
+// @Success 200 {object} models.SuccessResponse
+// @Failure 401 {object} models.ErrorResponse
+// @Failure 403 {object} models.ErrorResponse
+// @Failure 500 {object} models.ErrorResponse
+// @Router /embed/widgets/{widget} [get]
+func (eh *EmbedHandler) GetWidget(c *gin.Context) {
+	token, err := eh.getEmbedToken(c.Query("token"))
+	if err != nil || token.Revoked || time.Now().After(token.ExpiresAt) {
+		c.JSON(http.StatusUnauthorized, models.ErrorResponse{
+			Error:   "invalid_token",
+			Message: "Invalid or expired embed token",
+		})
+		return
+	}
+
+	if token.Widget != c.Param("widget") {
+		c.JSON(http.StatusForbidden, models.ErrorResponse{
+			Error:   "forbidden",
+			Message: "Embed token is not valid for this widget",
+		})
+		return
+	}
+
+	// Embed routes skip the global CORS policy; the token decides which sites may read them
+	origin := c.GetHeader("Origin")
+	if origin != "" {
+		if len(token.AllowedOrigins) > 0 && !utils.Contains(token.AllowedOrigins, origin) {
+			c.JSON(http.StatusForbidden, models.ErrorResponse{
+				Error:   "forbidden",
+				Message: "Origin is not allowed for this embed token",
+			})
+			return
+		}
+		c.Header("Access-Control-Allow-Origin", origin)
+		c.Header("Vary", "Origin")
+	}
+
+	data, err := eh.widgetData(token)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, models.ErrorResponse{
+			Error:   "internal_error",
+			Message: "Failed to load widget data",
+		})
+		return
+	}
+
+	c.Header("Cache-Control", "public, max-age=300")
+	c.JSON(http.StatusOK, models.SuccessResponse{
+		Success: true,
+		Data:    data,
+	})
+}
+
+// Helper functions
+func (eh *EmbedHandler) getEmbedToken(rawToken string) (*models.EmbedToken, error) {
+	if !strings.HasPrefix(rawToken, utils.EmbedTokenPrefix) {
+		return nil, fmt.Errorf("invalid embed token")
+	}
+
+	ctx := eh.firestoreService.Context()
+	docs, err := eh.firestoreService.EmbedTokens().
+		Where("token_hash", "==", utils.HashToken(rawToken)).
+		Limit(1).
+		Documents(ctx).GetAll()
+	if err != nil {
+		return nil, err
+	}
+	if len(docs) == 0 {
+		return nil, fmt.Errorf("embed token not found")
+	}
+
+	var token models.EmbedToken
+	if err := docs[0].DataTo(&token); err != nil {
+		return nil, err
+	}
+
+	return &token, nil
+}
+
+// widgetData returns the widget's numbers, computed at most once per
+// widgetCacheTTL for each token
+func (eh *EmbedHandler) widgetData(token *models.EmbedToken) (map[string]interface{}, error) {
+	eh.mu.Lock()
+	defer eh.mu.Unlock()
+	if cached, ok := eh.cache[token.ID]; ok && time.Since(cached.loadedAt) < widgetCacheTTL {
+		return cached.data, nil
+	}
+
+	data, err := eh.computeWidgetData(token)
+	if err != nil {
+		return nil, err
+	}
+	for id, cached := range eh.cache {
+		if time.Since(cached.loadedAt) >= widgetCacheTTL {
+			delete(eh.cache, id)
+		}
+	}
+	eh.cache[token.ID] = widgetCacheEntry{data: data, loadedAt: time.Now()}
+	return data, nil
+}
+
+// computeWidgetData counts the approved submissions in scope for the token:
+// the token owner's organization, the fields named by field_ids or located in
+// region, and the last days days
+func (eh *EmbedHandler) computeWidgetData(token *models.EmbedToken) (map[string]interface{}, error) {
+	ctx := eh.firestoreService.Context()
+
+	orgID, err := eh.tokenOrgID(token)
+	if err != nil {
+		return nil, err
+	}
+
+	query := eh.firestoreService.Submissions().Where("status", "==", "approved")
+	fieldsQuery := eh.firestoreService.Fields().Query
+	if orgID != "" {
+		query = query.Where("org_id", "==", orgID)
+		fieldsQuery = fieldsQuery.Where("org_id", "==", orgID)
+	}
+
+	queries := []firestore.Query{query}
+	var fieldIDs []string
+	if ids := token.Params["field_ids"]; ids != "" {
+		for _, id := range strings.Split(ids, ",") {
+			if id = strings.TrimSpace(id); id != "" {
+				fieldIDs = append(fieldIDs, id)
+			}
+		}
+	} else if region := token.Params["region"]; region != "" {
+		docs, err := fieldsQuery.Where("location", "==", region).Select().Documents(ctx).GetAll()
+		if err != nil {
+			return nil, err
+		}
+		for _, doc := range docs {
+			fieldIDs = append(fieldIDs, doc.Ref.ID)
+		}
+		if len(fieldIDs) == 0 {
+			queries = nil
+		}
+	}
+
+	// Firestore caps "in" filters, so large field lists are counted in chunks
+	if len(fieldIDs) > 0 {
+		queries = nil
+		for i := 0; i < len(fieldIDs); i += firestoreInLimit {
+			end := i + firestoreInLimit
+			if end > len(fieldIDs) {
+				end = len(fieldIDs)
+			}
+			queries = append(queries, query.Where("field_id", "in", fieldIDs[i:end]))
+		}
+	}
+
+	data := map[string]interface{}{
+		"widget":     token.Widget,
+		"params":     token.Params,
+		"updated_at": time.Now(),
+	}
+
+	var total int64
+	switch token.Widget {
+	case "stage_distribution":
+		stages := make(map[string]int)
+		for _, query := range queries {
+			count, err := countDocuments(ctx, query)
+			if err != nil {
+				return nil, err
+			}
+			total += count
+
+			byStage, err := countByValue(ctx, query, "growth_stage", eh.vocabularyService.GrowthStages())
+			if err != nil {
+				return nil, err
+			}
+			for stage, count := range byStage {
+				stages[stage] += count
+			}
+		}
+		data["stage_distribution"] = stages
+	case "daily_submissions":
+		days, err := strconv.Atoi(token.Params["days"])
+		if err != nil {
+			days = 30
+		}
+		daily := make(map[string]int)
+		for _, query := range queries {
+			byDay, err := countByDay(ctx, query, days)
+			if err != nil {
+				return nil, err
+			}
+			for day, count := range byDay {
+				daily[day] += count
+				total += int64(count)
+			}
+		}
+		data["daily_submissions"] = daily
+	}
+	data["total"] = total
+
+	return data, nil
+}
+
+// tokenOrgID returns the organization whose submissions the token shows.
+// Tokens created before they recorded it fall back to their creator's.
+func (eh *EmbedHandler) tokenOrgID(token *models.EmbedToken) (string, error) {
+	if token.OrgID != "" || token.CreatedBy == "" {
+		return token.OrgID, nil
+	}
+
+	ctx := eh.firestoreService.Context()
+	doc, err := eh.firestoreService.Users().Doc(token.CreatedBy).Get(ctx)
+	if err != nil {
+		return "", err
+	}
+	var creator models.User
+	if err := doc.DataTo(&creator); err != nil {
+		return "", err
+	}
+	return creator.OrgID, nil
+}
+
+// countByDay counts the documents of query dated on each of the last days UTC
+// days, keyed by date. Days without documents are left out.
+func countByDay(ctx context.Context, query firestore.Query, days int) (map[string]int, error) {
+	today := time.Now().UTC().Truncate(24 * time.Hour)
+	counts := make([]int64, days)
+	errs := make([]error, days)
+
+	// Bound the aggregation queries in flight for long ranges
+	sem := make(chan struct{}, 10)
+	var wg sync.WaitGroup
+	for i := 0; i < days; i++ {
+		wg.Add(1)
+		go func(i int) {
+			defer wg.Done()
+			sem <- struct{}{}
+			defer func() { <-sem }()
+			start := today.AddDate(0, 0, -i)
+			counts[i], errs[i] = countDocuments(ctx, query.
+				Where("date", ">=", start).
+				Where("date", "<", start.AddDate(0, 0, 1)))
+		}(i)
+	}
+	wg.Wait()
+
+	byDay := make(map[string]int)
+	for i := 0; i < days; i++ {
+		if errs[i] != nil {
+			return nil, errs[i]
+		}
+		if counts[i] > 0 {
+			byDay[utils.FormatDate(today.AddDate(0, 0, -i))] = int(counts[i])
+		}
+	}
+	return byDay, nil
+}
@@ -0,0 +1,157 @@
+package handlers
+
+import (
+	"bytes"
+	"encoding/csv"
+	"fmt"
+	"net/http"
+	"sort"
+	"strconv"
+	"strings"
+
+	"rice-monitor-api/models"
+	"rice-monitor-api/utils"
+
+	"github.com/gin-gonic/gin"
+)
+
+// weatherExportColumns describes the weatherColumns of an export
+var weatherExportColumns = []models.ExportColumn{
+	{Name: "Temperature (C)", Type: "number", Unit: "C", Description: "Air temperature at observation time, empty when unknown"},
+	{Name: "Humidity (%)", Type: "number", Unit: "%", Description: "Relative humidity at observation time, empty when unknown"},
+	{Name: "Rainfall (mm)", Type: "number", Unit: "mm", Description: "Rainfall at observation time, empty when unknown"},
+	{Name: "Rainfall 24h (mm)", Type: "number", Unit: "mm", Description: "Rainfall in the 24 hours up to the observation, empty when unknown"},
+	{Name: "Weather Source", Type: "string", Description: "device when reported by the app, open-meteo when looked up by the server"},
+}
+
+// submissionExportColumns describes the columns of the submissions export
+var submissionExportColumns = append(append(append(append(append([]models.ExportColumn{
+	{Name: "ID", Type: "string", Description: "Submission ID"},
+	{Name: "Field ID", Type: "string", Description: "ID of the monitored field"},
+	{Name: "Field Name", Type: "string", Description: "Name of the monitored field"},
+	{Name: "Date", Type: "date", Description: "Observation date (YYYY-MM-DD) in the exporting user's timezone"},
+	{Name: "Growth Stage", Type: "string", Description: "Growth stage code, see the growth_stages vocabulary"},
+	{Name: "Plant Conditions", Type: "string", Description: "Semicolon separated plant condition codes, see the plant_conditions vocabulary"},
+}, traitExportColumns()...), []models.ExportColumn{
+	{Name: "Hill ID", Type: "string", Description: "Marked hill or quadrat measured, empty when not recorded"},
+	{Name: "Notes", Type: "string", Description: "Observer's notes"},
+	{Name: "Observer", Type: "string", Description: "Name of the observer"},
+	{Name: "Observer ID", Type: "string", Description: "User ID of the observer"},
+	{Name: "Status", Type: "string", Description: "Review status, see the statuses vocabulary"},
+	{Name: "Source", Type: "string", Description: "app, email or sms"},
+	{Name: "Provenance", Type: "string", Description: "research or community"},
+	{Name: "Latitude", Type: "number", Description: "GPS latitude recorded with the observation, empty when not captured"},
+	{Name: "Longitude", Type: "number", Description: "GPS longitude recorded with the observation, empty when not captured"},
+	{Name: "GPS Accuracy (m)", Type: "number", Description: "Accuracy radius of the GPS position in meters, empty when unknown"},
+	{Name: "Created At", Type: "datetime", Description: "When the submission was created (RFC 3339) in the exporting user's timezone"},
+}...), weatherExportColumns...),
+	models.ExportColumn{Name: "Custom Traits", Type: "string", Description: "Semicolon separated key=value pairs of the deployment's custom traits, see GET /traits"},
+), replicateSummaryColumns...)
+
+// datasetExportColumns describes the columns of a published dataset. Observer
+// identity is left out on purpose, the release is public.
+var datasetExportColumns = append(append([]models.ExportColumn{
+	{Name: "ID", Type: "string", Description: "Submission ID"},
+	{Name: "Field ID", Type: "string", Description: "ID of the monitored field"},
+	{Name: "Date", Type: "date", Description: "Observation date (YYYY-MM-DD)"},
+	{Name: "Growth Stage", Type: "string", Description: "Growth stage code, see the growth_stages vocabulary"},
+	{Name: "Plant Conditions", Type: "string", Description: "Semicolon separated plant condition codes, see the plant_conditions vocabulary"},
+}, traitExportColumns()...), append([]models.ExportColumn{
+	{Name: "Hill ID", Type: "string", Description: "Marked hill or quadrat measured, empty when not recorded"},
+}, weatherExportColumns...)...)
+
+// customTraitsColumn formats custom trait values as key=value pairs ordered by key
+func customTraitsColumn(custom map[string]float64) string {
+	pairs := make([]string, 0, len(custom))
+	for key, value := range custom {
+		pairs = append(pairs, key+"="+strconv.FormatFloat(value, 'f', -1, 64))
+	}
+	sort.Strings(pairs)
+	return strings.Join(pairs, ";")
+}
+
+// traitExportColumns describes one column per trait of the trait registry
+func traitExportColumns() []models.ExportColumn {
+	columns := make([]models.ExportColumn, len(utils.Traits))
+	for i, trait := range utils.Traits {
+		columns[i] = models.ExportColumn{Name: trait.Label, Type: trait.Type, Unit: trait.Unit, Description: trait.Description}
+	}
+	return columns
+}
+
+func columnNames(columns []models.ExportColumn) []string {
+	names := make([]string, len(columns))
+	for i, column := range columns {
+		names[i] = column.Name
+	}
+	return names
+}
+
+// weatherColumns formats a submission's weather snapshot, leaving unknown values empty
+func weatherColumns(weather *models.WeatherSnapshot) []string {
+	if weather == nil {
+		return []string{"", "", "", "", ""}
+	}
+	return []string{
+		formatOptional(weather.TemperatureC),
+		formatOptional(weather.HumidityPct),
+		formatOptional(weather.RainfallMM),
+		formatOptional(weather.Rainfall24hMM),
+		weather.Source,
+	}
+}
+
+func formatOptional(value *float64) string {
+	if value == nil {
+		return ""
+	}
+	return strconv.FormatFloat(*value, 'f', -1, 64)
+}
+
+// exportFormat reads the format query parameter of an export, csv by default.
+// It responds with 400 and returns false for other formats.
+func exportFormat(c *gin.Context) (string, bool) {
+	format := c.DefaultQuery("format", "csv")
+	if format != "csv" && format != "xlsx" {
+		c.JSON(http.StatusBadRequest, models.ErrorResponse{
+			Error:   "invalid_format",
+			Message: "format must be csv or xlsx",
+		})
+		return "", false
+	}
+	return format, true
+}
+
+// writeExport sends the header and rows as an attachment named name.csv or
+// name.xlsx
+func writeExport(c *gin.Context, format, name string, header []string, rows [][]string) {
+	var buf bytes.Buffer
+	contentType := "text/csv"
+
+	if format == "xlsx" {
+		contentType = "application/vnd.openxmlformats-officedocument.spreadsheetml.sheet"
+		if err := utils.WriteXLSX(&buf, name, append([][]string{header}, rows...)); err != nil {
+			c.JSON(http.StatusInternalServerError, models.ErrorResponse{
+				Error:   "internal_error",
+				Message: "Failed to build export",
+			})
+			return
+		}
+	} else {
+		w := csv.NewWriter(&buf)
+		w.Write(header)
+		w.WriteAll(rows)
+	}
+
+	stampAgreement(c)
+	c.Header("Content-Disposition", fmt.Sprintf("attachment; filename=%s.%s", name, format))
+	c.Data(http.StatusOK, contentType, buf.Bytes())
+}
+
+// stampAgreement names the data access agreement an external collaborator
+// accepted on their export, as set by AuthMiddleware.RequireAgreement
+func stampAgreement(c *gin.Context) {
+	if agreementID := c.GetString("agreement_id"); agreementID != "" {
+		c.Header("X-Data-Agreement", agreementID)
+	}
+}
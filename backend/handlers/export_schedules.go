@@ -0,0 +1,319 @@
+package handlers
+
+import (
+	"bytes"
+	"encoding/csv"
+	"net/http"
+	"path"
+	"strconv"
+	"strings"
+	"time"
+
+	"rice-monitor-api/models"
+	"rice-monitor-api/services"
+	"rice-monitor-api/utils"
+
+	"cloud.google.com/go/firestore"
+	"github.com/gin-gonic/gin"
+	"google.golang.org/api/iterator"
+)
+
+// maxExportSchedules is how many scheduled exports one user may define
+const maxExportSchedules = 10
+
+type ExportScheduleHandler struct {
+	firestoreService *services.FirestoreService
+}
+
+func NewExportScheduleHandler(firestoreService *services.FirestoreService) *ExportScheduleHandler {
+	return &ExportScheduleHandler{
+		firestoreService: firestoreService,
+	}
+}
+
+// @Summary List scheduled exports
+// @Description List the current user's scheduled submissions exports
+// @Tags exports
+// @Produce  json
+// @Security ApiKeyAuth
+// @Success 200 {object} models.SuccessResponse
+// @Failure 500 {object} models.ErrorResponse
+// @Router /exports/schedules [get]
+func (eh *ExportScheduleHandler) GetExportSchedules(c *gin.Context) {
+	currentUser, _ := c.Get("user")
+	user := currentUser.(*models.User)
+
+	ctx := eh.firestoreService.Context()
+	docs, err := eh.firestoreService.ExportSchedules().Where("user_id", "==", user.ID).Documents(ctx).GetAll()
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, models.ErrorResponse{
+			Error:   "internal_error",
+			Message: "Failed to retrieve scheduled exports",
+		})
+		return
+	}
+
+	schedules := []models.ExportSchedule{}
+	for _, doc := range docs {
+		var schedule models.ExportSchedule
+		doc.DataTo(&schedule)
+		schedules = append(schedules, schedule)
+	}
+
+	c.JSON(http.StatusOK, models.SuccessResponse{
+		Success: true,
+		Data:    schedules,
+	})
+}
+
+// @Summary Schedule an export
+// @Description Export the submissions created each week (run on Mondays) or month (run on the 1st), at midnight UTC.
+// @Description The file is written to the bucket under scheduled-exports/{user_id}/{path}, or emailed to the
+// @Description recipients (the current user by default) as a signed link. Filters work as on GET /submissions/export
+// @Description and the export sees what the current user can see at the time it runs.
+// @Tags exports
+// @Accept  json
+// @Produce  json
+// @Security ApiKeyAuth
+// @Param schedule body models.CreateExportScheduleRequest true "Scheduled export"
+// @Success 201 {object} models.SuccessResponse
+// @Failure 400 {object} models.ErrorResponse
+// @Failure 409 {object} models.ErrorResponse
+// @Failure 500 {object} models.ErrorResponse
+// @Router /exports/schedules [post]
+func (eh *ExportScheduleHandler) CreateExportSchedule(c *gin.Context) {
+	var req models.CreateExportScheduleRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, models.ErrorResponse{
+			Error:   "invalid_request",
+			Message: err.Error(),
+		})
+		return
+	}
+
+	currentUser, _ := c.Get("user")
+	user := currentUser.(*models.User)
+
+	folder := ""
+	if req.Path != "" {
+		folder = path.Clean(strings.Trim(req.Path, "/"))
+		if folder == "." || folder == ".." || strings.HasPrefix(folder, "../") {
+			c.JSON(http.StatusBadRequest, models.ErrorResponse{
+				Error:   "invalid_path",
+				Message: "path must be a folder name such as weekly/field-a",
+			})
+			return
+		}
+	}
+	if req.Format == "" {
+		req.Format = "csv"
+	}
+
+	ctx := eh.firestoreService.Context()
+	count, err := countDocuments(ctx, eh.firestoreService.ExportSchedules().Where("user_id", "==", user.ID))
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, models.ErrorResponse{
+			Error:   "internal_error",
+			Message: "Failed to create scheduled export",
+		})
+		return
+	}
+	if count >= maxExportSchedules {
+		c.JSON(http.StatusConflict, models.ErrorResponse{
+			Error:   "too_many_schedules",
+			Message: "You can have at most " + strconv.Itoa(maxExportSchedules) + " scheduled exports",
+		})
+		return
+	}
+
+	now := time.Now()
+	schedule := models.ExportSchedule{
+		ID:        utils.GenerateID(),
+		UserID:    user.ID,
+		OrgID:     user.OrgID,
+		Name:      req.Name,
+		Format:    req.Format,
+		Frequency: req.Frequency,
+		Delivery:  req.Delivery,
+		Filters:   req.Filters,
+		Active:    true,
+		CreatedAt: now,
+		UpdatedAt: now,
+	}
+	if req.Delivery == "gcs" {
+		schedule.Path = folder
+	} else {
+		schedule.Recipients = req.Recipients
+	}
+
+	if _, err := eh.firestoreService.ExportSchedules().Doc(schedule.ID).Set(ctx, schedule); err != nil {
+		c.JSON(http.StatusInternalServerError, models.ErrorResponse{
+			Error:   "internal_error",
+			Message: "Failed to create scheduled export",
+		})
+		return
+	}
+
+	c.JSON(http.StatusCreated, models.SuccessResponse{
+		Success: true,
+		Data:    schedule,
+		Message: "Scheduled export created successfully",
+	})
+}
+
+// @Summary Delete a scheduled export
+// @Description Stop a scheduled export. Files already delivered and the record of past runs are kept.
+// @Tags exports
+// @Produce  json
+// @Security ApiKeyAuth
+// @Param id path string true "Scheduled export ID"
+// @Success 200 {object} models.SuccessResponse
+// @Failure 404 {object} models.ErrorResponse
+// @Failure 500 {object} models.ErrorResponse
+// @Router /exports/schedules/{id} [delete]
+func (eh *ExportScheduleHandler) DeleteExportSchedule(c *gin.Context) {
+	currentUser, _ := c.Get("user")
+	user := currentUser.(*models.User)
+
+	ctx := eh.firestoreService.Context()
+	ref := eh.firestoreService.ExportSchedules().Doc(c.Param("id"))
+	doc, err := ref.Get(ctx)
+	if err != nil {
+		c.JSON(http.StatusNotFound, models.ErrorResponse{
+			Error:   "not_found",
+			Message: "Scheduled export not found",
+		})
+		return
+	}
+
+	var schedule models.ExportSchedule
+	doc.DataTo(&schedule)
+	if schedule.UserID != user.ID {
+		// Other users' schedules are reported as missing rather than forbidden
+		c.JSON(http.StatusNotFound, models.ErrorResponse{
+			Error:   "not_found",
+			Message: "Scheduled export not found",
+		})
+		return
+	}
+
+	if _, err := ref.Delete(ctx); err != nil {
+		c.JSON(http.StatusInternalServerError, models.ErrorResponse{
+			Error:   "internal_error",
+			Message: "Failed to delete scheduled export",
+		})
+		return
+	}
+
+	c.JSON(http.StatusOK, models.SuccessResponse{
+		Success: true,
+		Message: "Scheduled export deleted successfully",
+	})
+}
+
+// @Summary List export runs
+// @Description List past runs of the current user's scheduled exports, newest first, with where each file was delivered
+// @Tags exports
+// @Produce  json
+// @Security ApiKeyAuth
+// @Param schedule_id query string false "Only runs of this scheduled export"
+// @Param limit query int false "Maximum runs returned (default 50, max 100)"
+// @Success 200 {object} models.SuccessResponse
+// @Failure 500 {object} models.ErrorResponse
+// @Router /exports [get]
+func (eh *ExportScheduleHandler) GetExportRuns(c *gin.Context) {
+	currentUser, _ := c.Get("user")
+	user := currentUser.(*models.User)
+
+	limit, err := strconv.Atoi(c.DefaultQuery("limit", "50"))
+	if err != nil || limit <= 0 {
+		limit = 50
+	}
+	limit = min(limit, 100)
+
+	query := eh.firestoreService.ExportRuns().Where("user_id", "==", user.ID)
+	if scheduleID := c.Query("schedule_id"); scheduleID != "" {
+		query = query.Where("schedule_id", "==", scheduleID)
+	}
+
+	ctx := eh.firestoreService.Context()
+	docs, err := query.OrderBy("created_at", firestore.Desc).Limit(limit).Documents(ctx).GetAll()
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, models.ErrorResponse{
+			Error:   "internal_error",
+			Message: "Failed to retrieve exports",
+		})
+		return
+	}
+
+	runs := []models.ExportRun{}
+	for _, doc := range docs {
+		var run models.ExportRun
+		doc.DataTo(&run)
+		runs = append(runs, run)
+	}
+
+	c.JSON(http.StatusOK, models.SuccessResponse{
+		Success: true,
+		Data:    runs,
+	})
+}
+
+// ScheduledExport builds the file of a scheduled export as a services.ExportFunc:
+// the submissions user may export matching the schedule's filters that were
+// created between from and to, oldest first
+func (sh *SubmissionHandler) ScheduledExport(user *models.User, schedule *models.ExportSchedule, from, to time.Time) ([]byte, int, error) {
+	query, err := sh.submissionListQuery(user, models.SubmissionListParams{
+		Status:         schedule.Filters.Status,
+		FieldID:        schedule.Filters.FieldID,
+		FarmID:         schedule.Filters.FarmID,
+		SeasonID:       schedule.Filters.SeasonID,
+		GrowthStage:    schedule.Filters.GrowthStage,
+		PlantCondition: schedule.Filters.PlantCondition,
+		Observer:       schedule.Filters.Observer,
+		Tag:            schedule.Filters.Tag,
+		CreatedFrom:    from.Format(time.RFC3339),
+		CreatedTo:      to.Format(time.RFC3339),
+		Order:          "asc",
+	})
+	if err != nil {
+		return nil, 0, err
+	}
+
+	var submissions []models.Submission
+	iter := query.Documents(sh.firestoreService.Context())
+	defer iter.Stop()
+	for {
+		doc, err := iter.Next()
+		if err == iterator.Done {
+			break
+		}
+		if err != nil {
+			return nil, 0, err
+		}
+		var submission models.Submission
+		doc.DataTo(&submission)
+		submissions = append(submissions, submission)
+	}
+
+	loc := utils.UserLocation(user)
+	var buf bytes.Buffer
+	if schedule.Format == "geojson" {
+		err := sh.encodeSubmissionsGeoJSON(&buf, submissions, loc)
+		return buf.Bytes(), len(submissions), err
+	}
+
+	fieldName := sh.fieldNames()
+	rows := [][]string{columnNames(submissionExportColumns)}
+	for _, s := range submissions {
+		rows = append(rows, submissionExportRow(s, fieldName(s.FieldID), loc))
+	}
+	if schedule.Format == "xlsx" {
+		err := utils.WriteXLSX(&buf, "submissions", rows)
+		return buf.Bytes(), len(submissions), err
+	}
+
+	w := csv.NewWriter(&buf)
+	w.WriteAll(rows)
+	return buf.Bytes(), len(submissions), w.Error()
+}
@@ -0,0 +1,399 @@
+package handlers
+
+import (
+	"errors"
+	"net/http"
+	"time"
+
+	"rice-monitor-api/models"
+	"rice-monitor-api/services"
+	"rice-monitor-api/utils"
+
+	"cloud.google.com/go/firestore"
+	"github.com/gin-gonic/gin"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+)
+
+type FarmHandler struct {
+	firestoreService *services.FirestoreService
+}
+
+func NewFarmHandler(firestoreService *services.FirestoreService) *FarmHandler {
+	return &FarmHandler{
+		firestoreService: firestoreService,
+	}
+}
+
+// @Summary List farms
+// @Description List the farms fields are grouped under, by name. Members of an organization only see its farms.
+// @Tags farms
+// @Produce  json
+// @Security ApiKeyAuth
+// @Success 200 {object} models.SuccessResponse
+// @Failure 500 {object} models.ErrorResponse
+// @Router /farms [get]
+func (fh *FarmHandler) GetFarms(c *gin.Context) {
+	currentUser, _ := c.Get("user")
+	user := currentUser.(*models.User)
+
+	ctx := fh.firestoreService.Context()
+	docs, err := orgScope(fh.firestoreService.Farms().Query, user).OrderBy("name", firestore.Asc).Documents(ctx).GetAll()
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, models.ErrorResponse{
+			Error:   "internal_error",
+			Message: "Failed to retrieve farms",
+		})
+		return
+	}
+
+	farms := []models.Farm{}
+	for _, doc := range docs {
+		var farm models.Farm
+		doc.DataTo(&farm)
+		farms = append(farms, farm)
+	}
+
+	c.JSON(http.StatusOK, models.SuccessResponse{
+		Success: true,
+		Data:    farms,
+	})
+}
+
+// @Summary Create a farm
+// @Description Create a site to group fields under, in your organization
+// @Tags farms
+// @Accept  json
+// @Produce  json
+// @Security ApiKeyAuth
+// @Param farm body models.FarmRequest true "Farm details"
+// @Success 201 {object} models.SuccessResponse
+// @Failure 400 {object} models.ErrorResponse
+// @Failure 500 {object} models.ErrorResponse
+// @Router /farms [post]
+func (fh *FarmHandler) CreateFarm(c *gin.Context) {
+	var req models.FarmRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, models.ErrorResponse{
+			Error:   "invalid_request",
+			Message: err.Error(),
+		})
+		return
+	}
+
+	currentUser, _ := c.Get("user")
+	user := currentUser.(*models.User)
+
+	if !fh.checkManager(c, req.ManagerID, user.OrgID) {
+		return
+	}
+
+	farm := models.Farm{
+		ID:        utils.GenerateID(),
+		Name:      req.Name,
+		Region:    req.Region,
+		ManagerID: req.ManagerID,
+		OrgID:     user.OrgID,
+		CreatedBy: user.ID,
+		CreatedAt: time.Now(),
+		UpdatedAt: time.Now(),
+	}
+
+	ctx := fh.firestoreService.Context()
+	if _, err := fh.firestoreService.Farms().Doc(farm.ID).Set(ctx, farm); err != nil {
+		c.JSON(http.StatusInternalServerError, models.ErrorResponse{
+			Error:   "internal_error",
+			Message: "Failed to create farm",
+		})
+		return
+	}
+
+	c.JSON(http.StatusCreated, models.SuccessResponse{
+		Success: true,
+		Data:    farm,
+		Message: "Farm created successfully",
+	})
+}
+
+// @Summary Get a farm
+// @Description Get a farm with its fields
+// @Tags farms
+// @Produce  json
+// @Security ApiKeyAuth
+// @Param id path string true "Farm ID"
+// @Success 200 {object} models.SuccessResponse
+// @Failure 404 {object} models.ErrorResponse
+// @Failure 500 {object} models.ErrorResponse
+// @Router /farms/{id} [get]
+func (fh *FarmHandler) GetFarm(c *gin.Context) {
+	currentUser, _ := c.Get("user")
+	user := currentUser.(*models.User)
+
+	farm, ok := fh.visibleFarm(c, user)
+	if !ok {
+		return
+	}
+
+	ctx := fh.firestoreService.Context()
+	docs, err := fh.firestoreService.Fields().Where("farm_id", "==", farm.ID).Documents(ctx).GetAll()
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, models.ErrorResponse{
+			Error:   "internal_error",
+			Message: "Failed to retrieve the farm's fields",
+		})
+		return
+	}
+
+	farm.Fields = []models.Field{}
+	for _, doc := range docs {
+		var field models.Field
+		doc.DataTo(&field)
+		if canReadField(user, &field) {
+			field.BoundaryGeoJSON = utils.GeoJSONPolygonOf(field.Boundary)
+			farm.Fields = append(farm.Fields, field)
+		}
+	}
+
+	c.JSON(http.StatusOK, models.SuccessResponse{
+		Success: true,
+		Data:    farm,
+	})
+}
+
+// @Summary Update a farm
+// @Description Rename a farm or change its region or manager. Its manager can update it too.
+// @Tags farms
+// @Accept  json
+// @Produce  json
+// @Security ApiKeyAuth
+// @Param id path string true "Farm ID"
+// @Param farm body models.FarmRequest true "Farm details"
+// @Success 200 {object} models.SuccessResponse
+// @Failure 400 {object} models.ErrorResponse
+// @Failure 403 {object} models.ErrorResponse
+// @Failure 404 {object} models.ErrorResponse
+// @Failure 500 {object} models.ErrorResponse
+// @Router /farms/{id} [put]
+func (fh *FarmHandler) UpdateFarm(c *gin.Context) {
+	var req models.FarmRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, models.ErrorResponse{
+			Error:   "invalid_request",
+			Message: err.Error(),
+		})
+		return
+	}
+
+	currentUser, _ := c.Get("user")
+	user := currentUser.(*models.User)
+
+	farm, ok := fh.visibleFarm(c, user)
+	if !ok {
+		return
+	}
+	if !utils.HasPermissionIn(user, utils.PermFarmsManage, farm.OrgID) && farm.ManagerID != user.ID {
+		c.JSON(http.StatusForbidden, models.ErrorResponse{
+			Error:   "forbidden",
+			Message: "Access denied",
+		})
+		return
+	}
+	if !fh.checkManager(c, req.ManagerID, farm.OrgID) {
+		return
+	}
+
+	farm.Name = req.Name
+	farm.Region = req.Region
+	farm.ManagerID = req.ManagerID
+	farm.UpdatedAt = time.Now()
+
+	ctx := fh.firestoreService.Context()
+	if _, err := fh.firestoreService.Farms().Doc(farm.ID).Set(ctx, farm); err != nil {
+		c.JSON(http.StatusInternalServerError, models.ErrorResponse{
+			Error:   "internal_error",
+			Message: "Failed to update farm",
+		})
+		return
+	}
+
+	c.JSON(http.StatusOK, models.SuccessResponse{
+		Success: true,
+		Data:    farm,
+		Message: "Farm updated successfully",
+	})
+}
+
+// @Summary Delete a farm
+// @Description Delete a farm that has no fields left. Move its fields to another farm, or out of every farm, first.
+// @Tags farms
+// @Produce  json
+// @Security ApiKeyAuth
+// @Param id path string true "Farm ID"
+// @Success 200 {object} models.SuccessResponse
+// @Failure 403 {object} models.ErrorResponse
+// @Failure 404 {object} models.ErrorResponse
+// @Failure 409 {object} models.ErrorResponse
+// @Failure 500 {object} models.ErrorResponse
+// @Router /farms/{id} [delete]
+func (fh *FarmHandler) DeleteFarm(c *gin.Context) {
+	currentUser, _ := c.Get("user")
+	user := currentUser.(*models.User)
+
+	farm, ok := fh.visibleFarm(c, user)
+	if !ok {
+		return
+	}
+	if !utils.HasPermissionIn(user, utils.PermFarmsManage, farm.OrgID) {
+		c.JSON(http.StatusForbidden, models.ErrorResponse{
+			Error:   "forbidden",
+			Message: "Access denied",
+		})
+		return
+	}
+
+	ctx := fh.firestoreService.Context()
+	fields, err := fh.firestoreService.Fields().Where("farm_id", "==", farm.ID).Limit(1).Documents(ctx).GetAll()
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, models.ErrorResponse{
+			Error:   "internal_error",
+			Message: "Failed to check the farm's fields",
+		})
+		return
+	}
+	if len(fields) > 0 {
+		c.JSON(http.StatusConflict, models.ErrorResponse{
+			Error:   "farm_not_empty",
+			Message: "Move every field out of the farm before deleting it",
+		})
+		return
+	}
+
+	if _, err := fh.firestoreService.Farms().Doc(farm.ID).Delete(ctx); err != nil {
+		c.JSON(http.StatusInternalServerError, models.ErrorResponse{
+			Error:   "internal_error",
+			Message: "Failed to delete farm",
+		})
+		return
+	}
+
+	c.JSON(http.StatusOK, models.SuccessResponse{
+		Success: true,
+		Message: "Farm deleted successfully",
+	})
+}
+
+// visibleFarm loads the farm of the request, answering 404 when it is missing
+// or belongs to another organization
+func (fh *FarmHandler) visibleFarm(c *gin.Context, user *models.User) (*models.Farm, bool) {
+	farm, err := getFarm(fh.firestoreService, c.Param("id"))
+	if err != nil || (user.OrgID != "" && farm.OrgID != user.OrgID) {
+		c.JSON(http.StatusNotFound, models.ErrorResponse{
+			Error:   "not_found",
+			Message: "Farm not found",
+		})
+		return nil, false
+	}
+	return farm, true
+}
+
+// checkManager responds with an error and returns false unless managerID is
+// empty or a user of the organization orgID
+func (fh *FarmHandler) checkManager(c *gin.Context, managerID, orgID string) bool {
+	if managerID == "" {
+		return true
+	}
+
+	ctx := fh.firestoreService.Context()
+	doc, err := fh.firestoreService.Users().Doc(managerID).Get(ctx)
+	var manager models.User
+	if err == nil {
+		doc.DataTo(&manager)
+	}
+	if err != nil || manager.DeletedAt != nil || (orgID != "" && manager.OrgID != orgID) {
+		c.JSON(http.StatusBadRequest, models.ErrorResponse{
+			Error:   "invalid_request",
+			Message: "manager_id must be a user of the farm's organization",
+		})
+		return false
+	}
+	return true
+}
+
+func getFarm(firestoreService *services.FirestoreService, farmID string) (*models.Farm, error) {
+	ctx := firestoreService.Context()
+	doc, err := firestoreService.Farms().Doc(farmID).Get(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	var farm models.Farm
+	if err := doc.DataTo(&farm); err != nil {
+		return nil, err
+	}
+	return &farm, nil
+}
+
+// checkFieldFarm returns an error unless farmID is empty or a farm of the
+// field's organization
+func checkFieldFarm(firestoreService *services.FirestoreService, farmID, orgID string) error {
+	if farmID == "" {
+		return nil
+	}
+	farm, err := getFarm(firestoreService, farmID)
+	if err != nil || farm.OrgID != orgID {
+		return errors.New("farm_id must be a farm of the field's organization")
+	}
+	return nil
+}
+
+// applyFieldFarm copies the farm of the submission's field onto it, so
+// submissions can be filtered by farm
+func applyFieldFarm(firestoreService *services.FirestoreService, submission *models.Submission) error {
+	submission.FarmID = ""
+	if submission.FieldID == "" {
+		return nil
+	}
+
+	ctx := firestoreService.Context()
+	doc, err := firestoreService.Fields().Doc(submission.FieldID).Get(ctx)
+	if status.Code(err) == codes.NotFound {
+		return nil
+	}
+	if err != nil {
+		return err
+	}
+
+	var field models.Field
+	doc.DataTo(&field)
+	submission.FarmID = field.FarmID
+	return nil
+}
+
+// moveFieldSubmissions sets the farm of every submission of a field moved to
+// another farm, or removes it when farmID is empty
+func moveFieldSubmissions(firestoreService *services.FirestoreService, fieldID, farmID string) error {
+	ctx := firestoreService.Context()
+	docs, err := firestoreService.Submissions().Where("field_id", "==", fieldID).Select().Documents(ctx).GetAll()
+	if err != nil {
+		return err
+	}
+
+	var value interface{} = farmID
+	if farmID == "" {
+		value = firestore.Delete
+	}
+	for i := 0; i < len(docs); i += firestoreBatchSize {
+		end := i + firestoreBatchSize
+		if end > len(docs) {
+			end = len(docs)
+		}
+		batch := firestoreService.Client.Batch()
+		for _, doc := range docs[i:end] {
+			batch.Update(doc.Ref, []firestore.Update{{Path: "farm_id", Value: value}})
+		}
+		if _, err := batch.Commit(ctx); err != nil {
+			return err
+		}
+	}
+	return nil
+}
@@ -0,0 +1,145 @@
+package handlers
+
+import (
+	"net/http"
+	"time"
+
+	"rice-monitor-api/models"
+	"rice-monitor-api/services"
+
+	"cloud.google.com/go/firestore"
+	"github.com/gin-gonic/gin"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+)
+
+// fieldArchivedResponse answers submissions to an archived field
+var fieldArchivedResponse = models.ErrorResponse{
+	Error:   "field_archived",
+	Message: "The field is archived and no longer takes observations",
+}
+
+// @Summary Archive a field
+// @Description Archive a field that is no longer monitored. It is left out of the field list unless asked for
+// @Description and takes no new submissions, but its submissions stay in analytics and exports.
+// @Tags fields
+// @Produce  json
+// @Security ApiKeyAuth
+// @Param id path string true "Field ID"
+// @Success 200 {object} models.SuccessResponse
+// @Failure 403 {object} models.ErrorResponse
+// @Failure 404 {object} models.ErrorResponse
+// @Failure 409 {object} models.ErrorResponse
+// @Failure 500 {object} models.ErrorResponse
+// @Router /fields/{id}/archive [post]
+func (fh *FieldHandler) ArchiveField(c *gin.Context) {
+	currentUser, _ := c.Get("user")
+	user := currentUser.(*models.User)
+
+	field, ok := fh.managedField(c, user)
+	if !ok {
+		return
+	}
+	if field.Archived {
+		c.JSON(http.StatusConflict, models.ErrorResponse{
+			Error:   "already_archived",
+			Message: "Field is already archived",
+		})
+		return
+	}
+
+	now := time.Now()
+	fh.setArchived(c, field.ID, []firestore.Update{
+		{Path: "archived", Value: true},
+		{Path: "archived_at", Value: now},
+		{Path: "archived_by", Value: user.ID},
+		{Path: "updated_at", Value: now},
+	}, "Field archived successfully")
+}
+
+// @Summary Unarchive a field
+// @Description Bring an archived field back into the field list so it takes submissions again
+// @Tags fields
+// @Produce  json
+// @Security ApiKeyAuth
+// @Param id path string true "Field ID"
+// @Success 200 {object} models.SuccessResponse
+// @Failure 403 {object} models.ErrorResponse
+// @Failure 404 {object} models.ErrorResponse
+// @Failure 409 {object} models.ErrorResponse
+// @Failure 500 {object} models.ErrorResponse
+// @Router /fields/{id}/unarchive [post]
+func (fh *FieldHandler) UnarchiveField(c *gin.Context) {
+	currentUser, _ := c.Get("user")
+	user := currentUser.(*models.User)
+
+	field, ok := fh.managedField(c, user)
+	if !ok {
+		return
+	}
+	if !field.Archived {
+		c.JSON(http.StatusConflict, models.ErrorResponse{
+			Error:   "not_archived",
+			Message: "Field is not archived",
+		})
+		return
+	}
+
+	fh.setArchived(c, field.ID, []firestore.Update{
+		{Path: "archived", Value: false},
+		{Path: "archived_at", Value: firestore.Delete},
+		{Path: "archived_by", Value: firestore.Delete},
+		{Path: "updated_at", Value: time.Now()},
+	}, "Field unarchived successfully")
+}
+
+// setArchived applies the archiving updates to a field and responds with the
+// updated field
+func (fh *FieldHandler) setArchived(c *gin.Context, fieldID string, updates []firestore.Update, message string) {
+	ctx := fh.firestoreService.Context()
+	if _, err := fh.firestoreService.Fields().Doc(fieldID).Update(ctx, updates); err != nil {
+		c.JSON(http.StatusInternalServerError, models.ErrorResponse{
+			Error:   "internal_error",
+			Message: "Failed to update field",
+		})
+		return
+	}
+
+	field, err := fh.getFieldByID(fieldID)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, models.ErrorResponse{
+			Error:   "internal_error",
+			Message: "Failed to retrieve updated field",
+		})
+		return
+	}
+
+	c.JSON(http.StatusOK, models.SuccessResponse{
+		Success: true,
+		Data:    field,
+		Message: message,
+	})
+}
+
+// fieldArchived reports whether the field is archived. Missing fields are
+// not, and are left to the callers to report.
+func fieldArchived(firestoreService *services.FirestoreService, fieldID string) (bool, error) {
+	if fieldID == "" {
+		return false, nil
+	}
+
+	ctx := firestoreService.Context()
+	doc, err := firestoreService.Fields().Doc(fieldID).Get(ctx)
+	if status.Code(err) == codes.NotFound {
+		return false, nil
+	}
+	if err != nil {
+		return false, err
+	}
+
+	var field models.Field
+	if err := doc.DataTo(&field); err != nil {
+		return false, err
+	}
+	return field.Archived, nil
+}
@@ -0,0 +1,300 @@
+package handlers
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"time"
+
+	"rice-monitor-api/models"
+	"rice-monitor-api/utils"
+
+	"cloud.google.com/go/firestore"
+	"github.com/gin-gonic/gin"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+)
+
+var errNotCollaborator = errors.New("not a collaborator")
+
+// @Summary List a field's collaborators
+// @Description List the users the field is shared with and their roles
+// @Tags fields
+// @Produce  json
+// @Security ApiKeyAuth
+// @Param id path string true "Field ID"
+// @Success 200 {object} models.SuccessResponse
+// @Failure 403 {object} models.ErrorResponse
+// @Failure 404 {object} models.ErrorResponse
+// @Failure 500 {object} models.ErrorResponse
+// @Router /fields/{id}/collaborators [get]
+func (fh *FieldHandler) GetFieldCollaborators(c *gin.Context) {
+	currentUser, _ := c.Get("user")
+	user := currentUser.(*models.User)
+
+	field, err := fh.getFieldByID(c.Param("id"))
+	if err != nil {
+		c.JSON(http.StatusNotFound, models.ErrorResponse{
+			Error:   "not_found",
+			Message: "Field not found",
+		})
+		return
+	}
+	if !canReadField(user, field) {
+		c.JSON(http.StatusForbidden, models.ErrorResponse{
+			Error:   "forbidden",
+			Message: "Access denied",
+		})
+		return
+	}
+
+	collaborators := []models.FieldCollaborator{}
+	if len(field.Collaborators) > 0 {
+		refs := make([]*firestore.DocumentRef, len(field.Collaborators))
+		for i, collaborator := range field.Collaborators {
+			refs[i] = fh.firestoreService.Users().Doc(collaborator.UserID)
+		}
+		docs, err := fh.firestoreService.Client.GetAll(fh.firestoreService.Context(), refs)
+		if err != nil {
+			c.JSON(http.StatusInternalServerError, models.ErrorResponse{
+				Error:   "internal_error",
+				Message: "Failed to retrieve collaborators",
+			})
+			return
+		}
+
+		for i, collaborator := range field.Collaborators {
+			// Deleted users keep no access
+			if !docs[i].Exists() {
+				continue
+			}
+			var member models.User
+			docs[i].DataTo(&member)
+			if member.DeletedAt != nil {
+				continue
+			}
+			collaborator.Name = member.Name
+			collaborator.Email = member.Email
+			collaborators = append(collaborators, collaborator)
+		}
+	}
+
+	c.JSON(http.StatusOK, models.SuccessResponse{
+		Success: true,
+		Data:    collaborators,
+	})
+}
+
+// @Summary Share a field with a user
+// @Description Share the field with a user as a viewer, who can see the field and its submissions, or as a
+// @Description contributor, who can also submit observations to it. Sharing again changes the user's role.
+// @Description Only the field's owner or someone who can update every field can share it.
+// @Tags fields
+// @Accept  json
+// @Produce  json
+// @Security ApiKeyAuth
+// @Param id path string true "Field ID"
+// @Param userId path string true "User ID"
+// @Param collaborator body models.SetFieldCollaboratorRequest true "Role of the user"
+// @Success 200 {object} models.SuccessResponse
+// @Failure 400 {object} models.ErrorResponse
+// @Failure 403 {object} models.ErrorResponse
+// @Failure 404 {object} models.ErrorResponse
+// @Failure 500 {object} models.ErrorResponse
+// @Router /fields/{id}/collaborators/{userId} [put]
+func (fh *FieldHandler) SetFieldCollaborator(c *gin.Context) {
+	var req models.SetFieldCollaboratorRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, models.ErrorResponse{
+			Error:   "invalid_request",
+			Message: err.Error(),
+		})
+		return
+	}
+
+	currentUser, _ := c.Get("user")
+	user := currentUser.(*models.User)
+
+	field, ok := fh.managedField(c, user)
+	if !ok {
+		return
+	}
+
+	userID := c.Param("userId")
+	if userID == field.OwnerID {
+		c.JSON(http.StatusBadRequest, models.ErrorResponse{
+			Error:   "invalid_request",
+			Message: "The field's owner already has full access",
+		})
+		return
+	}
+
+	ctx := fh.firestoreService.Context()
+	userDoc, err := fh.firestoreService.Users().Doc(userID).Get(ctx)
+	if err != nil {
+		c.JSON(http.StatusNotFound, models.ErrorResponse{
+			Error:   "not_found",
+			Message: "User not found",
+		})
+		return
+	}
+	var collaborator models.User
+	userDoc.DataTo(&collaborator)
+	if collaborator.DeletedAt != nil {
+		c.JSON(http.StatusNotFound, models.ErrorResponse{
+			Error:   "not_found",
+			Message: "User not found",
+		})
+		return
+	}
+
+	entry := models.FieldCollaborator{
+		UserID:  userID,
+		Role:    req.Role,
+		AddedBy: user.ID,
+		AddedAt: time.Now(),
+	}
+	collaborators, err := fh.updateCollaborators(field.ID, func(collaborators []models.FieldCollaborator) ([]models.FieldCollaborator, error) {
+		for i := range collaborators {
+			if collaborators[i].UserID == userID {
+				collaborators[i].Role = req.Role
+				return collaborators, nil
+			}
+		}
+		return append(collaborators, entry), nil
+	})
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, models.ErrorResponse{
+			Error:   "internal_error",
+			Message: "Failed to share field",
+		})
+		return
+	}
+
+	c.JSON(http.StatusOK, models.SuccessResponse{
+		Success: true,
+		Data:    collaborators,
+		Message: "Field shared successfully",
+	})
+}
+
+// @Summary Stop sharing a field with a user
+// @Description Remove a collaborator from the field. Collaborators can also remove themselves.
+// @Tags fields
+// @Produce  json
+// @Security ApiKeyAuth
+// @Param id path string true "Field ID"
+// @Param userId path string true "User ID"
+// @Success 200 {object} models.SuccessResponse
+// @Failure 403 {object} models.ErrorResponse
+// @Failure 404 {object} models.ErrorResponse
+// @Failure 500 {object} models.ErrorResponse
+// @Router /fields/{id}/collaborators/{userId} [delete]
+func (fh *FieldHandler) RemoveFieldCollaborator(c *gin.Context) {
+	currentUser, _ := c.Get("user")
+	user := currentUser.(*models.User)
+
+	userID := c.Param("userId")
+	if userID != user.ID {
+		if _, ok := fh.managedField(c, user); !ok {
+			return
+		}
+	}
+
+	_, err := fh.updateCollaborators(c.Param("id"), func(collaborators []models.FieldCollaborator) ([]models.FieldCollaborator, error) {
+		for i := range collaborators {
+			if collaborators[i].UserID == userID {
+				return append(collaborators[:i], collaborators[i+1:]...), nil
+			}
+		}
+		return nil, errNotCollaborator
+	})
+	if err != nil {
+		if status.Code(err) == codes.NotFound {
+			c.JSON(http.StatusNotFound, models.ErrorResponse{
+				Error:   "not_found",
+				Message: "Field not found",
+			})
+			return
+		}
+		if errors.Is(err, errNotCollaborator) {
+			c.JSON(http.StatusNotFound, models.ErrorResponse{
+				Error:   "not_found",
+				Message: "The field is not shared with this user",
+			})
+			return
+		}
+		c.JSON(http.StatusInternalServerError, models.ErrorResponse{
+			Error:   "internal_error",
+			Message: "Failed to remove collaborator",
+		})
+		return
+	}
+
+	c.JSON(http.StatusOK, models.SuccessResponse{
+		Success: true,
+		Message: "Collaborator removed successfully",
+	})
+}
+
+// managedField loads the field of the request, answering 404 or 403 when it
+// is missing or user may not manage its collaborators and seasons
+func (fh *FieldHandler) managedField(c *gin.Context, user *models.User) (*models.Field, bool) {
+	field, err := fh.getFieldByID(c.Param("id"))
+	if err != nil {
+		c.JSON(http.StatusNotFound, models.ErrorResponse{
+			Error:   "not_found",
+			Message: "Field not found",
+		})
+		return nil, false
+	}
+	if !utils.HasPermissionIn(user, utils.PermFieldsUpdateAll, field.OrgID) && field.OwnerID != user.ID {
+		c.JSON(http.StatusForbidden, models.ErrorResponse{
+			Error:   "forbidden",
+			Message: "Only the field's owner or someone who can update every field can change it",
+		})
+		return nil, false
+	}
+	return field, true
+}
+
+// updateCollaborators changes a field's collaborators in a transaction, so
+// concurrent changes are not lost, and returns them
+func (fh *FieldHandler) updateCollaborators(fieldID string, change func([]models.FieldCollaborator) ([]models.FieldCollaborator, error)) ([]models.FieldCollaborator, error) {
+	var collaborators []models.FieldCollaborator
+	ref := fh.firestoreService.Fields().Doc(fieldID)
+	err := fh.firestoreService.Client.RunTransaction(fh.firestoreService.Context(), func(ctx context.Context, tx *firestore.Transaction) error {
+		doc, err := tx.Get(ref)
+		if err != nil {
+			return err
+		}
+		var field models.Field
+		doc.DataTo(&field)
+
+		collaborators, err = change(field.Collaborators)
+		if err != nil {
+			return err
+		}
+		ids := make([]string, len(collaborators))
+		for i, collaborator := range collaborators {
+			ids[i] = collaborator.UserID
+		}
+		return tx.Update(ref, []firestore.Update{
+			{Path: "collaborators", Value: collaborators},
+			{Path: "collaborator_ids", Value: ids},
+			{Path: "updated_at", Value: time.Now()},
+		})
+	})
+	return collaborators, err
+}
+
+// fieldCollaboratorRole returns the role of the user the field is shared
+// with, or "" when it is not shared with them
+func fieldCollaboratorRole(field *models.Field, userID string) string {
+	for _, collaborator := range field.Collaborators {
+		if collaborator.UserID == userID {
+			return collaborator.Role
+		}
+	}
+	return ""
+}
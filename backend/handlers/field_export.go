@@ -0,0 +1,185 @@
+package handlers
+
+import (
+	"bytes"
+	"fmt"
+	"html"
+	"net/http"
+	"strings"
+	"time"
+
+	"rice-monitor-api/models"
+	"rice-monitor-api/utils"
+
+	"cloud.google.com/go/firestore"
+	"github.com/gin-gonic/gin"
+)
+
+// kmlRecentObservations is how many of a field's latest observations its popup lists
+const kmlRecentObservations = 5
+
+// kmlStyles color fields by the plant conditions of their latest observation
+var kmlStyles = []utils.KMLStyle{
+	{ID: "healthy", LineColor: "ff00aa00", FillColor: "6600aa00"},
+	{ID: "attention", LineColor: "ff00c8ff", FillColor: "6600c8ff"},
+	{ID: "problem", LineColor: "ff0000dd", FillColor: "660000dd"},
+	{ID: "unobserved", LineColor: "ff999999", FillColor: "44999999"},
+}
+
+// kmlProblemConditions mark a field red rather than yellow
+var kmlProblemConditions = []string{"Signs of pest infestation", "Disease symptoms"}
+
+// @Summary Export fields to KML
+// @Description Download the fields you can read as a KML file for Google Earth. Fields are drawn as
+// @Description their boundary polygon (or a point without one), colored by their latest observation:
+// @Description green healthy, red pests or disease, yellow other problems, grey not yet observed.
+// @Description Each placemark's popup summarizes the field's recent observations.
+// @Tags fields
+// @Produce  application/vnd.google-earth.kml+xml
+// @Security ApiKeyAuth
+// @Param format query string false "Export format (kml)"
+// @Success 200 {file} file
+// @Failure 400 {object} models.ErrorResponse
+// @Failure 500 {object} models.ErrorResponse
+// @Router /fields/export [get]
+func (fh *FieldHandler) ExportFields(c *gin.Context) {
+	if format := c.DefaultQuery("format", "kml"); format != "kml" {
+		c.JSON(http.StatusBadRequest, models.ErrorResponse{
+			Error:   "invalid_format",
+			Message: "format must be kml",
+		})
+		return
+	}
+
+	currentUser, _ := c.Get("user")
+	user := currentUser.(*models.User)
+
+	ctx := fh.firestoreService.Context()
+	docs, err := fh.firestoreService.Fields().OrderBy("name", firestore.Asc).Documents(ctx).GetAll()
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, models.ErrorResponse{
+			Error:   "internal_error",
+			Message: "Failed to retrieve fields",
+		})
+		return
+	}
+
+	placemarks := []utils.KMLPlacemark{}
+	for _, doc := range docs {
+		var field models.Field
+		doc.DataTo(&field)
+		if !canReadField(user, &field) {
+			continue
+		}
+
+		observations, err := fh.recentObservations(field.ID)
+		if err != nil {
+			c.JSON(http.StatusInternalServerError, models.ErrorResponse{
+				Error:   "internal_error",
+				Message: "Failed to retrieve observations",
+			})
+			return
+		}
+
+		placemarks = append(placemarks, utils.KMLPlacemark{
+			Name:        field.Name,
+			Description: fieldPopup(&field, observations, utils.UserLocation(user)),
+			StyleID:     fieldStyle(observations),
+			Point:       field.Coordinates,
+			Boundary:    field.Boundary,
+		})
+	}
+
+	var buf bytes.Buffer
+	if err := utils.WriteKML(&buf, "Rice Monitor fields", kmlStyles, placemarks); err != nil {
+		c.JSON(http.StatusInternalServerError, models.ErrorResponse{
+			Error:   "internal_error",
+			Message: "Failed to build export",
+		})
+		return
+	}
+
+	stampAgreement(c)
+	c.Header("Content-Disposition", "attachment; filename=fields.kml")
+	c.Data(http.StatusOK, "application/vnd.google-earth.kml+xml", buf.Bytes())
+}
+
+// recentObservations returns the field's latest non-draft submissions, newest first
+func (fh *FieldHandler) recentObservations(fieldID string) ([]models.Submission, error) {
+	ctx := fh.firestoreService.Context()
+	docs, err := fh.firestoreService.Submissions().
+		Where("field_id", "==", fieldID).
+		OrderBy("date", firestore.Desc).
+		Limit(kmlRecentObservations * 2).
+		Documents(ctx).GetAll()
+	if err != nil {
+		return nil, err
+	}
+
+	observations := []models.Submission{}
+	for _, doc := range docs {
+		var submission models.Submission
+		doc.DataTo(&submission)
+		if submission.Status == "draft" || submission.Status == "rejected" {
+			continue
+		}
+		observations = append(observations, submission)
+		if len(observations) == kmlRecentObservations {
+			break
+		}
+	}
+	return observations, nil
+}
+
+// fieldStyle picks the style for the plant conditions of the latest observation
+func fieldStyle(observations []models.Submission) string {
+	if len(observations) == 0 {
+		return "unobserved"
+	}
+
+	conditions := observations[0].PlantConditions
+	for _, condition := range kmlProblemConditions {
+		if utils.Contains(conditions, condition) {
+			return "problem"
+		}
+	}
+	for _, condition := range conditions {
+		if condition != "Healthy" {
+			return "attention"
+		}
+	}
+	return "healthy"
+}
+
+// fieldPopup renders the HTML shown when a field's placemark is clicked, with
+// observation dates in loc
+func fieldPopup(field *models.Field, observations []models.Submission, loc *time.Location) string {
+	var b strings.Builder
+	fmt.Fprintf(&b, "<p>%s", html.EscapeString(field.Location))
+	if field.RiceVariety != "" {
+		fmt.Fprintf(&b, "<br/>Variety: %s", html.EscapeString(field.RiceVariety))
+	}
+	if field.Area > 0 {
+		fmt.Fprintf(&b, "<br/>Area: %.2f ha", field.Area)
+	}
+	if field.TransplantDate != "" {
+		fmt.Fprintf(&b, "<br/>Transplanted: %s", html.EscapeString(field.TransplantDate))
+	}
+	b.WriteString("</p>")
+
+	if len(observations) == 0 {
+		b.WriteString("<p>No observations yet</p>")
+		return b.String()
+	}
+
+	b.WriteString("<table><tr><th>Date</th><th>Stage</th><th>Conditions</th><th>Status</th></tr>")
+	for _, observation := range observations {
+		fmt.Fprintf(&b, "<tr><td>%s</td><td>%s</td><td>%s</td><td>%s</td></tr>",
+			utils.FormatDate(observation.Date.In(loc)),
+			html.EscapeString(observation.GrowthStage),
+			html.EscapeString(strings.Join(observation.PlantConditions, ", ")),
+			html.EscapeString(observation.Status))
+	}
+	b.WriteString("</table>")
+	return b.String()
+}
@@ -0,0 +1,121 @@
+package handlers
+
+import (
+	"net/http"
+	"sort"
+	"strconv"
+
+	"rice-monitor-api/models"
+	"rice-monitor-api/utils"
+
+	"github.com/gin-gonic/gin"
+)
+
+// @Summary Find fields nearby
+// @Description Find the fields you can see within radius_km (1 by default, at most 50) of a position, nearest first,
+// @Description so an observer standing in a paddy can pick its field. Distances are to the field's boundary, 0 inside
+// @Description it, or to its coordinates without one. Archived fields and fields without coordinates are never found.
+// @Tags fields
+// @Produce  json
+// @Security ApiKeyAuth
+// @Param lat query number true "Latitude"
+// @Param lng query number true "Longitude"
+// @Param radius_km query number false "Search radius in kilometres (up to 50)"
+// @Param limit query int false "Maximum number of fields (1-100, default 20)"
+// @Success 200 {object} models.SuccessResponse
+// @Failure 400 {object} models.ErrorResponse
+// @Failure 500 {object} models.ErrorResponse
+// @Router /fields/nearby [get]
+func (fh *FieldHandler) GetNearbyFields(c *gin.Context) {
+	lat, latErr := strconv.ParseFloat(c.Query("lat"), 64)
+	lng, lngErr := strconv.ParseFloat(c.Query("lng"), 64)
+	position := models.Location{Latitude: lat, Longitude: lng}
+	if latErr != nil || lngErr != nil || !validCoordinates(position) {
+		c.JSON(http.StatusBadRequest, models.ErrorResponse{
+			Error:   "invalid_request",
+			Message: "lat and lng must be a valid latitude and longitude",
+		})
+		return
+	}
+
+	radiusKm := 1.0
+	if value := c.Query("radius_km"); value != "" {
+		radius, err := strconv.ParseFloat(value, 64)
+		if err != nil || radius <= 0 || radius > 50 {
+			c.JSON(http.StatusBadRequest, models.ErrorResponse{
+				Error:   "invalid_request",
+				Message: "radius_km must be greater than 0 and at most 50",
+			})
+			return
+		}
+		radiusKm = radius
+	}
+
+	limit := 20
+	if value := c.Query("limit"); value != "" {
+		n, err := strconv.Atoi(value)
+		if err != nil || n < 1 || n > 100 {
+			c.JSON(http.StatusBadRequest, models.ErrorResponse{
+				Error:   "invalid_request",
+				Message: "limit must be between 1 and 100",
+			})
+			return
+		}
+		limit = n
+	}
+
+	currentUser, _ := c.Get("user")
+	user := currentUser.(*models.User)
+
+	// Every field within the radius has its geohash in one of the cells
+	ctx := fh.firestoreService.Context()
+	nearby := []models.NearbyField{}
+	seen := make(map[string]bool)
+	for _, cell := range utils.GeohashCells(lat, lng, radiusKm) {
+		docs, err := orgScope(fh.firestoreService.Fields().Query, user).
+			Where("geohash", ">=", cell).
+			Where("geohash", "<", cell+"~").
+			Documents(ctx).GetAll()
+		if err != nil {
+			c.JSON(http.StatusInternalServerError, models.ErrorResponse{
+				Error:   "internal_error",
+				Message: "Failed to search fields",
+			})
+			return
+		}
+
+		for _, doc := range docs {
+			var field models.Field
+			doc.DataTo(&field)
+			if seen[field.ID] || field.Archived || !canReadField(user, &field) {
+				continue
+			}
+			seen[field.ID] = true
+
+			found := models.NearbyField{Field: field}
+			if len(field.Boundary) >= 3 {
+				found.InsideBoundary = utils.PointInPolygon(position, field.Boundary)
+				found.DistanceKm = utils.DistanceToPolygonKm(position, field.Boundary)
+			} else {
+				found.DistanceKm = utils.DistanceKm(position, field.Coordinates)
+			}
+			if found.DistanceKm > radiusKm {
+				continue
+			}
+			found.BoundaryGeoJSON = utils.GeoJSONPolygonOf(field.Boundary)
+			nearby = append(nearby, found)
+		}
+	}
+
+	sort.SliceStable(nearby, func(i, j int) bool {
+		return nearby[i].DistanceKm < nearby[j].DistanceKm
+	})
+	if len(nearby) > limit {
+		nearby = nearby[:limit]
+	}
+
+	c.JSON(http.StatusOK, models.SuccessResponse{
+		Success: true,
+		Data:    nearby,
+	})
+}
@@ -0,0 +1,415 @@
+package handlers
+
+import (
+	"net/http"
+	"sort"
+	"time"
+
+	"rice-monitor-api/models"
+	"rice-monitor-api/services"
+	"rice-monitor-api/utils"
+
+	"cloud.google.com/go/firestore"
+	"github.com/gin-gonic/gin"
+)
+
+// @Summary List field notes
+// @Description List the notes and announcements of a field, pinned first and then newest first.
+// @Description Expired notes are left out unless include_expired is true.
+// @Tags fields
+// @Produce  json
+// @Security ApiKeyAuth
+// @Param id path string true "Field ID"
+// @Param include_expired query bool false "Include expired notes"
+// @Success 200 {object} models.SuccessResponse
+// @Failure 403 {object} models.ErrorResponse
+// @Failure 404 {object} models.ErrorResponse
+// @Failure 500 {object} models.ErrorResponse
+// @Router /fields/{id}/notes [get]
+func (fh *FieldHandler) GetFieldNotes(c *gin.Context) {
+	field, ok := fh.readableField(c)
+	if !ok {
+		return
+	}
+
+	notes, err := fieldNotes(fh.firestoreService, []string{field.ID}, c.Query("include_expired") == "true")
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, models.ErrorResponse{
+			Error:   "internal_error",
+			Message: "Failed to retrieve field notes",
+		})
+		return
+	}
+
+	c.JSON(http.StatusOK, models.SuccessResponse{
+		Success: true,
+		Data:    notes,
+	})
+}
+
+// @Summary Add a field note
+// @Description Add a note to a field. Anyone who can see the field can add notes; only the field
+// @Description owner (or users who can edit all fields) can pin them as announcements.
+// @Tags fields
+// @Accept  json
+// @Produce  json
+// @Security ApiKeyAuth
+// @Param id path string true "Field ID"
+// @Param note body models.FieldNoteRequest true "Note"
+// @Success 201 {object} models.SuccessResponse
+// @Failure 400 {object} models.ErrorResponse
+// @Failure 403 {object} models.ErrorResponse
+// @Failure 404 {object} models.ErrorResponse
+// @Failure 500 {object} models.ErrorResponse
+// @Router /fields/{id}/notes [post]
+func (fh *FieldHandler) CreateFieldNote(c *gin.Context) {
+	var req models.FieldNoteRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, models.ErrorResponse{
+			Error:   "invalid_request",
+			Message: err.Error(),
+		})
+		return
+	}
+
+	field, ok := fh.readableField(c)
+	if !ok {
+		return
+	}
+
+	currentUser, _ := c.Get("user")
+	user := currentUser.(*models.User)
+
+	if !checkFieldNote(c, user, field, &req) {
+		return
+	}
+
+	note := models.FieldNote{
+		ID:         utils.GenerateID(),
+		FieldID:    field.ID,
+		Text:       req.Text,
+		Pinned:     req.Pinned,
+		ExpiresAt:  req.ExpiresAt,
+		AuthorID:   user.ID,
+		AuthorName: user.Name,
+		CreatedAt:  time.Now(),
+		UpdatedAt:  time.Now(),
+	}
+
+	ctx := fh.firestoreService.Context()
+	if _, err := fh.firestoreService.FieldNotes().Doc(note.ID).Set(ctx, note); err != nil {
+		c.JSON(http.StatusInternalServerError, models.ErrorResponse{
+			Error:   "internal_error",
+			Message: "Failed to create field note",
+		})
+		return
+	}
+
+	c.JSON(http.StatusCreated, models.SuccessResponse{
+		Success: true,
+		Data:    note,
+		Message: "Field note created successfully",
+	})
+}
+
+// @Summary Update a field note
+// @Description Replace the text, pin and expiry of a note. Notes can be edited by their author
+// @Description and by the field owner.
+// @Tags fields
+// @Accept  json
+// @Produce  json
+// @Security ApiKeyAuth
+// @Param id path string true "Field ID"
+// @Param noteId path string true "Note ID"
+// @Param note body models.FieldNoteRequest true "Note"
+// @Success 200 {object} models.SuccessResponse
+// @Failure 400 {object} models.ErrorResponse
+// @Failure 403 {object} models.ErrorResponse
+// @Failure 404 {object} models.ErrorResponse
+// @Failure 500 {object} models.ErrorResponse
+// @Router /fields/{id}/notes/{noteId} [put]
+func (fh *FieldHandler) UpdateFieldNote(c *gin.Context) {
+	var req models.FieldNoteRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, models.ErrorResponse{
+			Error:   "invalid_request",
+			Message: err.Error(),
+		})
+		return
+	}
+
+	field, note, ok := fh.editableFieldNote(c)
+	if !ok {
+		return
+	}
+
+	currentUser, _ := c.Get("user")
+	user := currentUser.(*models.User)
+
+	if !checkFieldNote(c, user, field, &req) {
+		return
+	}
+
+	note.Text = req.Text
+	note.Pinned = req.Pinned
+	note.ExpiresAt = req.ExpiresAt
+	note.UpdatedAt = time.Now()
+
+	ctx := fh.firestoreService.Context()
+	_, err := fh.firestoreService.FieldNotes().Doc(note.ID).Update(ctx, []firestore.Update{
+		{Path: "text", Value: note.Text},
+		{Path: "pinned", Value: note.Pinned},
+		{Path: "expires_at", Value: note.ExpiresAt},
+		{Path: "updated_at", Value: note.UpdatedAt},
+	})
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, models.ErrorResponse{
+			Error:   "internal_error",
+			Message: "Failed to update field note",
+		})
+		return
+	}
+
+	c.JSON(http.StatusOK, models.SuccessResponse{
+		Success: true,
+		Data:    note,
+		Message: "Field note updated successfully",
+	})
+}
+
+// @Summary Delete a field note
+// @Description Delete a note. Notes can be deleted by their author and by the field owner.
+// @Tags fields
+// @Produce  json
+// @Security ApiKeyAuth
+// @Param id path string true "Field ID"
+// @Param noteId path string true "Note ID"
+// @Success 200 {object} models.SuccessResponse
+// @Failure 403 {object} models.ErrorResponse
+// @Failure 404 {object} models.ErrorResponse
+// @Failure 500 {object} models.ErrorResponse
+// @Router /fields/{id}/notes/{noteId} [delete]
+func (fh *FieldHandler) DeleteFieldNote(c *gin.Context) {
+	_, note, ok := fh.editableFieldNote(c)
+	if !ok {
+		return
+	}
+
+	ctx := fh.firestoreService.Context()
+	if _, err := fh.firestoreService.FieldNotes().Doc(note.ID).Delete(ctx); err != nil {
+		c.JSON(http.StatusInternalServerError, models.ErrorResponse{
+			Error:   "internal_error",
+			Message: "Failed to delete field note",
+		})
+		return
+	}
+
+	c.JSON(http.StatusOK, models.SuccessResponse{
+		Success: true,
+		Message: "Field note deleted successfully",
+	})
+}
+
+// @Summary Today's overview
+// @Description List the fields you own or are assigned to, archived ones aside, with their current notes and any
+// @Description critical growth stage window that is open today
+// @Tags fields
+// @Produce  json
+// @Security ApiKeyAuth
+// @Success 200 {object} models.SuccessResponse
+// @Failure 500 {object} models.ErrorResponse
+// @Router /me/today [get]
+func (fh *FieldHandler) GetToday(c *gin.Context) {
+	currentUser, _ := c.Get("user")
+	user := currentUser.(*models.User)
+
+	ctx := fh.firestoreService.Context()
+	docs, err := fh.firestoreService.Fields().Where("owner_id", "==", user.ID).Documents(ctx).GetAll()
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, models.ErrorResponse{
+			Error:   "internal_error",
+			Message: "Failed to retrieve fields",
+		})
+		return
+	}
+
+	var fields []models.Field
+	var fieldIDs []string
+	for _, doc := range docs {
+		var field models.Field
+		doc.DataTo(&field)
+		if field.Archived {
+			continue
+		}
+		fields = append(fields, field)
+		fieldIDs = append(fieldIDs, field.ID)
+	}
+	for _, fieldID := range user.FieldIDs {
+		if utils.Contains(fieldIDs, fieldID) {
+			continue
+		}
+		// Assigned fields may have been deleted since the invite
+		if field, err := fh.getFieldByID(fieldID); err == nil && !field.Archived {
+			fields = append(fields, *field)
+			fieldIDs = append(fieldIDs, field.ID)
+		}
+	}
+
+	notes, err := fieldNotes(fh.firestoreService, fieldIDs, false)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, models.ErrorResponse{
+			Error:   "internal_error",
+			Message: "Failed to retrieve field notes",
+		})
+		return
+	}
+
+	now := time.Now()
+	today := models.TodayOverview{Date: utils.FormatDate(now), Fields: []models.TodayField{}}
+	for _, field := range fields {
+		entry := models.TodayField{Field: field, Notes: []models.FieldNote{}}
+		for _, note := range notes {
+			if note.FieldID == field.ID {
+				entry.Notes = append(entry.Notes, note)
+			}
+		}
+		for _, window := range services.PredictCriticalWindows(&field) {
+			if !now.Before(window.Start) && !now.After(window.End) {
+				entry.OpenWindows = append(entry.OpenWindows, window)
+			}
+		}
+		today.Fields = append(today.Fields, entry)
+	}
+
+	c.JSON(http.StatusOK, models.SuccessResponse{
+		Success: true,
+		Data:    today,
+	})
+}
+
+// Helper functions
+
+// readableField loads the field in the id parameter and checks that the
+// current user can read it, responding with an error otherwise
+func (fh *FieldHandler) readableField(c *gin.Context) (*models.Field, bool) {
+	currentUser, _ := c.Get("user")
+	user := currentUser.(*models.User)
+
+	field, err := fh.getFieldByID(c.Param("id"))
+	if err != nil {
+		c.JSON(http.StatusNotFound, models.ErrorResponse{
+			Error:   "not_found",
+			Message: "Field not found",
+		})
+		return nil, false
+	}
+
+	if !canReadField(user, field) {
+		c.JSON(http.StatusForbidden, models.ErrorResponse{
+			Error:   "forbidden",
+			Message: "Access denied",
+		})
+		return nil, false
+	}
+
+	return field, true
+}
+
+// editableFieldNote loads the note in the noteId parameter and checks that the
+// current user wrote it or manages its field
+func (fh *FieldHandler) editableFieldNote(c *gin.Context) (*models.Field, *models.FieldNote, bool) {
+	field, ok := fh.readableField(c)
+	if !ok {
+		return nil, nil, false
+	}
+
+	ctx := fh.firestoreService.Context()
+	doc, err := fh.firestoreService.FieldNotes().Doc(c.Param("noteId")).Get(ctx)
+	if err != nil {
+		c.JSON(http.StatusNotFound, models.ErrorResponse{
+			Error:   "not_found",
+			Message: "Field note not found",
+		})
+		return nil, nil, false
+	}
+
+	var note models.FieldNote
+	doc.DataTo(&note)
+	if note.FieldID != field.ID {
+		c.JSON(http.StatusNotFound, models.ErrorResponse{
+			Error:   "not_found",
+			Message: "Field note not found",
+		})
+		return nil, nil, false
+	}
+
+	currentUser, _ := c.Get("user")
+	user := currentUser.(*models.User)
+	if note.AuthorID != user.ID && !canManageField(user, field) {
+		c.JSON(http.StatusForbidden, models.ErrorResponse{
+			Error:   "forbidden",
+			Message: "Only the author or the field owner can change this note",
+		})
+		return nil, nil, false
+	}
+
+	return field, &note, true
+}
+
+// checkFieldNote responds with an error and returns false when the user may
+// not pin notes on field or the expiry is in the past
+func checkFieldNote(c *gin.Context, user *models.User, field *models.Field, req *models.FieldNoteRequest) bool {
+	if req.Pinned && !canManageField(user, field) {
+		c.JSON(http.StatusForbidden, models.ErrorResponse{
+			Error:   "forbidden",
+			Message: "Only the field owner can pin announcements",
+		})
+		return false
+	}
+	if req.ExpiresAt != nil && !req.ExpiresAt.After(time.Now()) {
+		c.JSON(http.StatusBadRequest, models.ErrorResponse{
+			Error:   "invalid_request",
+			Message: "expires_at must be in the future",
+		})
+		return false
+	}
+	return true
+}
+
+// canManageField reports whether user owns field or can edit every field
+func canManageField(user *models.User, field *models.Field) bool {
+	return field.OwnerID == user.ID || utils.HasPermissionIn(user, utils.PermFieldsUpdateAll, field.OrgID)
+}
+
+// fieldNotes returns the notes of the given fields, pinned first and then
+// newest first, leaving out expired notes unless includeExpired is set
+func fieldNotes(firestoreService *services.FirestoreService, fieldIDs []string, includeExpired bool) ([]models.FieldNote, error) {
+	notes := []models.FieldNote{}
+	ctx := firestoreService.Context()
+
+	// "in" queries accept a limited number of values
+	for i := 0; i < len(fieldIDs); i += 10 {
+		docs, err := firestoreService.FieldNotes().
+			Where("field_id", "in", fieldIDs[i:min(i+10, len(fieldIDs))]).
+			Documents(ctx).GetAll()
+		if err != nil {
+			return nil, err
+		}
+
+		for _, doc := range docs {
+			var note models.FieldNote
+			doc.DataTo(&note)
+			if includeExpired || note.ExpiresAt == nil || note.ExpiresAt.After(time.Now()) {
+				notes = append(notes, note)
+			}
+		}
+	}
+
+	sort.Slice(notes, func(i, j int) bool {
+		if notes[i].Pinned != notes[j].Pinned {
+			return notes[i].Pinned
+		}
+		return notes[i].CreatedAt.After(notes[j].CreatedAt)
+	})
+	return notes, nil
+}
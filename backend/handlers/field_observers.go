@@ -0,0 +1,275 @@
+package handlers
+
+import (
+	"net/http"
+	"time"
+
+	"rice-monitor-api/models"
+	"rice-monitor-api/utils"
+
+	"cloud.google.com/go/firestore"
+	"github.com/gin-gonic/gin"
+)
+
+// canSubmitToField reports whether user may submit observations for field.
+// Observers are limited to the fields they own, are assigned to or
+// contribute to.
+func canSubmitToField(user *models.User, field *models.Field) bool {
+	if user.Role != "observer" {
+		return true
+	}
+	return field.OwnerID == user.ID || utils.Contains(user.FieldIDs, field.ID) ||
+		fieldCollaboratorRole(field, user.ID) == "contributor"
+}
+
+// @Summary List a field's observers
+// @Description List the users assigned to a field
+// @Tags fields
+// @Produce  json
+// @Security ApiKeyAuth
+// @Param id path string true "Field ID"
+// @Success 200 {object} models.SuccessResponse
+// @Failure 403 {object} models.ErrorResponse
+// @Failure 404 {object} models.ErrorResponse
+// @Failure 500 {object} models.ErrorResponse
+// @Router /fields/{id}/observers [get]
+func (fh *FieldHandler) GetFieldObservers(c *gin.Context) {
+	currentUser, _ := c.Get("user")
+	user := currentUser.(*models.User)
+
+	field, err := fh.getFieldByID(c.Param("id"))
+	if err != nil {
+		c.JSON(http.StatusNotFound, models.ErrorResponse{
+			Error:   "not_found",
+			Message: "Field not found",
+		})
+		return
+	}
+	if !canReadField(user, field) {
+		c.JSON(http.StatusForbidden, models.ErrorResponse{
+			Error:   "forbidden",
+			Message: "Access denied",
+		})
+		return
+	}
+
+	ctx := fh.firestoreService.Context()
+	docs, err := fh.firestoreService.Users().Where("field_ids", "array-contains", field.ID).Documents(ctx).GetAll()
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, models.ErrorResponse{
+			Error:   "internal_error",
+			Message: "Failed to retrieve observers",
+		})
+		return
+	}
+
+	observers := []models.User{}
+	for _, doc := range docs {
+		var observer models.User
+		doc.DataTo(&observer)
+		if observer.DeletedAt == nil {
+			observers = append(observers, observer)
+		}
+	}
+
+	c.JSON(http.StatusOK, models.SuccessResponse{
+		Success: true,
+		Data:    observers,
+	})
+}
+
+// @Summary Assign an observer to a field
+// @Description Assign a user of the field's organization to the field. Observers can only submit
+// @Description observations for fields they own or are assigned to.
+// @Tags fields
+// @Accept  json
+// @Produce  json
+// @Security ApiKeyAuth
+// @Param id path string true "Field ID"
+// @Param assignment body models.AssignObserverRequest true "User to assign"
+// @Success 200 {object} models.SuccessResponse
+// @Failure 400 {object} models.ErrorResponse
+// @Failure 403 {object} models.ErrorResponse
+// @Failure 404 {object} models.ErrorResponse
+// @Failure 500 {object} models.ErrorResponse
+// @Router /fields/{id}/observers [post]
+func (fh *FieldHandler) AssignObserver(c *gin.Context) {
+	var req models.AssignObserverRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, models.ErrorResponse{
+			Error:   "invalid_request",
+			Message: err.Error(),
+		})
+		return
+	}
+
+	field, observerRef, ok := fh.assignmentTarget(c, c.Param("id"), req.UserID)
+	if !ok {
+		return
+	}
+
+	ctx := fh.firestoreService.Context()
+	_, err := observerRef.Update(ctx, []firestore.Update{
+		{Path: "field_ids", Value: firestore.ArrayUnion(field.ID)},
+		{Path: "updated_at", Value: time.Now()},
+	})
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, models.ErrorResponse{
+			Error:   "internal_error",
+			Message: "Failed to assign observer",
+		})
+		return
+	}
+
+	c.JSON(http.StatusOK, models.SuccessResponse{
+		Success: true,
+		Message: "Observer assigned successfully",
+	})
+}
+
+// @Summary Unassign an observer from a field
+// @Description Remove a user's assignment to a field
+// @Tags fields
+// @Produce  json
+// @Security ApiKeyAuth
+// @Param id path string true "Field ID"
+// @Param userId path string true "User ID"
+// @Success 200 {object} models.SuccessResponse
+// @Failure 400 {object} models.ErrorResponse
+// @Failure 403 {object} models.ErrorResponse
+// @Failure 404 {object} models.ErrorResponse
+// @Failure 500 {object} models.ErrorResponse
+// @Router /fields/{id}/observers/{userId} [delete]
+func (fh *FieldHandler) UnassignObserver(c *gin.Context) {
+	field, observerRef, ok := fh.assignmentTarget(c, c.Param("id"), c.Param("userId"))
+	if !ok {
+		return
+	}
+
+	ctx := fh.firestoreService.Context()
+	_, err := observerRef.Update(ctx, []firestore.Update{
+		{Path: "field_ids", Value: firestore.ArrayRemove(field.ID)},
+		{Path: "updated_at", Value: time.Now()},
+	})
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, models.ErrorResponse{
+			Error:   "internal_error",
+			Message: "Failed to unassign observer",
+		})
+		return
+	}
+
+	c.JSON(http.StatusOK, models.SuccessResponse{
+		Success: true,
+		Message: "Observer unassigned successfully",
+	})
+}
+
+// assignmentTarget loads the field and the user whose assignment changes. It
+// responds with an error and returns false unless the current user manages
+// assignments in the field's organization and the user belongs to it.
+func (fh *FieldHandler) assignmentTarget(c *gin.Context, fieldID, userID string) (*models.Field, *firestore.DocumentRef, bool) {
+	currentUser, _ := c.Get("user")
+	user := currentUser.(*models.User)
+
+	field, err := fh.getFieldByID(fieldID)
+	if err != nil {
+		c.JSON(http.StatusNotFound, models.ErrorResponse{
+			Error:   "not_found",
+			Message: "Field not found",
+		})
+		return nil, nil, false
+	}
+	if !utils.HasPermissionIn(user, utils.PermFieldsAssign, field.OrgID) {
+		c.JSON(http.StatusForbidden, models.ErrorResponse{
+			Error:   "forbidden",
+			Message: "Access denied",
+		})
+		return nil, nil, false
+	}
+
+	ctx := fh.firestoreService.Context()
+	ref := fh.firestoreService.Users().Doc(userID)
+	doc, err := ref.Get(ctx)
+	if err != nil {
+		c.JSON(http.StatusNotFound, models.ErrorResponse{
+			Error:   "not_found",
+			Message: "User not found",
+		})
+		return nil, nil, false
+	}
+
+	var observer models.User
+	doc.DataTo(&observer)
+	if observer.OrgID != field.OrgID {
+		c.JSON(http.StatusBadRequest, models.ErrorResponse{
+			Error:   "invalid_request",
+			Message: "The user does not belong to the field's organization",
+		})
+		return nil, nil, false
+	}
+
+	return field, ref, true
+}
+
+// @Summary List a user's assigned fields
+// @Description List the fields a user is assigned to
+// @Tags users
+// @Produce  json
+// @Security ApiKeyAuth
+// @Param id path string true "User ID"
+// @Success 200 {object} models.SuccessResponse
+// @Failure 403 {object} models.ErrorResponse
+// @Failure 404 {object} models.ErrorResponse
+// @Failure 500 {object} models.ErrorResponse
+// @Router /users/{id}/assigned-fields [get]
+func (uh *UserHandler) GetAssignedFields(c *gin.Context) {
+	userID := c.Param("id")
+	currentUser, _ := c.Get("user")
+	if !uh.canAccessUser(c, currentUser.(*models.User), userID, utils.PermUsersRead) {
+		return
+	}
+
+	user, err := uh.getUserByID(userID)
+	if err != nil {
+		c.JSON(http.StatusNotFound, models.ErrorResponse{
+			Error:   "not_found",
+			Message: "User not found",
+		})
+		return
+	}
+
+	fields := []models.Field{}
+	if len(user.FieldIDs) > 0 {
+		refs := make([]*firestore.DocumentRef, len(user.FieldIDs))
+		for i, fieldID := range user.FieldIDs {
+			refs[i] = uh.firestoreService.Fields().Doc(fieldID)
+		}
+
+		ctx := uh.firestoreService.Context()
+		docs, err := uh.firestoreService.Client.GetAll(ctx, refs)
+		if err != nil {
+			c.JSON(http.StatusInternalServerError, models.ErrorResponse{
+				Error:   "internal_error",
+				Message: "Failed to retrieve fields",
+			})
+			return
+		}
+
+		// Assignments to deleted fields are skipped
+		for _, doc := range docs {
+			if !doc.Exists() {
+				continue
+			}
+			var field models.Field
+			doc.DataTo(&field)
+			field.BoundaryGeoJSON = utils.GeoJSONPolygonOf(field.Boundary)
+			fields = append(fields, field)
+		}
+	}
+
+	c.JSON(http.StatusOK, models.SuccessResponse{
+		Success: true,
+		Data:    fields,
+	})
+}
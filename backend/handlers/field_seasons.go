@@ -0,0 +1,436 @@
+package handlers
+
+import (
+	"fmt"
+	"net/http"
+	"sort"
+	"time"
+
+	"rice-monitor-api/models"
+	"rice-monitor-api/services"
+	"rice-monitor-api/utils"
+
+	"cloud.google.com/go/firestore"
+	"github.com/gin-gonic/gin"
+)
+
+// @Summary List a field's seasons
+// @Description List the crop seasons of a field, latest first
+// @Tags fields
+// @Produce  json
+// @Security ApiKeyAuth
+// @Param id path string true "Field ID"
+// @Success 200 {object} models.SuccessResponse
+// @Failure 403 {object} models.ErrorResponse
+// @Failure 404 {object} models.ErrorResponse
+// @Failure 500 {object} models.ErrorResponse
+// @Router /fields/{id}/seasons [get]
+func (fh *FieldHandler) GetFieldSeasons(c *gin.Context) {
+	field, ok := fh.readableField(c)
+	if !ok {
+		return
+	}
+
+	seasons, err := fieldSeasons(fh.firestoreService, field.ID)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, models.ErrorResponse{
+			Error:   "internal_error",
+			Message: "Failed to retrieve seasons",
+		})
+		return
+	}
+
+	c.JSON(http.StatusOK, models.SuccessResponse{
+		Success: true,
+		Data:    seasons,
+	})
+}
+
+// @Summary Start a season
+// @Description Record a crop season of a field. Seasons of a field cannot overlap, and only the latest can be
+// @Description left without a harvest date. Submissions observed during the season are attached to it, and the
+// @Description field's rice_variety and transplant_date follow its latest season.
+// @Tags fields
+// @Accept  json
+// @Produce  json
+// @Security ApiKeyAuth
+// @Param id path string true "Field ID"
+// @Param season body models.SeasonRequest true "Season"
+// @Success 201 {object} models.SuccessResponse
+// @Failure 400 {object} models.ErrorResponse
+// @Failure 403 {object} models.ErrorResponse
+// @Failure 404 {object} models.ErrorResponse
+// @Failure 409 {object} models.ErrorResponse
+// @Failure 500 {object} models.ErrorResponse
+// @Router /fields/{id}/seasons [post]
+func (fh *FieldHandler) CreateFieldSeason(c *gin.Context) {
+	var req models.SeasonRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, models.ErrorResponse{
+			Error:   "invalid_request",
+			Message: err.Error(),
+		})
+		return
+	}
+
+	currentUser, _ := c.Get("user")
+	user := currentUser.(*models.User)
+
+	field, ok := fh.managedField(c, user)
+	if !ok {
+		return
+	}
+
+	now := time.Now()
+	season := models.Season{
+		ID:             utils.GenerateID(),
+		FieldID:        field.ID,
+		OrgID:          field.OrgID,
+		Name:           req.Name,
+		RiceVariety:    req.RiceVariety,
+		TransplantDate: req.TransplantDate,
+		HarvestDate:    req.HarvestDate,
+		CreatedBy:      user.ID,
+		CreatedAt:      now,
+		UpdatedAt:      now,
+	}
+	if !fh.saveSeason(c, field, &season) {
+		return
+	}
+
+	c.JSON(http.StatusCreated, models.SuccessResponse{
+		Success: true,
+		Data:    season,
+		Message: "Season created successfully",
+	})
+}
+
+// @Summary Update a season
+// @Description Change a season's name, variety or dates, such as to record its harvest. Submissions are
+// @Description attached again to the seasons their dates fall in.
+// @Tags fields
+// @Accept  json
+// @Produce  json
+// @Security ApiKeyAuth
+// @Param id path string true "Field ID"
+// @Param seasonId path string true "Season ID"
+// @Param season body models.SeasonRequest true "Season"
+// @Success 200 {object} models.SuccessResponse
+// @Failure 400 {object} models.ErrorResponse
+// @Failure 403 {object} models.ErrorResponse
+// @Failure 404 {object} models.ErrorResponse
+// @Failure 409 {object} models.ErrorResponse
+// @Failure 500 {object} models.ErrorResponse
+// @Router /fields/{id}/seasons/{seasonId} [put]
+func (fh *FieldHandler) UpdateFieldSeason(c *gin.Context) {
+	var req models.SeasonRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, models.ErrorResponse{
+			Error:   "invalid_request",
+			Message: err.Error(),
+		})
+		return
+	}
+
+	currentUser, _ := c.Get("user")
+	user := currentUser.(*models.User)
+
+	field, ok := fh.managedField(c, user)
+	if !ok {
+		return
+	}
+	season, ok := fh.fieldSeason(c, field)
+	if !ok {
+		return
+	}
+
+	season.Name = req.Name
+	season.RiceVariety = req.RiceVariety
+	season.TransplantDate = req.TransplantDate
+	season.HarvestDate = req.HarvestDate
+	season.UpdatedAt = time.Now()
+	if !fh.saveSeason(c, field, season) {
+		return
+	}
+
+	c.JSON(http.StatusOK, models.SuccessResponse{
+		Success: true,
+		Data:    season,
+		Message: "Season updated successfully",
+	})
+}
+
+// @Summary Delete a season
+// @Description Delete a season recorded by mistake. Its submissions are detached from it.
+// @Tags fields
+// @Produce  json
+// @Security ApiKeyAuth
+// @Param id path string true "Field ID"
+// @Param seasonId path string true "Season ID"
+// @Success 200 {object} models.SuccessResponse
+// @Failure 403 {object} models.ErrorResponse
+// @Failure 404 {object} models.ErrorResponse
+// @Failure 500 {object} models.ErrorResponse
+// @Router /fields/{id}/seasons/{seasonId} [delete]
+func (fh *FieldHandler) DeleteFieldSeason(c *gin.Context) {
+	currentUser, _ := c.Get("user")
+	user := currentUser.(*models.User)
+
+	field, ok := fh.managedField(c, user)
+	if !ok {
+		return
+	}
+	season, ok := fh.fieldSeason(c, field)
+	if !ok {
+		return
+	}
+
+	ctx := fh.firestoreService.Context()
+	if _, err := fh.firestoreService.Seasons().Doc(season.ID).Delete(ctx); err != nil {
+		c.JSON(http.StatusInternalServerError, models.ErrorResponse{
+			Error:   "internal_error",
+			Message: "Failed to delete season",
+		})
+		return
+	}
+	if err := attachSeasonSubmissions(fh.firestoreService, field.ID); err != nil {
+		c.JSON(http.StatusInternalServerError, models.ErrorResponse{
+			Error:   "internal_error",
+			Message: "Season deleted, but its submissions could not be detached from it",
+		})
+		return
+	}
+
+	c.JSON(http.StatusOK, models.SuccessResponse{
+		Success: true,
+		Message: "Season deleted successfully",
+	})
+}
+
+// saveSeason validates a season against the other seasons of its field,
+// stores it and attaches the field's submissions to their seasons again,
+// responding with an error and returning false when that fails
+func (fh *FieldHandler) saveSeason(c *gin.Context, field *models.Field, season *models.Season) bool {
+	transplanted, err := time.Parse("2006-01-02", season.TransplantDate)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, models.ErrorResponse{
+			Error:   "invalid_request",
+			Message: "transplant_date must be a YYYY-MM-DD date",
+		})
+		return false
+	}
+	if season.HarvestDate != "" {
+		harvested, err := time.Parse("2006-01-02", season.HarvestDate)
+		if err != nil || harvested.Before(transplanted) {
+			c.JSON(http.StatusBadRequest, models.ErrorResponse{
+				Error:   "invalid_request",
+				Message: "harvest_date must be a YYYY-MM-DD date on or after transplant_date",
+			})
+			return false
+		}
+	}
+
+	seasons, err := fieldSeasons(fh.firestoreService, field.ID)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, models.ErrorResponse{
+			Error:   "internal_error",
+			Message: "Failed to retrieve seasons",
+		})
+		return false
+	}
+	for _, other := range seasons {
+		if other.ID != season.ID && seasonsOverlap(&other, season) {
+			c.JSON(http.StatusConflict, models.ErrorResponse{
+				Error:   "season_overlap",
+				Message: fmt.Sprintf("The season overlaps season %s transplanted on %s", other.ID, other.TransplantDate),
+			})
+			return false
+		}
+	}
+
+	ctx := fh.firestoreService.Context()
+	if _, err := fh.firestoreService.Seasons().Doc(season.ID).Set(ctx, season); err != nil {
+		c.JSON(http.StatusInternalServerError, models.ErrorResponse{
+			Error:   "internal_error",
+			Message: "Failed to save season",
+		})
+		return false
+	}
+
+	// The field's variety and transplant date follow its latest season
+	latest := season
+	for i := range seasons {
+		if seasons[i].ID != season.ID && seasons[i].TransplantDate > latest.TransplantDate {
+			latest = &seasons[i]
+		}
+	}
+	if latest == season {
+		updates := []firestore.Update{
+			{Path: "transplant_date", Value: season.TransplantDate},
+			{Path: "updated_at", Value: time.Now()},
+		}
+		if season.RiceVariety != "" {
+			updates = append(updates, firestore.Update{Path: "rice_variety", Value: season.RiceVariety})
+		}
+		if _, err := fh.firestoreService.Fields().Doc(field.ID).Update(ctx, updates); err != nil {
+			c.JSON(http.StatusInternalServerError, models.ErrorResponse{
+				Error:   "internal_error",
+				Message: "Season saved, but the field could not be updated to it",
+			})
+			return false
+		}
+	}
+
+	if err := attachSeasonSubmissions(fh.firestoreService, field.ID); err != nil {
+		c.JSON(http.StatusInternalServerError, models.ErrorResponse{
+			Error:   "internal_error",
+			Message: "Season saved, but its submissions could not be attached to it. Save it again to retry.",
+		})
+		return false
+	}
+	return true
+}
+
+// fieldSeason loads the season in the seasonId parameter, responding with 404
+// unless it belongs to field
+func (fh *FieldHandler) fieldSeason(c *gin.Context, field *models.Field) (*models.Season, bool) {
+	season, err := getSeason(fh.firestoreService, c.Param("seasonId"))
+	if err != nil || season.FieldID != field.ID {
+		c.JSON(http.StatusNotFound, models.ErrorResponse{
+			Error:   "not_found",
+			Message: "Season not found",
+		})
+		return nil, false
+	}
+	return season, true
+}
+
+func getSeason(firestoreService *services.FirestoreService, seasonID string) (*models.Season, error) {
+	ctx := firestoreService.Context()
+	doc, err := firestoreService.Seasons().Doc(seasonID).Get(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	var season models.Season
+	if err := doc.DataTo(&season); err != nil {
+		return nil, err
+	}
+	return &season, nil
+}
+
+// fieldSeasons returns the seasons of a field, latest first
+func fieldSeasons(firestoreService *services.FirestoreService, fieldID string) ([]models.Season, error) {
+	ctx := firestoreService.Context()
+	docs, err := firestoreService.Seasons().Where("field_id", "==", fieldID).Documents(ctx).GetAll()
+	if err != nil {
+		return nil, err
+	}
+
+	seasons := []models.Season{}
+	for _, doc := range docs {
+		var season models.Season
+		doc.DataTo(&season)
+		seasons = append(seasons, season)
+	}
+	sort.Slice(seasons, func(i, j int) bool {
+		return seasons[i].TransplantDate > seasons[j].TransplantDate
+	})
+	return seasons, nil
+}
+
+// seasonSpan returns when a season starts and ends, in UTC. A season not yet
+// harvested ends at now.
+func seasonSpan(season *models.Season, now time.Time) (time.Time, time.Time) {
+	start, _ := time.Parse("2006-01-02", season.TransplantDate)
+	end := now
+	if harvested, err := time.Parse("2006-01-02", season.HarvestDate); err == nil {
+		end = harvested.AddDate(0, 0, 1)
+	}
+	return start, end
+}
+
+// seasonsOverlap reports whether two seasons share a day. Seasons not yet
+// harvested last forever.
+func seasonsOverlap(a, b *models.Season) bool {
+	ends := func(s *models.Season) string {
+		if s.HarvestDate == "" {
+			return "9999-12-31"
+		}
+		return s.HarvestDate
+	}
+	return a.TransplantDate <= ends(b) && b.TransplantDate <= ends(a)
+}
+
+// matchSeason returns the ID of the season among seasons whose days include
+// date, in UTC, or "" when none does
+func matchSeason(seasons []models.Season, date time.Time) string {
+	day := date.UTC().Format("2006-01-02")
+	for _, season := range seasons {
+		if day >= season.TransplantDate && (season.HarvestDate == "" || day <= season.HarvestDate) {
+			return season.ID
+		}
+	}
+	return ""
+}
+
+// applySeason attaches the submission to the season of its field its date
+// falls in, if any
+func applySeason(firestoreService *services.FirestoreService, submission *models.Submission) error {
+	submission.SeasonID = ""
+	if submission.FieldID == "" {
+		return nil
+	}
+	seasons, err := fieldSeasons(firestoreService, submission.FieldID)
+	if err != nil {
+		return err
+	}
+	submission.SeasonID = matchSeason(seasons, submission.Date)
+	return nil
+}
+
+// attachSeasonSubmissions attaches every submission of a field to the season
+// its date falls in, after the field's seasons changed
+func attachSeasonSubmissions(firestoreService *services.FirestoreService, fieldID string) error {
+	seasons, err := fieldSeasons(firestoreService, fieldID)
+	if err != nil {
+		return err
+	}
+
+	ctx := firestoreService.Context()
+	docs, err := firestoreService.Submissions().Where("field_id", "==", fieldID).
+		Select("date", "season_id").Documents(ctx).GetAll()
+	if err != nil {
+		return err
+	}
+
+	var updates []*firestore.DocumentSnapshot
+	var seasonIDs []string
+	for _, doc := range docs {
+		var submission models.Submission
+		doc.DataTo(&submission)
+		if seasonID := matchSeason(seasons, submission.Date); seasonID != submission.SeasonID {
+			updates = append(updates, doc)
+			seasonIDs = append(seasonIDs, seasonID)
+		}
+	}
+
+	for i := 0; i < len(updates); i += firestoreBatchSize {
+		end := i + firestoreBatchSize
+		if end > len(updates) {
+			end = len(updates)
+		}
+		batch := firestoreService.Client.Batch()
+		for j := i; j < end; j++ {
+			var value interface{} = seasonIDs[j]
+			if seasonIDs[j] == "" {
+				value = firestore.Delete
+			}
+			batch.Update(updates[j].Ref, []firestore.Update{{Path: "season_id", Value: value}})
+		}
+		if _, err := batch.Commit(ctx); err != nil {
+			return err
+		}
+	}
+	return nil
+}
@@ -0,0 +1,122 @@
+package handlers
+
+import (
+	"net/http"
+
+	"rice-monitor-api/models"
+
+	"github.com/gin-gonic/gin"
+)
+
+// @Summary List a field's submissions
+// @Description List the submissions of one field, from every observer, to anyone who can read the field.
+// @Description Filters, time ranges and sorting work as on GET /submissions.
+// @Tags fields
+// @Produce  json
+// @Security ApiKeyAuth
+// @Param id path string true "Field ID"
+// @Param page query int false "Page number"
+// @Param limit query int false "Number of items per page, at most 100"
+// @Param status query string false "Filter by submission status"
+// @Param growth_stage query string false "Filter by growth stage"
+// @Param plant_condition query string false "Only submissions reporting this plant condition"
+// @Param tag query string false "Only submissions with this tag (not combined with plant_condition)"
+// @Param observer query string false "Filter by the user ID of the observer"
+// @Param created_from query string false "Created at or after (RFC 3339 or YYYY-MM-DD)"
+// @Param created_to query string false "Created before (RFC 3339 or YYYY-MM-DD)"
+// @Param date_from query string false "Observed on or after (RFC 3339 or YYYY-MM-DD)"
+// @Param date_to query string false "Observed before (RFC 3339 or YYYY-MM-DD)"
+// @Param sort query string false "created_at, date or status; defaults to the field of a time range, else created_at"
+// @Param order query string false "desc (default) or asc"
+// @Success 200 {object} models.SuccessResponse
+// @Failure 400 {object} models.ErrorResponse
+// @Failure 403 {object} models.ErrorResponse
+// @Failure 404 {object} models.ErrorResponse
+// @Failure 500 {object} models.ErrorResponse
+// @Router /fields/{id}/submissions [get]
+func (sh *SubmissionHandler) GetFieldSubmissions(c *gin.Context) {
+	currentUser, _ := c.Get("user")
+	user := currentUser.(*models.User)
+
+	var params models.SubmissionListParams
+	if err := c.ShouldBindQuery(&params); err != nil {
+		c.JSON(http.StatusBadRequest, models.ErrorResponse{
+			Error:   "invalid_request",
+			Message: err.Error(),
+		})
+		return
+	}
+
+	ctx := sh.firestoreService.Context()
+	doc, err := sh.firestoreService.Fields().Doc(c.Param("id")).Get(ctx)
+	if err != nil {
+		c.JSON(http.StatusNotFound, models.ErrorResponse{
+			Error:   "not_found",
+			Message: "Field not found",
+		})
+		return
+	}
+	var field models.Field
+	doc.DataTo(&field)
+
+	if !canReadField(user, &field) {
+		c.JSON(http.StatusForbidden, models.ErrorResponse{
+			Error:   "forbidden",
+			Message: "Access denied",
+		})
+		return
+	}
+
+	query := sh.firestoreService.Submissions().Where("field_id", "==", field.ID)
+	if params.Observer != "" {
+		query = query.Where("user_id", "==", params.Observer)
+	}
+	params.FieldID = ""
+	query, err = sh.filterSubmissions(query, user, params)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, models.ErrorResponse{
+			Error:   "invalid_request",
+			Message: err.Error(),
+		})
+		return
+	}
+
+	total, err := countDocuments(ctx, query)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, models.ErrorResponse{
+			Error:   "internal_error",
+			Message: "Failed to count submissions",
+		})
+		return
+	}
+
+	if params.Page > 1 {
+		query = query.Offset((params.Page - 1) * params.Limit)
+	}
+	docs, err := query.Limit(params.Limit).Documents(ctx).GetAll()
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, models.ErrorResponse{
+			Error:   "internal_error",
+			Message: "Failed to retrieve submissions",
+		})
+		return
+	}
+
+	submissions := []models.SubmissionResponse{}
+	for _, doc := range docs {
+		var submission models.Submission
+		doc.DataTo(&submission)
+		submissions = append(submissions, submissionResponse(user, submission, &field))
+	}
+
+	c.JSON(http.StatusOK, models.SuccessResponse{
+		Success: true,
+		Data: map[string]interface{}{
+			"submissions": submissions,
+			"page":        params.Page,
+			"limit":       params.Limit,
+			"total":       total,
+			"total_pages": (total + int64(params.Limit) - 1) / int64(params.Limit),
+		},
+	})
+}
@@ -1,7 +1,11 @@
 package handlers
 
 import (
+	"encoding/json"
+	"fmt"
 	"net/http"
+	"sort"
+	"strings"
 	"time"
 
 	"rice-monitor-api/models"
@@ -23,18 +27,54 @@ func NewFieldHandler(firestoreService *services.FirestoreService) *FieldHandler
 }
 
 // @Summary Get all fields
-// @Description Get a list of all fields for the user
+// @Description List the user's fields by name, a page at a time. Members of an organization see its fields, then
+// @Description the fields of other organizations shared with them. q matches the start of a field's name or
+// @Description location, or of any word in them, ignoring case. Archived fields are only listed with archived=true.
 // @Tags fields
 // @Produce  json
 // @Security ApiKeyAuth
+// @Param page query int false "Page number" default(1)
+// @Param limit query int false "Fields per page, at most 100" default(20)
+// @Param q query string false "Start of the name or location, or of a word in them"
+// @Param rice_variety query string false "Filter by rice variety"
+// @Param owner query string false "Filter by the user ID of the owner"
+// @Param archived query bool false "true to list archived fields instead, which are left out by default"
 // @Success 200 {object} models.SuccessResponse
+// @Failure 400 {object} models.ErrorResponse
 // @Failure 500 {object} models.ErrorResponse
 // @Router /fields [get]
 func (fh *FieldHandler) GetFields(c *gin.Context) {
+	var params models.FieldListParams
+	if err := c.ShouldBindQuery(&params); err != nil {
+		c.JSON(http.StatusBadRequest, models.ErrorResponse{
+			Error:   "invalid_request",
+			Message: err.Error(),
+		})
+		return
+	}
+
+	if params.Archived == nil {
+		archived := false
+		params.Archived = &archived
+	}
+
+	currentUser, _ := c.Get("user")
+	user := currentUser.(*models.User)
+
 	ctx := fh.firestoreService.Context()
-	query := fh.firestoreService.Fields().Query
+	query := filterFields(orgScope(fh.firestoreService.Fields().Query, user), params).OrderBy("name", firestore.Asc)
+
+	// Counted with an aggregation query instead of reading every field
+	total, err := countDocuments(ctx, query)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, models.ErrorResponse{
+			Error:   "internal_error",
+			Message: "Failed to count fields",
+		})
+		return
+	}
 
-	docs, err := query.Documents(ctx).GetAll()
+	shared, err := fh.sharedFields(user, params)
 	if err != nil {
 		c.JSON(http.StatusInternalServerError, models.ErrorResponse{
 			Error:   "internal_error",
@@ -43,21 +83,49 @@ func (fh *FieldHandler) GetFields(c *gin.Context) {
 		return
 	}
 
-	var fields []models.Field
-	for _, doc := range docs {
-		var field models.Field
-		doc.DataTo(&field)
-		fields = append(fields, field)
+	fields := []models.Field{}
+	offset := (params.Page - 1) * params.Limit
+	if int64(offset) < total {
+		if offset > 0 {
+			query = query.Offset(offset)
+		}
+		docs, err := query.Limit(params.Limit).Documents(ctx).GetAll()
+		if err != nil {
+			c.JSON(http.StatusInternalServerError, models.ErrorResponse{
+				Error:   "internal_error",
+				Message: "Failed to retrieve fields",
+			})
+			return
+		}
+		for _, doc := range docs {
+			var field models.Field
+			doc.DataTo(&field)
+			field.BoundaryGeoJSON = utils.GeoJSONPolygonOf(field.Boundary)
+			fields = append(fields, field)
+		}
 	}
 
+	// Shared fields are listed after the organization's
+	start := min(max(offset-int(total), 0), len(shared))
+	end := min(start+params.Limit-len(fields), len(shared))
+	fields = append(fields, shared[start:end]...)
+	total += int64(len(shared))
+
 	c.JSON(http.StatusOK, models.SuccessResponse{
 		Success: true,
-		Data:    fields,
+		Data: map[string]interface{}{
+			"fields":      fields,
+			"page":        params.Page,
+			"limit":       params.Limit,
+			"total":       total,
+			"total_pages": (total + int64(params.Limit) - 1) / int64(params.Limit),
+		},
 	})
 }
 
 // @Summary Create a new field
-// @Description Create a new field for the user
+// @Description Create a new field for the user. Its boundary can be given as vertices or as a GeoJSON polygon;
+// @Description it must be a simple polygon and the field's area is computed from it in hectares.
 // @Tags fields
 // @Accept  json
 // @Produce  json
@@ -77,18 +145,66 @@ func (fh *FieldHandler) CreateField(c *gin.Context) {
 		return
 	}
 
+	if !validTransplantDate(req.TransplantDate) {
+		c.JSON(http.StatusBadRequest, models.ErrorResponse{
+			Error:   "invalid_request",
+			Message: "transplant_date must be a YYYY-MM-DD date",
+		})
+		return
+	}
+	if !fh.checkSMSCode(c, req.SMSCode, "") {
+		return
+	}
+	if !validCoordinates(req.Coordinates) {
+		c.JSON(http.StatusBadRequest, models.ErrorResponse{
+			Error:   "invalid_request",
+			Message: "coordinates must hold a valid latitude and longitude",
+		})
+		return
+	}
+
+	area := req.Area
+	var boundary []models.Location
+	if len(req.Boundary) > 0 || req.BoundaryGeoJSON != nil {
+		var err error
+		boundary, area, err = fieldBoundary(req.Boundary, req.BoundaryGeoJSON)
+		if err != nil {
+			c.JSON(http.StatusBadRequest, models.ErrorResponse{
+				Error:   "invalid_boundary",
+				Message: err.Error(),
+			})
+			return
+		}
+	}
+
 	currentUser, _ := c.Get("user")
 	user := currentUser.(*models.User)
 
+	if err := checkFieldFarm(fh.firestoreService, req.FarmID, user.OrgID); err != nil {
+		c.JSON(http.StatusBadRequest, models.ErrorResponse{
+			Error:   "invalid_request",
+			Message: err.Error(),
+		})
+		return
+	}
+
 	field := models.Field{
 		ID:          utils.GenerateID(),
 		Name:        req.Name,
 		RiceVariety:   req.RiceVariety,
 		TentativeDate: req.TentativeDate,
+		TransplantDate: req.TransplantDate,
+		SMSCode:     req.SMSCode,
+		FarmID:      req.FarmID,
+		MonitoringCadenceDays: req.MonitoringCadenceDays,
 		Location:    req.Location,
 		Coordinates: req.Coordinates,
-		Area:        req.Area,
+		Geohash:     utils.FieldGeohash(req.Coordinates),
+		SearchTerms: utils.SearchTerms(req.Name, req.Location),
+		Boundary:    boundary,
+		Area:        area,
 		OwnerID:     user.ID,
+		OrgID:       user.OrgID,
 		CreatedAt:   time.Now(),
 		UpdatedAt:   time.Now(),
 	}
@@ -102,6 +218,7 @@ func (fh *FieldHandler) CreateField(c *gin.Context) {
 		})
 		return
 	}
+	field.BoundaryGeoJSON = utils.GeoJSONPolygonOf(field.Boundary)
 
 	c.JSON(http.StatusCreated, models.SuccessResponse{
 		Success: true,
@@ -111,7 +228,7 @@ func (fh *FieldHandler) CreateField(c *gin.Context) {
 }
 
 // @Summary Get a field by ID
-// @Description Get a single field by its ID
+// @Description Get a single field by its ID, with its current notes
 // @Tags fields
 // @Produce  json
 // @Security ApiKeyAuth
@@ -135,7 +252,7 @@ func (fh *FieldHandler) GetField(c *gin.Context) {
 	}
 
 	// Check if user can access this field
-	if user.Role != "admin" && field.OwnerID != user.ID {
+	if !canReadField(user, field) {
 		c.JSON(http.StatusForbidden, models.ErrorResponse{
 			Error:   "forbidden",
 			Message: "Access denied",
@@ -143,6 +260,16 @@ func (fh *FieldHandler) GetField(c *gin.Context) {
 		return
 	}
 
+	notes, err := fieldNotes(fh.firestoreService, []string{field.ID}, false)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, models.ErrorResponse{
+			Error:   "internal_error",
+			Message: "Failed to retrieve field notes",
+		})
+		return
+	}
+	field.Notes = notes
+
 	c.JSON(http.StatusOK, models.SuccessResponse{
 		Success: true,
 		Data:    field,
@@ -150,7 +277,8 @@ func (fh *FieldHandler) GetField(c *gin.Context) {
 }
 
 // @Summary Update a field
-// @Description Update an existing field
+// @Description Update an existing field. A new boundary, as vertices or as a GeoJSON polygon, replaces the field's
+// @Description area with its own; null removes the boundary. The area of a field with a boundary cannot be set.
 // @Tags fields
 // @Accept  json
 // @Produce  json
@@ -177,6 +305,96 @@ func (fh *FieldHandler) UpdateField(c *gin.Context) {
 		return
 	}
 
+	if value, ok := updateData["transplant_date"]; ok {
+		if date, isString := value.(string); !isString || !validTransplantDate(date) {
+			c.JSON(http.StatusBadRequest, models.ErrorResponse{
+				Error:   "invalid_request",
+				Message: "transplant_date must be a YYYY-MM-DD date",
+			})
+			return
+		}
+	}
+	if value, ok := updateData["sms_code"]; ok {
+		code, isString := value.(string)
+		if !isString {
+			c.JSON(http.StatusBadRequest, models.ErrorResponse{
+				Error:   "invalid_request",
+				Message: "sms_code must be a string of digits",
+			})
+			return
+		}
+		if !fh.checkSMSCode(c, code, fieldID) {
+			return
+		}
+	}
+
+	if value, ok := updateData["monitoring_cadence_days"]; ok {
+		days, isNumber := value.(float64)
+		if !isNumber || days != float64(int(days)) || days < 0 || days > 365 {
+			c.JSON(http.StatusBadRequest, models.ErrorResponse{
+				Error:   "invalid_request",
+				Message: "monitoring_cadence_days must be a whole number of days up to 365, 0 for the default",
+			})
+			return
+		}
+		updateData["monitoring_cadence_days"] = int(days)
+	}
+
+	// The geohash follows the coordinates
+	delete(updateData, "geohash")
+	if value, ok := updateData["coordinates"]; ok {
+		var coordinates models.Location
+		encoded, _ := json.Marshal(value)
+		if err := json.Unmarshal(encoded, &coordinates); err != nil || value == nil || !validCoordinates(coordinates) {
+			c.JSON(http.StatusBadRequest, models.ErrorResponse{
+				Error:   "invalid_request",
+				Message: "coordinates must hold a valid latitude and longitude",
+			})
+			return
+		}
+		updateData["coordinates"] = coordinates
+		if geohash := utils.FieldGeohash(coordinates); geohash != "" {
+			updateData["geohash"] = geohash
+		} else {
+			updateData["geohash"] = firestore.Delete
+		}
+	}
+
+	_, boundaryChanged := updateData["boundary"]
+	geoJSON, geoJSONChanged := updateData["boundary_geojson"]
+	delete(updateData, "boundary_geojson")
+	if boundaryChanged || geoJSONChanged {
+		boundaryChanged = true
+		var req struct {
+			Boundary        []models.Location      `json:"boundary"`
+			BoundaryGeoJSON *models.GeoJSONPolygon `json:"boundary_geojson"`
+		}
+		encoded, _ := json.Marshal(map[string]interface{}{"boundary": updateData["boundary"], "boundary_geojson": geoJSON})
+		if err := json.Unmarshal(encoded, &req); err != nil {
+			c.JSON(http.StatusBadRequest, models.ErrorResponse{
+				Error:   "invalid_boundary",
+				Message: err.Error(),
+			})
+			return
+		}
+
+		if len(req.Boundary) == 0 && req.BoundaryGeoJSON == nil {
+			// Removing the boundary keeps the area last computed
+			updateData["boundary"] = firestore.Delete
+		} else {
+			boundary, area, err := fieldBoundary(req.Boundary, req.BoundaryGeoJSON)
+			if err != nil {
+				c.JSON(http.StatusBadRequest, models.ErrorResponse{
+					Error:   "invalid_boundary",
+					Message: err.Error(),
+				})
+				return
+			}
+			updateData["boundary"] = boundary
+			updateData["area"] = area
+		}
+	}
+
 	// Get existing field
 	field, err := fh.getFieldByID(fieldID)
 	if err != nil {
@@ -188,7 +406,7 @@ func (fh *FieldHandler) UpdateField(c *gin.Context) {
 	}
 
 	// Check permissions
-	if user.Role != "admin" && field.OwnerID != user.ID {
+	if !utils.HasPermissionIn(user, utils.PermFieldsUpdateAll, field.OrgID) && field.OwnerID != user.ID {
 		c.JSON(http.StatusForbidden, models.ErrorResponse{
 			Error:   "forbidden",
 			Message: "Access denied",
@@ -196,10 +414,81 @@ func (fh *FieldHandler) UpdateField(c *gin.Context) {
 		return
 	}
 
+	value, farmSet := updateData["farm_id"]
+	farmID := ""
+	if farmSet {
+		id, isString := value.(string)
+		if value == nil {
+			id, isString = "", true
+		}
+		if !isString {
+			c.JSON(http.StatusBadRequest, models.ErrorResponse{
+				Error:   "invalid_request",
+				Message: "farm_id must be a farm ID, or empty to remove the field from its farm",
+			})
+			return
+		}
+		if err := checkFieldFarm(fh.firestoreService, id, field.OrgID); err != nil {
+			c.JSON(http.StatusBadRequest, models.ErrorResponse{
+				Error:   "invalid_request",
+				Message: err.Error(),
+			})
+			return
+		}
+		farmID = id
+		updateData["farm_id"] = id
+		if id == "" {
+			updateData["farm_id"] = firestore.Delete
+		}
+	}
+
+	// The search terms follow the name and location
+	delete(updateData, "search_terms")
+	_, nameSet := updateData["name"]
+	_, locationSet := updateData["location"]
+	if nameSet || locationSet {
+		name, location := field.Name, field.Location
+		for key, text := range map[string]*string{"name": &name, "location": &location} {
+			value, ok := updateData[key]
+			if !ok {
+				continue
+			}
+			if *text, ok = value.(string); !ok || strings.TrimSpace(*text) == "" {
+				c.JSON(http.StatusBadRequest, models.ErrorResponse{
+					Error:   "invalid_request",
+					Message: key + " must be a non-empty string",
+				})
+				return
+			}
+		}
+		updateData["search_terms"] = utils.SearchTerms(name, location)
+	}
+
+	// The area of a field with a boundary is computed from it
+	if len(field.Boundary) >= 3 && !boundaryChanged {
+		delete(updateData, "area")
+	}
+
 	// Remove sensitive fields
 	delete(updateData, "id")
 	delete(updateData, "owner_id")
+	delete(updateData, "org_id")
 	delete(updateData, "created_at")
+	// Degree days are maintained by the weather backfill
+	delete(updateData, "cumulative_gdd")
+	delete(updateData, "weather_from")
+	delete(updateData, "weather_through")
+	// Stress event tags are maintained by the stress event endpoints
+	delete(updateData, "stress_event_ids")
+	// Notes are managed through the field notes endpoints
+	delete(updateData, "notes")
+	// Collaborators are managed through the field collaborators endpoints
+	delete(updateData, "collaborators")
+	delete(updateData, "collaborator_ids")
+	// Archiving has its own endpoints
+	delete(updateData, "archived")
+	delete(updateData, "archived_at")
+	delete(updateData, "archived_by")
 	updateData["updated_at"] = time.Now()
 
 	ctx := fh.firestoreService.Context()
@@ -219,6 +508,17 @@ func (fh *FieldHandler) UpdateField(c *gin.Context) {
 		return
 	}
 
+	// Submissions follow their field to its farm
+	if farmSet {
+		if err := moveFieldSubmissions(fh.firestoreService, fieldID, farmID); err != nil {
+			c.JSON(http.StatusInternalServerError, models.ErrorResponse{
+				Error:   "internal_error",
+				Message: "Field updated, but its submissions could not be moved to its farm. Set farm_id again to retry.",
+			})
+			return
+		}
+	}
+
 	// Get updated field
 	updatedField, err := fh.getFieldByID(fieldID)
 	if err != nil {
@@ -263,7 +563,7 @@ func (fh *FieldHandler) DeleteField(c *gin.Context) {
 	}
 
 	// Check permissions
-	if user.Role != "admin" && field.OwnerID != user.ID {
+	if !utils.HasPermissionIn(user, utils.PermFieldsDelete, field.OrgID) && field.OwnerID != user.ID {
 		c.JSON(http.StatusForbidden, models.ErrorResponse{
 			Error:   "forbidden",
 			Message: "Access denied",
@@ -289,6 +589,49 @@ func (fh *FieldHandler) DeleteField(c *gin.Context) {
 	})
 }
 
+// @Summary Get critical windows of a field
+// @Description Get the predicted dates of the growth stages whose traits must be measured
+// @Description during a visit (panicle initiation, flowering). Empty until the field has a transplant_date.
+// @Tags fields
+// @Produce  json
+// @Security ApiKeyAuth
+// @Param id path string true "Field ID"
+// @Success 200 {object} models.SuccessResponse
+// @Failure 403 {object} models.ErrorResponse
+// @Failure 404 {object} models.ErrorResponse
+// @Router /fields/{id}/critical-windows [get]
+func (fh *FieldHandler) GetCriticalWindows(c *gin.Context) {
+	currentUser, _ := c.Get("user")
+	user := currentUser.(*models.User)
+
+	field, err := fh.getFieldByID(c.Param("id"))
+	if err != nil {
+		c.JSON(http.StatusNotFound, models.ErrorResponse{
+			Error:   "not_found",
+			Message: "Field not found",
+		})
+		return
+	}
+
+	if !canReadField(user, field) {
+		c.JSON(http.StatusForbidden, models.ErrorResponse{
+			Error:   "forbidden",
+			Message: "Access denied",
+		})
+		return
+	}
+
+	windows := services.PredictCriticalWindows(field)
+	if windows == nil {
+		windows = []models.CriticalWindow{}
+	}
+
+	c.JSON(http.StatusOK, models.SuccessResponse{
+		Success: true,
+		Data:    windows,
+	})
+}
+
 // Helper function
 func (fh *FieldHandler) getFieldByID(fieldID string) (*models.Field, error) {
 	ctx := fh.firestoreService.Context()
@@ -302,6 +645,151 @@ func (fh *FieldHandler) getFieldByID(fieldID string) (*models.Field, error) {
 	if err != nil {
 		return nil, err
 	}
+	field.BoundaryGeoJSON = utils.GeoJSONPolygonOf(field.Boundary)
 
 	return &field, nil
 }
+
+// validCoordinates reports whether coordinates are a latitude and longitude
+func validCoordinates(coordinates models.Location) bool {
+	return coordinates.Latitude >= -90 && coordinates.Latitude <= 90 &&
+		coordinates.Longitude >= -180 && coordinates.Longitude <= 180
+}
+
+// fieldBoundary validates a field boundary given as vertices or as a GeoJSON
+// polygon, and returns its vertices and area in hectares
+func fieldBoundary(boundary []models.Location, geoJSON *models.GeoJSONPolygon) ([]models.Location, float64, error) {
+	if geoJSON != nil {
+		if len(boundary) > 0 {
+			return nil, 0, fmt.Errorf("give either boundary or boundary_geojson, not both")
+		}
+		var err error
+		if boundary, err = utils.BoundaryFromGeoJSON(geoJSON); err != nil {
+			return nil, 0, err
+		}
+	}
+
+	boundary, err := utils.ValidateBoundary(boundary)
+	if err != nil {
+		return nil, 0, err
+	}
+	return boundary, utils.PolygonAreaHectares(boundary), nil
+}
+
+// canReadField reports whether user owns field, is assigned to it, has it
+// shared with them or may read every field
+func canReadField(user *models.User, field *models.Field) bool {
+	return utils.HasPermissionIn(user, utils.PermFieldsReadAll, field.OrgID) || field.OwnerID == user.ID ||
+		utils.Contains(user.FieldIDs, field.ID) || fieldCollaboratorRole(field, user.ID) != ""
+}
+
+// filterFields applies the filters of params to a query of fields. The
+// composite indexes they need are defined in firestore.indexes.json.
+func filterFields(query firestore.Query, params models.FieldListParams) firestore.Query {
+	if params.RiceVariety != "" {
+		query = query.Where("rice_variety", "==", params.RiceVariety)
+	}
+	if params.OwnerID != "" {
+		query = query.Where("owner_id", "==", params.OwnerID)
+	}
+	if params.Archived != nil {
+		query = query.Where("archived", "==", *params.Archived)
+	}
+	if term := utils.SearchTerm(params.Query); term != "" {
+		query = query.Where("search_terms", "array-contains", term)
+	}
+	return query
+}
+
+// fieldMatches reports whether field passes the filters of params, as
+// filterFields would select it
+func fieldMatches(field *models.Field, params models.FieldListParams) bool {
+	if params.RiceVariety != "" && field.RiceVariety != params.RiceVariety {
+		return false
+	}
+	if params.OwnerID != "" && field.OwnerID != params.OwnerID {
+		return false
+	}
+	if params.Archived != nil && field.Archived != *params.Archived {
+		return false
+	}
+	if term := utils.SearchTerm(params.Query); term != "" && !utils.Contains(utils.SearchTerms(field.Name, field.Location), term) {
+		return false
+	}
+	return true
+}
+
+// sharedFields returns the fields of other organizations shared with user
+// that match params, by name. They are few, and Firestore allows a single
+// array-contains filter per query, so they are filtered here.
+func (fh *FieldHandler) sharedFields(user *models.User, params models.FieldListParams) ([]models.Field, error) {
+	// Users outside organizations already list every field
+	if user.OrgID == "" {
+		return nil, nil
+	}
+
+	ctx := fh.firestoreService.Context()
+	docs, err := fh.firestoreService.Fields().Where("collaborator_ids", "array-contains", user.ID).Documents(ctx).GetAll()
+	if err != nil {
+		return nil, err
+	}
+
+	fields := []models.Field{}
+	for _, doc := range docs {
+		var field models.Field
+		doc.DataTo(&field)
+		if field.OrgID == user.OrgID || !fieldMatches(&field, params) {
+			continue
+		}
+		field.BoundaryGeoJSON = utils.GeoJSONPolygonOf(field.Boundary)
+		fields = append(fields, field)
+	}
+	sort.Slice(fields, func(i, j int) bool {
+		return fields[i].Name < fields[j].Name
+	})
+	return fields, nil
+}
+
+// checkSMSCode responds with an error and returns false unless code is empty
+// or a number of up to 6 digits that no other field uses
+func (fh *FieldHandler) checkSMSCode(c *gin.Context, code, fieldID string) bool {
+	if code == "" {
+		return true
+	}
+	if len(code) > 6 || strings.Trim(code, "0123456789") != "" {
+		c.JSON(http.StatusBadRequest, models.ErrorResponse{
+			Error:   "invalid_request",
+			Message: "sms_code must be a number of up to 6 digits",
+		})
+		return false
+	}
+
+	ctx := fh.firestoreService.Context()
+	docs, err := fh.firestoreService.Fields().Where("sms_code", "==", code).Documents(ctx).GetAll()
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, models.ErrorResponse{
+			Error:   "internal_error",
+			Message: "Failed to check sms_code",
+		})
+		return false
+	}
+	for _, doc := range docs {
+		if doc.Ref.ID != fieldID {
+			c.JSON(http.StatusConflict, models.ErrorResponse{
+				Error:   "sms_code_taken",
+				Message: "Another field already uses this sms_code",
+			})
+			return false
+		}
+	}
+	return true
+}
+
+// validTransplantDate reports whether date is empty or a YYYY-MM-DD date
+func validTransplantDate(date string) bool {
+	if date == "" {
+		return true
+	}
+	_, err := time.Parse("2006-01-02", date)
+	return err == nil
+}
@@ -0,0 +1,107 @@
+package handlers
+
+import (
+	"net/http"
+	"sort"
+
+	"rice-monitor-api/models"
+	"rice-monitor-api/utils"
+
+	"github.com/gin-gonic/gin"
+)
+
+// @Summary Hill trait trajectories
+// @Description Link the measurements of each marked hill or quadrat of a field across visits by hill_id,
+// @Description giving one trait trajectory per hill instead of field-level averages. Draft and rejected
+// @Description submissions are left out. Without analytics:read_all only your own submissions are included.
+// @Tags analytics
+// @Produce  json
+// @Security ApiKeyAuth
+// @Param field_id query string true "Field ID"
+// @Success 200 {object} models.SuccessResponse
+// @Failure 400 {object} models.ErrorResponse
+// @Failure 403 {object} models.ErrorResponse
+// @Failure 404 {object} models.ErrorResponse
+// @Failure 500 {object} models.ErrorResponse
+// @Router /analytics/hills [get]
+func (ah *AnalyticsHandler) GetHillTrajectories(c *gin.Context) {
+	fieldID := c.Query("field_id")
+	if fieldID == "" {
+		c.JSON(http.StatusBadRequest, models.ErrorResponse{
+			Error:   "invalid_request",
+			Message: "field_id is required",
+		})
+		return
+	}
+
+	currentUser, _ := c.Get("user")
+	user := currentUser.(*models.User)
+
+	ctx := ah.firestoreService.Context()
+	doc, err := ah.firestoreService.Fields().Doc(fieldID).Get(ctx)
+	if err != nil {
+		c.JSON(http.StatusNotFound, models.ErrorResponse{
+			Error:   "not_found",
+			Message: "Field not found",
+		})
+		return
+	}
+
+	var field models.Field
+	doc.DataTo(&field)
+	if !canReadField(user, &field) {
+		c.JSON(http.StatusForbidden, models.ErrorResponse{
+			Error:   "forbidden",
+			Message: "Access denied",
+		})
+		return
+	}
+
+	query := ah.firestoreService.Submissions().Where("field_id", "==", fieldID)
+	if !utils.HasPermissionIn(user, utils.PermAnalyticsReadAll, field.OrgID) {
+		query = query.Where("user_id", "==", user.ID)
+	}
+
+	docs, err := query.Documents(ctx).GetAll()
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, models.ErrorResponse{
+			Error:   "internal_error",
+			Message: "Failed to retrieve submissions",
+		})
+		return
+	}
+
+	byHill := map[string][]models.HillMeasurement{}
+	for _, doc := range docs {
+		var submission models.Submission
+		doc.DataTo(&submission)
+		if submission.HillID == "" || submission.Status == "draft" || submission.Status == "rejected" {
+			continue
+		}
+
+		byHill[submission.HillID] = append(byHill[submission.HillID], models.HillMeasurement{
+			SubmissionID:    submission.ID,
+			Date:            submission.Date,
+			GrowthStage:     submission.GrowthStage,
+			CulmLength:      submission.TraitMeasurements.CulmLength,
+			PanicleLength:   submission.TraitMeasurements.PanicleLength,
+			PaniclesPerHill: submission.TraitMeasurements.PaniclesPerHill,
+		})
+	}
+
+	trajectories := make([]models.HillTrajectory, 0, len(byHill))
+	for hillID, measurements := range byHill {
+		sort.Slice(measurements, func(i, j int) bool {
+			return measurements[i].Date.Before(measurements[j].Date)
+		})
+		trajectories = append(trajectories, models.HillTrajectory{HillID: hillID, Measurements: measurements})
+	}
+	sort.Slice(trajectories, func(i, j int) bool {
+		return trajectories[i].HillID < trajectories[j].HillID
+	})
+
+	c.JSON(http.StatusOK, models.SuccessResponse{
+		Success: true,
+		Data:    trajectories,
+	})
+}
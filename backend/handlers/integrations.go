@@ -0,0 +1,268 @@
+package handlers
+
+import (
+	"net/http"
+	"strings"
+	"time"
+
+	"rice-monitor-api/models"
+	"rice-monitor-api/services"
+	"rice-monitor-api/utils"
+
+	"cloud.google.com/go/firestore"
+	"github.com/gin-gonic/gin"
+)
+
+// defaultBacklogThreshold is the review backlog above which review_backlog is posted
+const defaultBacklogThreshold = 20
+
+// integrationWebhookPrefixes are the URLs each integration type may post to,
+// so an integration cannot be pointed at internal services
+var integrationWebhookPrefixes = map[string]string{
+	"slack":       "https://hooks.slack.com/",
+	"google_chat": "https://chat.googleapis.com/",
+}
+
+type IntegrationHandler struct {
+	firestoreService *services.FirestoreService
+	chatOpsService   *services.ChatOpsService
+}
+
+func NewIntegrationHandler(firestoreService *services.FirestoreService, chatOpsService *services.ChatOpsService) *IntegrationHandler {
+	return &IntegrationHandler{
+		firestoreService: firestoreService,
+		chatOpsService:   chatOpsService,
+	}
+}
+
+// @Summary List integrations
+// @Description List the team chat integrations
+// @Tags integrations
+// @Produce  json
+// @Security ApiKeyAuth
+// @Success 200 {object} models.SuccessResponse
+// @Failure 500 {object} models.ErrorResponse
+// @Router /admin/integrations [get]
+func (ih *IntegrationHandler) GetIntegrations(c *gin.Context) {
+	ctx := ih.firestoreService.Context()
+	docs, err := ih.firestoreService.Integrations().OrderBy("created_at", firestore.Desc).Documents(ctx).GetAll()
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, models.ErrorResponse{
+			Error:   "internal_error",
+			Message: "Failed to retrieve integrations",
+		})
+		return
+	}
+
+	integrations := []models.Integration{}
+	for _, doc := range docs {
+		var integration models.Integration
+		doc.DataTo(&integration)
+		integrations = append(integrations, integration)
+	}
+
+	c.JSON(http.StatusOK, models.SuccessResponse{
+		Success: true,
+		Data:    integrations,
+	})
+}
+
+// @Summary Create an integration
+// @Description Post events to a Slack or Google Chat incoming webhook.
+// @Description Events: pest_alert, review_backlog, weekly_summary
+// @Tags integrations
+// @Accept  json
+// @Produce  json
+// @Security ApiKeyAuth
+// @Param integration body models.IntegrationRequest true "Integration details"
+// @Success 201 {object} models.SuccessResponse
+// @Failure 400 {object} models.ErrorResponse
+// @Failure 500 {object} models.ErrorResponse
+// @Router /admin/integrations [post]
+func (ih *IntegrationHandler) CreateIntegration(c *gin.Context) {
+	req, ok := bindIntegration(c)
+	if !ok {
+		return
+	}
+
+	currentUser, _ := c.Get("user")
+	user := currentUser.(*models.User)
+
+	integration := models.Integration{
+		ID:        utils.GenerateID(),
+		CreatedBy: user.ID,
+		CreatedAt: time.Now(),
+	}
+	applyIntegrationRequest(&integration, req)
+
+	ctx := ih.firestoreService.Context()
+	if _, err := ih.firestoreService.Integrations().Doc(integration.ID).Set(ctx, integration); err != nil {
+		c.JSON(http.StatusInternalServerError, models.ErrorResponse{
+			Error:   "internal_error",
+			Message: "Failed to create integration",
+		})
+		return
+	}
+
+	c.JSON(http.StatusCreated, models.SuccessResponse{
+		Success: true,
+		Data:    integration,
+		Message: "Integration created successfully",
+	})
+}
+
+// @Summary Update an integration
+// @Description Replace an integration's webhook, events and settings
+// @Tags integrations
+// @Accept  json
+// @Produce  json
+// @Security ApiKeyAuth
+// @Param id path string true "Integration ID"
+// @Param integration body models.IntegrationRequest true "Integration details"
+// @Success 200 {object} models.SuccessResponse
+// @Failure 400 {object} models.ErrorResponse
+// @Failure 404 {object} models.ErrorResponse
+// @Failure 500 {object} models.ErrorResponse
+// @Router /admin/integrations/{id} [put]
+func (ih *IntegrationHandler) UpdateIntegration(c *gin.Context) {
+	integration, ok := ih.getIntegration(c)
+	if !ok {
+		return
+	}
+
+	req, ok := bindIntegration(c)
+	if !ok {
+		return
+	}
+	applyIntegrationRequest(integration, req)
+	integration.LastError = ""
+
+	ctx := ih.firestoreService.Context()
+	if _, err := ih.firestoreService.Integrations().Doc(integration.ID).Set(ctx, integration); err != nil {
+		c.JSON(http.StatusInternalServerError, models.ErrorResponse{
+			Error:   "internal_error",
+			Message: "Failed to update integration",
+		})
+		return
+	}
+
+	c.JSON(http.StatusOK, models.SuccessResponse{
+		Success: true,
+		Data:    integration,
+		Message: "Integration updated successfully",
+	})
+}
+
+// @Summary Delete an integration
+// @Description Stop posting events to an integration's webhook
+// @Tags integrations
+// @Produce  json
+// @Security ApiKeyAuth
+// @Param id path string true "Integration ID"
+// @Success 200 {object} models.SuccessResponse
+// @Failure 404 {object} models.ErrorResponse
+// @Failure 500 {object} models.ErrorResponse
+// @Router /admin/integrations/{id} [delete]
+func (ih *IntegrationHandler) DeleteIntegration(c *gin.Context) {
+	integration, ok := ih.getIntegration(c)
+	if !ok {
+		return
+	}
+
+	ctx := ih.firestoreService.Context()
+	if _, err := ih.firestoreService.Integrations().Doc(integration.ID).Delete(ctx); err != nil {
+		c.JSON(http.StatusInternalServerError, models.ErrorResponse{
+			Error:   "internal_error",
+			Message: "Failed to delete integration",
+		})
+		return
+	}
+
+	c.JSON(http.StatusOK, models.SuccessResponse{
+		Success: true,
+		Message: "Integration deleted successfully",
+	})
+}
+
+// @Summary Test an integration
+// @Description Post a test message to the integration's webhook
+// @Tags integrations
+// @Produce  json
+// @Security ApiKeyAuth
+// @Param id path string true "Integration ID"
+// @Success 200 {object} models.SuccessResponse
+// @Failure 404 {object} models.ErrorResponse
+// @Failure 502 {object} models.ErrorResponse
+// @Router /admin/integrations/{id}/test [post]
+func (ih *IntegrationHandler) TestIntegration(c *gin.Context) {
+	integration, ok := ih.getIntegration(c)
+	if !ok {
+		return
+	}
+
+	text := "Rice Monitor test message for integration " + integration.Name
+	if err := ih.chatOpsService.Post(integration, text); err != nil {
+		c.JSON(http.StatusBadGateway, models.ErrorResponse{
+			Error:   "webhook_failed",
+			Message: err.Error(),
+		})
+		return
+	}
+
+	c.JSON(http.StatusOK, models.SuccessResponse{
+		Success: true,
+		Message: "Test message posted successfully",
+	})
+}
+
+func (ih *IntegrationHandler) getIntegration(c *gin.Context) (*models.Integration, bool) {
+	ctx := ih.firestoreService.Context()
+	doc, err := ih.firestoreService.Integrations().Doc(c.Param("id")).Get(ctx)
+	if err != nil {
+		c.JSON(http.StatusNotFound, models.ErrorResponse{
+			Error:   "not_found",
+			Message: "Integration not found",
+		})
+		return nil, false
+	}
+
+	var integration models.Integration
+	doc.DataTo(&integration)
+	return &integration, true
+}
+
+// bindIntegration binds the request and checks the webhook belongs to the
+// integration's chat service
+func bindIntegration(c *gin.Context) (*models.IntegrationRequest, bool) {
+	var req models.IntegrationRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, models.ErrorResponse{
+			Error:   "invalid_request",
+			Message: err.Error(),
+		})
+		return nil, false
+	}
+
+	prefix := integrationWebhookPrefixes[req.Type]
+	if !strings.HasPrefix(req.WebhookURL, prefix) {
+		c.JSON(http.StatusBadRequest, models.ErrorResponse{
+			Error:   "invalid_webhook_url",
+			Message: "A " + req.Type + " webhook URL must start with " + prefix,
+		})
+		return nil, false
+	}
+	return &req, true
+}
+
+func applyIntegrationRequest(integration *models.Integration, req *models.IntegrationRequest) {
+	integration.Name = req.Name
+	integration.Type = req.Type
+	integration.WebhookURL = req.WebhookURL
+	integration.Events = req.Events
+	integration.BacklogThreshold = defaultBacklogThreshold
+	if req.BacklogThreshold != nil {
+		integration.BacklogThreshold = *req.BacklogThreshold
+	}
+	integration.Enabled = req.Enabled == nil || *req.Enabled
+	integration.UpdatedAt = time.Now()
+}
@@ -0,0 +1,249 @@
+package handlers
+
+import (
+	"fmt"
+	"log"
+	"net/http"
+	"strings"
+	"time"
+
+	"rice-monitor-api/models"
+	"rice-monitor-api/services"
+	"rice-monitor-api/utils"
+
+	"cloud.google.com/go/firestore"
+	"github.com/gin-gonic/gin"
+)
+
+type InviteHandler struct {
+	firestoreService *services.FirestoreService
+	mailerService    *services.MailerService
+}
+
+func NewInviteHandler(firestoreService *services.FirestoreService, mailerService *services.MailerService) *InviteHandler {
+	return &InviteHandler{
+		firestoreService: firestoreService,
+		mailerService:    mailerService,
+	}
+}
+
+// @Summary List invites
+// @Description List all invites, newest first
+// @Tags invites
+// @Produce  json
+// @Security ApiKeyAuth
+// @Success 200 {object} models.SuccessResponse
+// @Failure 500 {object} models.ErrorResponse
+// @Router /invites [get]
+func (ih *InviteHandler) GetInvites(c *gin.Context) {
+	ctx := ih.firestoreService.Context()
+	docs, err := ih.firestoreService.Invites().OrderBy("created_at", firestore.Desc).Documents(ctx).GetAll()
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, models.ErrorResponse{
+			Error:   "internal_error",
+			Message: "Failed to retrieve invites",
+		})
+		return
+	}
+
+	invites := []models.Invite{}
+	for _, doc := range docs {
+		var invite models.Invite
+		doc.DataTo(&invite)
+		invites = append(invites, invite)
+	}
+
+	c.JSON(http.StatusOK, models.SuccessResponse{
+		Success: true,
+		Data:    invites,
+	})
+}
+
+// @Summary Create an invite
+// @Description Create a single-use invite code with a role and optional field assignments.
+// @Description When an email is given, only that address can use the invite and the code is emailed to it.
+// @Tags invites
+// @Accept  json
+// @Produce  json
+// @Security ApiKeyAuth
+// @Param invite body models.CreateInviteRequest true "Invite details"
+// @Success 201 {object} models.SuccessResponse
+// @Failure 400 {object} models.ErrorResponse
+// @Failure 500 {object} models.ErrorResponse
+// @Router /invites [post]
+func (ih *InviteHandler) CreateInvite(c *gin.Context) {
+	var req models.CreateInviteRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, models.ErrorResponse{
+			Error:   "invalid_request",
+			Message: err.Error(),
+		})
+		return
+	}
+
+	if !utils.Contains(utils.Roles, req.Role) {
+		c.JSON(http.StatusBadRequest, models.ErrorResponse{
+			Error:   "invalid_role",
+			Message: "Role must be one of: " + strings.Join(utils.Roles, ", "),
+		})
+		return
+	}
+	// Invited users join no organization, so they are made org_admin after being added to one
+	if req.Role == "org_admin" {
+		c.JSON(http.StatusBadRequest, models.ErrorResponse{
+			Error:   "invalid_role",
+			Message: "Invite as researcher, add the user to an organization, then make them org_admin",
+		})
+		return
+	}
+
+	if err := ih.checkFields(req.FieldIDs); err != nil {
+		c.JSON(http.StatusBadRequest, models.ErrorResponse{
+			Error:   "invalid_request",
+			Message: err.Error(),
+		})
+		return
+	}
+
+	if req.ExpiresInDays == 0 {
+		req.ExpiresInDays = 14
+	}
+	if req.FieldIDs == nil {
+		req.FieldIDs = []string{}
+	}
+
+	currentUser, _ := c.Get("user")
+	user := currentUser.(*models.User)
+
+	code, err := utils.GenerateSecureToken(24)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, models.ErrorResponse{
+			Error:   "internal_error",
+			Message: "Failed to generate invite code",
+		})
+		return
+	}
+	code = utils.InviteCodePrefix + code
+
+	invite := models.Invite{
+		ID:        utils.GenerateID(),
+		Email:     normalizeEmail(req.Email),
+		Role:      req.Role,
+		FieldIDs:  req.FieldIDs,
+		Prefix:    code[:len(utils.InviteCodePrefix)+8],
+		CodeHash:  utils.HashToken(code),
+		CreatedBy: user.ID,
+		CreatedAt: time.Now(),
+		ExpiresAt: time.Now().AddDate(0, 0, req.ExpiresInDays),
+	}
+
+	ctx := ih.firestoreService.Context()
+	_, err = ih.firestoreService.Invites().Doc(invite.ID).Set(ctx, invite)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, models.ErrorResponse{
+			Error:   "internal_error",
+			Message: "Failed to create invite",
+		})
+		return
+	}
+
+	if invite.Email != "" {
+		if err := ih.sendInviteEmail(&invite, user, code); err != nil {
+			log.Printf("Failed to send invite email: %v", err)
+		}
+	}
+
+	c.JSON(http.StatusCreated, models.SuccessResponse{
+		Success: true,
+		Data: models.CreateInviteResponse{
+			Invite: invite,
+			Code:   code,
+		},
+		Message: "Invite created successfully. Store the code now, it will not be shown again",
+	})
+}
+
+// @Summary Revoke an invite
+// @Description Revoke an unused invite by its ID
+// @Tags invites
+// @Produce  json
+// @Security ApiKeyAuth
+// @Param id path string true "Invite ID"
+// @Success 200 {object} models.SuccessResponse
+// @Failure 404 {object} models.ErrorResponse
+// @Failure 409 {object} models.ErrorResponse
+// @Failure 500 {object} models.ErrorResponse
+// @Router /invites/{id} [delete]
+func (ih *InviteHandler) RevokeInvite(c *gin.Context) {
+	inviteID := c.Param("id")
+
+	ctx := ih.firestoreService.Context()
+	doc, err := ih.firestoreService.Invites().Doc(inviteID).Get(ctx)
+	if err != nil {
+		c.JSON(http.StatusNotFound, models.ErrorResponse{
+			Error:   "not_found",
+			Message: "Invite not found",
+		})
+		return
+	}
+
+	var invite models.Invite
+	doc.DataTo(&invite)
+
+	if invite.Revoked || invite.UsedBy != "" {
+		c.JSON(http.StatusConflict, models.ErrorResponse{
+			Error:   "invite_closed",
+			Message: "Invite has already been used or revoked",
+		})
+		return
+	}
+
+	_, err = ih.firestoreService.Invites().Doc(inviteID).Update(ctx, []firestore.Update{
+		{Path: "revoked", Value: true},
+	})
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, models.ErrorResponse{
+			Error:   "internal_error",
+			Message: "Failed to revoke invite",
+		})
+		return
+	}
+
+	c.JSON(http.StatusOK, models.SuccessResponse{
+		Success: true,
+		Message: "Invite revoked successfully",
+	})
+}
+
+// Helper functions
+
+// checkFields returns an error naming the first field ID that does not exist
+func (ih *InviteHandler) checkFields(fieldIDs []string) error {
+	if len(fieldIDs) == 0 {
+		return nil
+	}
+
+	refs := make([]*firestore.DocumentRef, 0, len(fieldIDs))
+	for _, id := range fieldIDs {
+		refs = append(refs, ih.firestoreService.Fields().Doc(id))
+	}
+
+	docs, err := ih.firestoreService.Client.GetAll(ih.firestoreService.Context(), refs)
+	if err != nil {
+		return err
+	}
+	for i, doc := range docs {
+		if !doc.Exists() {
+			return fmt.Errorf("field %s not found", fieldIDs[i])
+		}
+	}
+	return nil
+}
+
+func (ih *InviteHandler) sendInviteEmail(invite *models.Invite, inviter *models.User, code string) error {
+	signupURL := fmt.Sprintf("%s/?invite=%s",
+		utils.GetEnvOrDefault("FRONTEND_URL", "http://localhost:3000"), code)
+	body := fmt.Sprintf("Hello,\n\n%s has invited you to join Rice Monitor as %s. Use the link below to create your account, or enter the invite code when signing in with Google. The invite expires on %s.\n\n%s\n\nInvite code: %s\n",
+		inviter.Name, invite.Role, utils.FormatDate(invite.ExpiresAt), signupURL, code)
+	return ih.mailerService.Send([]string{invite.Email}, "You're invited to Rice Monitor", body)
+}
@@ -0,0 +1,309 @@
+package handlers
+
+import (
+	"net/http"
+	"reflect"
+	"sort"
+	"strings"
+	"time"
+
+	"rice-monitor-api/models"
+	"rice-monitor-api/services"
+	"rice-monitor-api/utils"
+
+	"github.com/gin-gonic/gin"
+)
+
+// unitDescriptions spells out the units used by traits and export columns
+var unitDescriptions = map[string]string{
+	"cm":    "Centimetres",
+	"count": "Whole number of items",
+	"C":     "Degrees Celsius",
+	"%":     "Percent",
+	"mm":    "Millimetres",
+}
+
+type MetaHandler struct {
+	vocabularyService *services.VocabularyService
+	configService     *services.ConfigService
+}
+
+func NewMetaHandler(vocabularyService *services.VocabularyService, configService *services.ConfigService) *MetaHandler {
+	return &MetaHandler{
+		vocabularyService: vocabularyService,
+		configService:     configService,
+	}
+}
+
+// @Summary Data dictionary
+// @Description Describe submissions for downstream analysts: a JSON Schema of a submission, the trait
+// @Description registry with units, the status, growth stage and plant condition vocabularies with
+// @Description their labels in every locale, and the columns of the submissions and dataset exports.
+// @Description It is built from the registries the API itself uses, so it is always current.
+// @Tags meta
+// @Produce  json
+// @Produce  text/csv
+// @Param format query string false "json (default) or csv"
+// @Success 200 {object} models.SuccessResponse
+// @Failure 400 {object} models.ErrorResponse
+// @Router /meta/data-dictionary [get]
+func (mh *MetaHandler) GetDataDictionary(c *gin.Context) {
+	format := c.DefaultQuery("format", "json")
+	if format != "json" && format != "csv" {
+		c.JSON(http.StatusBadRequest, models.ErrorResponse{
+			Error:   "invalid_format",
+			Message: "format must be json or csv",
+		})
+		return
+	}
+
+	dictionary := mh.dataDictionary()
+	if format == "csv" {
+		writeExport(c, "csv", "data-dictionary", []string{"Section", "Name", "Type", "Unit", "Allowed Values", "Description"}, dataDictionaryRows(dictionary))
+		return
+	}
+
+	c.JSON(http.StatusOK, models.SuccessResponse{
+		Success: true,
+		Data:    dictionary,
+	})
+}
+
+// @Summary Configuration versions
+// @Description Get the version of the configuration bundle and of each of its sections (vocabularies,
+// @Description traits, form_templates, crop_calendars). Clients compare them with the versions they
+// @Description cached and download only the sections that changed.
+// @Tags meta
+// @Produce  json
+// @Success 200 {object} models.SuccessResponse
+// @Success 304 "Not modified"
+// @Router /meta/config/version [get]
+func (mh *MetaHandler) GetConfigVersion(c *gin.Context) {
+	manifest := mh.configService.Manifest()
+	if notModified(c, manifest.Version) {
+		return
+	}
+
+	c.JSON(http.StatusOK, models.SuccessResponse{
+		Success: true,
+		Data:    manifest,
+	})
+}
+
+// @Summary Configuration bundle
+// @Description Get the configuration offline forms need: vocabularies with their labels and synonyms, the
+// @Description trait registry, form templates and crop calendars. The ETag is the bundle version; send it
+// @Description back in If-None-Match to get 304 when nothing changed.
+// @Tags meta
+// @Produce  json
+// @Param sections query string false "Comma separated sections to include, all by default"
+// @Success 200 {object} models.SuccessResponse
+// @Success 304 "Not modified"
+// @Failure 400 {object} models.ErrorResponse
+// @Router /meta/config [get]
+func (mh *MetaHandler) GetConfig(c *gin.Context) {
+	var names []string
+	if param := c.Query("sections"); param != "" {
+		for _, name := range strings.Split(param, ",") {
+			name = strings.TrimSpace(name)
+			if !utils.Contains(services.ConfigSections, name) {
+				c.JSON(http.StatusBadRequest, models.ErrorResponse{
+					Error:   "invalid_request",
+					Message: "sections must be among: " + strings.Join(services.ConfigSections, ", "),
+				})
+				return
+			}
+			names = append(names, name)
+		}
+	}
+
+	bundle := mh.configService.Bundle(names)
+	if notModified(c, bundle.Version) {
+		return
+	}
+
+	c.JSON(http.StatusOK, models.SuccessResponse{
+		Success: true,
+		Data:    bundle,
+	})
+}
+
+// notModified sets the ETag of a response with version and answers 304 when
+// the client's If-None-Match already holds it
+func notModified(c *gin.Context, version string) bool {
+	etag := `"` + version + `"`
+	c.Header("ETag", etag)
+	c.Header("Cache-Control", "no-cache")
+
+	for _, candidate := range strings.Split(c.GetHeader("If-None-Match"), ",") {
+		candidate = strings.TrimPrefix(strings.TrimSpace(candidate), "W/")
+		if candidate == etag || candidate == "*" {
+			c.Status(http.StatusNotModified)
+			return true
+		}
+	}
+	return false
+}
+
+// @Summary Vocabularies
+// @Description Get the canonical submission statuses, growth stages and plant conditions of this deployment,
+// @Description in order, with their labels in every locale. growth_stage and plant_conditions of submissions
+// @Description must be among these codes; other capitalization is accepted and stored as the code.
+// @Tags meta
+// @Produce  json
+// @Success 200 {object} models.SuccessResponse
+// @Router /vocabularies [get]
+func (mh *MetaHandler) GetVocabularies(c *gin.Context) {
+	c.JSON(http.StatusOK, models.SuccessResponse{
+		Success: true,
+		Data:    mh.vocabularyTerms(),
+	})
+}
+
+// submissionVocabularies returns the codes of each controlled vocabulary of submissions
+func (mh *MetaHandler) submissionVocabularies() map[string][]string {
+	return map[string][]string{
+		"statuses":         utils.SubmissionStatuses,
+		"growth_stages":    mh.vocabularyService.GrowthStages(),
+		"plant_conditions": mh.vocabularyService.PlantConditions(),
+	}
+}
+
+// vocabularyTerms returns each vocabulary's codes with their labels in every locale
+func (mh *MetaHandler) vocabularyTerms() map[string][]models.VocabularyTerm {
+	vocabularies := map[string][]models.VocabularyTerm{}
+	for name, codes := range mh.submissionVocabularies() {
+		terms := make([]models.VocabularyTerm, len(codes))
+		for i, code := range codes {
+			terms[i] = models.VocabularyTerm{Code: code, Labels: map[string]string{}}
+		}
+		for _, locale := range mh.vocabularyService.Locales() {
+			labels, _ := mh.vocabularyService.Labels(locale)
+			byCode := map[string]map[string]string{
+				"statuses":         labels.Statuses,
+				"growth_stages":    labels.GrowthStages,
+				"plant_conditions": labels.Conditions,
+			}[name]
+			for i, term := range terms {
+				if label, ok := byCode[term.Code]; ok {
+					terms[i].Labels[locale] = label
+				}
+			}
+		}
+		vocabularies[name] = terms
+	}
+	return vocabularies
+}
+
+func (mh *MetaHandler) dataDictionary() models.DataDictionary {
+	vocabularies := mh.submissionVocabularies()
+
+	schema := utils.JSONSchema(reflect.TypeOf(models.Submission{}))
+	schema["$schema"] = "https://json-schema.org/draft/2020-12/schema"
+	schema["title"] = "Submission"
+	properties := schema["properties"].(map[string]interface{})
+	properties["status"].(map[string]interface{})["enum"] = vocabularies["statuses"]
+	properties["growth_stage"].(map[string]interface{})["enum"] = vocabularies["growth_stages"]
+	properties["plant_conditions"].(map[string]interface{})["items"].(map[string]interface{})["enum"] = vocabularies["plant_conditions"]
+	for _, name := range []string{"trait_measurements", "raw_measurements"} {
+		traits := properties[name].(map[string]interface{})["properties"].(map[string]interface{})
+		for _, trait := range utils.Traits {
+			traits[trait.Key].(map[string]interface{})["description"] = trait.Description
+			traits[trait.Key].(map[string]interface{})["x-unit"] = trait.Unit
+		}
+	}
+
+	dictionary := models.DataDictionary{
+		GeneratedAt:  time.Now(),
+		Schema:       schema,
+		Traits:       utils.Traits,
+		Vocabularies: mh.vocabularyTerms(),
+		Units:        map[string]string{},
+		Exports: map[string][]models.ExportColumn{
+			"submissions": submissionExportColumns,
+			"datasets":    datasetExportColumns,
+			"replicates":  replicateExportColumns,
+		},
+	}
+
+	units := []string{}
+	for _, trait := range utils.Traits {
+		units = append(units, trait.Unit)
+	}
+	for _, columns := range dictionary.Exports {
+		for _, column := range columns {
+			units = append(units, column.Unit)
+		}
+	}
+	for _, unit := range units {
+		if unit != "" {
+			dictionary.Units[unit] = unitDescriptions[unit]
+		}
+	}
+
+	return dictionary
+}
+
+// dataDictionaryRows flattens the dictionary into one row per submission
+// property, trait, vocabulary term and export column
+func dataDictionaryRows(dictionary models.DataDictionary) [][]string {
+	var rows [][]string
+
+	var addProperties func(prefix string, schema map[string]interface{})
+	addProperties = func(prefix string, schema map[string]interface{}) {
+		properties, _ := schema["properties"].(map[string]interface{})
+		names := make([]string, 0, len(properties))
+		for name := range properties {
+			names = append(names, name)
+		}
+		sort.Strings(names)
+
+		for _, name := range names {
+			property := properties[name].(map[string]interface{})
+			kind, _ := property["type"].(string)
+			if format, ok := property["format"].(string); ok {
+				kind = format
+			}
+			enum, _ := property["enum"].([]string)
+			if items, ok := property["items"].(map[string]interface{}); ok {
+				if values, ok := items["enum"].([]string); ok {
+					enum = values
+				}
+			}
+			unit, _ := property["x-unit"].(string)
+			description, _ := property["description"].(string)
+			rows = append(rows, []string{"submission", prefix + name, kind, unit, strings.Join(enum, ";"), description})
+			if kind == "object" {
+				addProperties(prefix+name+".", property)
+			}
+		}
+	}
+	addProperties("", dictionary.Schema)
+
+	for _, trait := range dictionary.Traits {
+		rows = append(rows, []string{"trait", trait.Key, trait.Type, trait.Unit, "", trait.Description})
+	}
+
+	for _, name := range []string{"statuses", "growth_stages", "plant_conditions"} {
+		for _, term := range dictionary.Vocabularies[name] {
+			locales := make([]string, 0, len(term.Labels))
+			for locale := range term.Labels {
+				locales = append(locales, locale)
+			}
+			sort.Strings(locales)
+			labels := make([]string, len(locales))
+			for i, locale := range locales {
+				labels[i] = locale + "=" + term.Labels[locale]
+			}
+			rows = append(rows, []string{"vocabulary:" + name, term.Code, "string", "", "", strings.Join(labels, "; ")})
+		}
+	}
+
+	for _, name := range []string{"submissions", "datasets"} {
+		for _, column := range dictionary.Exports[name] {
+			rows = append(rows, []string{"export:" + name, column.Name, column.Type, column.Unit, "", column.Description})
+		}
+	}
+
+	return rows
+}
@@ -0,0 +1,147 @@
+package handlers
+
+import (
+	"net/http"
+
+	"rice-monitor-api/models"
+	"rice-monitor-api/utils"
+
+	"github.com/gin-gonic/gin"
+)
+
+// @Summary Get notification settings
+// @Description Get which emails, digests and alerts a user receives. Users who never saved settings get the defaults.
+// @Tags users
+// @Produce  json
+// @Security ApiKeyAuth
+// @Param id path string true "User ID"
+// @Success 200 {object} models.SuccessResponse
+// @Failure 403 {object} models.ErrorResponse
+// @Failure 404 {object} models.ErrorResponse
+// @Failure 500 {object} models.ErrorResponse
+// @Router /users/{id}/notification-settings [get]
+func (uh *UserHandler) GetNotificationSettings(c *gin.Context) {
+	userID := c.Param("id")
+	if !uh.notificationSettingsTarget(c, userID, utils.PermUsersRead) {
+		return
+	}
+
+	settings, err := uh.notificationSettings.Get(userID)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, models.ErrorResponse{
+			Error:   "internal_error",
+			Message: "Failed to retrieve notification settings",
+		})
+		return
+	}
+
+	c.JSON(http.StatusOK, models.SuccessResponse{
+		Success: true,
+		Data:    settings,
+	})
+}
+
+// @Summary Update notification settings
+// @Description Update which emails, digests and alerts a user receives. Omitted values are left unchanged;
+// @Description alert thresholds are replaced together, and a threshold of 0 turns that alert off.
+// @Tags users
+// @Accept  json
+// @Produce  json
+// @Security ApiKeyAuth
+// @Param id path string true "User ID"
+// @Param settings body models.NotificationSettingsRequest true "Settings to change"
+// @Success 200 {object} models.SuccessResponse
+// @Failure 400 {object} models.ErrorResponse
+// @Failure 403 {object} models.ErrorResponse
+// @Failure 404 {object} models.ErrorResponse
+// @Failure 500 {object} models.ErrorResponse
+// @Router /users/{id}/notification-settings [put]
+func (uh *UserHandler) UpdateNotificationSettings(c *gin.Context) {
+	userID := c.Param("id")
+	if !uh.notificationSettingsTarget(c, userID, utils.PermUsersManage) {
+		return
+	}
+
+	var req models.NotificationSettingsRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, models.ErrorResponse{
+			Error:   "invalid_request",
+			Message: err.Error(),
+		})
+		return
+	}
+
+	settings, err := uh.notificationSettings.Update(userID, req)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, models.ErrorResponse{
+			Error:   "internal_error",
+			Message: "Failed to update notification settings",
+		})
+		return
+	}
+
+	c.JSON(http.StatusOK, models.SuccessResponse{
+		Success: true,
+		Data:    settings,
+		Message: "Notification settings updated successfully",
+	})
+}
+
+// @Summary Reset notification settings
+// @Description Delete a user's notification settings, so the defaults apply again
+// @Tags users
+// @Produce  json
+// @Security ApiKeyAuth
+// @Param id path string true "User ID"
+// @Success 200 {object} models.SuccessResponse
+// @Failure 403 {object} models.ErrorResponse
+// @Failure 404 {object} models.ErrorResponse
+// @Failure 500 {object} models.ErrorResponse
+// @Router /users/{id}/notification-settings [delete]
+func (uh *UserHandler) ResetNotificationSettings(c *gin.Context) {
+	userID := c.Param("id")
+	if !uh.notificationSettingsTarget(c, userID, utils.PermUsersManage) {
+		return
+	}
+
+	if err := uh.notificationSettings.Reset(userID); err != nil {
+		c.JSON(http.StatusInternalServerError, models.ErrorResponse{
+			Error:   "internal_error",
+			Message: "Failed to reset notification settings",
+		})
+		return
+	}
+
+	settings, err := uh.notificationSettings.Get(userID)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, models.ErrorResponse{
+			Error:   "internal_error",
+			Message: "Failed to retrieve notification settings",
+		})
+		return
+	}
+
+	c.JSON(http.StatusOK, models.SuccessResponse{
+		Success: true,
+		Data:    settings,
+		Message: "Notification settings reset to the defaults",
+	})
+}
+
+// notificationSettingsTarget responds with an error and returns false unless
+// the current user may act on userID's settings with perm and the user exists
+func (uh *UserHandler) notificationSettingsTarget(c *gin.Context, userID string, perm utils.Permission) bool {
+	currentUser, _ := c.Get("user")
+	if !uh.canAccessUser(c, currentUser.(*models.User), userID, perm) {
+		return false
+	}
+
+	if _, err := uh.getUserByID(userID); err != nil {
+		c.JSON(http.StatusNotFound, models.ErrorResponse{
+			Error:   "not_found",
+			Message: "User not found",
+		})
+		return false
+	}
+	return true
+}
@@ -0,0 +1,460 @@
+package handlers
+
+import (
+	"net/http"
+	"time"
+
+	"rice-monitor-api/models"
+	"rice-monitor-api/services"
+	"rice-monitor-api/utils"
+
+	"cloud.google.com/go/firestore"
+	"github.com/gin-gonic/gin"
+)
+
+type OrganizationHandler struct {
+	firestoreService *services.FirestoreService
+}
+
+func NewOrganizationHandler(firestoreService *services.FirestoreService) *OrganizationHandler {
+	return &OrganizationHandler{
+		firestoreService: firestoreService,
+	}
+}
+
+// orgScope narrows a query over records carrying org_id to the user's
+// organization when they belong to one
+func orgScope(query firestore.Query, user *models.User) firestore.Query {
+	if user.OrgID == "" {
+		return query
+	}
+	return query.Where("org_id", "==", user.OrgID)
+}
+
+// @Summary List organizations
+// @Description List every organization, or only your own unless you can manage organizations
+// @Tags organizations
+// @Produce  json
+// @Security ApiKeyAuth
+// @Success 200 {object} models.SuccessResponse
+// @Failure 500 {object} models.ErrorResponse
+// @Router /organizations [get]
+func (oh *OrganizationHandler) GetOrganizations(c *gin.Context) {
+	currentUser, _ := c.Get("user")
+	user := currentUser.(*models.User)
+
+	ctx := oh.firestoreService.Context()
+	organizations := []models.Organization{}
+
+	if !utils.HasPermissionIn(user, utils.PermOrganizationsManage, "") {
+		if user.OrgID != "" {
+			if organization, err := oh.getOrganization(user.OrgID); err == nil {
+				organizations = append(organizations, *organization)
+			}
+		}
+		c.JSON(http.StatusOK, models.SuccessResponse{
+			Success: true,
+			Data:    organizations,
+		})
+		return
+	}
+
+	docs, err := oh.firestoreService.Organizations().OrderBy("name", firestore.Asc).Documents(ctx).GetAll()
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, models.ErrorResponse{
+			Error:   "internal_error",
+			Message: "Failed to retrieve organizations",
+		})
+		return
+	}
+
+	for _, doc := range docs {
+		var organization models.Organization
+		doc.DataTo(&organization)
+		organizations = append(organizations, organization)
+	}
+
+	c.JSON(http.StatusOK, models.SuccessResponse{
+		Success: true,
+		Data:    organizations,
+	})
+}
+
+// @Summary Get an organization
+// @Description Get an organization you belong to, or any organization if you can manage them
+// @Tags organizations
+// @Produce  json
+// @Security ApiKeyAuth
+// @Param id path string true "Organization ID"
+// @Success 200 {object} models.SuccessResponse
+// @Failure 404 {object} models.ErrorResponse
+// @Router /organizations/{id} [get]
+func (oh *OrganizationHandler) GetOrganization(c *gin.Context) {
+	orgID := c.Param("id")
+	currentUser, _ := c.Get("user")
+	user := currentUser.(*models.User)
+
+	// Other organizations are reported as missing so their names are not revealed
+	organization, err := oh.getOrganization(orgID)
+	if err != nil || (user.OrgID != orgID && !utils.HasPermissionIn(user, utils.PermOrganizationsManage, orgID)) {
+		c.JSON(http.StatusNotFound, models.ErrorResponse{
+			Error:   "not_found",
+			Message: "Organization not found",
+		})
+		return
+	}
+
+	c.JSON(http.StatusOK, models.SuccessResponse{
+		Success: true,
+		Data:    organization,
+	})
+}
+
+// @Summary Create an organization
+// @Description Create a research group. Fields and submissions created by its members are only visible within it.
+// @Tags organizations
+// @Accept  json
+// @Produce  json
+// @Security ApiKeyAuth
+// @Param organization body models.OrganizationRequest true "Organization details"
+// @Success 201 {object} models.SuccessResponse
+// @Failure 400 {object} models.ErrorResponse
+// @Failure 500 {object} models.ErrorResponse
+// @Router /organizations [post]
+func (oh *OrganizationHandler) CreateOrganization(c *gin.Context) {
+	var req models.OrganizationRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, models.ErrorResponse{
+			Error:   "invalid_request",
+			Message: err.Error(),
+		})
+		return
+	}
+
+	currentUser, _ := c.Get("user")
+	user := currentUser.(*models.User)
+
+	organization := models.Organization{
+		ID:          utils.GenerateID(),
+		Name:        req.Name,
+		Description: req.Description,
+		CreatedBy:   user.ID,
+		CreatedAt:   time.Now(),
+		UpdatedAt:   time.Now(),
+	}
+
+	ctx := oh.firestoreService.Context()
+	if _, err := oh.firestoreService.Organizations().Doc(organization.ID).Set(ctx, organization); err != nil {
+		c.JSON(http.StatusInternalServerError, models.ErrorResponse{
+			Error:   "internal_error",
+			Message: "Failed to create organization",
+		})
+		return
+	}
+
+	c.JSON(http.StatusCreated, models.SuccessResponse{
+		Success: true,
+		Data:    organization,
+		Message: "Organization created successfully",
+	})
+}
+
+// @Summary Update an organization
+// @Description Rename an organization or change its description
+// @Tags organizations
+// @Accept  json
+// @Produce  json
+// @Security ApiKeyAuth
+// @Param id path string true "Organization ID"
+// @Param organization body models.OrganizationRequest true "Organization details"
+// @Success 200 {object} models.SuccessResponse
+// @Failure 400 {object} models.ErrorResponse
+// @Failure 404 {object} models.ErrorResponse
+// @Failure 500 {object} models.ErrorResponse
+// @Router /organizations/{id} [put]
+func (oh *OrganizationHandler) UpdateOrganization(c *gin.Context) {
+	orgID := c.Param("id")
+
+	var req models.OrganizationRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, models.ErrorResponse{
+			Error:   "invalid_request",
+			Message: err.Error(),
+		})
+		return
+	}
+
+	organization, err := oh.getOrganization(orgID)
+	if err != nil {
+		c.JSON(http.StatusNotFound, models.ErrorResponse{
+			Error:   "not_found",
+			Message: "Organization not found",
+		})
+		return
+	}
+
+	organization.Name = req.Name
+	organization.Description = req.Description
+	organization.UpdatedAt = time.Now()
+
+	ctx := oh.firestoreService.Context()
+	if _, err := oh.firestoreService.Organizations().Doc(orgID).Set(ctx, organization); err != nil {
+		c.JSON(http.StatusInternalServerError, models.ErrorResponse{
+			Error:   "internal_error",
+			Message: "Failed to update organization",
+		})
+		return
+	}
+
+	c.JSON(http.StatusOK, models.SuccessResponse{
+		Success: true,
+		Data:    organization,
+		Message: "Organization updated successfully",
+	})
+}
+
+// @Summary Delete an organization
+// @Description Delete an organization that has no members left. Its fields and submissions keep their org_id.
+// @Tags organizations
+// @Produce  json
+// @Security ApiKeyAuth
+// @Param id path string true "Organization ID"
+// @Success 200 {object} models.SuccessResponse
+// @Failure 404 {object} models.ErrorResponse
+// @Failure 409 {object} models.ErrorResponse
+// @Failure 500 {object} models.ErrorResponse
+// @Router /organizations/{id} [delete]
+func (oh *OrganizationHandler) DeleteOrganization(c *gin.Context) {
+	orgID := c.Param("id")
+
+	if _, err := oh.getOrganization(orgID); err != nil {
+		c.JSON(http.StatusNotFound, models.ErrorResponse{
+			Error:   "not_found",
+			Message: "Organization not found",
+		})
+		return
+	}
+
+	ctx := oh.firestoreService.Context()
+	members, err := oh.firestoreService.Users().Where("org_id", "==", orgID).Limit(1).Documents(ctx).GetAll()
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, models.ErrorResponse{
+			Error:   "internal_error",
+			Message: "Failed to check organization members",
+		})
+		return
+	}
+	if len(members) > 0 {
+		c.JSON(http.StatusConflict, models.ErrorResponse{
+			Error:   "organization_not_empty",
+			Message: "Remove every member before deleting the organization",
+		})
+		return
+	}
+
+	if _, err := oh.firestoreService.Organizations().Doc(orgID).Delete(ctx); err != nil {
+		c.JSON(http.StatusInternalServerError, models.ErrorResponse{
+			Error:   "internal_error",
+			Message: "Failed to delete organization",
+		})
+		return
+	}
+
+	c.JSON(http.StatusOK, models.SuccessResponse{
+		Success: true,
+		Message: "Organization deleted successfully",
+	})
+}
+
+// @Summary List organization members
+// @Description List the users of an organization. Its org admins and organization managers may list them.
+// @Tags organizations
+// @Produce  json
+// @Security ApiKeyAuth
+// @Param id path string true "Organization ID"
+// @Success 200 {object} models.SuccessResponse
+// @Failure 403 {object} models.ErrorResponse
+// @Failure 500 {object} models.ErrorResponse
+// @Router /organizations/{id}/members [get]
+func (oh *OrganizationHandler) GetMembers(c *gin.Context) {
+	orgID := c.Param("id")
+	currentUser, _ := c.Get("user")
+	user := currentUser.(*models.User)
+
+	if !utils.HasPermissionIn(user, utils.PermUsersRead, orgID) {
+		c.JSON(http.StatusForbidden, models.ErrorResponse{
+			Error:   "forbidden",
+			Message: "Access denied",
+		})
+		return
+	}
+
+	ctx := oh.firestoreService.Context()
+	docs, err := oh.firestoreService.Users().Where("org_id", "==", orgID).Documents(ctx).GetAll()
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, models.ErrorResponse{
+			Error:   "internal_error",
+			Message: "Failed to retrieve members",
+		})
+		return
+	}
+
+	members := []models.User{}
+	for _, doc := range docs {
+		var member models.User
+		doc.DataTo(&member)
+		if member.DeletedAt == nil {
+			members = append(members, member)
+		}
+	}
+
+	c.JSON(http.StatusOK, models.SuccessResponse{
+		Success: true,
+		Data:    members,
+	})
+}
+
+// @Summary Add an organization member
+// @Description Move a user into the organization. Records they created earlier stay with their previous organization.
+// @Description Admins manage the whole deployment and cannot join an organization; use the org_admin role instead.
+// @Tags organizations
+// @Produce  json
+// @Security ApiKeyAuth
+// @Param id path string true "Organization ID"
+// @Param userId path string true "User ID"
+// @Success 200 {object} models.SuccessResponse
+// @Failure 400 {object} models.ErrorResponse
+// @Failure 404 {object} models.ErrorResponse
+// @Failure 500 {object} models.ErrorResponse
+// @Router /organizations/{id}/members/{userId} [put]
+func (oh *OrganizationHandler) AddMember(c *gin.Context) {
+	orgID := c.Param("id")
+	userID := c.Param("userId")
+
+	if _, err := oh.getOrganization(orgID); err != nil {
+		c.JSON(http.StatusNotFound, models.ErrorResponse{
+			Error:   "not_found",
+			Message: "Organization not found",
+		})
+		return
+	}
+
+	member, ok := oh.getMember(c, userID)
+	if !ok {
+		return
+	}
+	if member.Role == "admin" {
+		c.JSON(http.StatusBadRequest, models.ErrorResponse{
+			Error:   "invalid_request",
+			Message: "Admins cannot join an organization",
+		})
+		return
+	}
+
+	ctx := oh.firestoreService.Context()
+	_, err := oh.firestoreService.Users().Doc(userID).Update(ctx, []firestore.Update{
+		{Path: "org_id", Value: orgID},
+		{Path: "updated_at", Value: time.Now()},
+	})
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, models.ErrorResponse{
+			Error:   "internal_error",
+			Message: "Failed to add member",
+		})
+		return
+	}
+
+	member.OrgID = orgID
+	c.JSON(http.StatusOK, models.SuccessResponse{
+		Success: true,
+		Data:    member,
+		Message: "Member added successfully",
+	})
+}
+
+// @Summary Remove an organization member
+// @Description Take a user out of the organization. Its org admins and organization managers may remove members.
+// @Description A removed org_admin holds no permissions until given another role.
+// @Tags organizations
+// @Produce  json
+// @Security ApiKeyAuth
+// @Param id path string true "Organization ID"
+// @Param userId path string true "User ID"
+// @Success 200 {object} models.SuccessResponse
+// @Failure 403 {object} models.ErrorResponse
+// @Failure 404 {object} models.ErrorResponse
+// @Failure 500 {object} models.ErrorResponse
+// @Router /organizations/{id}/members/{userId} [delete]
+func (oh *OrganizationHandler) RemoveMember(c *gin.Context) {
+	orgID := c.Param("id")
+	userID := c.Param("userId")
+	currentUser, _ := c.Get("user")
+	user := currentUser.(*models.User)
+
+	if !utils.HasPermissionIn(user, utils.PermUsersManage, orgID) {
+		c.JSON(http.StatusForbidden, models.ErrorResponse{
+			Error:   "forbidden",
+			Message: "Access denied",
+		})
+		return
+	}
+
+	member, ok := oh.getMember(c, userID)
+	if !ok {
+		return
+	}
+	if member.OrgID != orgID {
+		c.JSON(http.StatusNotFound, models.ErrorResponse{
+			Error:   "not_found",
+			Message: "User is not a member of this organization",
+		})
+		return
+	}
+
+	ctx := oh.firestoreService.Context()
+	_, err := oh.firestoreService.Users().Doc(userID).Update(ctx, []firestore.Update{
+		{Path: "org_id", Value: firestore.Delete},
+		{Path: "updated_at", Value: time.Now()},
+	})
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, models.ErrorResponse{
+			Error:   "internal_error",
+			Message: "Failed to remove member",
+		})
+		return
+	}
+
+	c.JSON(http.StatusOK, models.SuccessResponse{
+		Success: true,
+		Message: "Member removed successfully",
+	})
+}
+
+func (oh *OrganizationHandler) getOrganization(orgID string) (*models.Organization, error) {
+	ctx := oh.firestoreService.Context()
+	doc, err := oh.firestoreService.Organizations().Doc(orgID).Get(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	var organization models.Organization
+	doc.DataTo(&organization)
+	return &organization, nil
+}
+
+// getMember loads a user, responding with 404 and returning false when they do not exist
+func (oh *OrganizationHandler) getMember(c *gin.Context, userID string) (*models.User, bool) {
+	ctx := oh.firestoreService.Context()
+	doc, err := oh.firestoreService.Users().Doc(userID).Get(ctx)
+	if err != nil {
+		c.JSON(http.StatusNotFound, models.ErrorResponse{
+			Error:   "not_found",
+			Message: "User not found",
+		})
+		return nil, false
+	}
+
+	var member models.User
+	doc.DataTo(&member)
+	return &member, true
+}
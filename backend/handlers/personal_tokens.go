@@ -0,0 +1,238 @@
+package handlers
+
+import (
+	"net/http"
+	"time"
+
+	"rice-monitor-api/models"
+	"rice-monitor-api/services"
+	"rice-monitor-api/utils"
+
+	"cloud.google.com/go/firestore"
+	"github.com/gin-gonic/gin"
+)
+
+// defaultTokenLifetimeDays is how long a personal access token lasts when no expiry is requested
+const defaultTokenLifetimeDays = 90
+
+type PersonalTokenHandler struct {
+	firestoreService *services.FirestoreService
+}
+
+func NewPersonalTokenHandler(firestoreService *services.FirestoreService) *PersonalTokenHandler {
+	return &PersonalTokenHandler{
+		firestoreService: firestoreService,
+	}
+}
+
+// @Summary List personal access tokens
+// @Description List a user's personal access tokens, including expired and revoked ones
+// @Tags users
+// @Produce  json
+// @Security ApiKeyAuth
+// @Param id path string true "User ID"
+// @Success 200 {object} models.SuccessResponse
+// @Failure 403 {object} models.ErrorResponse
+// @Failure 500 {object} models.ErrorResponse
+// @Router /users/{id}/tokens [get]
+func (th *PersonalTokenHandler) GetTokens(c *gin.Context) {
+	userID := c.Param("id")
+	currentUser, _ := c.Get("user")
+	user := currentUser.(*models.User)
+
+	if userID != user.ID && !utils.HasPermission(user.Role, utils.PermAPIKeysManage) {
+		c.JSON(http.StatusForbidden, models.ErrorResponse{
+			Error:   "forbidden",
+			Message: "Access denied",
+		})
+		return
+	}
+
+	ctx := th.firestoreService.Context()
+	docs, err := th.firestoreService.PersonalAccessTokens().Where("user_id", "==", userID).Documents(ctx).GetAll()
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, models.ErrorResponse{
+			Error:   "internal_error",
+			Message: "Failed to retrieve tokens",
+		})
+		return
+	}
+
+	tokens := []models.PersonalAccessToken{}
+	for _, doc := range docs {
+		var token models.PersonalAccessToken
+		doc.DataTo(&token)
+		tokens = append(tokens, token)
+	}
+
+	c.JSON(http.StatusOK, models.SuccessResponse{
+		Success: true,
+		Data:    tokens,
+	})
+}
+
+// @Summary Create a personal access token
+// @Description Create a token for your own scripts, sent as "Authorization: Bearer rmp_...". It only opens the
+// @Description routes of its scopes and acts with your permissions. The token is only returned once.
+// @Description Scopes: submissions:read, submissions:write, fields:read, analytics:read
+// @Tags users
+// @Accept  json
+// @Produce  json
+// @Security ApiKeyAuth
+// @Param id path string true "User ID, which must be your own"
+// @Param token body models.CreatePersonalAccessTokenRequest true "Token details"
+// @Success 201 {object} models.SuccessResponse
+// @Failure 400 {object} models.ErrorResponse
+// @Failure 403 {object} models.ErrorResponse
+// @Failure 500 {object} models.ErrorResponse
+// @Router /users/{id}/tokens [post]
+func (th *PersonalTokenHandler) CreateToken(c *gin.Context) {
+	userID := c.Param("id")
+	currentUser, _ := c.Get("user")
+	user := currentUser.(*models.User)
+
+	// Tokens act as their owner, so nobody can create them for someone else
+	if userID != user.ID {
+		c.JSON(http.StatusForbidden, models.ErrorResponse{
+			Error:   "forbidden",
+			Message: "You can only create tokens for yourself",
+		})
+		return
+	}
+
+	var req models.CreatePersonalAccessTokenRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, models.ErrorResponse{
+			Error:   "invalid_request",
+			Message: err.Error(),
+		})
+		return
+	}
+
+	for _, scope := range req.Scopes {
+		if !utils.ValidScope(scope) {
+			c.JSON(http.StatusBadRequest, models.ErrorResponse{
+				Error:   "invalid_scope",
+				Message: "Unknown scope " + scope,
+			})
+			return
+		}
+	}
+
+	days := req.ExpiresInDays
+	if days == 0 {
+		days = defaultTokenLifetimeDays
+	}
+
+	rawToken, err := utils.GeneratePersonalAccessToken()
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, models.ErrorResponse{
+			Error:   "internal_error",
+			Message: "Failed to generate token",
+		})
+		return
+	}
+
+	now := time.Now()
+	token := models.PersonalAccessToken{
+		ID:        utils.GenerateID(),
+		UserID:    user.ID,
+		Name:      req.Name,
+		Prefix:    rawToken[:len(utils.PersonalAccessTokenPrefix)+8],
+		TokenHash: utils.HashToken(rawToken),
+		Scopes:    req.Scopes,
+		CreatedAt: now,
+		ExpiresAt: now.AddDate(0, 0, days),
+	}
+
+	ctx := th.firestoreService.Context()
+	if _, err := th.firestoreService.PersonalAccessTokens().Doc(token.ID).Set(ctx, token); err != nil {
+		c.JSON(http.StatusInternalServerError, models.ErrorResponse{
+			Error:   "internal_error",
+			Message: "Failed to create token",
+		})
+		return
+	}
+
+	c.JSON(http.StatusCreated, models.SuccessResponse{
+		Success: true,
+		Data: models.CreatePersonalAccessTokenResponse{
+			PersonalAccessToken: token,
+			Token:               rawToken,
+		},
+		Message: "Token created successfully. Store it now, it will not be shown again",
+	})
+}
+
+// @Summary Revoke a personal access token
+// @Description Revoke one of a user's personal access tokens
+// @Tags users
+// @Produce  json
+// @Security ApiKeyAuth
+// @Param id path string true "User ID"
+// @Param tokenId path string true "Token ID"
+// @Success 200 {object} models.SuccessResponse
+// @Failure 403 {object} models.ErrorResponse
+// @Failure 404 {object} models.ErrorResponse
+// @Failure 409 {object} models.ErrorResponse
+// @Failure 500 {object} models.ErrorResponse
+// @Router /users/{id}/tokens/{tokenId} [delete]
+func (th *PersonalTokenHandler) RevokeToken(c *gin.Context) {
+	userID := c.Param("id")
+	currentUser, _ := c.Get("user")
+	user := currentUser.(*models.User)
+
+	if userID != user.ID && !utils.HasPermission(user.Role, utils.PermAPIKeysManage) {
+		c.JSON(http.StatusForbidden, models.ErrorResponse{
+			Error:   "forbidden",
+			Message: "Access denied",
+		})
+		return
+	}
+
+	ctx := th.firestoreService.Context()
+	ref := th.firestoreService.PersonalAccessTokens().Doc(c.Param("tokenId"))
+	doc, err := ref.Get(ctx)
+	if err != nil {
+		c.JSON(http.StatusNotFound, models.ErrorResponse{
+			Error:   "not_found",
+			Message: "Token not found",
+		})
+		return
+	}
+
+	var token models.PersonalAccessToken
+	doc.DataTo(&token)
+	if token.UserID != userID {
+		c.JSON(http.StatusNotFound, models.ErrorResponse{
+			Error:   "not_found",
+			Message: "Token not found",
+		})
+		return
+	}
+
+	if token.Revoked {
+		c.JSON(http.StatusConflict, models.ErrorResponse{
+			Error:   "already_revoked",
+			Message: "Token is already revoked",
+		})
+		return
+	}
+
+	_, err = ref.Update(ctx, []firestore.Update{
+		{Path: "revoked", Value: true},
+		{Path: "revoked_at", Value: time.Now()},
+	})
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, models.ErrorResponse{
+			Error:   "internal_error",
+			Message: "Failed to revoke token",
+		})
+		return
+	}
+
+	c.JSON(http.StatusOK, models.SuccessResponse{
+		Success: true,
+		Message: "Token revoked successfully",
+	})
+}
@@ -0,0 +1,95 @@
+package handlers
+
+import (
+	"net/http"
+	"strconv"
+
+	"rice-monitor-api/models"
+	"rice-monitor-api/services"
+
+	"cloud.google.com/go/firestore"
+	"github.com/gin-gonic/gin"
+)
+
+type QualityReportHandler struct {
+	firestoreService *services.FirestoreService
+}
+
+func NewQualityReportHandler(firestoreService *services.FirestoreService) *QualityReportHandler {
+	return &QualityReportHandler{
+		firestoreService: firestoreService,
+	}
+}
+
+// @Summary List data quality reports
+// @Description List the scheduled data quality reports, newest first
+// @Tags admin
+// @Produce  json
+// @Security ApiKeyAuth
+// @Param period query string false "Filter by period (daily, weekly)"
+// @Param limit query int false "Number of reports to return"
+// @Success 200 {object} models.SuccessResponse
+// @Failure 500 {object} models.ErrorResponse
+// @Router /admin/quality-reports [get]
+func (qh *QualityReportHandler) GetQualityReports(c *gin.Context) {
+	limit, err := strconv.Atoi(c.DefaultQuery("limit", "30"))
+	if err != nil || limit <= 0 {
+		limit = 30
+	}
+
+	ctx := qh.firestoreService.Context()
+	query := qh.firestoreService.QualityReports().Query
+	if period := c.Query("period"); period != "" {
+		query = query.Where("period", "==", period)
+	}
+
+	docs, err := query.OrderBy("period_start", firestore.Desc).Limit(limit).Documents(ctx).GetAll()
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, models.ErrorResponse{
+			Error:   "internal_error",
+			Message: "Failed to retrieve quality reports",
+		})
+		return
+	}
+
+	reports := []models.QualityReport{}
+	for _, doc := range docs {
+		var report models.QualityReport
+		doc.DataTo(&report)
+		reports = append(reports, report)
+	}
+
+	c.JSON(http.StatusOK, models.SuccessResponse{
+		Success: true,
+		Data:    reports,
+	})
+}
+
+// @Summary Get a data quality report
+// @Description Get a data quality report by its ID, e.g. daily-2024-06-01
+// @Tags admin
+// @Produce  json
+// @Security ApiKeyAuth
+// @Param id path string true "Report ID"
+// @Success 200 {object} models.SuccessResponse
+// @Failure 404 {object} models.ErrorResponse
+// @Router /admin/quality-reports/{id} [get]
+func (qh *QualityReportHandler) GetQualityReport(c *gin.Context) {
+	ctx := qh.firestoreService.Context()
+	doc, err := qh.firestoreService.QualityReports().Doc(c.Param("id")).Get(ctx)
+	if err != nil {
+		c.JSON(http.StatusNotFound, models.ErrorResponse{
+			Error:   "not_found",
+			Message: "Quality report not found",
+		})
+		return
+	}
+
+	var report models.QualityReport
+	doc.DataTo(&report)
+
+	c.JSON(http.StatusOK, models.SuccessResponse{
+		Success: true,
+		Data:    report,
+	})
+}
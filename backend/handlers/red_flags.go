@@ -0,0 +1,104 @@
+package handlers
+
+import (
+	"fmt"
+	"net/http"
+	"strconv"
+	"time"
+
+	"rice-monitor-api/models"
+	"rice-monitor-api/services"
+
+	"github.com/gin-gonic/gin"
+)
+
+// @Summary Get red flags
+// @Description Rank the most urgent issues across the organization's fields by a severity score from 0 to 100:
+// @Description clusters of nearby fields reporting pests or disease, fields overdue for a visit, fields whose last
+// @Description measurements of a trait all deviate from their growth stage average, and observers whose rejections spiked.
+// @Tags analytics
+// @Produce  json
+// @Security ApiKeyAuth
+// @Param days query int false "Recent window in days for outbreaks, deviations and rejection spikes (1-90, default 14)"
+// @Param overdue_days query int false "Days without a visit before a field is overdue (1-365, default 14)"
+// @Param limit query int false "Maximum number of flags (1-100, default 20)"
+// @Success 200 {object} models.SuccessResponse
+// @Failure 400 {object} models.ErrorResponse
+// @Failure 500 {object} models.ErrorResponse
+// @Router /analytics/red-flags [get]
+func (ah *AnalyticsHandler) GetRedFlags(c *gin.Context) {
+	currentUser, _ := c.Get("user")
+	user := currentUser.(*models.User)
+
+	var values [3]int
+	for i, param := range []struct {
+		name          string
+		fallback, max int
+	}{{"days", 14, 90}, {"overdue_days", 14, 365}, {"limit", 20, 100}} {
+		n, err := strconv.Atoi(c.DefaultQuery(param.name, strconv.Itoa(param.fallback)))
+		if err != nil || n < 1 || n > param.max {
+			c.JSON(http.StatusBadRequest, models.ErrorResponse{
+				Error:   "invalid_request",
+				Message: fmt.Sprintf("%s must be a number from 1 to %d", param.name, param.max),
+			})
+			return
+		}
+		values[i] = n
+	}
+	days, overdueDays, limit := values[0], values[1], values[2]
+
+	now := time.Now()
+	window := time.Duration(days) * 24 * time.Hour
+	ctx := ah.firestoreService.Context()
+
+	fieldDocs, err := orgScope(ah.firestoreService.Fields().Query, user).Documents(ctx).GetAll()
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, models.ErrorResponse{
+			Error:   "internal_error",
+			Message: "Failed to retrieve fields",
+		})
+		return
+	}
+	fields := make([]models.Field, len(fieldDocs))
+	for i, doc := range fieldDocs {
+		doc.DataTo(&fields[i])
+	}
+
+	submissionDocs, err := orgScope(ah.firestoreService.Submissions().Query, user).
+		Where("created_at", ">=", now.Add(-services.RedFlagLookback(window))).
+		Documents(ctx).GetAll()
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, models.ErrorResponse{
+			Error:   "internal_error",
+			Message: "Failed to retrieve submissions",
+		})
+		return
+	}
+	submissions := make([]models.Submission, len(submissionDocs))
+	for i, doc := range submissionDocs {
+		doc.DataTo(&submissions[i])
+	}
+
+	flags := services.DetectRedFlags(fields, submissions, now, window, time.Duration(overdueDays)*24*time.Hour)
+	counts := map[string]int{"outbreak_cluster": 0, "overdue_visit": 0, "trait_deviation": 0, "rejection_spike": 0}
+	for _, flag := range flags {
+		counts[flag.Type]++
+	}
+	if len(flags) > limit {
+		flags = flags[:limit]
+	}
+	if flags == nil {
+		flags = []models.RedFlag{}
+	}
+
+	c.JSON(http.StatusOK, models.SuccessResponse{
+		Success: true,
+		Data: models.RedFlagReport{
+			Days:        days,
+			OverdueDays: overdueDays,
+			Counts:      counts,
+			Flags:       flags,
+			GeneratedAt: now,
+		},
+	})
+}
@@ -0,0 +1,255 @@
+package handlers
+
+import (
+	"crypto/subtle"
+	"log"
+	"net/http"
+	"strings"
+	"time"
+
+	"rice-monitor-api/models"
+	"rice-monitor-api/services"
+	"rice-monitor-api/utils"
+
+	"cloud.google.com/go/firestore"
+	"github.com/gin-gonic/gin"
+)
+
+type ServiceAccountHandler struct {
+	firestoreService *services.FirestoreService
+}
+
+func NewServiceAccountHandler(firestoreService *services.FirestoreService) *ServiceAccountHandler {
+	return &ServiceAccountHandler{
+		firestoreService: firestoreService,
+	}
+}
+
+// @Summary List service accounts
+// @Description List all service accounts
+// @Tags service-accounts
+// @Produce  json
+// @Security ApiKeyAuth
+// @Success 200 {object} models.SuccessResponse
+// @Failure 500 {object} models.ErrorResponse
+// @Router /service-accounts [get]
+func (sh *ServiceAccountHandler) GetServiceAccounts(c *gin.Context) {
+	ctx := sh.firestoreService.Context()
+	docs, err := sh.firestoreService.ServiceAccounts().OrderBy("created_at", firestore.Desc).Documents(ctx).GetAll()
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, models.ErrorResponse{
+			Error:   "internal_error",
+			Message: "Failed to retrieve service accounts",
+		})
+		return
+	}
+
+	accounts := []models.ServiceAccount{}
+	for _, doc := range docs {
+		var account models.ServiceAccount
+		doc.DataTo(&account)
+		accounts = append(accounts, account)
+	}
+
+	c.JSON(http.StatusOK, models.SuccessResponse{
+		Success: true,
+		Data:    accounts,
+	})
+}
+
+// @Summary Create a service account
+// @Description Create a service account for a device or integration. The client secret is only returned once.
+// @Description Scopes: submissions:write, submissions:read, fields:read, analytics:read
+// @Tags service-accounts
+// @Accept  json
+// @Produce  json
+// @Security ApiKeyAuth
+// @Param account body models.CreateServiceAccountRequest true "Service account details"
+// @Success 201 {object} models.SuccessResponse
+// @Failure 400 {object} models.ErrorResponse
+// @Failure 500 {object} models.ErrorResponse
+// @Router /service-accounts [post]
+func (sh *ServiceAccountHandler) CreateServiceAccount(c *gin.Context) {
+	var req models.CreateServiceAccountRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, models.ErrorResponse{
+			Error:   "invalid_request",
+			Message: err.Error(),
+		})
+		return
+	}
+
+	for _, scope := range req.Scopes {
+		if !utils.ValidScope(scope) {
+			c.JSON(http.StatusBadRequest, models.ErrorResponse{
+				Error:   "invalid_scope",
+				Message: "Unknown scope " + scope,
+			})
+			return
+		}
+	}
+
+	currentUser, _ := c.Get("user")
+	user := currentUser.(*models.User)
+
+	secret, err := utils.GenerateSecureToken(32)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, models.ErrorResponse{
+			Error:   "internal_error",
+			Message: "Failed to generate client secret",
+		})
+		return
+	}
+
+	account := models.ServiceAccount{
+		ID:         utils.GenerateID(),
+		Name:       req.Name,
+		SecretHash: utils.HashToken(secret),
+		Scopes:     req.Scopes,
+		CreatedBy:  user.ID,
+		CreatedAt:  time.Now(),
+	}
+
+	ctx := sh.firestoreService.Context()
+	_, err = sh.firestoreService.ServiceAccounts().Doc(account.ID).Set(ctx, account)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, models.ErrorResponse{
+			Error:   "internal_error",
+			Message: "Failed to create service account",
+		})
+		return
+	}
+
+	c.JSON(http.StatusCreated, models.SuccessResponse{
+		Success: true,
+		Data: models.CreateServiceAccountResponse{
+			ServiceAccount: account,
+			ClientSecret:   secret,
+		},
+		Message: "Service account created successfully. Store the client secret now, it will not be shown again",
+	})
+}
+
+// @Summary Disable a service account
+// @Description Disable a service account. Its existing tokens stop working immediately.
+// @Tags service-accounts
+// @Produce  json
+// @Security ApiKeyAuth
+// @Param id path string true "Service account ID"
+// @Success 200 {object} models.SuccessResponse
+// @Failure 404 {object} models.ErrorResponse
+// @Failure 409 {object} models.ErrorResponse
+// @Failure 500 {object} models.ErrorResponse
+// @Router /service-accounts/{id} [delete]
+func (sh *ServiceAccountHandler) DisableServiceAccount(c *gin.Context) {
+	accountID := c.Param("id")
+
+	ctx := sh.firestoreService.Context()
+	doc, err := sh.firestoreService.ServiceAccounts().Doc(accountID).Get(ctx)
+	if err != nil {
+		c.JSON(http.StatusNotFound, models.ErrorResponse{
+			Error:   "not_found",
+			Message: "Service account not found",
+		})
+		return
+	}
+
+	var account models.ServiceAccount
+	doc.DataTo(&account)
+
+	if account.Disabled {
+		c.JSON(http.StatusConflict, models.ErrorResponse{
+			Error:   "already_disabled",
+			Message: "Service account is already disabled",
+		})
+		return
+	}
+
+	_, err = sh.firestoreService.ServiceAccounts().Doc(accountID).Update(ctx, []firestore.Update{
+		{Path: "disabled", Value: true},
+	})
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, models.ErrorResponse{
+			Error:   "internal_error",
+			Message: "Failed to disable service account",
+		})
+		return
+	}
+
+	c.JSON(http.StatusOK, models.SuccessResponse{
+		Success: true,
+		Message: "Service account disabled successfully",
+	})
+}
+
+// @Summary Service account token
+// @Description Exchange service account credentials for a 1 hour access token (OAuth2 client credentials grant)
+// @Tags auth
+// @Accept  x-www-form-urlencoded,json
+// @Produce  json
+// @Param grant_type formData string true "Must be client_credentials"
+// @Param client_id formData string true "Service account ID"
+// @Param client_secret formData string true "Client secret"
+// @Success 200 {object} models.ClientCredentialsResponse
+// @Failure 400 {object} models.ErrorResponse
+// @Failure 401 {object} models.ErrorResponse
+// @Failure 500 {object} models.ErrorResponse
+// @Router /auth/token [post]
+func (sh *ServiceAccountHandler) IssueToken(c *gin.Context) {
+	var req models.ClientCredentialsRequest
+	if err := c.ShouldBind(&req); err != nil {
+		c.JSON(http.StatusBadRequest, models.ErrorResponse{
+			Error:   "invalid_request",
+			Message: err.Error(),
+		})
+		return
+	}
+
+	if req.GrantType != "client_credentials" {
+		c.JSON(http.StatusBadRequest, models.ErrorResponse{
+			Error:   "unsupported_grant_type",
+			Message: "Only the client_credentials grant is supported",
+		})
+		return
+	}
+
+	ctx := sh.firestoreService.Context()
+	doc, err := sh.firestoreService.ServiceAccounts().Doc(req.ClientID).Get(ctx)
+
+	var account models.ServiceAccount
+	if err == nil {
+		doc.DataTo(&account)
+	}
+	if err != nil || account.Disabled ||
+		subtle.ConstantTimeCompare([]byte(utils.HashToken(req.ClientSecret)), []byte(account.SecretHash)) != 1 {
+		c.JSON(http.StatusUnauthorized, models.ErrorResponse{
+			Error:   "invalid_client",
+			Message: "Invalid client credentials",
+		})
+		return
+	}
+
+	accessToken, err := utils.GenerateServiceAccountToken(&account)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, models.ErrorResponse{
+			Error:   "internal_error",
+			Message: "Failed to generate token",
+		})
+		return
+	}
+
+	_, err = sh.firestoreService.ServiceAccounts().Doc(account.ID).Update(ctx, []firestore.Update{
+		{Path: "last_used_at", Value: time.Now()},
+	})
+	if err != nil {
+		// Log error but don't fail the token request
+		log.Printf("Failed to update service account last use: %v", err)
+	}
+
+	c.JSON(http.StatusOK, models.ClientCredentialsResponse{
+		AccessToken: accessToken,
+		TokenType:   "Bearer",
+		ExpiresIn:   3600, // 1 hour
+		Scope:       strings.Join(account.Scopes, " "),
+	})
+}
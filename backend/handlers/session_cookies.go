@@ -0,0 +1,57 @@
+package handlers
+
+import (
+	"net/http"
+	"strings"
+
+	"rice-monitor-api/models"
+	"rice-monitor-api/utils"
+
+	"github.com/gin-gonic/gin"
+)
+
+// sendAuthResponse returns the tokens of a login or refresh. In cookie session
+// mode they are set as HttpOnly cookies and left out of the body, together
+// with a new CSRF token the frontend echoes on state-changing requests.
+func sendAuthResponse(c *gin.Context, resp models.AuthResponse) {
+	if !utils.CookieSessionMode() {
+		c.JSON(http.StatusOK, resp)
+		return
+	}
+
+	csrfToken, err := utils.GenerateSecureToken(32)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, models.ErrorResponse{
+			Error:   "internal_error",
+			Message: "Failed to start session",
+		})
+		return
+	}
+
+	sessionAge := int(utils.RefreshTokenTTL.Seconds())
+	setSessionCookie(c, utils.AccessTokenCookie, resp.AccessToken, "/api/v1", int(resp.ExpiresIn), true)
+	setSessionCookie(c, utils.RefreshTokenCookie, resp.RefreshToken, "/api/v1/auth", sessionAge, true)
+	setSessionCookie(c, utils.CSRFCookie, csrfToken, "/", sessionAge, false)
+
+	resp.AccessToken = ""
+	resp.RefreshToken = ""
+	c.JSON(http.StatusOK, resp)
+}
+
+// clearSessionCookies expires the session cookies on logout
+func clearSessionCookies(c *gin.Context) {
+	if !utils.CookieSessionMode() {
+		return
+	}
+	setSessionCookie(c, utils.AccessTokenCookie, "", "/api/v1", -1, true)
+	setSessionCookie(c, utils.RefreshTokenCookie, "", "/api/v1/auth", -1, true)
+	setSessionCookie(c, utils.CSRFCookie, "", "/", -1, false)
+}
+
+// setSessionCookie sets a SameSite=Strict cookie, Secure unless
+// AUTH_COOKIE_SECURE is off, on AUTH_COOKIE_DOMAIN when configured
+func setSessionCookie(c *gin.Context, name, value, path string, maxAge int, httpOnly bool) {
+	secure := strings.ToLower(utils.GetEnvOrDefault("AUTH_COOKIE_SECURE", "on")) != "off"
+	c.SetSameSite(http.SameSiteStrictMode)
+	c.SetCookie(name, value, maxAge, path, utils.GetEnvOrDefault("AUTH_COOKIE_DOMAIN", ""), secure, httpOnly)
+}
@@ -0,0 +1,99 @@
+package handlers
+
+import (
+	"net/http"
+	"net/url"
+	"time"
+
+	"rice-monitor-api/models"
+	"rice-monitor-api/services"
+	"rice-monitor-api/utils"
+
+	"github.com/gin-gonic/gin"
+)
+
+type ShareTokenHandler struct {
+	firestoreService *services.FirestoreService
+}
+
+func NewShareTokenHandler(firestoreService *services.FirestoreService) *ShareTokenHandler {
+	return &ShareTokenHandler{
+		firestoreService: firestoreService,
+	}
+}
+
+// @Summary Create a share token
+// @Description Mint a short-lived, read-only link a collaborator can use without an account.
+// @Description With field_id the link only covers that field, which must be yours unless you can read all fields.
+// @Tags share-tokens
+// @Accept  json
+// @Produce  json
+// @Security ApiKeyAuth
+// @Param token body models.CreateShareTokenRequest true "Share token details"
+// @Success 201 {object} models.SuccessResponse
+// @Failure 400 {object} models.ErrorResponse
+// @Failure 403 {object} models.ErrorResponse
+// @Failure 404 {object} models.ErrorResponse
+// @Failure 500 {object} models.ErrorResponse
+// @Router /share-tokens [post]
+func (sh *ShareTokenHandler) CreateShareToken(c *gin.Context) {
+	var req models.CreateShareTokenRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, models.ErrorResponse{
+			Error:   "invalid_request",
+			Message: err.Error(),
+		})
+		return
+	}
+
+	currentUser, _ := c.Get("user")
+	user := currentUser.(*models.User)
+
+	if req.FieldID != "" {
+		ctx := sh.firestoreService.Context()
+		doc, err := sh.firestoreService.Fields().Doc(req.FieldID).Get(ctx)
+		if err != nil {
+			c.JSON(http.StatusNotFound, models.ErrorResponse{
+				Error:   "not_found",
+				Message: "Field not found",
+			})
+			return
+		}
+
+		var field models.Field
+		doc.DataTo(&field)
+		if field.OwnerID != user.ID && !utils.HasPermissionIn(user, utils.PermFieldsReadAll, field.OrgID) {
+			c.JSON(http.StatusForbidden, models.ErrorResponse{
+				Error:   "forbidden",
+				Message: "Only the field owner can share its data",
+			})
+			return
+		}
+	}
+
+	hours := req.ExpiresInHours
+	if hours == 0 {
+		hours = 24
+	}
+
+	token, expiresAt, err := utils.GenerateShareToken(user, req.Scope, req.FieldID, time.Duration(hours)*time.Hour)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, models.ErrorResponse{
+			Error:   "internal_error",
+			Message: "Failed to create share token",
+		})
+		return
+	}
+
+	c.JSON(http.StatusCreated, models.SuccessResponse{
+		Success: true,
+		Data: models.ShareTokenResponse{
+			Token:     token,
+			URL:       utils.GetEnvOrDefault("PUBLIC_BASE_URL", "http://localhost:8080") + "/api/v1/shared/submissions/export?token=" + url.QueryEscape(token),
+			Scope:     req.Scope,
+			FieldID:   req.FieldID,
+			ExpiresAt: expiresAt,
+		},
+		Message: "Share token created successfully",
+	})
+}
@@ -0,0 +1,362 @@
+package handlers
+
+import (
+	"net/http"
+	"sort"
+	"time"
+
+	"rice-monitor-api/models"
+	"rice-monitor-api/services"
+	"rice-monitor-api/utils"
+
+	"cloud.google.com/go/firestore"
+	"github.com/gin-gonic/gin"
+)
+
+const (
+	// impactWeeksBefore and impactWeeksAfter bound the impact report around the
+	// event, so the baseline and the recovery are both visible
+	impactWeeksBefore = 4
+	impactWeeksAfter  = 8
+)
+
+type StressEventHandler struct {
+	firestoreService *services.FirestoreService
+}
+
+func NewStressEventHandler(firestoreService *services.FirestoreService) *StressEventHandler {
+	return &StressEventHandler{
+		firestoreService: firestoreService,
+	}
+}
+
+// @Summary List stress events
+// @Description List the declared drought and flood events, most recent first
+// @Tags stress-events
+// @Produce  json
+// @Security ApiKeyAuth
+// @Success 200 {object} models.SuccessResponse
+// @Failure 500 {object} models.ErrorResponse
+// @Router /stress-events [get]
+func (sh *StressEventHandler) GetStressEvents(c *gin.Context) {
+	ctx := sh.firestoreService.Context()
+	docs, err := sh.firestoreService.StressEvents().OrderBy("start_date", firestore.Desc).Documents(ctx).GetAll()
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, models.ErrorResponse{
+			Error:   "internal_error",
+			Message: "Failed to retrieve stress events",
+		})
+		return
+	}
+
+	events := []models.StressEvent{}
+	for _, doc := range docs {
+		var event models.StressEvent
+		doc.DataTo(&event)
+		events = append(events, event)
+	}
+
+	c.JSON(http.StatusOK, models.SuccessResponse{
+		Success: true,
+		Data:    events,
+	})
+}
+
+// @Summary Declare a stress event
+// @Description Declare a regional drought or flood over a polygon. Fields inside the polygon are tagged with the event.
+// @Tags stress-events
+// @Accept  json
+// @Produce  json
+// @Security ApiKeyAuth
+// @Param event body models.CreateStressEventRequest true "Stress event"
+// @Success 201 {object} models.SuccessResponse
+// @Failure 400 {object} models.ErrorResponse
+// @Failure 500 {object} models.ErrorResponse
+// @Router /stress-events [post]
+func (sh *StressEventHandler) CreateStressEvent(c *gin.Context) {
+	var req models.CreateStressEventRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, models.ErrorResponse{
+			Error:   "invalid_request",
+			Message: err.Error(),
+		})
+		return
+	}
+
+	startDate, err := utils.ParseDate(req.StartDate)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, models.ErrorResponse{
+			Error:   "invalid_request",
+			Message: "start_date must be a YYYY-MM-DD date",
+		})
+		return
+	}
+	endDate, err := utils.ParseDate(req.EndDate)
+	if err != nil || endDate.Before(startDate) {
+		c.JSON(http.StatusBadRequest, models.ErrorResponse{
+			Error:   "invalid_request",
+			Message: "end_date must be a YYYY-MM-DD date on or after start_date",
+		})
+		return
+	}
+	for _, point := range req.Area {
+		if point.Latitude < -90 || point.Latitude > 90 || point.Longitude < -180 || point.Longitude > 180 {
+			c.JSON(http.StatusBadRequest, models.ErrorResponse{
+				Error:   "invalid_request",
+				Message: "area must contain valid coordinates",
+			})
+			return
+		}
+	}
+
+	currentUser, _ := c.Get("user")
+	user := currentUser.(*models.User)
+
+	ctx := sh.firestoreService.Context()
+	fieldDocs, err := sh.firestoreService.Fields().Documents(ctx).GetAll()
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, models.ErrorResponse{
+			Error:   "internal_error",
+			Message: "Failed to retrieve fields",
+		})
+		return
+	}
+
+	event := models.StressEvent{
+		ID:          utils.GenerateID(),
+		Type:        req.Type,
+		Name:        req.Name,
+		Description: req.Description,
+		StartDate:   startDate,
+		EndDate:     endDate,
+		Area:        req.Area,
+		FieldIDs:    []string{},
+		CreatedBy:   user.ID,
+		CreatedAt:   time.Now(),
+	}
+
+	batch := sh.firestoreService.Client.Batch()
+	for _, doc := range fieldDocs {
+		var field models.Field
+		doc.DataTo(&field)
+		if utils.PointInPolygon(field.Coordinates, req.Area) {
+			event.FieldIDs = append(event.FieldIDs, field.ID)
+			batch.Update(doc.Ref, []firestore.Update{
+				{Path: "stress_event_ids", Value: firestore.ArrayUnion(event.ID)},
+			})
+		}
+	}
+	batch.Set(sh.firestoreService.StressEvents().Doc(event.ID), event)
+
+	if _, err := batch.Commit(ctx); err != nil {
+		c.JSON(http.StatusInternalServerError, models.ErrorResponse{
+			Error:   "internal_error",
+			Message: "Failed to create stress event",
+		})
+		return
+	}
+
+	c.JSON(http.StatusCreated, models.SuccessResponse{
+		Success: true,
+		Data:    event,
+		Message: "Stress event created successfully",
+	})
+}
+
+// @Summary Delete a stress event
+// @Description Delete a stress event and remove its tag from the affected fields
+// @Tags stress-events
+// @Produce  json
+// @Security ApiKeyAuth
+// @Param id path string true "Stress event ID"
+// @Success 200 {object} models.SuccessResponse
+// @Failure 404 {object} models.ErrorResponse
+// @Failure 500 {object} models.ErrorResponse
+// @Router /stress-events/{id} [delete]
+func (sh *StressEventHandler) DeleteStressEvent(c *gin.Context) {
+	event, err := sh.getStressEvent(c.Param("id"))
+	if err != nil {
+		c.JSON(http.StatusNotFound, models.ErrorResponse{
+			Error:   "not_found",
+			Message: "Stress event not found",
+		})
+		return
+	}
+
+	ctx := sh.firestoreService.Context()
+	fieldDocs, err := sh.firestoreService.Fields().Where("stress_event_ids", "array-contains", event.ID).Documents(ctx).GetAll()
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, models.ErrorResponse{
+			Error:   "internal_error",
+			Message: "Failed to retrieve tagged fields",
+		})
+		return
+	}
+
+	batch := sh.firestoreService.Client.Batch()
+	for _, doc := range fieldDocs {
+		batch.Update(doc.Ref, []firestore.Update{
+			{Path: "stress_event_ids", Value: firestore.ArrayRemove(event.ID)},
+		})
+	}
+	batch.Delete(sh.firestoreService.StressEvents().Doc(event.ID))
+
+	if _, err := batch.Commit(ctx); err != nil {
+		c.JSON(http.StatusInternalServerError, models.ErrorResponse{
+			Error:   "internal_error",
+			Message: "Failed to delete stress event",
+		})
+		return
+	}
+
+	c.JSON(http.StatusOK, models.SuccessResponse{
+		Success: true,
+		Message: "Stress event deleted successfully",
+	})
+}
+
+// @Summary Stress event impact report
+// @Description Compare the weekly average trait measurements of the fields affected by a stress event
+// @Description with all other fields, from 4 weeks before the event to 8 weeks after it ended.
+// @Description Only research-grade submissions are included.
+// @Tags stress-events
+// @Produce  json
+// @Security ApiKeyAuth
+// @Param id path string true "Stress event ID"
+// @Success 200 {object} models.SuccessResponse
+// @Failure 404 {object} models.ErrorResponse
+// @Failure 500 {object} models.ErrorResponse
+// @Router /stress-events/{id}/impact [get]
+func (sh *StressEventHandler) GetImpactReport(c *gin.Context) {
+	event, err := sh.getStressEvent(c.Param("id"))
+	if err != nil {
+		c.JSON(http.StatusNotFound, models.ErrorResponse{
+			Error:   "not_found",
+			Message: "Stress event not found",
+		})
+		return
+	}
+
+	from := event.StartDate.AddDate(0, 0, -7*impactWeeksBefore)
+	to := event.EndDate.AddDate(0, 0, 7*impactWeeksAfter+1)
+
+	ctx := sh.firestoreService.Context()
+	docs, err := sh.firestoreService.Submissions().
+		Where("date", ">=", from).
+		Where("date", "<", to).
+		Documents(ctx).GetAll()
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, models.ErrorResponse{
+			Error:   "internal_error",
+			Message: "Failed to retrieve submissions",
+		})
+		return
+	}
+
+	affected, unaffected := newTrajectory(), newTrajectory()
+	affectedFields, unaffectedFields := map[string]bool{}, map[string]bool{}
+	for _, doc := range docs {
+		var submission models.Submission
+		doc.DataTo(&submission)
+		if submissionProvenance(submission) != "research" || submission.FieldID == "" {
+			continue
+		}
+
+		week := int(submission.Date.Sub(event.StartDate).Hours() / (24 * 7))
+		if submission.Date.Before(event.StartDate) {
+			week--
+		}
+		if utils.Contains(event.FieldIDs, submission.FieldID) {
+			affected.add(week, submission.TraitMeasurements)
+			affectedFields[submission.FieldID] = true
+		} else {
+			unaffected.add(week, submission.TraitMeasurements)
+			unaffectedFields[submission.FieldID] = true
+		}
+	}
+
+	c.JSON(http.StatusOK, models.SuccessResponse{
+		Success: true,
+		Data: models.StressImpactReport{
+			Event:            *event,
+			AffectedFields:   len(affectedFields),
+			UnaffectedFields: len(unaffectedFields),
+			Affected:         affected.points(),
+			Unaffected:       unaffected.points(),
+			GeneratedAt:      time.Now(),
+		},
+	})
+}
+
+// Helper functions
+
+func (sh *StressEventHandler) getStressEvent(eventID string) (*models.StressEvent, error) {
+	ctx := sh.firestoreService.Context()
+	doc, err := sh.firestoreService.StressEvents().Doc(eventID).Get(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	var event models.StressEvent
+	doc.DataTo(&event)
+	return &event, nil
+}
+
+// traitSums accumulates the measurements of one week. Zero values are
+// unmeasured traits and are left out of the averages.
+type traitSums struct {
+	submissions                          int
+	culmLength, panicleLength, panicles  float64
+	culmCount, panicleCount, hillsCounts int
+}
+
+type trajectory map[int]*traitSums
+
+func newTrajectory() trajectory {
+	return trajectory{}
+}
+
+func (t trajectory) add(week int, traits models.TraitMeasurements) {
+	sums, ok := t[week]
+	if !ok {
+		sums = &traitSums{}
+		t[week] = sums
+	}
+
+	sums.submissions++
+	if traits.CulmLength > 0 {
+		sums.culmLength += traits.CulmLength
+		sums.culmCount++
+	}
+	if traits.PanicleLength > 0 {
+		sums.panicleLength += traits.PanicleLength
+		sums.panicleCount++
+	}
+	if traits.PaniclesPerHill > 0 {
+		sums.panicles += float64(traits.PaniclesPerHill)
+		sums.hillsCounts++
+	}
+}
+
+// points returns the weekly averages in week order
+func (t trajectory) points() []models.TraitTrajectoryPoint {
+	average := func(sum float64, count int) float64 {
+		if count == 0 {
+			return 0
+		}
+		return sum / float64(count)
+	}
+
+	points := make([]models.TraitTrajectoryPoint, 0, len(t))
+	for week, sums := range t {
+		points = append(points, models.TraitTrajectoryPoint{
+			Week:            week,
+			Submissions:     sums.submissions,
+			CulmLength:      average(sums.culmLength, sums.culmCount),
+			PanicleLength:   average(sums.panicleLength, sums.panicleCount),
+			PaniclesPerHill: average(sums.panicles, sums.hillsCounts),
+		})
+	}
+	sort.Slice(points, func(i, j int) bool { return points[i].Week < points[j].Week })
+	return points
+}
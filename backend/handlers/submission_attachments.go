@@ -0,0 +1,378 @@
+package handlers
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"log"
+	"mime"
+	"net/http"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"time"
+
+	"rice-monitor-api/models"
+	"rice-monitor-api/utils"
+
+	"cloud.google.com/go/firestore"
+	"github.com/gin-gonic/gin"
+)
+
+const (
+	// maxAttachments is how many attachments one submission may have
+	maxAttachments = 10
+	// maxPDFAttachmentBytes is the largest data sheet scan accepted
+	maxPDFAttachmentBytes = 10 << 20
+	// maxAudioAttachmentBytes is the largest voice note accepted, a few minutes of compressed audio
+	maxAudioAttachmentBytes = 5 << 20
+)
+
+// attachmentTypes are the accepted attachment file extensions, with their
+// type and the content type they are stored and served with
+var attachmentTypes = map[string]struct{ kind, contentType string }{
+	".pdf":  {"pdf", "application/pdf"},
+	".m4a":  {"audio", "audio/mp4"},
+	".aac":  {"audio", "audio/aac"},
+	".mp3":  {"audio", "audio/mpeg"},
+	".ogg":  {"audio", "audio/ogg"},
+	".opus": {"audio", "audio/ogg"},
+	".wav":  {"audio", "audio/wav"},
+	".webm": {"audio", "audio/webm"},
+}
+
+// errTooManyAttachments is returned when a submission already has maxAttachments
+var errTooManyAttachments = errors.New("too many attachments")
+
+// @Summary Attach a file to a submission
+// @Description Attach a PDF scan of the paper data sheet (up to 10 MB) or a voice note (m4a, aac, mp3, ogg, opus,
+// @Description wav or webm, up to 5 MB) to a submission. A submission can have 10 attachments. Attachments are
+// @Description private and downloaded through the URL returned. When speech-to-text is configured, voice notes are
+// @Description transcribed in the background and the transcript is appended to the submission's notes.
+// @Tags submissions
+// @Accept  multipart/form-data
+// @Produce  json
+// @Security ApiKeyAuth
+// @Param id path string true "Submission ID"
+// @Param file formData file true "PDF or audio file"
+// @Param transcribe formData bool false "Transcribe a voice note into the notes (default true)"
+// @Success 201 {object} models.SuccessResponse
+// @Failure 400 {object} models.ErrorResponse
+// @Failure 403 {object} models.ErrorResponse
+// @Failure 404 {object} models.ErrorResponse
+// @Failure 409 {object} models.ErrorResponse
+// @Failure 413 {object} models.ErrorResponse
+// @Failure 500 {object} models.ErrorResponse
+// @Failure 503 {object} models.ErrorResponse
+// @Router /submissions/{id}/attachments [post]
+func (sh *SubmissionHandler) UploadAttachment(c *gin.Context) {
+	submissionID := c.Param("id")
+	currentUser, _ := c.Get("user")
+	user := currentUser.(*models.User)
+
+	file, header, err := c.Request.FormFile("file")
+	if err != nil {
+		c.JSON(http.StatusBadRequest, models.ErrorResponse{
+			Error:   "invalid_request",
+			Message: "No file uploaded",
+		})
+		return
+	}
+	defer file.Close()
+
+	ext := strings.ToLower(filepath.Ext(header.Filename))
+	fileType, ok := attachmentTypes[ext]
+	if !ok {
+		c.JSON(http.StatusBadRequest, models.ErrorResponse{
+			Error:   "invalid_file_type",
+			Message: "Only PDF and audio (m4a, aac, mp3, ogg, opus, wav, webm) files can be attached",
+		})
+		return
+	}
+	limit := int64(maxAudioAttachmentBytes)
+	if fileType.kind == "pdf" {
+		limit = maxPDFAttachmentBytes
+	}
+	if header.Size > limit {
+		c.JSON(http.StatusRequestEntityTooLarge, models.ErrorResponse{
+			Error:   "file_too_large",
+			Message: fmt.Sprintf("%s files can be at most %d MB", ext, limit>>20),
+		})
+		return
+	}
+
+	// The extension is all clients send reliably, so check the content matches it
+	head := make([]byte, 512)
+	n, _ := io.ReadFull(file, head)
+	sniffed := http.DetectContentType(head[:n])
+	if (fileType.kind == "pdf" && sniffed != "application/pdf") || strings.HasPrefix(sniffed, "text/") || strings.HasPrefix(sniffed, "image/") {
+		c.JSON(http.StatusBadRequest, models.ErrorResponse{
+			Error:   "invalid_file_type",
+			Message: "The file content does not match its " + ext + " extension",
+		})
+		return
+	}
+	if _, err := file.Seek(0, io.SeekStart); err != nil {
+		c.JSON(http.StatusInternalServerError, models.ErrorResponse{
+			Error:   "internal_error",
+			Message: "Failed to read upload",
+		})
+		return
+	}
+
+	ctx := sh.firestoreService.Context()
+	docRef := sh.firestoreService.Submissions().Doc(submissionID)
+	doc, err := docRef.Get(ctx)
+	if err != nil {
+		c.JSON(http.StatusNotFound, models.ErrorResponse{
+			Error:   "not_found",
+			Message: "Submission not found",
+		})
+		return
+	}
+	var submission models.Submission
+	doc.DataTo(&submission)
+
+	if !utils.HasPermissionIn(user, utils.PermSubmissionsUpdateAll, submission.OrgID) && submission.UserID != user.ID {
+		c.JSON(http.StatusForbidden, models.ErrorResponse{
+			Error:   "forbidden",
+			Message: "Access denied",
+		})
+		return
+	}
+	if len(submission.Attachments) >= maxAttachments {
+		c.JSON(http.StatusConflict, models.ErrorResponse{
+			Error:   "too_many_attachments",
+			Message: fmt.Sprintf("A submission can have at most %d attachments", maxAttachments),
+		})
+		return
+	}
+
+	attachment := models.Attachment{
+		ID:          utils.GenerateID(),
+		Type:        fileType.kind,
+		Name:        filepath.Base(header.Filename),
+		ContentType: fileType.contentType,
+		Size:        header.Size,
+		UploadedBy:  user.ID,
+		UploadedAt:  time.Now(),
+	}
+	transcribe := attachment.Type == "audio" && sh.transcriptionService.Enabled() && c.PostForm("transcribe") != "false"
+	if transcribe {
+		attachment.TranscriptStatus = "pending"
+	}
+	attachment.Object = fmt.Sprintf("attachments/%s/%s%s", submissionID, attachment.ID, ext)
+	attachment.URL = fmt.Sprintf("/api/v1/submissions/%s/attachments/%s", submissionID, attachment.ID)
+
+	if err := sh.storageService.UploadObject(attachment.Object, attachment.ContentType, file); err != nil {
+		recordUploadFailure(sh.firestoreService, models.UploadFailure{
+			SubmissionID: submissionID,
+			UserID:       user.ID,
+			Filename:     header.Filename,
+			Source:       "app",
+			Error:        err.Error(),
+		})
+		c.Header("Retry-After", strconv.Itoa(uploadRetryAfterSeconds))
+		c.JSON(http.StatusServiceUnavailable, models.ErrorResponse{
+			Error:   "storage_unavailable",
+			Message: "File storage is unavailable. Upload the attachment again later",
+		})
+		return
+	}
+
+	err = sh.firestoreService.Client.RunTransaction(ctx, func(ctx context.Context, tx *firestore.Transaction) error {
+		doc, err := tx.Get(docRef)
+		if err != nil {
+			return err
+		}
+		var submission models.Submission
+		doc.DataTo(&submission)
+		if len(submission.Attachments) >= maxAttachments {
+			return errTooManyAttachments
+		}
+		after := submission
+		after.Attachments = append(submission.Attachments, attachment)
+		after.UpdatedAt = time.Now()
+		audit := submissionAudit(user.ID, "update", &submission, &after)
+		if err := tx.Set(sh.firestoreService.SubmissionAudit().Doc(audit.ID), audit); err != nil {
+			return err
+		}
+		return tx.Update(docRef, []firestore.Update{
+			{Path: "attachments", Value: after.Attachments},
+			{Path: "updated_at", Value: after.UpdatedAt},
+		})
+	})
+	if err != nil {
+		sh.deleteAttachmentObject(attachment.Object)
+		if errors.Is(err, errTooManyAttachments) {
+			c.JSON(http.StatusConflict, models.ErrorResponse{
+				Error:   "too_many_attachments",
+				Message: fmt.Sprintf("A submission can have at most %d attachments", maxAttachments),
+			})
+			return
+		}
+		c.JSON(http.StatusInternalServerError, models.ErrorResponse{
+			Error:   "internal_error",
+			Message: "Failed to attach file to submission",
+		})
+		return
+	}
+	if transcribe {
+		go sh.transcribeAttachment(submissionID, attachment, user.ID)
+	}
+
+	c.JSON(http.StatusCreated, models.SuccessResponse{
+		Success: true,
+		Data:    attachment,
+		Message: "Attachment uploaded successfully",
+	})
+}
+
+// @Summary Download an attachment
+// @Description Download a file attached to a submission
+// @Tags submissions
+// @Produce  application/octet-stream
+// @Security ApiKeyAuth
+// @Param id path string true "Submission ID"
+// @Param attachmentId path string true "Attachment ID"
+// @Success 200 {file} file
+// @Failure 403 {object} models.ErrorResponse
+// @Failure 404 {object} models.ErrorResponse
+// @Failure 500 {object} models.ErrorResponse
+// @Router /submissions/{id}/attachments/{attachmentId} [get]
+func (sh *SubmissionHandler) GetAttachment(c *gin.Context) {
+	currentUser, _ := c.Get("user")
+	user := currentUser.(*models.User)
+
+	submission, attachment, ok := sh.findAttachment(c)
+	if !ok {
+		return
+	}
+	if !utils.HasPermissionIn(user, utils.PermSubmissionsReadAll, submission.OrgID) && submission.UserID != user.ID {
+		c.JSON(http.StatusForbidden, models.ErrorResponse{
+			Error:   "forbidden",
+			Message: "Access denied",
+		})
+		return
+	}
+
+	r, err := sh.storageService.OpenObject(attachment.Object)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, models.ErrorResponse{
+			Error:   "internal_error",
+			Message: "Failed to read attachment",
+		})
+		return
+	}
+	defer r.Close()
+
+	c.DataFromReader(http.StatusOK, r.Attrs.Size, attachment.ContentType, r, map[string]string{
+		"Content-Disposition":    mime.FormatMediaType("attachment", map[string]string{"filename": attachment.Name}),
+		"X-Content-Type-Options": "nosniff",
+	})
+}
+
+// @Summary Delete an attachment
+// @Description Remove a file attached to a submission
+// @Tags submissions
+// @Produce  json
+// @Security ApiKeyAuth
+// @Param id path string true "Submission ID"
+// @Param attachmentId path string true "Attachment ID"
+// @Success 200 {object} models.SuccessResponse
+// @Failure 403 {object} models.ErrorResponse
+// @Failure 404 {object} models.ErrorResponse
+// @Failure 500 {object} models.ErrorResponse
+// @Router /submissions/{id}/attachments/{attachmentId} [delete]
+func (sh *SubmissionHandler) DeleteAttachment(c *gin.Context) {
+	currentUser, _ := c.Get("user")
+	user := currentUser.(*models.User)
+
+	submission, attachment, ok := sh.findAttachment(c)
+	if !ok {
+		return
+	}
+	if !utils.HasPermissionIn(user, utils.PermSubmissionsUpdateAll, submission.OrgID) && submission.UserID != user.ID {
+		c.JSON(http.StatusForbidden, models.ErrorResponse{
+			Error:   "forbidden",
+			Message: "Access denied",
+		})
+		return
+	}
+
+	ctx := sh.firestoreService.Context()
+	docRef := sh.firestoreService.Submissions().Doc(submission.ID)
+	err := sh.firestoreService.Client.RunTransaction(ctx, func(ctx context.Context, tx *firestore.Transaction) error {
+		doc, err := tx.Get(docRef)
+		if err != nil {
+			return err
+		}
+		var current models.Submission
+		doc.DataTo(&current)
+
+		after := current
+		after.Attachments = []models.Attachment{}
+		for _, a := range current.Attachments {
+			if a.ID != attachment.ID {
+				after.Attachments = append(after.Attachments, a)
+			}
+		}
+		after.UpdatedAt = time.Now()
+		audit := submissionAudit(user.ID, "update", &current, &after)
+		if err := tx.Set(sh.firestoreService.SubmissionAudit().Doc(audit.ID), audit); err != nil {
+			return err
+		}
+		return tx.Update(docRef, []firestore.Update{
+			{Path: "attachments", Value: after.Attachments},
+			{Path: "updated_at", Value: after.UpdatedAt},
+		})
+	})
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, models.ErrorResponse{
+			Error:   "internal_error",
+			Message: "Failed to delete attachment",
+		})
+		return
+	}
+	sh.deleteAttachmentObject(attachment.Object)
+
+	c.JSON(http.StatusOK, models.SuccessResponse{
+		Success: true,
+		Message: "Attachment deleted successfully",
+	})
+}
+
+// findAttachment loads the submission and attachment named in the path,
+// responding with 404 and returning false when either does not exist
+func (sh *SubmissionHandler) findAttachment(c *gin.Context) (*models.Submission, *models.Attachment, bool) {
+	doc, err := sh.firestoreService.Submissions().Doc(c.Param("id")).Get(sh.firestoreService.Context())
+	if err != nil {
+		c.JSON(http.StatusNotFound, models.ErrorResponse{
+			Error:   "not_found",
+			Message: "Submission not found",
+		})
+		return nil, nil, false
+	}
+	var submission models.Submission
+	doc.DataTo(&submission)
+
+	for i := range submission.Attachments {
+		if submission.Attachments[i].ID == c.Param("attachmentId") {
+			return &submission, &submission.Attachments[i], true
+		}
+	}
+	c.JSON(http.StatusNotFound, models.ErrorResponse{
+		Error:   "not_found",
+		Message: "Attachment not found",
+	})
+	return nil, nil, false
+}
+
+// deleteAttachmentObject removes an attachment's file from the bucket. A
+// failure only leaves an orphaned object behind, so it is logged.
+func (sh *SubmissionHandler) deleteAttachmentObject(name string) {
+	if err := sh.storageService.Bucket().Object(name).Delete(sh.storageService.Context()); err != nil {
+		log.Printf("Failed to delete attachment %s: %v", name, err)
+	}
+}
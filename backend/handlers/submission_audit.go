@@ -0,0 +1,172 @@
+package handlers
+
+import (
+	"encoding/json"
+	"log"
+	"net/http"
+	"reflect"
+	"sort"
+	"time"
+
+	"rice-monitor-api/models"
+	"rice-monitor-api/services"
+	"rice-monitor-api/utils"
+
+	"cloud.google.com/go/firestore"
+	"github.com/gin-gonic/gin"
+)
+
+// @Summary Submission audit trail
+// @Description List every change made to a submission through the API, oldest first: who made it, whether it
+// @Description created, updated, deleted or reviewed the submission, and each changed path's value before and after.
+// @Description The trail outlives the submission, so deleted submissions can still be audited.
+// @Tags submissions
+// @Produce  json
+// @Security ApiKeyAuth
+// @Param id path string true "Submission ID"
+// @Success 200 {object} models.SuccessResponse
+// @Failure 403 {object} models.ErrorResponse
+// @Failure 500 {object} models.ErrorResponse
+// @Router /submissions/{id}/audit [get]
+func (sh *SubmissionHandler) GetSubmissionAudit(c *gin.Context) {
+	currentUser, _ := c.Get("user")
+	user := currentUser.(*models.User)
+
+	ctx := sh.firestoreService.Context()
+	docs, err := sh.firestoreService.SubmissionAudit().
+		Where("submission_id", "==", c.Param("id")).
+		OrderBy("created_at", firestore.Asc).
+		Documents(ctx).GetAll()
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, models.ErrorResponse{
+			Error:   "internal_error",
+			Message: "Failed to retrieve audit trail",
+		})
+		return
+	}
+
+	entries := []models.SubmissionAudit{}
+	for _, doc := range docs {
+		var entry models.SubmissionAudit
+		doc.DataTo(&entry)
+		entries = append(entries, entry)
+	}
+
+	// Entries carry the submission's organization, which outlives a deletion
+	if len(entries) > 0 && !utils.HasPermissionIn(user, utils.PermSubmissionsAuditRead, entries[0].OrgID) {
+		c.JSON(http.StatusForbidden, models.ErrorResponse{
+			Error:   "forbidden",
+			Message: "Access denied",
+		})
+		return
+	}
+
+	c.JSON(http.StatusOK, models.SuccessResponse{
+		Success: true,
+		Data:    entries,
+	})
+}
+
+// submissionAudit builds the audit entry of actorID's action on a submission.
+// before is nil for a creation and after is nil for a deletion.
+func submissionAudit(actorID, action string, before, after *models.Submission) models.SubmissionAudit {
+	entry := models.SubmissionAudit{
+		ID:        utils.GenerateID(),
+		ActorID:   actorID,
+		Action:    action,
+		Changes:   submissionChanges(before, after),
+		CreatedAt: time.Now(),
+	}
+	for _, s := range []*models.Submission{after, before} {
+		if s != nil {
+			entry.SubmissionID, entry.OrgID = s.ID, s.OrgID
+			break
+		}
+	}
+	if after != nil {
+		entry.UpdatedAt = &after.UpdatedAt
+	}
+	return entry
+}
+
+// recordSubmissionAudit stores the audit entry of a change already written.
+// The change stands either way, so a failure is only logged.
+func recordSubmissionAudit(firestoreService *services.FirestoreService, actorID, action string, before, after *models.Submission) {
+	entry := submissionAudit(actorID, action, before, after)
+	if _, err := firestoreService.SubmissionAudit().Doc(entry.ID).Set(firestoreService.Context(), entry); err != nil {
+		log.Printf("Failed to record audit of submission %s: %v", entry.SubmissionID, err)
+	}
+}
+
+// submissionChanges compares two versions of a submission path by path, as
+// they appear in the API. updated_at is left out, every change moves it.
+func submissionChanges(before, after *models.Submission) []models.AuditChange {
+	old, current := submissionPaths(before), submissionPaths(after)
+
+	var paths []string
+	for path := range old {
+		paths = append(paths, path)
+	}
+	for path := range current {
+		if _, ok := old[path]; !ok {
+			paths = append(paths, path)
+		}
+	}
+	sort.Strings(paths)
+
+	changes := []models.AuditChange{}
+	for _, path := range paths {
+		if path == "updated_at" || reflect.DeepEqual(old[path], current[path]) {
+			continue
+		}
+		changes = append(changes, models.AuditChange{Path: path, Before: old[path], After: current[path]})
+	}
+	return changes
+}
+
+// submissionPaths flattens a submission's JSON into dotted paths. Empty values
+// are left out, so they are audited the same as unset ones.
+func submissionPaths(s *models.Submission) map[string]interface{} {
+	paths := map[string]interface{}{}
+	if s == nil {
+		return paths
+	}
+	var doc map[string]interface{}
+	encoded, _ := json.Marshal(s)
+	json.Unmarshal(encoded, &doc)
+	flattenPaths("", doc, paths)
+	return paths
+}
+
+func flattenPaths(prefix string, doc map[string]interface{}, paths map[string]interface{}) {
+	for key, value := range doc {
+		path := key
+		if prefix != "" {
+			path = prefix + "." + key
+		}
+		if nested, ok := value.(map[string]interface{}); ok {
+			flattenPaths(path, nested, paths)
+			continue
+		}
+		if !emptyJSON(value) {
+			paths[path] = value
+		}
+	}
+}
+
+// emptyJSON reports whether a decoded JSON value is null, "", 0, false or []
+func emptyJSON(value interface{}) bool {
+	switch v := value.(type) {
+	case nil:
+		return true
+	case string:
+		return v == "" || v == "0001-01-01T00:00:00Z" // zero time
+	case float64:
+		return v == 0
+	case bool:
+		return !v
+	case []interface{}:
+		return len(v) == 0
+	}
+	return false
+}
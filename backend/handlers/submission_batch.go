@@ -0,0 +1,190 @@
+package handlers
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"regexp"
+	"sort"
+
+	"rice-monitor-api/models"
+	"rice-monitor-api/services"
+
+	"cloud.google.com/go/firestore"
+	"github.com/gin-gonic/gin"
+	"github.com/gin-gonic/gin/binding"
+)
+
+// firestoreBatchSize is the most writes Firestore accepts in one batch
+const firestoreBatchSize = 500
+
+// clientIDPattern limits client-generated IDs to characters that are safe in
+// Firestore document IDs and URLs
+var clientIDPattern = regexp.MustCompile(`^[A-Za-z0-9_-]+$`)
+
+// @Summary Upload a batch of submissions
+// @Description Create the submissions an observer recorded offline, up to SUBMISSION_BATCH_LIMIT (100 by default)
+// @Description at once. Each item is a submission as for POST /submissions with the "id" the app generated for it.
+// @Description Items are checked one by one and the result of each is returned in order: created, exists when an
+// @Description earlier upload already stored it, so failed uploads can simply be retried, or failed with an error code.
+// @Tags submissions
+// @Accept  json
+// @Produce  json
+// @Security ApiKeyAuth
+// @Param batch body models.BatchSubmissionRequest true "Submissions to create"
+// @Success 200 {object} models.SuccessResponse
+// @Failure 400 {object} models.ErrorResponse
+// @Failure 500 {object} models.ErrorResponse
+// @Router /submissions/batch [post]
+func (sh *SubmissionHandler) CreateSubmissionBatch(c *gin.Context) {
+	var req models.BatchSubmissionRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, models.ErrorResponse{
+			Error:   "invalid_request",
+			Message: err.Error(),
+		})
+		return
+	}
+	if len(req.Submissions) > sh.batchLimit {
+		c.JSON(http.StatusBadRequest, models.ErrorResponse{
+			Error:   "batch_too_large",
+			Message: fmt.Sprintf("A batch can hold at most %d submissions", sh.batchLimit),
+		})
+		return
+	}
+
+	currentUser, _ := c.Get("user")
+	user := currentUser.(*models.User)
+
+	results := make([]models.BatchSubmissionResult, len(req.Submissions))
+	fail := func(i int, problem *models.ErrorResponse) {
+		results[i].Status = "failed"
+		results[i].Error = problem.Error
+		results[i].Message = problem.Message
+	}
+
+	items := make([]models.BatchSubmissionItem, len(req.Submissions))
+	seen := make(map[string]bool)
+	var valid []int
+	for i, raw := range req.Submissions {
+		results[i].Index = i
+		if err := json.Unmarshal(raw, &items[i]); err != nil {
+			fail(i, &models.ErrorResponse{Error: "invalid_request", Message: err.Error()})
+			continue
+		}
+		results[i].ID = items[i].ID
+		if err := binding.Validator.ValidateStruct(&items[i]); err != nil {
+			fail(i, &models.ErrorResponse{Error: "invalid_request", Message: err.Error()})
+			continue
+		}
+		if !clientIDPattern.MatchString(items[i].ID) {
+			fail(i, &models.ErrorResponse{Error: "invalid_id", Message: "id may only contain letters, digits, - and _"})
+			continue
+		}
+		if seen[items[i].ID] {
+			fail(i, &models.ErrorResponse{Error: "duplicate_id", Message: "id is used by an earlier item of the batch"})
+			continue
+		}
+		seen[items[i].ID] = true
+		valid = append(valid, i)
+	}
+
+	// Items of an earlier upload that did not get its response are already stored
+	ctx := sh.firestoreService.Context()
+	refs := make([]*firestore.DocumentRef, len(valid))
+	for j, i := range valid {
+		refs[j] = sh.firestoreService.Submissions().Doc(items[i].ID)
+	}
+	var snapshots []*firestore.DocumentSnapshot
+	var err error
+	if len(refs) > 0 {
+		snapshots, err = sh.firestoreService.Client.GetAll(ctx, refs)
+	}
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, models.ErrorResponse{
+			Error:   "internal_error",
+			Message: "Failed to check for existing submissions",
+		})
+		return
+	}
+	var pending []int
+	for j, i := range valid {
+		if !snapshots[j].Exists() {
+			pending = append(pending, i)
+			continue
+		}
+		var existing models.Submission
+		snapshots[j].DataTo(&existing)
+		if existing.UserID == user.ID {
+			results[i].Status = "exists"
+		} else {
+			fail(i, &models.ErrorResponse{Error: "id_conflict", Message: "id is already used by another submission"})
+		}
+	}
+
+	// Oldest observations first, so stage changes within the batch are
+	// compared with the visit before them
+	sort.SliceStable(pending, func(a, b int) bool {
+		return items[pending[a]].Date.Before(items[pending[b]].Date)
+	})
+	var built []*models.Submission
+	var builtIndex []int
+	for _, i := range pending {
+		submission, _, problem := sh.buildSubmission(c, user, items[i].CreateSubmissionRequest, items[i].ID, built)
+		if problem != nil {
+			fail(i, problem)
+			continue
+		}
+		built = append(built, submission)
+		builtIndex = append(builtIndex, i)
+	}
+
+	var created []*models.Submission
+	// Each submission is written with its audit entry
+	perBatch := firestoreBatchSize / 2
+	for start := 0; start < len(built); start += perBatch {
+		end := min(start+perBatch, len(built))
+		batch := sh.firestoreService.Client.Batch()
+		for _, submission := range built[start:end] {
+			batch.Set(sh.firestoreService.Submissions().Doc(submission.ID), submission)
+			audit := submissionAudit(user.ID, "create", nil, submission)
+			batch.Set(sh.firestoreService.SubmissionAudit().Doc(audit.ID), audit)
+		}
+		_, err := batch.Commit(ctx)
+		for k := start; k < end; k++ {
+			if err != nil {
+				fail(builtIndex[k], &models.ErrorResponse{Error: "internal_error", Message: "Failed to create submission"})
+				continue
+			}
+			results[builtIndex[k]].Status = "created"
+			created = append(created, built[k])
+		}
+	}
+
+	// One goroutine for the whole batch, so a day of records does not hit
+	// the chat, webhook and weather services all at once
+	go func() {
+		for _, submission := range created {
+			sh.chatOpsService.SubmissionCreated(submission)
+			sh.webhookService.Dispatch(services.WebhookEventSubmissionCreated, submission)
+			sh.recordWeather(submission)
+			sh.registerTags(submission.OrgID, submission.Tags)
+		}
+	}()
+
+	counts := map[string]int{"created": 0, "exists": 0, "failed": 0}
+	for _, result := range results {
+		counts[result.Status]++
+	}
+
+	c.JSON(http.StatusOK, models.SuccessResponse{
+		Success: true,
+		Data: map[string]interface{}{
+			"results": results,
+			"created": counts["created"],
+			"exists":  counts["exists"],
+			"failed":  counts["failed"],
+		},
+		Message: fmt.Sprintf("%d of %d submissions created", counts["created"], len(results)),
+	})
+}
@@ -0,0 +1,279 @@
+package handlers
+
+import (
+	"fmt"
+	"log"
+	"net/http"
+	"strings"
+	"time"
+
+	"rice-monitor-api/models"
+	"rice-monitor-api/utils"
+
+	"cloud.google.com/go/firestore"
+	"github.com/gin-gonic/gin"
+)
+
+// bulkWrite is the pending change of one submission of a bulk action
+type bulkWrite struct {
+	index int    // into the results
+	orgID string // of the submission
+	add   func(batch *firestore.WriteBatch)
+}
+
+// @Summary Update or delete submissions in bulk
+// @Description Apply one action to up to 200 submissions: "status" moves them to status (under_review, approved or
+// @Description rejected, with a comment required to reject) following the review workflow, "tags" adds add_tags and
+// @Description removes remove_tags, and "delete" deletes them. Each submission is checked on its own and the result
+// @Description of each is returned in order: updated, deleted, unchanged or failed with an error code.
+// @Tags submissions
+// @Accept  json
+// @Produce  json
+// @Security ApiKeyAuth
+// @Param bulk body models.BulkSubmissionRequest true "Submissions and action"
+// @Success 200 {object} models.SuccessResponse
+// @Failure 400 {object} models.ErrorResponse
+// @Failure 500 {object} models.ErrorResponse
+// @Router /submissions/bulk [post]
+func (sh *SubmissionHandler) BulkUpdateSubmissions(c *gin.Context) {
+	var req models.BulkSubmissionRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, models.ErrorResponse{
+			Error:   "invalid_request",
+			Message: err.Error(),
+		})
+		return
+	}
+
+	req.Comment = strings.TrimSpace(req.Comment)
+	addTags, err := cleanTags(req.AddTags)
+	if err == nil {
+		req.RemoveTags, err = cleanTags(req.RemoveTags)
+	}
+	if err != nil {
+		c.JSON(http.StatusBadRequest, models.ErrorResponse{
+			Error:   "invalid_tags",
+			Message: err.Error(),
+		})
+		return
+	}
+	removeTags := req.RemoveTags
+	switch {
+	case req.Action == "status" && req.Status == "":
+		c.JSON(http.StatusBadRequest, models.ErrorResponse{
+			Error:   "invalid_request",
+			Message: "status is required to change the status",
+		})
+		return
+	case req.Action == "status" && req.Status == "rejected" && req.Comment == "":
+		c.JSON(http.StatusBadRequest, models.ErrorResponse{
+			Error:   "comment_required",
+			Message: "Explain why the submissions are rejected",
+		})
+		return
+	case req.Action == "tags" && len(addTags)+len(removeTags) == 0:
+		c.JSON(http.StatusBadRequest, models.ErrorResponse{
+			Error:   "invalid_request",
+			Message: "add_tags or remove_tags is required to change tags",
+		})
+		return
+	}
+
+	currentUser, _ := c.Get("user")
+	user := currentUser.(*models.User)
+	ctx := sh.firestoreService.Context()
+
+	// Repeated IDs are reported once
+	var ids []string
+	seen := make(map[string]bool)
+	for _, id := range req.IDs {
+		if !seen[id] {
+			seen[id] = true
+			ids = append(ids, id)
+		}
+	}
+
+	refs := make([]*firestore.DocumentRef, len(ids))
+	for i, id := range ids {
+		refs[i] = sh.firestoreService.Submissions().Doc(id)
+	}
+	docs, err := sh.firestoreService.Client.GetAll(ctx, refs)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, models.ErrorResponse{
+			Error:   "internal_error",
+			Message: "Failed to retrieve submissions",
+		})
+		return
+	}
+
+	results := make([]models.BulkSubmissionResult, len(ids))
+	fail := func(i int, problem *models.ErrorResponse) {
+		results[i].Status = "failed"
+		results[i].Error = problem.Error
+		results[i].Message = problem.Message
+	}
+
+	permission := utils.PermSubmissionsApprove
+	if req.Action == "delete" {
+		permission = utils.PermSubmissionsDeleteAll
+	}
+
+	var writes []bulkWrite
+	reviewed := make(map[int]*models.Submission)   // community submissions whose status changes
+	changed := make(map[int][2]*models.Submission) // submissions updated, before and after, for webhooks
+	for i, doc := range docs {
+		results[i].ID = ids[i]
+		if !doc.Exists() {
+			fail(i, &models.ErrorResponse{Error: "not_found", Message: "Submission not found"})
+			continue
+		}
+		var submission models.Submission
+		doc.DataTo(&submission)
+		ref, index := doc.Ref, i
+		if !utils.HasPermissionIn(user, permission, submission.OrgID) {
+			fail(i, &models.ErrorResponse{Error: "forbidden", Message: "Access denied"})
+			continue
+		}
+
+		switch req.Action {
+		case "status":
+			if submission.Status == req.Status {
+				results[i].Status = "unchanged"
+				continue
+			}
+			if submission.Status == "draft" {
+				fail(i, &models.ErrorResponse{Error: "not_submitted", Message: "Drafts cannot be reviewed until they are submitted"})
+				continue
+			}
+			if problem := transitionError(submission.Status, req.Status); problem != nil {
+				fail(i, problem)
+				continue
+			}
+			writes = append(writes, bulkWrite{index: i, add: func(batch *firestore.WriteBatch) {
+				decision := sh.batchReview(batch, &submission, user, req.Status, req.Comment)
+				after := reviewedSubmission(&submission, decision)
+				changed[index] = [2]*models.Submission{&submission, &after}
+			}})
+			if submission.Provenance == "community" {
+				reviewed[i] = &submission
+			}
+
+		case "tags":
+			tags := updatedTags(submission.Tags, addTags, removeTags)
+			if strings.Join(tags, "\x00") == strings.Join(submission.Tags, "\x00") {
+				results[i].Status = "unchanged"
+				continue
+			}
+			if len(tags) > maxSubmissionTags {
+				fail(i, &models.ErrorResponse{Error: "invalid_tags", Message: fmt.Sprintf("A submission can have at most %d tags", maxSubmissionTags)})
+				continue
+			}
+			writes = append(writes, bulkWrite{index: i, orgID: submission.OrgID, add: func(batch *firestore.WriteBatch) {
+				now := time.Now()
+				batch.Update(ref, []firestore.Update{
+					{Path: "tags", Value: tags},
+					{Path: "updated_at", Value: now},
+				})
+				tagged := submission
+				tagged.Tags, tagged.UpdatedAt = tags, now
+				audit := submissionAudit(user.ID, "update", &submission, &tagged)
+				batch.Set(sh.firestoreService.SubmissionAudit().Doc(audit.ID), audit)
+				changed[index] = [2]*models.Submission{&submission, &tagged}
+			}})
+
+		case "delete":
+			writes = append(writes, bulkWrite{index: i, add: func(batch *firestore.WriteBatch) {
+				batch.Delete(ref)
+				audit := submissionAudit(user.ID, "delete", &submission, nil)
+				batch.Set(sh.firestoreService.SubmissionAudit().Doc(audit.ID), audit)
+			}})
+		}
+	}
+
+	// A status change takes three writes, the others two
+	perBatch := firestoreBatchSize / 3
+	done := "updated"
+	if req.Action == "delete" {
+		done = "deleted"
+	}
+	tagged := make(map[string]bool) // organizations of the submissions tagged
+	var updated [][2]*models.Submission
+	for start := 0; start < len(writes); start += perBatch {
+		end := min(start+perBatch, len(writes))
+		batch := sh.firestoreService.Client.Batch()
+		for _, write := range writes[start:end] {
+			write.add(batch)
+		}
+		_, err := batch.Commit(ctx)
+		for _, write := range writes[start:end] {
+			if err != nil {
+				fail(write.index, &models.ErrorResponse{Error: "internal_error", Message: "Failed to update submission"})
+				delete(reviewed, write.index)
+				continue
+			}
+			results[write.index].Status = done
+			if change, ok := changed[write.index]; ok {
+				updated = append(updated, change)
+			}
+			if req.Action == "tags" {
+				tagged[write.orgID] = true
+			}
+		}
+	}
+
+	// One goroutine for all of them, so webhooks are not sent 200 events at once
+	go func() {
+		for _, change := range updated {
+			sh.webhookService.Dispatch(submissionEvent(change[0], change[1]), change[1])
+		}
+	}()
+
+	// Review outcomes feed the observers' reputation, once per observer
+	recomputed := make(map[string]bool)
+	for _, submission := range reviewed {
+		if recomputed[submission.UserID] {
+			continue
+		}
+		recomputed[submission.UserID] = true
+		if _, err := sh.reputationService.Recompute(submission.UserID); err != nil {
+			log.Printf("Failed to update reputation for user %s: %v", submission.UserID, err)
+		}
+	}
+
+	for orgID := range tagged {
+		sh.registerTags(orgID, addTags)
+	}
+
+	counts := map[string]int{"updated": 0, "deleted": 0, "unchanged": 0, "failed": 0}
+	for _, result := range results {
+		counts[result.Status]++
+	}
+
+	c.JSON(http.StatusOK, models.SuccessResponse{
+		Success: true,
+		Data: map[string]interface{}{
+			"results":   results,
+			done:        counts[done],
+			"unchanged": counts["unchanged"],
+			"failed":    counts["failed"],
+		},
+		Message: fmt.Sprintf("%d of %d submissions %s", counts[done], len(results), done),
+	})
+}
+
+// updatedTags returns tags with add appended and remove taken out, in order
+func updatedTags(tags, add, remove []string) []string {
+	removed := make(map[string]bool)
+	for _, tag := range remove {
+		removed[tag] = true
+	}
+	var updated []string
+	seen := make(map[string]bool)
+	for _, tag := range append(append([]string{}, tags...), add...) {
+		if !removed[tag] && !seen[tag] {
+			seen[tag] = true
+			updated = append(updated, tag)
+		}
+	}
+	return updated
+}
@@ -0,0 +1,151 @@
+package handlers
+
+import (
+	"net/http"
+	"time"
+
+	"rice-monitor-api/models"
+	"rice-monitor-api/services"
+	"rice-monitor-api/utils"
+
+	"github.com/gin-gonic/gin"
+)
+
+// @Summary Duplicate a submission
+// @Description Start the next visit from a submission: a new draft dated today for the same field, observer,
+// @Description growth stage, conditions, hill and tags, with the same traits and replicate hills left blank.
+// @Description Photos, notes, evidence, attachments, weather and the GPS position are not copied.
+// @Tags submissions
+// @Produce  json
+// @Security ApiKeyAuth
+// @Param id path string true "Submission ID"
+// @Success 201 {object} models.SuccessResponse
+// @Failure 403 {object} models.ErrorResponse
+// @Failure 404 {object} models.ErrorResponse
+// @Failure 500 {object} models.ErrorResponse
+// @Router /submissions/{id}/duplicate [post]
+func (sh *SubmissionHandler) DuplicateSubmission(c *gin.Context) {
+	currentUser, _ := c.Get("user")
+	user := currentUser.(*models.User)
+
+	ctx := sh.firestoreService.Context()
+	doc, err := sh.firestoreService.Submissions().Doc(c.Param("id")).Get(ctx)
+	if err != nil {
+		c.JSON(http.StatusNotFound, models.ErrorResponse{
+			Error:   "not_found",
+			Message: "Submission not found",
+		})
+		return
+	}
+
+	var original models.Submission
+	doc.DataTo(&original)
+
+	if !utils.HasPermissionIn(user, utils.PermSubmissionsReadAll, original.OrgID) && original.UserID != user.ID {
+		c.JSON(http.StatusForbidden, models.ErrorResponse{
+			Error:   "forbidden",
+			Message: "Access denied",
+		})
+		return
+	}
+
+	archived, err := fieldArchived(sh.firestoreService, original.FieldID)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, models.ErrorResponse{
+			Error:   "internal_error",
+			Message: "Failed to look up the field",
+		})
+		return
+	}
+	if archived {
+		c.JSON(http.StatusConflict, fieldArchivedResponse)
+		return
+	}
+
+	// Observers only submit for the fields they own or are assigned to
+	if user.Role == "observer" && original.FieldID != "" {
+		field, err := sh.getSubmissionField(original)
+		if err != nil || !canSubmitToField(user, field) {
+			c.JSON(http.StatusForbidden, models.ErrorResponse{
+				Error:   "field_not_assigned",
+				Message: "You are not assigned to this field",
+			})
+			return
+		}
+	}
+
+	now := time.Now()
+	submission := &models.Submission{
+		ID:                   utils.GenerateID(),
+		UserID:               user.ID,
+		FieldID:              original.FieldID,
+		OrgID:                user.OrgID,
+		FarmID:               original.FarmID,
+		Date:                 now,
+		GrowthStage:          original.GrowthStage,
+		PlantConditions:      append([]string{}, original.PlantConditions...),
+		TraitMeasurements:    blankMeasurements(original.TraitMeasurements),
+		HillID:               original.HillID,
+		Replicates:           blankReplicates(original.Replicates),
+		ObserverName:         original.ObserverName,
+		Images:               []string{},
+		Tags:                 original.Tags,
+		Status:               "draft",
+		Source:               "app",
+		Provenance:           original.Provenance,
+		VerificationRequired: original.VerificationRequired,
+		CreatedAt:            now,
+		UpdatedAt:            now,
+	}
+	if err := applySeason(sh.firestoreService, submission); err != nil {
+		c.JSON(http.StatusInternalServerError, models.ErrorResponse{
+			Error:   "internal_error",
+			Message: "Failed to look up the field's season",
+		})
+		return
+	}
+
+	if _, err := sh.firestoreService.Submissions().Doc(submission.ID).Set(ctx, submission); err != nil {
+		c.JSON(http.StatusInternalServerError, models.ErrorResponse{
+			Error:   "internal_error",
+			Message: "Failed to duplicate submission",
+		})
+		return
+	}
+	recordSubmissionAudit(sh.firestoreService, user.ID, "create", nil, submission)
+	sh.registerTags(submission.OrgID, submission.Tags)
+
+	go sh.webhookService.Dispatch(services.WebhookEventSubmissionCreated, submission)
+
+	c.JSON(http.StatusCreated, models.SuccessResponse{
+		Success: true,
+		Data:    submission,
+		Message: "Draft created from submission " + original.ID,
+	})
+}
+
+// blankMeasurements keeps which traits were measured, and on how many hills,
+// with their values cleared
+func blankMeasurements(measurements models.TraitMeasurements) models.TraitMeasurements {
+	blank := models.TraitMeasurements{HillsObserved: measurements.HillsObserved}
+	if len(measurements.Custom) > 0 {
+		blank.Custom = make(map[string]float64, len(measurements.Custom))
+		for key := range measurements.Custom {
+			blank.Custom[key] = 0
+		}
+	}
+	return blank
+}
+
+// blankReplicates keeps the hills measured replicate by replicate, with their
+// measurements cleared
+func blankReplicates(replicates []models.Replicate) []models.Replicate {
+	if len(replicates) == 0 {
+		return nil
+	}
+	blank := make([]models.Replicate, len(replicates))
+	for i, replicate := range replicates {
+		blank[i] = models.Replicate{HillID: replicate.HillID}
+	}
+	return blank
+}
@@ -0,0 +1,139 @@
+package handlers
+
+import (
+	"errors"
+	"strings"
+	"time"
+
+	"rice-monitor-api/models"
+	"rice-monitor-api/utils"
+
+	"cloud.google.com/go/firestore"
+)
+
+// errObserverForbidden is returned when a user without submissions:read_all
+// filters by another observer
+var errObserverForbidden = errors.New("you can only list your own submissions")
+
+// submissionListQuery builds the query of the submission listing: the user's
+// scope first, then the filters and order of params. Users without read_all
+// only see their own submissions, so filtering them by another observer is
+// refused rather than silently returning nothing. The composite indexes the
+// filters and orders need are defined in firestore.indexes.json.
+func (sh *SubmissionHandler) submissionListQuery(user *models.User, params models.SubmissionListParams) (firestore.Query, error) {
+	query := sh.firestoreService.Submissions().Query
+
+	if !utils.HasPermissionIn(user, utils.PermSubmissionsReadAll, user.OrgID) {
+		if params.Observer != "" && params.Observer != user.ID {
+			return query, errObserverForbidden
+		}
+		query = query.Where("user_id", "==", user.ID)
+	} else {
+		query = orgScope(query, user)
+		if params.Observer != "" {
+			query = query.Where("user_id", "==", params.Observer)
+		}
+	}
+
+	return sh.filterSubmissions(query, user, params)
+}
+
+// filterSubmissions applies the filters and order of params to a query of
+// submissions already scoped to what user may see. Growth stages and plant
+// conditions match their codes ignoring case and extra whitespace, and time
+// bounds given as dates are read in the user's timezone.
+func (sh *SubmissionHandler) filterSubmissions(query firestore.Query, user *models.User, params models.SubmissionListParams) (firestore.Query, error) {
+	if params.GrowthStage != "" {
+		code, ok := sh.vocabularyService.Canonical("growth_stage", params.GrowthStage)
+		if !ok {
+			return query, errors.New("growth_stage must be one of: " + strings.Join(sh.vocabularyService.GrowthStages(), ", "))
+		}
+		params.GrowthStage = code
+	}
+	if params.PlantCondition != "" {
+		code, ok := sh.vocabularyService.Canonical("condition", params.PlantCondition)
+		if !ok {
+			return query, errors.New("plant_condition must be one of: " + strings.Join(sh.vocabularyService.PlantConditions(), ", "))
+		}
+		params.PlantCondition = code
+	}
+
+	for _, filter := range []struct{ path, value string }{
+		{"status", params.Status},
+		{"field_id", params.FieldID},
+		{"farm_id", params.FarmID},
+		{"season_id", params.SeasonID},
+		{"growth_stage", params.GrowthStage},
+	} {
+		if filter.value != "" {
+			query = query.Where(filter.path, "==", filter.value)
+		}
+	}
+	// Firestore allows one array-contains filter per query
+	if params.PlantCondition != "" && params.Tag != "" {
+		return query, errors.New("filter by either plant_condition or tag, not both")
+	}
+	if params.PlantCondition != "" {
+		query = query.Where("plant_conditions", "array-contains", params.PlantCondition)
+	}
+	if params.Tag != "" {
+		query = query.Where("tags", "array-contains", strings.ToLower(params.Tag))
+	}
+
+	// Firestore only allows range filters on a single field per query
+	createdRange := params.CreatedFrom != "" || params.CreatedTo != ""
+	dateRange := params.DateFrom != "" || params.DateTo != ""
+	if createdRange && dateRange {
+		return query, errors.New("filter by either created_from/created_to or date_from/date_to, not both")
+	}
+
+	loc := utils.UserLocation(user)
+	rangePath := ""
+	var bounds [2]*time.Time
+	for _, bound := range []struct {
+		param, path, op, value string
+	}{
+		{"created_from", "created_at", ">=", params.CreatedFrom},
+		{"created_to", "created_at", "<", params.CreatedTo},
+		{"date_from", "date", ">=", params.DateFrom},
+		{"date_to", "date", "<", params.DateTo},
+	} {
+		if bound.value == "" {
+			continue
+		}
+		t, err := parseEventTime(bound.value, loc)
+		if err != nil {
+			return query, errors.New(bound.param + " must be an RFC 3339 time or a YYYY-MM-DD date")
+		}
+		if bound.op == ">=" {
+			bounds[0] = &t
+		} else {
+			bounds[1] = &t
+		}
+		query = query.Where(bound.path, bound.op, t)
+		rangePath = bound.path
+	}
+	if bounds[0] != nil && bounds[1] != nil && !bounds[0].Before(*bounds[1]) {
+		return query, errors.New("the start of a range must be before its end")
+	}
+
+	// Firestore orders by the range field first, so other orders would not
+	// be what was asked for
+	sort := params.Sort
+	if sort == "" {
+		sort = "created_at"
+		if rangePath != "" {
+			sort = rangePath
+		}
+	}
+	if rangePath != "" && rangePath != sort {
+		return query, errors.New("results filtered by a " + rangePath + " range can only be sorted by " + rangePath)
+	}
+	direction := firestore.Desc
+	if params.Order == "asc" {
+		direction = firestore.Asc
+	}
+	query = query.OrderBy(sort, direction)
+
+	return query, nil
+}
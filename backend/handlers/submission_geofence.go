@@ -0,0 +1,39 @@
+package handlers
+
+import (
+	"math"
+
+	"rice-monitor-api/models"
+	"rice-monitor-api/utils"
+)
+
+// outOfBoundsM returns how many meters the observation position lies outside
+// the field, beyond the accuracy of the fix, or 0 when it is in bounds. Fields
+// with a boundary polygon are checked against it, other fields against a
+// circle of geofenceRadiusM around their coordinates. Positions that cannot
+// be checked, such as for fields without coordinates, count as in bounds.
+func (sh *SubmissionHandler) outOfBoundsM(fieldID string, location *models.GPSFix) float64 {
+	if location == nil || sh.geofenceRadiusM == 0 {
+		return 0
+	}
+	field, err := sh.getSubmissionField(models.Submission{FieldID: fieldID})
+	if err != nil {
+		return 0
+	}
+
+	var distance float64
+	switch {
+	case len(field.Boundary) >= 3:
+		distance = 1000 * utils.DistanceToPolygonKm(location.Point(), field.Boundary)
+	case field.Coordinates.Latitude != 0 || field.Coordinates.Longitude != 0:
+		distance = 1000*utils.DistanceKm(location.Point(), field.Coordinates) - sh.geofenceRadiusM
+	default:
+		return 0
+	}
+
+	outside := distance - location.Accuracy
+	if outside <= 0 {
+		return 0
+	}
+	return math.Round(outside)
+}
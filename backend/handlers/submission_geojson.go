@@ -0,0 +1,103 @@
+package handlers
+
+import (
+	"bytes"
+	"io"
+	"net/http"
+	"strings"
+	"time"
+
+	"rice-monitor-api/models"
+	"rice-monitor-api/utils"
+
+	"github.com/gin-gonic/gin"
+)
+
+// submissionExportFormat reads the format of a submissions export, which can
+// also be geojson. It responds with 400 and returns false for other formats.
+func submissionExportFormat(c *gin.Context) (string, bool) {
+	format := c.DefaultQuery("format", "csv")
+	if format != "csv" && format != "xlsx" && format != "geojson" {
+		c.JSON(http.StatusBadRequest, models.ErrorResponse{
+			Error:   "invalid_format",
+			Message: "format must be csv, xlsx or geojson",
+		})
+		return "", false
+	}
+	return format, true
+}
+
+// writeSubmissionsGeoJSON sends the submissions as a GeoJSON FeatureCollection
+// of points, for GIS tools such as QGIS
+func (sh *SubmissionHandler) writeSubmissionsGeoJSON(c *gin.Context, submissions []models.Submission, loc *time.Location) {
+	var buf bytes.Buffer
+	if err := sh.encodeSubmissionsGeoJSON(&buf, submissions, loc); err != nil {
+		c.JSON(http.StatusInternalServerError, models.ErrorResponse{
+			Error:   "internal_error",
+			Message: "Failed to build export",
+		})
+		return
+	}
+
+	stampAgreement(c)
+	c.Header("Content-Disposition", "attachment; filename=submissions.geojson")
+	c.Data(http.StatusOK, "application/geo+json", buf.Bytes())
+}
+
+// encodeSubmissionsGeoJSON writes the submissions as a FeatureCollection of
+// points. Each point is the GPS position captured with the observation, else
+// the coordinates reported with it, else its field's coordinates;
+// position_source says which.
+func (sh *SubmissionHandler) encodeSubmissionsGeoJSON(w io.Writer, submissions []models.Submission, loc *time.Location) error {
+	fields, err := sh.submissionFields(submissions)
+	if err != nil {
+		return err
+	}
+
+	features := make([]utils.GeoJSONFeature, len(submissions))
+	for i, s := range submissions {
+		field := fields[s.FieldID]
+		point, source := submissionPoint(s, field)
+		properties := map[string]interface{}{
+			"field_id":          s.FieldID,
+			"field_name":        "",
+			"date":              s.Date.In(loc).Format("2006-01-02"),
+			"growth_stage":      s.GrowthStage,
+			"plant_conditions":  strings.Join(s.PlantConditions, ";"),
+			"culm_length":       s.TraitMeasurements.CulmLength,
+			"panicle_length":    s.TraitMeasurements.PanicleLength,
+			"panicles_per_hill": s.TraitMeasurements.PaniclesPerHill,
+			"hills_observed":    s.TraitMeasurements.HillsObserved,
+			"hill_id":           s.HillID,
+			"observer":          s.ObserverName,
+			"status":            s.Status,
+			"position_source":   source,
+		}
+		if field != nil {
+			properties["field_name"] = field.Name
+		}
+		if len(s.TraitMeasurements.Custom) > 0 {
+			properties["custom_traits"] = s.TraitMeasurements.Custom
+		}
+		if s.Location != nil && source == "gps" {
+			properties["accuracy_m"] = s.Location.Accuracy
+		}
+		features[i] = utils.GeoJSONFeature{ID: s.ID, Point: point, Properties: properties}
+	}
+
+	return utils.WriteGeoJSON(w, features)
+}
+
+// submissionPoint returns where a submission was observed and how that is known
+func submissionPoint(s models.Submission, field *models.Field) (*models.Location, string) {
+	switch {
+	case s.Location != nil:
+		point := s.Location.Point()
+		return &point, "gps"
+	case s.Coordinates != nil:
+		return s.Coordinates, "coordinates"
+	case field != nil && (field.Coordinates.Latitude != 0 || field.Coordinates.Longitude != 0):
+		return &field.Coordinates, "field"
+	}
+	return nil, ""
+}
@@ -0,0 +1,121 @@
+package handlers
+
+import (
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"net/http"
+	"strings"
+	"time"
+
+	"rice-monitor-api/models"
+	"rice-monitor-api/utils"
+
+	"cloud.google.com/go/firestore"
+	"github.com/gin-gonic/gin"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+)
+
+// maxIdempotencyKeyLength matches the limit on client_id in the body
+const maxIdempotencyKeyLength = 255
+
+// idempotencyKey returns the key of a submission request, from the
+// Idempotency-Key header or client_id in the body, or "" when it has none
+func idempotencyKey(c *gin.Context, req models.CreateSubmissionRequest) (string, *models.ErrorResponse) {
+	header := strings.TrimSpace(c.GetHeader("Idempotency-Key"))
+	body := strings.TrimSpace(req.ClientID)
+	if header != "" && body != "" && header != body {
+		return "", &models.ErrorResponse{
+			Error:   "idempotency_key_mismatch",
+			Message: "Idempotency-Key header and client_id differ",
+		}
+	}
+	if len(header) > maxIdempotencyKeyLength {
+		return "", &models.ErrorResponse{
+			Error:   "invalid_request",
+			Message: "Idempotency-Key may be at most 255 characters",
+		}
+	}
+	if header != "" {
+		return header, nil
+	}
+	return body, nil
+}
+
+// submissionRequestHash fingerprints a submission request, so a key reused
+// for a different submission is told apart from a retry
+func submissionRequestHash(req models.CreateSubmissionRequest) string {
+	req.ClientID = ""
+	encoded, _ := json.Marshal(req)
+	sum := sha256.Sum256(encoded)
+	return hex.EncodeToString(sum[:])
+}
+
+// idempotencyRef is the record of a user's key. Keys are only unique per user.
+func (sh *SubmissionHandler) idempotencyRef(userID, key string) *firestore.DocumentRef {
+	return sh.firestoreService.IdempotencyKeys().Doc(utils.HashToken(userID + ":" + key))
+}
+
+// replaySubmission responds with the submission created earlier for the key.
+// It reports false, without responding, when the key has not been used yet.
+func (sh *SubmissionHandler) replaySubmission(c *gin.Context, user *models.User, key, hash string) bool {
+	ctx := sh.firestoreService.Context()
+	doc, err := sh.idempotencyRef(user.ID, key).Get(ctx)
+	if status.Code(err) == codes.NotFound {
+		return false
+	}
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, models.ErrorResponse{
+			Error:   "internal_error",
+			Message: "Failed to check the idempotency key",
+		})
+		return true
+	}
+
+	var record models.IdempotencyKey
+	doc.DataTo(&record)
+	if record.RequestHash != hash {
+		c.JSON(http.StatusUnprocessableEntity, models.ErrorResponse{
+			Error:   "idempotency_key_reused",
+			Message: "The idempotency key was already used for a different submission",
+		})
+		return true
+	}
+
+	doc, err = sh.firestoreService.Submissions().Doc(record.SubmissionID).Get(ctx)
+	if err != nil {
+		c.JSON(http.StatusNotFound, models.ErrorResponse{
+			Error:   "not_found",
+			Message: "The submission created for this idempotency key no longer exists",
+		})
+		return true
+	}
+	var submission models.Submission
+	doc.DataTo(&submission)
+
+	c.Header("Idempotent-Replayed", "true")
+	c.JSON(http.StatusOK, models.SuccessResponse{
+		Success: true,
+		Data:    submission,
+		Message: "Submission already created",
+	})
+	return true
+}
+
+// createIdempotent stores the submission together with its key record. It
+// fails with codes.AlreadyExists when a concurrent request used the key first.
+func (sh *SubmissionHandler) createIdempotent(ctx context.Context, user *models.User, key, hash string, submission *models.Submission) error {
+	batch := sh.firestoreService.Client.Batch()
+	batch.Create(sh.idempotencyRef(user.ID, key), models.IdempotencyKey{
+		Key:          key,
+		UserID:       user.ID,
+		SubmissionID: submission.ID,
+		RequestHash:  hash,
+		CreatedAt:    time.Now(),
+	})
+	batch.Create(sh.firestoreService.Submissions().Doc(submission.ID), submission)
+	_, err := batch.Commit(ctx)
+	return err
+}
@@ -0,0 +1,74 @@
+package handlers
+
+import (
+	"net/http"
+	"strconv"
+	"time"
+
+	"rice-monitor-api/models"
+
+	"github.com/gin-gonic/gin"
+)
+
+// @Summary List fields overdue for a visit
+// @Description List the fields that went longer than their monitoring cadence without an observation, longest
+// @Description overdue first. Drafts do not count as visits and archived fields are left out. Observers only see the
+// @Description fields they own or are assigned to.
+// @Tags submissions
+// @Produce  json
+// @Security ApiKeyAuth
+// @Param days query int false "List fields without an observation in this many days instead of their cadence (1-365)"
+// @Success 200 {object} models.SuccessResponse
+// @Failure 400 {object} models.ErrorResponse
+// @Failure 500 {object} models.ErrorResponse
+// @Router /submissions/overdue [get]
+func (sh *SubmissionHandler) GetOverdueFields(c *gin.Context) {
+	currentUser, _ := c.Get("user")
+	user := currentUser.(*models.User)
+
+	days := 0
+	if value := c.Query("days"); value != "" {
+		n, err := strconv.Atoi(value)
+		if err != nil || n < 1 || n > 365 {
+			c.JSON(http.StatusBadRequest, models.ErrorResponse{
+				Error:   "invalid_request",
+				Message: "days must be between 1 and 365",
+			})
+			return
+		}
+		days = n
+	}
+
+	ctx := sh.firestoreService.Context()
+	docs, err := orgScope(sh.firestoreService.Fields().Query, user).Documents(ctx).GetAll()
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, models.ErrorResponse{
+			Error:   "internal_error",
+			Message: "Failed to retrieve fields",
+		})
+		return
+	}
+
+	var fields []models.Field
+	for _, doc := range docs {
+		var field models.Field
+		doc.DataTo(&field)
+		if !field.Archived && canSubmitToField(user, &field) {
+			fields = append(fields, field)
+		}
+	}
+
+	overdue, err := sh.overdueService.Overdue(fields, days, time.Now())
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, models.ErrorResponse{
+			Error:   "internal_error",
+			Message: "Failed to check the fields' last visits",
+		})
+		return
+	}
+
+	c.JSON(http.StatusOK, models.SuccessResponse{
+		Success: true,
+		Data:    overdue,
+	})
+}
@@ -0,0 +1,155 @@
+package handlers
+
+import (
+	"math"
+	"strconv"
+	"time"
+
+	"rice-monitor-api/models"
+	"rice-monitor-api/utils"
+)
+
+// replicatedTraits are the built-in traits measured on each hill. Hills
+// observed is the number of replicates itself.
+var replicatedTraits = []string{"culm_length", "panicle_length", "panicles_per_hill"}
+
+// replicateExportColumns describes the columns of a replicates export, one row per measured hill
+var replicateExportColumns = append(append([]models.ExportColumn{
+	{Name: "Submission ID", Type: "string", Description: "ID of the submission the hill was measured for"},
+	{Name: "Field ID", Type: "string", Description: "ID of the monitored field"},
+	{Name: "Field Name", Type: "string", Description: "Name of the monitored field"},
+	{Name: "Date", Type: "date", Description: "Observation date (YYYY-MM-DD) in the exporting user's timezone"},
+	{Name: "Replicate", Type: "integer", Description: "Position of the hill among the submission's replicates, from 1"},
+	{Name: "Hill ID", Type: "string", Description: "Marked hill measured, empty when not recorded"},
+}, replicatedTraitColumns("")...),
+	models.ExportColumn{Name: "Custom Traits", Type: "string", Description: "Semicolon separated key=value pairs of the deployment's custom traits, see GET /traits"},
+)
+
+// replicateSummaryColumns describes the standard deviation columns of the
+// submissions export; the trait columns hold the means
+var replicateSummaryColumns = replicatedTraitColumns(" SD")
+
+// replicatedTraitColumns describes one column per replicated trait, its label followed by suffix
+func replicatedTraitColumns(suffix string) []models.ExportColumn {
+	var columns []models.ExportColumn
+	for _, trait := range utils.Traits {
+		if !utils.Contains(replicatedTraits, trait.Key) {
+			continue
+		}
+		column := models.ExportColumn{Name: trait.Label + suffix, Type: trait.Type, Unit: trait.Unit, Description: trait.Description}
+		if suffix != "" {
+			column.Type = "number"
+			column.Description = "Sample standard deviation of " + trait.Label + " over the replicates, empty without replicates"
+		}
+		columns = append(columns, column)
+	}
+	return columns
+}
+
+// summarizeReplicates computes the mean and sample standard deviation of each
+// trait over the replicates, leaving out hills where it was not measured, and
+// the trait measurements of the submission: the means, rounded to whole
+// numbers for counts, with the number of hills observed
+func summarizeReplicates(replicates []models.Replicate) (models.TraitMeasurements, map[string]models.TraitSummary) {
+	values := map[string][]float64{}
+	for _, replicate := range replicates {
+		for key, value := range map[string]float64{
+			"culm_length":       replicate.CulmLength,
+			"panicle_length":    replicate.PanicleLength,
+			"panicles_per_hill": float64(replicate.PaniclesPerHill),
+		} {
+			if value != 0 {
+				values[key] = append(values[key], value)
+			}
+		}
+		for key, value := range replicate.Custom {
+			values[key] = append(values[key], value)
+		}
+	}
+
+	summary := map[string]models.TraitSummary{}
+	for key, measured := range values {
+		var sum float64
+		for _, value := range measured {
+			sum += value
+		}
+		mean := sum / float64(len(measured))
+		var squares float64
+		for _, value := range measured {
+			squares += (value - mean) * (value - mean)
+		}
+		stats := models.TraitSummary{N: len(measured), Mean: roundHundredths(mean)}
+		if len(measured) > 1 {
+			stats.StdDev = roundHundredths(math.Sqrt(squares / float64(len(measured)-1)))
+		}
+		summary[key] = stats
+	}
+
+	traits := models.TraitMeasurements{
+		CulmLength:      summary["culm_length"].Mean,
+		PanicleLength:   summary["panicle_length"].Mean,
+		PaniclesPerHill: int(math.Round(summary["panicles_per_hill"].Mean)),
+		HillsObserved:   len(replicates),
+	}
+	for key, stats := range summary {
+		if !utils.Contains(replicatedTraits, key) {
+			if traits.Custom == nil {
+				traits.Custom = map[string]float64{}
+			}
+			traits.Custom[key] = stats.Mean
+		}
+	}
+	return traits, summary
+}
+
+// replicatesProblem validates the custom trait values of each replicate. On
+// failure it returns the status code and error to respond with.
+func (sh *SubmissionHandler) replicatesProblem(replicates []models.Replicate) (int, *models.ErrorResponse) {
+	for i, replicate := range replicates {
+		if code, problem := sh.customTraitsProblem(replicate.Custom); problem != nil {
+			problem.Message = "replicate " + strconv.Itoa(i+1) + ": " + problem.Message
+			return code, problem
+		}
+	}
+	return 0, nil
+}
+
+// replicateSummaryRow formats the standard deviations of the replicateSummaryColumns
+func replicateSummaryRow(summary map[string]models.TraitSummary) []string {
+	var row []string
+	for _, trait := range utils.Traits {
+		if !utils.Contains(replicatedTraits, trait.Key) {
+			continue
+		}
+		value := ""
+		if stats, ok := summary[trait.Key]; ok {
+			value = strconv.FormatFloat(stats.StdDev, 'f', -1, 64)
+		}
+		row = append(row, value)
+	}
+	return row
+}
+
+// replicateExportRows formats a submission's replicates as the replicateExportColumns
+func replicateExportRows(s models.Submission, fieldName string, loc *time.Location) [][]string {
+	rows := make([][]string, len(s.Replicates))
+	for i, replicate := range s.Replicates {
+		values := map[string]float64{
+			"culm_length":       replicate.CulmLength,
+			"panicle_length":    replicate.PanicleLength,
+			"panicles_per_hill": float64(replicate.PaniclesPerHill),
+		}
+		row := []string{s.ID, s.FieldID, fieldName, s.Date.In(loc).Format("2006-01-02"), strconv.Itoa(i + 1), replicate.HillID}
+		for _, trait := range utils.Traits {
+			if utils.Contains(replicatedTraits, trait.Key) {
+				row = append(row, strconv.FormatFloat(values[trait.Key], 'f', -1, 64))
+			}
+		}
+		rows[i] = append(row, customTraitsColumn(replicate.Custom))
+	}
+	return rows
+}
+
+func roundHundredths(value float64) float64 {
+	return math.Round(value*100) / 100
+}
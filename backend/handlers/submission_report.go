@@ -0,0 +1,245 @@
+package handlers
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"image"
+	_ "image/jpeg" // decoders for the photo thumbnails
+	_ "image/png"
+	"log"
+	"net/http"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+
+	"rice-monitor-api/models"
+	"rice-monitor-api/utils"
+
+	"github.com/gin-gonic/gin"
+)
+
+const (
+	// reportPhotos is the most photos a report embeds
+	reportPhotos = 12
+	// reportPhotoBytes is the largest photo a report downloads
+	reportPhotoBytes = 10 << 20
+	// reportThumbnailSize is the longer side of an embedded thumbnail in pixels
+	reportThumbnailSize = 400
+)
+
+// @Summary Submission report
+// @Description Download a printable PDF report of a submission for paper archives: its field, the observation,
+// @Description measurements, weather, notes and thumbnails of up to 12 photos. Dates are in the user's timezone.
+// @Tags submissions
+// @Produce  application/pdf
+// @Security ApiKeyAuth
+// @Param id path string true "Submission ID"
+// @Success 200 {file} file
+// @Failure 403 {object} models.ErrorResponse
+// @Failure 404 {object} models.ErrorResponse
+// @Failure 500 {object} models.ErrorResponse
+// @Router /submissions/{id}/report.pdf [get]
+func (sh *SubmissionHandler) GetSubmissionReport(c *gin.Context) {
+	currentUser, _ := c.Get("user")
+	user := currentUser.(*models.User)
+
+	ctx := sh.firestoreService.Context()
+	doc, err := sh.firestoreService.Submissions().Doc(c.Param("id")).Get(ctx)
+	if err != nil {
+		c.JSON(http.StatusNotFound, models.ErrorResponse{
+			Error:   "not_found",
+			Message: "Submission not found",
+		})
+		return
+	}
+	var submission models.Submission
+	doc.DataTo(&submission)
+
+	if !utils.HasPermissionIn(user, utils.PermSubmissionsReadAll, submission.OrgID) && submission.UserID != user.ID {
+		c.JSON(http.StatusForbidden, models.ErrorResponse{
+			Error:   "forbidden",
+			Message: "Access denied",
+		})
+		return
+	}
+
+	field, err := sh.getSubmissionField(submission)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, models.ErrorResponse{
+			Error:   "internal_error",
+			Message: "Failed to retrieve associated field data",
+		})
+		return
+	}
+
+	var buf bytes.Buffer
+	if err := sh.submissionReport(&submission, field, utils.UserLocation(user)).Write(&buf); err != nil {
+		c.JSON(http.StatusInternalServerError, models.ErrorResponse{
+			Error:   "internal_error",
+			Message: "Failed to build report",
+		})
+		return
+	}
+
+	c.Header("Content-Disposition", fmt.Sprintf("attachment; filename=submission-%s.pdf", submission.ID))
+	c.Data(http.StatusOK, "application/pdf", buf.Bytes())
+}
+
+// submissionReport lays out the report of a submission
+func (sh *SubmissionHandler) submissionReport(s *models.Submission, field *models.Field, loc *time.Location) *utils.PDF {
+	pdf := utils.NewPDF()
+	pdf.Title("Field Observation Report")
+	pdf.Field("Submission", s.ID)
+	pdf.Field("Generated", time.Now().In(loc).Format("2 January 2006 15:04 MST"))
+
+	pdf.Heading("Field")
+	if field.ID == "" {
+		pdf.Paragraph("Not linked to a field.")
+	} else {
+		pdf.Field("Name", field.Name)
+		pdf.Field("Location", field.Location)
+		pdf.Field("Rice variety", field.RiceVariety)
+		if field.TransplantDate != "" {
+			pdf.Field("Transplanted", field.TransplantDate)
+		}
+		if field.Area > 0 {
+			pdf.Field("Area", strconv.FormatFloat(field.Area, 'f', -1, 64)+" ha")
+		}
+		if field.Coordinates.Latitude != 0 || field.Coordinates.Longitude != 0 {
+			pdf.Field("Coordinates", formatPosition(field.Coordinates))
+		}
+	}
+
+	pdf.Heading("Observation")
+	pdf.Field("Date", s.Date.In(loc).Format("2 January 2006"))
+	pdf.Field("Growth stage", s.GrowthStage)
+	pdf.Field("Plant conditions", strings.Join(s.PlantConditions, ", "))
+	if s.HillID != "" {
+		pdf.Field("Hill", s.HillID)
+	}
+	pdf.Field("Observer", s.ObserverName)
+	pdf.Field("Status", s.Status)
+	if s.ReviewComment != "" {
+		pdf.Field("Review comment", s.ReviewComment)
+	}
+	if s.Location != nil {
+		position := formatPosition(s.Location.Point())
+		if s.Location.Accuracy > 0 {
+			position += fmt.Sprintf(" (within %.0f m)", s.Location.Accuracy)
+		}
+		pdf.Field("GPS position", position)
+		if s.OutOfBoundsM > 0 {
+			pdf.Field("Geofence", fmt.Sprintf("%.0f m outside the field", s.OutOfBoundsM))
+		}
+	}
+
+	pdf.Heading("Measurements")
+	measured := traitValues(s.TraitMeasurements)
+	var raw map[string]float64
+	if s.RawMeasurements != nil {
+		raw = traitValues(*s.RawMeasurements)
+	}
+	for _, trait := range utils.Traits {
+		value := strconv.FormatFloat(measured[trait.Key], 'f', -1, 64)
+		if trait.Unit != "count" {
+			value += " " + trait.Unit
+		}
+		if raw != nil && raw[trait.Key] != measured[trait.Key] {
+			value += fmt.Sprintf(" (calibrated, measured %s)", strconv.FormatFloat(raw[trait.Key], 'f', -1, 64))
+		}
+		pdf.Field(trait.Label, value)
+	}
+	// Custom traits are labelled from their definitions while they are defined
+	custom := make([]string, 0, len(s.TraitMeasurements.Custom))
+	for key := range s.TraitMeasurements.Custom {
+		custom = append(custom, key)
+	}
+	sort.Strings(custom)
+	for _, key := range custom {
+		label, value := key, strconv.FormatFloat(s.TraitMeasurements.Custom[key], 'f', -1, 64)
+		if definition, err := sh.traitService.Definition(key); err == nil && definition != nil {
+			label = definition.Label
+			if definition.Unit != "count" {
+				value += " " + definition.Unit
+			}
+		}
+		pdf.Field(label, value)
+	}
+	if s.Weather != nil {
+		values := weatherColumns(s.Weather)
+		for i, column := range weatherExportColumns[:4] {
+			if values[i] != "" {
+				pdf.Field(column.Name, values[i])
+			}
+		}
+	}
+
+	pdf.Heading("Notes")
+	if strings.TrimSpace(s.Notes) == "" {
+		pdf.Paragraph("None.")
+	} else {
+		pdf.Paragraph(s.Notes)
+	}
+
+	pdf.Heading("Photos")
+	thumbnails, missing := sh.reportThumbnails(s)
+	if err := pdf.Thumbnails(thumbnails); err != nil {
+		log.Printf("Failed to embed photos in the report of submission %s: %v", s.ID, err)
+	}
+	switch {
+	case len(s.Images) == 0:
+		pdf.Paragraph("No photos.")
+	case missing > 0:
+		pdf.Paragraph(fmt.Sprintf("%d of %d photos could not be included.", missing, len(s.Images)))
+	}
+
+	return pdf
+}
+
+// reportThumbnails loads thumbnails of the submission's photos from storage.
+// Photos stored elsewhere, too large, in other formats or beyond reportPhotos
+// are counted as missing.
+func (sh *SubmissionHandler) reportThumbnails(s *models.Submission) ([]image.Image, int) {
+	var thumbnails []image.Image
+	for i, url := range s.Images {
+		if i == reportPhotos {
+			break
+		}
+		name, ok := sh.storageService.ObjectName(url)
+		if !ok {
+			continue
+		}
+		data, err := sh.storageService.ReadObject(name, reportPhotoBytes)
+		if err != nil {
+			log.Printf("Failed to read photo %s of submission %s: %v", name, s.ID, err)
+			continue
+		}
+		img, _, err := image.Decode(bytes.NewReader(data))
+		if err != nil {
+			continue
+		}
+		thumbnails = append(thumbnails, utils.Thumbnail(img, reportThumbnailSize))
+	}
+	return thumbnails, len(s.Images) - len(thumbnails)
+}
+
+// traitValues returns trait measurements by their registry key, custom
+// traits included
+func traitValues(traits models.TraitMeasurements) map[string]float64 {
+	custom := traits.Custom
+	traits.Custom = nil
+
+	values := map[string]float64{}
+	encoded, _ := json.Marshal(traits)
+	json.Unmarshal(encoded, &values)
+	for key, value := range custom {
+		values[key] = value
+	}
+	return values
+}
+
+func formatPosition(point models.Location) string {
+	return fmt.Sprintf("%.6f, %.6f", point.Latitude, point.Longitude)
+}
@@ -0,0 +1,81 @@
+package handlers
+
+import (
+	"net/http"
+	"strings"
+
+	"rice-monitor-api/models"
+
+	"github.com/gin-gonic/gin"
+)
+
+// @Summary Approve a submission
+// @Description Approve a submission, with an optional comment. The reviewer and time are recorded on the
+// @Description submission and in the review log.
+// @Tags submissions
+// @Accept  json
+// @Produce  json
+// @Security ApiKeyAuth
+// @Param id path string true "Submission ID"
+// @Param review body models.ReviewCommentRequest false "Reviewer comment"
+// @Success 200 {object} models.SuccessResponse
+// @Failure 400 {object} models.ErrorResponse
+// @Failure 403 {object} models.ErrorResponse
+// @Failure 404 {object} models.ErrorResponse
+// @Failure 409 {object} models.ErrorResponse
+// @Failure 422 {object} models.ErrorResponse
+// @Failure 500 {object} models.ErrorResponse
+// @Router /submissions/{id}/approve [post]
+func (sh *SubmissionHandler) ApproveSubmission(c *gin.Context) {
+	var req models.ReviewCommentRequest
+	if c.Request.ContentLength != 0 {
+		if err := c.ShouldBindJSON(&req); err != nil {
+			c.JSON(http.StatusBadRequest, models.ErrorResponse{
+				Error:   "invalid_request",
+				Message: err.Error(),
+			})
+			return
+		}
+	}
+
+	sh.reviewSubmission(c, c.Param("id"), "approved", strings.TrimSpace(req.Comment))
+}
+
+// @Summary Reject a submission
+// @Description Reject a submission. A comment telling the observer what was wrong is required. The reviewer
+// @Description and time are recorded on the submission and in the review log.
+// @Tags submissions
+// @Accept  json
+// @Produce  json
+// @Security ApiKeyAuth
+// @Param id path string true "Submission ID"
+// @Param review body models.ReviewCommentRequest true "Reviewer comment"
+// @Success 200 {object} models.SuccessResponse
+// @Failure 400 {object} models.ErrorResponse
+// @Failure 403 {object} models.ErrorResponse
+// @Failure 404 {object} models.ErrorResponse
+// @Failure 409 {object} models.ErrorResponse
+// @Failure 422 {object} models.ErrorResponse
+// @Failure 500 {object} models.ErrorResponse
+// @Router /submissions/{id}/reject [post]
+func (sh *SubmissionHandler) RejectSubmission(c *gin.Context) {
+	var req models.ReviewCommentRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, models.ErrorResponse{
+			Error:   "invalid_request",
+			Message: err.Error(),
+		})
+		return
+	}
+
+	comment := strings.TrimSpace(req.Comment)
+	if comment == "" {
+		c.JSON(http.StatusBadRequest, models.ErrorResponse{
+			Error:   "comment_required",
+			Message: "Explain why the submission is rejected",
+		})
+		return
+	}
+
+	sh.reviewSubmission(c, c.Param("id"), "rejected", comment)
+}
@@ -0,0 +1,137 @@
+package handlers
+
+import (
+	"net/http"
+	"strconv"
+	"strings"
+
+	"rice-monitor-api/models"
+	"rice-monitor-api/utils"
+
+	"github.com/gin-gonic/gin"
+)
+
+// @Summary Search submissions
+// @Description Search submissions by growth stage, plant condition or pest, named in any supported
+// @Description language, or by free text in the notes. A query naming a vocabulary entry also matches
+// @Description its synonyms and translations, so "মাজরা পোকা" finds notes mentioning stem borer.
+// @Tags submissions
+// @Produce  json
+// @Security ApiKeyAuth
+// @Param q query string true "Search query"
+// @Param limit query int false "Maximum number of results (default 50, max 200)"
+// @Success 200 {object} models.SuccessResponse
+// @Failure 400 {object} models.ErrorResponse
+// @Failure 500 {object} models.ErrorResponse
+// @Router /submissions/search [get]
+func (sh *SubmissionHandler) SearchSubmissions(c *gin.Context) {
+	currentUser, _ := c.Get("user")
+	user := currentUser.(*models.User)
+
+	q := strings.TrimSpace(c.Query("q"))
+	if q == "" {
+		c.JSON(http.StatusBadRequest, models.ErrorResponse{
+			Error:   "invalid_request",
+			Message: "q is required",
+		})
+		return
+	}
+	limit, err := strconv.Atoi(c.DefaultQuery("limit", "50"))
+	if err != nil || limit <= 0 {
+		limit = 50
+	}
+	if limit > 200 {
+		limit = 200
+	}
+
+	match, matched := sh.vocabularyService.Resolve(q)
+	terms := sh.vocabularyService.Expand(q)
+
+	ctx := sh.firestoreService.Context()
+	query := sh.firestoreService.Submissions().Query
+	if !utils.HasPermissionIn(user, utils.PermSubmissionsReadAll, user.OrgID) {
+		query = query.Where("user_id", "==", user.ID)
+	} else {
+		query = orgScope(query, user)
+	}
+
+	docs, err := query.Documents(ctx).GetAll()
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, models.ErrorResponse{
+			Error:   "internal_error",
+			Message: "Failed to search submissions",
+		})
+		return
+	}
+
+	var found []models.Submission
+	for _, doc := range docs {
+		if len(found) == limit {
+			break
+		}
+
+		var submission models.Submission
+		doc.DataTo(&submission)
+		if submissionMatches(submission, match, matched, terms) {
+			found = append(found, submission)
+		}
+	}
+	fields, err := sh.submissionFields(found)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, models.ErrorResponse{
+			Error:   "internal_error",
+			Message: "Failed to search submissions",
+		})
+		return
+	}
+
+	submissionsResponse := []models.SubmissionResponse{}
+	for _, submission := range found {
+		field := &models.Field{}
+		if submission.FieldID != "" {
+			if field = fields[submission.FieldID]; field == nil {
+				continue
+			}
+		}
+		submissionsResponse = append(submissionsResponse, submissionResponse(user, submission, field))
+	}
+
+	data := map[string]interface{}{
+		"submissions": submissionsResponse,
+		"terms":       terms,
+		"limit":       limit,
+		"total":       len(submissionsResponse),
+	}
+	if matched {
+		data["match"] = match
+	}
+	c.JSON(http.StatusOK, models.SuccessResponse{
+		Success: true,
+		Data:    data,
+	})
+}
+
+// submissionMatches reports whether submission has the growth stage or plant
+// condition the query names, or mentions any of terms in its notes
+func submissionMatches(submission models.Submission, match models.VocabularyMatch, matched bool, terms []string) bool {
+	if matched {
+		switch match.Kind {
+		case "growth_stage":
+			if submission.GrowthStage == match.Code {
+				return true
+			}
+		case "condition":
+			if utils.Contains(submission.PlantConditions, match.Code) {
+				return true
+			}
+		}
+	}
+
+	notes := strings.ToLower(strings.Join(strings.Fields(submission.Notes), " "))
+	for _, term := range terms {
+		if strings.Contains(notes, term) {
+			return true
+		}
+	}
+	return false
+}
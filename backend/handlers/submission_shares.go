@@ -0,0 +1,358 @@
+package handlers
+
+import (
+	"fmt"
+	"net/http"
+	"net/url"
+	"strings"
+	"time"
+
+	"rice-monitor-api/models"
+	"rice-monitor-api/utils"
+
+	"cloud.google.com/go/firestore"
+	"github.com/gin-gonic/gin"
+)
+
+// @Summary Share a submission
+// @Description Mint a public, read-only link to the submission and its photos for a collaborator without an
+// @Description account. The link expires after expires_in_hours (168 by default, at most 720) and can be revoked.
+// @Description Only the submission's observer or someone who can read all submissions can share it.
+// @Tags submissions
+// @Accept  json
+// @Produce  json
+// @Security ApiKeyAuth
+// @Param id path string true "Submission ID"
+// @Param share body models.CreateSubmissionShareRequest false "Share link details"
+// @Success 201 {object} models.SuccessResponse
+// @Failure 400 {object} models.ErrorResponse
+// @Failure 403 {object} models.ErrorResponse
+// @Failure 404 {object} models.ErrorResponse
+// @Failure 500 {object} models.ErrorResponse
+// @Router /submissions/{id}/share [post]
+func (sh *SubmissionHandler) CreateSubmissionShare(c *gin.Context) {
+	var req models.CreateSubmissionShareRequest
+	if c.Request.ContentLength != 0 {
+		if err := c.ShouldBindJSON(&req); err != nil {
+			c.JSON(http.StatusBadRequest, models.ErrorResponse{
+				Error:   "invalid_request",
+				Message: err.Error(),
+			})
+			return
+		}
+	}
+
+	currentUser, _ := c.Get("user")
+	user := currentUser.(*models.User)
+
+	submission, ok := sh.shareableSubmission(c, user)
+	if !ok {
+		return
+	}
+
+	rawToken, err := utils.GenerateSecureToken(32)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, models.ErrorResponse{
+			Error:   "internal_error",
+			Message: "Failed to generate share link",
+		})
+		return
+	}
+	rawToken = utils.SubmissionShareTokenPrefix + rawToken
+
+	hours := req.ExpiresInHours
+	if hours == 0 {
+		hours = 168
+	}
+
+	now := time.Now()
+	share := models.SubmissionShare{
+		ID:           utils.GenerateID(),
+		SubmissionID: submission.ID,
+		Prefix:       rawToken[:len(utils.SubmissionShareTokenPrefix)+8],
+		TokenHash:    utils.HashToken(rawToken),
+		CreatedBy:    user.ID,
+		CreatedAt:    now,
+		ExpiresAt:    now.Add(time.Duration(hours) * time.Hour),
+	}
+
+	ctx := sh.firestoreService.Context()
+	if _, err := sh.firestoreService.SubmissionShares().Doc(share.ID).Set(ctx, share); err != nil {
+		c.JSON(http.StatusInternalServerError, models.ErrorResponse{
+			Error:   "internal_error",
+			Message: "Failed to create share link",
+		})
+		return
+	}
+
+	c.JSON(http.StatusCreated, models.SuccessResponse{
+		Success: true,
+		Data: models.CreateSubmissionShareResponse{
+			SubmissionShare: share,
+			Token:           rawToken,
+			URL:             utils.GetEnvOrDefault("PUBLIC_BASE_URL", "http://localhost:8080") + "/api/v1/shared/submission?token=" + url.QueryEscape(rawToken),
+		},
+		Message: "Share link created successfully. Store it now, it will not be shown again",
+	})
+}
+
+// @Summary List a submission's share links
+// @Description List the share links of a submission, newest first, with how often each was viewed
+// @Tags submissions
+// @Produce  json
+// @Security ApiKeyAuth
+// @Param id path string true "Submission ID"
+// @Success 200 {object} models.SuccessResponse
+// @Failure 403 {object} models.ErrorResponse
+// @Failure 404 {object} models.ErrorResponse
+// @Failure 500 {object} models.ErrorResponse
+// @Router /submissions/{id}/shares [get]
+func (sh *SubmissionHandler) GetSubmissionShares(c *gin.Context) {
+	currentUser, _ := c.Get("user")
+	user := currentUser.(*models.User)
+
+	submission, ok := sh.shareableSubmission(c, user)
+	if !ok {
+		return
+	}
+
+	ctx := sh.firestoreService.Context()
+	docs, err := sh.firestoreService.SubmissionShares().
+		Where("submission_id", "==", submission.ID).
+		OrderBy("created_at", firestore.Desc).
+		Documents(ctx).GetAll()
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, models.ErrorResponse{
+			Error:   "internal_error",
+			Message: "Failed to retrieve share links",
+		})
+		return
+	}
+
+	shares := []models.SubmissionShare{}
+	for _, doc := range docs {
+		var share models.SubmissionShare
+		doc.DataTo(&share)
+		shares = append(shares, share)
+	}
+
+	c.JSON(http.StatusOK, models.SuccessResponse{
+		Success: true,
+		Data:    shares,
+	})
+}
+
+// @Summary Revoke a share link
+// @Description Revoke a share link of a submission; it stops working at once
+// @Tags submissions
+// @Produce  json
+// @Security ApiKeyAuth
+// @Param id path string true "Submission ID"
+// @Param shareId path string true "Share link ID"
+// @Success 200 {object} models.SuccessResponse
+// @Failure 403 {object} models.ErrorResponse
+// @Failure 404 {object} models.ErrorResponse
+// @Failure 409 {object} models.ErrorResponse
+// @Failure 500 {object} models.ErrorResponse
+// @Router /submissions/{id}/shares/{shareId} [delete]
+func (sh *SubmissionHandler) RevokeSubmissionShare(c *gin.Context) {
+	currentUser, _ := c.Get("user")
+	user := currentUser.(*models.User)
+
+	submission, ok := sh.shareableSubmission(c, user)
+	if !ok {
+		return
+	}
+
+	ctx := sh.firestoreService.Context()
+	docRef := sh.firestoreService.SubmissionShares().Doc(c.Param("shareId"))
+	doc, err := docRef.Get(ctx)
+	if err != nil {
+		c.JSON(http.StatusNotFound, models.ErrorResponse{
+			Error:   "not_found",
+			Message: "Share link not found",
+		})
+		return
+	}
+
+	var share models.SubmissionShare
+	doc.DataTo(&share)
+	if share.SubmissionID != submission.ID {
+		c.JSON(http.StatusNotFound, models.ErrorResponse{
+			Error:   "not_found",
+			Message: "Share link not found",
+		})
+		return
+	}
+	if share.Revoked {
+		c.JSON(http.StatusConflict, models.ErrorResponse{
+			Error:   "already_revoked",
+			Message: "Share link is already revoked",
+		})
+		return
+	}
+
+	now := time.Now()
+	_, err = docRef.Update(ctx, []firestore.Update{
+		{Path: "revoked", Value: true},
+		{Path: "revoked_at", Value: now},
+	})
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, models.ErrorResponse{
+			Error:   "internal_error",
+			Message: "Failed to revoke share link",
+		})
+		return
+	}
+
+	c.JSON(http.StatusOK, models.SuccessResponse{
+		Success: true,
+		Message: "Share link revoked successfully",
+	})
+}
+
+// @Summary View a shared submission
+// @Description Public, read-only view of a submission and its photos through a share link. The observer's
+// @Description account, device and exact GPS position are left out.
+// @Tags submissions
+// @Produce  json
+// @Param token query string true "Share link token"
+// @Success 200 {object} models.SuccessResponse
+// @Failure 401 {object} models.ErrorResponse
+// @Failure 404 {object} models.ErrorResponse
+// @Router /shared/submission [get]
+func (sh *SubmissionHandler) GetSharedSubmission(c *gin.Context) {
+	share, err := sh.getSubmissionShare(c.Query("token"))
+	if err != nil || share.Revoked || time.Now().After(share.ExpiresAt) {
+		c.JSON(http.StatusUnauthorized, models.ErrorResponse{
+			Error:   "invalid_token",
+			Message: "Invalid, expired or revoked share link",
+		})
+		return
+	}
+
+	ctx := sh.firestoreService.Context()
+	doc, err := sh.firestoreService.Submissions().Doc(share.SubmissionID).Get(ctx)
+	if err != nil {
+		c.JSON(http.StatusNotFound, models.ErrorResponse{
+			Error:   "not_found",
+			Message: "Submission not found",
+		})
+		return
+	}
+	var submission models.Submission
+	doc.DataTo(&submission)
+
+	// Links stop working when their creator could no longer share the submission
+	userDoc, err := sh.firestoreService.Users().Doc(share.CreatedBy).Get(ctx)
+	if err != nil {
+		c.JSON(http.StatusUnauthorized, models.ErrorResponse{
+			Error:   "invalid_token",
+			Message: "Invalid, expired or revoked share link",
+		})
+		return
+	}
+	var creator models.User
+	userDoc.DataTo(&creator)
+	if utils.AccountSuspended(&creator) || !canShareSubmission(&creator, &submission) {
+		c.JSON(http.StatusUnauthorized, models.ErrorResponse{
+			Error:   "invalid_token",
+			Message: "Invalid, expired or revoked share link",
+		})
+		return
+	}
+
+	field, err := sh.getSubmissionField(submission)
+	if err != nil {
+		field = &models.Field{}
+	}
+
+	_, err = sh.firestoreService.SubmissionShares().Doc(share.ID).Update(ctx, []firestore.Update{
+		{Path: "views", Value: firestore.Increment(1)},
+		{Path: "last_viewed_at", Value: time.Now()},
+	})
+	if err != nil {
+		fmt.Printf("Failed to count view of share link %s: %v\n", share.ID, err)
+	}
+
+	c.Header("Cache-Control", "no-store")
+	c.Header("X-Robots-Tag", "noindex")
+	c.JSON(http.StatusOK, models.SuccessResponse{
+		Success: true,
+		Data: models.SharedSubmission{
+			ID:                submission.ID,
+			FieldName:         field.Name,
+			FieldLocation:     field.Location,
+			RiceVariety:       field.RiceVariety,
+			Date:              submission.Date,
+			GrowthStage:       submission.GrowthStage,
+			PlantConditions:   submission.PlantConditions,
+			TraitMeasurements: submission.TraitMeasurements,
+			HillID:            submission.HillID,
+			ReplicateSummary:  submission.ReplicateSummary,
+			Notes:             submission.Notes,
+			ObserverName:      submission.ObserverName,
+			Images:            submission.Images,
+			Status:            submission.Status,
+			Tags:              submission.Tags,
+			Weather:           submission.Weather,
+			UpdatedAt:         submission.UpdatedAt,
+			ExpiresAt:         share.ExpiresAt,
+		},
+	})
+}
+
+// shareableSubmission loads the submission of the request, answering 404 or
+// 403 when it is missing or user may not share it
+func (sh *SubmissionHandler) shareableSubmission(c *gin.Context, user *models.User) (*models.Submission, bool) {
+	ctx := sh.firestoreService.Context()
+	doc, err := sh.firestoreService.Submissions().Doc(c.Param("id")).Get(ctx)
+	if err != nil {
+		c.JSON(http.StatusNotFound, models.ErrorResponse{
+			Error:   "not_found",
+			Message: "Submission not found",
+		})
+		return nil, false
+	}
+
+	var submission models.Submission
+	doc.DataTo(&submission)
+	if !canShareSubmission(user, &submission) {
+		c.JSON(http.StatusForbidden, models.ErrorResponse{
+			Error:   "forbidden",
+			Message: "Only the observer or someone who can read all submissions can share it",
+		})
+		return nil, false
+	}
+	return &submission, true
+}
+
+// canShareSubmission reports whether user may share submission: its
+// observer, or someone who can read all submissions of its organization
+func canShareSubmission(user *models.User, submission *models.Submission) bool {
+	return submission.UserID == user.ID || utils.HasPermissionIn(user, utils.PermSubmissionsReadAll, submission.OrgID)
+}
+
+func (sh *SubmissionHandler) getSubmissionShare(rawToken string) (*models.SubmissionShare, error) {
+	if !strings.HasPrefix(rawToken, utils.SubmissionShareTokenPrefix) {
+		return nil, fmt.Errorf("invalid share link")
+	}
+
+	ctx := sh.firestoreService.Context()
+	docs, err := sh.firestoreService.SubmissionShares().
+		Where("token_hash", "==", utils.HashToken(rawToken)).
+		Limit(1).
+		Documents(ctx).GetAll()
+	if err != nil {
+		return nil, err
+	}
+	if len(docs) == 0 {
+		return nil, fmt.Errorf("share link not found")
+	}
+
+	var share models.SubmissionShare
+	if err := docs[0].DataTo(&share); err != nil {
+		return nil, err
+	}
+	return &share, nil
+}
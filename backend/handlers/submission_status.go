@@ -0,0 +1,53 @@
+package handlers
+
+import (
+	"fmt"
+	"strings"
+
+	"rice-monitor-api/models"
+)
+
+// submissionTransitions lists the statuses a submission may move to from each
+// status. Reviewers may decide straight from submitted, and reverse an
+// approval or rejection; a rejected submission goes back to submitted once it
+// has been edited.
+var submissionTransitions = map[string][]string{
+	"draft":        {"submitted"},
+	"submitted":    {"under_review", "approved", "rejected"},
+	"under_review": {"approved", "rejected"},
+	"approved":     {"rejected"},
+	"rejected":     {"submitted", "approved"},
+}
+
+// transitionError describes why a submission cannot move from one status to
+// another, or is nil when it can
+func transitionError(from, to string) *models.ErrorResponse {
+	for _, allowed := range submissionTransitions[from] {
+		if allowed == to {
+			return nil
+		}
+	}
+	message := fmt.Sprintf("A %s submission cannot be moved to %s", from, to)
+	if next := submissionTransitions[from]; len(next) > 0 {
+		message += ", only to " + strings.Join(next, " or ")
+	}
+	return &models.ErrorResponse{Error: "invalid_transition", Message: message}
+}
+
+// statusUpdateProblem validates a change of status by an update against the
+// submission's current status, returning the error to respond with 422 when
+// it is not allowed. edited tells whether the update changes anything else.
+func statusUpdateProblem(submission *models.Submission, status string, edited bool) *models.ErrorResponse {
+	if problem := transitionError(submission.Status, status); problem != nil {
+		return problem
+	}
+
+	// Resubmitting a rejected submission must come with the fix
+	if submission.Status == "rejected" && status == "submitted" && !edited {
+		return &models.ErrorResponse{
+			Error:   "invalid_transition",
+			Message: "Edit a rejected submission when resubmitting it",
+		}
+	}
+	return nil
+}
@@ -0,0 +1,246 @@
+package handlers
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"strings"
+	"time"
+
+	"rice-monitor-api/models"
+	"rice-monitor-api/utils"
+
+	"cloud.google.com/go/firestore"
+	"github.com/gin-gonic/gin"
+	"github.com/gin-gonic/gin/binding"
+)
+
+// @Summary Sync offline edits
+// @Description Apply edits made offline to existing submissions, up to SUBMISSION_BATCH_LIMIT (100 by default) at once.
+// @Description Each edit holds the changes as for PUT /submissions/{id} and the updated_at of the version it was made to.
+// @Description An edit to a submission changed on the server since then is a conflict under the reject policy; under
+// @Description merge it is applied unless the server changed the same paths. Results are returned in order: updated,
+// @Description merged, conflict with the conflicting paths and the server's version, or failed with an error code.
+// @Tags submissions
+// @Accept  json
+// @Produce  json
+// @Security ApiKeyAuth
+// @Param sync body models.SyncSubmissionsRequest true "Offline edits and the conflict policy"
+// @Success 200 {object} models.SuccessResponse
+// @Failure 400 {object} models.ErrorResponse
+// @Router /submissions/sync [post]
+func (sh *SubmissionHandler) SyncSubmissions(c *gin.Context) {
+	var req models.SyncSubmissionsRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, models.ErrorResponse{
+			Error:   "invalid_request",
+			Message: err.Error(),
+		})
+		return
+	}
+	if len(req.Edits) > sh.batchLimit {
+		c.JSON(http.StatusBadRequest, models.ErrorResponse{
+			Error:   "batch_too_large",
+			Message: fmt.Sprintf("A sync can hold at most %d edits", sh.batchLimit),
+		})
+		return
+	}
+
+	currentUser, _ := c.Get("user")
+	user := currentUser.(*models.User)
+
+	policy := req.Policy
+	if policy == "" {
+		policy = sh.syncPolicy
+	}
+
+	results := make([]models.SyncEditResult, len(req.Edits))
+	seen := make(map[string]bool)
+	for i, raw := range req.Edits {
+		results[i].Index = i
+		var edit models.SyncEdit
+		if err := json.Unmarshal(raw, &edit); err != nil {
+			failSync(&results[i], &models.ErrorResponse{Error: "invalid_request", Message: err.Error()})
+			continue
+		}
+		results[i].ID = edit.ID
+		if err := binding.Validator.ValidateStruct(&edit); err != nil {
+			failSync(&results[i], &models.ErrorResponse{Error: "invalid_request", Message: err.Error()})
+			continue
+		}
+		if seen[edit.ID] {
+			failSync(&results[i], &models.ErrorResponse{Error: "duplicate_id", Message: "id is edited by an earlier item of the sync"})
+			continue
+		}
+		seen[edit.ID] = true
+		sh.syncEdit(user, edit, policy, &results[i])
+	}
+
+	counts := map[string]int{"updated": 0, "merged": 0, "conflict": 0, "failed": 0}
+	for _, result := range results {
+		counts[result.Status]++
+	}
+
+	c.JSON(http.StatusOK, models.SuccessResponse{
+		Success: true,
+		Data: map[string]interface{}{
+			"policy":    policy,
+			"results":   results,
+			"updated":   counts["updated"],
+			"merged":    counts["merged"],
+			"conflicts": counts["conflict"],
+			"failed":    counts["failed"],
+		},
+		Message: fmt.Sprintf("%d of %d edits applied", counts["updated"]+counts["merged"], len(results)),
+	})
+}
+
+// syncEdit applies one offline edit under policy and records the outcome in result
+func (sh *SubmissionHandler) syncEdit(user *models.User, edit models.SyncEdit, policy string, result *models.SyncEditResult) {
+	ctx := sh.firestoreService.Context()
+	doc, err := sh.firestoreService.Submissions().Doc(edit.ID).Get(ctx)
+	if err != nil {
+		failSync(result, &models.ErrorResponse{Error: "not_found", Message: "Submission not found"})
+		return
+	}
+
+	var submission models.Submission
+	doc.DataTo(&submission)
+
+	// Checked before the server's version is returned with a conflict
+	if !utils.HasPermissionIn(user, utils.PermSubmissionsUpdateAll, submission.OrgID) && submission.UserID != user.ID {
+		failSync(result, &models.ErrorResponse{Error: "forbidden", Message: "Access denied"})
+		return
+	}
+
+	applied := "updated"
+	if !sameVersion(submission.UpdatedAt, edit.BaseUpdatedAt) {
+		conflicts, err := sh.syncConflicts(&submission, edit)
+		if err != nil {
+			failSync(result, &models.ErrorResponse{Error: "internal_error", Message: "Failed to compare the edit with the server's changes"})
+			return
+		}
+		if policy == "reject" || len(conflicts) > 0 {
+			result.Status = "conflict"
+			result.Error = "edit_conflict"
+			result.Message = "The submission was changed on the server after the version edited"
+			result.Conflicts = conflicts
+			result.Submission = &submission
+			return
+		}
+		applied = "merged"
+	}
+
+	updated, code, problem := sh.updateSubmission(user, submission, doc.UpdateTime, edit.Changes)
+	if code == http.StatusConflict {
+		// Changed between the check and the update; the next sync compares again
+		result.Status, result.Error, result.Message = "conflict", problem.Error, problem.Message
+		return
+	}
+	if problem != nil {
+		failSync(result, problem)
+		return
+	}
+	result.Status = applied
+	result.Submission = updated
+}
+
+// syncConflicts returns the paths an offline edit changes that were also
+// changed on the server after the version edited, as the audit trail records
+func (sh *SubmissionHandler) syncConflicts(submission *models.Submission, edit models.SyncEdit) ([]models.SyncConflict, error) {
+	edited := editedPaths(edit.Changes)
+	if len(edited) == 0 {
+		return nil, nil
+	}
+
+	ctx := sh.firestoreService.Context()
+	docs, err := sh.firestoreService.SubmissionAudit().
+		Where("submission_id", "==", submission.ID).
+		OrderBy("created_at", firestore.Asc).
+		Documents(ctx).GetAll()
+	if err != nil {
+		return nil, err
+	}
+
+	// The latest server change to each edited path
+	latest := make(map[string]models.SubmissionAudit)
+	for _, doc := range docs {
+		var entry models.SubmissionAudit
+		doc.DataTo(&entry)
+		if entry.UpdatedAt == nil || !versionAfter(*entry.UpdatedAt, edit.BaseUpdatedAt) {
+			continue
+		}
+		for _, change := range entry.Changes {
+			for _, path := range edited {
+				if change.Path == path || strings.HasPrefix(change.Path, path+".") {
+					latest[path] = entry
+				}
+			}
+		}
+	}
+
+	client, server := jsonFields(edit.Changes), jsonFields(submission)
+	var conflicts []models.SyncConflict
+	for _, path := range edited {
+		entry, ok := latest[path]
+		if !ok {
+			continue
+		}
+		conflicts = append(conflicts, models.SyncConflict{
+			Path:      path,
+			Client:    client[path],
+			Server:    server[path],
+			ChangedBy: entry.ActorID,
+			ChangedAt: entry.CreatedAt,
+		})
+	}
+	return conflicts, nil
+}
+
+// editedPaths returns the submission paths an update writes, in request order
+func editedPaths(req models.UpdateSubmissionRequest) []string {
+	var paths []string
+	add := func(set bool, path string) {
+		if set {
+			paths = append(paths, path)
+		}
+	}
+	add(req.Date != nil, "date")
+	add(req.GrowthStage != nil, "growth_stage")
+	add(req.PlantConditions != nil, "plant_conditions")
+	// New replicates replace the trait measurements with their means
+	add(req.TraitMeasurements != nil || req.Replicates != nil, "trait_measurements")
+	add(req.Replicates != nil, "replicates")
+	add(req.HillID != nil, "hill_id")
+	add(req.Notes != nil, "notes")
+	add(req.ObserverName != nil, "observer_name")
+	add(req.Images != nil, "images")
+	add(req.Status != nil, "status")
+	add(req.Tags != nil, "tags")
+	return paths
+}
+
+// jsonFields returns the top-level fields of v as encoded in the API
+func jsonFields(v interface{}) map[string]interface{} {
+	fields := map[string]interface{}{}
+	encoded, _ := json.Marshal(v)
+	json.Unmarshal(encoded, &fields)
+	return fields
+}
+
+// sameVersion compares updated_at versions to the millisecond, the precision
+// mobile clients keep
+func sameVersion(a, b time.Time) bool {
+	return a.Truncate(time.Millisecond).Equal(b.Truncate(time.Millisecond))
+}
+
+// versionAfter reports whether version a is newer than b, to the millisecond
+func versionAfter(a, b time.Time) bool {
+	return a.Truncate(time.Millisecond).After(b.Truncate(time.Millisecond))
+}
+
+func failSync(result *models.SyncEditResult, problem *models.ErrorResponse) {
+	result.Status = "failed"
+	result.Error = problem.Error
+	result.Message = problem.Message
+}
@@ -0,0 +1,102 @@
+package handlers
+
+import (
+	"fmt"
+	"log"
+	"net/http"
+	"regexp"
+	"strings"
+	"time"
+
+	"rice-monitor-api/models"
+
+	"cloud.google.com/go/firestore"
+	"github.com/gin-gonic/gin"
+)
+
+// maxSubmissionTags is the most tags one submission can carry
+const maxSubmissionTags = 20
+
+// tagPattern keeps tags short lowercase labels such as training-sample, which
+// also makes them safe in document IDs
+var tagPattern = regexp.MustCompile(`^[a-z0-9][a-z0-9_-]{0,39}$`)
+
+// @Summary List submission tags
+// @Description List the tags used on your organization's submissions, alphabetically, so apps can suggest them.
+// @Tags submissions
+// @Produce  json
+// @Security ApiKeyAuth
+// @Param prefix query string false "Only tags starting with this prefix"
+// @Success 200 {object} models.SuccessResponse
+// @Failure 500 {object} models.ErrorResponse
+// @Router /submissions/tags [get]
+func (sh *SubmissionHandler) GetSubmissionTags(c *gin.Context) {
+	currentUser, _ := c.Get("user")
+	user := currentUser.(*models.User)
+
+	query := sh.firestoreService.SubmissionTags().Where("org_id", "==", user.OrgID)
+	if prefix := strings.ToLower(strings.TrimSpace(c.Query("prefix"))); prefix != "" {
+		query = query.Where("name", ">=", prefix).Where("name", "<", prefix+"\uf8ff")
+	}
+	docs, err := query.OrderBy("name", firestore.Asc).Documents(sh.firestoreService.Context()).GetAll()
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, models.ErrorResponse{
+			Error:   "internal_error",
+			Message: "Failed to retrieve tags",
+		})
+		return
+	}
+
+	tags := []models.SubmissionTag{}
+	for _, doc := range docs {
+		var tag models.SubmissionTag
+		doc.DataTo(&tag)
+		tags = append(tags, tag)
+	}
+
+	c.JSON(http.StatusOK, models.SuccessResponse{
+		Success: true,
+		Data:    tags,
+	})
+}
+
+// cleanTags lowercases and trims tags, drops repeated ones and checks them
+// against tagPattern and maxSubmissionTags
+func cleanTags(tags []string) ([]string, error) {
+	var cleaned []string
+	seen := make(map[string]bool)
+	for _, tag := range tags {
+		tag = strings.ToLower(strings.TrimSpace(tag))
+		if seen[tag] {
+			continue
+		}
+		if !tagPattern.MatchString(tag) {
+			return nil, fmt.Errorf("invalid tag %q: tags are up to 40 lowercase letters, digits, - and _", tag)
+		}
+		seen[tag] = true
+		cleaned = append(cleaned, tag)
+	}
+	if len(cleaned) > maxSubmissionTags {
+		return nil, fmt.Errorf("a submission can have at most %d tags", maxSubmissionTags)
+	}
+	return cleaned, nil
+}
+
+// registerTags records the tags as used in the organization, for
+// GetSubmissionTags. The submission is stored either way, so a failure is only logged.
+func (sh *SubmissionHandler) registerTags(orgID string, tags []string) {
+	if len(tags) == 0 {
+		return
+	}
+	batch := sh.firestoreService.Client.Batch()
+	for _, tag := range tags {
+		batch.Set(sh.firestoreService.SubmissionTags().Doc(orgID+":"+tag), map[string]interface{}{
+			"name":         tag,
+			"org_id":       orgID,
+			"last_used_at": time.Now(),
+		}, firestore.MergeAll)
+	}
+	if _, err := batch.Commit(sh.firestoreService.Context()); err != nil {
+		log.Printf("Failed to register tags of organization %s: %v", orgID, err)
+	}
+}
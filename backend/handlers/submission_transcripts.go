@@ -0,0 +1,155 @@
+package handlers
+
+import (
+	"context"
+	"log"
+	"net/http"
+	"strings"
+	"time"
+
+	"rice-monitor-api/models"
+	"rice-monitor-api/utils"
+
+	"cloud.google.com/go/firestore"
+	"github.com/gin-gonic/gin"
+)
+
+// @Summary Transcribe a voice note
+// @Description Transcribe a voice note attachment again after its transcription failed, or one uploaded with
+// @Description transcribe=false. The transcript is written in the background and appended to the submission's notes.
+// @Tags submissions
+// @Produce  json
+// @Security ApiKeyAuth
+// @Param id path string true "Submission ID"
+// @Param attachmentId path string true "Attachment ID"
+// @Success 202 {object} models.SuccessResponse
+// @Failure 400 {object} models.ErrorResponse
+// @Failure 403 {object} models.ErrorResponse
+// @Failure 404 {object} models.ErrorResponse
+// @Failure 409 {object} models.ErrorResponse
+// @Failure 503 {object} models.ErrorResponse
+// @Router /submissions/{id}/attachments/{attachmentId}/transcribe [post]
+func (sh *SubmissionHandler) TranscribeAttachment(c *gin.Context) {
+	currentUser, _ := c.Get("user")
+	user := currentUser.(*models.User)
+
+	if !sh.transcriptionService.Enabled() {
+		c.JSON(http.StatusServiceUnavailable, models.ErrorResponse{
+			Error:   "transcription_unavailable",
+			Message: "Speech-to-text is not configured",
+		})
+		return
+	}
+
+	submission, attachment, ok := sh.findAttachment(c)
+	if !ok {
+		return
+	}
+	if !utils.HasPermissionIn(user, utils.PermSubmissionsUpdateAll, submission.OrgID) && submission.UserID != user.ID {
+		c.JSON(http.StatusForbidden, models.ErrorResponse{
+			Error:   "forbidden",
+			Message: "Access denied",
+		})
+		return
+	}
+	if attachment.Type != "audio" {
+		c.JSON(http.StatusBadRequest, models.ErrorResponse{
+			Error:   "invalid_request",
+			Message: "Only voice notes can be transcribed",
+		})
+		return
+	}
+	if attachment.TranscriptStatus == "pending" || attachment.TranscriptStatus == "complete" {
+		c.JSON(http.StatusConflict, models.ErrorResponse{
+			Error:   "already_transcribed",
+			Message: "The voice note is " + attachment.TranscriptStatus + " transcription",
+		})
+		return
+	}
+
+	if err := sh.setTranscript(submission.ID, attachment.ID, "pending", "", ""); err != nil {
+		c.JSON(http.StatusInternalServerError, models.ErrorResponse{
+			Error:   "internal_error",
+			Message: "Failed to start transcription",
+		})
+		return
+	}
+	attachment.TranscriptStatus = "pending"
+	go sh.transcribeAttachment(submission.ID, *attachment, user.ID)
+
+	c.JSON(http.StatusAccepted, models.SuccessResponse{
+		Success: true,
+		Data:    attachment,
+		Message: "Transcription started",
+	})
+}
+
+// transcribeAttachment transcribes a voice note and appends the transcript to
+// the submission's notes on behalf of actorID. Failures are logged and leave
+// the attachment's transcript failed, so it can be retried.
+func (sh *SubmissionHandler) transcribeAttachment(submissionID string, attachment models.Attachment, actorID string) {
+	transcript, err := sh.transcribe(attachment)
+	if err != nil {
+		log.Printf("Failed to transcribe attachment %s of submission %s: %v", attachment.ID, submissionID, err)
+		if err := sh.setTranscript(submissionID, attachment.ID, "failed", "", actorID); err != nil {
+			log.Printf("Failed to record the failed transcription of attachment %s: %v", attachment.ID, err)
+		}
+		return
+	}
+	if err := sh.setTranscript(submissionID, attachment.ID, "complete", transcript, actorID); err != nil {
+		log.Printf("Failed to store the transcript of attachment %s of submission %s: %v", attachment.ID, submissionID, err)
+	}
+}
+
+func (sh *SubmissionHandler) transcribe(attachment models.Attachment) (string, error) {
+	r, err := sh.storageService.OpenObject(attachment.Object)
+	if err != nil {
+		return "", err
+	}
+	defer r.Close()
+	return sh.transcriptionService.Transcribe(sh.firestoreService.Context(), attachment.Name, r)
+}
+
+// setTranscript stores the transcription status of an attachment. A complete
+// transcript is also appended to the notes, audited as an edit by actorID.
+// Attachments deleted in the meantime are left alone.
+func (sh *SubmissionHandler) setTranscript(submissionID, attachmentID, status, transcript, actorID string) error {
+	docRef := sh.firestoreService.Submissions().Doc(submissionID)
+	return sh.firestoreService.Client.RunTransaction(sh.firestoreService.Context(), func(ctx context.Context, tx *firestore.Transaction) error {
+		doc, err := tx.Get(docRef)
+		if err != nil {
+			return err
+		}
+		var current models.Submission
+		doc.DataTo(&current)
+
+		after := current
+		after.Attachments = append([]models.Attachment{}, current.Attachments...)
+		found := false
+		for i := range after.Attachments {
+			if after.Attachments[i].ID == attachmentID {
+				after.Attachments[i].TranscriptStatus = status
+				after.Attachments[i].Transcript = transcript
+				found = true
+			}
+		}
+		if !found {
+			return nil
+		}
+
+		updates := []firestore.Update{{Path: "attachments", Value: after.Attachments}}
+		if status == "complete" && transcript != "" {
+			after.Notes = strings.TrimSpace(strings.TrimSpace(current.Notes) + "\n\n" + transcript)
+			after.UpdatedAt = time.Now()
+			updates = append(updates,
+				firestore.Update{Path: "notes", Value: after.Notes},
+				firestore.Update{Path: "updated_at", Value: after.UpdatedAt},
+			)
+			audit := submissionAudit(actorID, "update", &current, &after)
+			if err := tx.Set(sh.firestoreService.SubmissionAudit().Doc(audit.ID), audit); err != nil {
+				return err
+			}
+		}
+		return tx.Update(docRef, updates)
+	})
+}
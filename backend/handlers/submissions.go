@@ -1,9 +1,15 @@
 package handlers
 
 import (
+	"encoding/csv"
+	"errors"
 	"fmt"
+	"log"
 	"net/http"
+	"os"
+	"sort"
 	"strconv"
+	"strings"
 	"time"
 
 	"rice-monitor-api/models"
@@ -13,28 +19,94 @@ import (
 	"cloud.google.com/go/firestore"
 	"github.com/gin-gonic/gin"
 	"google.golang.org/api/iterator"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
 )
 
+// reviewStatuses can only be set by users with the submissions:approve permission
+var reviewStatuses = map[string]bool{
+	"under_review": true,
+	"approved":     true,
+	"rejected":     true,
+}
+
 type SubmissionHandler struct {
-	firestoreService *services.FirestoreService
+	firestoreService     *services.FirestoreService
+	reputationService    *services.ReputationService
+	chatOpsService       *services.ChatOpsService
+	weatherService       *services.WeatherService
+	vocabularyService    *services.VocabularyService
+	storageService       *services.StorageService
+	traitService         *services.TraitService
+	transcriptionService *services.TranscriptionService
+	webhookService       *services.WebhookService
+	overdueService       *services.OverdueService
+	stagePhotoRequired   bool    // a claimed stage change since the previous visit needs a photo
+	batchLimit           int     // most submissions accepted by one batch upload
+	geofenceRadiusM      float64 // fields without a boundary extend this far from their coordinates, 0 disables the check
+	syncPolicy           string  // how offline edits to submissions changed since are handled: reject or merge
 }
 
-func NewSubmissionHandler(firestoreService *services.FirestoreService) *SubmissionHandler {
+func NewSubmissionHandler(firestoreService *services.FirestoreService, reputationService *services.ReputationService, chatOpsService *services.ChatOpsService, weatherService *services.WeatherService, vocabularyService *services.VocabularyService, storageService *services.StorageService, traitService *services.TraitService, transcriptionService *services.TranscriptionService, webhookService *services.WebhookService, overdueService *services.OverdueService) *SubmissionHandler {
+	batchLimit, err := strconv.Atoi(os.Getenv("SUBMISSION_BATCH_LIMIT"))
+	if err != nil || batchLimit <= 0 {
+		batchLimit = 100
+	}
+	geofenceRadiusM, err := strconv.ParseFloat(os.Getenv("SUBMISSION_GEOFENCE_RADIUS_M"), 64)
+	if err != nil || geofenceRadiusM <= 0 {
+		geofenceRadiusM = 500
+	}
+	if strings.ToLower(os.Getenv("SUBMISSION_GEOFENCE")) == "off" {
+		geofenceRadiusM = 0
+	}
+	syncPolicy := strings.ToLower(os.Getenv("SYNC_CONFLICT_POLICY"))
+	if syncPolicy != "reject" {
+		syncPolicy = "merge"
+	}
+
 	return &SubmissionHandler{
-		firestoreService: firestoreService,
+		firestoreService:     firestoreService,
+		reputationService:    reputationService,
+		chatOpsService:       chatOpsService,
+		weatherService:       weatherService,
+		vocabularyService:    vocabularyService,
+		storageService:       storageService,
+		traitService:         traitService,
+		transcriptionService: transcriptionService,
+		webhookService:       webhookService,
+		overdueService:       overdueService,
+		stagePhotoRequired:   strings.ToLower(os.Getenv("STAGE_CHANGE_PHOTO_REQUIRED")) != "off",
+		batchLimit:           batchLimit,
+		geofenceRadiusM:      geofenceRadiusM,
+		syncPolicy:           syncPolicy,
 	}
 }
 
 // @Summary Get all submissions
-// @Description Get a list of all submissions
+// @Description Get a list of all submissions. Users without submissions:read_all only see their own.
+// @Description Filters combine; time ranges can be set on created_at or on the observation date, not both.
 // @Tags submissions
 // @Produce  json
 // @Security ApiKeyAuth
 // @Param page query int false "Page number"
-// @Param limit query int false "Number of items per page"
+// @Param limit query int false "Number of items per page, at most 100"
 // @Param status query string false "Filter by submission status"
 // @Param field_id query string false "Filter by field ID"
+// @Param farm_id query string false "Filter by farm ID"
+// @Param season_id query string false "Filter by season ID"
+// @Param growth_stage query string false "Filter by growth stage"
+// @Param plant_condition query string false "Only submissions reporting this plant condition"
+// @Param tag query string false "Only submissions with this tag (not combined with plant_condition)"
+// @Param observer query string false "Filter by the user ID of the observer"
+// @Param created_from query string false "Created at or after (RFC 3339 or YYYY-MM-DD)"
+// @Param created_to query string false "Created before (RFC 3339 or YYYY-MM-DD)"
+// @Param date_from query string false "Observed on or after (RFC 3339 or YYYY-MM-DD)"
+// @Param date_to query string false "Observed before (RFC 3339 or YYYY-MM-DD)"
+// @Param sort query string false "created_at, date or status; defaults to the field of a time range, else created_at"
+// @Param order query string false "desc (default) or asc"
 // @Success 200 {object} models.SuccessResponse
+// @Failure 400 {object} models.ErrorResponse
+// @Failure 403 {object} models.ErrorResponse
 // @Failure 500 {object} models.ErrorResponse
 // @Router /submissions [get]
 func (sh *SubmissionHandler) GetSubmissions(c *gin.Context) {
@@ -44,24 +116,46 @@ func (sh *SubmissionHandler) GetSubmissions(c *gin.Context) {
 	fmt.Println(user)
 
 	// Parse query parameters
-	page, _ := strconv.Atoi(c.DefaultQuery("page", "1"))
-	limit, _ := strconv.Atoi(c.DefaultQuery("limit", "20"))
-	status := c.Query("status")
+	var params models.SubmissionListParams
+	if err := c.ShouldBindQuery(&params); err != nil {
+		c.JSON(http.StatusBadRequest, models.ErrorResponse{
+			Error:   "invalid_request",
+			Message: err.Error(),
+		})
+		return
+	}
+	page, limit := params.Page, params.Limit
 
 	ctx := sh.firestoreService.Context()
-	query := sh.firestoreService.Submissions().Query
-
-	fmt.Printf("Retrieving submissions (page %d, limit %d, status %s)\n", page, limit, status)
 
-	fmt.Println(query)
+	fmt.Printf("Retrieving submissions (page %d, limit %d, status %s)\n", page, limit, params.Status)
 
-	// // Filter by user (non-admin users can only see their submissions)
-	if user.Role != "admin" {
-		query = query.Where("user_id", "==", user.ID)
+	// Filter by user (users without read_all can only see their submissions)
+	query, err := sh.submissionListQuery(user, params)
+	if errors.Is(err, errObserverForbidden) {
+		c.JSON(http.StatusForbidden, models.ErrorResponse{
+			Error:   "forbidden",
+			Message: err.Error(),
+		})
+		return
+	}
+	if err != nil {
+		c.JSON(http.StatusBadRequest, models.ErrorResponse{
+			Error:   "invalid_request",
+			Message: err.Error(),
+		})
+		return
 	}
 
-	// // Order by creation date (newest first)
-	// query = query.OrderBy("created_at", firestore.Desc)
+	// The real number of matches, so clients can page through all of them
+	total, err := countDocuments(ctx, query)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, models.ErrorResponse{
+			Error:   "internal_error",
+			Message: "Failed to count submissions",
+		})
+		return
+	}
 
 	// Apply pagination
 	if page > 1 {
@@ -82,43 +176,30 @@ func (sh *SubmissionHandler) GetSubmissions(c *gin.Context) {
 
 	fmt.Printf("Retrieved %d submissions\n", len(docs))
 
-	var submissionsResponse []models.SubmissionResponse
-	for _, doc := range docs {
-		var submission models.Submission
-		doc.DataTo(&submission)
-
-		fieldDoc, err := sh.firestoreService.Fields().Doc(submission.FieldID).Get(ctx)
-
-		fmt.Println(fieldDoc)
-
-		var field *models.Field
-		if err == nil {
-			field = &models.Field{}
-			fieldDoc.DataTo(field)
-		}
+	submissions := make([]models.Submission, len(docs))
+	for i, doc := range docs {
+		doc.DataTo(&submissions[i])
+	}
+	fields, err := sh.submissionFields(submissions)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, models.ErrorResponse{
+			Error:   "internal_error",
+			Message: "Failed to retrieve submissions",
+		})
+		return
+	}
 
-		if err != nil {
-			fmt.Printf("Failed to get field for submission %s: %v\n", submission.ID, err)
-			// Optionally, you can skip this submission or return an error
-			continue
+	var submissionsResponse []models.SubmissionResponse
+	for _, submission := range submissions {
+		field := &models.Field{} // community observations may have no field
+		if submission.FieldID != "" {
+			if field = fields[submission.FieldID]; field == nil {
+				fmt.Printf("Field %s of submission %s not found\n", submission.FieldID, submission.ID)
+				continue
+			}
 		}
 
-		submissionsResponse = append(submissionsResponse, models.SubmissionResponse{
-			ID:                submission.ID,
-			UserID:            submission.UserID,
-			FieldID:           submission.FieldID,
-			Field:             *field, // Dereference the field pointer
-			Date:              submission.Date,
-			GrowthStage:       submission.GrowthStage,
-			PlantConditions:   submission.PlantConditions,
-			TraitMeasurements: submission.TraitMeasurements,
-			Notes:             submission.Notes,
-			ObserverName:      submission.ObserverName,
-			Images:            submission.Images,
-			Status:            submission.Status,
-			CreatedAt:         submission.CreatedAt,
-			UpdatedAt:         submission.UpdatedAt,
-		})
+		submissionsResponse = append(submissionsResponse, submissionResponse(user, submission, field))
 	}
 
 	c.JSON(http.StatusOK, models.SuccessResponse{
@@ -127,20 +208,27 @@ func (sh *SubmissionHandler) GetSubmissions(c *gin.Context) {
 			"submissions": submissionsResponse,
 			"page":        page,
 			"limit":       limit,
-			"total":       len(submissionsResponse),
+			"total":       total,
+			"total_pages": (total + int64(limit) - 1) / int64(limit),
 		},
 	})
 }
 
 // @Summary Create a new submission
-// @Description Create a new submission
+// @Description Create a new submission. Observers can only submit for fields they own or are assigned to.
+// @Description With an Idempotency-Key header or client_id, a retried request returns the submission the first
+// @Description one created, with status 200 and an Idempotent-Replayed header, instead of creating another.
 // @Tags submissions
 // @Accept  json
 // @Produce  json
 // @Security ApiKeyAuth
+// @Param Idempotency-Key header string false "Key generated by the client for this submission"
 // @Param submission body models.CreateSubmissionRequest true "Submission object that needs to be added"
+// @Success 200 {object} models.SuccessResponse
 // @Success 201 {object} models.SuccessResponse
 // @Failure 400 {object} models.ErrorResponse
+// @Failure 403 {object} models.ErrorResponse
+// @Failure 422 {object} models.ErrorResponse
 // @Failure 500 {object} models.ErrorResponse
 // @Router /submissions [post]
 func (sh *SubmissionHandler) CreateSubmission(c *gin.Context) {
@@ -156,24 +244,275 @@ func (sh *SubmissionHandler) CreateSubmission(c *gin.Context) {
 	currentUser, _ := c.Get("user")
 	user := currentUser.(*models.User)
 
+	key, problem := idempotencyKey(c, req)
+	if problem != nil {
+		c.JSON(http.StatusBadRequest, problem)
+		return
+	}
+	hash := submissionRequestHash(req)
+	if key != "" && sh.replaySubmission(c, user, key, hash) {
+		return
+	}
+
+	submission, code, problem := sh.buildSubmission(c, user, req, utils.GenerateID(), nil)
+	if problem != nil {
+		c.JSON(code, problem)
+		return
+	}
+
+	ctx := sh.firestoreService.Context()
+	var err error
+	if key == "" {
+		_, err = sh.firestoreService.Submissions().Doc(submission.ID).Set(ctx, submission)
+	} else {
+		err = sh.createIdempotent(ctx, user, key, hash, submission)
+		// A concurrent retry with the same key got there first
+		if status.Code(err) == codes.AlreadyExists && sh.replaySubmission(c, user, key, hash) {
+			return
+		}
+	}
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, models.ErrorResponse{
+			Error:   "internal_error",
+			Message: "Failed to create submission",
+		})
+		return
+	}
+	recordSubmissionAudit(sh.firestoreService, user.ID, "create", nil, submission)
+	sh.registerTags(submission.OrgID, submission.Tags)
+
+	go sh.chatOpsService.SubmissionCreated(submission)
+	go sh.webhookService.Dispatch(services.WebhookEventSubmissionCreated, submission)
+	go sh.recordWeather(submission)
+
+	c.JSON(http.StatusCreated, models.SuccessResponse{
+		Success: true,
+		Data:    submission,
+		Message: "Submission created successfully",
+	})
+}
+
+// buildSubmission validates req and turns it into a new submission with id.
+// earlier lists submissions of the same upload that are not stored yet, so a
+// batch can confirm its own stage changes. On failure it returns the status
+// code and error to respond with.
+func (sh *SubmissionHandler) buildSubmission(c *gin.Context, user *models.User, req models.CreateSubmissionRequest, id string, earlier []*models.Submission) (*models.Submission, int, *models.ErrorResponse) {
+	var err error
+	if req.GrowthStage, req.PlantConditions, err = sh.canonicalTerms(req.GrowthStage, req.PlantConditions); err != nil {
+		return nil, http.StatusBadRequest, &models.ErrorResponse{
+			Error:   "invalid_vocabulary",
+			Message: err.Error(),
+		}
+	}
+	if len(req.Replicates) > 0 {
+		// Means of integer custom traits need not be whole, the replicates are validated instead
+		if code, problem := sh.replicatesProblem(req.Replicates); problem != nil {
+			return nil, code, problem
+		}
+	} else if code, problem := sh.customTraitsProblem(req.TraitMeasurements.Custom); problem != nil {
+		return nil, code, problem
+	}
+	var summary map[string]models.TraitSummary
+	if len(req.Replicates) > 0 {
+		req.TraitMeasurements, summary = summarizeReplicates(req.Replicates)
+	}
+	if err := validateEvidence(req.Evidence, req.Images, req.PlantConditions, req.TraitMeasurements.Custom); err != nil {
+		return nil, http.StatusBadRequest, &models.ErrorResponse{
+			Error:   "invalid_evidence",
+			Message: err.Error(),
+		}
+	}
+	if req.Tags, err = cleanTags(req.Tags); err != nil {
+		return nil, http.StatusBadRequest, &models.ErrorResponse{
+			Error:   "invalid_tags",
+			Message: err.Error(),
+		}
+	}
+
+	archived, err := fieldArchived(sh.firestoreService, req.FieldID)
+	if err != nil {
+		return nil, http.StatusInternalServerError, &models.ErrorResponse{
+			Error:   "internal_error",
+			Message: "Failed to look up the field",
+		}
+	}
+	if archived {
+		return nil, http.StatusConflict, &fieldArchivedResponse
+	}
+
+	// Observers only submit for the fields they own, are assigned to or contribute to
+	if user.Role == "observer" {
+		field, err := sh.getSubmissionField(models.Submission{FieldID: req.FieldID})
+		if err != nil {
+			return nil, http.StatusBadRequest, &models.ErrorResponse{
+				Error:   "invalid_request",
+				Message: "Field not found",
+			}
+		}
+		if !canSubmitToField(user, field) {
+			return nil, http.StatusForbidden, &models.ErrorResponse{
+				Error:   "field_not_assigned",
+				Message: "You are not assigned to this field",
+			}
+		}
+	}
+
+	if code, problem := sh.stagePhotoProblem(req.FieldID, "", req.Date, req.GrowthStage, len(req.Images)+req.PendingImages, earlier); problem != nil {
+		return nil, code, problem
+	}
+	if req.Weather != nil {
+		req.Weather.Source = "device"
+	}
+	mediaStatus := ""
+	if req.PendingImages > 0 {
+		mediaStatus = "pending"
+	}
+
 	submission := &models.Submission{
-		ID:                utils.GenerateID(),
+		ID:                id,
 		UserID:            user.ID,
 		FieldID:           req.FieldID,
+		OrgID:             user.OrgID,
 		Date:              req.Date,
 		GrowthStage:       req.GrowthStage,
 		PlantConditions:   req.PlantConditions,
 		TraitMeasurements: req.TraitMeasurements,
+		HillID:            strings.TrimSpace(req.HillID),
+		Replicates:        req.Replicates,
+		ReplicateSummary:  summary,
 		Notes:             req.Notes,
 		ObserverName:      req.ObserverName,
 		Images:            req.Images, // Will be populated when images are uploaded
+		PendingImages:     req.PendingImages,
+		MediaStatus:       mediaStatus,
+		Evidence:          req.Evidence,
+		Weather:           req.Weather,
+		Device:            submissionDevice(c, req.Device),
+		Location:          req.Location,
+		Tags:              req.Tags,
 		Status:            "submitted",
+		Source:            "app",
+		Provenance:        "research",
 		CreatedAt:         time.Now(),
 		UpdatedAt:         time.Now(),
 	}
 
+	// Observations recorded away from the field go to the review queue
+	if outside := sh.outOfBoundsM(req.FieldID, req.Location); outside > 0 {
+		submission.OutOfBoundsM = outside
+		submission.Status = "under_review"
+	}
+
+	if err := applyCalibrations(sh.firestoreService, submission); err != nil {
+		return nil, http.StatusInternalServerError, &models.ErrorResponse{
+			Error:   "internal_error",
+			Message: "Failed to apply calibrations",
+		}
+	}
+	if err := applyFieldFarm(sh.firestoreService, submission); err != nil {
+		return nil, http.StatusInternalServerError, &models.ErrorResponse{
+			Error:   "internal_error",
+			Message: "Failed to look up the field's farm",
+		}
+	}
+	if err := applySeason(sh.firestoreService, submission); err != nil {
+		return nil, http.StatusInternalServerError, &models.ErrorResponse{
+			Error:   "internal_error",
+			Message: "Failed to look up the field's season",
+		}
+	}
+
+	return submission, 0, nil
+}
+
+// @Summary Create a community submission
+// @Description Create a simplified community-science observation: one photo, a growth stage,
+// @Description one condition and the device GPS position. These are flagged with community
+// @Description provenance and require verification before use in research analytics.
+// @Tags submissions
+// @Accept  json
+// @Produce  json
+// @Security ApiKeyAuth
+// @Param submission body models.CommunitySubmissionRequest true "Community observation"
+// @Success 201 {object} models.SuccessResponse
+// @Failure 400 {object} models.ErrorResponse
+// @Failure 500 {object} models.ErrorResponse
+// @Router /submissions/community [post]
+func (sh *SubmissionHandler) CreateCommunitySubmission(c *gin.Context) {
+	var req models.CommunitySubmissionRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, models.ErrorResponse{
+			Error:   "invalid_request",
+			Message: err.Error(),
+		})
+		return
+	}
+
+	if err := sh.validateCommunitySubmission(&req); err != nil {
+		c.JSON(http.StatusBadRequest, models.ErrorResponse{
+			Error:   "validation_failed",
+			Message: err.Error(),
+		})
+		return
+	}
+
+	archived, err := fieldArchived(sh.firestoreService, req.FieldID)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, models.ErrorResponse{
+			Error:   "internal_error",
+			Message: "Failed to look up the field",
+		})
+		return
+	}
+	if archived {
+		c.JSON(http.StatusConflict, fieldArchivedResponse)
+		return
+	}
+
+	currentUser, _ := c.Get("user")
+	user := currentUser.(*models.User)
+
+	date := time.Now()
+	if req.Date != nil {
+		date = *req.Date
+	}
+
+	submission := &models.Submission{
+		ID:                   utils.GenerateID(),
+		UserID:               user.ID,
+		FieldID:              req.FieldID,
+		OrgID:                user.OrgID,
+		Date:                 date,
+		GrowthStage:          req.GrowthStage,
+		PlantConditions:      []string{req.Condition},
+		ObserverName:         user.Name,
+		Images:               []string{req.Image},
+		Status:               "submitted",
+		Source:               "app",
+		Provenance:           "community",
+		Coordinates:          req.Coordinates,
+		Device:               submissionDevice(c, req.Device),
+		VerificationRequired: true,
+		CreatedAt:            time.Now(),
+		UpdatedAt:            time.Now(),
+	}
+	if err := applyFieldFarm(sh.firestoreService, submission); err != nil {
+		c.JSON(http.StatusInternalServerError, models.ErrorResponse{
+			Error:   "internal_error",
+			Message: "Failed to look up the field's farm",
+		})
+		return
+	}
+	if err := applySeason(sh.firestoreService, submission); err != nil {
+		c.JSON(http.StatusInternalServerError, models.ErrorResponse{
+			Error:   "internal_error",
+			Message: "Failed to look up the field's season",
+		})
+		return
+	}
+
 	ctx := sh.firestoreService.Context()
-	_, err := sh.firestoreService.Submissions().Doc(submission.ID).Set(ctx, submission)
+	_, err = sh.firestoreService.Submissions().Doc(submission.ID).Set(ctx, submission)
 	if err != nil {
 		c.JSON(http.StatusInternalServerError, models.ErrorResponse{
 			Error:   "internal_error",
@@ -181,11 +520,16 @@ func (sh *SubmissionHandler) CreateSubmission(c *gin.Context) {
 		})
 		return
 	}
+	recordSubmissionAudit(sh.firestoreService, user.ID, "create", nil, submission)
+
+	go sh.chatOpsService.SubmissionCreated(submission)
+	go sh.webhookService.Dispatch(services.WebhookEventSubmissionCreated, submission)
+	go sh.recordWeather(submission)
 
 	c.JSON(http.StatusCreated, models.SuccessResponse{
 		Success: true,
 		Data:    submission,
-		Message: "Submission created successfully",
+		Message: "Observation submitted successfully",
 	})
 }
 
@@ -217,8 +561,12 @@ func (sh *SubmissionHandler) GetSubmission(c *gin.Context) {
 	var submission models.Submission
 	doc.DataTo(&submission)
 
-	// Check if user can access this submission
-	if user.Role != "admin" && submission.UserID != user.ID {
+	field, err := sh.getSubmissionField(submission)
+
+	// Check if user can access this submission. Whoever can see its field,
+	// such as the field's owner and collaborators, can see it too.
+	if !utils.HasPermissionIn(user, utils.PermSubmissionsReadAll, submission.OrgID) && submission.UserID != user.ID &&
+		(submission.FieldID == "" || err != nil || !canReadField(user, field)) {
 		c.JSON(http.StatusForbidden, models.ErrorResponse{
 			Error:   "forbidden",
 			Message: "Access denied",
@@ -226,14 +574,6 @@ func (sh *SubmissionHandler) GetSubmission(c *gin.Context) {
 		return
 	}
 
-	field_doc, err := sh.firestoreService.Fields().Doc(submission.FieldID).Get(ctx)
-
-	var field *models.Field
-	if err == nil {
-		field = &models.Field{}
-		field_doc.DataTo(field)
-	}
-
 	if err != nil {
 		fmt.Printf("Failed to get field for submission %s: %v\n", submission.ID, err)
 		c.JSON(http.StatusInternalServerError, models.ErrorResponse{
@@ -243,41 +583,27 @@ func (sh *SubmissionHandler) GetSubmission(c *gin.Context) {
 		return
 	}
 
-	submissionResponse := models.SubmissionResponse{
-		ID:                submission.ID,
-		UserID:            submission.UserID,
-		FieldID:           submission.FieldID,
-		Field:             *field,
-		Date:              submission.Date,
-		GrowthStage:       submission.GrowthStage,
-		PlantConditions:   submission.PlantConditions,
-		TraitMeasurements: submission.TraitMeasurements,
-		Notes:             submission.Notes,
-		ObserverName:      submission.ObserverName,
-		Images:            submission.Images,
-		Status:            submission.Status,
-		CreatedAt:         submission.CreatedAt,
-		UpdatedAt:         submission.UpdatedAt,
-	}
-
 	c.JSON(http.StatusOK, models.SuccessResponse{
 		Success: true,
-		Data:    submissionResponse,
+		Data:    submissionResponse(user, submission, field),
 	})
 }
 
 // @Summary Update a submission
-// @Description Update an existing submission
+// @Description Update an existing submission. Only the fields of the request can be changed and only the fields
+// @Description sent are updated; others are ignored. Lists replace the current values.
 // @Tags submissions
 // @Accept  json
 // @Produce  json
 // @Security ApiKeyAuth
 // @Param id path string true "Submission ID"
-// @Param submission body object true "Submission object that needs to be updated"
+// @Param submission body models.UpdateSubmissionRequest true "Changes, only the fields sent are updated"
 // @Success 200 {object} models.SuccessResponse
 // @Failure 400 {object} models.ErrorResponse
 // @Failure 403 {object} models.ErrorResponse
 // @Failure 404 {object} models.ErrorResponse
+// @Failure 409 {object} models.ErrorResponse
+// @Failure 422 {object} models.ErrorResponse
 // @Failure 500 {object} models.ErrorResponse
 // @Router /submissions/{id} [put]
 func (sh *SubmissionHandler) UpdateSubmission(c *gin.Context) {
@@ -285,8 +611,8 @@ func (sh *SubmissionHandler) UpdateSubmission(c *gin.Context) {
 	currentUser, _ := c.Get("user")
 	user := currentUser.(*models.User)
 
-	var updateData map[string]interface{}
-	if err := c.ShouldBindJSON(&updateData); err != nil {
+	var req models.UpdateSubmissionRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
 		c.JSON(http.StatusBadRequest, models.ErrorResponse{
 			Error:   "invalid_request",
 			Message: err.Error(),
@@ -309,55 +635,488 @@ func (sh *SubmissionHandler) UpdateSubmission(c *gin.Context) {
 	var submission models.Submission
 	doc.DataTo(&submission)
 
+	updated, code, problem := sh.updateSubmission(user, submission, doc.UpdateTime, req)
+	if problem != nil {
+		c.JSON(code, problem)
+		return
+	}
+
+	c.JSON(http.StatusOK, models.SuccessResponse{
+		Success: true,
+		Data:    updated,
+		Message: "Submission updated successfully",
+	})
+}
+
+// updateSubmission applies req to submission, as read at readAt, on behalf of
+// user and returns the submission as stored. The update fails with 409
+// edit_conflict when the submission was changed after it was read. On failure
+// it returns the status code and error to respond with.
+func (sh *SubmissionHandler) updateSubmission(user *models.User, submission models.Submission, readAt time.Time, req models.UpdateSubmissionRequest) (*models.Submission, int, *models.ErrorResponse) {
+	ctx := sh.firestoreService.Context()
+
 	// Check permissions
-	if user.Role != "admin" && submission.UserID != user.ID {
-		c.JSON(http.StatusForbidden, models.ErrorResponse{
+	if !utils.HasPermissionIn(user, utils.PermSubmissionsUpdateAll, submission.OrgID) && submission.UserID != user.ID {
+		return nil, http.StatusForbidden, &models.ErrorResponse{
 			Error:   "forbidden",
 			Message: "Access denied",
-		})
-		return
+		}
+	}
+
+	// Only the paths of UpdateSubmissionRequest are written. Evidence,
+	// attachments and images' upload state have their own endpoints, and
+	// weather, device and position are captured when the observation is made.
+	var updates []firestore.Update
+	set := func(path string, value interface{}) {
+		updates = append(updates, firestore.Update{Path: path, Value: value})
+	}
+
+	// The stage photo is checked against the submission as it will be after the update
+	stage, images, date := submission.GrowthStage, submission.Images, submission.Date
+
+	if req.GrowthStage != nil || req.PlantConditions != nil {
+		requested := ""
+		if req.GrowthStage != nil {
+			requested = *req.GrowthStage
+		}
+		canonical, conditions, err := sh.canonicalTerms(requested, req.PlantConditions)
+		if err != nil {
+			return nil, http.StatusBadRequest, &models.ErrorResponse{
+				Error:   "invalid_vocabulary",
+				Message: err.Error(),
+			}
+		}
+		if req.GrowthStage != nil {
+			stage = canonical
+			set("growth_stage", stage)
+		}
+		if req.PlantConditions != nil {
+			set("plant_conditions", conditions)
+		}
+	}
+	if req.Tags != nil {
+		tags, err := cleanTags(req.Tags)
+		if err != nil {
+			return nil, http.StatusBadRequest, &models.ErrorResponse{
+				Error:   "invalid_tags",
+				Message: err.Error(),
+			}
+		}
+		req.Tags = tags
+		if len(tags) == 0 {
+			set("tags", firestore.Delete)
+		} else {
+			set("tags", tags)
+		}
+	}
+	if req.Date != nil {
+		date = *req.Date
+		set("date", date)
+	}
+	if req.HillID != nil {
+		set("hill_id", strings.TrimSpace(*req.HillID))
+	}
+	if req.Notes != nil {
+		set("notes", *req.Notes)
+	}
+	if req.ObserverName != nil {
+		set("observer_name", *req.ObserverName)
+	}
+	if req.Images != nil {
+		images = req.Images
+		set("images", images)
+	}
+
+	// New replicates replace the trait measurements with their means
+	replicated := len(submission.Replicates) > 0
+	if req.Replicates != nil {
+		if code, problem := sh.replicatesProblem(req.Replicates); problem != nil {
+			return nil, code, problem
+		}
+		replicated = len(req.Replicates) > 0
+		if replicated {
+			traits, summary := summarizeReplicates(req.Replicates)
+			req.TraitMeasurements = &traits
+			set("replicates", req.Replicates)
+			set("replicate_summary", summary)
+		} else {
+			set("replicates", firestore.Delete)
+			set("replicate_summary", firestore.Delete)
+		}
+	} else if req.TraitMeasurements != nil && replicated {
+		return nil, http.StatusBadRequest, &models.ErrorResponse{
+			Error:   "replicates_recorded",
+			Message: "The trait measurements are the means of the replicates, update replicates instead",
+		}
+	}
+
+	// New trait measurements are raw values and are calibrated like new submissions
+	var calibrated []firestore.Update
+	if req.TraitMeasurements != nil {
+		recalibrated := submission
+		recalibrated.TraitMeasurements = *req.TraitMeasurements
+		// Means of integer custom traits need not be whole, the replicates were validated instead
+		if !replicated {
+			if code, problem := sh.customTraitsProblem(recalibrated.TraitMeasurements.Custom); problem != nil {
+				return nil, code, problem
+			}
+		}
+		if err := applyCalibrations(sh.firestoreService, &recalibrated); err != nil {
+			return nil, http.StatusInternalServerError, &models.ErrorResponse{
+				Error:   "internal_error",
+				Message: "Failed to apply calibrations",
+			}
+		}
+
+		set("trait_measurements", recalibrated.TraitMeasurements)
+		if recalibrated.RawMeasurements != nil {
+			calibrated = []firestore.Update{
+				{Path: "raw_measurements", Value: recalibrated.RawMeasurements},
+				{Path: "calibrations", Value: recalibrated.Calibrations},
+			}
+		} else {
+			calibrated = []firestore.Update{
+				{Path: "raw_measurements", Value: firestore.Delete},
+				{Path: "calibrations", Value: firestore.Delete},
+			}
+		}
+	}
+
+	changed := make([]string, len(updates))
+	for i, update := range updates {
+		changed[i] = update.Path
+	}
+
+	// Only reviewers can move a submission into a review status
+	newStatus := submission.Status
+	if req.Status != nil && *req.Status != submission.Status {
+		if reviewStatuses[*req.Status] && !utils.HasPermissionIn(user, utils.PermSubmissionsApprove, submission.OrgID) {
+			return nil, http.StatusForbidden, &models.ErrorResponse{
+				Error:   "forbidden",
+				Message: fmt.Sprintf("Only reviewers can move a submission to %s", *req.Status),
+			}
+		}
+		if problem := statusUpdateProblem(&submission, *req.Status, len(changed) > 0); problem != nil {
+			return nil, http.StatusUnprocessableEntity, problem
+		}
+		newStatus = *req.Status
+		set("status", newStatus)
+	}
+
+	if newStatus != "draft" {
+		if code, problem := sh.stagePhotoProblem(submission.FieldID, submission.ID, date, stage, len(images)+submission.PendingImages, nil); problem != nil {
+			return nil, code, problem
+		}
 	}
 
-	// Remove sensitive fields
-	delete(updateData, "id")
-	delete(updateData, "user_id")
-	delete(updateData, "created_at")
-	updateData["updated_at"] = time.Now()
+	// A new date can move the submission to another season of its field
+	if req.Date != nil {
+		dated := submission
+		dated.Date = date
+		if err := applySeason(sh.firestoreService, &dated); err != nil {
+			return nil, http.StatusInternalServerError, &models.ErrorResponse{
+				Error:   "internal_error",
+				Message: "Failed to look up the field's season",
+			}
+		}
+		if dated.SeasonID != "" {
+			set("season_id", dated.SeasonID)
+		} else {
+			set("season_id", firestore.Delete)
+		}
+	}
 
 	// Update document
-	updates := []firestore.Update{{Path: "updated_at", Value: time.Now()}}
-	for key, value := range updateData {
-		updates = append(updates, firestore.Update{Path: key, Value: value})
+	set("updated_at", time.Now())
+	updates = append(updates, calibrated...)
+
+	batch := sh.firestoreService.Client.Batch()
+	batch.Update(sh.firestoreService.Submissions().Doc(submission.ID), updates, firestore.LastUpdateTime(readAt))
+
+	// Edits to approved data are logged for the correction rate analytics
+	if submission.Status == "approved" && len(changed) > 0 {
+		sort.Strings(changed)
+		correction := models.SubmissionCorrection{
+			ID:           utils.GenerateID(),
+			SubmissionID: submission.ID,
+			OrgID:        submission.OrgID,
+			ObserverID:   submission.UserID,
+			EditorID:     user.ID,
+			Fields:       changed,
+			CreatedAt:    time.Now(),
+		}
+		batch.Set(sh.firestoreService.SubmissionCorrections().Doc(correction.ID), correction)
 	}
 
-	_, err = sh.firestoreService.Submissions().Doc(submissionID).Update(ctx, updates)
+	_, err := batch.Commit(ctx)
+	if status.Code(err) == codes.FailedPrecondition {
+		return nil, http.StatusConflict, &models.ErrorResponse{
+			Error:   "edit_conflict",
+			Message: "The submission was changed meanwhile, reload it and try again",
+		}
+	}
 	if err != nil {
-		c.JSON(http.StatusInternalServerError, models.ErrorResponse{
+		return nil, http.StatusInternalServerError, &models.ErrorResponse{
 			Error:   "internal_error",
 			Message: "Failed to update submission",
-		})
-		return
+		}
 	}
 
 	// Get updated submission
-	doc, err = sh.firestoreService.Submissions().Doc(submissionID).Get(ctx)
+	doc, err := sh.firestoreService.Submissions().Doc(submission.ID).Get(ctx)
 	if err != nil {
-		c.JSON(http.StatusInternalServerError, models.ErrorResponse{
+		return nil, http.StatusInternalServerError, &models.ErrorResponse{
 			Error:   "internal_error",
 			Message: "Failed to retrieve updated submission",
-		})
-		return
+		}
 	}
 
-	doc.DataTo(&submission)
+	var updated models.Submission
+	doc.DataTo(&updated)
+	recordSubmissionAudit(sh.firestoreService, user.ID, "update", &submission, &updated)
+	go sh.webhookService.Dispatch(submissionEvent(&submission, &updated), &updated)
+	if req.Tags != nil {
+		sh.registerTags(updated.OrgID, updated.Tags)
+	}
 
-	c.JSON(http.StatusOK, models.SuccessResponse{
+	return &updated, 0, nil
+}
+
+// @Summary Link photos to traits and conditions
+// @Description Replace the evidence links of a submission. Each link ties one of the submission's
+// @Description images to a trait measurement (culm_length, panicle_length, panicles_per_hill,
+// @Description hills_observed) or to one of its plant conditions.
+// @Tags submissions
+// @Accept  json
+// @Produce  json
+// @Security ApiKeyAuth
+// @Param id path string true "Submission ID"
+// @Param evidence body models.UpdateEvidenceRequest true "Evidence links"
+// @Success 200 {object} models.SuccessResponse
+// @Failure 400 {object} models.ErrorResponse
+// @Failure 403 {object} models.ErrorResponse
+// @Failure 404 {object} models.ErrorResponse
+// @Failure 500 {object} models.ErrorResponse
+// @Router /submissions/{id}/evidence [put]
+func (sh *SubmissionHandler) UpdateEvidence(c *gin.Context) {
+	submissionID := c.Param("id")
+	currentUser, _ := c.Get("user")
+	user := currentUser.(*models.User)
+
+	var req models.UpdateEvidenceRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, models.ErrorResponse{
+			Error:   "invalid_request",
+			Message: err.Error(),
+		})
+		return
+	}
+
+	ctx := sh.firestoreService.Context()
+	doc, err := sh.firestoreService.Submissions().Doc(submissionID).Get(ctx)
+	if err != nil {
+		c.JSON(http.StatusNotFound, models.ErrorResponse{
+			Error:   "not_found",
+			Message: "Submission not found",
+		})
+		return
+	}
+
+	var submission models.Submission
+	doc.DataTo(&submission)
+
+	if !utils.HasPermissionIn(user, utils.PermSubmissionsUpdateAll, submission.OrgID) && submission.UserID != user.ID {
+		c.JSON(http.StatusForbidden, models.ErrorResponse{
+			Error:   "forbidden",
+			Message: "Access denied",
+		})
+		return
+	}
+
+	if err := validateEvidence(req.Evidence, submission.Images, submission.PlantConditions, submission.TraitMeasurements.Custom); err != nil {
+		c.JSON(http.StatusBadRequest, models.ErrorResponse{
+			Error:   "invalid_evidence",
+			Message: err.Error(),
+		})
+		return
+	}
+	if req.Evidence == nil {
+		req.Evidence = []models.EvidenceLink{}
+	}
+
+	now := time.Now()
+	_, err = sh.firestoreService.Submissions().Doc(submissionID).Update(ctx, []firestore.Update{
+		{Path: "evidence", Value: req.Evidence},
+		{Path: "updated_at", Value: now},
+	})
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, models.ErrorResponse{
+			Error:   "internal_error",
+			Message: "Failed to update evidence",
+		})
+		return
+	}
+
+	before := submission
+	submission.Evidence, submission.UpdatedAt = req.Evidence, now
+	recordSubmissionAudit(sh.firestoreService, user.ID, "update", &before, &submission)
+
+	c.JSON(http.StatusOK, models.SuccessResponse{
 		Success: true,
 		Data:    submission,
-		Message: "Submission updated successfully",
+		Message: "Evidence updated successfully",
+	})
+}
+
+// @Summary Review a submission
+// @Description Set the review status of a submission (under_review, approved or rejected).
+// @Description Every decision is kept in the review log with the reviewer and reason.
+// @Tags submissions
+// @Accept  json
+// @Produce  json
+// @Security ApiKeyAuth
+// @Param id path string true "Submission ID"
+// @Param review body models.ReviewSubmissionRequest true "Review status"
+// @Success 200 {object} models.SuccessResponse
+// @Failure 400 {object} models.ErrorResponse
+// @Failure 403 {object} models.ErrorResponse
+// @Failure 404 {object} models.ErrorResponse
+// @Failure 409 {object} models.ErrorResponse
+// @Failure 422 {object} models.ErrorResponse
+// @Failure 500 {object} models.ErrorResponse
+// @Router /submissions/{id}/status [put]
+func (sh *SubmissionHandler) ReviewSubmission(c *gin.Context) {
+	submissionID := c.Param("id")
+
+	var req models.ReviewSubmissionRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, models.ErrorResponse{
+			Error:   "invalid_request",
+			Message: err.Error(),
+		})
+		return
+	}
+
+	sh.reviewSubmission(c, submissionID, req.Status, req.Reason)
+}
+
+// reviewSubmission sets the submission's review status, records the decision
+// in the review log and on the submission, and responds
+func (sh *SubmissionHandler) reviewSubmission(c *gin.Context, submissionID, status, reason string) {
+	ctx := sh.firestoreService.Context()
+	docRef := sh.firestoreService.Submissions().Doc(submissionID)
+
+	doc, err := docRef.Get(ctx)
+	if err != nil {
+		c.JSON(http.StatusNotFound, models.ErrorResponse{
+			Error:   "not_found",
+			Message: "Submission not found",
+		})
+		return
+	}
+
+	var submission models.Submission
+	doc.DataTo(&submission)
+
+	currentUser, _ := c.Get("user")
+	reviewer := currentUser.(*models.User)
+
+	if !utils.HasPermissionIn(reviewer, utils.PermSubmissionsApprove, submission.OrgID) {
+		c.JSON(http.StatusForbidden, models.ErrorResponse{
+			Error:   "forbidden",
+			Message: "Access denied",
+		})
+		return
+	}
+	if submission.Status == "draft" {
+		c.JSON(http.StatusConflict, models.ErrorResponse{
+			Error:   "not_submitted",
+			Message: "Drafts cannot be reviewed until they are submitted",
+		})
+		return
+	}
+	if problem := transitionError(submission.Status, status); problem != nil {
+		c.JSON(http.StatusUnprocessableEntity, problem)
+		return
+	}
+
+	batch := sh.firestoreService.Client.Batch()
+	decision := sh.batchReview(batch, &submission, reviewer, status, reason)
+	_, err = batch.Commit(ctx)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, models.ErrorResponse{
+			Error:   "internal_error",
+			Message: "Failed to update submission status",
+		})
+		return
+	}
+
+	reviewed := reviewedSubmission(&submission, decision)
+	go sh.webhookService.Dispatch(submissionEvent(&submission, &reviewed), &reviewed)
+
+	// Review outcomes feed the observer's reputation
+	if submission.Provenance == "community" {
+		if _, err := sh.reputationService.Recompute(submission.UserID); err != nil {
+			log.Printf("Failed to update reputation for user %s: %v", submission.UserID, err)
+		}
+	}
+
+	c.JSON(http.StatusOK, models.SuccessResponse{
+		Success: true,
+		Data:    decision,
+		Message: "Submission status updated successfully",
 	})
 }
 
+// batchReview adds reviewer's decision on a submission to batch: the status
+// update, the review log entry and the audit entry. It takes three writes.
+func (sh *SubmissionHandler) batchReview(batch *firestore.WriteBatch, submission *models.Submission, reviewer *models.User, status, reason string) models.ReviewDecision {
+	decision := models.ReviewDecision{
+		ID:             utils.GenerateID(),
+		SubmissionID:   submission.ID,
+		OrgID:          submission.OrgID,
+		ReviewerID:     reviewer.ID,
+		ReviewerName:   reviewer.Name,
+		ObserverID:     submission.UserID,
+		PreviousStatus: submission.Status,
+		Status:         status,
+		Reason:         reason,
+		// Overturning an earlier approval or rejection is flagged for audits
+		Reversal:  (submission.Status == "approved" || submission.Status == "rejected") && status != submission.Status,
+		CreatedAt: time.Now(),
+	}
+
+	batch.Update(sh.firestoreService.Submissions().Doc(submission.ID), []firestore.Update{
+		{Path: "status", Value: status},
+		{Path: "reviewed_by", Value: reviewer.ID},
+		{Path: "reviewed_at", Value: decision.CreatedAt},
+		{Path: "review_comment", Value: reason},
+		{Path: "updated_at", Value: decision.CreatedAt},
+	})
+	batch.Set(sh.firestoreService.ReviewDecisions().Doc(decision.ID), decision)
+	reviewed := reviewedSubmission(submission, decision)
+	audit := submissionAudit(reviewer.ID, "status_change", submission, &reviewed)
+	batch.Set(sh.firestoreService.SubmissionAudit().Doc(audit.ID), audit)
+	return decision
+}
+
+// reviewedSubmission returns the submission as the review decision leaves it
+func reviewedSubmission(submission *models.Submission, decision models.ReviewDecision) models.Submission {
+	reviewed := *submission
+	reviewed.Status, reviewed.ReviewedBy, reviewed.ReviewedAt = decision.Status, decision.ReviewerID, &decision.CreatedAt
+	reviewed.ReviewComment, reviewed.UpdatedAt = decision.Reason, decision.CreatedAt
+	return reviewed
+}
+
+// submissionEvent names the webhook event of a change from before to after:
+// approved when it moves the submission to approved, updated otherwise
+func submissionEvent(before, after *models.Submission) string {
+	if after.Status == "approved" && before.Status != "approved" {
+		return services.WebhookEventSubmissionApproved
+	}
+	return services.WebhookEventSubmissionUpdated
+}
+
 // @Summary Delete a submission
 // @Description Delete a submission by its ID
 // @Tags submissions
@@ -390,7 +1149,7 @@ func (sh *SubmissionHandler) DeleteSubmission(c *gin.Context) {
 	doc.DataTo(&submission)
 
 	// Check permissions
-	if user.Role != "admin" && submission.UserID != user.ID {
+	if !utils.HasPermissionIn(user, utils.PermSubmissionsDeleteAll, submission.OrgID) && submission.UserID != user.ID {
 		c.JSON(http.StatusForbidden, models.ErrorResponse{
 			Error:   "forbidden",
 			Message: "Access denied",
@@ -407,6 +1166,7 @@ func (sh *SubmissionHandler) DeleteSubmission(c *gin.Context) {
 		})
 		return
 	}
+	recordSubmissionAudit(sh.firestoreService, user.ID, "delete", &submission, nil)
 
 	c.JSON(http.StatusOK, models.SuccessResponse{
 		Success: true,
@@ -414,30 +1174,232 @@ func (sh *SubmissionHandler) DeleteSubmission(c *gin.Context) {
 	})
 }
 
-// @Summary Export submissions to CSV
-// @Description Export submissions to a CSV file
+// @Summary Compare a submission with the previous visit
+// @Description Return the photos of a submission next to those of the previous visit to the same field,
+// @Description so reviewers can visually confirm a claimed growth stage change
+// @Tags submissions
+// @Produce  json
+// @Security ApiKeyAuth
+// @Param id path string true "Submission ID"
+// @Success 200 {object} models.SuccessResponse
+// @Failure 403 {object} models.ErrorResponse
+// @Failure 404 {object} models.ErrorResponse
+// @Failure 500 {object} models.ErrorResponse
+// @Router /submissions/{id}/stage-comparison [get]
+func (sh *SubmissionHandler) GetStageComparison(c *gin.Context) {
+	ctx := sh.firestoreService.Context()
+	doc, err := sh.firestoreService.Submissions().Doc(c.Param("id")).Get(ctx)
+	if err != nil {
+		c.JSON(http.StatusNotFound, models.ErrorResponse{
+			Error:   "not_found",
+			Message: "Submission not found",
+		})
+		return
+	}
+
+	var submission models.Submission
+	doc.DataTo(&submission)
+
+	currentUser, _ := c.Get("user")
+	if !utils.HasPermissionIn(currentUser.(*models.User), utils.PermSubmissionsApprove, submission.OrgID) {
+		c.JSON(http.StatusForbidden, models.ErrorResponse{
+			Error:   "forbidden",
+			Message: "Access denied",
+		})
+		return
+	}
+
+	comparison := models.StageComparison{Current: visitPhotos(&submission)}
+	if submission.FieldID != "" {
+		previous, err := sh.previousVisit(submission.FieldID, submission.ID, submission.Date)
+		if err != nil {
+			c.JSON(http.StatusInternalServerError, models.ErrorResponse{
+				Error:   "internal_error",
+				Message: "Failed to retrieve the previous visit",
+			})
+			return
+		}
+		if previous != nil {
+			photos := visitPhotos(previous)
+			comparison.Previous = &photos
+			comparison.StageChanged = previous.GrowthStage != submission.GrowthStage
+		}
+	}
+
+	c.JSON(http.StatusOK, models.SuccessResponse{
+		Success: true,
+		Data:    comparison,
+	})
+}
+
+// @Summary Export submissions
+// @Description Export submissions to a CSV or XLSX file, or to GeoJSON points for GIS tools such as QGIS.
+// @Description The export has every field of a submission, see the data dictionary, and takes the filters and
+// @Description order of the submission list. CSV is streamed, so large exports start downloading at once.
+// @Description With replicates=true a CSV or XLSX export has one row per hill measured instead, for the
+// @Description submissions measured hill by hill.
 // @Tags submissions
 // @Produce  text/csv
+// @Produce  application/geo+json
 // @Security ApiKeyAuth
-// @Success 200 {string} string "CSV content"
+// @Param format query string false "csv (default), xlsx or geojson"
+// @Param replicates query bool false "One row per replicate hill (csv and xlsx only)"
+// @Param status query string false "Filter by submission status"
+// @Param field_id query string false "Filter by field ID"
+// @Param farm_id query string false "Filter by farm ID"
+// @Param season_id query string false "Filter by season ID"
+// @Param growth_stage query string false "Filter by growth stage"
+// @Param plant_condition query string false "Only submissions reporting this plant condition"
+// @Param tag query string false "Only submissions with this tag (not combined with plant_condition)"
+// @Param observer query string false "Filter by the user ID of the observer"
+// @Param created_from query string false "Created at or after (RFC 3339 or YYYY-MM-DD)"
+// @Param created_to query string false "Created before (RFC 3339 or YYYY-MM-DD)"
+// @Param date_from query string false "Observed on or after (RFC 3339 or YYYY-MM-DD)"
+// @Param date_to query string false "Observed before (RFC 3339 or YYYY-MM-DD)"
+// @Param sort query string false "created_at, date or status; defaults to the field of a time range, else created_at"
+// @Param order query string false "desc (default) or asc"
+// @Success 200 {string} string "CSV, XLSX or GeoJSON content"
+// @Failure 400 {object} models.ErrorResponse
+// @Failure 403 {object} models.ErrorResponse
 // @Failure 500 {object} models.ErrorResponse
 // @Router /submissions/export [get]
 func (sh *SubmissionHandler) ExportSubmissions(c *gin.Context) {
 	currentUser, _ := c.Get("user")
 	user := currentUser.(*models.User)
 
+	format, ok := submissionExportFormat(c)
+	if !ok {
+		return
+	}
+
+	var params models.SubmissionListParams
+	if err := c.ShouldBindQuery(&params); err != nil {
+		c.JSON(http.StatusBadRequest, models.ErrorResponse{
+			Error:   "invalid_request",
+			Message: err.Error(),
+		})
+		return
+	}
+
+	layout := submissionsLayout
+	if c.Query("replicates") == "true" {
+		if format == "geojson" {
+			c.JSON(http.StatusBadRequest, models.ErrorResponse{
+				Error:   "invalid_request",
+				Message: "replicates can only be exported to csv or xlsx",
+			})
+			return
+		}
+		layout = replicatesLayout
+	}
+
+	sh.exportSubmissions(c, format, layout, user, params)
+}
+
+// @Summary Export submissions with a share token
+// @Description Download a submissions export without an account, using a share token minted with
+// @Description POST /share-tokens. The export holds what the token's creator could export.
+// @Tags submissions
+// @Produce  text/csv
+// @Produce  application/geo+json
+// @Param token query string true "Share token"
+// @Param format query string false "csv (default), xlsx or geojson"
+// @Success 200 {string} string "CSV or XLSX content"
+// @Failure 400 {object} models.ErrorResponse
+// @Failure 401 {object} models.ErrorResponse
+// @Failure 500 {object} models.ErrorResponse
+// @Router /shared/submissions/export [get]
+func (sh *SubmissionHandler) ExportSharedSubmissions(c *gin.Context) {
+	claims, err := utils.ValidateShareToken(c.Query("token"), utils.ShareScopeExportSubmissions)
+	if err != nil {
+		c.JSON(http.StatusUnauthorized, models.ErrorResponse{
+			Error:   "unauthorized",
+			Message: "Invalid or expired share token",
+		})
+		return
+	}
+
+	// The creator's access is rechecked, so suspending them or logging them out everywhere revokes their links
 	ctx := sh.firestoreService.Context()
-	query := sh.firestoreService.Submissions().Query
+	doc, err := sh.firestoreService.Users().Doc(claims.UserID).Get(ctx)
+	if err != nil {
+		c.JSON(http.StatusUnauthorized, models.ErrorResponse{
+			Error:   "unauthorized",
+			Message: "Invalid or expired share token",
+		})
+		return
+	}
+	var user models.User
+	doc.DataTo(&user)
+	if utils.TokenRevoked(claims, &user) || utils.AccountSuspended(&user) {
+		c.JSON(http.StatusUnauthorized, models.ErrorResponse{
+			Error:   "unauthorized",
+			Message: "Share token has been revoked",
+		})
+		return
+	}
 
-	// Filter by user (non-admin users can only export their submissions)
-	if user.Role != "admin" {
-		query = query.Where("user_id", "==", user.ID)
+	format, ok := submissionExportFormat(c)
+	if !ok {
+		return
 	}
 
-	// Execute query
-	iter := query.Documents(ctx)
-	var submissions []models.Submission
+	sh.exportSubmissions(c, format, submissionsLayout, &user, models.SubmissionListParams{FieldID: claims.FieldID, Order: "desc"})
+}
+
+// exportLayout is the file name, columns and rows of a submissions export
+type exportLayout struct {
+	name    string
+	columns []models.ExportColumn
+	rows    func(s models.Submission, fieldName string, loc *time.Location) [][]string
+}
+
+// submissionsLayout has one row per submission
+var submissionsLayout = exportLayout{
+	name:    "submissions",
+	columns: submissionExportColumns,
+	rows: func(s models.Submission, fieldName string, loc *time.Location) [][]string {
+		return [][]string{submissionExportRow(s, fieldName, loc)}
+	},
+}
+
+// replicatesLayout has one row per replicate hill, leaving out submissions without replicates
+var replicatesLayout = exportLayout{
+	name:    "replicates",
+	columns: replicateExportColumns,
+	rows:    replicateExportRows,
+}
 
+// exportSubmissions sends the submissions user may export that match params,
+// laid out as layout. CSV is streamed row by row; XLSX and GeoJSON are built in memory.
+func (sh *SubmissionHandler) exportSubmissions(c *gin.Context, format string, layout exportLayout, user *models.User, params models.SubmissionListParams) {
+	query, err := sh.submissionListQuery(user, params)
+	if errors.Is(err, errObserverForbidden) {
+		c.JSON(http.StatusForbidden, models.ErrorResponse{
+			Error:   "forbidden",
+			Message: err.Error(),
+		})
+		return
+	}
+	if err != nil {
+		c.JSON(http.StatusBadRequest, models.ErrorResponse{
+			Error:   "invalid_request",
+			Message: err.Error(),
+		})
+		return
+	}
+
+	// Dates are written in the exporting user's timezone
+	loc := utils.UserLocation(user)
+	iter := query.Documents(sh.firestoreService.Context())
+	defer iter.Stop()
+
+	if format == "csv" {
+		sh.streamSubmissionsCSV(c, iter, layout, loc)
+		return
+	}
+
+	var submissions []models.Submission
 	for {
 		doc, err := iter.Next()
 		if err == iterator.Done {
@@ -456,16 +1418,488 @@ func (sh *SubmissionHandler) ExportSubmissions(c *gin.Context) {
 		submissions = append(submissions, submission)
 	}
 
-	// Set CSV headers
+	if format == "geojson" {
+		sh.writeSubmissionsGeoJSON(c, submissions, loc)
+		return
+	}
+
+	fieldName := sh.fieldNames()
+	rows := make([][]string, 0, len(submissions))
+	for _, s := range submissions {
+		rows = append(rows, layout.rows(s, fieldName(s.FieldID), loc)...)
+	}
+
+	writeExport(c, format, layout.name, columnNames(layout.columns), rows)
+}
+
+// streamSubmissionsCSV writes the submissions of iter to the response as they
+// are read, so large exports never sit in memory. The first read happens
+// before the response starts, so a failing query still gets an error
+// response; later failures can only cut the file short and are logged.
+func (sh *SubmissionHandler) streamSubmissionsCSV(c *gin.Context, iter *firestore.DocumentIterator, layout exportLayout, loc *time.Location) {
+	doc, err := iter.Next()
+	if err != nil && err != iterator.Done {
+		c.JSON(http.StatusInternalServerError, models.ErrorResponse{
+			Error:   "internal_error",
+			Message: "Failed to retrieve submissions",
+		})
+		return
+	}
+
+	stampAgreement(c)
+	c.Header("Content-Disposition", "attachment; filename="+layout.name+".csv")
 	c.Header("Content-Type", "text/csv")
-	c.Header("Content-Disposition", "attachment; filename=submissions.csv")
+	c.Status(http.StatusOK)
+
+	w := csv.NewWriter(c.Writer)
+	w.Write(columnNames(layout.columns))
+	fieldName := sh.fieldNames()
+	for rows := 1; err == nil; rows++ {
+		var submission models.Submission
+		doc.DataTo(&submission)
+		for _, row := range layout.rows(submission, fieldName(submission.FieldID), loc) {
+			w.Write(row)
+		}
+		if rows%500 == 0 {
+			w.Flush()
+			c.Writer.Flush()
+		}
+		doc, err = iter.Next()
+	}
+	if err != iterator.Done {
+		log.Printf("Submissions export cut short: %v", err)
+	}
+	w.Flush()
+}
+
+// submissionExportRow formats a submission as the submissionExportColumns
+func submissionExportRow(s models.Submission, fieldName string, loc *time.Location) []string {
+	row := []string{s.ID, s.FieldID, fieldName, s.Date.In(loc).Format("2006-01-02"), s.GrowthStage, strings.Join(s.PlantConditions, ";")}
+
+	values := traitValues(s.TraitMeasurements)
+	for _, trait := range utils.Traits {
+		row = append(row, strconv.FormatFloat(values[trait.Key], 'f', -1, 64))
+	}
+
+	latitude, longitude, accuracy := "", "", ""
+	if s.Location != nil {
+		latitude = strconv.FormatFloat(s.Location.Latitude, 'f', -1, 64)
+		longitude = strconv.FormatFloat(s.Location.Longitude, 'f', -1, 64)
+		accuracy = strconv.FormatFloat(s.Location.Accuracy, 'f', -1, 64)
+	} else if s.Coordinates != nil {
+		latitude = strconv.FormatFloat(s.Coordinates.Latitude, 'f', -1, 64)
+		longitude = strconv.FormatFloat(s.Coordinates.Longitude, 'f', -1, 64)
+	}
+
+	row = append(row, s.HillID, s.Notes, s.ObserverName, s.UserID, s.Status, s.Source, submissionProvenance(s),
+		latitude, longitude, accuracy, s.CreatedAt.In(loc).Format(time.RFC3339))
+	row = append(row, weatherColumns(s.Weather)...)
+	row = append(row, customTraitsColumn(s.TraitMeasurements.Custom))
+	return append(row, replicateSummaryRow(s.ReplicateSummary)...)
+}
+
+// fieldNames returns a lookup of field names by ID that loads each field once.
+// Fields that cannot be loaded have an empty name.
+func (sh *SubmissionHandler) fieldNames() func(string) string {
+	names := map[string]string{"": ""}
+	return func(fieldID string) string {
+		name, ok := names[fieldID]
+		if !ok {
+			if field, err := sh.getSubmissionField(models.Submission{FieldID: fieldID}); err == nil {
+				name = field.Name
+			}
+			names[fieldID] = name
+		}
+		return name
+	}
+}
 
-	// Write CSV content
-	csvContent := "ID,Date,Location,Growth Stage,Observer,Status\n"
+// @Summary Export review decisions
+// @Description Export the review log for program audits: every status decision with its reviewer,
+// @Description time, reason and whether it reversed an earlier approval or rejection, oldest first
+// @Tags submissions
+// @Produce  text/csv
+// @Security ApiKeyAuth
+// @Param format query string false "csv (default) or xlsx"
+// @Param reviewer_id query string false "Only decisions by this reviewer"
+// @Param since query string false "Only decisions at or after this time (RFC 3339 or YYYY-MM-DD)"
+// @Param until query string false "Only decisions before this time (RFC 3339 or YYYY-MM-DD)"
+// @Success 200 {string} string "CSV or XLSX content"
+// @Failure 400 {object} models.ErrorResponse
+// @Failure 500 {object} models.ErrorResponse
+// @Router /submissions/reviews/export [get]
+func (sh *SubmissionHandler) ExportReviewDecisions(c *gin.Context) {
+	format, ok := exportFormat(c)
+	if !ok {
+		return
+	}
+
+	currentUser, _ := c.Get("user")
+	loc := utils.UserLocation(currentUser.(*models.User))
+
+	query := orgScope(sh.firestoreService.ReviewDecisions().Query, currentUser.(*models.User))
+	if reviewerID := c.Query("reviewer_id"); reviewerID != "" {
+		query = query.Where("reviewer_id", "==", reviewerID)
+	}
+	for _, bound := range []struct{ param, op string }{{"since", ">="}, {"until", "<"}} {
+		value := c.Query(bound.param)
+		if value == "" {
+			continue
+		}
+		t, err := parseEventTime(value, loc)
+		if err != nil {
+			c.JSON(http.StatusBadRequest, models.ErrorResponse{
+				Error:   "invalid_request",
+				Message: bound.param + " must be an RFC 3339 time or a YYYY-MM-DD date",
+			})
+			return
+		}
+		query = query.Where("created_at", bound.op, t)
+	}
+
+	ctx := sh.firestoreService.Context()
+	docs, err := query.OrderBy("created_at", firestore.Asc).Documents(ctx).GetAll()
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, models.ErrorResponse{
+			Error:   "internal_error",
+			Message: "Failed to retrieve review decisions",
+		})
+		return
+	}
+
+	rows := make([][]string, 0, len(docs))
+	for _, doc := range docs {
+		var d models.ReviewDecision
+		doc.DataTo(&d)
+		rows = append(rows, []string{
+			d.ID,
+			d.SubmissionID,
+			d.ReviewerID,
+			d.ReviewerName,
+			d.CreatedAt.In(loc).Format(time.RFC3339),
+			d.PreviousStatus,
+			d.Status,
+			strconv.FormatBool(d.Reversal),
+			d.Reason,
+		})
+	}
+
+	writeExport(c, format, "review-decisions", []string{
+		"ID", "Submission ID", "Reviewer ID", "Reviewer", "Decided At",
+		"Previous Status", "Status", "Reversal", "Reason",
+	}, rows)
+}
+
+// stagePhotoProblem returns 400 and the error to respond with when the
+// submission claims a different growth stage than the previous visit to the
+// field but has no photo to confirm it. Photos still waiting to be uploaded
+// count. The previous visit can also be one of earlier, submissions not stored yet.
+func (sh *SubmissionHandler) stagePhotoProblem(fieldID, submissionID string, date time.Time, stage string, photos int, earlier []*models.Submission) (int, *models.ErrorResponse) {
+	if !sh.stagePhotoRequired || fieldID == "" || photos > 0 {
+		return 0, nil
+	}
+
+	previous, err := sh.previousVisit(fieldID, submissionID, date)
+	if err != nil {
+		return http.StatusInternalServerError, &models.ErrorResponse{
+			Error:   "internal_error",
+			Message: "Failed to retrieve the previous visit",
+		}
+	}
+	for _, candidate := range earlier {
+		if candidate.FieldID == fieldID && candidate.Date.Before(date) &&
+			(previous == nil || candidate.Date.After(previous.Date)) {
+			previous = candidate
+		}
+	}
+	if previous == nil || previous.GrowthStage == stage {
+		return 0, nil
+	}
+
+	return http.StatusBadRequest, &models.ErrorResponse{
+		Error:   "stage_photo_required",
+		Message: fmt.Sprintf("The growth stage changed from %s since the previous visit; attach a photo to confirm it", previous.GrowthStage),
+	}
+}
+
+// previousVisit returns the latest non-draft submission for the field dated
+// before date, other than submissionID, or nil when there is none
+func (sh *SubmissionHandler) previousVisit(fieldID, submissionID string, date time.Time) (*models.Submission, error) {
+	ctx := sh.firestoreService.Context()
+	docs, err := sh.firestoreService.Submissions().
+		Where("field_id", "==", fieldID).
+		Where("date", "<", date).
+		OrderBy("date", firestore.Desc).
+		Limit(10).
+		Documents(ctx).GetAll()
+	if err != nil {
+		return nil, err
+	}
+
+	for _, doc := range docs {
+		var submission models.Submission
+		doc.DataTo(&submission)
+		if submission.ID != submissionID && submission.Status != "draft" {
+			return &submission, nil
+		}
+	}
+	return nil, nil
+}
+
+// recordWeather looks up the weather at the time and place of the observation
+// and stores it on the submission, unless the device already reported it.
+// Failures are logged; the submission is kept without a snapshot.
+func (sh *SubmissionHandler) recordWeather(submission *models.Submission) {
+	if submission.Weather != nil {
+		return
+	}
+
+	// The phone's GPS fix is where the plants were, the field's coordinates are a fallback
+	location := submission.Coordinates
+	if submission.Location != nil {
+		point := submission.Location.Point()
+		location = &point
+	}
+	if location == nil {
+		field, err := sh.getSubmissionField(*submission)
+		if err != nil || (field.Coordinates.Latitude == 0 && field.Coordinates.Longitude == 0) {
+			return
+		}
+		location = &field.Coordinates
+	}
+
+	snapshot, err := sh.weatherService.Snapshot(*location, submission.Date)
+	if err != nil {
+		log.Printf("Failed to look up weather for submission %s: %v", submission.ID, err)
+		return
+	}
+	if snapshot == nil {
+		return
+	}
+
+	ctx := sh.firestoreService.Context()
+	_, err = sh.firestoreService.Submissions().Doc(submission.ID).Update(ctx, []firestore.Update{
+		{Path: "weather", Value: snapshot},
+	})
+	if err != nil {
+		log.Printf("Failed to store weather for submission %s: %v", submission.ID, err)
+	}
+}
+
+func visitPhotos(submission *models.Submission) models.VisitPhotos {
+	return models.VisitPhotos{
+		SubmissionID: submission.ID,
+		Date:         submission.Date,
+		GrowthStage:  submission.GrowthStage,
+		Images:       submission.Images,
+	}
+}
+
+// submissionResponse is the submission as returned to user, with its field
+func submissionResponse(user *models.User, submission models.Submission, field *models.Field) models.SubmissionResponse {
+	return models.SubmissionResponse{
+		ID:                   submission.ID,
+		UserID:               submission.UserID,
+		FieldID:              submission.FieldID,
+		Field:                *field,
+		Date:                 submission.Date,
+		GrowthStage:          submission.GrowthStage,
+		PlantConditions:      submission.PlantConditions,
+		TraitMeasurements:    submission.TraitMeasurements,
+		RawMeasurements:      submission.RawMeasurements,
+		Calibrations:         submission.Calibrations,
+		HillID:               submission.HillID,
+		Replicates:           submission.Replicates,
+		ReplicateSummary:     submission.ReplicateSummary,
+		Notes:                submission.Notes,
+		ObserverName:         submission.ObserverName,
+		Images:               submission.Images,
+		PendingImages:        submission.PendingImages,
+		MediaStatus:          submission.MediaStatus,
+		Evidence:             submission.Evidence,
+		Attachments:          submission.Attachments,
+		Status:               submission.Status,
+		ReviewedBy:           submission.ReviewedBy,
+		ReviewedAt:           submission.ReviewedAt,
+		ReviewComment:        submission.ReviewComment,
+		Tags:                 submission.Tags,
+		Source:               submission.Source,
+		Provenance:           submissionProvenance(submission),
+		Coordinates:          submission.Coordinates,
+		Location:             submission.Location,
+		OutOfBoundsM:         submission.OutOfBoundsM,
+		Weather:              submission.Weather,
+		Device:               deviceFor(user, submission),
+		VerificationRequired: submission.VerificationRequired,
+		CreatedAt:            submission.CreatedAt,
+		UpdatedAt:            submission.UpdatedAt,
+	}
+}
+
+// getSubmissionField loads the field a submission belongs to. Community observations
+// may not be linked to a field, in which case an empty field is returned.
+func (sh *SubmissionHandler) getSubmissionField(submission models.Submission) (*models.Field, error) {
+	if submission.FieldID == "" {
+		return &models.Field{}, nil
+	}
+
+	ctx := sh.firestoreService.Context()
+	doc, err := sh.firestoreService.Fields().Doc(submission.FieldID).Get(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	var field models.Field
+	if err := doc.DataTo(&field); err != nil {
+		return nil, err
+	}
+
+	return &field, nil
+}
+
+// submissionFields loads the fields of the submissions by ID in one read, so
+// lists do not look them up one by one. Fields that no longer exist are left out.
+func (sh *SubmissionHandler) submissionFields(submissions []models.Submission) (map[string]*models.Field, error) {
+	var refs []*firestore.DocumentRef
+	seen := map[string]bool{}
 	for _, s := range submissions {
-		csvContent += fmt.Sprintf("%s,%s,%s,%s,%s\n",
-			s.ID, s.Date.Format("2006-01-02"), s.GrowthStage, s.ObserverName, s.Status)
+		if s.FieldID != "" && !seen[s.FieldID] {
+			seen[s.FieldID] = true
+			refs = append(refs, sh.firestoreService.Fields().Doc(s.FieldID))
+		}
+	}
+
+	fields := map[string]*models.Field{}
+	if len(refs) == 0 {
+		return fields, nil
+	}
+	docs, err := sh.firestoreService.Client.GetAll(sh.firestoreService.Context(), refs)
+	if err != nil {
+		return nil, err
+	}
+	for _, doc := range docs {
+		if !doc.Exists() {
+			continue
+		}
+		var field models.Field
+		doc.DataTo(&field)
+		fields[doc.Ref.ID] = &field
+	}
+	return fields, nil
+}
+
+// validateCommunitySubmission is the validation profile for community observations.
+// Stage and condition must come from the app's pickers and the GPS fix must be real.
+func (sh *SubmissionHandler) validateCommunitySubmission(req *models.CommunitySubmissionRequest) error {
+	if !utils.Contains(sh.vocabularyService.GrowthStages(), req.GrowthStage) {
+		return fmt.Errorf("unknown growth stage %q", req.GrowthStage)
+	}
+	if !utils.Contains(sh.vocabularyService.PlantConditions(), req.Condition) {
+		return fmt.Errorf("unknown plant condition %q", req.Condition)
+	}
+
+	lat, lng := req.Coordinates.Latitude, req.Coordinates.Longitude
+	if lat < -90 || lat > 90 || lng < -180 || lng > 180 || (lat == 0 && lng == 0) {
+		return fmt.Errorf("coordinates must be a valid GPS position")
+	}
+	if req.Date != nil && req.Date.After(time.Now().Add(time.Hour)) {
+		return fmt.Errorf("date cannot be in the future")
+	}
+
+	return nil
+}
+
+// canonicalTerms replaces a growth stage and plant conditions by their codes in
+// the vocabulary, so "tillering" is stored as "Tillering". An empty stage is
+// left empty; repeated conditions are dropped.
+func (sh *SubmissionHandler) canonicalTerms(stage string, conditions []string) (string, []string, error) {
+	if stage != "" {
+		code, ok := sh.vocabularyService.Canonical("growth_stage", stage)
+		if !ok {
+			return "", nil, fmt.Errorf("unknown growth stage %q, must be one of: %s", stage, strings.Join(sh.vocabularyService.GrowthStages(), ", "))
+		}
+		stage = code
+	}
+
+	var codes []string
+	for _, condition := range conditions {
+		code, ok := sh.vocabularyService.Canonical("condition", condition)
+		if !ok {
+			return "", nil, fmt.Errorf("unknown plant condition %q, must be one of: %s", condition, strings.Join(sh.vocabularyService.PlantConditions(), ", "))
+		}
+		if !utils.Contains(codes, code) {
+			codes = append(codes, code)
+		}
 	}
+	if conditions != nil && codes == nil {
+		codes = []string{}
+	}
+	return stage, codes, nil
+}
+
+// customTraitsProblem validates the custom trait values of a submission. On
+// failure it returns the status code and error to respond with.
+func (sh *SubmissionHandler) customTraitsProblem(custom map[string]float64) (int, *models.ErrorResponse) {
+	err := sh.traitService.Validate(custom)
+	if errors.Is(err, services.ErrInvalidTrait) {
+		return http.StatusBadRequest, &models.ErrorResponse{
+			Error:   "invalid_traits",
+			Message: err.Error(),
+		}
+	}
+	if err != nil {
+		return http.StatusInternalServerError, &models.ErrorResponse{
+			Error:   "internal_error",
+			Message: "Failed to validate traits",
+		}
+	}
+	return 0, nil
+}
+
+// validateEvidence checks that every link points at one of images and at
+// either a known trait, built in or among the custom traits recorded, or one
+// of conditions, but not both
+func validateEvidence(links []models.EvidenceLink, images, conditions []string, custom map[string]float64) error {
+	for i, link := range links {
+		if !utils.Contains(images, link.Image) {
+			return fmt.Errorf("evidence %d: image is not attached to the submission", i)
+		}
+		if (link.Trait == "") == (link.Condition == "") {
+			return fmt.Errorf("evidence %d: link the image to either a trait or a condition", i)
+		}
+		if _, recorded := custom[link.Trait]; link.Trait != "" && !utils.Contains(utils.TraitKeys, link.Trait) && !recorded {
+			return fmt.Errorf("evidence %d: unknown trait %q, must be one of: %s or a custom trait recorded on the submission", i, link.Trait, strings.Join(utils.TraitKeys, ", "))
+		}
+		if link.Condition != "" && !utils.Contains(conditions, link.Condition) {
+			return fmt.Errorf("evidence %d: condition %q is not recorded on the submission", i, link.Condition)
+		}
+	}
+	return nil
+}
 
-	c.String(http.StatusOK, csvContent)
-}
\ No newline at end of file
+// submissionProvenance returns the provenance of a submission, treating records
+// created before provenance was tracked as research-grade
+// submissionDevice records the device the client reported along with its user agent
+func submissionDevice(c *gin.Context, reported *models.DeviceMetadata) *models.DeviceMetadata {
+	device := models.DeviceMetadata{}
+	if reported != nil {
+		device = *reported
+	}
+	device.UserAgent = c.Request.UserAgent()
+	return &device
+}
+
+// deviceFor returns the submission's device metadata when user can read all submissions
+func deviceFor(user *models.User, submission models.Submission) *models.DeviceMetadata {
+	if !utils.HasPermissionIn(user, utils.PermSubmissionsReadAll, submission.OrgID) {
+		return nil
+	}
+	return submission.Device
+}
+
+func submissionProvenance(submission models.Submission) string {
+	if submission.Provenance == "" {
+		return "research"
+	}
+	return submission.Provenance
+}
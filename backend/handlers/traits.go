@@ -0,0 +1,268 @@
+package handlers
+
+import (
+	"net/http"
+	"regexp"
+	"time"
+
+	"rice-monitor-api/models"
+	"rice-monitor-api/services"
+	"rice-monitor-api/utils"
+
+	"cloud.google.com/go/firestore"
+	"github.com/gin-gonic/gin"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+)
+
+// traitKeyPattern keeps custom trait keys in the style of the built-in ones
+var traitKeyPattern = regexp.MustCompile(`^[a-z][a-z0-9_]{1,39}$`)
+
+type TraitHandler struct {
+	firestoreService *services.FirestoreService
+	traitService     *services.TraitService
+}
+
+func NewTraitHandler(firestoreService *services.FirestoreService, traitService *services.TraitService) *TraitHandler {
+	return &TraitHandler{
+		firestoreService: firestoreService,
+		traitService:     traitService,
+	}
+}
+
+// @Summary List traits
+// @Description List the traits submissions can record: the built-in ones of trait_measurements, then the
+// @Description deployment's custom traits, recorded under trait_measurements.custom by key
+// @Tags traits
+// @Produce  json
+// @Security ApiKeyAuth
+// @Success 200 {object} models.SuccessResponse
+// @Failure 500 {object} models.ErrorResponse
+// @Router /traits [get]
+func (th *TraitHandler) GetTraits(c *gin.Context) {
+	custom, err := th.traitService.Definitions()
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, models.ErrorResponse{
+			Error:   "internal_error",
+			Message: "Failed to retrieve traits",
+		})
+		return
+	}
+
+	c.JSON(http.StatusOK, models.SuccessResponse{
+		Success: true,
+		Data:    append(append([]models.TraitDefinition{}, utils.Traits...), custom...),
+	})
+}
+
+// @Summary Define a custom trait
+// @Description Define a trait the built-in ones do not cover, such as plant height, tiller count or SPAD.
+// @Description Submissions record it as trait_measurements.custom.<key>, checked against its type and bounds.
+// @Tags traits
+// @Accept  json
+// @Produce  json
+// @Security ApiKeyAuth
+// @Param trait body models.CreateTraitRequest true "Trait"
+// @Success 201 {object} models.SuccessResponse
+// @Failure 400 {object} models.ErrorResponse
+// @Failure 409 {object} models.ErrorResponse
+// @Failure 500 {object} models.ErrorResponse
+// @Router /traits [post]
+func (th *TraitHandler) CreateTrait(c *gin.Context) {
+	var req models.CreateTraitRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, models.ErrorResponse{
+			Error:   "invalid_request",
+			Message: err.Error(),
+		})
+		return
+	}
+	if !traitKeyPattern.MatchString(req.Key) {
+		c.JSON(http.StatusBadRequest, models.ErrorResponse{
+			Error:   "invalid_request",
+			Message: "key must be 2 to 40 lowercase letters, digits and _, starting with a letter",
+		})
+		return
+	}
+	if utils.Contains(utils.TraitKeys, req.Key) || req.Key == "custom" {
+		c.JSON(http.StatusConflict, models.ErrorResponse{
+			Error:   "trait_exists",
+			Message: "A built-in trait already has this key",
+		})
+		return
+	}
+	if !validTraitBounds(req.Min, req.Max) {
+		c.JSON(http.StatusBadRequest, models.ErrorResponse{
+			Error:   "invalid_request",
+			Message: "min must not be greater than max",
+		})
+		return
+	}
+
+	currentUser, _ := c.Get("user")
+	user := currentUser.(*models.User)
+
+	now := time.Now()
+	trait := models.TraitDefinition{
+		Key:         req.Key,
+		Label:       req.Label,
+		Type:        req.Type,
+		Unit:        req.Unit,
+		Description: req.Description,
+		Min:         req.Min,
+		Max:         req.Max,
+		Custom:      true,
+		CreatedBy:   user.ID,
+		CreatedAt:   &now,
+		UpdatedAt:   &now,
+	}
+
+	ctx := th.firestoreService.Context()
+	if _, err := th.firestoreService.TraitDefinitions().Doc(trait.Key).Create(ctx, trait); err != nil {
+		if status.Code(err) == codes.AlreadyExists {
+			c.JSON(http.StatusConflict, models.ErrorResponse{
+				Error:   "trait_exists",
+				Message: "A custom trait already has this key",
+			})
+			return
+		}
+		c.JSON(http.StatusInternalServerError, models.ErrorResponse{
+			Error:   "internal_error",
+			Message: "Failed to create trait",
+		})
+		return
+	}
+	th.traitService.Invalidate()
+
+	c.JSON(http.StatusCreated, models.SuccessResponse{
+		Success: true,
+		Data:    trait,
+		Message: "Trait created successfully",
+	})
+}
+
+// @Summary Update a custom trait
+// @Description Change the label, unit, description or bounds of a custom trait. New bounds apply to new and
+// @Description edited submissions; values already recorded are kept.
+// @Tags traits
+// @Accept  json
+// @Produce  json
+// @Security ApiKeyAuth
+// @Param key path string true "Trait key"
+// @Param trait body models.UpdateTraitRequest true "Changes"
+// @Success 200 {object} models.SuccessResponse
+// @Failure 400 {object} models.ErrorResponse
+// @Failure 404 {object} models.ErrorResponse
+// @Failure 500 {object} models.ErrorResponse
+// @Router /traits/{key} [put]
+func (th *TraitHandler) UpdateTrait(c *gin.Context) {
+	var req models.UpdateTraitRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, models.ErrorResponse{
+			Error:   "invalid_request",
+			Message: err.Error(),
+		})
+		return
+	}
+
+	ctx := th.firestoreService.Context()
+	ref := th.firestoreService.TraitDefinitions().Doc(c.Param("key"))
+	doc, err := ref.Get(ctx)
+	if err != nil {
+		c.JSON(http.StatusNotFound, models.ErrorResponse{
+			Error:   "not_found",
+			Message: "Custom trait not found",
+		})
+		return
+	}
+	var trait models.TraitDefinition
+	doc.DataTo(&trait)
+
+	if req.Label != nil {
+		trait.Label = *req.Label
+	}
+	if req.Unit != nil {
+		trait.Unit = *req.Unit
+	}
+	if req.Description != nil {
+		trait.Description = *req.Description
+	}
+	if req.Min != nil {
+		trait.Min = req.Min
+	}
+	if req.Max != nil {
+		trait.Max = req.Max
+	}
+	if !validTraitBounds(trait.Min, trait.Max) {
+		c.JSON(http.StatusBadRequest, models.ErrorResponse{
+			Error:   "invalid_request",
+			Message: "min must not be greater than max",
+		})
+		return
+	}
+	now := time.Now()
+	trait.UpdatedAt = &now
+
+	_, err = ref.Update(ctx, []firestore.Update{
+		{Path: "label", Value: trait.Label},
+		{Path: "unit", Value: trait.Unit},
+		{Path: "description", Value: trait.Description},
+		{Path: "min", Value: trait.Min},
+		{Path: "max", Value: trait.Max},
+		{Path: "updated_at", Value: trait.UpdatedAt},
+	})
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, models.ErrorResponse{
+			Error:   "internal_error",
+			Message: "Failed to update trait",
+		})
+		return
+	}
+	th.traitService.Invalidate()
+
+	c.JSON(http.StatusOK, models.SuccessResponse{
+		Success: true,
+		Data:    trait,
+		Message: "Trait updated successfully",
+	})
+}
+
+// @Summary Delete a custom trait
+// @Description Stop accepting a custom trait. Submissions that recorded it keep their values.
+// @Tags traits
+// @Produce  json
+// @Security ApiKeyAuth
+// @Param key path string true "Trait key"
+// @Success 200 {object} models.SuccessResponse
+// @Failure 404 {object} models.ErrorResponse
+// @Failure 500 {object} models.ErrorResponse
+// @Router /traits/{key} [delete]
+func (th *TraitHandler) DeleteTrait(c *gin.Context) {
+	ctx := th.firestoreService.Context()
+	ref := th.firestoreService.TraitDefinitions().Doc(c.Param("key"))
+	if _, err := ref.Delete(ctx, firestore.Exists); err != nil {
+		if status.Code(err) == codes.NotFound {
+			c.JSON(http.StatusNotFound, models.ErrorResponse{
+				Error:   "not_found",
+				Message: "Custom trait not found",
+			})
+			return
+		}
+		c.JSON(http.StatusInternalServerError, models.ErrorResponse{
+			Error:   "internal_error",
+			Message: "Failed to delete trait",
+		})
+		return
+	}
+	th.traitService.Invalidate()
+
+	c.JSON(http.StatusOK, models.SuccessResponse{
+		Success: true,
+		Message: "Trait deleted successfully",
+	})
+}
+
+// validTraitBounds reports whether the lower bound, when set, is not above the upper one
+func validTraitBounds(low, high *float64) bool {
+	return low == nil || high == nil || *low <= *high
+}
@@ -0,0 +1,235 @@
+package handlers
+
+import (
+	"context"
+	"log"
+	"net/http"
+	"time"
+
+	"rice-monitor-api/models"
+	"rice-monitor-api/utils"
+
+	"cloud.google.com/go/firestore"
+	"github.com/gin-gonic/gin"
+)
+
+// transferBatchSize is how many documents one transaction reassigns, within
+// Firestore's limit of 500 writes. Larger transfers run in the background.
+const transferBatchSize = 400
+
+// ownedCollection is a collection of documents owned by a user through ownerField
+type ownedCollection struct {
+	collection *firestore.CollectionRef
+	ownerField string
+}
+
+func (uh *UserHandler) ownedCollections() []ownedCollection {
+	return []ownedCollection{
+		{uh.firestoreService.Fields(), "owner_id"},
+		{uh.firestoreService.Submissions(), "user_id"},
+	}
+}
+
+// countOwnedData returns how many fields and submissions userID owns
+func (uh *UserHandler) countOwnedData(ctx context.Context, userID string) (int, error) {
+	total := 0
+	for _, owned := range uh.ownedCollections() {
+		count, err := countDocuments(ctx, owned.collection.Where(owned.ownerField, "==", userID))
+		if err != nil {
+			return 0, err
+		}
+		total += int(count)
+	}
+	return total, nil
+}
+
+// transferAndDelete reassigns the user's fields and submissions to
+// deletion.TransferTo in transactions of transferBatchSize documents, recording
+// progress on the deletion after each, then soft-deletes the user. The user is
+// only deleted once nothing is left to transfer, so a failed deletion can be
+// retried with the same request.
+func (uh *UserHandler) transferAndDelete(user *models.User, deletion *models.UserDeletion) error {
+	ctx := uh.firestoreService.Context()
+	deletionRef := uh.firestoreService.UserDeletions().Doc(deletion.ID)
+
+	fail := func(err error) error {
+		log.Printf("Failed to transfer the data of user %s: %v", deletion.ID, err)
+		deletion.Status = "failed"
+		deletion.Error = err.Error()
+		_, updateErr := deletionRef.Update(ctx, []firestore.Update{
+			{Path: "status", Value: deletion.Status},
+			{Path: "error", Value: deletion.Error},
+			{Path: "updated_at", Value: time.Now()},
+		})
+		if updateErr != nil {
+			log.Printf("Failed to record the failed deletion of user %s: %v", deletion.ID, updateErr)
+		}
+		return err
+	}
+
+	for _, owned := range uh.ownedCollections() {
+		for {
+			moved := 0
+			err := uh.firestoreService.Client.RunTransaction(ctx, func(ctx context.Context, tx *firestore.Transaction) error {
+				query := owned.collection.Where(owned.ownerField, "==", deletion.ID).Limit(transferBatchSize)
+				docs, err := tx.Documents(query).GetAll()
+				if err != nil {
+					return err
+				}
+				for _, doc := range docs {
+					err := tx.Update(doc.Ref, []firestore.Update{
+						{Path: owned.ownerField, Value: deletion.TransferTo},
+						{Path: "updated_at", Value: time.Now()},
+					})
+					if err != nil {
+						return err
+					}
+				}
+				moved = len(docs)
+				return nil
+			})
+			if err != nil {
+				return fail(err)
+			}
+			if moved == 0 {
+				break
+			}
+
+			deletion.Transferred += moved
+			deletion.UpdatedAt = time.Now()
+			_, err = deletionRef.Update(ctx, []firestore.Update{
+				{Path: "transferred", Value: deletion.Transferred},
+				{Path: "updated_at", Value: deletion.UpdatedAt},
+			})
+			if err != nil {
+				log.Printf("Failed to record the transfer progress of user %s: %v", deletion.ID, err)
+			}
+		}
+	}
+
+	if err := uh.softDeleteUser(ctx, user, deletion.RequestedBy, deletion); err != nil {
+		return fail(err)
+	}
+	return nil
+}
+
+// softDeleteUser marks the user deleted by deletedBy, and marks deletion done
+// when given. The user and their email reservation are kept until the user
+// purger removes them, so the account can be restored until then.
+func (uh *UserHandler) softDeleteUser(ctx context.Context, user *models.User, deletedBy string, deletion *models.UserDeletion) error {
+	now := time.Now()
+	batch := uh.firestoreService.Client.Batch()
+	batch.Update(uh.firestoreService.Users().Doc(user.ID), []firestore.Update{
+		{Path: "deleted_at", Value: now},
+		{Path: "deleted_by", Value: deletedBy},
+		{Path: "tokens_valid_after", Value: now}, // a restored user signs in again
+		{Path: "updated_at", Value: now},
+	})
+	if deletion != nil {
+		deletion.Status = "done"
+		deletion.UpdatedAt = now
+		deletion.CompletedAt = &now
+		batch.Set(uh.firestoreService.UserDeletions().Doc(deletion.ID), deletion)
+	}
+	_, err := batch.Commit(ctx)
+	return err
+}
+
+// @Summary Get user deletion progress
+// @Description Get the progress of a user deletion that transfers their fields and submissions.
+// @Description Status is running, done once the user is deleted, or failed; failed deletions can be retried.
+// @Tags users
+// @Produce  json
+// @Security ApiKeyAuth
+// @Param id path string true "User ID"
+// @Success 200 {object} models.SuccessResponse
+// @Failure 404 {object} models.ErrorResponse
+// @Router /users/{id}/deletion [get]
+func (uh *UserHandler) GetUserDeletion(c *gin.Context) {
+	currentUser, _ := c.Get("user")
+	user := currentUser.(*models.User)
+
+	ctx := uh.firestoreService.Context()
+	doc, err := uh.firestoreService.UserDeletions().Doc(c.Param("id")).Get(ctx)
+	if err != nil {
+		c.JSON(http.StatusNotFound, models.ErrorResponse{
+			Error:   "not_found",
+			Message: "No deletion found for this user",
+		})
+		return
+	}
+
+	var deletion models.UserDeletion
+	doc.DataTo(&deletion)
+	if !utils.HasPermissionIn(user, utils.PermUsersManage, deletion.OrgID) {
+		c.JSON(http.StatusNotFound, models.ErrorResponse{
+			Error:   "not_found",
+			Message: "No deletion found for this user",
+		})
+		return
+	}
+
+	c.JSON(http.StatusOK, models.SuccessResponse{
+		Success: true,
+		Data:    deletion,
+	})
+}
+
+// @Summary Restore a deleted user
+// @Description Restore a soft-deleted user before they are purged. Fields and submissions transferred
+// @Description when they were deleted stay with the user who took them over.
+// @Tags users
+// @Produce  json
+// @Security ApiKeyAuth
+// @Param id path string true "User ID"
+// @Success 200 {object} models.SuccessResponse
+// @Failure 403 {object} models.ErrorResponse
+// @Failure 404 {object} models.ErrorResponse
+// @Failure 409 {object} models.ErrorResponse
+// @Failure 500 {object} models.ErrorResponse
+// @Router /users/{id}/restore [post]
+func (uh *UserHandler) RestoreUser(c *gin.Context) {
+	userID := c.Param("id")
+	currentUser, _ := c.Get("user")
+	if !uh.canAccessUser(c, currentUser.(*models.User), userID, utils.PermUsersManage) {
+		return
+	}
+
+	user, err := uh.getUserByID(userID)
+	if err != nil {
+		c.JSON(http.StatusNotFound, models.ErrorResponse{
+			Error:   "not_found",
+			Message: "User not found",
+		})
+		return
+	}
+	if user.DeletedAt == nil {
+		c.JSON(http.StatusConflict, models.ErrorResponse{
+			Error:   "not_deleted",
+			Message: "User is not deleted",
+		})
+		return
+	}
+
+	ctx := uh.firestoreService.Context()
+	_, err = uh.firestoreService.Users().Doc(userID).Update(ctx, []firestore.Update{
+		{Path: "deleted_at", Value: firestore.Delete},
+		{Path: "deleted_by", Value: firestore.Delete},
+		{Path: "updated_at", Value: time.Now()},
+	})
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, models.ErrorResponse{
+			Error:   "internal_error",
+			Message: "Failed to restore user",
+		})
+		return
+	}
+
+	user.DeletedAt = nil
+	user.DeletedBy = ""
+	c.JSON(http.StatusOK, models.SuccessResponse{
+		Success: true,
+		Data:    user,
+		Message: "User restored successfully",
+	})
+}
@@ -0,0 +1,312 @@
+package handlers
+
+import (
+	"archive/zip"
+	"bytes"
+	"context"
+	"encoding/csv"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"time"
+
+	"rice-monitor-api/models"
+	"rice-monitor-api/utils"
+
+	"cloud.google.com/go/firestore"
+	"github.com/gin-gonic/gin"
+	"google.golang.org/api/iterator"
+)
+
+// erasedName replaces the name of an erased user wherever it was copied
+const erasedName = "Erased user"
+
+// personalData is a set of documents holding a user's personal data, and the
+// updates that anonymize them. Documents without updates are deleted.
+type personalData struct {
+	query   firestore.Query
+	updates []firestore.Update
+}
+
+// personalDataOf lists where personal data of the user is kept outside their
+// profile. Names copied onto observations, notes and reviews are replaced,
+// while the measurements themselves are kept.
+func (uh *UserHandler) personalDataOf(user *models.User, now time.Time) []personalData {
+	fs := uh.firestoreService
+	data := []personalData{
+		{fs.Submissions().Where("user_id", "==", user.ID), []firestore.Update{
+			{Path: "observer_name", Value: erasedName},
+			{Path: "updated_at", Value: now},
+		}},
+		{fs.FieldNotes().Where("author_id", "==", user.ID), []firestore.Update{
+			{Path: "author_name", Value: erasedName},
+		}},
+		{fs.ReviewDecisions().Where("reviewer_id", "==", user.ID), []firestore.Update{
+			{Path: "reviewer_name", Value: erasedName},
+		}},
+		{fs.AgreementAcceptances().Where("user_id", "==", user.ID), []firestore.Update{
+			{Path: "ip_address", Value: ""},
+		}},
+		{fs.APIKeys().Where("user_id", "==", user.ID), []firestore.Update{
+			{Path: "revoked", Value: true},
+			{Path: "revoked_at", Value: now},
+		}},
+		{fs.PersonalAccessTokens().Where("user_id", "==", user.ID), []firestore.Update{
+			{Path: "revoked", Value: true},
+			{Path: "revoked_at", Value: now},
+		}},
+		{fs.Sessions().Where("user_id", "==", user.ID), nil},
+		{fs.AuthEvents().Where("user_id", "==", user.ID), nil},
+	}
+	if user.Email != "" {
+		// Failed sign-ins are logged by address only
+		data = append(data, personalData{fs.AuthEvents().Where("email", "==", user.Email), nil})
+	}
+	return data
+}
+
+// @Summary Export a user's personal data
+// @Description Download a zip archive of everything stored about a user: their profile, notification settings,
+// @Description submissions, the URLs of their photos, sessions and sign-in history. Users can export their own data.
+// @Tags users
+// @Produce  application/zip
+// @Security ApiKeyAuth
+// @Param id path string true "User ID"
+// @Success 200 {file} file
+// @Failure 403 {object} models.ErrorResponse
+// @Failure 404 {object} models.ErrorResponse
+// @Failure 500 {object} models.ErrorResponse
+// @Router /users/{id}/export [get]
+func (uh *UserHandler) ExportUserData(c *gin.Context) {
+	userID := c.Param("id")
+	currentUser, _ := c.Get("user")
+	if !uh.canAccessUser(c, currentUser.(*models.User), userID, utils.PermUsersManage) {
+		return
+	}
+
+	user, err := uh.getUserByID(userID)
+	if err != nil {
+		c.JSON(http.StatusNotFound, models.ErrorResponse{
+			Error:   "not_found",
+			Message: "User not found",
+		})
+		return
+	}
+
+	var buf bytes.Buffer
+	if err := uh.writePersonalData(&buf, user); err != nil {
+		c.JSON(http.StatusInternalServerError, models.ErrorResponse{
+			Error:   "internal_error",
+			Message: "Failed to build export",
+		})
+		return
+	}
+
+	c.Header("Content-Disposition", fmt.Sprintf("attachment; filename=user-%s-%s.zip", user.ID, time.Now().Format("20060102")))
+	c.Data(http.StatusOK, "application/zip", buf.Bytes())
+}
+
+// writePersonalData writes the user's data as a zip archive of JSON files,
+// with the photo URLs as a CSV file
+func (uh *UserHandler) writePersonalData(buf *bytes.Buffer, user *models.User) error {
+	ctx := uh.firestoreService.Context()
+
+	settings, err := uh.notificationSettings.Get(user.ID)
+	if err != nil {
+		return err
+	}
+
+	submissions := []models.Submission{}
+	docs, err := uh.firestoreService.Submissions().Where("user_id", "==", user.ID).Documents(ctx).GetAll()
+	if err != nil {
+		return err
+	}
+	images := [][]string{{"submission_id", "date", "url"}}
+	for _, doc := range docs {
+		var submission models.Submission
+		doc.DataTo(&submission)
+		submissions = append(submissions, submission)
+		for _, url := range submission.Images {
+			images = append(images, []string{submission.ID, utils.FormatDate(submission.Date), url})
+		}
+	}
+
+	sessions := []models.Session{}
+	docs, err = uh.firestoreService.Sessions().Where("user_id", "==", user.ID).Documents(ctx).GetAll()
+	if err != nil {
+		return err
+	}
+	for _, doc := range docs {
+		var session models.Session
+		doc.DataTo(&session)
+		sessions = append(sessions, session)
+	}
+
+	events := []models.AuthEvent{}
+	docs, err = uh.firestoreService.AuthEvents().Where("user_id", "==", user.ID).Documents(ctx).GetAll()
+	if err != nil {
+		return err
+	}
+	for _, doc := range docs {
+		var event models.AuthEvent
+		doc.DataTo(&event)
+		events = append(events, event)
+	}
+
+	archive := zip.NewWriter(buf)
+	files := []struct {
+		name string
+		data interface{}
+	}{
+		{"profile.json", user},
+		{"notification_settings.json", settings},
+		{"submissions.json", submissions},
+		{"sessions.json", sessions},
+		{"sign_in_history.json", events},
+	}
+	for _, file := range files {
+		f, err := archive.Create(file.name)
+		if err != nil {
+			return err
+		}
+		encoder := json.NewEncoder(f)
+		encoder.SetIndent("", "  ")
+		if err := encoder.Encode(file.data); err != nil {
+			return err
+		}
+	}
+
+	f, err := archive.Create("images.csv")
+	if err != nil {
+		return err
+	}
+	w := csv.NewWriter(f)
+	if err := w.WriteAll(images); err != nil {
+		return err
+	}
+
+	return archive.Close()
+}
+
+// @Summary Erase a user's personal data
+// @Description Anonymize a user on request: their name, email address, phone number, photo, password and preferences
+// @Description are removed and their name is replaced on submissions, field notes and reviews. Sessions and sign-in
+// @Description history are deleted and API keys and access tokens revoked. Submissions and their measurements are kept,
+// @Description so published datasets stay valid. The account is deactivated and cannot be restored.
+// @Tags users
+// @Produce  json
+// @Security ApiKeyAuth
+// @Param id path string true "User ID"
+// @Success 200 {object} models.SuccessResponse
+// @Failure 403 {object} models.ErrorResponse
+// @Failure 404 {object} models.ErrorResponse
+// @Failure 409 {object} models.ErrorResponse
+// @Failure 500 {object} models.ErrorResponse
+// @Router /users/{id}/erase [post]
+func (uh *UserHandler) EraseUser(c *gin.Context) {
+	userID := c.Param("id")
+	currentUser, _ := c.Get("user")
+	if !uh.canAccessUser(c, currentUser.(*models.User), userID, utils.PermUsersManage) {
+		return
+	}
+
+	user, err := uh.getUserByID(userID)
+	if err != nil {
+		c.JSON(http.StatusNotFound, models.ErrorResponse{
+			Error:   "not_found",
+			Message: "User not found",
+		})
+		return
+	}
+	if user.ErasedAt != nil {
+		c.JSON(http.StatusConflict, models.ErrorResponse{
+			Error:   "already_erased",
+			Message: "User's personal data has already been erased",
+		})
+		return
+	}
+
+	// Copies of the data go first, so a failed erasure can be retried while
+	// the profile still names the user
+	ctx := uh.firestoreService.Context()
+	now := time.Now()
+	for _, data := range uh.personalDataOf(user, now) {
+		if err := uh.anonymize(ctx, data); err != nil {
+			c.JSON(http.StatusInternalServerError, models.ErrorResponse{
+				Error:   "internal_error",
+				Message: "Failed to erase the user's data, please retry",
+			})
+			return
+		}
+	}
+
+	batch := uh.firestoreService.Client.Batch()
+	batch.Update(uh.firestoreService.Users().Doc(user.ID), []firestore.Update{
+		{Path: "name", Value: erasedName},
+		{Path: "email", Value: ""},
+		{Path: "picture", Value: ""},
+		{Path: "phone", Value: firestore.Delete},
+		{Path: "password_hash", Value: firestore.Delete},
+		{Path: "preferences", Value: models.UserPreferences{}},
+		{Path: "email_verified", Value: false},
+		{Path: "deactivated", Value: true},
+		{Path: "erased_at", Value: now},
+		{Path: "tokens_valid_after", Value: now},
+		{Path: "updated_at", Value: now},
+	})
+	if user.Email != "" {
+		batch.Delete(uh.firestoreService.UserEmails().Doc(user.Email))
+	}
+	batch.Delete(uh.firestoreService.NotificationSettings().Doc(user.ID))
+	if _, err := batch.Commit(ctx); err != nil {
+		c.JSON(http.StatusInternalServerError, models.ErrorResponse{
+			Error:   "internal_error",
+			Message: "Failed to erase the user's data, please retry",
+		})
+		return
+	}
+
+	c.JSON(http.StatusOK, models.SuccessResponse{
+		Success: true,
+		Message: "User's personal data erased successfully",
+	})
+}
+
+// anonymize applies data.updates to every matching document, or deletes them,
+// in batches of transferBatchSize writes
+func (uh *UserHandler) anonymize(ctx context.Context, data personalData) error {
+	iter := data.query.Documents(ctx)
+	defer iter.Stop()
+
+	batch := uh.firestoreService.Client.Batch()
+	pending := 0
+	for {
+		doc, err := iter.Next()
+		if err == iterator.Done {
+			break
+		}
+		if err != nil {
+			return err
+		}
+
+		if data.updates == nil {
+			batch.Delete(doc.Ref)
+		} else {
+			batch.Update(doc.Ref, data.updates)
+		}
+		pending++
+		if pending == transferBatchSize {
+			if _, err := batch.Commit(ctx); err != nil {
+				return err
+			}
+			batch = uh.firestoreService.Client.Batch()
+			pending = 0
+		}
+	}
+
+	if pending == 0 {
+		return nil
+	}
+	_, err := batch.Commit(ctx)
+	return err
+}
@@ -0,0 +1,89 @@
+package handlers
+
+import (
+	"net/http"
+	"sort"
+	"strings"
+
+	"rice-monitor-api/models"
+	"rice-monitor-api/utils"
+
+	"github.com/gin-gonic/gin"
+)
+
+// @Summary Search users
+// @Description Suggest users whose name or email starts with q (case-insensitive), for picking reviewers
+// @Description and assignees. Only active users are suggested, sorted by name, with just what a picker shows.
+// @Tags users
+// @Produce  json
+// @Security ApiKeyAuth
+// @Param q query string true "Name or email prefix"
+// @Param role query string false "Only suggest users with this role"
+// @Param limit query int false "Maximum number of matches, at most 25" default(10)
+// @Success 200 {object} models.SuccessResponse
+// @Failure 400 {object} models.ErrorResponse
+// @Failure 500 {object} models.ErrorResponse
+// @Router /users/search [get]
+func (uh *UserHandler) SearchUsers(c *gin.Context) {
+	var params models.UserSearchParams
+	if err := c.ShouldBindQuery(&params); err != nil {
+		c.JSON(http.StatusBadRequest, models.ErrorResponse{
+			Error:   "invalid_request",
+			Message: err.Error(),
+		})
+		return
+	}
+	search := strings.ToLower(strings.TrimSpace(params.Query))
+	if search == "" {
+		c.JSON(http.StatusBadRequest, models.ErrorResponse{
+			Error:   "invalid_request",
+			Message: "q must not be blank",
+		})
+		return
+	}
+
+	currentUser, _ := c.Get("user")
+
+	ctx := uh.firestoreService.Context()
+	query := orgScope(uh.firestoreService.Users().Query, currentUser.(*models.User))
+	if params.Role != "" {
+		query = query.Where("role", "==", params.Role)
+	}
+
+	docs, err := query.Documents(ctx).GetAll()
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, models.ErrorResponse{
+			Error:   "internal_error",
+			Message: "Failed to search users",
+		})
+		return
+	}
+
+	matches := []models.UserMatch{}
+	for _, doc := range docs {
+		var user models.User
+		doc.DataTo(&user)
+
+		if user.DeletedAt != nil || user.Pending || utils.AccountSuspended(&user) || !userHasPrefix(&user, search) {
+			continue
+		}
+		matches = append(matches, models.UserMatch{
+			ID:      user.ID,
+			Name:    user.Name,
+			Picture: user.Picture,
+			Role:    user.Role,
+		})
+	}
+
+	sort.Slice(matches, func(i, j int) bool {
+		return strings.ToLower(matches[i].Name) < strings.ToLower(matches[j].Name)
+	})
+	if len(matches) > params.Limit {
+		matches = matches[:params.Limit]
+	}
+
+	c.JSON(http.StatusOK, models.SuccessResponse{
+		Success: true,
+		Data:    matches,
+	})
+}
@@ -0,0 +1,115 @@
+package handlers
+
+import (
+	"math"
+	"net/http"
+	"sort"
+
+	"rice-monitor-api/models"
+	"rice-monitor-api/utils"
+
+	"github.com/gin-gonic/gin"
+)
+
+// @Summary Get user contribution statistics
+// @Description Get a user's submission counts by month and status, the fields they covered, how many
+// @Description photos they took and the share of their reviewed submissions that were approved.
+// @Description Months follow the observation date in the requesting user's time zone.
+// @Tags users
+// @Produce  json
+// @Security ApiKeyAuth
+// @Param id path string true "User ID"
+// @Param since query string false "Only observations at or after this time (RFC 3339 or YYYY-MM-DD)"
+// @Param until query string false "Only observations before this time (RFC 3339 or YYYY-MM-DD)"
+// @Success 200 {object} models.SuccessResponse
+// @Failure 400 {object} models.ErrorResponse
+// @Failure 403 {object} models.ErrorResponse
+// @Failure 500 {object} models.ErrorResponse
+// @Router /users/{id}/stats [get]
+func (uh *UserHandler) GetUserStats(c *gin.Context) {
+	userID := c.Param("id")
+	currentUser, _ := c.Get("user")
+	user := currentUser.(*models.User)
+
+	if !uh.canAccessUser(c, user, userID, utils.PermUsersRead) {
+		return
+	}
+
+	loc := utils.UserLocation(user)
+	query := uh.firestoreService.Submissions().Where("user_id", "==", userID)
+	for _, bound := range []struct{ param, op string }{{"since", ">="}, {"until", "<"}} {
+		value := c.Query(bound.param)
+		if value == "" {
+			continue
+		}
+		t, err := parseEventTime(value, loc)
+		if err != nil {
+			c.JSON(http.StatusBadRequest, models.ErrorResponse{
+				Error:   "invalid_request",
+				Message: bound.param + " must be an RFC 3339 time or a YYYY-MM-DD date",
+			})
+			return
+		}
+		query = query.Where("date", bound.op, t)
+	}
+
+	ctx := uh.firestoreService.Context()
+	docs, err := query.Documents(ctx).GetAll()
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, models.ErrorResponse{
+			Error:   "internal_error",
+			Message: "Failed to retrieve statistics",
+		})
+		return
+	}
+
+	stats := models.UserStats{
+		UserID:   userID,
+		ByMonth:  []models.MonthlyCount{},
+		ByStatus: map[string]int{},
+		FieldIDs: []string{},
+	}
+	byMonth := map[string]int{}
+	for _, doc := range docs {
+		var submission models.Submission
+		doc.DataTo(&submission)
+
+		stats.Submissions++
+		byMonth[submission.Date.In(loc).Format("2006-01")]++
+		stats.ByStatus[submission.Status]++
+		stats.Images += len(submission.Images)
+		if !utils.Contains(stats.FieldIDs, submission.FieldID) {
+			stats.FieldIDs = append(stats.FieldIDs, submission.FieldID)
+		}
+		switch submission.Status {
+		case "approved":
+			stats.Approved++
+		case "rejected":
+			stats.Rejected++
+		}
+
+		date := submission.Date
+		if stats.FirstSubmission == nil || date.Before(*stats.FirstSubmission) {
+			stats.FirstSubmission = &date
+		}
+		if stats.LastSubmission == nil || date.After(*stats.LastSubmission) {
+			stats.LastSubmission = &date
+		}
+	}
+
+	for month, count := range byMonth {
+		stats.ByMonth = append(stats.ByMonth, models.MonthlyCount{Month: month, Count: count})
+	}
+	sort.Slice(stats.ByMonth, func(i, j int) bool { return stats.ByMonth[i].Month < stats.ByMonth[j].Month })
+	sort.Strings(stats.FieldIDs)
+	stats.FieldsCovered = len(stats.FieldIDs)
+	if reviewed := stats.Approved + stats.Rejected; reviewed > 0 {
+		rate := math.Round(float64(stats.Approved)/float64(reviewed)*1000) / 1000
+		stats.ApprovalRate = &rate
+	}
+
+	c.JSON(http.StatusOK, models.SuccessResponse{
+		Success: true,
+		Data:    stats,
+	})
+}
@@ -1,23 +1,37 @@
 package handlers
 
 import (
+	"context"
+	"errors"
+	"fmt"
 	"net/http"
+	"strings"
 	"time"
 
 	"rice-monitor-api/models"
 	"rice-monitor-api/services"
+	"rice-monitor-api/utils"
 
 	"cloud.google.com/go/firestore"
 	"github.com/gin-gonic/gin"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
 )
 
+// errUserNotFound is returned from transactions when the target user does not exist
+var errUserNotFound = errors.New("user not found")
+
 type UserHandler struct {
-	firestoreService *services.FirestoreService
+	firestoreService     *services.FirestoreService
+	reputationService    *services.ReputationService
+	notificationSettings *services.NotificationSettingsService
 }
 
-func NewUserHandler(firestoreService *services.FirestoreService) *UserHandler {
+func NewUserHandler(firestoreService *services.FirestoreService, reputationService *services.ReputationService, notificationSettings *services.NotificationSettingsService) *UserHandler {
 	return &UserHandler{
-		firestoreService: firestoreService,
+		firestoreService:     firestoreService,
+		reputationService:    reputationService,
+		notificationSettings: notificationSettings,
 	}
 }
 
@@ -37,11 +51,7 @@ func (uh *UserHandler) GetUser(c *gin.Context) {
 	currentUserObj := currentUser.(*models.User)
 
 	// Check if user can access this user's data
-	if currentUserObj.ID != userID && currentUserObj.Role != "admin" {
-		c.JSON(http.StatusForbidden, models.ErrorResponse{
-			Error:   "forbidden",
-			Message: "Access denied",
-		})
+	if !uh.canAccessUser(c, currentUserObj, userID, utils.PermUsersRead) {
 		return
 	}
 
@@ -60,6 +70,40 @@ func (uh *UserHandler) GetUser(c *gin.Context) {
 	})
 }
 
+// @Summary Get user reputation
+// @Description Get the community observer reputation of a user
+// @Tags users
+// @Produce  json
+// @Security ApiKeyAuth
+// @Param id path string true "User ID"
+// @Success 200 {object} models.SuccessResponse
+// @Failure 403 {object} models.ErrorResponse
+// @Failure 500 {object} models.ErrorResponse
+// @Router /users/{id}/reputation [get]
+func (uh *UserHandler) GetUserReputation(c *gin.Context) {
+	userID := c.Param("id")
+	currentUser, _ := c.Get("user")
+	currentUserObj := currentUser.(*models.User)
+
+	if !uh.canAccessUser(c, currentUserObj, userID, utils.PermUsersRead) {
+		return
+	}
+
+	reputation, err := uh.reputationService.Get(userID)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, models.ErrorResponse{
+			Error:   "internal_error",
+			Message: "Failed to retrieve reputation",
+		})
+		return
+	}
+
+	c.JSON(http.StatusOK, models.SuccessResponse{
+		Success: true,
+		Data:    reputation,
+	})
+}
+
 // @Summary Update user
 // @Description Update an existing user
 // @Tags users
@@ -79,11 +123,7 @@ func (uh *UserHandler) UpdateUser(c *gin.Context) {
 	currentUserObj := currentUser.(*models.User)
 
 	// Check if user can update this user's data
-	if currentUserObj.ID != userID && currentUserObj.Role != "admin" {
-		c.JSON(http.StatusForbidden, models.ErrorResponse{
-			Error:   "forbidden",
-			Message: "Access denied",
-		})
+	if !uh.canAccessUser(c, currentUserObj, userID, utils.PermUsersManage) {
 		return
 	}
 
@@ -99,12 +139,53 @@ func (uh *UserHandler) UpdateUser(c *gin.Context) {
 	// Remove sensitive fields
 	delete(updateData, "id")
 	delete(updateData, "email")
+	delete(updateData, "password_hash")
+	delete(updateData, "email_verified")
+	delete(updateData, "tokens_valid_after")
+	delete(updateData, "token_version")
 	delete(updateData, "created_at")
+
+	// Preferences are validated by PUT /users/:id/preferences
+	delete(updateData, "preferences")
+
+	// Membership is changed through the organization endpoints
+	delete(updateData, "org_id")
 	updateData["updated_at"] = time.Now()
 
-	// Only admin can change role
-	if currentUserObj.Role != "admin" {
-		delete(updateData, "role")
+	// Roles are changed through PUT /users/:id/role so every change is audited
+	delete(updateData, "role")
+
+	// Suspension is changed through the suspend and reactivate endpoints
+	delete(updateData, "deactivated")
+	delete(updateData, "suspended_until")
+	delete(updateData, "suspension_reason")
+
+	// Phone numbers are stored normalized so SMS senders can be matched
+	if value, ok := updateData["phone"]; ok {
+		phone, isString := value.(string)
+		if isString {
+			phone = utils.NormalizePhone(phone)
+		}
+		if !isString || (phone != "" && len(phone) < 8) {
+			c.JSON(http.StatusBadRequest, models.ErrorResponse{
+				Error:   "invalid_request",
+				Message: "phone must be a phone number in international format",
+			})
+			return
+		}
+		updateData["phone"] = phone
+	}
+
+	// Approval is granted through POST /users/:id/approve
+	delete(updateData, "pending")
+	delete(updateData, "approved_by")
+	delete(updateData, "approved_at")
+
+	// Field assignments come from invites and, like marking external
+	// collaborators, can only be changed by user managers
+	if !utils.HasPermissionIn(currentUserObj, utils.PermUsersManage, currentUserObj.OrgID) {
+		delete(updateData, "field_ids")
+		delete(updateData, "external")
 	}
 
 	ctx := uh.firestoreService.Context()
@@ -141,31 +222,503 @@ func (uh *UserHandler) UpdateUser(c *gin.Context) {
 	})
 }
 
-// @Summary Delete user
-// @Description Delete a user by their ID
+// @Summary Get user preferences
+// @Description Get a user's measurement unit, date format, timezone and language
 // @Tags users
 // @Produce  json
 // @Security ApiKeyAuth
 // @Param id path string true "User ID"
 // @Success 200 {object} models.SuccessResponse
+// @Failure 403 {object} models.ErrorResponse
+// @Failure 404 {object} models.ErrorResponse
+// @Router /users/{id}/preferences [get]
+func (uh *UserHandler) GetPreferences(c *gin.Context) {
+	userID := c.Param("id")
+	currentUser, _ := c.Get("user")
+	currentUserObj := currentUser.(*models.User)
+
+	if !uh.canAccessUser(c, currentUserObj, userID, utils.PermUsersRead) {
+		return
+	}
+
+	user, err := uh.getUserByID(userID)
+	if err != nil {
+		c.JSON(http.StatusNotFound, models.ErrorResponse{
+			Error:   "not_found",
+			Message: "User not found",
+		})
+		return
+	}
+
+	c.JSON(http.StatusOK, models.SuccessResponse{
+		Success: true,
+		Data:    user.Preferences,
+	})
+}
+
+// @Summary Update user preferences
+// @Description Replace a user's preferences. Exports and analytics use the timezone to bucket and print dates.
+// @Tags users
+// @Accept  json
+// @Produce  json
+// @Security ApiKeyAuth
+// @Param id path string true "User ID"
+// @Param preferences body models.UserPreferences true "Preferences"
+// @Success 200 {object} models.SuccessResponse
 // @Failure 400 {object} models.ErrorResponse
 // @Failure 403 {object} models.ErrorResponse
+// @Failure 404 {object} models.ErrorResponse
 // @Failure 500 {object} models.ErrorResponse
-// @Router /users/{id} [delete]
-func (uh *UserHandler) DeleteUser(c *gin.Context) {
+// @Router /users/{id}/preferences [put]
+func (uh *UserHandler) UpdatePreferences(c *gin.Context) {
+	userID := c.Param("id")
+	currentUser, _ := c.Get("user")
+	currentUserObj := currentUser.(*models.User)
+
+	if !uh.canAccessUser(c, currentUserObj, userID, utils.PermUsersManage) {
+		return
+	}
+
+	var req models.UserPreferences
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, models.ErrorResponse{
+			Error:   "invalid_request",
+			Message: err.Error(),
+		})
+		return
+	}
+	if req.Timezone != "" {
+		if _, err := time.LoadLocation(req.Timezone); err != nil {
+			c.JSON(http.StatusBadRequest, models.ErrorResponse{
+				Error:   "invalid_request",
+				Message: "timezone must be an IANA timezone name such as Asia/Dhaka",
+			})
+			return
+		}
+	}
+
+	if _, err := uh.getUserByID(userID); err != nil {
+		c.JSON(http.StatusNotFound, models.ErrorResponse{
+			Error:   "not_found",
+			Message: "User not found",
+		})
+		return
+	}
+
+	ctx := uh.firestoreService.Context()
+	_, err := uh.firestoreService.Users().Doc(userID).Update(ctx, []firestore.Update{
+		{Path: "preferences", Value: req},
+		{Path: "updated_at", Value: time.Now()},
+	})
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, models.ErrorResponse{
+			Error:   "internal_error",
+			Message: "Failed to update preferences",
+		})
+		return
+	}
+
+	c.JSON(http.StatusOK, models.SuccessResponse{
+		Success: true,
+		Data:    req,
+		Message: "Preferences updated successfully",
+	})
+}
+
+// @Summary Change user role
+// @Description Change a user's role and record who made the change
+// @Tags users
+// @Accept  json
+// @Produce  json
+// @Security ApiKeyAuth
+// @Param id path string true "User ID"
+// @Param role body models.UpdateRoleRequest true "New role"
+// @Success 200 {object} models.SuccessResponse
+// @Failure 400 {object} models.ErrorResponse
+// @Failure 403 {object} models.ErrorResponse
+// @Failure 404 {object} models.ErrorResponse
+// @Failure 500 {object} models.ErrorResponse
+// @Router /users/{id}/role [put]
+func (uh *UserHandler) UpdateUserRole(c *gin.Context) {
+	userID := c.Param("id")
+	currentUser, _ := c.Get("user")
+	currentUserObj := currentUser.(*models.User)
+
+	var req models.UpdateRoleRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, models.ErrorResponse{
+			Error:   "invalid_request",
+			Message: err.Error(),
+		})
+		return
+	}
+
+	if !utils.Contains(utils.Roles, req.Role) {
+		c.JSON(http.StatusBadRequest, models.ErrorResponse{
+			Error:   "invalid_role",
+			Message: "Role must be one of: " + strings.Join(utils.Roles, ", "),
+		})
+		return
+	}
+
+	// Prevent admins from locking themselves out
+	if currentUserObj.ID == userID {
+		c.JSON(http.StatusBadRequest, models.ErrorResponse{
+			Error:   "invalid_request",
+			Message: "Cannot change your own role",
+		})
+		return
+	}
+	if !uh.canAccessUser(c, currentUserObj, userID, utils.PermUsersManage) {
+		return
+	}
+
+	// Admins manage the whole deployment, org admins a single organization
+	target, err := uh.getUserByID(userID)
+	if err != nil {
+		c.JSON(http.StatusNotFound, models.ErrorResponse{
+			Error:   "not_found",
+			Message: "User not found",
+		})
+		return
+	}
+	if req.Role == "admin" && (currentUserObj.OrgID != "" || target.OrgID != "") {
+		c.JSON(http.StatusBadRequest, models.ErrorResponse{
+			Error:   "invalid_role",
+			Message: "Members of an organization cannot be made admin; use org_admin",
+		})
+		return
+	}
+	if req.Role == "org_admin" && target.OrgID == "" {
+		c.JSON(http.StatusBadRequest, models.ErrorResponse{
+			Error:   "invalid_role",
+			Message: "Only members of an organization can be made org_admin",
+		})
+		return
+	}
+
+	ctx := uh.firestoreService.Context()
+	userRef := uh.firestoreService.Users().Doc(userID)
+
+	var change *models.RoleChange
+	err = uh.firestoreService.Client.RunTransaction(ctx, func(ctx context.Context, tx *firestore.Transaction) error {
+		doc, err := tx.Get(userRef)
+		if status.Code(err) == codes.NotFound {
+			return errUserNotFound
+		}
+		if err != nil {
+			return err
+		}
+
+		var user models.User
+		if err := doc.DataTo(&user); err != nil {
+			return err
+		}
+		if user.Role == req.Role {
+			change = nil
+			return nil
+		}
+
+		change = &models.RoleChange{
+			ID:        utils.GenerateID(),
+			UserID:    userID,
+			OldRole:   user.Role,
+			NewRole:   req.Role,
+			ChangedBy: currentUserObj.ID,
+			ChangedAt: time.Now(),
+		}
+
+		if err := tx.Update(userRef, []firestore.Update{
+			{Path: "role", Value: req.Role},
+			{Path: "updated_at", Value: change.ChangedAt},
+		}); err != nil {
+			return err
+		}
+		return tx.Create(uh.firestoreService.RoleChanges().Doc(change.ID), change)
+	})
+	if err == errUserNotFound {
+		c.JSON(http.StatusNotFound, models.ErrorResponse{
+			Error:   "not_found",
+			Message: "User not found",
+		})
+		return
+	}
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, models.ErrorResponse{
+			Error:   "internal_error",
+			Message: "Failed to update user role",
+		})
+		return
+	}
+
+	user, err := uh.getUserByID(userID)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, models.ErrorResponse{
+			Error:   "internal_error",
+			Message: "Failed to retrieve updated user",
+		})
+		return
+	}
+
+	message := "User role updated successfully"
+	if change == nil {
+		message = "User already has this role"
+	}
+
+	c.JSON(http.StatusOK, models.SuccessResponse{
+		Success: true,
+		Data:    user,
+		Message: message,
+	})
+}
+
+// @Summary Suspend user
+// @Description Suspend a user until a given time, or deactivate the account when no end time is given.
+// @Description Suspended users are refused with 403 on every authenticated request and cannot log in.
+// @Tags users
+// @Accept  json
+// @Produce  json
+// @Security ApiKeyAuth
+// @Param id path string true "User ID"
+// @Param suspension body models.SuspendUserRequest true "Suspension details"
+// @Success 200 {object} models.SuccessResponse
+// @Failure 400 {object} models.ErrorResponse
+// @Failure 404 {object} models.ErrorResponse
+// @Failure 500 {object} models.ErrorResponse
+// @Router /users/{id}/suspend [put]
+func (uh *UserHandler) SuspendUser(c *gin.Context) {
+	userID := c.Param("id")
+	currentUser, _ := c.Get("user")
+	currentUserObj := currentUser.(*models.User)
+
+	var req models.SuspendUserRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, models.ErrorResponse{
+			Error:   "invalid_request",
+			Message: err.Error(),
+		})
+		return
+	}
+
+	if req.Until != nil && !req.Until.After(time.Now()) {
+		c.JSON(http.StatusBadRequest, models.ErrorResponse{
+			Error:   "invalid_request",
+			Message: "until must be in the future",
+		})
+		return
+	}
+
+	// Prevent admins from locking themselves out
+	if currentUserObj.ID == userID {
+		c.JSON(http.StatusBadRequest, models.ErrorResponse{
+			Error:   "invalid_request",
+			Message: "Cannot suspend your own account",
+		})
+		return
+	}
+
+	uh.setSuspension(c, userID, []firestore.Update{
+		{Path: "deactivated", Value: req.Until == nil},
+		{Path: "suspended_until", Value: req.Until},
+		{Path: "suspension_reason", Value: req.Reason},
+	}, "User suspended successfully")
+}
+
+// @Summary Reactivate user
+// @Description Lift a suspension or deactivation
+// @Tags users
+// @Produce  json
+// @Security ApiKeyAuth
+// @Param id path string true "User ID"
+// @Success 200 {object} models.SuccessResponse
+// @Failure 404 {object} models.ErrorResponse
+// @Failure 500 {object} models.ErrorResponse
+// @Router /users/{id}/reactivate [put]
+func (uh *UserHandler) ReactivateUser(c *gin.Context) {
+	uh.setSuspension(c, c.Param("id"), []firestore.Update{
+		{Path: "deactivated", Value: false},
+		{Path: "suspended_until", Value: nil},
+		{Path: "suspension_reason", Value: ""},
+	}, "User reactivated successfully")
+}
+
+// @Summary List users
+// @Description List users newest first, optionally filtered by role and active status and
+// @Description searched by name or email prefix (case-insensitive)
+// @Tags users
+// @Produce  json
+// @Security ApiKeyAuth
+// @Param page query int false "Page number" default(1)
+// @Param limit query int false "Users per page, at most 100" default(20)
+// @Param role query string false "admin, org_admin, coordinator, agronomist, researcher or observer"
+// @Param active query bool false "true for active users, false for deactivated or suspended ones"
+// @Param q query string false "Name or email prefix"
+// @Param deleted query bool false "true to list soft-deleted users instead"
+// @Success 200 {object} models.SuccessResponse
+// @Failure 400 {object} models.ErrorResponse
+// @Failure 500 {object} models.ErrorResponse
+// @Router /users [get]
+func (uh *UserHandler) GetUsers(c *gin.Context) {
+	var params models.UserListParams
+	if err := c.ShouldBindQuery(&params); err != nil {
+		c.JSON(http.StatusBadRequest, models.ErrorResponse{
+			Error:   "invalid_request",
+			Message: err.Error(),
+		})
+		return
+	}
+
+	currentUser, _ := c.Get("user")
+	users, err := uh.listUsers(currentUser.(*models.User), params)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, models.ErrorResponse{
+			Error:   "internal_error",
+			Message: "Failed to retrieve users",
+		})
+		return
+	}
+
+	total := len(users)
+	start := min((params.Page-1)*params.Limit, total)
+	end := min(start+params.Limit, total)
+
+	c.JSON(http.StatusOK, models.SuccessResponse{
+		Success: true,
+		Data: map[string]interface{}{
+			"users": users[start:end],
+			"page":  params.Page,
+			"limit": params.Limit,
+			"total": total,
+		},
+	})
+}
+
+// @Summary List pending users
+// @Description List new accounts waiting for approval, oldest first
+// @Tags users
+// @Produce  json
+// @Security ApiKeyAuth
+// @Success 200 {object} models.SuccessResponse
+// @Failure 500 {object} models.ErrorResponse
+// @Router /users/pending [get]
+func (uh *UserHandler) GetPendingUsers(c *gin.Context) {
+	currentUser, _ := c.Get("user")
+	query := orgScope(uh.firestoreService.Users().Query, currentUser.(*models.User))
+
+	ctx := uh.firestoreService.Context()
+	docs, err := query.Where("pending", "==", true).OrderBy("created_at", firestore.Asc).Documents(ctx).GetAll()
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, models.ErrorResponse{
+			Error:   "internal_error",
+			Message: "Failed to retrieve pending users",
+		})
+		return
+	}
+
+	users := []models.User{}
+	for _, doc := range docs {
+		var user models.User
+		doc.DataTo(&user)
+		if user.DeletedAt == nil {
+			users = append(users, user)
+		}
+	}
+
+	c.JSON(http.StatusOK, models.SuccessResponse{
+		Success: true,
+		Data:    users,
+	})
+}
+
+// @Summary Approve user
+// @Description Approve a pending account so it can submit data
+// @Tags users
+// @Produce  json
+// @Security ApiKeyAuth
+// @Param id path string true "User ID"
+// @Success 200 {object} models.SuccessResponse
+// @Failure 404 {object} models.ErrorResponse
+// @Failure 409 {object} models.ErrorResponse
+// @Failure 500 {object} models.ErrorResponse
+// @Router /users/{id}/approve [post]
+func (uh *UserHandler) ApproveUser(c *gin.Context) {
 	userID := c.Param("id")
 	currentUser, _ := c.Get("user")
 	currentUserObj := currentUser.(*models.User)
 
-	// Only admin can delete users
-	if currentUserObj.Role != "admin" {
-		c.JSON(http.StatusForbidden, models.ErrorResponse{
-			Error:   "forbidden",
-			Message: "Only administrators can delete users",
+	if !uh.canAccessUser(c, currentUserObj, userID, utils.PermUsersManage) {
+		return
+	}
+
+	user, err := uh.getUserByID(userID)
+	if err != nil {
+		c.JSON(http.StatusNotFound, models.ErrorResponse{
+			Error:   "not_found",
+			Message: "User not found",
+		})
+		return
+	}
+	if !user.Pending {
+		c.JSON(http.StatusConflict, models.ErrorResponse{
+			Error:   "not_pending",
+			Message: "User is not waiting for approval",
 		})
 		return
 	}
 
+	now := time.Now()
+	ctx := uh.firestoreService.Context()
+	_, err = uh.firestoreService.Users().Doc(userID).Update(ctx, []firestore.Update{
+		{Path: "pending", Value: false},
+		{Path: "approved_by", Value: currentUserObj.ID},
+		{Path: "approved_at", Value: now},
+		{Path: "updated_at", Value: now},
+	})
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, models.ErrorResponse{
+			Error:   "internal_error",
+			Message: "Failed to approve user",
+		})
+		return
+	}
+
+	user.Pending = false
+	user.ApprovedBy = currentUserObj.ID
+	user.ApprovedAt = &now
+	user.UpdatedAt = now
+
+	c.JSON(http.StatusOK, models.SuccessResponse{
+		Success: true,
+		Data:    user,
+		Message: "User approved successfully",
+	})
+}
+
+// @Summary Delete user
+// @Description Delete a user by their ID. A user who still owns fields or submissions is only deleted
+// @Description with transfer_to, the ID of a user of the same organization who takes them over. Small
+// @Description transfers finish within the request; larger ones answer 202 and run in the background,
+// @Description with progress at GET /users/{id}/deletion. The user is deleted once everything is transferred.
+// @Description Deleted users can no longer sign in and are left out of listings; they can be restored with
+// @Description POST /users/{id}/restore until they are purged, USER_PURGE_DAYS (30 by default) later.
+// @Tags users
+// @Produce  json
+// @Security ApiKeyAuth
+// @Param id path string true "User ID"
+// @Param transfer_to query string false "User who takes over the fields and submissions"
+// @Success 200 {object} models.SuccessResponse
+// @Success 202 {object} models.SuccessResponse
+// @Failure 400 {object} models.ErrorResponse
+// @Failure 403 {object} models.ErrorResponse
+// @Failure 404 {object} models.ErrorResponse
+// @Failure 409 {object} models.ErrorResponse
+// @Failure 500 {object} models.ErrorResponse
+// @Router /users/{id} [delete]
+func (uh *UserHandler) DeleteUser(c *gin.Context) {
+	userID := c.Param("id")
+	currentUser, _ := c.Get("user")
+	currentUserObj := currentUser.(*models.User)
+
 	// Prevent admin from deleting themselves
 	if currentUserObj.ID == userID {
 		c.JSON(http.StatusBadRequest, models.ErrorResponse{
@@ -174,24 +727,244 @@ func (uh *UserHandler) DeleteUser(c *gin.Context) {
 		})
 		return
 	}
+	if !uh.canAccessUser(c, currentUserObj, userID, utils.PermUsersManage) {
+		return
+	}
+
+	user, err := uh.getUserByID(userID)
+	if err != nil {
+		c.JSON(http.StatusNotFound, models.ErrorResponse{
+			Error:   "not_found",
+			Message: "User not found",
+		})
+		return
+	}
+	if user.DeletedAt != nil {
+		c.JSON(http.StatusConflict, models.ErrorResponse{
+			Error:   "already_deleted",
+			Message: "User is already deleted",
+		})
+		return
+	}
 
 	ctx := uh.firestoreService.Context()
-	_, err := uh.firestoreService.Users().Doc(userID).Delete(ctx)
+	owned, err := uh.countOwnedData(ctx, userID)
 	if err != nil {
 		c.JSON(http.StatusInternalServerError, models.ErrorResponse{
 			Error:   "internal_error",
-			Message: "Failed to delete user",
+			Message: "Failed to count the user's data",
+		})
+		return
+	}
+
+	if owned == 0 {
+		if err := uh.softDeleteUser(ctx, user, currentUserObj.ID, nil); err != nil {
+			c.JSON(http.StatusInternalServerError, models.ErrorResponse{
+				Error:   "internal_error",
+				Message: "Failed to delete user",
+			})
+			return
+		}
+
+		c.JSON(http.StatusOK, models.SuccessResponse{
+			Success: true,
+			Message: "User deleted successfully",
+		})
+		return
+	}
+
+	transferTo := c.Query("transfer_to")
+	if transferTo == "" {
+		c.JSON(http.StatusConflict, models.ErrorResponse{
+			Error:   "user_has_data",
+			Message: fmt.Sprintf("User still owns %d fields and submissions. Pass transfer_to with the ID of the user who takes them over", owned),
+		})
+		return
+	}
+
+	target, err := uh.getUserByID(transferTo)
+	if err != nil || transferTo == userID || target.DeletedAt != nil {
+		c.JSON(http.StatusBadRequest, models.ErrorResponse{
+			Error:   "invalid_transfer",
+			Message: "transfer_to must be the ID of another existing user",
+		})
+		return
+	}
+	if target.OrgID != user.OrgID {
+		c.JSON(http.StatusBadRequest, models.ErrorResponse{
+			Error:   "invalid_transfer",
+			Message: "Data can only be transferred to a user of the same organization",
+		})
+		return
+	}
+
+	deletionRef := uh.firestoreService.UserDeletions().Doc(userID)
+	if doc, err := deletionRef.Get(ctx); err == nil {
+		var previous models.UserDeletion
+		doc.DataTo(&previous)
+		if previous.Status == "running" {
+			c.JSON(http.StatusConflict, models.ErrorResponse{
+				Error:   "deletion_in_progress",
+				Message: "The user's data is already being transferred",
+			})
+			return
+		}
+	}
+
+	deletion := &models.UserDeletion{
+		ID:          userID,
+		OrgID:       user.OrgID,
+		TransferTo:  transferTo,
+		RequestedBy: currentUserObj.ID,
+		Status:      "running",
+		Total:       owned,
+		CreatedAt:   time.Now(),
+		UpdatedAt:   time.Now(),
+	}
+	if _, err := deletionRef.Set(ctx, deletion); err != nil {
+		c.JSON(http.StatusInternalServerError, models.ErrorResponse{
+			Error:   "internal_error",
+			Message: "Failed to start the deletion",
+		})
+		return
+	}
+
+	if owned > transferBatchSize {
+		started := *deletion
+		go uh.transferAndDelete(user, deletion)
+
+		c.JSON(http.StatusAccepted, models.SuccessResponse{
+			Success: true,
+			Data:    started,
+			Message: "Transferring the user's data. The user is deleted when it completes",
+		})
+		return
+	}
+
+	if err := uh.transferAndDelete(user, deletion); err != nil {
+		c.JSON(http.StatusInternalServerError, models.ErrorResponse{
+			Error:   "internal_error",
+			Message: "Failed to transfer the user's data, the user was not deleted",
 		})
 		return
 	}
 
 	c.JSON(http.StatusOK, models.SuccessResponse{
 		Success: true,
+		Data:    deletion,
 		Message: "User deleted successfully",
 	})
 }
 
+// listUsers returns the users of current's organization matching the
+// listing filters, newest first
+func (uh *UserHandler) listUsers(current *models.User, params models.UserListParams) ([]models.User, error) {
+	ctx := uh.firestoreService.Context()
+	query := orgScope(uh.firestoreService.Users().Query, current)
+	if params.Role != "" {
+		query = query.Where("role", "==", params.Role)
+	}
+
+	docs, err := query.OrderBy("created_at", firestore.Desc).Documents(ctx).GetAll()
+	if err != nil {
+		return nil, err
+	}
+
+	// Suspensions expire by time and prefixes match either field, so the
+	// remaining filters are applied here
+	search := strings.ToLower(strings.TrimSpace(params.Query))
+	users := []models.User{}
+	for _, doc := range docs {
+		var user models.User
+		doc.DataTo(&user)
+
+		if (user.DeletedAt != nil) != params.Deleted {
+			continue
+		}
+		if params.Active != nil && *params.Active == utils.AccountSuspended(&user) {
+			continue
+		}
+		if search != "" && !userHasPrefix(&user, search) {
+			continue
+		}
+		users = append(users, user)
+	}
+	return users, nil
+}
+
+// userHasPrefix reports whether the user's name or email starts with the
+// lowercase prefix
+func userHasPrefix(user *models.User, prefix string) bool {
+	return strings.HasPrefix(strings.ToLower(user.Name), prefix) ||
+		strings.HasPrefix(strings.ToLower(user.Email), prefix)
+}
+
+// canAccessUser responds with 403 and returns false unless current is the user
+// or holds perm over users of the user's organization
+func (uh *UserHandler) canAccessUser(c *gin.Context, current *models.User, userID string, perm utils.Permission) bool {
+	if current.ID == userID {
+		return true
+	}
+
+	targetOrgID := ""
+	if current.OrgID != "" {
+		if target, err := uh.getUserByID(userID); err == nil {
+			targetOrgID = target.OrgID
+		}
+	}
+	if utils.HasPermissionIn(current, perm, targetOrgID) {
+		return true
+	}
+
+	c.JSON(http.StatusForbidden, models.ErrorResponse{
+		Error:   "forbidden",
+		Message: "Access denied",
+	})
+	return false
+}
+
 // Helper function
+// setSuspension applies the suspension updates to an existing user and responds with the updated user
+func (uh *UserHandler) setSuspension(c *gin.Context, userID string, updates []firestore.Update, message string) {
+	currentUser, _ := c.Get("user")
+	if !uh.canAccessUser(c, currentUser.(*models.User), userID, utils.PermUsersManage) {
+		return
+	}
+
+	if _, err := uh.getUserByID(userID); err != nil {
+		c.JSON(http.StatusNotFound, models.ErrorResponse{
+			Error:   "not_found",
+			Message: "User not found",
+		})
+		return
+	}
+
+	ctx := uh.firestoreService.Context()
+	updates = append(updates, firestore.Update{Path: "updated_at", Value: time.Now()})
+	if _, err := uh.firestoreService.Users().Doc(userID).Update(ctx, updates); err != nil {
+		c.JSON(http.StatusInternalServerError, models.ErrorResponse{
+			Error:   "internal_error",
+			Message: "Failed to update user",
+		})
+		return
+	}
+
+	user, err := uh.getUserByID(userID)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, models.ErrorResponse{
+			Error:   "internal_error",
+			Message: "Failed to retrieve updated user",
+		})
+		return
+	}
+
+	c.JSON(http.StatusOK, models.SuccessResponse{
+		Success: true,
+		Data:    user,
+		Message: message,
+	})
+}
+
 func (uh *UserHandler) getUserByID(userID string) (*models.User, error) {
 	ctx := uh.firestoreService.Context()
 	doc, err := uh.firestoreService.Users().Doc(userID).Get(ctx)
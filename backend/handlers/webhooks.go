@@ -0,0 +1,419 @@
+package handlers
+
+import (
+	"encoding/json"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+
+	"rice-monitor-api/models"
+	"rice-monitor-api/services"
+	"rice-monitor-api/utils"
+
+	"cloud.google.com/go/firestore"
+	"github.com/gin-gonic/gin"
+)
+
+// webhookSecretPrefix marks webhook signing secrets
+const webhookSecretPrefix = "whsec_"
+
+type WebhookHandler struct {
+	firestoreService *services.FirestoreService
+	webhookService   *services.WebhookService
+}
+
+func NewWebhookHandler(firestoreService *services.FirestoreService, webhookService *services.WebhookService) *WebhookHandler {
+	return &WebhookHandler{
+		firestoreService: firestoreService,
+		webhookService:   webhookService,
+	}
+}
+
+// @Summary List webhooks
+// @Description List the webhooks submission events are delivered to. Secrets are not included.
+// @Tags webhooks
+// @Produce  json
+// @Security ApiKeyAuth
+// @Success 200 {object} models.SuccessResponse
+// @Failure 500 {object} models.ErrorResponse
+// @Router /admin/webhooks [get]
+func (wh *WebhookHandler) GetWebhooks(c *gin.Context) {
+	ctx := wh.firestoreService.Context()
+	docs, err := wh.firestoreService.Webhooks().OrderBy("created_at", firestore.Desc).Documents(ctx).GetAll()
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, models.ErrorResponse{
+			Error:   "internal_error",
+			Message: "Failed to retrieve webhooks",
+		})
+		return
+	}
+
+	webhooks := []models.Webhook{}
+	for _, doc := range docs {
+		var webhook models.Webhook
+		doc.DataTo(&webhook)
+		webhook.Secret = ""
+		webhooks = append(webhooks, webhook)
+	}
+
+	c.JSON(http.StatusOK, models.SuccessResponse{
+		Success: true,
+		Data:    webhooks,
+	})
+}
+
+// @Summary Create a webhook
+// @Description Deliver submission events to an HTTPS URL as JSON POSTs. Events: submission.created,
+// @Description submission.updated, submission.approved. The secret returned signs every delivery and is not
+// @Description shown again.
+// @Tags webhooks
+// @Accept  json
+// @Produce  json
+// @Security ApiKeyAuth
+// @Param webhook body models.WebhookRequest true "Webhook details"
+// @Success 201 {object} models.SuccessResponse
+// @Failure 400 {object} models.ErrorResponse
+// @Failure 500 {object} models.ErrorResponse
+// @Router /admin/webhooks [post]
+func (wh *WebhookHandler) CreateWebhook(c *gin.Context) {
+	req, ok := bindWebhook(c)
+	if !ok {
+		return
+	}
+
+	currentUser, _ := c.Get("user")
+	user := currentUser.(*models.User)
+
+	secret, err := utils.GenerateSecureToken(32)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, models.ErrorResponse{
+			Error:   "internal_error",
+			Message: "Failed to generate webhook secret",
+		})
+		return
+	}
+	webhook := models.Webhook{
+		ID:        utils.GenerateID(),
+		Secret:    webhookSecretPrefix + secret,
+		CreatedBy: user.ID,
+		CreatedAt: time.Now(),
+	}
+	applyWebhookRequest(&webhook, req)
+
+	ctx := wh.firestoreService.Context()
+	if _, err := wh.firestoreService.Webhooks().Doc(webhook.ID).Set(ctx, webhook); err != nil {
+		c.JSON(http.StatusInternalServerError, models.ErrorResponse{
+			Error:   "internal_error",
+			Message: "Failed to create webhook",
+		})
+		return
+	}
+
+	c.JSON(http.StatusCreated, models.SuccessResponse{
+		Success: true,
+		Data:    webhook,
+		Message: "Webhook created successfully",
+	})
+}
+
+// @Summary Update a webhook
+// @Description Replace a webhook's URL, events and settings. The secret is kept.
+// @Tags webhooks
+// @Accept  json
+// @Produce  json
+// @Security ApiKeyAuth
+// @Param id path string true "Webhook ID"
+// @Param webhook body models.WebhookRequest true "Webhook details"
+// @Success 200 {object} models.SuccessResponse
+// @Failure 400 {object} models.ErrorResponse
+// @Failure 404 {object} models.ErrorResponse
+// @Failure 500 {object} models.ErrorResponse
+// @Router /admin/webhooks/{id} [put]
+func (wh *WebhookHandler) UpdateWebhook(c *gin.Context) {
+	webhook, ok := wh.getWebhook(c)
+	if !ok {
+		return
+	}
+
+	req, ok := bindWebhook(c)
+	if !ok {
+		return
+	}
+	applyWebhookRequest(webhook, req)
+	webhook.LastError = ""
+
+	ctx := wh.firestoreService.Context()
+	if _, err := wh.firestoreService.Webhooks().Doc(webhook.ID).Set(ctx, webhook); err != nil {
+		c.JSON(http.StatusInternalServerError, models.ErrorResponse{
+			Error:   "internal_error",
+			Message: "Failed to update webhook",
+		})
+		return
+	}
+
+	webhook.Secret = ""
+	c.JSON(http.StatusOK, models.SuccessResponse{
+		Success: true,
+		Data:    webhook,
+		Message: "Webhook updated successfully",
+	})
+}
+
+// @Summary Delete a webhook
+// @Description Stop delivering events to a webhook. Pending retries are given up; the delivery log is kept.
+// @Tags webhooks
+// @Produce  json
+// @Security ApiKeyAuth
+// @Param id path string true "Webhook ID"
+// @Success 200 {object} models.SuccessResponse
+// @Failure 404 {object} models.ErrorResponse
+// @Failure 500 {object} models.ErrorResponse
+// @Router /admin/webhooks/{id} [delete]
+func (wh *WebhookHandler) DeleteWebhook(c *gin.Context) {
+	webhook, ok := wh.getWebhook(c)
+	if !ok {
+		return
+	}
+
+	ctx := wh.firestoreService.Context()
+	if _, err := wh.firestoreService.Webhooks().Doc(webhook.ID).Delete(ctx); err != nil {
+		c.JSON(http.StatusInternalServerError, models.ErrorResponse{
+			Error:   "internal_error",
+			Message: "Failed to delete webhook",
+		})
+		return
+	}
+
+	c.JSON(http.StatusOK, models.SuccessResponse{
+		Success: true,
+		Message: "Webhook deleted successfully",
+	})
+}
+
+// @Summary Rotate a webhook's secret
+// @Description Replace the secret deliveries are signed with. The new secret is returned once and applies at once,
+// @Description including to pending retries.
+// @Tags webhooks
+// @Produce  json
+// @Security ApiKeyAuth
+// @Param id path string true "Webhook ID"
+// @Success 200 {object} models.SuccessResponse
+// @Failure 404 {object} models.ErrorResponse
+// @Failure 500 {object} models.ErrorResponse
+// @Router /admin/webhooks/{id}/rotate-secret [post]
+func (wh *WebhookHandler) RotateWebhookSecret(c *gin.Context) {
+	webhook, ok := wh.getWebhook(c)
+	if !ok {
+		return
+	}
+
+	secret, err := utils.GenerateSecureToken(32)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, models.ErrorResponse{
+			Error:   "internal_error",
+			Message: "Failed to generate webhook secret",
+		})
+		return
+	}
+	webhook.Secret = webhookSecretPrefix + secret
+	webhook.UpdatedAt = time.Now()
+
+	ctx := wh.firestoreService.Context()
+	_, err = wh.firestoreService.Webhooks().Doc(webhook.ID).Update(ctx, []firestore.Update{
+		{Path: "secret", Value: webhook.Secret},
+		{Path: "updated_at", Value: webhook.UpdatedAt},
+	})
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, models.ErrorResponse{
+			Error:   "internal_error",
+			Message: "Failed to rotate webhook secret",
+		})
+		return
+	}
+
+	c.JSON(http.StatusOK, models.SuccessResponse{
+		Success: true,
+		Data:    webhook,
+		Message: "Webhook secret rotated successfully",
+	})
+}
+
+// @Summary Test a webhook
+// @Description Send a signed ping event to the webhook and report the response. Pings are not logged.
+// @Tags webhooks
+// @Produce  json
+// @Security ApiKeyAuth
+// @Param id path string true "Webhook ID"
+// @Success 200 {object} models.SuccessResponse
+// @Failure 404 {object} models.ErrorResponse
+// @Failure 502 {object} models.ErrorResponse
+// @Router /admin/webhooks/{id}/test [post]
+func (wh *WebhookHandler) TestWebhook(c *gin.Context) {
+	webhook, ok := wh.getWebhook(c)
+	if !ok {
+		return
+	}
+
+	id := utils.GenerateID()
+	payload, _ := json.Marshal(map[string]interface{}{
+		"id":         id,
+		"event":      "ping",
+		"created_at": time.Now(),
+		"data":       map[string]string{"webhook_id": webhook.ID},
+	})
+	code, err := wh.webhookService.Send(webhook, id, "ping", payload)
+	if err != nil {
+		c.JSON(http.StatusBadGateway, models.ErrorResponse{
+			Error:   "webhook_failed",
+			Message: err.Error(),
+		})
+		return
+	}
+
+	c.JSON(http.StatusOK, models.SuccessResponse{
+		Success: true,
+		Data:    map[string]int{"response_code": code},
+		Message: "Test event delivered successfully",
+	})
+}
+
+// @Summary List webhook deliveries
+// @Description List the latest deliveries to a webhook, newest first, with their attempts and outcome
+// @Tags webhooks
+// @Produce  json
+// @Security ApiKeyAuth
+// @Param id path string true "Webhook ID"
+// @Param status query string false "pending, delivered or failed"
+// @Param limit query int false "Deliveries to return, 50 by default and at most 200"
+// @Success 200 {object} models.SuccessResponse
+// @Failure 400 {object} models.ErrorResponse
+// @Failure 500 {object} models.ErrorResponse
+// @Router /admin/webhooks/{id}/deliveries [get]
+func (wh *WebhookHandler) GetWebhookDeliveries(c *gin.Context) {
+	limit, err := strconv.Atoi(c.DefaultQuery("limit", "50"))
+	if err != nil || limit <= 0 {
+		limit = 50
+	}
+	limit = min(limit, 200)
+
+	query := wh.firestoreService.WebhookDeliveries().Where("webhook_id", "==", c.Param("id"))
+	if status := c.Query("status"); status != "" {
+		if status != "pending" && status != "delivered" && status != "failed" {
+			c.JSON(http.StatusBadRequest, models.ErrorResponse{
+				Error:   "invalid_request",
+				Message: "status must be pending, delivered or failed",
+			})
+			return
+		}
+		query = query.Where("status", "==", status)
+	}
+
+	ctx := wh.firestoreService.Context()
+	docs, err := query.OrderBy("created_at", firestore.Desc).Limit(limit).Documents(ctx).GetAll()
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, models.ErrorResponse{
+			Error:   "internal_error",
+			Message: "Failed to retrieve deliveries",
+		})
+		return
+	}
+
+	deliveries := []models.WebhookDelivery{}
+	for _, doc := range docs {
+		var delivery models.WebhookDelivery
+		doc.DataTo(&delivery)
+		deliveries = append(deliveries, delivery)
+	}
+
+	c.JSON(http.StatusOK, models.SuccessResponse{
+		Success: true,
+		Data:    deliveries,
+	})
+}
+
+// @Summary Redeliver a webhook delivery
+// @Description Send a delivery again, such as one given up after its retries, with the same ID and payload
+// @Tags webhooks
+// @Produce  json
+// @Security ApiKeyAuth
+// @Param id path string true "Webhook ID"
+// @Param deliveryId path string true "Delivery ID"
+// @Success 202 {object} models.SuccessResponse
+// @Failure 404 {object} models.ErrorResponse
+// @Failure 500 {object} models.ErrorResponse
+// @Router /admin/webhooks/{id}/deliveries/{deliveryId}/redeliver [post]
+func (wh *WebhookHandler) RedeliverWebhookDelivery(c *gin.Context) {
+	ctx := wh.firestoreService.Context()
+	doc, err := wh.firestoreService.WebhookDeliveries().Doc(c.Param("deliveryId")).Get(ctx)
+	var delivery models.WebhookDelivery
+	if err == nil {
+		doc.DataTo(&delivery)
+	}
+	if err != nil || delivery.WebhookID != c.Param("id") {
+		c.JSON(http.StatusNotFound, models.ErrorResponse{
+			Error:   "not_found",
+			Message: "Delivery not found",
+		})
+		return
+	}
+
+	if err := wh.webhookService.Redeliver(&delivery); err != nil {
+		c.JSON(http.StatusInternalServerError, models.ErrorResponse{
+			Error:   "internal_error",
+			Message: "Failed to queue delivery",
+		})
+		return
+	}
+
+	c.JSON(http.StatusAccepted, models.SuccessResponse{
+		Success: true,
+		Data:    delivery,
+		Message: "Delivery queued, it is sent within a minute",
+	})
+}
+
+func (wh *WebhookHandler) getWebhook(c *gin.Context) (*models.Webhook, bool) {
+	ctx := wh.firestoreService.Context()
+	doc, err := wh.firestoreService.Webhooks().Doc(c.Param("id")).Get(ctx)
+	if err != nil {
+		c.JSON(http.StatusNotFound, models.ErrorResponse{
+			Error:   "not_found",
+			Message: "Webhook not found",
+		})
+		return nil, false
+	}
+
+	var webhook models.Webhook
+	doc.DataTo(&webhook)
+	return &webhook, true
+}
+
+// bindWebhook binds the request and checks the URL is HTTPS
+func bindWebhook(c *gin.Context) (*models.WebhookRequest, bool) {
+	var req models.WebhookRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, models.ErrorResponse{
+			Error:   "invalid_request",
+			Message: err.Error(),
+		})
+		return nil, false
+	}
+
+	if !strings.HasPrefix(req.URL, "https://") {
+		c.JSON(http.StatusBadRequest, models.ErrorResponse{
+			Error:   "invalid_webhook_url",
+			Message: "A webhook URL must start with https://",
+		})
+		return nil, false
+	}
+	return &req, true
+}
+
+func applyWebhookRequest(webhook *models.Webhook, req *models.WebhookRequest) {
+	webhook.Name = req.Name
+	webhook.URL = req.URL
+	webhook.Events = req.Events
+	webhook.Enabled = req.Enabled == nil || *req.Enabled
+	webhook.UpdatedAt = time.Now()
+}
@@ -1,40 +1,77 @@
 package middleware
 
 import (
+	"fmt"
+	"log"
 	"net/http"
 	"strings"
+	"time"
 
 	"rice-monitor-api/models"
 	"rice-monitor-api/services"
 	"rice-monitor-api/utils"
 
+	"cloud.google.com/go/firestore"
 	"github.com/gin-gonic/gin"
 )
 
+// apiKeyUsageInterval is how often last_used_at is refreshed for an API key
+const apiKeyUsageInterval = time.Minute
+
 type AuthMiddleware struct {
-	firestoreService *services.FirestoreService
+	firestoreService     *services.FirestoreService
+	dataAgreementService *services.DataAgreementService
 }
 
-func NewAuthMiddleware(firestoreService *services.FirestoreService) *AuthMiddleware {
+func NewAuthMiddleware(firestoreService *services.FirestoreService, dataAgreementService *services.DataAgreementService) *AuthMiddleware {
 	return &AuthMiddleware{
-		firestoreService: firestoreService,
+		firestoreService:     firestoreService,
+		dataAgreementService: dataAgreementService,
 	}
 }
 
 func (am *AuthMiddleware) RequireAuth() gin.HandlerFunc {
 	return func(c *gin.Context) {
-		authHeader := c.GetHeader("Authorization")
-		if authHeader == "" {
-			c.JSON(http.StatusUnauthorized, models.ErrorResponse{
-				Error:   "unauthorized",
-				Message: "Authorization header required",
-			})
-			c.Abort()
+		// Programmatic clients authenticate with an API key instead of a JWT
+		if apiKey := c.GetHeader("X-API-Key"); apiKey != "" {
+			user, err := am.getUserByAPIKey(apiKey)
+			if err != nil {
+				c.JSON(http.StatusUnauthorized, models.ErrorResponse{
+					Error:   "unauthorized",
+					Message: "Invalid API key",
+				})
+				c.Abort()
+				return
+			}
+
+			if rejectSuspended(c, user) {
+				return
+			}
+
+			c.Set("user", user)
+			c.Set("user_id", user.ID)
+			c.Set("user_role", user.Role)
+			c.Set("auth_method", "api_key")
+			c.Next()
 			return
 		}
 
+		authMethod := "jwt"
+		authHeader := c.GetHeader("Authorization")
 		tokenString := strings.TrimPrefix(authHeader, "Bearer ")
-		if tokenString == authHeader {
+		if authHeader == "" {
+			// Browsers in cookie session mode send the access token as a cookie
+			cookie, err := c.Cookie(utils.AccessTokenCookie)
+			if err != nil || !utils.CookieSessionMode() {
+				c.JSON(http.StatusUnauthorized, models.ErrorResponse{
+					Error:   "unauthorized",
+					Message: "Authorization header required",
+				})
+				c.Abort()
+				return
+			}
+			tokenString, authMethod = cookie, "cookie"
+		} else if tokenString == authHeader {
 			c.JSON(http.StatusUnauthorized, models.ErrorResponse{
 				Error:   "unauthorized",
 				Message: "Bearer token required",
@@ -43,6 +80,12 @@ func (am *AuthMiddleware) RequireAuth() gin.HandlerFunc {
 			return
 		}
 
+		// Scripts send personal access tokens as Bearer tokens
+		if authMethod == "jwt" && strings.HasPrefix(tokenString, utils.PersonalAccessTokenPrefix) {
+			am.authenticatePersonalToken(c, tokenString)
+			return
+		}
+
 		claims, err := utils.ValidateToken(tokenString)
 		if err != nil {
 			c.JSON(http.StatusUnauthorized, models.ErrorResponse{
@@ -53,6 +96,11 @@ func (am *AuthMiddleware) RequireAuth() gin.HandlerFunc {
 			return
 		}
 
+		if claims.Kind == "service_account" {
+			am.authenticateServiceAccount(c, claims)
+			return
+		}
+
 		// Get user from database
 		user, err := am.getUserByID(claims.UserID)
 		if err != nil {
@@ -64,14 +112,176 @@ func (am *AuthMiddleware) RequireAuth() gin.HandlerFunc {
 			return
 		}
 
+		if utils.TokenRevoked(claims, user) {
+			c.JSON(http.StatusUnauthorized, models.ErrorResponse{
+				Error:   "unauthorized",
+				Message: "Token has been revoked",
+			})
+			c.Abort()
+			return
+		}
+
+		if claims.SessionID != "" && am.sessionRevoked(claims.SessionID) {
+			c.JSON(http.StatusUnauthorized, models.ErrorResponse{
+				Error:   "unauthorized",
+				Message: "Session has been revoked",
+			})
+			c.Abort()
+			return
+		}
+
+		if rejectSuspended(c, user) {
+			return
+		}
+
 		c.Set("user", user)
 		c.Set("user_id", user.ID)
 		c.Set("user_role", user.Role)
+		c.Set("auth_method", authMethod)
+		c.Set("session_id", claims.SessionID)
 		c.Next()
 	}
 }
 
-func (am *AuthMiddleware) RequireAdmin() gin.HandlerFunc {
+// authenticateServiceAccount admits a client credentials token when the account
+// is still enabled and its scopes open the requested route. Handlers see the
+// account as a user with the permissionless service role.
+func (am *AuthMiddleware) authenticateServiceAccount(c *gin.Context, claims *models.Claims) {
+	ctx := am.firestoreService.Context()
+	doc, err := am.firestoreService.ServiceAccounts().Doc(claims.UserID).Get(ctx)
+	if err != nil {
+		c.JSON(http.StatusUnauthorized, models.ErrorResponse{
+			Error:   "unauthorized",
+			Message: "Service account not found",
+		})
+		c.Abort()
+		return
+	}
+
+	var account models.ServiceAccount
+	doc.DataTo(&account)
+	if account.Disabled {
+		c.JSON(http.StatusUnauthorized, models.ErrorResponse{
+			Error:   "unauthorized",
+			Message: "Service account is disabled",
+		})
+		c.Abort()
+		return
+	}
+
+	// Scopes are read from the account, not the token, so narrowing them takes effect immediately
+	if !utils.ScopeAllows(account.Scopes, c.Request.Method, c.FullPath()) {
+		c.JSON(http.StatusForbidden, models.ErrorResponse{
+			Error:   "insufficient_scope",
+			Message: "Service account scopes do not allow this request",
+		})
+		c.Abort()
+		return
+	}
+
+	user := &models.User{
+		ID:   account.ID,
+		Name: account.Name,
+		Role: utils.ServiceAccountRole,
+	}
+
+	c.Set("user", user)
+	c.Set("user_id", user.ID)
+	c.Set("user_role", user.Role)
+	c.Set("auth_method", "service_account")
+	c.Set("scopes", account.Scopes)
+	c.Next()
+}
+
+// authenticatePersonalToken admits a personal access token that is neither
+// revoked nor expired when its scopes open the requested route. Handlers see
+// the token's owner, so the owner's permissions still apply.
+func (am *AuthMiddleware) authenticatePersonalToken(c *gin.Context, rawToken string) {
+	ctx := am.firestoreService.Context()
+	docs, err := am.firestoreService.PersonalAccessTokens().
+		Where("token_hash", "==", utils.HashToken(rawToken)).
+		Limit(1).
+		Documents(ctx).GetAll()
+	if err != nil || len(docs) == 0 {
+		c.JSON(http.StatusUnauthorized, models.ErrorResponse{
+			Error:   "unauthorized",
+			Message: "Invalid token",
+		})
+		c.Abort()
+		return
+	}
+
+	var token models.PersonalAccessToken
+	docs[0].DataTo(&token)
+	if token.Revoked || time.Now().After(token.ExpiresAt) {
+		c.JSON(http.StatusUnauthorized, models.ErrorResponse{
+			Error:   "unauthorized",
+			Message: "Token has expired or been revoked",
+		})
+		c.Abort()
+		return
+	}
+
+	if !utils.ScopeAllows(token.Scopes, c.Request.Method, c.FullPath()) {
+		c.JSON(http.StatusForbidden, models.ErrorResponse{
+			Error:   "insufficient_scope",
+			Message: "Token scopes do not allow this request",
+		})
+		c.Abort()
+		return
+	}
+
+	user, err := am.getUserByID(token.UserID)
+	if err != nil {
+		c.JSON(http.StatusUnauthorized, models.ErrorResponse{
+			Error:   "unauthorized",
+			Message: "User not found",
+		})
+		c.Abort()
+		return
+	}
+	if rejectSuspended(c, user) {
+		return
+	}
+
+	// Usage is recorded at most once a minute, as for API keys
+	if token.LastUsedAt == nil || time.Since(*token.LastUsedAt) > apiKeyUsageInterval {
+		ref := docs[0].Ref
+		go func() {
+			_, err := ref.Update(ctx, []firestore.Update{
+				{Path: "last_used_at", Value: time.Now()},
+			})
+			if err != nil {
+				log.Printf("Failed to update personal access token last use: %v", err)
+			}
+		}()
+	}
+
+	c.Set("user", user)
+	c.Set("user_id", user.ID)
+	c.Set("user_role", user.Role)
+	c.Set("auth_method", "personal_token")
+	c.Set("scopes", token.Scopes)
+	c.Next()
+}
+
+// rejectSuspended aborts with 403 when the user is deactivated or suspended
+func rejectSuspended(c *gin.Context, user *models.User) bool {
+	if !utils.AccountSuspended(user) {
+		return false
+	}
+
+	c.JSON(http.StatusForbidden, models.ErrorResponse{
+		Error:   "account_suspended",
+		Message: utils.SuspensionMessage(user),
+	})
+	c.Abort()
+	return true
+}
+
+// RequirePermission aborts with 403 unless the authenticated user's role grants perm.
+// Handlers still check that the records they touch are in the user's organization.
+func (am *AuthMiddleware) RequirePermission(perm utils.Permission) gin.HandlerFunc {
 	return func(c *gin.Context) {
 		user, exists := c.Get("user")
 		if !exists {
@@ -84,15 +294,105 @@ func (am *AuthMiddleware) RequireAdmin() gin.HandlerFunc {
 		}
 
 		userObj := user.(*models.User)
-		if userObj.Role != "admin" {
+		if !utils.HasPermissionIn(userObj, perm, userObj.OrgID) {
 			c.JSON(http.StatusForbidden, models.ErrorResponse{
 				Error:   "forbidden",
-				Message: "Admin access required",
+				Message: "Missing permission " + string(perm),
+			})
+			c.Abort()
+			return
+		}
+
+		c.Next()
+	}
+}
+
+// RequireApproved aborts with 403 while the authenticated user is waiting for
+// an admin to approve their account
+func (am *AuthMiddleware) RequireApproved() gin.HandlerFunc {
+	return func(c *gin.Context) {
+		user, exists := c.Get("user")
+		if !exists {
+			c.JSON(http.StatusUnauthorized, models.ErrorResponse{
+				Error:   "unauthorized",
+				Message: "User not found in context",
+			})
+			c.Abort()
+			return
+		}
+
+		if user.(*models.User).Pending {
+			c.JSON(http.StatusForbidden, models.ErrorResponse{
+				Error:   "approval_pending",
+				Message: "Your account is waiting for approval by an administrator",
+			})
+			c.Abort()
+			return
+		}
+
+		c.Next()
+	}
+}
+
+// RequireAgreement aborts with 403 when the authenticated user is an external
+// collaborator who has not accepted the current data access agreement of their
+// organization. The accepted agreement's ID is stored as "agreement_id" so
+// exports can be stamped with it.
+func (am *AuthMiddleware) RequireAgreement() gin.HandlerFunc {
+	return func(c *gin.Context) {
+		user, exists := c.Get("user")
+		if !exists {
+			c.JSON(http.StatusUnauthorized, models.ErrorResponse{
+				Error:   "unauthorized",
+				Message: "User not found in context",
+			})
+			c.Abort()
+			return
+		}
+
+		userObj := user.(*models.User)
+		if !userObj.External {
+			c.Next()
+			return
+		}
+
+		agreement, err := am.dataAgreementService.Current(userObj.OrgID)
+		if err != nil {
+			c.JSON(http.StatusInternalServerError, models.ErrorResponse{
+				Error:   "internal_error",
+				Message: "Failed to check the data access agreement",
+			})
+			c.Abort()
+			return
+		}
+		if agreement == nil {
+			c.JSON(http.StatusForbidden, models.ErrorResponse{
+				Error:   "agreement_required",
+				Message: "No data access agreement has been published for your organization yet",
 			})
 			c.Abort()
 			return
 		}
 
+		acceptance, err := am.dataAgreementService.Acceptance(userObj.ID, agreement)
+		if err != nil {
+			c.JSON(http.StatusInternalServerError, models.ErrorResponse{
+				Error:   "internal_error",
+				Message: "Failed to check the data access agreement",
+			})
+			c.Abort()
+			return
+		}
+		if acceptance == nil {
+			c.JSON(http.StatusForbidden, models.ErrorResponse{
+				Error:   "agreement_required",
+				Message: fmt.Sprintf("Accept version %d of the data access agreement before exporting data", agreement.Version),
+			})
+			c.Abort()
+			return
+		}
+
+		c.Set("agreement_id", agreement.ID)
 		c.Next()
 	}
 }
@@ -112,3 +412,57 @@ func (am *AuthMiddleware) getUserByID(userID string) (*models.User, error) {
 
 	return &user, nil
 }
+
+func (am *AuthMiddleware) getUserByAPIKey(rawKey string) (*models.User, error) {
+	ctx := am.firestoreService.Context()
+	docs, err := am.firestoreService.APIKeys().
+		Where("key_hash", "==", utils.HashToken(rawKey)).
+		Where("revoked", "==", false).
+		Limit(1).
+		Documents(ctx).GetAll()
+	if err != nil {
+		return nil, err
+	}
+	if len(docs) == 0 {
+		return nil, fmt.Errorf("api key not found")
+	}
+
+	var key models.APIKey
+	if err := docs[0].DataTo(&key); err != nil {
+		return nil, err
+	}
+
+	user, err := am.getUserByID(key.UserID)
+	if err != nil {
+		return nil, err
+	}
+
+	// Scripts call in tight loops, so only record usage about once a minute
+	// to stay clear of Firestore's per-document write limit
+	if key.LastUsedAt == nil || time.Since(*key.LastUsedAt) > apiKeyUsageInterval {
+		ref := docs[0].Ref
+		go func() {
+			_, err := ref.Update(ctx, []firestore.Update{
+				{Path: "last_used_at", Value: time.Now()},
+			})
+			if err != nil {
+				log.Printf("Failed to update API key last use: %v", err)
+			}
+		}()
+	}
+
+	return user, nil
+}
+
+// sessionRevoked reports whether the session was revoked or can no longer be read
+func (am *AuthMiddleware) sessionRevoked(sessionID string) bool {
+	ctx := am.firestoreService.Context()
+	doc, err := am.firestoreService.Sessions().Doc(sessionID).Get(ctx)
+	if err != nil {
+		return true
+	}
+
+	var session models.Session
+	doc.DataTo(&session)
+	return session.Revoked
+}
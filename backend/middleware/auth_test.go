@@ -0,0 +1,45 @@
+package middleware
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"rice-monitor-api/models"
+	"rice-monitor-api/utils"
+
+	"github.com/gin-gonic/gin"
+)
+
+func TestRequireAuthRejectsRefreshToken(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+
+	accessToken, refreshToken, err := utils.GenerateTokens(&models.User{ID: "user-1", Role: "observer"}, "session-1")
+	if err != nil {
+		t.Fatalf("GenerateTokens: %v", err)
+	}
+
+	// Refused before the user is looked up, so no Firestore is needed
+	router := gin.New()
+	router.GET("/protected", NewAuthMiddleware(nil, nil).RequireAuth(), func(c *gin.Context) {
+		c.Status(http.StatusOK)
+	})
+
+	req := httptest.NewRequest(http.MethodGet, "/protected", nil)
+	req.Header.Set("Authorization", "Bearer "+refreshToken)
+	w := httptest.NewRecorder()
+	router.ServeHTTP(w, req)
+	if w.Code != http.StatusUnauthorized {
+		t.Fatalf("refresh token as bearer: got %d, want %d", w.Code, http.StatusUnauthorized)
+	}
+
+	if _, err := utils.ValidateRefreshToken(refreshToken); err != nil {
+		t.Fatalf("refresh token rejected for refresh: %v", err)
+	}
+	if _, err := utils.ValidateRefreshToken(accessToken); err == nil {
+		t.Fatal("access token accepted for refresh")
+	}
+	if _, err := utils.ValidateToken(accessToken); err != nil {
+		t.Fatalf("access token rejected: %v", err)
+	}
+}
@@ -1,6 +1,7 @@
 package middleware
 
 import (
+	"strings"
 	"time"
 
 	"github.com/gin-contrib/cors"
@@ -12,11 +13,21 @@ func CORSMiddleware() gin.HandlerFunc {
 	config := cors.Config{
 		AllowOrigins:     []string{"http://localhost:3000", "http://localhost:8080", "https://rice-monitor.com", "https://www.rice-monitor.com"},
 		AllowMethods:     []string{"GET", "POST", "PUT", "DELETE", "OPTIONS"},
-		AllowHeaders:     []string{"Origin", "Content-Type", "Authorization"},
-		ExposeHeaders:    []string{"Content-Length"},
+		AllowHeaders:     []string{"Origin", "Content-Type", "Authorization", "X-API-Key", "X-CSRF-Token", "Idempotency-Key"},
+		ExposeHeaders:    []string{"Content-Length", "Idempotent-Replayed"},
 		AllowCredentials: true,
 		MaxAge:           12 * time.Hour,
 	}
 
-	return cors.New(config)
+	handler := cors.New(config)
+
+	return func(c *gin.Context) {
+		// Embedded widgets are read by partner sites; their handler checks the
+		// origin against the embed token instead
+		if strings.HasPrefix(c.Request.URL.Path, "/api/v1/embed/") {
+			c.Next()
+			return
+		}
+		handler(c)
+	}
 }
@@ -0,0 +1,136 @@
+package middleware
+
+import (
+	"math"
+	"net/http"
+	"os"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"rice-monitor-api/models"
+
+	"github.com/gin-gonic/gin"
+)
+
+// RateLimiter limits how many requests each client IP can make per period.
+// A client that exceeds the limit is locked out for the lockout duration.
+// Counts are kept in memory, so each instance limits independently.
+type RateLimiter struct {
+	limit   int
+	period  time.Duration
+	lockout time.Duration
+
+	mu      sync.Mutex
+	clients map[string]*rateWindow
+	swept   time.Time
+}
+
+// rateWindow counts a client's requests in the current period
+type rateWindow struct {
+	count       int
+	resetAt     time.Time
+	lockedUntil time.Time
+}
+
+func NewRateLimiter(limit int, period, lockout time.Duration) *RateLimiter {
+	return &RateLimiter{
+		limit:   limit,
+		period:  period,
+		lockout: lockout,
+		clients: make(map[string]*rateWindow),
+		swept:   time.Now(),
+	}
+}
+
+// NewAuthRateLimiter limits the auth endpoints to AUTH_RATE_LIMIT requests per
+// minute per IP (default 20), locking out for AUTH_LOCKOUT_MINUTES (default 15)
+func NewAuthRateLimiter() *RateLimiter {
+	limit, err := strconv.Atoi(os.Getenv("AUTH_RATE_LIMIT"))
+	if err != nil || limit <= 0 {
+		limit = 20
+	}
+	minutes, err := strconv.Atoi(os.Getenv("AUTH_LOCKOUT_MINUTES"))
+	if err != nil || minutes <= 0 {
+		minutes = 15
+	}
+	return NewRateLimiter(limit, time.Minute, time.Duration(minutes)*time.Minute)
+}
+
+// ConfigureClientIP sets which proxies gin believes about the client IP. Rate
+// limits and the auth log are keyed by it, and gin otherwise takes
+// X-Forwarded-For from anyone, so a client could pick a new IP per request.
+// Only the comma separated TRUSTED_PROXIES (IPs or CIDR ranges of the load
+// balancer) are trusted, none by default, and TRUSTED_PLATFORM names a
+// header the platform sets to the client IP, such as CF-Connecting-IP.
+func ConfigureClientIP(router *gin.Engine) error {
+	var proxies []string
+	for _, proxy := range strings.Split(os.Getenv("TRUSTED_PROXIES"), ",") {
+		if proxy = strings.TrimSpace(proxy); proxy != "" {
+			proxies = append(proxies, proxy)
+		}
+	}
+	if err := router.SetTrustedProxies(proxies); err != nil {
+		return err
+	}
+	router.TrustedPlatform = os.Getenv("TRUSTED_PLATFORM")
+	return nil
+}
+
+// Limit aborts with 429 and a Retry-After header once the client IP is over the limit
+func (rl *RateLimiter) Limit() gin.HandlerFunc {
+	return func(c *gin.Context) {
+		retryAfter := rl.allow(c.ClientIP(), time.Now())
+		if retryAfter <= 0 {
+			c.Next()
+			return
+		}
+
+		c.Header("Retry-After", strconv.Itoa(int(math.Ceil(retryAfter.Seconds()))))
+		c.JSON(http.StatusTooManyRequests, models.ErrorResponse{
+			Error:   "rate_limited",
+			Message: "Too many requests, try again later",
+		})
+		c.Abort()
+	}
+}
+
+// allow counts a request from key and returns how long the client must wait,
+// or zero when the request is allowed
+func (rl *RateLimiter) allow(key string, now time.Time) time.Duration {
+	rl.mu.Lock()
+	defer rl.mu.Unlock()
+
+	rl.sweep(now)
+
+	window, ok := rl.clients[key]
+	if !ok || (now.After(window.resetAt) && now.After(window.lockedUntil)) {
+		window = &rateWindow{resetAt: now.Add(rl.period)}
+		rl.clients[key] = window
+	}
+	if now.Before(window.lockedUntil) {
+		return window.lockedUntil.Sub(now)
+	}
+
+	window.count++
+	if window.count > rl.limit {
+		window.lockedUntil = now.Add(rl.lockout)
+		return rl.lockout
+	}
+	return 0
+}
+
+// sweep drops clients whose window and lockout have both ended, at most once a period
+func (rl *RateLimiter) sweep(now time.Time) {
+	if now.Sub(rl.swept) < rl.period {
+		return
+	}
+	rl.swept = now
+
+	for key, window := range rl.clients {
+		if now.After(window.resetAt) && now.After(window.lockedUntil) {
+			delete(rl.clients, key)
+		}
+	}
+}
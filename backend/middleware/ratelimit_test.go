@@ -0,0 +1,67 @@
+package middleware
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/gin-gonic/gin"
+)
+
+func TestLimitIgnoresForgedForwardedFor(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+	t.Setenv("TRUSTED_PROXIES", "")
+	t.Setenv("TRUSTED_PLATFORM", "")
+
+	router := gin.New()
+	if err := ConfigureClientIP(router); err != nil {
+		t.Fatalf("ConfigureClientIP: %v", err)
+	}
+	router.POST("/login", NewRateLimiter(2, time.Minute, 15*time.Minute).Limit(), func(c *gin.Context) {
+		c.Status(http.StatusOK)
+	})
+
+	forged := []string{"10.0.0.1", "10.0.0.2", "10.0.0.3", "10.0.0.4"}
+	for i, ip := range forged {
+		req := httptest.NewRequest(http.MethodPost, "/login", nil)
+		req.RemoteAddr = "203.0.113.7:4321"
+		req.Header.Set("X-Forwarded-For", ip)
+		w := httptest.NewRecorder()
+		router.ServeHTTP(w, req)
+
+		want := http.StatusOK
+		if i >= 2 {
+			want = http.StatusTooManyRequests
+		}
+		if w.Code != want {
+			t.Fatalf("request %d with X-Forwarded-For %s: got %d, want %d", i+1, ip, w.Code, want)
+		}
+	}
+}
+
+func TestLimitTrustsConfiguredProxy(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+	t.Setenv("TRUSTED_PROXIES", "203.0.113.0/24")
+	t.Setenv("TRUSTED_PLATFORM", "")
+
+	router := gin.New()
+	if err := ConfigureClientIP(router); err != nil {
+		t.Fatalf("ConfigureClientIP: %v", err)
+	}
+	router.POST("/login", NewRateLimiter(1, time.Minute, 15*time.Minute).Limit(), func(c *gin.Context) {
+		c.Status(http.StatusOK)
+	})
+
+	// Clients behind the load balancer are limited separately
+	for _, ip := range []string{"198.51.100.1", "198.51.100.2"} {
+		req := httptest.NewRequest(http.MethodPost, "/login", nil)
+		req.RemoteAddr = "203.0.113.7:4321"
+		req.Header.Set("X-Forwarded-For", ip)
+		w := httptest.NewRecorder()
+		router.ServeHTTP(w, req)
+		if w.Code != http.StatusOK {
+			t.Fatalf("client %s: got %d, want %d", ip, w.Code, http.StatusOK)
+		}
+	}
+}
@@ -0,0 +1,64 @@
+package middleware
+
+import (
+	"context"
+	"fmt"
+	"log"
+	"net/http"
+	"runtime/debug"
+	"time"
+
+	"rice-monitor-api/models"
+	"rice-monitor-api/services"
+
+	"github.com/gin-gonic/gin"
+)
+
+// Recovery turns a panic in any handler into a 500 error response and sends
+// the panic, stack trace, request and user ID to the error reporter
+func Recovery(reporter services.ErrorReporter) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		defer func() {
+			r := recover()
+			if r == nil {
+				return
+			}
+
+			report := &services.ErrorReport{
+				Message:   fmt.Sprintf("panic: %v", r),
+				Stack:     string(debug.Stack()),
+				Method:    c.Request.Method,
+				URL:       c.Request.URL.String(),
+				UserAgent: c.Request.UserAgent(),
+				RemoteIP:  c.ClientIP(),
+				Time:      time.Now(),
+			}
+			if currentUser, ok := c.Get("user"); ok {
+				if user, ok := currentUser.(*models.User); ok {
+					report.UserID = user.ID
+				}
+			}
+			log.Printf("%s %s: %s\n%s", report.Method, report.URL, report.Message, report.Stack)
+
+			// Reporting must not hold up the response
+			go func() {
+				ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+				defer cancel()
+				if err := reporter.Report(ctx, report); err != nil {
+					log.Printf("Failed to report panic: %v", err)
+				}
+			}()
+
+			if c.Writer.Written() {
+				c.Abort()
+				return
+			}
+			c.AbortWithStatusJSON(http.StatusInternalServerError, models.ErrorResponse{
+				Error:   "internal_error",
+				Message: "An unexpected error occurred",
+			})
+		}()
+
+		c.Next()
+	}
+}
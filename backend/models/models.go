@@ -1,6 +1,7 @@
 package models
 
 import (
+	"encoding/json"
 	"time"
 
 	"github.com/golang-jwt/jwt/v4"
@@ -8,14 +9,69 @@ import (
 
 // User represents a user in the system
 type User struct {
-	ID          string    `json:"id" firestore:"id"`
-	Email       string    `json:"email" firestore:"email"`
-	Name        string    `json:"name" firestore:"name"`
-	Picture     string    `json:"picture" firestore:"picture"`
-	Role        string    `json:"role" firestore:"role"` // admin, researcher, observer
-	CreatedAt   time.Time `json:"created_at" firestore:"created_at"`
-	UpdatedAt   time.Time `json:"updated_at" firestore:"updated_at"`
-	LastLoginAt time.Time `json:"last_login_at" firestore:"last_login_at"`
+	ID               string          `json:"id" firestore:"id"`
+	Email            string          `json:"email" firestore:"email"`
+	Name             string          `json:"name" firestore:"name"`
+	Picture          string          `json:"picture" firestore:"picture"`
+	Phone            string          `json:"phone,omitempty" firestore:"phone,omitempty"`   // E.164, matched against SMS senders
+	Role             string          `json:"role" firestore:"role"`                         // admin, org_admin, coordinator, agronomist, researcher, observer
+	OrgID            string          `json:"org_id,omitempty" firestore:"org_id,omitempty"` // organization the user belongs to, if any
+	PasswordHash     string          `json:"-" firestore:"password_hash,omitempty"`
+	EmailVerified    bool            `json:"email_verified" firestore:"email_verified"`
+	TokensValidAfter time.Time       `json:"-" firestore:"tokens_valid_after"`                      // JWTs issued earlier are rejected
+	TokenVersion     int             `json:"-" firestore:"token_version"`                           // bumped by logout-all; JWTs carrying another version are rejected
+	FieldIDs         []string        `json:"field_ids,omitempty" firestore:"field_ids,omitempty"`   // fields assigned by an invite or POST /fields/:id/observers
+	External         bool            `json:"external" firestore:"external"`                         // external collaborator, must accept the data access agreement before exporting
+	Deactivated      bool            `json:"deactivated" firestore:"deactivated"`                   // blocked until reactivated
+	SuspendedUntil   *time.Time      `json:"suspended_until,omitempty" firestore:"suspended_until"` // blocked until this time
+	SuspensionReason string          `json:"suspension_reason,omitempty" firestore:"suspension_reason"`
+	Pending          bool            `json:"pending" firestore:"pending"` // new observer waiting for approval before submitting data
+	ApprovedBy       string          `json:"approved_by,omitempty" firestore:"approved_by,omitempty"`
+	ApprovedAt       *time.Time      `json:"approved_at,omitempty" firestore:"approved_at,omitempty"`
+	DeletedAt        *time.Time      `json:"deleted_at,omitempty" firestore:"deleted_at,omitempty"` // soft-deleted, purged once the purge window has passed
+	DeletedBy        string          `json:"deleted_by,omitempty" firestore:"deleted_by,omitempty"`
+	ErasedAt         *time.Time      `json:"erased_at,omitempty" firestore:"erased_at,omitempty"` // personal data erased on request, kept only as an anonymous observer
+	Preferences      UserPreferences `json:"preferences" firestore:"preferences"`
+	CreatedAt        time.Time       `json:"created_at" firestore:"created_at"`
+	UpdatedAt        time.Time       `json:"updated_at" firestore:"updated_at"`
+	LastLoginAt      time.Time       `json:"last_login_at" firestore:"last_login_at"`
+}
+
+// UserPreferences controls how a user's exports and analytics are presented.
+// Empty values fall back to centimetres, YYYY-MM-DD, UTC and English.
+type UserPreferences struct {
+	LengthUnit string `json:"length_unit,omitempty" firestore:"length_unit,omitempty" binding:"omitempty,oneof=cm inch"`
+	DateFormat string `json:"date_format,omitempty" firestore:"date_format,omitempty" binding:"omitempty,oneof=YYYY-MM-DD DD/MM/YYYY MM/DD/YYYY"`
+	Timezone   string `json:"timezone,omitempty" firestore:"timezone,omitempty"` // IANA name, e.g. Asia/Dhaka
+	Language   string `json:"language,omitempty" firestore:"language,omitempty" binding:"omitempty,oneof=en bn"`
+}
+
+// NotificationSettings controls which notifications a user receives. Users
+// who never saved settings get the defaults of NotificationSettingsService.
+type NotificationSettings struct {
+	UserID           string          `json:"user_id" firestore:"user_id"`
+	EmailOnApproval  bool            `json:"email_on_approval" firestore:"email_on_approval"`
+	EmailOnRejection bool            `json:"email_on_rejection" firestore:"email_on_rejection"`
+	WeeklyDigest     bool            `json:"weekly_digest" firestore:"weekly_digest"`
+	DigestDay        string          `json:"digest_day" firestore:"digest_day"` // lowercase weekday the digest is sent on
+	Alerts           AlertThresholds `json:"alerts" firestore:"alerts"`
+	UpdatedAt        time.Time       `json:"updated_at" firestore:"updated_at"` // zero for defaults
+}
+
+// AlertThresholds are the levels at which a user is alerted. Zero turns an alert off.
+type AlertThresholds struct {
+	PendingReviews   int `json:"pending_reviews" firestore:"pending_reviews" binding:"min=0"`       // submissions waiting for review
+	QualityIssues    int `json:"quality_issues" firestore:"quality_issues" binding:"min=0"`         // issues in a quality report
+	DaysWithoutVisit int `json:"days_without_visit" firestore:"days_without_visit" binding:"min=0"` // days since an assigned field was last observed
+}
+
+// NotificationSettingsRequest updates notification settings. Omitted values are left unchanged.
+type NotificationSettingsRequest struct {
+	EmailOnApproval  *bool            `json:"email_on_approval"`
+	EmailOnRejection *bool            `json:"email_on_rejection"`
+	WeeklyDigest     *bool            `json:"weekly_digest"`
+	DigestDay        *string          `json:"digest_day" binding:"omitempty,oneof=monday tuesday wednesday thursday friday saturday sunday"`
+	Alerts           *AlertThresholds `json:"alerts"`
 }
 
 // Field represents a rice field
@@ -25,42 +81,915 @@ type Field struct {
 	Location    string    `json:"location" firestore:"location"`
 	RiceVariety    string    `json:"rice_variety" firestore:"rice_variety"`
 	TentativeDate    string    `json:"tentative_date" firestore:"tentative_date"`
+	TransplantDate string `json:"transplant_date,omitempty" firestore:"transplant_date,omitempty"` // YYYY-MM-DD, used to predict growth stages
+	CumulativeGDD float64 `json:"cumulative_gdd" firestore:"cumulative_gdd"` // growing degree days since transplanting, through WeatherThrough
+	WeatherFrom string `json:"-" firestore:"weather_from,omitempty"` // transplant date the backfill started from
+	WeatherThrough string `json:"weather_through,omitempty" firestore:"weather_through,omitempty"` // last day with backfilled weather
+	StressEventIDs []string `json:"stress_event_ids,omitempty" firestore:"stress_event_ids,omitempty"` // drought and flood events covering the field
+	SMSCode     string    `json:"sms_code,omitempty" firestore:"sms_code,omitempty"` // digits identifying the field in SMS observations
+	MonitoringCadenceDays int `json:"monitoring_cadence_days,omitempty" firestore:"monitoring_cadence_days,omitempty"` // days expected between visits, MONITORING_CADENCE_DAYS when zero
+	Notes       []FieldNote `json:"notes,omitempty" firestore:"-"` // current notes, only filled in on the field detail
 	Coordinates Location  `json:"coordinates" firestore:"coordinates"`
-	Area        float64   `json:"area" firestore:"area"` // in hectares
+	Geohash     string    `json:"geohash,omitempty" firestore:"geohash,omitempty"` // of the coordinates, for nearby searches
+	Boundary    []Location `json:"boundary,omitempty" firestore:"boundary,omitempty"` // polygon outlining the field, drawn in KML exports
+	BoundaryGeoJSON *GeoJSONPolygon `json:"boundary_geojson,omitempty" firestore:"-"` // the boundary for map libraries, filled in responses
+	Area        float64   `json:"area" firestore:"area"` // in hectares, computed from the boundary when the field has one
 	OwnerID     string    `json:"owner_id" firestore:"owner_id"`
+	OrgID       string    `json:"org_id,omitempty" firestore:"org_id,omitempty"` // owner's organization when the field was created
+	FarmID      string    `json:"farm_id,omitempty" firestore:"farm_id,omitempty"` // site the field belongs to
+	Collaborators []FieldCollaborator `json:"collaborators,omitempty" firestore:"collaborators,omitempty"` // users the field is shared with
+	CollaboratorIDs []string `json:"-" firestore:"collaborator_ids,omitempty"` // user IDs of the collaborators, to list the fields shared with a user
+	SearchTerms []string `json:"-" firestore:"search_terms,omitempty"` // prefixes of the name and location, to search fields
+	Archived    bool      `json:"archived" firestore:"archived"` // no longer monitored: hidden from the field list and closed to submissions
+	ArchivedAt  *time.Time `json:"archived_at,omitempty" firestore:"archived_at,omitempty"`
+	ArchivedBy  string    `json:"archived_by,omitempty" firestore:"archived_by,omitempty"`
+	CreatedAt   time.Time `json:"created_at" firestore:"created_at"`
+	UpdatedAt   time.Time `json:"updated_at" firestore:"updated_at"`
+}
+
+// Organization is a research group sharing the deployment. Members only see
+// fields and submissions created within their organization.
+type Organization struct {
+	ID          string    `json:"id" firestore:"id"`
+	Name        string    `json:"name" firestore:"name"`
+	Description string    `json:"description,omitempty" firestore:"description,omitempty"`
+	CreatedBy   string    `json:"created_by" firestore:"created_by"`
 	CreatedAt   time.Time `json:"created_at" firestore:"created_at"`
 	UpdatedAt   time.Time `json:"updated_at" firestore:"updated_at"`
 }
 
+// Farm is a site grouping fields, such as a research station or a farmer's
+// holding
+type Farm struct {
+	ID        string    `json:"id" firestore:"id"`
+	Name      string    `json:"name" firestore:"name"`
+	Region    string    `json:"region,omitempty" firestore:"region,omitempty"`
+	ManagerID string    `json:"manager_id,omitempty" firestore:"manager_id,omitempty"` // user who runs the site and may edit it
+	OrgID     string    `json:"org_id,omitempty" firestore:"org_id,omitempty"`
+	Fields    []Field   `json:"fields,omitempty" firestore:"-"` // the farm's fields, only filled in on the farm detail
+	CreatedBy string    `json:"created_by" firestore:"created_by"`
+	CreatedAt time.Time `json:"created_at" firestore:"created_at"`
+	UpdatedAt time.Time `json:"updated_at" firestore:"updated_at"`
+}
+
+// FarmRequest creates or updates a farm
+type FarmRequest struct {
+	Name      string `json:"name" binding:"required,max=200"`
+	Region    string `json:"region" binding:"max=200"`
+	ManagerID string `json:"manager_id"`
+}
+
+// OrganizationRequest creates or renames an organization
+type OrganizationRequest struct {
+	Name        string `json:"name" binding:"required,max=100"`
+	Description string `json:"description" binding:"max=500"`
+}
+
+// DataAgreement is one version of an organization's data access agreement.
+// External collaborators must accept the current version before exporting data.
+type DataAgreement struct {
+	ID        string    `json:"id" firestore:"id"`
+	OrgID     string    `json:"org_id" firestore:"org_id"` // empty for users outside every organization
+	Version   int       `json:"version" firestore:"version"`
+	Title     string    `json:"title" firestore:"title"`
+	Text      string    `json:"text" firestore:"text"`
+	CreatedBy string    `json:"created_by" firestore:"created_by"`
+	CreatedAt time.Time `json:"created_at" firestore:"created_at"`
+}
+
+// DataAgreementRequest publishes a new version of a data access agreement
+type DataAgreementRequest struct {
+	OrgID string `json:"org_id"` // only for admins outside every organization; others publish for their own
+	Title string `json:"title" binding:"required,max=200"`
+	Text  string `json:"text" binding:"required,max=50000"`
+}
+
+// AcceptAgreementRequest accepts the version of the agreement the user was shown
+type AcceptAgreementRequest struct {
+	Version int `json:"version" binding:"required,min=1"`
+}
+
+// AgreementAcceptance records that a user accepted a data agreement version
+type AgreementAcceptance struct {
+	ID          string    `json:"id" firestore:"id"` // agreement ID and user ID
+	AgreementID string    `json:"agreement_id" firestore:"agreement_id"`
+	OrgID       string    `json:"org_id" firestore:"org_id"`
+	Version     int       `json:"version" firestore:"version"`
+	UserID      string    `json:"user_id" firestore:"user_id"`
+	IPAddress   string    `json:"ip_address" firestore:"ip_address"`
+	AcceptedAt  time.Time `json:"accepted_at" firestore:"accepted_at"`
+}
+
+// FieldNote is a note or pinned announcement on a field, e.g. "do not enter
+// until 5 Aug - spraying"
+type FieldNote struct {
+	ID         string     `json:"id" firestore:"id"`
+	FieldID    string     `json:"field_id" firestore:"field_id"`
+	Text       string     `json:"text" firestore:"text"`
+	Pinned     bool       `json:"pinned" firestore:"pinned"`                   // announcements are pinned by the field owner
+	ExpiresAt  *time.Time `json:"expires_at,omitempty" firestore:"expires_at"` // hidden after this time
+	AuthorID   string     `json:"author_id" firestore:"author_id"`
+	AuthorName string     `json:"author_name" firestore:"author_name"`
+	CreatedAt  time.Time  `json:"created_at" firestore:"created_at"`
+	UpdatedAt  time.Time  `json:"updated_at" firestore:"updated_at"`
+}
+
+// TodayOverview is what the current user should know before visiting their fields today
+type TodayOverview struct {
+	Date   string       `json:"date"`
+	Fields []TodayField `json:"fields"`
+}
+
+// TodayField is one of the user's fields with its current notes and open critical windows
+type TodayField struct {
+	Field       Field            `json:"field"`
+	Notes       []FieldNote      `json:"notes"`
+	OpenWindows []CriticalWindow `json:"open_windows,omitempty"`
+}
+
+// StressEvent is a regional drought or flood declared by an admin
+type StressEvent struct {
+	ID          string     `json:"id" firestore:"id"`
+	Type        string     `json:"type" firestore:"type"` // drought, flood
+	Name        string     `json:"name" firestore:"name"`
+	Description string     `json:"description" firestore:"description"`
+	StartDate   time.Time  `json:"start_date" firestore:"start_date"`
+	EndDate     time.Time  `json:"end_date" firestore:"end_date"`
+	Area        []Location `json:"area" firestore:"area"`           // polygon vertices
+	FieldIDs    []string   `json:"field_ids" firestore:"field_ids"` // fields inside the area when the event was declared
+	CreatedBy   string     `json:"created_by" firestore:"created_by"`
+	CreatedAt   time.Time  `json:"created_at" firestore:"created_at"`
+}
+
+// TraitTrajectoryPoint is the average trait measurements of a group of fields
+// in one week relative to a stress event
+type TraitTrajectoryPoint struct {
+	Week            int     `json:"week"` // weeks since the event started, negative before it
+	Submissions     int     `json:"submissions"`
+	CulmLength      float64 `json:"culm_length"`
+	PanicleLength   float64 `json:"panicle_length"`
+	PaniclesPerHill float64 `json:"panicles_per_hill"`
+}
+
+// HillMeasurement is one visit's measurement of a marked hill
+type HillMeasurement struct {
+	SubmissionID    string    `json:"submission_id"`
+	Date            time.Time `json:"date"`
+	GrowthStage     string    `json:"growth_stage"`
+	CulmLength      float64   `json:"culm_length"`
+	PanicleLength   float64   `json:"panicle_length"`
+	PaniclesPerHill int       `json:"panicles_per_hill"`
+}
+
+// HillTrajectory lists the measurements of one marked hill or quadrat in date order
+type HillTrajectory struct {
+	HillID       string            `json:"hill_id"`
+	Measurements []HillMeasurement `json:"measurements"`
+}
+
+// StressImpactReport compares the trait trajectories of fields affected by a
+// stress event with the fields outside it
+type StressImpactReport struct {
+	Event            StressEvent            `json:"event"`
+	AffectedFields   int                    `json:"affected_fields"`
+	UnaffectedFields int                    `json:"unaffected_fields"`
+	Affected         []TraitTrajectoryPoint `json:"affected"`
+	Unaffected       []TraitTrajectoryPoint `json:"unaffected"`
+	GeneratedAt      time.Time              `json:"generated_at"`
+}
+
+// DailyWeather is one day of weather at a field, with its growing degree days
+type DailyWeather struct {
+	FieldID       string  `json:"field_id" firestore:"field_id"`
+	Date          string  `json:"date" firestore:"date"` // YYYY-MM-DD
+	TempMax       float64 `json:"temp_max" firestore:"temp_max"`
+	TempMin       float64 `json:"temp_min" firestore:"temp_min"`
+	Precipitation float64 `json:"precipitation" firestore:"precipitation"` // mm
+	GDD           float64 `json:"gdd" firestore:"gdd"`
+}
+
+// WeatherSnapshot is the weather when an observation was made, either reported
+// by the device or looked up for the hour of the observation
+type WeatherSnapshot struct {
+	TemperatureC  *float64 `json:"temperature_c,omitempty" firestore:"temperature_c,omitempty" binding:"omitempty,min=-50,max=60"`
+	HumidityPct   *float64 `json:"humidity_pct,omitempty" firestore:"humidity_pct,omitempty" binding:"omitempty,min=0,max=100"`
+	RainfallMM    *float64 `json:"rainfall_mm,omitempty" firestore:"rainfall_mm,omitempty" binding:"omitempty,min=0"`         // in the hour of the observation
+	Rainfall24hMM *float64 `json:"rainfall_24h_mm,omitempty" firestore:"rainfall_24h_mm,omitempty" binding:"omitempty,min=0"` // in the 24 hours up to the observation
+	Source        string   `json:"source" firestore:"source"`                                                                 // device, open-meteo
+}
+
+// DeviceMetadata is what the client reported about the device a submission
+// was made on, kept for provenance so systematic data issues can be traced to
+// specific app versions or devices
+type DeviceMetadata struct {
+	DeviceID     string `json:"device_id,omitempty" firestore:"device_id,omitempty" binding:"max=100"` // stable install ID, matched by device calibrations
+	AppVersion   string `json:"app_version,omitempty" firestore:"app_version,omitempty" binding:"max=50"`
+	DeviceModel  string `json:"device_model,omitempty" firestore:"device_model,omitempty" binding:"max=100"`
+	OS           string `json:"os,omitempty" firestore:"os,omitempty" binding:"max=50"`
+	OSVersion    string `json:"os_version,omitempty" firestore:"os_version,omitempty" binding:"max=50"`
+	Connectivity string `json:"connectivity,omitempty" firestore:"connectivity,omitempty" binding:"omitempty,oneof=wifi cellular offline unknown"` // offline for queued uploads
+	UserAgent    string `json:"user_agent,omitempty" firestore:"user_agent,omitempty"`                                                             // recorded by the server
+}
+
+// AppVersionStats summarizes the review outcomes of one app version's submissions
+type AppVersionStats struct {
+	AppVersion    string    `json:"app_version"` // "unknown" for clients that did not report one
+	Platforms     []string  `json:"platforms"`
+	Submissions   int       `json:"submissions"`
+	Approved      int       `json:"approved"`
+	Rejected      int       `json:"rejected"`
+	RejectionRate float64   `json:"rejection_rate"` // share of reviewed submissions that were rejected
+	FirstSeen     time.Time `json:"first_seen"`
+	LastSeen      time.Time `json:"last_seen"`
+}
+
+// CriticalWindow is the predicted period of a growth stage whose traits can
+// only be measured while it lasts
+type CriticalWindow struct {
+	Stage string    `json:"stage"`
+	Start time.Time `json:"start"`
+	End   time.Time `json:"end"`
+}
+
+// VisitReminder records a reminder sent for a field's critical window
+type VisitReminder struct {
+	ID          string    `json:"id" firestore:"id"`
+	FieldID     string    `json:"field_id" firestore:"field_id"`
+	Stage       string    `json:"stage" firestore:"stage"`
+	WindowStart time.Time `json:"window_start" firestore:"window_start"`
+	WindowEnd   time.Time `json:"window_end" firestore:"window_end"`
+	Recipients  []string  `json:"recipients" firestore:"recipients"`
+	CreatedAt   time.Time `json:"created_at" firestore:"created_at"`
+}
+
+// OverdueField is a field that went longer than its monitoring cadence
+// without an observation
+type OverdueField struct {
+	FieldID        string     `json:"field_id"`
+	FieldName      string     `json:"field_name"`
+	Location       string     `json:"location"`
+	OwnerID        string     `json:"owner_id"`
+	CadenceDays    int        `json:"cadence_days"`     // days expected between visits
+	LastVisitAt    *time.Time `json:"last_visit_at"`    // date of the latest observation, null when never observed
+	DaysSinceVisit int        `json:"days_since_visit"` // counted from the transplant date or creation when never observed
+	DueAt          time.Time  `json:"due_at"`           // when the next visit was due
+}
+
+// OverdueNotice records an overdue visit email sent to one of a field's monitors
+type OverdueNotice struct {
+	ID          string     `json:"id" firestore:"id"`
+	FieldID     string     `json:"field_id" firestore:"field_id"`
+	UserID      string     `json:"user_id" firestore:"user_id"`
+	LastVisitAt *time.Time `json:"last_visit_at" firestore:"last_visit_at"`
+	Days        int        `json:"days" firestore:"days"` // days without a visit when notified
+	CreatedAt   time.Time  `json:"created_at" firestore:"created_at"`
+}
+
+// APIKey represents a key used by scripts to call the API on behalf of a user
+type APIKey struct {
+	ID         string     `json:"id" firestore:"id"`
+	UserID     string     `json:"user_id" firestore:"user_id"`
+	Name       string     `json:"name" firestore:"name"`
+	Prefix     string     `json:"prefix" firestore:"prefix"` // first characters of the key, for display
+	KeyHash    string     `json:"-" firestore:"key_hash"`    // SHA-256 of the full key
+	Revoked    bool       `json:"revoked" firestore:"revoked"`
+	CreatedAt  time.Time  `json:"created_at" firestore:"created_at"`
+	LastUsedAt *time.Time `json:"last_used_at,omitempty" firestore:"last_used_at"`
+	RevokedAt  *time.Time `json:"revoked_at,omitempty" firestore:"revoked_at"`
+}
+
+// PersonalAccessToken lets a user's own scripts call the API with a subset of
+// their permissions until it expires or is revoked
+type PersonalAccessToken struct {
+	ID         string     `json:"id" firestore:"id"`
+	UserID     string     `json:"user_id" firestore:"user_id"`
+	Name       string     `json:"name" firestore:"name"`
+	Prefix     string     `json:"prefix" firestore:"prefix"` // first characters of the token, for display
+	TokenHash  string     `json:"-" firestore:"token_hash"`  // SHA-256 of the full token
+	Scopes     []string   `json:"scopes" firestore:"scopes"` // routes the token opens, as for service accounts
+	Revoked    bool       `json:"revoked" firestore:"revoked"`
+	CreatedAt  time.Time  `json:"created_at" firestore:"created_at"`
+	ExpiresAt  time.Time  `json:"expires_at" firestore:"expires_at"`
+	LastUsedAt *time.Time `json:"last_used_at,omitempty" firestore:"last_used_at"`
+	RevokedAt  *time.Time `json:"revoked_at,omitempty" firestore:"revoked_at"`
+}
+
+// Dataset represents a curated release of approved submissions for open data portals
+type Dataset struct {
+	ID          string     `json:"id" firestore:"id"`
+	Title       string     `json:"title" firestore:"title"`
+	Description string     `json:"description" firestore:"description"`
+	Keywords    []string   `json:"keywords" firestore:"keywords"`
+	License     string     `json:"license" firestore:"license"` // SPDX identifier or license URL
+	Publisher   string     `json:"publisher" firestore:"publisher"`
+	FieldIDs    []string   `json:"field_ids" firestore:"field_ids"`       // empty means all fields
+	StartDate   string     `json:"start_date" firestore:"start_date"`     // YYYY-MM-DD, optional
+	EndDate     string     `json:"end_date" firestore:"end_date"`         // YYYY-MM-DD, optional
+	Status      string     `json:"status" firestore:"status"`             // draft, published
+	RecordCount int        `json:"record_count" firestore:"record_count"` // rows in the published snapshot
+	CKANID      string     `json:"ckan_id,omitempty" firestore:"ckan_id"`
+	CreatedBy   string     `json:"created_by" firestore:"created_by"`
+	CreatedAt   time.Time  `json:"created_at" firestore:"created_at"`
+	UpdatedAt   time.Time  `json:"updated_at" firestore:"updated_at"`
+	PublishedAt *time.Time `json:"published_at,omitempty" firestore:"published_at"`
+}
+
+// PasswordReset represents a pending password reset request
+type PasswordReset struct {
+	ID        string    `json:"id" firestore:"id"`
+	UserID    string    `json:"user_id" firestore:"user_id"`
+	TokenHash string    `json:"-" firestore:"token_hash"`
+	Used      bool      `json:"used" firestore:"used"`
+	ExpiresAt time.Time `json:"expires_at" firestore:"expires_at"`
+	CreatedAt time.Time `json:"created_at" firestore:"created_at"`
+}
+
+// MagicLink represents a pending passwordless login link
+type MagicLink struct {
+	ID        string    `json:"id" firestore:"id"`
+	UserID    string    `json:"user_id" firestore:"user_id"`
+	TokenHash string    `json:"-" firestore:"token_hash"`
+	Used      bool      `json:"used" firestore:"used"`
+	ExpiresAt time.Time `json:"expires_at" firestore:"expires_at"`
+	CreatedAt time.Time `json:"created_at" firestore:"created_at"`
+}
+
+// EmailVerification represents a pending email address verification
+type EmailVerification struct {
+	ID        string    `json:"id" firestore:"id"`
+	UserID    string    `json:"user_id" firestore:"user_id"`
+	TokenHash string    `json:"-" firestore:"token_hash"`
+	Used      bool      `json:"used" firestore:"used"`
+	ExpiresAt time.Time `json:"expires_at" firestore:"expires_at"`
+	CreatedAt time.Time `json:"created_at" firestore:"created_at"`
+}
+
+// AnalyticsJob represents a heavy analytics query processed by the background workers
+type AnalyticsJob struct {
+	ID          string            `json:"id" firestore:"id"`
+	UserID      string            `json:"user_id" firestore:"user_id"`
+	OrgID       string            `json:"org_id,omitempty" firestore:"org_id,omitempty"` // requester's organization
+	Type        string            `json:"type" firestore:"type"`                         // trends, report
+	Params      map[string]string `json:"params" firestore:"params"`
+	Status      string            `json:"status" firestore:"status"` // queued, running, done, failed
+	Estimated   int64             `json:"estimated_documents" firestore:"estimated_documents"`
+	Error       string            `json:"error,omitempty" firestore:"error"`
+	ResultPath  string            `json:"-" firestore:"result_path"` // JSON result object in the storage bucket
+	Result      json.RawMessage   `json:"result,omitempty" firestore:"-"`
+	CreatedAt   time.Time         `json:"created_at" firestore:"created_at"`
+	StartedAt   *time.Time        `json:"started_at,omitempty" firestore:"started_at"`
+	CompletedAt *time.Time        `json:"completed_at,omitempty" firestore:"completed_at"`
+}
+
+// UserDeletion tracks the deletion of a user whose fields and submissions are
+// transferred to another user first. It is keyed by the deleted user's ID.
+type UserDeletion struct {
+	ID          string     `json:"id" firestore:"id"` // the deleted user's ID
+	OrgID       string     `json:"org_id,omitempty" firestore:"org_id,omitempty"`
+	TransferTo  string     `json:"transfer_to" firestore:"transfer_to"`
+	RequestedBy string     `json:"requested_by" firestore:"requested_by"`
+	Status      string     `json:"status" firestore:"status"` // running, done, failed
+	Total       int        `json:"total" firestore:"total"`   // fields and submissions to transfer
+	Transferred int        `json:"transferred" firestore:"transferred"`
+	Error       string     `json:"error,omitempty" firestore:"error"`
+	CreatedAt   time.Time  `json:"created_at" firestore:"created_at"`
+	UpdatedAt   time.Time  `json:"updated_at" firestore:"updated_at"`
+	CompletedAt *time.Time `json:"completed_at,omitempty" firestore:"completed_at"`
+}
+
+// RoleChange is an audit record of a user's role being changed
+type RoleChange struct {
+	ID        string    `json:"id" firestore:"id"`
+	UserID    string    `json:"user_id" firestore:"user_id"`
+	OldRole   string    `json:"old_role" firestore:"old_role"`
+	NewRole   string    `json:"new_role" firestore:"new_role"`
+	ChangedBy string    `json:"changed_by" firestore:"changed_by"`
+	ChangedAt time.Time `json:"changed_at" firestore:"changed_at"`
+}
+
+// Reputation scores a community observer from review outcomes and agreement
+// with nearby research-grade observations
+type Reputation struct {
+	UserID           string    `json:"user_id" firestore:"user_id"`
+	Score            float64   `json:"score" firestore:"score"` // 0-100
+	Tier             string    `json:"tier" firestore:"tier"`   // low, new, standard, trusted
+	ApprovedCount    int       `json:"approved_count" firestore:"approved_count"`
+	RejectedCount    int       `json:"rejected_count" firestore:"rejected_count"`
+	AgreementChecks  int       `json:"agreement_checks" firestore:"agreement_checks"`
+	AgreementMatches int       `json:"agreement_matches" firestore:"agreement_matches"`
+	UpdatedAt        time.Time `json:"updated_at" firestore:"updated_at"`
+}
+
+// UserStats summarizes a user's contributions
+type UserStats struct {
+	UserID          string         `json:"user_id"`
+	Submissions     int            `json:"submissions"`
+	ByMonth         []MonthlyCount `json:"by_month"` // by observation date, oldest first
+	ByStatus        map[string]int `json:"by_status"`
+	FieldsCovered   int            `json:"fields_covered"`
+	FieldIDs        []string       `json:"field_ids"`
+	Images          int            `json:"images"`
+	Approved        int            `json:"approved"`
+	Rejected        int            `json:"rejected"`
+	ApprovalRate    *float64       `json:"approval_rate"` // approved out of reviewed, 0-1; null until one is reviewed
+	FirstSubmission *time.Time     `json:"first_submission,omitempty"`
+	LastSubmission  *time.Time     `json:"last_submission,omitempty"`
+}
+
+// MonthlyCount is the number of items in a calendar month
+type MonthlyCount struct {
+	Month string `json:"month"` // YYYY-MM
+	Count int    `json:"count"`
+}
+
+// ServiceAccount is a non-human client, such as a weather station or importer,
+// that obtains JWTs with the client credentials grant
+type ServiceAccount struct {
+	ID         string     `json:"id" firestore:"id"` // also the client ID
+	Name       string     `json:"name" firestore:"name"`
+	SecretHash string     `json:"-" firestore:"secret_hash"` // SHA-256 of the client secret
+	Scopes     []string   `json:"scopes" firestore:"scopes"`
+	Disabled   bool       `json:"disabled" firestore:"disabled"`
+	CreatedBy  string     `json:"created_by" firestore:"created_by"`
+	CreatedAt  time.Time  `json:"created_at" firestore:"created_at"`
+	LastUsedAt *time.Time `json:"last_used_at,omitempty" firestore:"last_used_at"`
+}
+
+// EmbedToken grants a partner website read access to one dashboard widget
+type EmbedToken struct {
+	ID             string            `json:"id" firestore:"id"`
+	Name           string            `json:"name" firestore:"name"`
+	Widget         string            `json:"widget" firestore:"widget"` // stage_distribution, daily_submissions
+	Params         map[string]string `json:"params" firestore:"params"` // fixed widget filters (region, field_ids, days)
+	AllowedOrigins []string          `json:"allowed_origins" firestore:"allowed_origins"`
+	Prefix         string            `json:"prefix" firestore:"prefix"`
+	TokenHash      string            `json:"-" firestore:"token_hash"`
+	Revoked        bool              `json:"revoked" firestore:"revoked"`
+	OrgID          string            `json:"org_id,omitempty" firestore:"org_id,omitempty"` // creator's organization, whose submissions the widget counts
+	CreatedBy      string            `json:"created_by" firestore:"created_by"`
+	CreatedAt      time.Time         `json:"created_at" firestore:"created_at"`
+	ExpiresAt      time.Time         `json:"expires_at" firestore:"expires_at"`
+}
+
+// SubmissionShare is a public read-only link to one submission, for
+// collaborators outside the system
+type SubmissionShare struct {
+	ID           string     `json:"id" firestore:"id"`
+	SubmissionID string     `json:"submission_id" firestore:"submission_id"`
+	Prefix       string     `json:"prefix" firestore:"prefix"`
+	TokenHash    string     `json:"-" firestore:"token_hash"`
+	Revoked      bool       `json:"revoked" firestore:"revoked"`
+	RevokedAt    *time.Time `json:"revoked_at,omitempty" firestore:"revoked_at"`
+	Views        int        `json:"views" firestore:"views"`
+	LastViewedAt *time.Time `json:"last_viewed_at,omitempty" firestore:"last_viewed_at"`
+	CreatedBy    string     `json:"created_by" firestore:"created_by"`
+	CreatedAt    time.Time  `json:"created_at" firestore:"created_at"`
+	ExpiresAt    time.Time  `json:"expires_at" firestore:"expires_at"`
+}
+
+// SharedSubmission is what a share link shows of a submission: the
+// observation and its photos, without the observer's account, device or
+// exact GPS position
+type SharedSubmission struct {
+	ID                string                  `json:"id"`
+	FieldName         string                  `json:"field_name,omitempty"`
+	FieldLocation     string                  `json:"field_location,omitempty"`
+	RiceVariety       string                  `json:"rice_variety,omitempty"`
+	Date              time.Time               `json:"date"`
+	GrowthStage       string                  `json:"growth_stage"`
+	PlantConditions   []string                `json:"plant_conditions"`
+	TraitMeasurements TraitMeasurements       `json:"trait_measurements"`
+	HillID            string                  `json:"hill_id,omitempty"`
+	ReplicateSummary  map[string]TraitSummary `json:"replicate_summary,omitempty"`
+	Notes             string                  `json:"notes"`
+	ObserverName      string                  `json:"observer_name"`
+	Images            []string                `json:"images"`
+	Status            string                  `json:"status"`
+	Tags              []string                `json:"tags,omitempty"`
+	Weather           *WeatherSnapshot        `json:"weather,omitempty"`
+	UpdatedAt         time.Time               `json:"updated_at"`
+	ExpiresAt         time.Time               `json:"expires_at"` // when the link stops working
+}
+
+// Invite lets a new user create an account with a preset role and field assignments
+type Invite struct {
+	ID        string     `json:"id" firestore:"id"`
+	Email     string     `json:"email,omitempty" firestore:"email"` // when set, only this address may use the invite
+	Role      string     `json:"role" firestore:"role"`
+	FieldIDs  []string   `json:"field_ids" firestore:"field_ids"`
+	Prefix    string     `json:"prefix" firestore:"prefix"` // first characters of the code, for display
+	CodeHash  string     `json:"-" firestore:"code_hash"`   // SHA-256 of the full code
+	Revoked   bool       `json:"revoked" firestore:"revoked"`
+	CreatedBy string     `json:"created_by" firestore:"created_by"`
+	CreatedAt time.Time  `json:"created_at" firestore:"created_at"`
+	ExpiresAt time.Time  `json:"expires_at" firestore:"expires_at"`
+	UsedBy    string     `json:"used_by,omitempty" firestore:"used_by"`
+	UsedAt    *time.Time `json:"used_at,omitempty" firestore:"used_at"`
+}
+
+// Session records a device a user is logged in on. Each login starts a
+// session and the tokens issued for it carry its ID.
+type Session struct {
+	ID         string    `json:"id" firestore:"id"`
+	UserID     string    `json:"user_id" firestore:"user_id"`
+	UserAgent  string    `json:"user_agent" firestore:"user_agent"`
+	IPAddress  string    `json:"ip_address" firestore:"ip_address"`
+	Revoked    bool      `json:"-" firestore:"revoked"`
+	Current    bool      `json:"current" firestore:"-"` // set when listing, for the session making the request
+	CreatedAt  time.Time `json:"created_at" firestore:"created_at"`
+	LastUsedAt time.Time `json:"last_used_at" firestore:"last_used_at"`
+	ExpiresAt  time.Time `json:"expires_at" firestore:"expires_at"` // when the latest refresh token expires
+}
+
+// AuthEvent is an entry in the authentication audit log
+type AuthEvent struct {
+	ID        string    `json:"id" firestore:"id"`
+	Type      string    `json:"type" firestore:"type"`     // login, refresh, logout, logout_all
+	Method    string    `json:"method" firestore:"method"` // google, apple, facebook, password, refresh_token, jwt, api_key
+	Success   bool      `json:"success" firestore:"success"`
+	Reason    string    `json:"reason,omitempty" firestore:"reason,omitempty"` // error code of a failed attempt
+	UserID    string    `json:"user_id,omitempty" firestore:"user_id,omitempty"`
+	Email     string    `json:"email,omitempty" firestore:"email,omitempty"`
+	SessionID string    `json:"session_id,omitempty" firestore:"session_id,omitempty"`
+	IPAddress string    `json:"ip_address" firestore:"ip_address"`
+	UserAgent string    `json:"user_agent" firestore:"user_agent"`
+	CreatedAt time.Time `json:"created_at" firestore:"created_at"`
+}
+
+// UploadFailure records an image that could not be stored, for the data quality report
+type UploadFailure struct {
+	ID           string    `json:"id" firestore:"id"`
+	SubmissionID string    `json:"submission_id" firestore:"submission_id"`
+	UserID       string    `json:"user_id" firestore:"user_id"`
+	Filename     string    `json:"filename" firestore:"filename"`
+	Source       string    `json:"source" firestore:"source"` // app, email
+	Error        string    `json:"error" firestore:"error"`
+	CreatedAt    time.Time `json:"created_at" firestore:"created_at"`
+}
+
+// QualityIssue is one problem found by a data quality report
+type QualityIssue struct {
+	SubmissionID string `json:"submission_id" firestore:"submission_id"`
+	FieldID      string `json:"field_id,omitempty" firestore:"field_id"`
+	UserID       string `json:"user_id,omitempty" firestore:"user_id"`
+	Detail       string `json:"detail" firestore:"detail"`
+}
+
+// QualityReport summarizes data quality problems found during one period
+type QualityReport struct {
+	ID             string         `json:"id" firestore:"id"`         // period and start date, e.g. daily-2024-06-01
+	Period         string         `json:"period" firestore:"period"` // daily, weekly
+	PeriodStart    time.Time      `json:"period_start" firestore:"period_start"`
+	PeriodEnd      time.Time      `json:"period_end" firestore:"period_end"`
+	Submissions    int            `json:"submissions" firestore:"submissions"` // submissions created during the period
+	Anomalies      []QualityIssue `json:"anomalies" firestore:"anomalies"`
+	Duplicates     []QualityIssue `json:"duplicates" firestore:"duplicates"`
+	MissingPhotos  []QualityIssue `json:"missing_photos" firestore:"missing_photos"`
+	OverdueReviews []QualityIssue `json:"overdue_reviews" firestore:"overdue_reviews"`
+	FailedUploads  []QualityIssue `json:"failed_uploads" firestore:"failed_uploads"`
+	CreatedAt      time.Time      `json:"created_at" firestore:"created_at"`
+}
+
+// ExportFilters narrow the submissions of a scheduled export, as the
+// filters of GET /submissions/export
+type ExportFilters struct {
+	Status         string `json:"status,omitempty" firestore:"status,omitempty" binding:"omitempty,oneof=draft submitted under_review approved rejected"`
+	FieldID        string `json:"field_id,omitempty" firestore:"field_id,omitempty"`
+	FarmID         string `json:"farm_id,omitempty" firestore:"farm_id,omitempty"`
+	SeasonID       string `json:"season_id,omitempty" firestore:"season_id,omitempty"`
+	GrowthStage    string `json:"growth_stage,omitempty" firestore:"growth_stage,omitempty"`
+	PlantCondition string `json:"plant_condition,omitempty" firestore:"plant_condition,omitempty"`
+	Observer       string `json:"observer,omitempty" firestore:"observer,omitempty"` // user ID of the submitter
+	Tag            string `json:"tag,omitempty" firestore:"tag,omitempty"`
+}
+
+// ExportSchedule is a recurring submissions export. Each run exports the
+// submissions created during the previous week or month, as its owner sees them.
+type ExportSchedule struct {
+	ID         string        `json:"id" firestore:"id"`
+	UserID     string        `json:"user_id" firestore:"user_id"` // owner, whose access scopes the export
+	OrgID      string        `json:"org_id,omitempty" firestore:"org_id,omitempty"`
+	Name       string        `json:"name" firestore:"name"`
+	Format     string        `json:"format" firestore:"format"`                             // csv, xlsx, geojson
+	Frequency  string        `json:"frequency" firestore:"frequency"`                       // weekly (Mondays) or monthly (1st of the month)
+	Delivery   string        `json:"delivery" firestore:"delivery"`                         // gcs or email
+	Path       string        `json:"path,omitempty" firestore:"path,omitempty"`             // folder under the owner's exports in the bucket, for gcs delivery
+	Recipients []string      `json:"recipients,omitempty" firestore:"recipients,omitempty"` // emailed a signed link, for email delivery
+	Filters    ExportFilters `json:"filters" firestore:"filters"`
+	Active     bool          `json:"active" firestore:"active"` // cleared when the owner can no longer export
+	LastRunAt  *time.Time    `json:"last_run_at,omitempty" firestore:"last_run_at,omitempty"`
+	CreatedAt  time.Time     `json:"created_at" firestore:"created_at"`
+	UpdatedAt  time.Time     `json:"updated_at" firestore:"updated_at"`
+}
+
+// CreateExportScheduleRequest defines a scheduled export
+type CreateExportScheduleRequest struct {
+	Name       string        `json:"name" binding:"required,max=100"`
+	Format     string        `json:"format" binding:"omitempty,oneof=csv xlsx geojson"` // defaults to csv
+	Frequency  string        `json:"frequency" binding:"required,oneof=weekly monthly"`
+	Delivery   string        `json:"delivery" binding:"required,oneof=gcs email"`
+	Path       string        `json:"path" binding:"max=200"`
+	Recipients []string      `json:"recipients" binding:"max=10,dive,email"` // defaults to the owner's email
+	Filters    ExportFilters `json:"filters"`
+}
+
+// ExportRun is one run of a scheduled export
+type ExportRun struct {
+	ID          string     `json:"id" firestore:"id"` // schedule ID and period end, e.g. abc123-2024-06-03
+	ScheduleID  string     `json:"schedule_id" firestore:"schedule_id"`
+	UserID      string     `json:"user_id" firestore:"user_id"`
+	Name        string     `json:"name" firestore:"name"`
+	Format      string     `json:"format" firestore:"format"`
+	Delivery    string     `json:"delivery" firestore:"delivery"`
+	PeriodStart time.Time  `json:"period_start" firestore:"period_start"`
+	PeriodEnd   time.Time  `json:"period_end" firestore:"period_end"`
+	Status      string     `json:"status" firestore:"status"` // running, done, failed
+	Rows        int        `json:"rows" firestore:"rows"`
+	Path        string     `json:"path,omitempty" firestore:"path,omitempty"` // object in the bucket
+	Error       string     `json:"error,omitempty" firestore:"error,omitempty"`
+	CreatedAt   time.Time  `json:"created_at" firestore:"created_at"`
+	CompletedAt *time.Time `json:"completed_at,omitempty" firestore:"completed_at,omitempty"`
+}
+
+// RedFlag is one urgent issue on the red-flag dashboard
+type RedFlag struct {
+	Type     string   `json:"type"`     // outbreak_cluster, overdue_visit, trait_deviation, rejection_spike
+	Severity int      `json:"severity"` // 0-100, flags are ranked by it
+	Title    string   `json:"title"`
+	Detail   string   `json:"detail"`
+	FieldIDs []string `json:"field_ids,omitempty"`
+	UserID   string   `json:"user_id,omitempty"` // observer, for rejection spikes
+}
+
+// RedFlagReport ranks the most urgent issues across the fields a manager oversees
+type RedFlagReport struct {
+	Days        int            `json:"days"`         // recent window the flags look at
+	OverdueDays int            `json:"overdue_days"` // days without a visit before a field is overdue
+	Counts      map[string]int `json:"counts"`       // flags found per type, before the limit
+	Flags       []RedFlag      `json:"flags"`
+	GeneratedAt time.Time      `json:"generated_at"`
+}
+
 // Location represents GPS coordinates
 type Location struct {
 	Latitude  float64 `json:"latitude" firestore:"latitude"`
 	Longitude float64 `json:"longitude" firestore:"longitude"`
 }
 
+// Season is one crop cycle of a field, from transplanting to harvest.
+// Observations made during it are attached to it.
+type Season struct {
+	ID             string    `json:"id" firestore:"id"`
+	FieldID        string    `json:"field_id" firestore:"field_id"`
+	OrgID          string    `json:"org_id,omitempty" firestore:"org_id,omitempty"` // the field's organization
+	Name           string    `json:"name,omitempty" firestore:"name,omitempty"`     // such as "Boro 2026"
+	RiceVariety    string    `json:"rice_variety,omitempty" firestore:"rice_variety,omitempty"`
+	TransplantDate string    `json:"transplant_date" firestore:"transplant_date"`               // YYYY-MM-DD
+	HarvestDate    string    `json:"harvest_date,omitempty" firestore:"harvest_date,omitempty"` // YYYY-MM-DD, empty while the crop is in the field
+	CreatedBy      string    `json:"created_by" firestore:"created_by"`
+	CreatedAt      time.Time `json:"created_at" firestore:"created_at"`
+	UpdatedAt      time.Time `json:"updated_at" firestore:"updated_at"`
+}
+
+// SeasonRequest starts a crop season of a field or updates it
+type SeasonRequest struct {
+	Name           string `json:"name" binding:"max=100"`
+	RiceVariety    string `json:"rice_variety" binding:"max=100"`
+	TransplantDate string `json:"transplant_date" binding:"required"`
+	HarvestDate    string `json:"harvest_date"`
+}
+
+// FieldCollaborator is a user a field is shared with. Viewers can see the
+// field and its submissions; contributors can also submit observations to it.
+type FieldCollaborator struct {
+	UserID  string    `json:"user_id" firestore:"user_id"`
+	Role    string    `json:"role" firestore:"role"` // viewer or contributor
+	Name    string    `json:"name,omitempty" firestore:"-"`
+	Email   string    `json:"email,omitempty" firestore:"-"`
+	AddedBy string    `json:"added_by" firestore:"added_by"`
+	AddedAt time.Time `json:"added_at" firestore:"added_at"`
+}
+
+// NearbyField is a field found near a position
+type NearbyField struct {
+	Field
+	DistanceKm     float64 `json:"distance_km"`     // to the field's boundary, or to its coordinates without one
+	InsideBoundary bool    `json:"inside_boundary"` // the position lies within the field's boundary
+}
+
+// GeoJSONPolygon is an RFC 7946 Polygon geometry. Positions are
+// [longitude, latitude] and each ring repeats its first position last.
+type GeoJSONPolygon struct {
+	Type        string        `json:"type"`
+	Coordinates [][][]float64 `json:"coordinates"`
+}
+
+// GPSFix is the position a device reported when an observation was made
+type GPSFix struct {
+	Latitude  float64 `json:"latitude" firestore:"latitude" binding:"min=-90,max=90"`
+	Longitude float64 `json:"longitude" firestore:"longitude" binding:"min=-180,max=180"`
+	Accuracy  float64 `json:"accuracy" firestore:"accuracy" binding:"min=0"` // radius in meters, 0 when unknown
+}
+
+// Point returns the position without its accuracy
+func (f GPSFix) Point() Location {
+	return Location{Latitude: f.Latitude, Longitude: f.Longitude}
+}
+
 // Submission represents a monitoring submission
 type Submission struct {
-	ID                string            `json:"id" firestore:"id"`
-	UserID            string            `json:"user_id" firestore:"user_id"`
-	FieldID           string            `json:"field_id" firestore:"field_id"`
-	Date              time.Time         `json:"date" firestore:"date"`
-	GrowthStage       string            `json:"growth_stage" firestore:"growth_stage"`
-	PlantConditions   []string          `json:"plant_conditions" firestore:"plant_conditions"`
-	TraitMeasurements TraitMeasurements `json:"trait_measurements" firestore:"trait_measurements"`
-	Notes             string            `json:"notes" firestore:"notes"`
-	ObserverName      string            `json:"observer_name" firestore:"observer_name"`
-	Images            []string          `json:"images" firestore:"images"` // URLs to uploaded images
-	Status            string            `json:"status" firestore:"status"` // submitted, under_review, approved, rejected
-	CreatedAt         time.Time         `json:"created_at" firestore:"created_at"`
-	UpdatedAt         time.Time         `json:"updated_at" firestore:"updated_at"`
+	ID                   string                  `json:"id" firestore:"id"`
+	UserID               string                  `json:"user_id" firestore:"user_id"`
+	FieldID              string                  `json:"field_id" firestore:"field_id"`
+	OrgID                string                  `json:"org_id,omitempty" firestore:"org_id,omitempty"` // submitter's organization
+	FarmID               string                  `json:"farm_id,omitempty" firestore:"farm_id,omitempty"` // farm of the field, kept in step with it
+	SeasonID             string                  `json:"season_id,omitempty" firestore:"season_id,omitempty"` // crop season of the field the observation date falls in
+	Date                 time.Time               `json:"date" firestore:"date"`
+	GrowthStage          string                  `json:"growth_stage" firestore:"growth_stage"`
+	PlantConditions      []string                `json:"plant_conditions" firestore:"plant_conditions"`
+	TraitMeasurements    TraitMeasurements       `json:"trait_measurements" firestore:"trait_measurements"`                   // after calibration
+	RawMeasurements      *TraitMeasurements      `json:"raw_measurements,omitempty" firestore:"raw_measurements,omitempty"`   // as measured, set when calibrations were applied
+	Calibrations         []string                `json:"calibrations,omitempty" firestore:"calibrations,omitempty"`           // IDs of the calibrations applied
+	HillID               string                  `json:"hill_id,omitempty" firestore:"hill_id,omitempty"`                     // marked hill or quadrat measured, links visits to it
+	Replicates           []Replicate             `json:"replicates,omitempty" firestore:"replicates,omitempty"`               // per-hill measurements as measured, their means are the trait_measurements
+	ReplicateSummary     map[string]TraitSummary `json:"replicate_summary,omitempty" firestore:"replicate_summary,omitempty"` // by trait key, computed from the replicates
+	Notes                string                  `json:"notes" firestore:"notes"`
+	ObserverName         string                  `json:"observer_name" firestore:"observer_name"`
+	Images               []string                `json:"images" firestore:"images"`                                     // URLs to uploaded images
+	PendingImages        int                     `json:"pending_images,omitempty" firestore:"pending_images,omitempty"` // photos waiting to be uploaded
+	MediaStatus          string                  `json:"media_status,omitempty" firestore:"media_status,omitempty"`     // pending, complete or missing; empty when no upload was ever deferred
+	Evidence             []EvidenceLink          `json:"evidence,omitempty" firestore:"evidence,omitempty"`
+	Attachments          []Attachment            `json:"attachments,omitempty" firestore:"attachments,omitempty"` // PDF data sheets and voice notes
+	Status               string                  `json:"status" firestore:"status"`                               // draft, submitted, under_review, approved, rejected
+	ReviewedBy           string                  `json:"reviewed_by,omitempty" firestore:"reviewed_by,omitempty"` // reviewer of the latest review decision
+	ReviewedAt           *time.Time              `json:"reviewed_at,omitempty" firestore:"reviewed_at,omitempty"`
+	ReviewComment        string                  `json:"review_comment,omitempty" firestore:"review_comment,omitempty"`
+	Tags                 []string                `json:"tags,omitempty" firestore:"tags,omitempty"` // free-form labels, e.g. training-sample
+	Source               string                  `json:"source" firestore:"source"`                 // app, email, sms
+	Provenance           string                  `json:"provenance" firestore:"provenance"`         // research, community (empty means research)
+	Coordinates          *Location               `json:"coordinates,omitempty" firestore:"coordinates,omitempty"`
+	Location             *GPSFix                 `json:"location,omitempty" firestore:"location,omitempty"`               // device position at observation time
+	OutOfBoundsM         float64                 `json:"out_of_bounds_m,omitempty" firestore:"out_of_bounds_m,omitempty"` // how far outside the field the position was, flagged for review
+	Weather              *WeatherSnapshot        `json:"weather,omitempty" firestore:"weather,omitempty"`                 // at observation time
+	Device               *DeviceMetadata         `json:"device,omitempty" firestore:"device,omitempty"`                   // client that made the submission
+	VerificationRequired bool                    `json:"verification_required" firestore:"verification_required"`
+	CreatedAt            time.Time               `json:"created_at" firestore:"created_at"`
+	UpdatedAt            time.Time               `json:"updated_at" firestore:"updated_at"`
+}
+
+// Calibration corrects a known bias of one device's or one observer's
+// instrument, such as a stretched measuring tape, as raw*Factor + Offset
+type Calibration struct {
+	ID        string     `json:"id" firestore:"id"`
+	OrgID     string     `json:"org_id,omitempty" firestore:"org_id,omitempty"`
+	DeviceID  string     `json:"device_id,omitempty" firestore:"device_id,omitempty"` // set for device calibrations
+	UserID    string     `json:"user_id,omitempty" firestore:"user_id,omitempty"`     // set for observer calibrations
+	Trait     string     `json:"trait" firestore:"trait"`                             // culm_length or panicle_length
+	Factor    float64    `json:"factor" firestore:"factor"`
+	Offset    float64    `json:"offset" firestore:"offset"` // in cm, added after the factor
+	Reason    string     `json:"reason" firestore:"reason"`
+	Active    bool       `json:"active" firestore:"active"`
+	CreatedBy string     `json:"created_by" firestore:"created_by"`
+	CreatedAt time.Time  `json:"created_at" firestore:"created_at"`
+	RetiredAt *time.Time `json:"retired_at,omitempty" firestore:"retired_at,omitempty"`
+}
+
+// CalibrationRequest registers a calibration for either a device or an observer
+type CalibrationRequest struct {
+	DeviceID string   `json:"device_id" binding:"max=100"`
+	UserID   string   `json:"user_id"`
+	Trait    string   `json:"trait" binding:"required,oneof=culm_length panicle_length"`
+	Factor   *float64 `json:"factor" binding:"omitempty,gt=0"` // 1 when omitted
+	Offset   float64  `json:"offset"`
+	Reason   string   `json:"reason" binding:"required,max=500"`
+}
+
+// ReviewDecision is one entry of the review log, recorded whenever a reviewer
+// sets a submission's status
+type ReviewDecision struct {
+	ID             string    `json:"id" firestore:"id"`
+	SubmissionID   string    `json:"submission_id" firestore:"submission_id"`
+	OrgID          string    `json:"org_id,omitempty" firestore:"org_id,omitempty"` // organization of the submission
+	ReviewerID     string    `json:"reviewer_id" firestore:"reviewer_id"`
+	ReviewerName   string    `json:"reviewer_name" firestore:"reviewer_name"`
+	ObserverID     string    `json:"observer_id,omitempty" firestore:"observer_id,omitempty"` // user who made the submission
+	PreviousStatus string    `json:"previous_status" firestore:"previous_status"`
+	Status         string    `json:"status" firestore:"status"`
+	Reason         string    `json:"reason,omitempty" firestore:"reason"`
+	Reversal       bool      `json:"reversal" firestore:"reversal"` // overturned an earlier approval or rejection
+	CreatedAt      time.Time `json:"created_at" firestore:"created_at"`
+}
+
+// SubmissionCorrection records an edit to a submission that had already been approved
+type SubmissionCorrection struct {
+	ID           string    `json:"id" firestore:"id"`
+	SubmissionID string    `json:"submission_id" firestore:"submission_id"`
+	OrgID        string    `json:"org_id,omitempty" firestore:"org_id,omitempty"`
+	ObserverID   string    `json:"observer_id" firestore:"observer_id"` // user who made the submission
+	EditorID     string    `json:"editor_id" firestore:"editor_id"`
+	Fields       []string  `json:"fields" firestore:"fields"` // changed fields, sorted
+	CreatedAt    time.Time `json:"created_at" firestore:"created_at"`
+}
+
+// AuditChange is the value of one path of a submission before and after a change.
+// Nested values use dotted paths, e.g. trait_measurements.culm_length.
+type AuditChange struct {
+	Path   string      `json:"path" firestore:"path"`
+	Before interface{} `json:"before" firestore:"before"` // null when the path was not set
+	After  interface{} `json:"after" firestore:"after"`   // null when the path was removed
+}
+
+// SubmissionAudit records who changed what on a submission
+type SubmissionAudit struct {
+	ID           string        `json:"id" firestore:"id"`
+	SubmissionID string        `json:"submission_id" firestore:"submission_id"`
+	OrgID        string        `json:"org_id,omitempty" firestore:"org_id,omitempty"`
+	ActorID      string        `json:"actor_id" firestore:"actor_id"`
+	Action       string        `json:"action" firestore:"action"` // create, update, delete, status_change
+	Changes      []AuditChange `json:"changes" firestore:"changes"`
+	UpdatedAt    *time.Time    `json:"updated_at,omitempty" firestore:"updated_at,omitempty"` // the submission's updated_at after the change, the version it produced
+	CreatedAt    time.Time     `json:"created_at" firestore:"created_at"`
+}
+
+// CorrectionStats counts the approvals of one reviewer or of one observer's
+// submissions, and how many of them were later corrected or reversed
+type CorrectionStats struct {
+	UserID         string  `json:"user_id"`
+	Name           string  `json:"name,omitempty"`
+	Approved       int     `json:"approved"`
+	Rejected       int     `json:"rejected"`
+	Corrected      int     `json:"corrected"` // approvals followed by an edit to the submission
+	Reversed       int     `json:"reversed"`  // approvals later overturned by a review decision
+	CorrectionRate float64 `json:"correction_rate"`
+	ReversalRate   float64 `json:"reversal_rate"`
+}
+
+// VisitPhotos are the photos taken on one visit to a field
+type VisitPhotos struct {
+	SubmissionID string    `json:"submission_id"`
+	Date         time.Time `json:"date"`
+	GrowthStage  string    `json:"growth_stage"`
+	Images       []string  `json:"images"`
+}
+
+// StageComparison puts a submission's photos next to those of the previous
+// visit to the same field
+type StageComparison struct {
+	Current      VisitPhotos  `json:"current"`
+	Previous     *VisitPhotos `json:"previous"` // nil for the first visit
+	StageChanged bool         `json:"stage_changed"`
 }
 
 // TraitMeasurements represents the measurement data
 type TraitMeasurements struct {
-	CulmLength      float64 `json:"culm_length" firestore:"culm_length"`
-	PanicleLength   float64 `json:"panicle_length" firestore:"panicle_length"`
-	PaniclesPerHill int     `json:"panicles_per_hill" firestore:"panicles_per_hill"`
-	HillsObserved   int     `json:"hills_observed" firestore:"hills_observed"`
+	CulmLength      float64            `json:"culm_length" firestore:"culm_length"`
+	PanicleLength   float64            `json:"panicle_length" firestore:"panicle_length"`
+	PaniclesPerHill int                `json:"panicles_per_hill" firestore:"panicles_per_hill"`
+	HillsObserved   int                `json:"hills_observed" firestore:"hills_observed"`
+	Custom          map[string]float64 `json:"custom,omitempty" firestore:"custom,omitempty"` // the deployment's custom traits by key, see TraitDefinition
+}
+
+// Replicate is the measurements of one hill of a plot measured hill by hill.
+// Zero means the trait was not measured on the hill.
+type Replicate struct {
+	HillID          string             `json:"hill_id,omitempty" firestore:"hill_id,omitempty"`
+	CulmLength      float64            `json:"culm_length" firestore:"culm_length"`
+	PanicleLength   float64            `json:"panicle_length" firestore:"panicle_length"`
+	PaniclesPerHill int                `json:"panicles_per_hill" firestore:"panicles_per_hill"`
+	Custom          map[string]float64 `json:"custom,omitempty" firestore:"custom,omitempty"`
+}
+
+// TraitSummary summarizes one trait over a submission's replicates
+type TraitSummary struct {
+	N      int     `json:"n" firestore:"n"` // replicates that measured the trait
+	Mean   float64 `json:"mean" firestore:"mean"`
+	StdDev float64 `json:"stddev" firestore:"stddev"` // sample standard deviation, 0 for a single replicate
+}
+
+// EvidenceLink ties one of a submission's images to the trait measurement or
+// plant condition it documents
+type EvidenceLink struct {
+	Image     string `json:"image" firestore:"image" binding:"required"`
+	Trait     string `json:"trait,omitempty" firestore:"trait,omitempty"`         // a trait_measurements key, e.g. panicle_length
+	Condition string `json:"condition,omitempty" firestore:"condition,omitempty"` // one of the submission's plant conditions
+	Note      string `json:"note,omitempty" firestore:"note,omitempty"`
+}
+
+// Attachment is a file attached to a submission other than its photos, such
+// as a scan of the paper data sheet or a voice note. Attachments are private
+// and downloaded through the API.
+type Attachment struct {
+	ID               string    `json:"id" firestore:"id"`
+	Type             string    `json:"type" firestore:"type"` // pdf or audio
+	Name             string    `json:"name" firestore:"name"` // uploaded file name
+	ContentType      string    `json:"content_type" firestore:"content_type"`
+	Size             int64     `json:"size" firestore:"size"` // bytes
+	URL              string    `json:"url" firestore:"url"`   // API download path
+	Object           string    `json:"-" firestore:"object"`  // object name in the bucket
+	UploadedBy       string    `json:"uploaded_by" firestore:"uploaded_by"`
+	UploadedAt       time.Time `json:"uploaded_at" firestore:"uploaded_at"`
+	TranscriptStatus string    `json:"transcript_status,omitempty" firestore:"transcript_status,omitempty"` // voice notes: pending, complete or failed
+	Transcript       string    `json:"transcript,omitempty" firestore:"transcript,omitempty"`               // also appended to the submission's notes
 }
 
 // Request/Response DTOs
@@ -72,48 +1001,488 @@ type CreateSubmissionRequest struct {
 	GrowthStage       string            `json:"growth_stage" binding:"required"`
 	PlantConditions   []string          `json:"plant_conditions"`
 	TraitMeasurements TraitMeasurements `json:"trait_measurements"`
+	HillID            string            `json:"hill_id" binding:"max=40"`     // e.g. Q3-H12, the same on every visit to the hill
+	Replicates        []Replicate       `json:"replicates" binding:"max=100"` // replaces trait_measurements with their means
 	Notes             string            `json:"notes"`
 	ObserverName      string            `json:"observer_name" binding:"required"`
 	Images            []string          `json:"images"`
+	PendingImages     int               `json:"pending_images" binding:"min=0,max=20"` // photos taken but not uploaded yet, e.g. while storage is unavailable
+	Evidence          []EvidenceLink    `json:"evidence" binding:"dive"`
+	Weather           *WeatherSnapshot  `json:"weather"` // device-reported, looked up when omitted
+	Device            *DeviceMetadata   `json:"device"`
+	Location          *GPSFix           `json:"location"` // checked against the field's boundary
+	Tags              []string          `json:"tags"`
+	ClientID          string            `json:"client_id" binding:"max=255"` // idempotency key, as the Idempotency-Key header
+}
+
+// IdempotencyKey records the submission created for a client's idempotency
+// key, so a retried request returns it instead of creating another
+type IdempotencyKey struct {
+	Key          string    `json:"key" firestore:"key"`
+	UserID       string    `json:"user_id" firestore:"user_id"`
+	SubmissionID string    `json:"submission_id" firestore:"submission_id"`
+	RequestHash  string    `json:"request_hash" firestore:"request_hash"` // SHA-256 of the request, to catch reused keys
+	CreatedAt    time.Time `json:"created_at" firestore:"created_at"`
+}
+
+// BatchSubmissionRequest uploads submissions recorded offline. Items are
+// decoded and validated one by one, so one bad item does not fail the rest.
+type BatchSubmissionRequest struct {
+	Submissions []json.RawMessage `json:"submissions" binding:"required,min=1"`
+}
+
+// BatchSubmissionItem is one submission of a batch upload, with the ID the app
+// generated for it so a retried upload does not create it twice
+type BatchSubmissionItem struct {
+	ID string `json:"id" binding:"required,max=64"`
+	CreateSubmissionRequest
+}
+
+// BatchSubmissionResult is the outcome of one item of a batch upload
+type BatchSubmissionResult struct {
+	Index   int    `json:"index"`
+	ID      string `json:"id,omitempty"`
+	Status  string `json:"status"`          // created, exists (uploaded before) or failed
+	Error   string `json:"error,omitempty"` // error code of a failed item
+	Message string `json:"message,omitempty"`
+}
+
+// BulkSubmissionRequest applies one action to a list of submissions: a
+// status change, adding and removing tags, or deletion
+type BulkSubmissionRequest struct {
+	IDs        []string `json:"ids" binding:"required,min=1,max=200,dive,required"`
+	Action     string   `json:"action" binding:"required,oneof=status tags delete"`
+	Status     string   `json:"status" binding:"omitempty,oneof=under_review approved rejected"` // for the status action
+	Comment    string   `json:"comment" binding:"max=2000"`                                      // review comment, required to reject
+	AddTags    []string `json:"add_tags" binding:"max=20"`
+	RemoveTags []string `json:"remove_tags" binding:"max=20"`
+}
+
+// SubmissionTag is a tag used on an organization's submissions, kept so apps
+// can suggest existing tags
+type SubmissionTag struct {
+	Name       string    `json:"name" firestore:"name"`
+	OrgID      string    `json:"org_id,omitempty" firestore:"org_id"`
+	LastUsedAt time.Time `json:"last_used_at" firestore:"last_used_at"`
+}
+
+// BulkSubmissionResult is the outcome of a bulk action on one submission
+type BulkSubmissionResult struct {
+	ID      string `json:"id"`
+	Status  string `json:"status"`          // updated, deleted, unchanged or failed
+	Error   string `json:"error,omitempty"` // error code of a failed submission
+	Message string `json:"message,omitempty"`
+}
+
+// UpdateEvidenceRequest replaces the evidence links of a submission
+type UpdateEvidenceRequest struct {
+	Evidence []EvidenceLink `json:"evidence" binding:"dive"`
+}
+
+// CommunitySubmissionRequest represents the simplified payload used by community
+// observers: one photo, a growth stage, one condition and the device GPS position
+type CommunitySubmissionRequest struct {
+	FieldID     string          `json:"field_id"`
+	Image       string          `json:"image" binding:"required,url"`
+	GrowthStage string          `json:"growth_stage" binding:"required"`
+	Condition   string          `json:"condition" binding:"required"`
+	Coordinates *Location       `json:"coordinates" binding:"required"`
+	Date        *time.Time      `json:"date"`
+	Device      *DeviceMetadata `json:"device"`
 }
 
 // UpdateSubmissionRequest represents the request payload for updating submissions
 type UpdateSubmissionRequest struct {
-	Location          *string            `json:"location,omitempty"`
-	GrowthStage       *string            `json:"growth_stage,omitempty"`
-	PlantConditions   []string           `json:"plant_conditions,omitempty"`
+	Date              *time.Time         `json:"date,omitempty"`
+	GrowthStage       *string            `json:"growth_stage,omitempty" binding:"omitempty,min=1"`
+	PlantConditions   []string           `json:"plant_conditions,omitempty"` // replaces the conditions, [] removes them
 	TraitMeasurements *TraitMeasurements `json:"trait_measurements,omitempty"`
+	Replicates        []Replicate        `json:"replicates,omitempty" binding:"max=100"` // replaces the replicates and their means, [] removes them
+	HillID            *string            `json:"hill_id,omitempty" binding:"omitempty,max=40"`
 	Notes             *string            `json:"notes,omitempty"`
-	Status            *string            `json:"status,omitempty"`
+	ObserverName      *string            `json:"observer_name,omitempty" binding:"omitempty,min=1"`
+	Images            []string           `json:"images,omitempty" binding:"dive,required"` // replaces the images
+	Status            *string            `json:"status,omitempty" binding:"omitempty,oneof=draft submitted under_review approved rejected"`
+	Tags              []string           `json:"tags,omitempty"` // replaces the tags, [] removes them
+}
+
+// SyncSubmissionsRequest uploads edits made offline to existing submissions.
+// Edits are decoded and validated one by one, so one bad edit does not fail the rest.
+type SyncSubmissionsRequest struct {
+	Policy string            `json:"policy" binding:"omitempty,oneof=reject merge"` // SYNC_CONFLICT_POLICY when empty
+	Edits  []json.RawMessage `json:"edits" binding:"required,min=1"`
 }
+
+// SyncEdit is one offline edit: the changes made to a submission as it was at
+// base_updated_at
+type SyncEdit struct {
+	ID            string                  `json:"id" binding:"required"`
+	BaseUpdatedAt time.Time               `json:"base_updated_at" binding:"required"` // updated_at of the version edited
+	Changes       UpdateSubmissionRequest `json:"changes"`
+}
+
+// SyncEditResult is the outcome of one offline edit
+type SyncEditResult struct {
+	Index      int            `json:"index"`
+	ID         string         `json:"id,omitempty"`
+	Status     string         `json:"status"`          // updated, merged (applied over other changes), conflict or failed
+	Error      string         `json:"error,omitempty"` // error code of a conflicting or failed edit
+	Message    string         `json:"message,omitempty"`
+	Conflicts  []SyncConflict `json:"conflicts,omitempty"`
+	Submission *Submission    `json:"submission,omitempty"` // as stored after the edit, or the server's version on conflict
+}
+
+// SyncConflict is a path an offline edit changed that was also changed on the
+// server since the version edited
+type SyncConflict struct {
+	Path      string      `json:"path"`
+	Client    interface{} `json:"client"` // value in the edit
+	Server    interface{} `json:"server"` // value on the server now
+	ChangedBy string      `json:"changed_by"`
+	ChangedAt time.Time   `json:"changed_at"`
+}
+
 type SubmissionResponse struct {
-	ID                string            `json:"id"`
-	UserID            string            `json:"user_id"`
-	FieldID           string            `json:"field_id"`
-	Field             Field             `json:"field" `
-	Date              time.Time         `json:"date"`
-	GrowthStage       string            `json:"growth_stage"`
-	PlantConditions   []string          `json:"plant_conditions"`
-	TraitMeasurements TraitMeasurements `json:"trait_measurements"`
-	Notes             string            `json:"notes"`
-	ObserverName      string            `json:"observer_name"`
-	Images            []string          `json:"images"` // URLs to uploaded images
-	Status            string            `json:"status"` // submitted, under_review, approved, rejected
-	CreatedAt         time.Time         `json:"created_at"`
-	UpdatedAt         time.Time         `json:"updated_at"`
+	ID                   string                  `json:"id"`
+	UserID               string                  `json:"user_id"`
+	FieldID              string                  `json:"field_id"`
+	Field                Field                   `json:"field" `
+	Date                 time.Time               `json:"date"`
+	GrowthStage          string                  `json:"growth_stage"`
+	PlantConditions      []string                `json:"plant_conditions"`
+	TraitMeasurements    TraitMeasurements       `json:"trait_measurements"`
+	RawMeasurements      *TraitMeasurements      `json:"raw_measurements,omitempty"`
+	Calibrations         []string                `json:"calibrations,omitempty"`
+	HillID               string                  `json:"hill_id,omitempty"`
+	Replicates           []Replicate             `json:"replicates,omitempty"`
+	ReplicateSummary     map[string]TraitSummary `json:"replicate_summary,omitempty"`
+	Notes                string                  `json:"notes"`
+	ObserverName         string                  `json:"observer_name"`
+	Images               []string                `json:"images"` // URLs to uploaded images
+	PendingImages        int                     `json:"pending_images,omitempty"`
+	MediaStatus          string                  `json:"media_status,omitempty"`
+	Evidence             []EvidenceLink          `json:"evidence,omitempty"`
+	Attachments          []Attachment            `json:"attachments,omitempty"`
+	Status               string                  `json:"status"` // draft, submitted, under_review, approved, rejected
+	ReviewedBy           string                  `json:"reviewed_by,omitempty"`
+	ReviewedAt           *time.Time              `json:"reviewed_at,omitempty"`
+	ReviewComment        string                  `json:"review_comment,omitempty"`
+	Tags                 []string                `json:"tags,omitempty"`
+	Source               string                  `json:"source"`
+	Provenance           string                  `json:"provenance"`
+	Coordinates          *Location               `json:"coordinates,omitempty"`
+	Location             *GPSFix                 `json:"location,omitempty"`
+	OutOfBoundsM         float64                 `json:"out_of_bounds_m,omitempty"`
+	Weather              *WeatherSnapshot        `json:"weather,omitempty"`
+	Device               *DeviceMetadata         `json:"device,omitempty"` // only shown to users who can read all submissions
+	VerificationRequired bool                    `json:"verification_required"`
+	CreatedAt            time.Time               `json:"created_at"`
+	UpdatedAt            time.Time               `json:"updated_at"`
+}
+
+// ReviewCommentRequest is the body of an approval or rejection
+type ReviewCommentRequest struct {
+	Comment string `json:"comment" binding:"max=2000"` // required to reject
+}
+
+// ReviewSubmissionRequest represents the request payload for reviewing submissions
+type ReviewSubmissionRequest struct {
+	Status string `json:"status" binding:"required,oneof=under_review approved rejected"`
+	Reason string `json:"reason" binding:"max=2000"` // kept in the review log
 }
+
+// SuspendUserRequest represents the request payload for suspending a user.
+// Without an end time the account is deactivated until it is reactivated.
+type SuspendUserRequest struct {
+	Until  *time.Time `json:"until"`
+	Reason string     `json:"reason"`
+}
+
+// UpdateRoleRequest represents the request payload for changing a user's role
+type UpdateRoleRequest struct {
+	Role string `json:"role" binding:"required"`
+}
+
+// CreateServiceAccountRequest represents the request payload for creating service accounts
+type CreateServiceAccountRequest struct {
+	Name   string   `json:"name" binding:"required"`
+	Scopes []string `json:"scopes" binding:"required,min=1"`
+}
+
+// CreateServiceAccountResponse returns the client secret, which is only shown once
+type CreateServiceAccountResponse struct {
+	ServiceAccount
+	ClientSecret string `json:"client_secret"`
+}
+
+// Integration posts selected events to a Slack or Google Chat webhook
+type Integration struct {
+	ID               string     `json:"id" firestore:"id"`
+	Name             string     `json:"name" firestore:"name"`
+	Type             string     `json:"type" firestore:"type"` // slack, google_chat
+	WebhookURL       string     `json:"webhook_url" firestore:"webhook_url"`
+	Events           []string   `json:"events" firestore:"events"`                       // pest_alert, review_backlog, weekly_summary
+	BacklogThreshold int        `json:"backlog_threshold" firestore:"backlog_threshold"` // pending reviews above which review_backlog is posted
+	Enabled          bool       `json:"enabled" firestore:"enabled"`
+	LastDeliveryAt   *time.Time `json:"last_delivery_at,omitempty" firestore:"last_delivery_at,omitempty"`
+	LastError        string     `json:"last_error,omitempty" firestore:"last_error"`
+	CreatedBy        string     `json:"created_by" firestore:"created_by"`
+	CreatedAt        time.Time  `json:"created_at" firestore:"created_at"`
+	UpdatedAt        time.Time  `json:"updated_at" firestore:"updated_at"`
+}
+
+// IntegrationRequest creates or replaces an integration
+type IntegrationRequest struct {
+	Name             string   `json:"name" binding:"required"`
+	Type             string   `json:"type" binding:"required,oneof=slack google_chat"`
+	WebhookURL       string   `json:"webhook_url" binding:"required,url"`
+	Events           []string `json:"events" binding:"required,min=1,dive,oneof=pest_alert review_backlog weekly_summary"`
+	BacklogThreshold *int     `json:"backlog_threshold" binding:"omitempty,min=0"` // defaults to 20
+	Enabled          *bool    `json:"enabled"`                                     // defaults to true
+}
+
+// Webhook delivers submission events to an external URL, signed with its secret
+type Webhook struct {
+	ID             string     `json:"id" firestore:"id"`
+	Name           string     `json:"name" firestore:"name"`
+	URL            string     `json:"url" firestore:"url"`
+	Secret         string     `json:"secret,omitempty" firestore:"secret"` // HMAC-SHA256 key, only returned when created or rotated
+	Events         []string   `json:"events" firestore:"events"`           // submission.created, submission.updated, submission.approved
+	Enabled        bool       `json:"enabled" firestore:"enabled"`
+	LastDeliveryAt *time.Time `json:"last_delivery_at,omitempty" firestore:"last_delivery_at,omitempty"`
+	LastError      string     `json:"last_error,omitempty" firestore:"last_error"`
+	CreatedBy      string     `json:"created_by" firestore:"created_by"`
+	CreatedAt      time.Time  `json:"created_at" firestore:"created_at"`
+	UpdatedAt      time.Time  `json:"updated_at" firestore:"updated_at"`
+}
+
+// WebhookRequest creates or replaces a webhook
+type WebhookRequest struct {
+	Name    string   `json:"name" binding:"required,max=100"`
+	URL     string   `json:"url" binding:"required,url"` // https only
+	Events  []string `json:"events" binding:"required,min=1,dive,oneof=submission.created submission.updated submission.approved"`
+	Enabled *bool    `json:"enabled"` // defaults to true
+}
+
+// WebhookDelivery is one event sent to a webhook, with its retries
+type WebhookDelivery struct {
+	ID            string     `json:"id" firestore:"id"` // sent as X-Rice-Monitor-Delivery
+	WebhookID     string     `json:"webhook_id" firestore:"webhook_id"`
+	Event         string     `json:"event" firestore:"event"`
+	Payload       string     `json:"payload" firestore:"payload"` // JSON body sent
+	Status        string     `json:"status" firestore:"status"`   // pending, delivered or failed
+	Attempts      int        `json:"attempts" firestore:"attempts"`
+	ResponseCode  int        `json:"response_code,omitempty" firestore:"response_code"`
+	LastError     string     `json:"last_error,omitempty" firestore:"last_error"`
+	NextAttemptAt *time.Time `json:"next_attempt_at,omitempty" firestore:"next_attempt_at"` // while pending
+	DeliveredAt   *time.Time `json:"delivered_at,omitempty" firestore:"delivered_at,omitempty"`
+	CreatedAt     time.Time  `json:"created_at" firestore:"created_at"`
+}
+
+// ClientCredentialsRequest represents an OAuth2 client credentials token request
+type ClientCredentialsRequest struct {
+	GrantType    string `form:"grant_type" json:"grant_type" binding:"required"`
+	ClientID     string `form:"client_id" json:"client_id" binding:"required"`
+	ClientSecret string `form:"client_secret" json:"client_secret" binding:"required"`
+}
+
+// ClientCredentialsResponse represents an OAuth2 token response
+type ClientCredentialsResponse struct {
+	AccessToken string `json:"access_token"`
+	TokenType   string `json:"token_type"`
+	ExpiresIn   int    `json:"expires_in"`
+	Scope       string `json:"scope"`
+}
+
+// CreateEmbedTokenRequest represents the request payload for creating embed tokens
+type CreateEmbedTokenRequest struct {
+	Name           string            `json:"name" binding:"required"`
+	Widget         string            `json:"widget" binding:"required,oneof=stage_distribution daily_submissions"`
+	Params         map[string]string `json:"params"`
+	AllowedOrigins []string          `json:"allowed_origins"`
+	ExpiresInDays  int               `json:"expires_in_days" binding:"required,min=1,max=365"`
+}
+
+// CreateEmbedTokenResponse returns the embed token, which is only shown once
+type CreateEmbedTokenResponse struct {
+	EmbedToken
+	Token string `json:"token"`
+}
+
 // CreateFieldRequest represents the request payload for creating fields
 type CreateFieldRequest struct {
 	Name        string   `json:"name" binding:"required"`
 	Location    string   `json:"location" binding:"required"`
 	RiceVariety    string   `json:"rice_variety" `
 	TentativeDate    string   `json:"tentative_date"`
+	TransplantDate string `json:"transplant_date"`
+	SMSCode     string   `json:"sms_code"` // digits identifying the field in SMS observations
+	FarmID      string   `json:"farm_id"`
+	MonitoringCadenceDays int `json:"monitoring_cadence_days" binding:"omitempty,min=1,max=365"` // days expected between visits
 	Coordinates Location `json:"coordinates"`
-	Area        float64  `json:"area"`
+	Boundary    []Location `json:"boundary" binding:"omitempty,min=3"` // polygon outlining the field
+	BoundaryGeoJSON *GeoJSONPolygon `json:"boundary_geojson"` // the same polygon as GeoJSON, instead of boundary
+	Area        float64  `json:"area"` // replaced by the boundary's area when one is given
 }
 
-// GoogleTokenRequest represents Google OAuth token request
+// FieldNoteRequest represents the request payload for creating or updating a field note
+type FieldNoteRequest struct {
+	Text      string     `json:"text" binding:"required,max=2000"`
+	Pinned    bool       `json:"pinned"`
+	ExpiresAt *time.Time `json:"expires_at"`
+}
+
+// SetFieldCollaboratorRequest shares a field with a user, or changes their role
+type SetFieldCollaboratorRequest struct {
+	Role string `json:"role" binding:"required,oneof=viewer contributor"`
+}
+
+// AssignObserverRequest assigns a user to a field
+type AssignObserverRequest struct {
+	UserID string `json:"user_id" binding:"required"`
+}
+
+// CreateStressEventRequest represents the request payload for declaring a stress event
+type CreateStressEventRequest struct {
+	Type        string     `json:"type" binding:"required,oneof=drought flood"`
+	Name        string     `json:"name" binding:"required"`
+	Description string     `json:"description"`
+	StartDate   string     `json:"start_date" binding:"required"` // YYYY-MM-DD
+	EndDate     string     `json:"end_date" binding:"required"`   // YYYY-MM-DD
+	Area        []Location `json:"area" binding:"required,min=3"`
+}
+
+// CreateDatasetRequest represents the request payload for creating dataset releases
+type CreateDatasetRequest struct {
+	Title       string   `json:"title" binding:"required"`
+	Description string   `json:"description"`
+	Keywords    []string `json:"keywords"`
+	License     string   `json:"license"`
+	Publisher   string   `json:"publisher"`
+	FieldIDs    []string `json:"field_ids"`
+	StartDate   string   `json:"start_date"`
+	EndDate     string   `json:"end_date"`
+}
+
+// GoogleTokenRequest represents Google OAuth token request. An invite code
+// is required when the login creates a new account.
 type GoogleTokenRequest struct {
+	Token      string `json:"token" binding:"required"`
+	InviteCode string `json:"invite_code"`
+}
+
+// OAuthLoginRequest represents a social login token request. Name is only
+// used when the provider does not share one (Apple after the first sign-in).
+// An invite code is required when the login creates a new account.
+type OAuthLoginRequest struct {
+	Token      string `json:"token" binding:"required"`
+	Name       string `json:"name"`
+	InviteCode string `json:"invite_code"`
+}
+
+// CreateShareTokenRequest represents the request payload for minting a share token
+type CreateShareTokenRequest struct {
+	Scope          string `json:"scope" binding:"required,oneof=export:submissions"`
+	FieldID        string `json:"field_id"`                                            // limit the link to one field
+	ExpiresInHours int    `json:"expires_in_hours" binding:"omitempty,min=1,max=168"` // defaults to 24
+}
+
+// ShareTokenResponse returns a minted share token and the link that uses it
+type ShareTokenResponse struct {
+	Token     string    `json:"token"`
+	URL       string    `json:"url"`
+	Scope     string    `json:"scope"`
+	FieldID   string    `json:"field_id,omitempty"`
+	ExpiresAt time.Time `json:"expires_at"`
+}
+
+// CreateSubmissionShareRequest represents the request payload for sharing a submission
+type CreateSubmissionShareRequest struct {
+	ExpiresInHours int `json:"expires_in_hours" binding:"omitempty,min=1,max=720"` // defaults to 168
+}
+
+// CreateSubmissionShareResponse returns the share link, whose token is only shown once
+type CreateSubmissionShareResponse struct {
+	SubmissionShare
+	Token string `json:"token"`
+	URL   string `json:"url"`
+}
+
+// CreateInviteRequest represents the request payload for creating invites
+type CreateInviteRequest struct {
+	Email         string   `json:"email" binding:"omitempty,email"`
+	Role          string   `json:"role" binding:"required"`
+	FieldIDs      []string `json:"field_ids"`
+	ExpiresInDays int      `json:"expires_in_days" binding:"omitempty,min=1,max=90"` // defaults to 14
+}
+
+// CreateInviteResponse returns the invite code, which is only shown once
+type CreateInviteResponse struct {
+	Invite
+	Code string `json:"code"`
+}
+
+// CreateAPIKeyRequest represents the request payload for creating API keys
+type CreateAPIKeyRequest struct {
+	Name string `json:"name" binding:"required"`
+}
+
+// CreateAPIKeyResponse is returned once on creation; the raw key is never shown again
+type CreateAPIKeyResponse struct {
+	APIKey
+	Key string `json:"key"`
+}
+
+// CreatePersonalAccessTokenRequest represents the request payload for creating personal access tokens
+type CreatePersonalAccessTokenRequest struct {
+	Name          string   `json:"name" binding:"required,max=100"`
+	Scopes        []string `json:"scopes" binding:"required,min=1"`
+	ExpiresInDays int      `json:"expires_in_days" binding:"omitempty,min=1,max=365"` // 90 when omitted
+}
+
+// CreatePersonalAccessTokenResponse is returned once on creation; the raw token is never shown again
+type CreatePersonalAccessTokenResponse struct {
+	PersonalAccessToken
+	Token string `json:"token"`
+}
+
+// SignupRequest represents email/password signup request
+type SignupRequest struct {
+	Email      string `json:"email" binding:"required,email"`
+	Password   string `json:"password" binding:"required,min=8,max=72"`
+	Name       string `json:"name" binding:"required"`
+	InviteCode string `json:"invite_code" binding:"required"`
+}
+
+// LoginRequest represents email/password login request
+type LoginRequest struct {
+	Email    string `json:"email" binding:"required,email"`
+	Password string `json:"password" binding:"required"`
+}
+
+// VerifyEmailRequest represents an email verification request
+type VerifyEmailRequest struct {
+	Token string `json:"token" binding:"required"`
+}
+
+// ForgotPasswordRequest represents a request to send a password reset link
+type ForgotPasswordRequest struct {
+	Email string `json:"email" binding:"required,email"`
+}
+
+// ResetPasswordRequest represents a request to set a new password with a reset token
+type ResetPasswordRequest struct {
+	Token    string `json:"token" binding:"required"`
+	Password string `json:"password" binding:"required,min=8,max=72"`
+}
+
+// MagicLinkRequest represents a request to email a login link
+type MagicLinkRequest struct {
+	Email string `json:"email" binding:"required,email"`
+}
+
+// VerifyMagicLinkRequest represents a request to log in with a magic link token
+type VerifyMagicLinkRequest struct {
 	Token string `json:"token" binding:"required"`
 }
 
@@ -125,8 +1494,8 @@ type RefreshTokenRequest struct {
 // AuthResponse represents authentication response
 type AuthResponse struct {
 	User         User   `json:"user"`
-	AccessToken  string `json:"access_token"`
-	RefreshToken string `json:"refresh_token"`
+	AccessToken  string `json:"access_token,omitempty"`  // omitted in cookie session mode
+	RefreshToken string `json:"refresh_token,omitempty"` // omitted in cookie session mode
 	ExpiresIn    int64  `json:"expires_in"`
 }
 
@@ -145,9 +1514,14 @@ type SuccessResponse struct {
 
 // JWT Claims
 type Claims struct {
-	UserID string `json:"user_id"`
-	Email  string `json:"email"`
-	Role   string `json:"role"`
+	UserID    string   `json:"user_id"`
+	Email     string   `json:"email"`
+	Role      string   `json:"role"`
+	Kind      string   `json:"kind,omitempty"`     // empty for user access tokens, refresh for their refresh tokens, service_account for client credentials tokens, share for share links
+	Scopes    []string `json:"scopes,omitempty"`   // service account and share token scopes
+	SessionID string   `json:"sid,omitempty"`      // session the token was issued for
+	FieldID   string   `json:"field_id,omitempty"` // share tokens limited to one field
+	Version   int      `json:"tv,omitempty"`       // user's token version when issued
 	jwt.RegisteredClaims
 }
 
@@ -159,13 +1533,70 @@ type PaginationParams struct {
 	FieldID string `form:"field_id"`
 }
 
+// SubmissionListParams are the query parameters of the submission listing.
+// Time bounds are RFC 3339 times or YYYY-MM-DD dates; from is inclusive, to exclusive.
+type SubmissionListParams struct {
+	Page           int    `form:"page,default=1" binding:"min=1"`
+	Limit          int    `form:"limit,default=20" binding:"min=1,max=100"`
+	Status         string `form:"status" binding:"omitempty,oneof=draft submitted under_review approved rejected"`
+	FieldID        string `form:"field_id"`
+	FarmID         string `form:"farm_id"`
+	SeasonID       string `form:"season_id"`
+	GrowthStage    string `form:"growth_stage"`
+	PlantCondition string `form:"plant_condition"`
+	Observer       string `form:"observer"` // user ID of the submitter
+	Tag            string `form:"tag"`
+	CreatedFrom    string `form:"created_from"`
+	CreatedTo      string `form:"created_to"`
+	DateFrom       string `form:"date_from"` // observation date
+	DateTo         string `form:"date_to"`
+	Sort           string `form:"sort" binding:"omitempty,oneof=created_at date status"` // defaults to the range field, or created_at
+	Order          string `form:"order,default=desc" binding:"oneof=asc desc"`
+}
+
+// FieldListParams are the query parameters of the field listing
+type FieldListParams struct {
+	Page        int    `form:"page,default=1" binding:"min=1"`
+	Limit       int    `form:"limit,default=20" binding:"min=1,max=100"`
+	Query       string `form:"q"` // start of the name or location, or of a word in them
+	RiceVariety string `form:"rice_variety"`
+	OwnerID     string `form:"owner"` // user ID of the owner
+	Archived    *bool  `form:"archived"` // archived fields are left out unless true
+}
+
+// UserSearchParams are the query parameters of the user autocomplete
+type UserSearchParams struct {
+	Query string `form:"q" binding:"required"` // name or email prefix
+	Role  string `form:"role" binding:"omitempty,oneof=admin org_admin coordinator agronomist researcher observer"`
+	Limit int    `form:"limit,default=10" binding:"min=1,max=25"`
+}
+
+// UserMatch is a user suggested by the autocomplete, with only what a picker shows
+type UserMatch struct {
+	ID      string `json:"id"`
+	Name    string `json:"name"`
+	Picture string `json:"picture"`
+	Role    string `json:"role"`
+}
+
+// UserListParams are the query parameters of the admin user listing
+type UserListParams struct {
+	Page    int    `form:"page,default=1" binding:"min=1"`
+	Limit   int    `form:"limit,default=20" binding:"min=1,max=100"`
+	Role    string `form:"role" binding:"omitempty,oneof=admin org_admin coordinator agronomist researcher observer"`
+	Active  *bool  `form:"active"`  // false lists deactivated and suspended users
+	Query   string `form:"q"`       // name or email prefix
+	Deleted bool   `form:"deleted"` // true lists soft-deleted users instead, e.g. to restore them
+}
+
 // DashboardData represents dashboard analytics data
 type DashboardData struct {
-	TotalSubmissions    int            `json:"total_submissions"`
-	SubmissionsByStatus map[string]int `json:"submissions_by_status"`
-	SubmissionsByStage  map[string]int `json:"submissions_by_stage"`
-	RecentSubmissions   []Submission   `json:"recent_submissions"`
-	LastUpdated         time.Time      `json:"last_updated"`
+	TotalSubmissions    int              `json:"total_submissions"`
+	SubmissionsByStatus map[string]int   `json:"submissions_by_status"`
+	SubmissionsByStage  map[string]int   `json:"submissions_by_stage"`
+	RecentSubmissions   []Submission     `json:"recent_submissions"`
+	LastUpdated         time.Time        `json:"last_updated"`
+	Labels              *LocalizedLabels `json:"labels,omitempty"`
 }
 
 // TrendsData represents trends analytics data
@@ -173,6 +1604,24 @@ type TrendsData struct {
 	DailySubmissions map[string]int         `json:"daily_submissions"`
 	StageProgression map[string][]string    `json:"stage_progression"`
 	Period           map[string]interface{} `json:"period"`
+	Labels           *LocalizedLabels       `json:"labels,omitempty"`
+}
+
+// LocalizedLabels maps status, growth stage and plant condition codes to
+// display labels in one locale
+type LocalizedLabels struct {
+	Locale       string            `json:"locale"`
+	Statuses     map[string]string `json:"statuses"`
+	GrowthStages map[string]string `json:"growth_stages"`
+	Conditions   map[string]string `json:"conditions"`
+}
+
+// VocabularyMatch is the growth stage, plant condition or pest a term names,
+// with all the terms that name it
+type VocabularyMatch struct {
+	Kind  string   `json:"kind"` // growth_stage, condition or pest
+	Code  string   `json:"code"`
+	Terms []string `json:"terms"` // normalized: lowercase with single spaces
 }
 
 // ReportData represents report data
@@ -182,7 +1631,130 @@ type ReportData struct {
 	GeneratedAt time.Time   `json:"generated_at"`
 }
 
-type GoogleUserInfo struct {
+// TraitDefinition describes one trait measurement in the trait registry
+type TraitDefinition struct {
+	Key         string     `json:"key" firestore:"key"`
+	Label       string     `json:"label" firestore:"label"`
+	Type        string     `json:"type" firestore:"type"` // JSON Schema type, number or integer
+	Unit        string     `json:"unit" firestore:"unit"`
+	Description string     `json:"description" firestore:"description"`
+	Min         *float64   `json:"min,omitempty" firestore:"min,omitempty"` // bounds of custom trait values
+	Max         *float64   `json:"max,omitempty" firestore:"max,omitempty"`
+	Custom      bool       `json:"custom,omitempty" firestore:"custom"` // defined by the deployment, recorded under trait_measurements.custom
+	CreatedBy   string     `json:"created_by,omitempty" firestore:"created_by,omitempty"`
+	CreatedAt   *time.Time `json:"created_at,omitempty" firestore:"created_at,omitempty"`
+	UpdatedAt   *time.Time `json:"updated_at,omitempty" firestore:"updated_at,omitempty"`
+}
+
+// CreateTraitRequest defines a custom trait
+type CreateTraitRequest struct {
+	Key         string   `json:"key" binding:"required,max=40"` // e.g. plant_height, used as trait_measurements.custom.plant_height
+	Label       string   `json:"label" binding:"required,max=100"`
+	Type        string   `json:"type" binding:"required,oneof=number integer"`
+	Unit        string   `json:"unit" binding:"required,max=20"` // e.g. cm, count, SPAD
+	Description string   `json:"description" binding:"max=500"`
+	Min         *float64 `json:"min"`
+	Max         *float64 `json:"max"`
+}
+
+// UpdateTraitRequest changes a custom trait. Its key and type are fixed once
+// values are recorded.
+type UpdateTraitRequest struct {
+	Label       *string  `json:"label" binding:"omitempty,max=100"`
+	Unit        *string  `json:"unit" binding:"omitempty,max=20"`
+	Description *string  `json:"description" binding:"omitempty,max=500"`
+	Min         *float64 `json:"min"`
+	Max         *float64 `json:"max"`
+}
+
+// ExportColumn describes one column of a CSV or XLSX export
+type ExportColumn struct {
+	Name        string `json:"name"`
+	Type        string `json:"type"`
+	Unit        string `json:"unit,omitempty"`
+	Description string `json:"description"`
+}
+
+// VocabularyTerm is one code of a controlled vocabulary with its display labels by locale
+type VocabularyTerm struct {
+	Code   string            `json:"code"`
+	Labels map[string]string `json:"labels"`
+}
+
+// DataDictionary documents submissions, their traits, vocabularies, units and
+// export columns for downstream analysts
+type DataDictionary struct {
+	GeneratedAt  time.Time                   `json:"generated_at"`
+	Schema       map[string]interface{}      `json:"schema"` // JSON Schema of a submission
+	Traits       []TraitDefinition           `json:"traits"`
+	Vocabularies map[string][]VocabularyTerm `json:"vocabularies"`
+	Units        map[string]string           `json:"units"`
+	Exports      map[string][]ExportColumn   `json:"exports"`
+}
+
+// ConfigBundle is the configuration mobile clients cache to fill in forms
+// offline. Each section has its own version, so clients only download the
+// sections that changed.
+type ConfigBundle struct {
+	Version  string                   `json:"version"` // changes whenever any section changes
+	Sections map[string]ConfigSection `json:"sections"`
+}
+
+// ConfigSection is one versioned part of a ConfigBundle
+type ConfigSection struct {
+	Version string      `json:"version"`
+	Data    interface{} `json:"data"`
+}
+
+// ConfigManifest lists the current version of the bundle and of each section
+type ConfigManifest struct {
+	Version  string            `json:"version"`
+	Sections map[string]string `json:"sections"`
+}
+
+// FormTemplate describes the fields of an observation form
+type FormTemplate struct {
+	Key    string      `json:"key"`
+	Title  string      `json:"title"`
+	Fields []FormField `json:"fields"`
+}
+
+// FormField is one input of a FormTemplate
+type FormField struct {
+	Name     string   `json:"name"` // JSON name in the submission request
+	Label    string   `json:"label"`
+	Type     string   `json:"type"` // text, number, integer, date, choice, multi_choice, photo or location
+	Unit     string   `json:"unit,omitempty"`
+	Options  []string `json:"options,omitempty"` // codes offered by choice and multi_choice fields
+	Required bool     `json:"required"`
+}
+
+// CropCalendar gives the expected timing of growth stages after transplanting
+type CropCalendar struct {
+	GDDPerDay float64             `json:"gdd_per_day"` // typical daily degree days, to convert thresholds to days
+	Stages    []CropCalendarStage `json:"stages"`
+}
+
+// CropCalendarStage is when a growth stage is expected after transplanting
+type CropCalendarStage struct {
+	Stage    string  `json:"stage"`
+	StartGDD float64 `json:"start_gdd"`
+	EndGDD   float64 `json:"end_gdd"`
+	StartDay int     `json:"start_day"` // days after transplanting at the typical rate
+	EndDay   int     `json:"end_day"`
+	Critical bool    `json:"critical"` // its traits can only be measured while it lasts
+}
+
+// Migration marks a one-off data migration as completed so it is not run at
+// every startup
+type Migration struct {
+	Name        string    `json:"name" firestore:"name"`
+	Updated     int       `json:"updated" firestore:"updated"` // documents the migration changed
+	CompletedAt time.Time `json:"completed_at" firestore:"completed_at"`
+}
+
+// OAuthUserInfo is the profile a social login provider vouches for
+type OAuthUserInfo struct {
 	Email   string
 	Name    string
 	Picture string
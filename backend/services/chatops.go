@@ -0,0 +1,274 @@
+package services
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"log"
+	"net/http"
+	"strings"
+	"time"
+
+	"rice-monitor-api/models"
+	"rice-monitor-api/utils"
+
+	"cloud.google.com/go/firestore"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+)
+
+// Chat events an integration can subscribe to
+const (
+	ChatEventPestAlert     = "pest_alert"     // a submission reports pest infestation
+	ChatEventReviewBacklog = "review_backlog" // more submissions wait for review than the threshold
+	ChatEventWeeklySummary = "weekly_summary" // Monday summary of the past week
+)
+
+// ChatEvents lists the events integrations can subscribe to
+var ChatEvents = []string{ChatEventPestAlert, ChatEventReviewBacklog, ChatEventWeeklySummary}
+
+// pestCondition is the plant condition that raises a pest alert
+const pestCondition = "Signs of pest infestation"
+
+// ChatOpsService posts events to the Slack and Google Chat webhooks configured
+// as integrations. Pest alerts are posted as submissions arrive; the backlog
+// check runs every day at midnight UTC and the summary every Monday.
+type ChatOpsService struct {
+	firestoreService *FirestoreService
+	client           *http.Client
+	stop             chan struct{}
+}
+
+func NewChatOpsService(firestoreService *FirestoreService) *ChatOpsService {
+	return &ChatOpsService{
+		firestoreService: firestoreService,
+		client:           &http.Client{Timeout: 10 * time.Second},
+		stop:             make(chan struct{}),
+	}
+}
+
+// Start runs the daily checks until Stop is called
+func (cs *ChatOpsService) Start(ctx context.Context) error {
+	go func() {
+		for {
+			next := nextReportTime("daily", time.Now().UTC())
+			timer := time.NewTimer(time.Until(next))
+
+			select {
+			case <-cs.stop:
+				timer.Stop()
+				return
+			case <-timer.C:
+			}
+
+			if err := cs.Run(next); err != nil {
+				log.Printf("Failed to post scheduled chat notifications: %v", err)
+			}
+		}
+	}()
+	return nil
+}
+
+// Stop cancels the schedule. Messages already being posted are not interrupted.
+func (cs *ChatOpsService) Stop(ctx context.Context) error {
+	close(cs.stop)
+	return nil
+}
+
+// Run posts the review backlog for the day ending at now and, on Mondays, the
+// weekly summary. Each is claimed by date first, so when several instances run
+// the schedule only one of them posts it.
+func (cs *ChatOpsService) Run(now time.Time) error {
+	if claimed, err := cs.claim(ChatEventReviewBacklog, now); err != nil {
+		return err
+	} else if claimed {
+		if err := cs.postReviewBacklog(); err != nil {
+			log.Printf("Failed to post review backlog: %v", err)
+		}
+	}
+
+	if now.Weekday() != time.Monday {
+		return nil
+	}
+	if claimed, err := cs.claim(ChatEventWeeklySummary, now); err != nil || !claimed {
+		return err
+	}
+	return cs.postWeeklySummary(now)
+}
+
+// SubmissionCreated posts a pest alert when the submission reports pests
+func (cs *ChatOpsService) SubmissionCreated(submission *models.Submission) {
+	if submission.Status == "draft" || !utils.Contains(submission.PlantConditions, pestCondition) {
+		return
+	}
+
+	fieldName := submission.FieldID
+	doc, err := cs.firestoreService.Fields().Doc(submission.FieldID).Get(cs.firestoreService.Context())
+	if err == nil {
+		var field models.Field
+		doc.DataTo(&field)
+		fieldName = field.Name
+	}
+
+	text := fmt.Sprintf(":bug: Pest alert: %s reported signs of pest infestation in %s on %s (%s).",
+		submission.ObserverName, fieldName, utils.FormatDate(submission.Date), submission.GrowthStage)
+	if submission.Notes != "" {
+		text += "\n> " + submission.Notes
+	}
+	cs.Notify(ChatEventPestAlert, text)
+}
+
+// Notify posts text to every enabled integration subscribed to event.
+// Failures are recorded on the integration and never returned to the caller.
+func (cs *ChatOpsService) Notify(event, text string) {
+	integrations, err := cs.subscribers(event)
+	if err != nil {
+		log.Printf("Failed to load %s integrations: %v", event, err)
+		return
+	}
+	for _, integration := range integrations {
+		cs.deliver(&integration, text)
+	}
+}
+
+// Post sends text to the integration's webhook. Slack and Google Chat
+// incoming webhooks both accept a JSON body with a text field.
+func (cs *ChatOpsService) Post(integration *models.Integration, text string) error {
+	body, err := json.Marshal(map[string]string{"text": text})
+	if err != nil {
+		return err
+	}
+
+	resp, err := cs.client.Post(integration.WebhookURL, "application/json; charset=utf-8", bytes.NewReader(body))
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode >= 300 {
+		return fmt.Errorf("webhook returned status %d", resp.StatusCode)
+	}
+	return nil
+}
+
+// deliver posts text and records the outcome on the integration
+func (cs *ChatOpsService) deliver(integration *models.Integration, text string) {
+	lastError := ""
+	if err := cs.Post(integration, text); err != nil {
+		log.Printf("Failed to post to integration %s: %v", integration.ID, err)
+		lastError = err.Error()
+	}
+
+	ctx := cs.firestoreService.Context()
+	_, err := cs.firestoreService.Integrations().Doc(integration.ID).Update(ctx, []firestore.Update{
+		{Path: "last_delivery_at", Value: time.Now()},
+		{Path: "last_error", Value: lastError},
+	})
+	if err != nil {
+		log.Printf("Failed to record delivery for integration %s: %v", integration.ID, err)
+	}
+}
+
+func (cs *ChatOpsService) subscribers(event string) ([]models.Integration, error) {
+	docs, err := cs.firestoreService.Integrations().
+		Where("enabled", "==", true).
+		Where("events", "array-contains", event).
+		Documents(cs.firestoreService.Context()).GetAll()
+	if err != nil {
+		return nil, err
+	}
+
+	integrations := make([]models.Integration, 0, len(docs))
+	for _, doc := range docs {
+		var integration models.Integration
+		doc.DataTo(&integration)
+		integrations = append(integrations, integration)
+	}
+	return integrations, nil
+}
+
+// claim records that the scheduled event for the date of now is being posted.
+// It returns false when another instance already claimed it.
+func (cs *ChatOpsService) claim(event string, now time.Time) (bool, error) {
+	id := event + "-" + utils.FormatDate(now)
+	ctx := cs.firestoreService.Context()
+	_, err := cs.firestoreService.ChatNotifications().Doc(id).Create(ctx, map[string]interface{}{
+		"event":      event,
+		"created_at": time.Now(),
+	})
+	if status.Code(err) == codes.AlreadyExists {
+		return false, nil
+	}
+	return err == nil, err
+}
+
+// postReviewBacklog posts the number of submissions waiting for review to the
+// integrations whose threshold it exceeds
+func (cs *ChatOpsService) postReviewBacklog() error {
+	integrations, err := cs.subscribers(ChatEventReviewBacklog)
+	if err != nil || len(integrations) == 0 {
+		return err
+	}
+
+	docs, err := cs.firestoreService.Submissions().
+		Where("status", "in", pendingReviewStatuses).
+		Documents(cs.firestoreService.Context()).GetAll()
+	if err != nil {
+		return err
+	}
+
+	pending := len(docs)
+	for _, integration := range integrations {
+		if pending <= integration.BacklogThreshold {
+			continue
+		}
+		text := fmt.Sprintf(":inbox_tray: Review backlog: %d submissions are waiting for review (threshold %d).",
+			pending, integration.BacklogThreshold)
+		cs.deliver(&integration, text)
+	}
+	return nil
+}
+
+// postWeeklySummary posts the submissions of the week ending at end
+func (cs *ChatOpsService) postWeeklySummary(end time.Time) error {
+	integrations, err := cs.subscribers(ChatEventWeeklySummary)
+	if err != nil || len(integrations) == 0 {
+		return err
+	}
+
+	start := end.AddDate(0, 0, -7)
+	docs, err := cs.firestoreService.Submissions().
+		Where("created_at", ">=", start).
+		Where("created_at", "<", end).
+		Documents(cs.firestoreService.Context()).GetAll()
+	if err != nil {
+		return err
+	}
+
+	statuses := map[string]int{}
+	fields := map[string]bool{}
+	pests := 0
+	for _, doc := range docs {
+		var submission models.Submission
+		doc.DataTo(&submission)
+		statuses[submission.Status]++
+		fields[submission.FieldID] = true
+		if utils.Contains(submission.PlantConditions, pestCondition) {
+			pests++
+		}
+	}
+
+	var b strings.Builder
+	fmt.Fprintf(&b, ":bar_chart: Weekly summary for %s to %s\n", utils.FormatDate(start), utils.FormatDate(end.AddDate(0, 0, -1)))
+	fmt.Fprintf(&b, "%d submissions from %d fields", len(docs), len(fields))
+	fmt.Fprintf(&b, " (%d approved, %d rejected, %d waiting for review)\n",
+		statuses["approved"], statuses["rejected"], statuses["submitted"]+statuses["under_review"])
+	fmt.Fprintf(&b, "%d reported signs of pest infestation", pests)
+
+	text := b.String()
+	for _, integration := range integrations {
+		cs.deliver(&integration, text)
+	}
+	return nil
+}
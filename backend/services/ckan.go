@@ -0,0 +1,111 @@
+package services
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"os"
+	"time"
+)
+
+// CKANService pushes dataset metadata to a CKAN open data portal.
+// It is disabled unless CKAN_URL and CKAN_API_KEY are set.
+type CKANService struct {
+	BaseURL      string
+	APIKey       string
+	Organization string
+	client       *http.Client
+}
+
+// CKANPackage is the subset of the CKAN package schema we publish
+type CKANPackage struct {
+	ID        string         `json:"id,omitempty"`
+	Name      string         `json:"name"`
+	Title     string         `json:"title"`
+	Notes     string         `json:"notes"`
+	LicenseID string         `json:"license_id,omitempty"`
+	OwnerOrg  string         `json:"owner_org,omitempty"`
+	Tags      []CKANTag      `json:"tags"`
+	Resources []CKANResource `json:"resources"`
+}
+
+type CKANTag struct {
+	Name string `json:"name"`
+}
+
+type CKANResource struct {
+	Name   string `json:"name"`
+	URL    string `json:"url"`
+	Format string `json:"format"`
+}
+
+type ckanResponse struct {
+	Success bool            `json:"success"`
+	Result  json.RawMessage `json:"result"`
+	Error   json.RawMessage `json:"error"`
+}
+
+func NewCKANService() *CKANService {
+	return &CKANService{
+		BaseURL:      os.Getenv("CKAN_URL"),
+		APIKey:       os.Getenv("CKAN_API_KEY"),
+		Organization: os.Getenv("CKAN_ORGANIZATION"),
+		client:       &http.Client{Timeout: 30 * time.Second},
+	}
+}
+
+// Enabled reports whether a CKAN portal is configured
+func (cs *CKANService) Enabled() bool {
+	return cs.BaseURL != "" && cs.APIKey != ""
+}
+
+// PushPackage creates the package on the portal, or updates it when pkg.ID is set.
+// It returns the CKAN package ID.
+func (cs *CKANService) PushPackage(ctx context.Context, pkg CKANPackage) (string, error) {
+	if pkg.OwnerOrg == "" {
+		pkg.OwnerOrg = cs.Organization
+	}
+
+	action := "package_create"
+	if pkg.ID != "" {
+		action = "package_update"
+	}
+
+	body, err := json.Marshal(pkg)
+	if err != nil {
+		return "", err
+	}
+
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost,
+		fmt.Sprintf("%s/api/3/action/%s", cs.BaseURL, action), bytes.NewReader(body))
+	if err != nil {
+		return "", err
+	}
+	req.Header.Set("Content-Type", "application/json")
+	req.Header.Set("Authorization", cs.APIKey)
+
+	resp, err := cs.client.Do(req)
+	if err != nil {
+		return "", err
+	}
+	defer resp.Body.Close()
+
+	var result ckanResponse
+	if err := json.NewDecoder(resp.Body).Decode(&result); err != nil {
+		return "", fmt.Errorf("ckan %s: unexpected response (status %d): %v", action, resp.StatusCode, err)
+	}
+	if !result.Success {
+		return "", fmt.Errorf("ckan %s failed: %s", action, string(result.Error))
+	}
+
+	var created struct {
+		ID string `json:"id"`
+	}
+	if err := json.Unmarshal(result.Result, &created); err != nil {
+		return "", err
+	}
+
+	return created.ID, nil
+}
@@ -0,0 +1,145 @@
+package services
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"sort"
+	"strings"
+
+	"rice-monitor-api/models"
+	"rice-monitor-api/utils"
+)
+
+// ConfigSections lists the sections of the configuration bundle
+var ConfigSections = []string{"vocabularies", "traits", "form_templates", "crop_calendars"}
+
+// ConfigService assembles the configuration bundle clients cache for offline
+// forms. The configuration is built into the API, so the bundle is built once
+// and its versions only change with a new release.
+type ConfigService struct {
+	bundle models.ConfigBundle
+}
+
+func NewConfigService(vocabularyService *VocabularyService) *ConfigService {
+	labels := map[string]*models.LocalizedLabels{}
+	for _, locale := range vocabularyService.Locales() {
+		labels[locale], _ = vocabularyService.Labels(locale)
+	}
+
+	data := map[string]interface{}{
+		"vocabularies": map[string]interface{}{
+			"statuses":         utils.SubmissionStatuses,
+			"growth_stages":    vocabularyService.GrowthStages(),
+			"plant_conditions": vocabularyService.PlantConditions(),
+			"labels":           labels,
+			"synonyms":         vocabularyService.Synonyms(),
+		},
+		"traits":         utils.Traits,
+		"form_templates": formTemplates(vocabularyService),
+		"crop_calendars": map[string]models.CropCalendar{
+			"transplanted_rice": CriticalCropCalendar(),
+		},
+	}
+
+	bundle := models.ConfigBundle{Sections: map[string]models.ConfigSection{}}
+	for _, name := range ConfigSections {
+		bundle.Sections[name] = models.ConfigSection{
+			Version: contentVersion(data[name]),
+			Data:    data[name],
+		}
+	}
+	bundle.Version = combinedVersion(bundle.Sections)
+
+	return &ConfigService{bundle: bundle}
+}
+
+// Manifest returns the versions of the bundle and its sections
+func (cs *ConfigService) Manifest() models.ConfigManifest {
+	manifest := models.ConfigManifest{
+		Version:  cs.bundle.Version,
+		Sections: map[string]string{},
+	}
+	for name, section := range cs.bundle.Sections {
+		manifest.Sections[name] = section.Version
+	}
+	return manifest
+}
+
+// Bundle returns the named sections, or every section when names is empty.
+// Unknown names are ignored; the version covers only the sections returned.
+func (cs *ConfigService) Bundle(names []string) models.ConfigBundle {
+	if len(names) == 0 {
+		return cs.bundle
+	}
+
+	bundle := models.ConfigBundle{Sections: map[string]models.ConfigSection{}}
+	for _, name := range names {
+		if section, ok := cs.bundle.Sections[name]; ok {
+			bundle.Sections[name] = section
+		}
+	}
+	bundle.Version = combinedVersion(bundle.Sections)
+	return bundle
+}
+
+// contentVersion hashes the JSON encoding of data. Maps encode with sorted
+// keys, so equal content always has the same version.
+func contentVersion(data interface{}) string {
+	encoded, _ := json.Marshal(data)
+	sum := sha256.Sum256(encoded)
+	return hex.EncodeToString(sum[:8])
+}
+
+func combinedVersion(sections map[string]models.ConfigSection) string {
+	names := make([]string, 0, len(sections))
+	for name := range sections {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+
+	parts := make([]string, len(names))
+	for i, name := range names {
+		parts[i] = name + "=" + sections[name].Version
+	}
+	return contentVersion(strings.Join(parts, ";"))
+}
+
+// formTemplates describes the observation forms of the app
+func formTemplates(vocabularyService *VocabularyService) []models.FormTemplate {
+	traits := make([]models.FormField, len(utils.Traits))
+	for i, trait := range utils.Traits {
+		traits[i] = models.FormField{
+			Name:  "trait_measurements." + trait.Key,
+			Label: trait.Label,
+			Type:  trait.Type,
+			Unit:  trait.Unit,
+		}
+	}
+
+	observation := []models.FormField{
+		{Name: "field_id", Label: "Field", Type: "text", Required: true},
+		{Name: "date", Label: "Date", Type: "date", Required: true},
+		{Name: "growth_stage", Label: "Growth Stage", Type: "choice", Options: vocabularyService.GrowthStages(), Required: true},
+		{Name: "plant_conditions", Label: "Plant Conditions", Type: "multi_choice", Options: vocabularyService.PlantConditions()},
+	}
+	observation = append(observation, traits...)
+	observation = append(observation,
+		models.FormField{Name: "hill_id", Label: "Hill", Type: "text"},
+		models.FormField{Name: "notes", Label: "Notes", Type: "text"},
+		models.FormField{Name: "observer_name", Label: "Observer", Type: "text", Required: true},
+		models.FormField{Name: "images", Label: "Photos", Type: "photo"},
+	)
+
+	return []models.FormTemplate{
+		{Key: "observation", Title: "Field Observation", Fields: observation},
+		{Key: "community", Title: "Community Observation", Fields: []models.FormField{
+			{Name: "field_id", Label: "Field", Type: "text"},
+			{Name: "image", Label: "Photo", Type: "photo", Required: true},
+			{Name: "growth_stage", Label: "Growth Stage", Type: "choice", Options: vocabularyService.GrowthStages(), Required: true},
+			{Name: "condition", Label: "Condition", Type: "choice", Options: vocabularyService.PlantConditions(), Required: true},
+			{Name: "coordinates", Label: "Location", Type: "location", Required: true},
+			{Name: "date", Label: "Date", Type: "date"},
+		}},
+	}
+}
@@ -0,0 +1,68 @@
+package services
+
+import (
+	"rice-monitor-api/models"
+
+	"cloud.google.com/go/firestore"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+)
+
+// DataAgreementService looks up the data access agreements external
+// collaborators must accept before exporting data
+type DataAgreementService struct {
+	firestoreService *FirestoreService
+}
+
+func NewDataAgreementService(firestoreService *FirestoreService) *DataAgreementService {
+	return &DataAgreementService{
+		firestoreService: firestoreService,
+	}
+}
+
+// Current returns the latest agreement version of the organization orgID, or
+// nil if it has not published one
+func (ds *DataAgreementService) Current(orgID string) (*models.DataAgreement, error) {
+	ctx := ds.firestoreService.Context()
+	docs, err := ds.firestoreService.DataAgreements().
+		Where("org_id", "==", orgID).
+		OrderBy("version", firestore.Desc).
+		Limit(1).
+		Documents(ctx).GetAll()
+	if err != nil {
+		return nil, err
+	}
+	if len(docs) == 0 {
+		return nil, nil
+	}
+
+	var agreement models.DataAgreement
+	if err := docs[0].DataTo(&agreement); err != nil {
+		return nil, err
+	}
+	return &agreement, nil
+}
+
+// Acceptance returns userID's acceptance of agreement, or nil if they have not
+// accepted this version
+func (ds *DataAgreementService) Acceptance(userID string, agreement *models.DataAgreement) (*models.AgreementAcceptance, error) {
+	ctx := ds.firestoreService.Context()
+	doc, err := ds.firestoreService.AgreementAcceptances().Doc(AcceptanceID(agreement.ID, userID)).Get(ctx)
+	if status.Code(err) == codes.NotFound {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	var acceptance models.AgreementAcceptance
+	if err := doc.DataTo(&acceptance); err != nil {
+		return nil, err
+	}
+	return &acceptance, nil
+}
+
+// AcceptanceID is the ID of userID's acceptance of the agreement agreementID
+func AcceptanceID(agreementID, userID string) string {
+	return agreementID + "_" + userID
+}
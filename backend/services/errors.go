@@ -0,0 +1,166 @@
+package services
+
+import (
+	"bytes"
+	"context"
+	"crypto/rand"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/url"
+	"os"
+	"strings"
+	"time"
+)
+
+// ErrorReport describes an unexpected failure, such as a panic in a handler
+type ErrorReport struct {
+	Message   string
+	Stack     string
+	Method    string
+	URL       string
+	UserAgent string
+	RemoteIP  string
+	UserID    string
+	Time      time.Time
+}
+
+// ErrorReporter sends error reports to an error tracking service
+type ErrorReporter interface {
+	Report(ctx context.Context, report *ErrorReport) error
+}
+
+// NewErrorReporter returns a Sentry reporter when SENTRY_DSN is set. Otherwise
+// reports are written to stderr in the structured format Google Cloud Error
+// Reporting picks up from Cloud Run logs.
+func NewErrorReporter() ErrorReporter {
+	service := os.Getenv("K_SERVICE") // set by Cloud Run
+	if service == "" {
+		service = "rice-monitor-api"
+	}
+	environment := os.Getenv("ENVIRONMENT")
+
+	if dsn := os.Getenv("SENTRY_DSN"); dsn != "" {
+		reporter, err := newSentryReporter(dsn, environment)
+		if err == nil {
+			return reporter
+		}
+		fmt.Fprintf(os.Stderr, "Invalid SENTRY_DSN, falling back to Cloud Error Reporting: %v\n", err)
+	}
+
+	return &CloudErrorReporter{Service: service, Version: os.Getenv("K_REVISION")}
+}
+
+// CloudErrorReporter writes reports as structured log entries recognized by
+// Google Cloud Error Reporting
+type CloudErrorReporter struct {
+	Service string
+	Version string
+}
+
+func (cr *CloudErrorReporter) Report(ctx context.Context, report *ErrorReport) error {
+	entry := map[string]interface{}{
+		"@type":     "type.googleapis.com/google.devtools.clouderrorreporting.v1beta1.ReportedErrorEvent",
+		"severity":  "ERROR",
+		"eventTime": report.Time.Format(time.RFC3339Nano),
+		// Error Reporting groups events by the stack trace in the message
+		"message": report.Message + "\n\n" + report.Stack,
+		"serviceContext": map[string]string{
+			"service": cr.Service,
+			"version": cr.Version,
+		},
+		"context": map[string]interface{}{
+			"httpRequest": map[string]string{
+				"method":    report.Method,
+				"url":       report.URL,
+				"userAgent": report.UserAgent,
+				"remoteIp":  report.RemoteIP,
+			},
+			"user": report.UserID,
+		},
+	}
+
+	return json.NewEncoder(os.Stderr).Encode(entry)
+}
+
+// SentryReporter sends reports to Sentry's store endpoint
+type SentryReporter struct {
+	endpoint    string
+	publicKey   string
+	environment string
+	client      *http.Client
+}
+
+// newSentryReporter parses a DSN of the form https://<key>@<host>/<project>
+func newSentryReporter(dsn, environment string) (*SentryReporter, error) {
+	u, err := url.Parse(dsn)
+	if err != nil {
+		return nil, err
+	}
+	if u.User == nil || u.User.Username() == "" {
+		return nil, fmt.Errorf("missing public key")
+	}
+	project := strings.TrimPrefix(u.Path, "/")
+	if project == "" {
+		return nil, fmt.Errorf("missing project ID")
+	}
+
+	return &SentryReporter{
+		endpoint:    fmt.Sprintf("%s://%s/api/%s/store/", u.Scheme, u.Host, project),
+		publicKey:   u.User.Username(),
+		environment: environment,
+		client:      &http.Client{Timeout: 5 * time.Second},
+	}, nil
+}
+
+func (sr *SentryReporter) Report(ctx context.Context, report *ErrorReport) error {
+	eventID := make([]byte, 16)
+	if _, err := rand.Read(eventID); err != nil {
+		return err
+	}
+
+	event := map[string]interface{}{
+		"event_id":    hex.EncodeToString(eventID),
+		"timestamp":   report.Time.UTC().Format(time.RFC3339),
+		"level":       "error",
+		"platform":    "go",
+		"environment": sr.environment,
+		"message":     report.Message,
+		"request": map[string]interface{}{
+			"method":  report.Method,
+			"url":     report.URL,
+			"headers": map[string]string{"User-Agent": report.UserAgent},
+		},
+		"user": map[string]string{
+			"id":         report.UserID,
+			"ip_address": report.RemoteIP,
+		},
+		"extra": map[string]string{
+			"stack": report.Stack,
+		},
+	}
+
+	body, err := json.Marshal(event)
+	if err != nil {
+		return err
+	}
+
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, sr.endpoint, bytes.NewReader(body))
+	if err != nil {
+		return err
+	}
+	req.Header.Set("Content-Type", "application/json")
+	req.Header.Set("X-Sentry-Auth", fmt.Sprintf("Sentry sentry_version=7, sentry_client=rice-monitor-api/1.0, sentry_key=%s", sr.publicKey))
+
+	resp, err := sr.client.Do(req)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode != http.StatusOK {
+		return fmt.Errorf("sentry: unexpected status %d", resp.StatusCode)
+	}
+	return nil
+}
@@ -0,0 +1,284 @@
+package services
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"fmt"
+	"log"
+	"os"
+	"path"
+	"strconv"
+	"strings"
+	"time"
+
+	"rice-monitor-api/models"
+	"rice-monitor-api/utils"
+
+	"cloud.google.com/go/firestore"
+	"cloud.google.com/go/storage"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+)
+
+// ExportFunc builds the file of a scheduled export from the submissions user
+// may export that were created between from and to, returning how many rows it holds
+type ExportFunc func(user *models.User, schedule *models.ExportSchedule, from, to time.Time) ([]byte, int, error)
+
+// exportContentTypes are the content types of the scheduled export formats
+var exportContentTypes = map[string]string{
+	"csv":     "text/csv",
+	"xlsx":    "application/vnd.openxmlformats-officedocument.spreadsheetml.sheet",
+	"geojson": "application/geo+json",
+}
+
+// errOwnerCannotExport stops the schedules of owners no longer allowed to export
+var errOwnerCannotExport = errors.New("owner can no longer export")
+
+// ExportScheduler runs the users' scheduled exports at midnight UTC: weekly
+// ones on Mondays and monthly ones on the 1st. Files are written to the bucket
+// under scheduled-exports/, and a signed link is emailed for email delivery.
+type ExportScheduler struct {
+	firestoreService     *FirestoreService
+	storageService       *StorageService
+	mailerService        *MailerService
+	dataAgreementService *DataAgreementService
+	export               ExportFunc
+	enabled              bool
+	linkTTL              time.Duration // how long emailed links stay valid
+	stop                 chan struct{}
+}
+
+func NewExportScheduler(firestoreService *FirestoreService, storageService *StorageService, mailerService *MailerService, dataAgreementService *DataAgreementService, export ExportFunc) *ExportScheduler {
+	hours, err := strconv.Atoi(os.Getenv("SCHEDULED_EXPORT_LINK_TTL_HOURS"))
+	// V4 signed URLs are valid for at most 7 days
+	if err != nil || hours <= 0 || hours > 168 {
+		hours = 168
+	}
+
+	return &ExportScheduler{
+		firestoreService:     firestoreService,
+		storageService:       storageService,
+		mailerService:        mailerService,
+		dataAgreementService: dataAgreementService,
+		export:               export,
+		enabled:              strings.ToLower(os.Getenv("SCHEDULED_EXPORTS")) != "off",
+		linkTTL:              time.Duration(hours) * time.Hour,
+		stop:                 make(chan struct{}),
+	}
+}
+
+// Start runs the due exports at every midnight UTC until Stop is called
+func (es *ExportScheduler) Start(ctx context.Context) error {
+	if !es.enabled {
+		log.Println("Scheduled exports disabled")
+		return nil
+	}
+
+	go func() {
+		for {
+			next := nextReportTime("daily", time.Now().UTC())
+			timer := time.NewTimer(time.Until(next))
+
+			select {
+			case <-es.stop:
+				timer.Stop()
+				return
+			case <-timer.C:
+			}
+
+			if err := es.Run(next); err != nil {
+				log.Printf("Failed to run scheduled exports: %v", err)
+			}
+		}
+	}()
+	return nil
+}
+
+// Stop ends the schedule loop
+func (es *ExportScheduler) Stop(ctx context.Context) error {
+	if es.enabled {
+		close(es.stop)
+	}
+	return nil
+}
+
+// Run runs the exports due at now, a midnight UTC. Each schedule runs at most
+// once per period, even when several instances run at the same time.
+func (es *ExportScheduler) Run(now time.Time) error {
+	var frequencies []string
+	if now.Weekday() == time.Monday {
+		frequencies = append(frequencies, "weekly")
+	}
+	if now.Day() == 1 {
+		frequencies = append(frequencies, "monthly")
+	}
+	if len(frequencies) == 0 {
+		return nil
+	}
+
+	ctx := es.firestoreService.Context()
+	docs, err := es.firestoreService.ExportSchedules().
+		Where("active", "==", true).
+		Where("frequency", "in", frequencies).
+		Documents(ctx).GetAll()
+	if err != nil {
+		return err
+	}
+
+	for _, doc := range docs {
+		var schedule models.ExportSchedule
+		doc.DataTo(&schedule)
+		es.runSchedule(&schedule, now)
+	}
+	return nil
+}
+
+// runSchedule exports the period of the schedule ending at now and records the run
+func (es *ExportScheduler) runSchedule(schedule *models.ExportSchedule, now time.Time) {
+	from := now.AddDate(0, 0, -7)
+	if schedule.Frequency == "monthly" {
+		from = now.AddDate(0, -1, 0)
+	}
+
+	run := models.ExportRun{
+		ID:          fmt.Sprintf("%s-%s", schedule.ID, now.Format("2006-01-02")),
+		ScheduleID:  schedule.ID,
+		UserID:      schedule.UserID,
+		Name:        schedule.Name,
+		Format:      schedule.Format,
+		Delivery:    schedule.Delivery,
+		PeriodStart: from,
+		PeriodEnd:   now,
+		Status:      "running",
+		CreatedAt:   time.Now(),
+	}
+
+	ctx := es.firestoreService.Context()
+	runRef := es.firestoreService.ExportRuns().Doc(run.ID)
+	if _, err := runRef.Create(ctx, run); err != nil {
+		if status.Code(err) != codes.AlreadyExists {
+			log.Printf("Failed to start scheduled export %s: %v", schedule.ID, err)
+		}
+		return
+	}
+
+	err := es.deliver(schedule, &run)
+	completedAt := time.Now()
+	run.CompletedAt = &completedAt
+	run.Status = "done"
+	if err != nil {
+		log.Printf("Scheduled export %s failed: %v", schedule.ID, err)
+		run.Status = "failed"
+		run.Error = err.Error()
+	}
+	if _, err := runRef.Set(ctx, run); err != nil {
+		log.Printf("Failed to record scheduled export run %s: %v", run.ID, err)
+	}
+
+	updates := []firestore.Update{
+		{Path: "last_run_at", Value: completedAt},
+		{Path: "updated_at", Value: completedAt},
+	}
+	if errors.Is(err, errOwnerCannotExport) {
+		updates = append(updates, firestore.Update{Path: "active", Value: false})
+	}
+	if _, err := es.firestoreService.ExportSchedules().Doc(schedule.ID).Update(ctx, updates); err != nil {
+		log.Printf("Failed to update scheduled export %s: %v", schedule.ID, err)
+	}
+}
+
+// deliver builds the export of the run's period, writes it to the bucket and
+// emails a link to it for email delivery
+func (es *ExportScheduler) deliver(schedule *models.ExportSchedule, run *models.ExportRun) error {
+	user, err := es.owner(schedule)
+	if err != nil {
+		return err
+	}
+
+	data, rows, err := es.export(user, schedule, run.PeriodStart, run.PeriodEnd)
+	if err != nil {
+		return fmt.Errorf("failed to build export: %w", err)
+	}
+	run.Rows = rows
+
+	folder := path.Join("scheduled-exports", schedule.UserID, schedule.ID)
+	if schedule.Delivery == "gcs" && schedule.Path != "" {
+		folder = path.Join("scheduled-exports", schedule.UserID, schedule.Path)
+	}
+	run.Path = fmt.Sprintf("%s/%s.%s", folder, run.PeriodEnd.Format("2006-01-02"), schedule.Format)
+
+	wc := es.storageService.Bucket().Object(run.Path).NewWriter(es.storageService.Context())
+	wc.ContentType = exportContentTypes[schedule.Format]
+	if _, err := bytes.NewReader(data).WriteTo(wc); err != nil {
+		wc.Close()
+		return fmt.Errorf("failed to store export: %w", err)
+	}
+	if err := wc.Close(); err != nil {
+		return fmt.Errorf("failed to store export: %w", err)
+	}
+
+	if schedule.Delivery != "email" {
+		return nil
+	}
+
+	link, err := es.storageService.Bucket().SignedURL(run.Path, &storage.SignedURLOptions{
+		Method:  "GET",
+		Expires: time.Now().Add(es.linkTTL),
+		Scheme:  storage.SigningSchemeV4,
+	})
+	if err != nil {
+		return fmt.Errorf("failed to sign export link: %w", err)
+	}
+
+	recipients := schedule.Recipients
+	if len(recipients) == 0 {
+		recipients = []string{user.Email}
+	}
+	loc := utils.UserLocation(user)
+	subject := fmt.Sprintf("[Rice Monitor] %s export, %s to %s", schedule.Name,
+		run.PeriodStart.In(loc).Format("2 Jan"), run.PeriodEnd.Add(-time.Second).In(loc).Format("2 Jan 2006"))
+	body := fmt.Sprintf("Your %s export of %d submissions is ready:\n\n%s\n\nThe link expires on %s.\n",
+		schedule.Frequency, rows, link, time.Now().Add(es.linkTTL).In(loc).Format("2 January 2006 15:04 MST"))
+	if err := es.mailerService.Send(recipients, subject, body); err != nil {
+		return fmt.Errorf("failed to email export: %w", err)
+	}
+	return nil
+}
+
+// owner loads the schedule's owner, failing with errOwnerCannotExport once
+// they are suspended or deleted, or an external collaborator who has not
+// accepted the current data access agreement
+func (es *ExportScheduler) owner(schedule *models.ExportSchedule) (*models.User, error) {
+	doc, err := es.firestoreService.Users().Doc(schedule.UserID).Get(es.firestoreService.Context())
+	if status.Code(err) == codes.NotFound {
+		return nil, errOwnerCannotExport
+	}
+	if err != nil {
+		return nil, err
+	}
+	var user models.User
+	doc.DataTo(&user)
+	if utils.AccountSuspended(&user) {
+		return nil, errOwnerCannotExport
+	}
+	if !user.External {
+		return &user, nil
+	}
+
+	agreement, err := es.dataAgreementService.Current(user.OrgID)
+	if err != nil {
+		return nil, err
+	}
+	if agreement == nil {
+		return nil, errOwnerCannotExport
+	}
+	acceptance, err := es.dataAgreementService.Acceptance(user.ID, agreement)
+	if err != nil {
+		return nil, err
+	}
+	if acceptance == nil {
+		return nil, errOwnerCannotExport
+	}
+	return &user, nil
+}
@@ -0,0 +1,54 @@
+package services
+
+import (
+	"rice-monitor-api/models"
+	"rice-monitor-api/utils"
+
+	"cloud.google.com/go/firestore"
+)
+
+// BackfillFieldGeohashes stores the geohash of every field whose geohash is
+// missing or does not match its coordinates, such as fields created before
+// nearby searches, and returns how many it updated. It runs once, as the
+// field_geohash migration.
+func BackfillFieldGeohashes(firestoreService *FirestoreService) (int, error) {
+	ctx := firestoreService.Context()
+	docs, err := firestoreService.Fields().Select("coordinates", "geohash").Documents(ctx).GetAll()
+	if err != nil {
+		return 0, err
+	}
+
+	updated := 0
+	batch := firestoreService.Client.Batch()
+	pending := 0
+	for _, doc := range docs {
+		var field models.Field
+		doc.DataTo(&field)
+
+		geohash := utils.FieldGeohash(field.Coordinates)
+		if geohash == field.Geohash {
+			continue
+		}
+		var value interface{} = geohash
+		if geohash == "" {
+			value = firestore.Delete
+		}
+		batch.Update(doc.Ref, []firestore.Update{{Path: "geohash", Value: value}})
+		updated++
+
+		if pending++; pending == maxBatchWrites {
+			if _, err := batch.Commit(ctx); err != nil {
+				return 0, err
+			}
+			batch = firestoreService.Client.Batch()
+			pending = 0
+		}
+	}
+
+	if pending > 0 {
+		if _, err := batch.Commit(ctx); err != nil {
+			return 0, err
+		}
+	}
+	return updated, nil
+}
@@ -0,0 +1,59 @@
+package services
+
+import (
+	"reflect"
+
+	"rice-monitor-api/models"
+	"rice-monitor-api/utils"
+
+	"cloud.google.com/go/firestore"
+)
+
+// BackfillFieldListing stores the search terms and archived flag the field
+// listing filters on, on fields created before it did or whose terms are out
+// of date, and returns how many it updated. It runs once, as the field_listing
+// migration.
+func BackfillFieldListing(firestoreService *FirestoreService) (int, error) {
+	ctx := firestoreService.Context()
+	docs, err := firestoreService.Fields().Select("name", "location", "search_terms", "archived").Documents(ctx).GetAll()
+	if err != nil {
+		return 0, err
+	}
+
+	updated := 0
+	batch := firestoreService.Client.Batch()
+	pending := 0
+	for _, doc := range docs {
+		var field models.Field
+		doc.DataTo(&field)
+
+		var updates []firestore.Update
+		if terms := utils.SearchTerms(field.Name, field.Location); !reflect.DeepEqual(terms, field.SearchTerms) {
+			updates = append(updates, firestore.Update{Path: "search_terms", Value: terms})
+		}
+		// Equality filters do not match fields without the flag
+		if _, ok := doc.Data()["archived"]; !ok {
+			updates = append(updates, firestore.Update{Path: "archived", Value: false})
+		}
+		if len(updates) == 0 {
+			continue
+		}
+		batch.Update(doc.Ref, updates)
+		updated++
+
+		if pending++; pending == maxBatchWrites {
+			if _, err := batch.Commit(ctx); err != nil {
+				return 0, err
+			}
+			batch = firestoreService.Client.Batch()
+			pending = 0
+		}
+	}
+
+	if pending > 0 {
+		if _, err := batch.Commit(ctx); err != nil {
+			return 0, err
+		}
+	}
+	return updated, nil
+}
@@ -46,6 +46,216 @@ func (fs *FirestoreService) Fields() *firestore.CollectionRef {
 	return fs.Client.Collection("fields")
 }
 
+func (fs *FirestoreService) APIKeys() *firestore.CollectionRef {
+	return fs.Client.Collection("api_keys")
+}
+
+// PersonalAccessTokens holds users' scoped tokens for scripts, by hash
+func (fs *FirestoreService) PersonalAccessTokens() *firestore.CollectionRef {
+	return fs.Client.Collection("personal_access_tokens")
+}
+
+func (fs *FirestoreService) PasswordResets() *firestore.CollectionRef {
+	return fs.Client.Collection("password_resets")
+}
+
+// MagicLinks holds pending passwordless login links
+func (fs *FirestoreService) MagicLinks() *firestore.CollectionRef {
+	return fs.Client.Collection("magic_links")
+}
+
+func (fs *FirestoreService) EmailVerifications() *firestore.CollectionRef {
+	return fs.Client.Collection("email_verifications")
+}
+
+// UserEmails reserves each normalized email address, keyed by the address, so
+// concurrent signups cannot create duplicate accounts
+func (fs *FirestoreService) UserEmails() *firestore.CollectionRef {
+	return fs.Client.Collection("user_emails")
+}
+
+func (fs *FirestoreService) Datasets() *firestore.CollectionRef {
+	return fs.Client.Collection("datasets")
+}
+
+func (fs *FirestoreService) AnalyticsJobs() *firestore.CollectionRef {
+	return fs.Client.Collection("analytics_jobs")
+}
+
+// RoleChanges holds the audit trail of role changes
+func (fs *FirestoreService) RoleChanges() *firestore.CollectionRef {
+	return fs.Client.Collection("role_changes")
+}
+
+// Reputations holds community observer reputation, keyed by user ID
+func (fs *FirestoreService) Reputations() *firestore.CollectionRef {
+	return fs.Client.Collection("reputations")
+}
+
+func (fs *FirestoreService) ServiceAccounts() *firestore.CollectionRef {
+	return fs.Client.Collection("service_accounts")
+}
+
+func (fs *FirestoreService) EmbedTokens() *firestore.CollectionRef {
+	return fs.Client.Collection("embed_tokens")
+}
+
+func (fs *FirestoreService) Invites() *firestore.CollectionRef {
+	return fs.Client.Collection("invites")
+}
+
+func (fs *FirestoreService) Sessions() *firestore.CollectionRef {
+	return fs.Client.Collection("sessions")
+}
+
+// StressEvents holds the declared drought and flood events
+func (fs *FirestoreService) StressEvents() *firestore.CollectionRef {
+	return fs.Client.Collection("stress_events")
+}
+
+// FieldWeather holds the daily weather of each field, keyed by field and date
+func (fs *FirestoreService) FieldWeather() *firestore.CollectionRef {
+	return fs.Client.Collection("field_weather")
+}
+
+// VisitReminders records the critical window reminders already sent, keyed by field and window
+func (fs *FirestoreService) VisitReminders() *firestore.CollectionRef {
+	return fs.Client.Collection("visit_reminders")
+}
+
+// OverdueNotices records the overdue visit emails already sent, keyed by field, last visit and user
+func (fs *FirestoreService) OverdueNotices() *firestore.CollectionRef {
+	return fs.Client.Collection("overdue_notices")
+}
+
+// AuthEvents is the audit log of logins, token refreshes and logouts
+func (fs *FirestoreService) AuthEvents() *firestore.CollectionRef {
+	return fs.Client.Collection("auth_events")
+}
+
+// FieldNotes holds the notes and announcements of fields
+func (fs *FirestoreService) FieldNotes() *firestore.CollectionRef {
+	return fs.Client.Collection("field_notes")
+}
+
+// ReviewDecisions is the log of every review status decision
+func (fs *FirestoreService) ReviewDecisions() *firestore.CollectionRef {
+	return fs.Client.Collection("review_decisions")
+}
+
+// SubmissionCorrections logs edits made to submissions after their approval
+func (fs *FirestoreService) SubmissionCorrections() *firestore.CollectionRef {
+	return fs.Client.Collection("submission_corrections")
+}
+
+func (fs *FirestoreService) UserDeletions() *firestore.CollectionRef {
+	return fs.Client.Collection("user_deletions")
+}
+
+func (fs *FirestoreService) Calibrations() *firestore.CollectionRef {
+	return fs.Client.Collection("calibrations")
+}
+
+// TraitDefinitions holds the deployment's custom traits, keyed by trait key
+func (fs *FirestoreService) TraitDefinitions() *firestore.CollectionRef {
+	return fs.Client.Collection("trait_definitions")
+}
+
+func (fs *FirestoreService) UploadFailures() *firestore.CollectionRef {
+	return fs.Client.Collection("upload_failures")
+}
+
+// QualityReports holds the scheduled data quality reports, keyed by period and start date
+func (fs *FirestoreService) QualityReports() *firestore.CollectionRef {
+	return fs.Client.Collection("quality_reports")
+}
+
+// Integrations holds the team chat webhooks events are posted to
+func (fs *FirestoreService) Integrations() *firestore.CollectionRef {
+	return fs.Client.Collection("integrations")
+}
+
+// Webhooks holds the URLs submission events are delivered to
+func (fs *FirestoreService) Webhooks() *firestore.CollectionRef {
+	return fs.Client.Collection("webhooks")
+}
+
+// WebhookDeliveries logs every event sent to a webhook, pending ones waiting to be retried
+func (fs *FirestoreService) WebhookDeliveries() *firestore.CollectionRef {
+	return fs.Client.Collection("webhook_deliveries")
+}
+
+// ChatNotifications records the scheduled chat posts already sent, keyed by event and date
+func (fs *FirestoreService) ChatNotifications() *firestore.CollectionRef {
+	return fs.Client.Collection("chat_notifications")
+}
+
+// Organizations holds the research groups sharing the deployment
+func (fs *FirestoreService) Organizations() *firestore.CollectionRef {
+	return fs.Client.Collection("organizations")
+}
+
+// Seasons holds the crop seasons of every field
+func (fs *FirestoreService) Seasons() *firestore.CollectionRef {
+	return fs.Client.Collection("seasons")
+}
+
+// Farms holds the sites fields are grouped under
+func (fs *FirestoreService) Farms() *firestore.CollectionRef {
+	return fs.Client.Collection("farms")
+}
+
+// DataAgreements holds every version of the organizations' data access agreements
+func (fs *FirestoreService) DataAgreements() *firestore.CollectionRef {
+	return fs.Client.Collection("data_agreements")
+}
+
+// AgreementAcceptances records which users accepted which agreement version
+func (fs *FirestoreService) AgreementAcceptances() *firestore.CollectionRef {
+	return fs.Client.Collection("agreement_acceptances")
+}
+
+// NotificationSettings holds each user's notification settings, keyed by user ID
+func (fs *FirestoreService) NotificationSettings() *firestore.CollectionRef {
+	return fs.Client.Collection("notification_settings")
+}
+
+// IdempotencyKeys maps the idempotency keys of submission requests to the
+// submissions they created, keyed by a hash of user ID and key
+func (fs *FirestoreService) IdempotencyKeys() *firestore.CollectionRef {
+	return fs.Client.Collection("idempotency_keys")
+}
+
+// SubmissionAudit holds the per-path change log of every submission
+func (fs *FirestoreService) SubmissionAudit() *firestore.CollectionRef {
+	return fs.Client.Collection("submission_audit")
+}
+
+// SubmissionShares holds the public read-only links to single submissions
+func (fs *FirestoreService) SubmissionShares() *firestore.CollectionRef {
+	return fs.Client.Collection("submission_shares")
+}
+
+// SubmissionTags holds the tags used in each organization, keyed org_id:tag
+func (fs *FirestoreService) SubmissionTags() *firestore.CollectionRef {
+	return fs.Client.Collection("submission_tags")
+}
+
+// ExportSchedules holds the users' recurring submissions exports
+func (fs *FirestoreService) ExportSchedules() *firestore.CollectionRef {
+	return fs.Client.Collection("export_schedules")
+}
+
+// ExportRuns records each run of a scheduled export, keyed by schedule and period
+func (fs *FirestoreService) ExportRuns() *firestore.CollectionRef {
+	return fs.Client.Collection("export_runs")
+}
+
+// Migrations marks the one-off data migrations that completed, keyed by name
+func (fs *FirestoreService) Migrations() *firestore.CollectionRef {
+	return fs.Client.Collection("migrations")
+}
+
 // Context getter
 func (fs *FirestoreService) Context() context.Context {
 	return fs.ctx
@@ -0,0 +1,198 @@
+package services
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"log"
+	"os"
+	"strconv"
+	"sync"
+	"time"
+
+	"rice-monitor-api/models"
+
+	"cloud.google.com/go/firestore"
+)
+
+// ErrJobQueueFull is returned when no more jobs can be queued on this instance
+var ErrJobQueueFull = errors.New("job queue is full")
+
+// JobFunc computes the result of a background job
+type JobFunc func(ctx context.Context) (interface{}, error)
+
+type queuedJob struct {
+	id  string
+	run JobFunc
+}
+
+// JobService runs long analytics queries on a pool of in-process workers.
+// Job state lives in Firestore and results are written to the storage bucket
+// as JSON, so any instance can answer status requests.
+type JobService struct {
+	firestoreService *FirestoreService
+	storageService   *StorageService
+	queue            chan queuedJob
+	workers          int
+	stop             chan struct{}
+	wg               sync.WaitGroup
+}
+
+func NewJobService(firestoreService *FirestoreService, storageService *StorageService) *JobService {
+	workers, err := strconv.Atoi(os.Getenv("JOB_WORKERS"))
+	if err != nil || workers <= 0 {
+		workers = 2
+	}
+
+	return &JobService{
+		firestoreService: firestoreService,
+		storageService:   storageService,
+		queue:            make(chan queuedJob, 100),
+		workers:          workers,
+		stop:             make(chan struct{}),
+	}
+}
+
+// Start launches the worker pool
+func (js *JobService) Start(ctx context.Context) error {
+	for i := 0; i < js.workers; i++ {
+		js.wg.Add(1)
+		go js.work()
+	}
+	return nil
+}
+
+// Stop lets running jobs finish and stops the workers. Jobs still queued stay
+// "queued" in Firestore. It gives up when ctx is done.
+func (js *JobService) Stop(ctx context.Context) error {
+	close(js.stop)
+
+	done := make(chan struct{})
+	go func() {
+		js.wg.Wait()
+		close(done)
+	}()
+
+	select {
+	case <-done:
+		return nil
+	case <-ctx.Done():
+		return ctx.Err()
+	}
+}
+
+// Enqueue stores job as queued and hands run to the worker pool
+func (js *JobService) Enqueue(job *models.AnalyticsJob, run JobFunc) error {
+	ctx := js.firestoreService.Context()
+
+	job.Status = "queued"
+	job.CreatedAt = time.Now()
+	if _, err := js.firestoreService.AnalyticsJobs().Doc(job.ID).Set(ctx, job); err != nil {
+		return err
+	}
+
+	select {
+	case js.queue <- queuedJob{id: job.ID, run: run}:
+		return nil
+	default:
+		js.finish(job.ID, "failed", ErrJobQueueFull.Error(), "")
+		return ErrJobQueueFull
+	}
+}
+
+// Result reads the JSON result of a finished job from the bucket
+func (js *JobService) Result(job *models.AnalyticsJob) (json.RawMessage, error) {
+	if job.ResultPath == "" {
+		return nil, nil
+	}
+
+	r, err := js.storageService.Bucket().Object(job.ResultPath).NewReader(js.storageService.Context())
+	if err != nil {
+		return nil, err
+	}
+	defer r.Close()
+
+	data, err := io.ReadAll(r)
+	if err != nil {
+		return nil, err
+	}
+
+	return json.RawMessage(data), nil
+}
+
+func (js *JobService) work() {
+	defer js.wg.Done()
+	for {
+		select {
+		case <-js.stop:
+			return
+		case job := <-js.queue:
+			js.process(job)
+		}
+	}
+}
+
+func (js *JobService) process(job queuedJob) {
+	ctx := js.firestoreService.Context()
+	docRef := js.firestoreService.AnalyticsJobs().Doc(job.id)
+
+	_, err := docRef.Update(ctx, []firestore.Update{
+		{Path: "status", Value: "running"},
+		{Path: "started_at", Value: time.Now()},
+	})
+	if err != nil {
+		log.Printf("Failed to start job %s: %v", job.id, err)
+	}
+
+	result, err := js.run(ctx, job)
+	if err != nil {
+		log.Printf("Job %s failed: %v", job.id, err)
+		js.finish(job.id, "failed", err.Error(), "")
+		return
+	}
+
+	path := fmt.Sprintf("analytics-jobs/%s.json", job.id)
+	if err := js.writeResult(path, result); err != nil {
+		log.Printf("Failed to store result of job %s: %v", job.id, err)
+		js.finish(job.id, "failed", "Failed to store result", "")
+		return
+	}
+
+	js.finish(job.id, "done", "", path)
+}
+
+// run calls the job function, turning a panic into an error so the worker survives
+func (js *JobService) run(ctx context.Context, job queuedJob) (result interface{}, err error) {
+	defer func() {
+		if r := recover(); r != nil {
+			err = fmt.Errorf("job panicked: %v", r)
+		}
+	}()
+
+	return job.run(ctx)
+}
+
+func (js *JobService) writeResult(path string, result interface{}) error {
+	wc := js.storageService.Bucket().Object(path).NewWriter(js.storageService.Context())
+	wc.ContentType = "application/json"
+	if err := json.NewEncoder(wc).Encode(result); err != nil {
+		wc.Close()
+		return err
+	}
+	return wc.Close()
+}
+
+func (js *JobService) finish(id, status, message, resultPath string) {
+	ctx := js.firestoreService.Context()
+	_, err := js.firestoreService.AnalyticsJobs().Doc(id).Update(ctx, []firestore.Update{
+		{Path: "status", Value: status},
+		{Path: "error", Value: message},
+		{Path: "result_path", Value: resultPath},
+		{Path: "completed_at", Value: time.Now()},
+	})
+	if err != nil {
+		log.Printf("Failed to update job %s: %v", id, err)
+	}
+}
@@ -0,0 +1,62 @@
+package services
+
+import (
+	"fmt"
+	"log"
+	"net/smtp"
+	"os"
+	"strings"
+)
+
+// MailerService sends transactional email over SMTP.
+// When SMTP_HOST is not set, messages are logged instead of sent (local development).
+type MailerService struct {
+	Host     string
+	Port     string
+	Username string
+	Password string
+	From     string
+}
+
+func NewMailerService() *MailerService {
+	port := os.Getenv("SMTP_PORT")
+	if port == "" {
+		port = "587"
+	}
+
+	from := os.Getenv("MAIL_FROM")
+	if from == "" {
+		from = "no-reply@rice-monitor.com"
+	}
+
+	return &MailerService{
+		Host:     os.Getenv("SMTP_HOST"),
+		Port:     port,
+		Username: os.Getenv("SMTP_USERNAME"),
+		Password: os.Getenv("SMTP_PASSWORD"),
+		From:     from,
+	}
+}
+
+// Enabled reports whether an SMTP server is configured
+func (ms *MailerService) Enabled() bool {
+	return ms.Host != ""
+}
+
+// Send sends a plain text email to the given recipients
+func (ms *MailerService) Send(to []string, subject, body string) error {
+	if !ms.Enabled() {
+		log.Printf("SMTP not configured, email to %s not sent. Subject: %s\n%s", strings.Join(to, ", "), subject, body)
+		return nil
+	}
+
+	msg := fmt.Sprintf("From: %s\r\nTo: %s\r\nSubject: %s\r\nMIME-Version: 1.0\r\nContent-Type: text/plain; charset=\"utf-8\"\r\n\r\n%s",
+		ms.From, strings.Join(to, ", "), subject, body)
+
+	var auth smtp.Auth
+	if ms.Username != "" {
+		auth = smtp.PlainAuth("", ms.Username, ms.Password, ms.Host)
+	}
+
+	return smtp.SendMail(ms.Host+":"+ms.Port, auth, ms.From, to, []byte(msg))
+}
@@ -0,0 +1,168 @@
+package services
+
+import (
+	"context"
+	"log"
+	"os"
+	"strconv"
+	"strings"
+	"time"
+
+	"rice-monitor-api/models"
+	"rice-monitor-api/utils"
+
+	"cloud.google.com/go/firestore"
+	"cloud.google.com/go/storage"
+	"google.golang.org/api/iterator"
+)
+
+// MediaReconciler settles submissions whose photos could not be uploaded while
+// Storage was unavailable. Photos that reached the bucket without being linked
+// to their submission are linked, and submissions still waiting for photos
+// after the timeout are marked as missing them.
+type MediaReconciler struct {
+	firestoreService *FirestoreService
+	storageService   *StorageService
+	enabled          bool
+	interval         time.Duration
+	timeout          time.Duration // how long a submission may wait for its photos since it last changed
+	stop             chan struct{}
+}
+
+func NewMediaReconciler(firestoreService *FirestoreService, storageService *StorageService) *MediaReconciler {
+	minutes, err := strconv.Atoi(os.Getenv("MEDIA_RECONCILE_INTERVAL_MINUTES"))
+	if err != nil || minutes <= 0 {
+		minutes = 15
+	}
+	hours, err := strconv.Atoi(os.Getenv("MEDIA_PENDING_TIMEOUT_HOURS"))
+	if err != nil || hours <= 0 {
+		hours = 72
+	}
+
+	return &MediaReconciler{
+		firestoreService: firestoreService,
+		storageService:   storageService,
+		enabled:          strings.ToLower(os.Getenv("MEDIA_RECONCILER")) != "off",
+		interval:         time.Duration(minutes) * time.Minute,
+		timeout:          time.Duration(hours) * time.Hour,
+		stop:             make(chan struct{}),
+	}
+}
+
+// Start reconciles every interval until Stop is called
+func (mr *MediaReconciler) Start(ctx context.Context) error {
+	if !mr.enabled {
+		log.Println("Media reconciler disabled")
+		return nil
+	}
+
+	go func() {
+		ticker := time.NewTicker(mr.interval)
+		defer ticker.Stop()
+		for {
+			select {
+			case <-mr.stop:
+				return
+			case <-ticker.C:
+			}
+
+			if err := mr.Run(time.Now()); err != nil {
+				log.Printf("Failed to reconcile pending media: %v", err)
+			}
+		}
+	}()
+	return nil
+}
+
+// Stop cancels the schedule. A run already in progress is not interrupted.
+func (mr *MediaReconciler) Stop(ctx context.Context) error {
+	close(mr.stop)
+	return nil
+}
+
+// Run reconciles every submission whose media is pending. It stops at the
+// first submission whose photos cannot be listed, so nothing is marked
+// missing while Storage is still unavailable.
+func (mr *MediaReconciler) Run(now time.Time) error {
+	ctx := mr.firestoreService.Context()
+	docs, err := mr.firestoreService.Submissions().Where("media_status", "==", "pending").Documents(ctx).GetAll()
+	if err != nil {
+		return err
+	}
+
+	for _, doc := range docs {
+		uploaded, err := mr.uploadedImages(doc.Ref.ID)
+		if err != nil {
+			return err
+		}
+		if err := mr.reconcile(doc.Ref, uploaded, now); err != nil {
+			log.Printf("Failed to reconcile media of submission %s: %v", doc.Ref.ID, err)
+		}
+	}
+	return nil
+}
+
+// uploadedImages lists the URLs of the photos stored for a submission
+func (mr *MediaReconciler) uploadedImages(submissionID string) ([]string, error) {
+	ctx := mr.storageService.Context()
+	it := mr.storageService.Bucket().Objects(ctx, &storage.Query{Prefix: submissionID + "/"})
+
+	var urls []string
+	for {
+		attrs, err := it.Next()
+		if err == iterator.Done {
+			break
+		}
+		if err != nil {
+			return nil, err
+		}
+		urls = append(urls, mr.storageService.PublicURL(attrs.Name))
+	}
+	return urls, nil
+}
+
+// reconcile links the uploaded photos missing from the submission, counting
+// each against its pending images, and marks the media complete when none are
+// left or missing once the submission has waited longer than the timeout
+func (mr *MediaReconciler) reconcile(ref *firestore.DocumentRef, uploaded []string, now time.Time) error {
+	ctx := mr.firestoreService.Context()
+	return mr.firestoreService.Client.RunTransaction(ctx, func(ctx context.Context, tx *firestore.Transaction) error {
+		doc, err := tx.Get(ref)
+		if err != nil {
+			return err
+		}
+
+		var submission models.Submission
+		doc.DataTo(&submission)
+		if submission.MediaStatus != "pending" {
+			return nil
+		}
+
+		images, pending := submission.Images, submission.PendingImages
+		for _, url := range uploaded {
+			if !utils.Contains(images, url) {
+				images = append(images, url)
+				if pending > 0 {
+					pending--
+				}
+			}
+		}
+
+		status := "pending"
+		if pending == 0 {
+			status = "complete"
+		} else if now.Sub(submission.UpdatedAt) > mr.timeout {
+			status = "missing"
+		}
+		if status == submission.MediaStatus && len(images) == len(submission.Images) {
+			return nil
+		}
+
+		return tx.Update(ref, []firestore.Update{
+			{Path: "images", Value: images},
+			{Path: "pending_images", Value: pending},
+			{Path: "media_status", Value: status},
+			{Path: "updated_at", Value: now},
+		})
+	})
+}
@@ -0,0 +1,38 @@
+package services
+
+import (
+	"log"
+	"time"
+
+	"rice-monitor-api/models"
+
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+)
+
+// RunMigration runs a one-off data migration unless its marker document says
+// it already completed, and records the marker when it does. Migrations must
+// be safe to run again, since instances starting together may both run them.
+func RunMigration(firestoreService *FirestoreService, name string, migrate func() (int, error)) error {
+	ctx := firestoreService.Context()
+	_, err := firestoreService.Migrations().Doc(name).Get(ctx)
+	if err == nil {
+		return nil
+	}
+	if status.Code(err) != codes.NotFound {
+		return err
+	}
+
+	updated, err := migrate()
+	if err != nil {
+		return err
+	}
+	log.Printf("Migration %s updated %d documents", name, updated)
+
+	_, err = firestoreService.Migrations().Doc(name).Set(ctx, models.Migration{
+		Name:        name,
+		Updated:     updated,
+		CompletedAt: time.Now(),
+	})
+	return err
+}
@@ -0,0 +1,89 @@
+package services
+
+import (
+	"time"
+
+	"rice-monitor-api/models"
+
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+)
+
+// NotificationSettingsService stores what each user wants to be notified
+// about, for the notification senders to consult before sending
+type NotificationSettingsService struct {
+	firestoreService *FirestoreService
+}
+
+func NewNotificationSettingsService(firestoreService *FirestoreService) *NotificationSettingsService {
+	return &NotificationSettingsService{
+		firestoreService: firestoreService,
+	}
+}
+
+// DefaultNotificationSettings are the settings of a user who never saved any:
+// only rejections are emailed, so observers learn what to fix
+func DefaultNotificationSettings(userID string) models.NotificationSettings {
+	return models.NotificationSettings{
+		UserID:           userID,
+		EmailOnRejection: true,
+		DigestDay:        "monday",
+	}
+}
+
+// Get returns userID's settings, or the defaults if they never saved any
+func (ns *NotificationSettingsService) Get(userID string) (*models.NotificationSettings, error) {
+	ctx := ns.firestoreService.Context()
+	doc, err := ns.firestoreService.NotificationSettings().Doc(userID).Get(ctx)
+	if status.Code(err) == codes.NotFound {
+		settings := DefaultNotificationSettings(userID)
+		return &settings, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	var settings models.NotificationSettings
+	if err := doc.DataTo(&settings); err != nil {
+		return nil, err
+	}
+	return &settings, nil
+}
+
+// Update applies the values set in req to userID's settings and stores them
+func (ns *NotificationSettingsService) Update(userID string, req models.NotificationSettingsRequest) (*models.NotificationSettings, error) {
+	settings, err := ns.Get(userID)
+	if err != nil {
+		return nil, err
+	}
+
+	if req.EmailOnApproval != nil {
+		settings.EmailOnApproval = *req.EmailOnApproval
+	}
+	if req.EmailOnRejection != nil {
+		settings.EmailOnRejection = *req.EmailOnRejection
+	}
+	if req.WeeklyDigest != nil {
+		settings.WeeklyDigest = *req.WeeklyDigest
+	}
+	if req.DigestDay != nil {
+		settings.DigestDay = *req.DigestDay
+	}
+	if req.Alerts != nil {
+		settings.Alerts = *req.Alerts
+	}
+	settings.UpdatedAt = time.Now()
+
+	ctx := ns.firestoreService.Context()
+	if _, err := ns.firestoreService.NotificationSettings().Doc(userID).Set(ctx, settings); err != nil {
+		return nil, err
+	}
+	return settings, nil
+}
+
+// Reset deletes userID's settings, so the defaults apply again
+func (ns *NotificationSettingsService) Reset(userID string) error {
+	ctx := ns.firestoreService.Context()
+	_, err := ns.firestoreService.NotificationSettings().Doc(userID).Delete(ctx)
+	return err
+}
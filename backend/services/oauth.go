@@ -0,0 +1,319 @@
+package services
+
+import (
+	"context"
+	"crypto/rsa"
+	"encoding/base64"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"math/big"
+	"net/http"
+	"net/url"
+	"os"
+	"strings"
+	"sync"
+	"time"
+
+	"rice-monitor-api/models"
+	"rice-monitor-api/utils"
+
+	"github.com/golang-jwt/jwt/v4"
+	"google.golang.org/api/idtoken"
+)
+
+// ErrEmailNotVerified is returned when a provider does not vouch for the user's email.
+// Accounts are matched by email, so an unverified address could take over another account.
+var ErrEmailNotVerified = errors.New("provider did not verify the email address")
+
+// ErrDomainNotAllowed is returned when login is restricted to some email domains
+// and the account belongs to another one
+var ErrDomainNotAllowed = errors.New("email domain is not allowed to log in")
+
+// OAuthProvider validates a token issued by a social login provider and
+// returns the profile it proves
+type OAuthProvider interface {
+	Name() string
+	Verify(ctx context.Context, token string) (*models.OAuthUserInfo, error)
+}
+
+// NewOAuthProviders returns the configured providers keyed by name. Google is
+// always enabled and can be restricted to the comma separated email domains in
+// GOOGLE_ALLOWED_DOMAINS; Apple needs APPLE_CLIENT_ID and Facebook needs
+// FACEBOOK_APP_ID and FACEBOOK_APP_SECRET.
+func NewOAuthProviders() map[string]OAuthProvider {
+	client := &http.Client{Timeout: 10 * time.Second}
+
+	var allowedDomains []string
+	for _, domain := range strings.Split(os.Getenv("GOOGLE_ALLOWED_DOMAINS"), ",") {
+		domain = strings.ToLower(strings.TrimPrefix(strings.TrimSpace(domain), "@"))
+		if domain != "" {
+			allowedDomains = append(allowedDomains, domain)
+		}
+	}
+
+	providers := []OAuthProvider{
+		&GoogleProvider{ClientID: os.Getenv("GOOGLE_CLIENT_ID"), AllowedDomains: allowedDomains},
+	}
+	if clientID := os.Getenv("APPLE_CLIENT_ID"); clientID != "" {
+		providers = append(providers, &AppleProvider{ClientID: clientID, client: client})
+	}
+	if appID, secret := os.Getenv("FACEBOOK_APP_ID"), os.Getenv("FACEBOOK_APP_SECRET"); appID != "" && secret != "" {
+		providers = append(providers, &FacebookProvider{AppID: appID, AppSecret: secret, client: client})
+	}
+
+	byName := make(map[string]OAuthProvider, len(providers))
+	for _, provider := range providers {
+		byName[provider.Name()] = provider
+	}
+	return byName
+}
+
+// GoogleProvider validates Google Sign-In ID tokens
+type GoogleProvider struct {
+	ClientID       string
+	AllowedDomains []string // when set, only emails in these domains may log in
+}
+
+func (gp *GoogleProvider) Name() string {
+	return "google"
+}
+
+func (gp *GoogleProvider) Verify(ctx context.Context, token string) (*models.OAuthUserInfo, error) {
+	payload, err := idtoken.Validate(ctx, token, gp.ClientID)
+	if err != nil {
+		return nil, err
+	}
+
+	email, _ := payload.Claims["email"].(string)
+	name, _ := payload.Claims["name"].(string)
+	picture, _ := payload.Claims["picture"].(string)
+	verified, _ := payload.Claims["email_verified"].(bool)
+	if email == "" || !verified {
+		return nil, ErrEmailNotVerified
+	}
+
+	if len(gp.AllowedDomains) > 0 {
+		domain := strings.ToLower(email[strings.LastIndex(email, "@")+1:])
+		if !utils.Contains(gp.AllowedDomains, domain) {
+			return nil, ErrDomainNotAllowed
+		}
+	}
+
+	return &models.OAuthUserInfo{
+		Email:   email,
+		Name:    name,
+		Picture: picture,
+	}, nil
+}
+
+const (
+	appleIssuer  = "https://appleid.apple.com"
+	appleKeysURL = "https://appleid.apple.com/auth/keys"
+)
+
+// AppleProvider validates Sign in with Apple ID tokens against Apple's published keys
+type AppleProvider struct {
+	ClientID string
+	client   *http.Client
+
+	mu        sync.Mutex
+	keys      map[string]*rsa.PublicKey
+	fetchedAt time.Time
+}
+
+type appleClaims struct {
+	Email         string      `json:"email"`
+	EmailVerified interface{} `json:"email_verified"` // Apple sends either a bool or "true"
+	jwt.RegisteredClaims
+}
+
+func (ap *AppleProvider) Name() string {
+	return "apple"
+}
+
+// Verify checks the signature, issuer, audience and expiry of an Apple ID token.
+// Apple only shares the user's name with the app on first sign-in, so the
+// returned profile has no name.
+func (ap *AppleProvider) Verify(ctx context.Context, token string) (*models.OAuthUserInfo, error) {
+	var claims appleClaims
+	_, err := jwt.ParseWithClaims(token, &claims, func(t *jwt.Token) (interface{}, error) {
+		if _, ok := t.Method.(*jwt.SigningMethodRSA); !ok {
+			return nil, fmt.Errorf("unexpected signing method %v", t.Header["alg"])
+		}
+		kid, _ := t.Header["kid"].(string)
+		return ap.publicKey(ctx, kid)
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	if !claims.VerifyIssuer(appleIssuer, true) {
+		return nil, errors.New("invalid token issuer")
+	}
+	if !claims.VerifyAudience(ap.ClientID, true) {
+		return nil, errors.New("invalid token audience")
+	}
+
+	verified := claims.EmailVerified == true || claims.EmailVerified == "true"
+	if claims.Email == "" || !verified {
+		return nil, ErrEmailNotVerified
+	}
+
+	return &models.OAuthUserInfo{Email: claims.Email}, nil
+}
+
+// publicKey returns the signing key with the given ID, refreshing Apple's key
+// set at most once a minute when the ID is unknown
+func (ap *AppleProvider) publicKey(ctx context.Context, kid string) (*rsa.PublicKey, error) {
+	ap.mu.Lock()
+	defer ap.mu.Unlock()
+
+	if key, ok := ap.keys[kid]; ok {
+		return key, nil
+	}
+	if time.Since(ap.fetchedAt) < time.Minute {
+		return nil, fmt.Errorf("unknown signing key %q", kid)
+	}
+
+	keys, err := ap.fetchKeys(ctx)
+	if err != nil {
+		return nil, err
+	}
+	ap.keys = keys
+	ap.fetchedAt = time.Now()
+
+	if key, ok := ap.keys[kid]; ok {
+		return key, nil
+	}
+	return nil, fmt.Errorf("unknown signing key %q", kid)
+}
+
+func (ap *AppleProvider) fetchKeys(ctx context.Context) (map[string]*rsa.PublicKey, error) {
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, appleKeysURL, nil)
+	if err != nil {
+		return nil, err
+	}
+
+	resp, err := ap.client.Do(req)
+	if err != nil {
+		return nil, err
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode != http.StatusOK {
+		return nil, fmt.Errorf("apple keys: unexpected status %d", resp.StatusCode)
+	}
+
+	var jwks struct {
+		Keys []struct {
+			Kid string `json:"kid"`
+			Kty string `json:"kty"`
+			N   string `json:"n"`
+			E   string `json:"e"`
+		} `json:"keys"`
+	}
+	if err := json.NewDecoder(resp.Body).Decode(&jwks); err != nil {
+		return nil, err
+	}
+
+	keys := make(map[string]*rsa.PublicKey, len(jwks.Keys))
+	for _, k := range jwks.Keys {
+		if k.Kty != "RSA" {
+			continue
+		}
+		n, err := base64.RawURLEncoding.DecodeString(k.N)
+		if err != nil {
+			return nil, err
+		}
+		e, err := base64.RawURLEncoding.DecodeString(k.E)
+		if err != nil {
+			return nil, err
+		}
+		keys[k.Kid] = &rsa.PublicKey{
+			N: new(big.Int).SetBytes(n),
+			E: int(new(big.Int).SetBytes(e).Int64()),
+		}
+	}
+
+	return keys, nil
+}
+
+const facebookGraphURL = "https://graph.facebook.com"
+
+// FacebookProvider validates Facebook Login user access tokens through the Graph API
+type FacebookProvider struct {
+	AppID     string
+	AppSecret string
+	client    *http.Client
+}
+
+func (fp *FacebookProvider) Name() string {
+	return "facebook"
+}
+
+// Verify checks that the access token was issued to this app, then reads the
+// profile. Facebook only returns confirmed email addresses.
+func (fp *FacebookProvider) Verify(ctx context.Context, token string) (*models.OAuthUserInfo, error) {
+	var debug struct {
+		Data struct {
+			AppID   string `json:"app_id"`
+			IsValid bool   `json:"is_valid"`
+		} `json:"data"`
+	}
+	err := fp.get(ctx, "/debug_token", url.Values{
+		"input_token":  {token},
+		"access_token": {fp.AppID + "|" + fp.AppSecret},
+	}, &debug)
+	if err != nil {
+		return nil, err
+	}
+	if !debug.Data.IsValid || debug.Data.AppID != fp.AppID {
+		return nil, errors.New("token was not issued for this app")
+	}
+
+	var profile struct {
+		Name    string `json:"name"`
+		Email   string `json:"email"`
+		Picture struct {
+			Data struct {
+				URL string `json:"url"`
+			} `json:"data"`
+		} `json:"picture"`
+	}
+	err = fp.get(ctx, "/me", url.Values{
+		"fields":       {"name,email,picture.type(large)"},
+		"access_token": {token},
+	}, &profile)
+	if err != nil {
+		return nil, err
+	}
+	if profile.Email == "" {
+		return nil, ErrEmailNotVerified
+	}
+
+	return &models.OAuthUserInfo{
+		Email:   profile.Email,
+		Name:    profile.Name,
+		Picture: profile.Picture.Data.URL,
+	}, nil
+}
+
+func (fp *FacebookProvider) get(ctx context.Context, path string, params url.Values, out interface{}) error {
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, facebookGraphURL+path+"?"+params.Encode(), nil)
+	if err != nil {
+		return err
+	}
+
+	resp, err := fp.client.Do(req)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode != http.StatusOK {
+		return fmt.Errorf("facebook %s: unexpected status %d", path, resp.StatusCode)
+	}
+
+	return json.NewDecoder(resp.Body).Decode(out)
+}
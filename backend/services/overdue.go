@@ -0,0 +1,247 @@
+package services
+
+import (
+	"context"
+	"fmt"
+	"log"
+	"os"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+
+	"rice-monitor-api/models"
+	"rice-monitor-api/utils"
+
+	"cloud.google.com/go/firestore"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+)
+
+// OverdueService finds fields that went longer than their monitoring cadence
+// without an observation, and emails the people monitoring them once per
+// missed visit
+type OverdueService struct {
+	firestoreService *FirestoreService
+	mailerService    *MailerService
+	notifications    *NotificationSettingsService
+	enabled          bool
+	cadenceDays      int // days expected between visits of fields without their own cadence
+	stop             chan struct{}
+}
+
+func NewOverdueService(firestoreService *FirestoreService, mailerService *MailerService, notifications *NotificationSettingsService) *OverdueService {
+	days, err := strconv.Atoi(os.Getenv("MONITORING_CADENCE_DAYS"))
+	if err != nil || days < 1 {
+		days = 7
+	}
+
+	return &OverdueService{
+		firestoreService: firestoreService,
+		mailerService:    mailerService,
+		notifications:    notifications,
+		enabled:          strings.ToLower(os.Getenv("OVERDUE_REMINDERS")) != "off",
+		cadenceDays:      days,
+		stop:             make(chan struct{}),
+	}
+}
+
+// Start checks the fields every day at midnight UTC until Stop is called
+func (ov *OverdueService) Start(ctx context.Context) error {
+	if !ov.enabled {
+		log.Println("Overdue visit reminders disabled")
+		return nil
+	}
+
+	go func() {
+		for {
+			next := nextReportTime("daily", time.Now().UTC())
+			timer := time.NewTimer(time.Until(next))
+
+			select {
+			case <-ov.stop:
+				timer.Stop()
+				return
+			case <-timer.C:
+			}
+
+			if err := ov.Run(next); err != nil {
+				log.Printf("Failed to send overdue visit reminders: %v", err)
+			}
+		}
+	}()
+	return nil
+}
+
+// Stop cancels the schedule. Reminders already being sent are not interrupted.
+func (ov *OverdueService) Stop(ctx context.Context) error {
+	close(ov.stop)
+	return nil
+}
+
+// CadenceDays returns the days expected between visits of field
+func (ov *OverdueService) CadenceDays(field *models.Field) int {
+	if field.MonitoringCadenceDays > 0 {
+		return field.MonitoringCadenceDays
+	}
+	return ov.cadenceDays
+}
+
+// Overdue returns the fields among fields without an observation in the last
+// days days, or in their own cadence when days is zero, longest overdue first
+func (ov *OverdueService) Overdue(fields []models.Field, days int, now time.Time) ([]models.OverdueField, error) {
+	overdue := []models.OverdueField{}
+	for i := range fields {
+		lastVisit, since, err := ov.lastVisit(&fields[i])
+		if err != nil {
+			return nil, err
+		}
+
+		cadence := days
+		if cadence == 0 {
+			cadence = ov.CadenceDays(&fields[i])
+		}
+		if due := since.AddDate(0, 0, cadence); now.After(due) {
+			overdue = append(overdue, overdueField(&fields[i], cadence, lastVisit, since, now))
+		}
+	}
+
+	sort.SliceStable(overdue, func(i, j int) bool {
+		return overdue[i].DueAt.Before(overdue[j].DueAt)
+	})
+	return overdue, nil
+}
+
+// Run emails the monitors of every field gone without a visit for longer
+// than its cadence, or than their own days_without_visit alert when they set
+// one. Each monitor is emailed once per missed visit: notices are keyed by
+// field, last visit and user, so when several instances run the schedule only
+// the first one sends it.
+func (ov *OverdueService) Run(now time.Time) error {
+	ctx := ov.firestoreService.Context()
+	docs, err := ov.firestoreService.Fields().Documents(ctx).GetAll()
+	if err != nil {
+		return err
+	}
+
+	for _, doc := range docs {
+		var field models.Field
+		doc.DataTo(&field)
+		// Archived fields are no longer visited
+		if field.Archived {
+			continue
+		}
+
+		if err := ov.remind(&field, now); err != nil {
+			log.Printf("Failed to send overdue visit reminders for field %s: %v", field.ID, err)
+		}
+	}
+	return nil
+}
+
+func (ov *OverdueService) remind(field *models.Field, now time.Time) error {
+	lastVisit, since, err := ov.lastVisit(field)
+	if err != nil {
+		return err
+	}
+	// No one is notified before the shortest threshold anyone could have
+	if !now.After(since.AddDate(0, 0, 1)) {
+		return nil
+	}
+
+	monitors, err := fieldMonitors(ov.firestoreService, field)
+	if err != nil {
+		return err
+	}
+
+	ctx := ov.firestoreService.Context()
+	for _, user := range monitors {
+		if user.Email == "" {
+			continue
+		}
+		settings, err := ov.notifications.Get(user.ID)
+		if err != nil {
+			return err
+		}
+		cadence := settings.Alerts.DaysWithoutVisit
+		if cadence == 0 {
+			cadence = ov.CadenceDays(field)
+		}
+		if !now.After(since.AddDate(0, 0, cadence)) {
+			continue
+		}
+
+		overdue := overdueField(field, cadence, lastVisit, since, now)
+		notice := &models.OverdueNotice{
+			ID:          fmt.Sprintf("%s-%s-%s", field.ID, since.UTC().Format("20060102"), user.ID),
+			FieldID:     field.ID,
+			UserID:      user.ID,
+			LastVisitAt: lastVisit,
+			Days:        overdue.DaysSinceVisit,
+			CreatedAt:   time.Now(),
+		}
+		_, err = ov.firestoreService.OverdueNotices().Doc(notice.ID).Create(ctx, notice)
+		if status.Code(err) == codes.AlreadyExists {
+			continue
+		}
+		if err != nil {
+			return err
+		}
+
+		subject := fmt.Sprintf("Visit overdue: %s has not been observed for %d days", field.Name, overdue.DaysSinceVisit)
+		if err := ov.mailerService.Send([]string{user.Email}, subject, overdueBody(field, overdue)); err != nil {
+			log.Printf("Failed to email overdue visit of field %s to user %s: %v", field.ID, user.ID, err)
+		}
+	}
+	return nil
+}
+
+// lastVisit returns the date of the field's latest observation, drafts aside,
+// and the time its visits are counted from: that date, or the transplant date
+// or creation of a field never observed
+func (ov *OverdueService) lastVisit(field *models.Field) (*time.Time, time.Time, error) {
+	docs, err := ov.firestoreService.Submissions().
+		Where("field_id", "==", field.ID).
+		OrderBy("date", firestore.Desc).
+		Limit(20).
+		Documents(ov.firestoreService.Context()).GetAll()
+	if err != nil {
+		return nil, time.Time{}, err
+	}
+
+	for _, doc := range docs {
+		var submission models.Submission
+		doc.DataTo(&submission)
+		if submission.Status != "draft" {
+			return &submission.Date, submission.Date, nil
+		}
+	}
+
+	if transplanted, err := time.Parse("2006-01-02", field.TransplantDate); err == nil {
+		return nil, transplanted, nil
+	}
+	return nil, field.CreatedAt, nil
+}
+
+func overdueField(field *models.Field, cadence int, lastVisit *time.Time, since, now time.Time) models.OverdueField {
+	return models.OverdueField{
+		FieldID:        field.ID,
+		FieldName:      field.Name,
+		Location:       field.Location,
+		OwnerID:        field.OwnerID,
+		CadenceDays:    cadence,
+		LastVisitAt:    lastVisit,
+		DaysSinceVisit: int(now.Sub(since).Hours() / 24),
+		DueAt:          since.AddDate(0, 0, cadence),
+	}
+}
+
+func overdueBody(field *models.Field, overdue models.OverdueField) string {
+	last := "has never been observed"
+	if overdue.LastVisitAt != nil {
+		last = "was last observed on " + utils.FormatDate(*overdue.LastVisitAt)
+	}
+	return fmt.Sprintf("Hello,\n\n%s at %s %s. It should be visited every %d days, so a visit was due on %s. "+
+		"Please plan a visit and submit an observation.\n",
+		field.Name, field.Location, last, overdue.CadenceDays, utils.FormatDate(overdue.DueAt))
+}
@@ -0,0 +1,79 @@
+package services
+
+import (
+	"math"
+	"time"
+
+	"rice-monitor-api/models"
+)
+
+// calendarGDDPerDay is the typical daily degree days of the Bangladesh rice
+// seasons, used to convert the stage thresholds to days when a field has no
+// weather yet
+const calendarGDDPerDay = 17.0
+
+// stageWindow is when a growth stage is expected, in growing degree days after
+// transplanting
+type stageWindow struct {
+	stage    string
+	startGDD float64
+	endGDD   float64
+}
+
+// criticalWindows are the stages whose traits can only be measured while they
+// last. The thresholds are typical of transplanted Aman and Boro varieties,
+// about 45-55 and 75-85 days after transplanting at 17 degree days a day.
+var criticalWindows = []stageWindow{
+	{stage: "Panicle Initiation", startGDD: 765, endGDD: 935},
+	{stage: "Flowering", startGDD: 1275, endGDD: 1445},
+}
+
+// CriticalCropCalendar returns the critical windows as a crop calendar, in
+// degree days and in days at the typical rate
+func CriticalCropCalendar() models.CropCalendar {
+	calendar := models.CropCalendar{GDDPerDay: calendarGDDPerDay}
+	for _, w := range criticalWindows {
+		calendar.Stages = append(calendar.Stages, models.CropCalendarStage{
+			Stage:    w.stage,
+			StartGDD: w.startGDD,
+			EndGDD:   w.endGDD,
+			StartDay: int(math.Round(w.startGDD / calendarGDDPerDay)),
+			EndDay:   int(math.Round(w.endGDD / calendarGDDPerDay)),
+			Critical: true,
+		})
+	}
+	return calendar
+}
+
+// PredictCriticalWindows returns the expected dates of the critical growth
+// stages of field, or nil when its transplant date is unknown. Fields with
+// backfilled weather are projected from the degree days accumulated so far at
+// the rate they have accumulated; other fields fall back to calendar days.
+func PredictCriticalWindows(field *models.Field) []models.CriticalWindow {
+	transplanted, err := time.Parse("2006-01-02", field.TransplantDate)
+	if err != nil {
+		return nil
+	}
+
+	// Degree days accumulated by a known date, and the daily rate to project with
+	origin, accumulated, rate := transplanted, 0.0, calendarGDDPerDay
+	if through, err := time.Parse("2006-01-02", field.WeatherThrough); err == nil &&
+		field.WeatherFrom == field.TransplantDate && field.CumulativeGDD > 0 {
+		days := through.Sub(transplanted).Hours()/24 + 1
+		origin, accumulated, rate = through.AddDate(0, 0, 1), field.CumulativeGDD, field.CumulativeGDD/days
+	}
+
+	reachedOn := func(gdd float64) time.Time {
+		return origin.AddDate(0, 0, int(math.Round((gdd-accumulated)/rate)))
+	}
+
+	windows := make([]models.CriticalWindow, 0, len(criticalWindows))
+	for _, w := range criticalWindows {
+		windows = append(windows, models.CriticalWindow{
+			Stage: w.stage,
+			Start: reachedOn(w.startGDD),
+			End:   reachedOn(w.endGDD),
+		})
+	}
+	return windows
+}
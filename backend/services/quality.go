@@ -0,0 +1,314 @@
+package services
+
+import (
+	"context"
+	"fmt"
+	"log"
+	"os"
+	"strconv"
+	"strings"
+	"time"
+
+	"rice-monitor-api/models"
+	"rice-monitor-api/utils"
+
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+)
+
+// maxIssuesPerSection caps how many issues of each kind are listed in the email
+const maxIssuesPerSection = 10
+
+// pendingReviewStatuses are the statuses of submissions waiting for a reviewer
+var pendingReviewStatuses = []string{"submitted", "under_review"}
+
+// QualityService produces the scheduled data quality reports and emails them
+// to admins, so problems are caught during the season rather than at analysis time
+type QualityService struct {
+	firestoreService  *FirestoreService
+	mailerService     *MailerService
+	vocabularyService *VocabularyService
+	schedule          string        // daily, weekly or off
+	reviewSLA         time.Duration // how long a submission may wait for review
+	stop              chan struct{}
+}
+
+func NewQualityService(firestoreService *FirestoreService, mailerService *MailerService, vocabularyService *VocabularyService) *QualityService {
+	schedule := strings.ToLower(os.Getenv("QUALITY_REPORT_SCHEDULE"))
+	if schedule != "weekly" && schedule != "off" {
+		schedule = "daily"
+	}
+
+	days, err := strconv.Atoi(os.Getenv("QUALITY_REVIEW_SLA_DAYS"))
+	if err != nil || days <= 0 {
+		days = 3
+	}
+
+	return &QualityService{
+		firestoreService:  firestoreService,
+		mailerService:     mailerService,
+		vocabularyService: vocabularyService,
+		schedule:          schedule,
+		reviewSLA:         time.Duration(days) * 24 * time.Hour,
+		stop:              make(chan struct{}),
+	}
+}
+
+// Start runs the report at every period boundary (midnight UTC, Mondays for weekly)
+// until Stop is called
+func (qs *QualityService) Start(ctx context.Context) error {
+	if qs.schedule == "off" {
+		log.Println("Quality reports disabled")
+		return nil
+	}
+
+	go func() {
+		for {
+			next := nextReportTime(qs.schedule, time.Now().UTC())
+			timer := time.NewTimer(time.Until(next))
+
+			select {
+			case <-qs.stop:
+				timer.Stop()
+				return
+			case <-timer.C:
+			}
+
+			if _, err := qs.Run(qs.schedule, next); err != nil {
+				log.Printf("Failed to produce %s quality report: %v", qs.schedule, err)
+			}
+		}
+	}()
+	return nil
+}
+
+// Stop cancels the schedule. A report already being produced is not interrupted.
+func (qs *QualityService) Stop(ctx context.Context) error {
+	close(qs.stop)
+	return nil
+}
+
+// Run produces the report for the period ending at end, stores it and emails it
+// to admins. Reports are keyed by period, so when several instances run the
+// schedule only the first one stores and sends it.
+func (qs *QualityService) Run(period string, end time.Time) (*models.QualityReport, error) {
+	report, err := qs.Generate(period, end)
+	if err != nil {
+		return nil, err
+	}
+
+	ctx := qs.firestoreService.Context()
+	_, err = qs.firestoreService.QualityReports().Doc(report.ID).Create(ctx, report)
+	if status.Code(err) == codes.AlreadyExists {
+		return report, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	if err := qs.notifyAdmins(report); err != nil {
+		log.Printf("Failed to email quality report %s: %v", report.ID, err)
+	}
+
+	return report, nil
+}
+
+// Generate checks the submissions and uploads of the period ending at end
+func (qs *QualityService) Generate(period string, end time.Time) (*models.QualityReport, error) {
+	start := end.AddDate(0, 0, -1)
+	if period == "weekly" {
+		start = end.AddDate(0, 0, -7)
+	}
+
+	report := &models.QualityReport{
+		ID:             fmt.Sprintf("%s-%s", period, utils.FormatDate(start)),
+		Period:         period,
+		PeriodStart:    start,
+		PeriodEnd:      end,
+		Anomalies:      []models.QualityIssue{},
+		Duplicates:     []models.QualityIssue{},
+		MissingPhotos:  []models.QualityIssue{},
+		OverdueReviews: []models.QualityIssue{},
+		FailedUploads:  []models.QualityIssue{},
+		CreatedAt:      time.Now(),
+	}
+
+	ctx := qs.firestoreService.Context()
+	docs, err := qs.firestoreService.Submissions().
+		Where("created_at", ">=", start).
+		Where("created_at", "<", end).
+		Documents(ctx).GetAll()
+	if err != nil {
+		return nil, err
+	}
+	report.Submissions = len(docs)
+
+	// Same observer, field and observation day counts as a duplicate
+	seen := make(map[string]string)
+	for _, doc := range docs {
+		var submission models.Submission
+		doc.DataTo(&submission)
+
+		for _, detail := range qs.submissionAnomalies(&submission) {
+			report.Anomalies = append(report.Anomalies, qualityIssue(&submission, detail))
+		}
+
+		if len(submission.Images) == 0 && submission.Status != "draft" {
+			report.MissingPhotos = append(report.MissingPhotos, qualityIssue(&submission, "Submitted without photos"))
+		}
+
+		key := submission.UserID + "|" + submission.FieldID + "|" + utils.FormatDate(submission.Date)
+		if original, ok := seen[key]; ok {
+			report.Duplicates = append(report.Duplicates, qualityIssue(&submission,
+				"Same observer, field and date as submission "+original))
+		} else {
+			seen[key] = submission.ID
+		}
+	}
+
+	// Overdue reviews are the whole backlog, not only this period's submissions
+	docs, err = qs.firestoreService.Submissions().
+		Where("status", "in", pendingReviewStatuses).
+		Where("created_at", "<", end.Add(-qs.reviewSLA)).
+		Documents(ctx).GetAll()
+	if err != nil {
+		return nil, err
+	}
+	for _, doc := range docs {
+		var submission models.Submission
+		doc.DataTo(&submission)
+
+		days := int(end.Sub(submission.CreatedAt).Hours() / 24)
+		report.OverdueReviews = append(report.OverdueReviews, qualityIssue(&submission,
+			fmt.Sprintf("Waiting for review for %d days", days)))
+	}
+
+	docs, err = qs.firestoreService.UploadFailures().
+		Where("created_at", ">=", start).
+		Where("created_at", "<", end).
+		Documents(ctx).GetAll()
+	if err != nil {
+		return nil, err
+	}
+	for _, doc := range docs {
+		var failure models.UploadFailure
+		doc.DataTo(&failure)
+
+		report.FailedUploads = append(report.FailedUploads, models.QualityIssue{
+			SubmissionID: failure.SubmissionID,
+			UserID:       failure.UserID,
+			Detail:       fmt.Sprintf("%s upload of %s failed: %s", failure.Source, failure.Filename, failure.Error),
+		})
+	}
+
+	return report, nil
+}
+
+// IssueCount returns the total number of problems in a report
+func IssueCount(report *models.QualityReport) int {
+	return len(report.Anomalies) + len(report.Duplicates) + len(report.MissingPhotos) +
+		len(report.OverdueReviews) + len(report.FailedUploads)
+}
+
+func (qs *QualityService) notifyAdmins(report *models.QualityReport) error {
+	docs, err := qs.firestoreService.Users().
+		Where("role", "==", "admin").
+		Documents(qs.firestoreService.Context()).GetAll()
+	if err != nil {
+		return err
+	}
+
+	var to []string
+	for _, doc := range docs {
+		var user models.User
+		doc.DataTo(&user)
+		if user.Email != "" {
+			to = append(to, user.Email)
+		}
+	}
+	if len(to) == 0 {
+		return nil
+	}
+
+	subject := fmt.Sprintf("Rice Monitor %s data quality report: %d issues", report.Period, IssueCount(report))
+	return qs.mailerService.Send(to, subject, qualityReportBody(report))
+}
+
+func qualityReportBody(report *models.QualityReport) string {
+	var b strings.Builder
+	fmt.Fprintf(&b, "Data quality report for %s to %s\n", utils.FormatDate(report.PeriodStart), utils.FormatDate(report.PeriodEnd))
+	fmt.Fprintf(&b, "%d submissions were created during this period.\n", report.Submissions)
+
+	sections := []struct {
+		title  string
+		issues []models.QualityIssue
+	}{
+		{"New anomalies", report.Anomalies},
+		{"Duplicates", report.Duplicates},
+		{"Missing photos", report.MissingPhotos},
+		{"Overdue reviews", report.OverdueReviews},
+		{"Failed uploads", report.FailedUploads},
+	}
+	for _, section := range sections {
+		fmt.Fprintf(&b, "\n%s: %d\n", section.title, len(section.issues))
+		for i, issue := range section.issues {
+			if i == maxIssuesPerSection {
+				fmt.Fprintf(&b, "  ... and %d more\n", len(section.issues)-maxIssuesPerSection)
+				break
+			}
+			fmt.Fprintf(&b, "  - %s: %s\n", issue.SubmissionID, issue.Detail)
+		}
+	}
+
+	fmt.Fprintf(&b, "\nThe full report is available at %s/api/v1/admin/quality-reports/%s\n",
+		utils.GetEnvOrDefault("PUBLIC_BASE_URL", "http://localhost:8080"), report.ID)
+	return b.String()
+}
+
+// submissionAnomalies returns the values of a submission that are outside what
+// the app allows or physically plausible
+func (qs *QualityService) submissionAnomalies(submission *models.Submission) []string {
+	var anomalies []string
+
+	if !utils.Contains(qs.vocabularyService.GrowthStages(), submission.GrowthStage) {
+		anomalies = append(anomalies, "Unknown growth stage "+submission.GrowthStage)
+	}
+	for _, condition := range submission.PlantConditions {
+		if !utils.Contains(qs.vocabularyService.PlantConditions(), condition) {
+			anomalies = append(anomalies, "Unknown plant condition "+condition)
+		}
+	}
+	if submission.Date.After(submission.CreatedAt.Add(24 * time.Hour)) {
+		anomalies = append(anomalies, "Observation date is after the submission was created")
+	}
+
+	traits := submission.TraitMeasurements
+	if traits.CulmLength < 0 || traits.PanicleLength < 0 || traits.PaniclesPerHill < 0 || traits.HillsObserved < 0 {
+		anomalies = append(anomalies, "Negative trait measurement")
+	}
+	if traits.PanicleLength > traits.CulmLength && traits.CulmLength > 0 {
+		anomalies = append(anomalies, "Panicle length exceeds culm length")
+	}
+
+	return anomalies
+}
+
+func qualityIssue(submission *models.Submission, detail string) models.QualityIssue {
+	return models.QualityIssue{
+		SubmissionID: submission.ID,
+		FieldID:      submission.FieldID,
+		UserID:       submission.UserID,
+		Detail:       detail,
+	}
+}
+
+// nextReportTime returns the next period boundary after now
+func nextReportTime(schedule string, now time.Time) time.Time {
+	next := time.Date(now.Year(), now.Month(), now.Day(), 0, 0, 0, 0, time.UTC).AddDate(0, 0, 1)
+	if schedule == "weekly" {
+		for next.Weekday() != time.Monday {
+			next = next.AddDate(0, 0, 1)
+		}
+	}
+	return next
+}
@@ -0,0 +1,310 @@
+package services
+
+import (
+	"fmt"
+	"math"
+	"sort"
+	"strings"
+	"time"
+
+	"rice-monitor-api/models"
+	"rice-monitor-api/utils"
+)
+
+const (
+	// outbreakRadiusKm links fields reporting pests or disease into one cluster
+	// when they lie within this distance of each other
+	outbreakRadiusKm = 5.0
+	// deviationRun is how many consecutive measurements of a field must deviate
+	// from the stage average in the same direction to be flagged
+	deviationRun = 3
+	// deviationThreshold is the relative deviation from the stage average that counts
+	deviationThreshold = 0.2
+	// minStageSamples is how many measurements a stage average needs to be trusted
+	minStageSamples = 5
+	// minRejections is the least number of rejections in the window that can be a spike
+	minRejections = 3
+	// rejectionBaselineWindows is how many earlier windows the rejection baseline averages
+	rejectionBaselineWindows = 3
+)
+
+// outbreakConditions are the plant conditions that may spread between fields
+var outbreakConditions = []string{"Signs of pest infestation", "Disease symptoms"}
+
+// RedFlagLookback is how far back DetectRedFlags needs submissions: trait
+// averages use 90 days, the rejection baseline the windows before the current one
+func RedFlagLookback(window time.Duration) time.Duration {
+	lookback := 90 * 24 * time.Hour
+	if baseline := window * (rejectionBaselineWindows + 1); baseline > lookback {
+		lookback = baseline
+	}
+	return lookback
+}
+
+// DetectRedFlags finds outbreak clusters, overdue fields, sustained trait
+// deviations and rejection spikes among fields and the submissions of the
+// last RedFlagLookback(window), ranked by severity
+func DetectRedFlags(fields []models.Field, submissions []models.Submission, now time.Time, window, overdueAfter time.Duration) []models.RedFlag {
+	sort.Slice(submissions, func(i, j int) bool {
+		return submissions[i].Date.Before(submissions[j].Date)
+	})
+
+	var flags []models.RedFlag
+	flags = append(flags, outbreakClusters(fields, submissions, now.Add(-window))...)
+	flags = append(flags, overdueVisits(fields, submissions, now, now.Add(-RedFlagLookback(window)), overdueAfter)...)
+	flags = append(flags, traitDeviations(submissions, now.Add(-window))...)
+	flags = append(flags, rejectionSpikes(submissions, now, window)...)
+
+	sort.SliceStable(flags, func(i, j int) bool {
+		return flags[i].Severity > flags[j].Severity
+	})
+	return flags
+}
+
+// outbreakClusters groups the fields with pest or disease reports since start
+// that lie within outbreakRadiusKm of each other. A single field is not a cluster.
+func outbreakClusters(fields []models.Field, submissions []models.Submission, start time.Time) []models.RedFlag {
+	reports := make(map[string]int)
+	for _, submission := range submissions {
+		if submission.Date.Before(start) || submission.Status == "rejected" {
+			continue
+		}
+		for _, condition := range submission.PlantConditions {
+			if utils.Contains(outbreakConditions, condition) {
+				reports[submission.FieldID]++
+				break
+			}
+		}
+	}
+
+	var affected []models.Field
+	for _, field := range fields {
+		located := field.Coordinates.Latitude != 0 || field.Coordinates.Longitude != 0
+		if reports[field.ID] > 0 && located {
+			affected = append(affected, field)
+		}
+	}
+
+	// Single-linkage clustering: two fields closer than the radius join their clusters
+	cluster := make([]int, len(affected))
+	for i := range cluster {
+		cluster[i] = i
+	}
+	var find func(int) int
+	find = func(i int) int {
+		if cluster[i] != i {
+			cluster[i] = find(cluster[i])
+		}
+		return cluster[i]
+	}
+	for i := range affected {
+		for j := i + 1; j < len(affected); j++ {
+			if utils.DistanceKm(affected[i].Coordinates, affected[j].Coordinates) <= outbreakRadiusKm {
+				cluster[find(j)] = find(i)
+			}
+		}
+	}
+
+	members := make(map[int][]models.Field)
+	var roots []int
+	for i, field := range affected {
+		root := find(i)
+		if _, ok := members[root]; !ok {
+			roots = append(roots, root)
+		}
+		members[root] = append(members[root], field)
+	}
+
+	var flags []models.RedFlag
+	for _, root := range roots {
+		group := members[root]
+		if len(group) < 2 {
+			continue
+		}
+		total := 0
+		var fieldIDs, names []string
+		for _, field := range group {
+			total += reports[field.ID]
+			fieldIDs = append(fieldIDs, field.ID)
+			names = append(names, field.Name)
+		}
+		flags = append(flags, models.RedFlag{
+			Type:     "outbreak_cluster",
+			Severity: min(100, 20*len(group)+5*total),
+			Title:    fmt.Sprintf("Pest or disease cluster across %d fields", len(group)),
+			Detail:   fmt.Sprintf("%d reports since %s within %.0f km: %s", total, utils.FormatDate(start), outbreakRadiusKm, joinNames(names)),
+			FieldIDs: fieldIDs,
+		})
+	}
+	return flags
+}
+
+// overdueVisits flags the fields not observed for longer than overdueAfter.
+// Harvested fields no longer need visits. Fields without submissions since
+// lookbackStart count from then, or from their creation if later.
+func overdueVisits(fields []models.Field, submissions []models.Submission, now, lookbackStart time.Time, overdueAfter time.Duration) []models.RedFlag {
+	latest := make(map[string]models.Submission)
+	for _, submission := range submissions {
+		latest[submission.FieldID] = submission // sorted by date, so the last one wins
+	}
+
+	var flags []models.RedFlag
+	for _, field := range fields {
+		since := field.CreatedAt
+		detail := "Never observed"
+		if since.Before(lookbackStart) {
+			since = lookbackStart
+			detail = fmt.Sprintf("Not observed since before %s", utils.FormatDate(lookbackStart))
+		}
+		if submission, ok := latest[field.ID]; ok {
+			if submission.GrowthStage == "Harvested" {
+				continue
+			}
+			since = submission.Date
+			detail = fmt.Sprintf("Last observed on %s at %s", utils.FormatDate(submission.Date), submission.GrowthStage)
+		}
+		if now.Sub(since) <= overdueAfter {
+			continue
+		}
+
+		days := int(now.Sub(since).Hours() / 24)
+		flags = append(flags, models.RedFlag{
+			Type:     "overdue_visit",
+			Severity: min(60, int(30*now.Sub(since).Hours()/overdueAfter.Hours())),
+			Title:    fmt.Sprintf("%s not visited for %d days", field.Name, days),
+			Detail:   detail,
+			FieldIDs: []string{field.ID},
+		})
+	}
+	return flags
+}
+
+// traitDeviations flags fields whose last deviationRun measurements of a trait,
+// the latest since start, all lie more than deviationThreshold above or below
+// the average of their growth stage
+func traitDeviations(submissions []models.Submission, start time.Time) []models.RedFlag {
+	traits := []struct {
+		name  string
+		value func(models.TraitMeasurements) float64
+	}{
+		{"culm length", func(t models.TraitMeasurements) float64 { return t.CulmLength }},
+		{"panicle length", func(t models.TraitMeasurements) float64 { return t.PanicleLength }},
+		{"panicles per hill", func(t models.TraitMeasurements) float64 { return float64(t.PaniclesPerHill) }},
+	}
+
+	var flags []models.RedFlag
+	for _, trait := range traits {
+		sums := make(map[string]float64)
+		counts := make(map[string]int)
+		for _, submission := range submissions {
+			if value := trait.value(submission.TraitMeasurements); value > 0 && submission.Status != "rejected" {
+				sums[submission.GrowthStage] += value
+				counts[submission.GrowthStage]++
+			}
+		}
+
+		// Relative deviations of each field's measurements, oldest first
+		deviations := make(map[string][]float64)
+		latest := make(map[string]time.Time)
+		var fieldIDs []string
+		for _, submission := range submissions {
+			value := trait.value(submission.TraitMeasurements)
+			if value <= 0 || submission.Status == "rejected" || counts[submission.GrowthStage] < minStageSamples {
+				continue
+			}
+			average := sums[submission.GrowthStage] / float64(counts[submission.GrowthStage])
+			if _, ok := deviations[submission.FieldID]; !ok {
+				fieldIDs = append(fieldIDs, submission.FieldID)
+			}
+			deviations[submission.FieldID] = append(deviations[submission.FieldID], (value-average)/average)
+			latest[submission.FieldID] = submission.Date
+		}
+
+		for _, fieldID := range fieldIDs {
+			run := deviations[fieldID]
+			if len(run) < deviationRun || latest[fieldID].Before(start) {
+				continue
+			}
+			run = run[len(run)-deviationRun:]
+
+			total := 0.0
+			sustained := true
+			for _, deviation := range run {
+				if math.Abs(deviation) <= deviationThreshold || (deviation > 0) != (run[0] > 0) {
+					sustained = false
+					break
+				}
+				total += deviation
+			}
+			if !sustained {
+				continue
+			}
+
+			mean := total / float64(len(run))
+			direction := "above"
+			if mean < 0 {
+				direction = "below"
+			}
+			flags = append(flags, models.RedFlag{
+				Type:     "trait_deviation",
+				Severity: min(80, int(math.Round(100*math.Abs(mean)))),
+				Title:    fmt.Sprintf("Sustained %s deviation", trait.name),
+				Detail:   fmt.Sprintf("Last %d measurements averaged %.0f%% %s their growth stage average", deviationRun, 100*math.Abs(mean), direction),
+				FieldIDs: []string{fieldID},
+			})
+		}
+	}
+	return flags
+}
+
+// rejectionSpikes flags observers with at least minRejections rejections in
+// the last window and twice as many as their average over the windows before
+func rejectionSpikes(submissions []models.Submission, now time.Time, window time.Duration) []models.RedFlag {
+	start := now.Add(-window)
+	baselineStart := start.Add(-window * rejectionBaselineWindows)
+
+	recent := make(map[string]int)
+	earlier := make(map[string]int)
+	names := make(map[string]string)
+	var observers []string
+	for _, submission := range submissions {
+		if submission.Status != "rejected" || submission.UpdatedAt.Before(baselineStart) {
+			continue
+		}
+		if _, ok := names[submission.UserID]; !ok {
+			observers = append(observers, submission.UserID)
+		}
+		names[submission.UserID] = submission.ObserverName
+		if submission.UpdatedAt.Before(start) {
+			earlier[submission.UserID]++
+		} else {
+			recent[submission.UserID]++
+		}
+	}
+
+	var flags []models.RedFlag
+	for _, userID := range observers {
+		rejected := recent[userID]
+		baseline := float64(earlier[userID]) / rejectionBaselineWindows
+		if rejected < minRejections || float64(rejected) < 2*baseline {
+			continue
+		}
+		flags = append(flags, models.RedFlag{
+			Type:     "rejection_spike",
+			Severity: min(70, int(15*float64(rejected)/math.Max(baseline, 1))),
+			Title:    fmt.Sprintf("Rejections spiked for %s", names[userID]),
+			Detail:   fmt.Sprintf("%d submissions rejected since %s, against %.1f in earlier periods of the same length", rejected, utils.FormatDate(start), baseline),
+			UserID:   userID,
+		})
+	}
+	return flags
+}
+
+// joinNames lists up to five names, then how many more there are
+func joinNames(names []string) string {
+	const shown = 5
+	if len(names) <= shown {
+		return strings.Join(names, ", ")
+	}
+	return fmt.Sprintf("%s and %d more", joinNames(names[:shown]), len(names)-shown)
+}
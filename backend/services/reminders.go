@@ -0,0 +1,223 @@
+package services
+
+import (
+	"context"
+	"fmt"
+	"log"
+	"os"
+	"strconv"
+	"strings"
+	"time"
+
+	"rice-monitor-api/models"
+	"rice-monitor-api/utils"
+
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+)
+
+// ReminderService emails the people monitoring a field shortly before each of
+// its critical growth stage windows opens, since trait measurements missed
+// during those windows cannot be made up later
+type ReminderService struct {
+	firestoreService *FirestoreService
+	mailerService    *MailerService
+	enabled          bool
+	leadTime         time.Duration // how long before a window opens to send the reminder
+	stop             chan struct{}
+}
+
+func NewReminderService(firestoreService *FirestoreService, mailerService *MailerService) *ReminderService {
+	days, err := strconv.Atoi(os.Getenv("VISIT_REMINDER_LEAD_DAYS"))
+	if err != nil || days < 0 {
+		days = 3
+	}
+
+	return &ReminderService{
+		firestoreService: firestoreService,
+		mailerService:    mailerService,
+		enabled:          strings.ToLower(os.Getenv("VISIT_REMINDERS")) != "off",
+		leadTime:         time.Duration(days) * 24 * time.Hour,
+		stop:             make(chan struct{}),
+	}
+}
+
+// Start checks the fields every day at midnight UTC until Stop is called
+func (rs *ReminderService) Start(ctx context.Context) error {
+	if !rs.enabled {
+		log.Println("Visit reminders disabled")
+		return nil
+	}
+
+	go func() {
+		for {
+			next := nextReportTime("daily", time.Now().UTC())
+			timer := time.NewTimer(time.Until(next))
+
+			select {
+			case <-rs.stop:
+				timer.Stop()
+				return
+			case <-timer.C:
+			}
+
+			if err := rs.Run(next); err != nil {
+				log.Printf("Failed to send visit reminders: %v", err)
+			}
+		}
+	}()
+	return nil
+}
+
+// Stop cancels the schedule. Reminders already being sent are not interrupted.
+func (rs *ReminderService) Stop(ctx context.Context) error {
+	close(rs.stop)
+	return nil
+}
+
+// Run sends a reminder for every critical window that opens within the lead
+// time of now or is already open, unless the field has been observed at that
+// stage during the window. Each window is reminded about once; reminders are
+// keyed by field, stage and transplant date, so when several instances run the
+// schedule only the first one sends it.
+func (rs *ReminderService) Run(now time.Time) error {
+	ctx := rs.firestoreService.Context()
+	docs, err := rs.firestoreService.Fields().Where("transplant_date", "!=", "").Documents(ctx).GetAll()
+	if err != nil {
+		return err
+	}
+
+	for _, doc := range docs {
+		var field models.Field
+		doc.DataTo(&field)
+		// Archived fields are no longer visited
+		if field.Archived {
+			continue
+		}
+
+		for _, window := range PredictCriticalWindows(&field) {
+			if now.Before(window.Start.Add(-rs.leadTime)) || now.After(window.End) {
+				continue
+			}
+			if err := rs.remind(&field, window); err != nil {
+				log.Printf("Failed to send %s reminder for field %s: %v", window.Stage, field.ID, err)
+			}
+		}
+	}
+	return nil
+}
+
+func (rs *ReminderService) remind(field *models.Field, window models.CriticalWindow) error {
+	observed, err := rs.observedDuring(field.ID, window)
+	if err != nil || observed {
+		return err
+	}
+
+	recipients, err := rs.recipients(field)
+	if err != nil {
+		return err
+	}
+
+	reminder := &models.VisitReminder{
+		// Predicted dates move as degree days accumulate, so the season is keyed by transplant date
+		ID:          fmt.Sprintf("%s-%s-%s", field.ID, strings.ReplaceAll(strings.ToLower(window.Stage), " ", "_"), field.TransplantDate),
+		FieldID:     field.ID,
+		Stage:       window.Stage,
+		WindowStart: window.Start,
+		WindowEnd:   window.End,
+		Recipients:  recipients,
+		CreatedAt:   time.Now(),
+	}
+
+	ctx := rs.firestoreService.Context()
+	_, err = rs.firestoreService.VisitReminders().Doc(reminder.ID).Create(ctx, reminder)
+	if status.Code(err) == codes.AlreadyExists {
+		return nil
+	}
+	if err != nil {
+		return err
+	}
+
+	if len(recipients) == 0 {
+		return nil
+	}
+	subject := fmt.Sprintf("Visit %s: %s expected from %s", field.Name, window.Stage, utils.FormatDate(window.Start))
+	return rs.mailerService.Send(recipients, subject, reminderBody(field, window))
+}
+
+// observedDuring reports whether a submission for the field recorded the
+// window's stage on a date inside the window
+func (rs *ReminderService) observedDuring(fieldID string, window models.CriticalWindow) (bool, error) {
+	docs, err := rs.firestoreService.Submissions().
+		Where("field_id", "==", fieldID).
+		Where("growth_stage", "==", window.Stage).
+		Documents(rs.firestoreService.Context()).GetAll()
+	if err != nil {
+		return false, err
+	}
+
+	for _, doc := range docs {
+		var submission models.Submission
+		doc.DataTo(&submission)
+		if !submission.Date.Before(window.Start) && !submission.Date.After(window.End) {
+			return true, nil
+		}
+	}
+	return false, nil
+}
+
+// recipients returns the email addresses of the field owner and the users
+// assigned to the field
+func (rs *ReminderService) recipients(field *models.Field) ([]string, error) {
+	users, err := fieldMonitors(rs.firestoreService, field)
+	if err != nil {
+		return nil, err
+	}
+
+	var to []string
+	for _, user := range users {
+		if user.Email != "" && !utils.Contains(to, user.Email) {
+			to = append(to, user.Email)
+		}
+	}
+	return to, nil
+}
+
+// fieldMonitors returns the users monitoring a field: the users assigned to it
+// and its owner, leaving out suspended accounts
+func fieldMonitors(firestoreService *FirestoreService, field *models.Field) ([]models.User, error) {
+	ctx := firestoreService.Context()
+	docs, err := firestoreService.Users().Where("field_ids", "array-contains", field.ID).Documents(ctx).GetAll()
+	if err != nil {
+		return nil, err
+	}
+
+	var users []models.User
+	for _, doc := range docs {
+		var user models.User
+		doc.DataTo(&user)
+		users = append(users, user)
+	}
+	if doc, err := firestoreService.Users().Doc(field.OwnerID).Get(ctx); err == nil {
+		var owner models.User
+		doc.DataTo(&owner)
+		users = append(users, owner)
+	}
+
+	var monitors []models.User
+	seen := make(map[string]bool)
+	for _, user := range users {
+		if !utils.AccountSuspended(&user) && !seen[user.ID] {
+			seen[user.ID] = true
+			monitors = append(monitors, user)
+		}
+	}
+	return monitors, nil
+}
+
+func reminderBody(field *models.Field, window models.CriticalWindow) string {
+	return fmt.Sprintf("Hello,\n\n%s at %s (transplanted %s) is expected to reach %s between %s and %s. "+
+		"Trait measurements for this stage can only be taken during that window, so please plan a visit and submit an observation.\n",
+		field.Name, field.Location, field.TransplantDate, window.Stage,
+		utils.FormatDate(window.Start), utils.FormatDate(window.End))
+}
@@ -0,0 +1,227 @@
+package services
+
+import (
+	"time"
+
+	"rice-monitor-api/models"
+	"rice-monitor-api/utils"
+
+	"cloud.google.com/go/firestore"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+)
+
+const (
+	// agreementRadiusKm is how close a research observation must be to count as nearby
+	agreementRadiusKm = 5.0
+	// agreementWindow is how far apart in time a nearby observation may be
+	agreementWindow = 7 * 24 * time.Hour
+	// minReputationSignals is how many reviews and agreement checks a contributor
+	// needs before leaving the "new" tier
+	minReputationSignals = 5
+)
+
+// ReputationTiers lists the tiers from least to most trusted
+var ReputationTiers = []string{"low", "new", "standard", "trusted"}
+
+// reputationWeights is how much a community observation counts in weighted analytics
+var reputationWeights = map[string]float64{
+	"low":      0.25,
+	"new":      0.5,
+	"standard": 0.75,
+	"trusted":  1,
+}
+
+// ReputationService scores community observers
+type ReputationService struct {
+	firestoreService *FirestoreService
+}
+
+func NewReputationService(firestoreService *FirestoreService) *ReputationService {
+	return &ReputationService{
+		firestoreService: firestoreService,
+	}
+}
+
+// TierRank returns the position of tier in ReputationTiers, or -1 if unknown
+func TierRank(tier string) int {
+	for i, t := range ReputationTiers {
+		if t == tier {
+			return i
+		}
+	}
+	return -1
+}
+
+// Weight returns the analytics weight of a community observation by a contributor in tier
+func Weight(tier string) float64 {
+	if w, ok := reputationWeights[tier]; ok {
+		return w
+	}
+	return reputationWeights["new"]
+}
+
+// Get returns the stored reputation of userID, computing it if none exists yet
+func (rs *ReputationService) Get(userID string) (*models.Reputation, error) {
+	ctx := rs.firestoreService.Context()
+	doc, err := rs.firestoreService.Reputations().Doc(userID).Get(ctx)
+	if status.Code(err) == codes.NotFound {
+		return rs.Recompute(userID)
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	var reputation models.Reputation
+	if err := doc.DataTo(&reputation); err != nil {
+		return nil, err
+	}
+	return &reputation, nil
+}
+
+// GetMany returns stored reputations keyed by user ID. Users without a stored
+// reputation are left out and should be treated as the "new" tier.
+func (rs *ReputationService) GetMany(userIDs []string) (map[string]models.Reputation, error) {
+	reputations := make(map[string]models.Reputation)
+	if len(userIDs) == 0 {
+		return reputations, nil
+	}
+
+	refs := make([]*firestore.DocumentRef, 0, len(userIDs))
+	for _, id := range userIDs {
+		refs = append(refs, rs.firestoreService.Reputations().Doc(id))
+	}
+
+	docs, err := rs.firestoreService.Client.GetAll(rs.firestoreService.Context(), refs)
+	if err != nil {
+		return nil, err
+	}
+	for _, doc := range docs {
+		if !doc.Exists() {
+			continue
+		}
+		var reputation models.Reputation
+		if err := doc.DataTo(&reputation); err == nil {
+			reputations[reputation.UserID] = reputation
+		}
+	}
+
+	return reputations, nil
+}
+
+// Recompute scores userID from the review outcomes of their community
+// observations and how often their growth stage agrees with research
+// observations made nearby around the same time, then stores the result
+func (rs *ReputationService) Recompute(userID string) (*models.Reputation, error) {
+	ctx := rs.firestoreService.Context()
+
+	docs, err := rs.firestoreService.Submissions().
+		Where("user_id", "==", userID).
+		Where("provenance", "==", "community").
+		Documents(ctx).GetAll()
+	if err != nil {
+		return nil, err
+	}
+
+	fields, err := rs.fieldLocations()
+	if err != nil {
+		return nil, err
+	}
+
+	reputation := &models.Reputation{UserID: userID}
+	for _, doc := range docs {
+		var submission models.Submission
+		doc.DataTo(&submission)
+
+		switch submission.Status {
+		case "approved":
+			reputation.ApprovedCount++
+		case "rejected":
+			reputation.RejectedCount++
+		}
+
+		if submission.Coordinates == nil {
+			continue
+		}
+		stage, found, err := rs.nearbyResearchStage(&submission, fields)
+		if err != nil {
+			return nil, err
+		}
+		if found {
+			reputation.AgreementChecks++
+			if stage == submission.GrowthStage {
+				reputation.AgreementMatches++
+			}
+		}
+	}
+
+	// Laplace smoothing keeps a single outcome from producing an extreme score
+	positive := reputation.ApprovedCount + reputation.AgreementMatches
+	total := reputation.ApprovedCount + reputation.RejectedCount + reputation.AgreementChecks
+	reputation.Score = float64(positive+1) / float64(total+2) * 100
+
+	switch {
+	case total < minReputationSignals:
+		reputation.Tier = "new"
+	case reputation.Score >= 80:
+		reputation.Tier = "trusted"
+	case reputation.Score >= 50:
+		reputation.Tier = "standard"
+	default:
+		reputation.Tier = "low"
+	}
+	reputation.UpdatedAt = time.Now()
+
+	if _, err := rs.firestoreService.Reputations().Doc(userID).Set(ctx, reputation); err != nil {
+		return nil, err
+	}
+
+	return reputation, nil
+}
+
+// fieldLocations returns the coordinates of every field keyed by field ID
+func (rs *ReputationService) fieldLocations() (map[string]models.Location, error) {
+	docs, err := rs.firestoreService.Fields().Documents(rs.firestoreService.Context()).GetAll()
+	if err != nil {
+		return nil, err
+	}
+
+	locations := make(map[string]models.Location, len(docs))
+	for _, doc := range docs {
+		var field models.Field
+		doc.DataTo(&field)
+		locations[field.ID] = field.Coordinates
+	}
+	return locations, nil
+}
+
+// nearbyResearchStage returns the growth stage of the closest research
+// observation within agreementRadiusKm and agreementWindow of submission
+func (rs *ReputationService) nearbyResearchStage(submission *models.Submission, fields map[string]models.Location) (string, bool, error) {
+	docs, err := rs.firestoreService.Submissions().
+		Where("date", ">=", submission.Date.Add(-agreementWindow)).
+		Where("date", "<=", submission.Date.Add(agreementWindow)).
+		Documents(rs.firestoreService.Context()).GetAll()
+	if err != nil {
+		return "", false, err
+	}
+
+	stage, found, closest := "", false, agreementRadiusKm
+	for _, doc := range docs {
+		var other models.Submission
+		doc.DataTo(&other)
+		if other.Provenance == "community" {
+			continue
+		}
+
+		location, ok := fields[other.FieldID]
+		if !ok {
+			continue
+		}
+		if distance := utils.DistanceKm(*submission.Coordinates, location); distance <= closest {
+			stage, found, closest = other.GrowthStage, true, distance
+		}
+	}
+
+	return stage, found, nil
+}
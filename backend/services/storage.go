@@ -2,7 +2,11 @@ package services
 
 import (
 	"context"
+	"fmt"
+	"io"
+	"log"
 	"os"
+	"strings"
 
 	"cloud.google.com/go/storage"
 )
@@ -42,3 +46,64 @@ func (ss *StorageService) Bucket() *storage.BucketHandle {
 func (ss *StorageService) Context() context.Context {
 	return ss.ctx
 }
+
+// UploadPublicObject writes r to the bucket under name, makes it publicly readable and returns its URL
+func (ss *StorageService) UploadPublicObject(name, contentType string, r io.Reader) (string, error) {
+	if err := ss.UploadObject(name, contentType, r); err != nil {
+		return "", err
+	}
+
+	obj := ss.Bucket().Object(name)
+	if err := obj.ACL().Set(ss.ctx, storage.AllUsers, storage.RoleReader); err != nil {
+		// Log error but don't fail the upload
+		log.Printf("Failed to make object public: %v", err)
+	}
+
+	return ss.PublicURL(name), nil
+}
+
+// UploadObject writes r to the bucket under name, readable only through the API
+func (ss *StorageService) UploadObject(name, contentType string, r io.Reader) error {
+	wc := ss.Bucket().Object(name).NewWriter(ss.ctx)
+	wc.ContentType = contentType
+	if _, err := io.Copy(wc, r); err != nil {
+		wc.Close()
+		return err
+	}
+	return wc.Close()
+}
+
+// PublicURL returns the public URL of the object name
+func (ss *StorageService) PublicURL(name string) string {
+	return fmt.Sprintf("https://storage.googleapis.com/%s/%s", ss.BucketName, name)
+}
+
+// ObjectName returns the name of the object at a public URL of the bucket,
+// or false for URLs elsewhere
+func (ss *StorageService) ObjectName(url string) (string, bool) {
+	name := strings.TrimPrefix(url, ss.PublicURL(""))
+	if name == url || name == "" {
+		return "", false
+	}
+	return name, true
+}
+
+// OpenObject opens the object name for reading; the caller closes the reader
+func (ss *StorageService) OpenObject(name string) (*storage.Reader, error) {
+	return ss.Bucket().Object(name).NewReader(ss.ctx)
+}
+
+// ReadObject returns the content of the object name, failing when it is
+// larger than limit bytes
+func (ss *StorageService) ReadObject(name string, limit int64) ([]byte, error) {
+	r, err := ss.Bucket().Object(name).NewReader(ss.ctx)
+	if err != nil {
+		return nil, err
+	}
+	defer r.Close()
+
+	if r.Attrs.Size > limit {
+		return nil, fmt.Errorf("object %s is larger than %d bytes", name, limit)
+	}
+	return io.ReadAll(io.LimitReader(r, limit))
+}
@@ -0,0 +1,135 @@
+package services
+
+import (
+	"errors"
+	"fmt"
+	"math"
+	"sort"
+	"strconv"
+	"sync"
+	"time"
+
+	"rice-monitor-api/models"
+)
+
+// traitCacheTTL is how long trait definitions are kept before they are read
+// again, so definitions changed on another instance apply within a minute
+const traitCacheTTL = time.Minute
+
+// ErrInvalidTrait wraps the errors of custom trait values that fail validation
+var ErrInvalidTrait = errors.New("invalid trait")
+
+// TraitService holds the deployment's custom trait definitions, which extend
+// the built-in traits without code changes. Every submission is validated
+// against them, so they are cached.
+type TraitService struct {
+	firestoreService *FirestoreService
+
+	mu          sync.Mutex
+	definitions map[string]models.TraitDefinition
+	loadedAt    time.Time
+}
+
+func NewTraitService(firestoreService *FirestoreService) *TraitService {
+	return &TraitService{
+		firestoreService: firestoreService,
+	}
+}
+
+// Definitions returns the custom trait definitions ordered by key
+func (ts *TraitService) Definitions() ([]models.TraitDefinition, error) {
+	byKey, err := ts.load()
+	if err != nil {
+		return nil, err
+	}
+
+	definitions := make([]models.TraitDefinition, 0, len(byKey))
+	for _, definition := range byKey {
+		definitions = append(definitions, definition)
+	}
+	sort.Slice(definitions, func(i, j int) bool { return definitions[i].Key < definitions[j].Key })
+	return definitions, nil
+}
+
+// Definition returns the custom trait key, or nil if it is not defined
+func (ts *TraitService) Definition(key string) (*models.TraitDefinition, error) {
+	byKey, err := ts.load()
+	if err != nil {
+		return nil, err
+	}
+	definition, ok := byKey[key]
+	if !ok {
+		return nil, nil
+	}
+	return &definition, nil
+}
+
+// Validate checks custom trait values against their definitions: each key
+// must be defined, integer traits take whole numbers, and values stay within
+// the trait's bounds
+func (ts *TraitService) Validate(custom map[string]float64) error {
+	if len(custom) == 0 {
+		return nil
+	}
+	byKey, err := ts.load()
+	if err != nil {
+		return err
+	}
+
+	keys := make([]string, 0, len(custom))
+	for key := range custom {
+		keys = append(keys, key)
+	}
+	sort.Strings(keys)
+
+	for _, key := range keys {
+		value := custom[key]
+		definition, ok := byKey[key]
+		if !ok {
+			return fmt.Errorf("%w: %q is not defined, see GET /traits", ErrInvalidTrait, key)
+		}
+		if definition.Type == "integer" && value != math.Trunc(value) {
+			return fmt.Errorf("%w: %s must be a whole number", ErrInvalidTrait, key)
+		}
+		if definition.Min != nil && value < *definition.Min {
+			return fmt.Errorf("%w: %s must be at least %s %s", ErrInvalidTrait, key, formatTraitValue(*definition.Min), definition.Unit)
+		}
+		if definition.Max != nil && value > *definition.Max {
+			return fmt.Errorf("%w: %s must be at most %s %s", ErrInvalidTrait, key, formatTraitValue(*definition.Max), definition.Unit)
+		}
+	}
+	return nil
+}
+
+// Invalidate drops the cached definitions after they were changed
+func (ts *TraitService) Invalidate() {
+	ts.mu.Lock()
+	defer ts.mu.Unlock()
+	ts.definitions = nil
+}
+
+// load returns the definitions by key, reading them when the cache expired
+func (ts *TraitService) load() (map[string]models.TraitDefinition, error) {
+	ts.mu.Lock()
+	defer ts.mu.Unlock()
+	if ts.definitions != nil && time.Since(ts.loadedAt) < traitCacheTTL {
+		return ts.definitions, nil
+	}
+
+	docs, err := ts.firestoreService.TraitDefinitions().Documents(ts.firestoreService.Context()).GetAll()
+	if err != nil {
+		return nil, err
+	}
+	definitions := make(map[string]models.TraitDefinition, len(docs))
+	for _, doc := range docs {
+		var definition models.TraitDefinition
+		doc.DataTo(&definition)
+		definitions[definition.Key] = definition
+	}
+	ts.definitions, ts.loadedAt = definitions, time.Now()
+	return definitions, nil
+}
+
+func formatTraitValue(value float64) string {
+	return strconv.FormatFloat(value, 'f', -1, 64)
+}
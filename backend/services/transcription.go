@@ -0,0 +1,91 @@
+package services
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"mime/multipart"
+	"net/http"
+	"os"
+	"strings"
+	"time"
+
+	"rice-monitor-api/utils"
+)
+
+// TranscriptionService turns voice notes into text with a speech-to-text
+// service speaking the OpenAI audio transcription API, which hosted and
+// self-hosted Whisper servers both offer. It is disabled unless
+// TRANSCRIPTION_URL is set.
+type TranscriptionService struct {
+	URL      string
+	APIKey   string
+	Model    string
+	Language string // ISO 639-1 hint, detected by the service when empty
+	client   *http.Client
+}
+
+func NewTranscriptionService() *TranscriptionService {
+	return &TranscriptionService{
+		URL:      os.Getenv("TRANSCRIPTION_URL"),
+		APIKey:   os.Getenv("TRANSCRIPTION_API_KEY"),
+		Model:    utils.GetEnvOrDefault("TRANSCRIPTION_MODEL", "whisper-1"),
+		Language: os.Getenv("TRANSCRIPTION_LANGUAGE"),
+		client:   &http.Client{Timeout: 2 * time.Minute},
+	}
+}
+
+// Enabled reports whether a speech-to-text service is configured
+func (ts *TranscriptionService) Enabled() bool {
+	return ts.URL != ""
+}
+
+// Transcribe returns the text spoken in the audio file name
+func (ts *TranscriptionService) Transcribe(ctx context.Context, name string, audio io.Reader) (string, error) {
+	var body bytes.Buffer
+	form := multipart.NewWriter(&body)
+	part, err := form.CreateFormFile("file", name)
+	if err != nil {
+		return "", err
+	}
+	if _, err := io.Copy(part, audio); err != nil {
+		return "", err
+	}
+	form.WriteField("model", ts.Model)
+	form.WriteField("response_format", "json")
+	if ts.Language != "" {
+		form.WriteField("language", ts.Language)
+	}
+	if err := form.Close(); err != nil {
+		return "", err
+	}
+
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, ts.URL, &body)
+	if err != nil {
+		return "", err
+	}
+	req.Header.Set("Content-Type", form.FormDataContentType())
+	if ts.APIKey != "" {
+		req.Header.Set("Authorization", "Bearer "+ts.APIKey)
+	}
+
+	resp, err := ts.client.Do(req)
+	if err != nil {
+		return "", err
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode != http.StatusOK {
+		return "", fmt.Errorf("transcription: unexpected status %d", resp.StatusCode)
+	}
+
+	var result struct {
+		Text string `json:"text"`
+	}
+	if err := json.NewDecoder(resp.Body).Decode(&result); err != nil {
+		return "", err
+	}
+	return strings.TrimSpace(result.Text), nil
+}
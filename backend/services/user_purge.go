@@ -0,0 +1,97 @@
+package services
+
+import (
+	"context"
+	"log"
+	"os"
+	"strconv"
+	"strings"
+	"time"
+
+	"rice-monitor-api/models"
+)
+
+// userPurgeInterval is how often soft-deleted users are checked for purging
+const userPurgeInterval = time.Hour
+
+// UserPurger permanently deletes users once they have been soft-deleted for
+// longer than the purge window. Until then they can be restored.
+type UserPurger struct {
+	firestoreService *FirestoreService
+	enabled          bool
+	window           time.Duration
+	stop             chan struct{}
+}
+
+func NewUserPurger(firestoreService *FirestoreService) *UserPurger {
+	days, err := strconv.Atoi(os.Getenv("USER_PURGE_DAYS"))
+	if err != nil || days <= 0 {
+		days = 30
+	}
+
+	return &UserPurger{
+		firestoreService: firestoreService,
+		enabled:          strings.ToLower(os.Getenv("USER_PURGE")) != "off",
+		window:           time.Duration(days) * 24 * time.Hour,
+		stop:             make(chan struct{}),
+	}
+}
+
+// Start purges every userPurgeInterval until Stop is called
+func (up *UserPurger) Start(ctx context.Context) error {
+	if !up.enabled {
+		log.Println("User purger disabled")
+		return nil
+	}
+
+	go func() {
+		ticker := time.NewTicker(userPurgeInterval)
+		defer ticker.Stop()
+		for {
+			select {
+			case <-up.stop:
+				return
+			case <-ticker.C:
+			}
+
+			if err := up.Run(time.Now()); err != nil {
+				log.Printf("Failed to purge deleted users: %v", err)
+			}
+		}
+	}()
+	return nil
+}
+
+// Stop cancels the schedule. A run already in progress is not interrupted.
+func (up *UserPurger) Stop(ctx context.Context) error {
+	close(up.stop)
+	return nil
+}
+
+// Run deletes the users soft-deleted before the purge window together with
+// their email reservations, so the addresses can sign up again
+func (up *UserPurger) Run(now time.Time) error {
+	ctx := up.firestoreService.Context()
+	docs, err := up.firestoreService.Users().Where("deleted_at", "<=", now.Add(-up.window)).Documents(ctx).GetAll()
+	if err != nil {
+		return err
+	}
+
+	for _, doc := range docs {
+		var user models.User
+		doc.DataTo(&user)
+
+		batch := up.firestoreService.Client.Batch()
+		batch.Delete(doc.Ref)
+		if user.Email != "" { // empty once the user's personal data was erased
+			batch.Delete(up.firestoreService.UserEmails().Doc(user.Email))
+		}
+		batch.Delete(up.firestoreService.NotificationSettings().Doc(doc.Ref.ID))
+		if _, err := batch.Commit(ctx); err != nil {
+			log.Printf("Failed to purge user %s: %v", doc.Ref.ID, err)
+			continue
+		}
+		log.Printf("Purged user %s, deleted on %s", doc.Ref.ID, user.DeletedAt.Format(time.RFC3339))
+	}
+	return nil
+}
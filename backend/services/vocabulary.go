@@ -0,0 +1,310 @@
+package services
+
+import (
+	"fmt"
+	"os"
+	"sort"
+	"strings"
+
+	"rice-monitor-api/models"
+	"rice-monitor-api/utils"
+)
+
+// vocabulary maps each supported locale to the display labels of the codes
+// stored on submissions. Codes without a label are shown as-is.
+var vocabulary = map[string]models.LocalizedLabels{
+	"en": {
+		Locale: "en",
+		Statuses: map[string]string{
+			"draft":        "Draft",
+			"submitted":    "Submitted",
+			"under_review": "Under review",
+			"approved":     "Approved",
+			"rejected":     "Rejected",
+		},
+		GrowthStages: map[string]string{
+			"Seedling":           "Seedling",
+			"Tillering":          "Tillering",
+			"Panicle Initiation": "Panicle Initiation",
+			"Flowering":          "Flowering",
+			"Milk Stage":         "Milk Stage",
+			"Dough Stage":        "Dough Stage",
+			"Maturity":           "Maturity",
+			"Harvested":          "Harvested",
+		},
+		Conditions: map[string]string{
+			"Healthy":                         "Healthy",
+			"Unhealthy":                       "Unhealthy",
+			"Signs of pest infestation":       "Signs of pest infestation",
+			"Signs of nutrient deficiency":    "Signs of nutrient deficiency",
+			"Water stress (drought or flood)": "Water stress (drought or flood)",
+			"Lodging (bent/broken stems)":     "Lodging (bent/broken stems)",
+			"Weed infestation":                "Weed infestation",
+			"Disease symptoms":                "Disease symptoms",
+			"Other":                           "Other",
+		},
+	},
+	"bn": {
+		Locale: "bn",
+		Statuses: map[string]string{
+			"draft":        "খসড়া",
+			"submitted":    "জমা দেওয়া হয়েছে",
+			"under_review": "পর্যালোচনাধীন",
+			"approved":     "অনুমোদিত",
+			"rejected":     "প্রত্যাখ্যাত",
+		},
+		GrowthStages: map[string]string{
+			"Seedling":           "চারা",
+			"Tillering":          "কুশি গজানো",
+			"Panicle Initiation": "থোড় আসা",
+			"Flowering":          "ফুল আসা",
+			"Milk Stage":         "দুধ অবস্থা",
+			"Dough Stage":        "শক্ত দানা অবস্থা",
+			"Maturity":           "পরিপক্বতা",
+			"Harvested":          "কাটা হয়েছে",
+		},
+		Conditions: map[string]string{
+			"Healthy":                         "সুস্থ",
+			"Unhealthy":                       "অসুস্থ",
+			"Signs of pest infestation":       "পোকামাকড়ের আক্রমণের লক্ষণ",
+			"Signs of nutrient deficiency":    "পুষ্টির ঘাটতির লক্ষণ",
+			"Water stress (drought or flood)": "পানির চাপ (খরা বা বন্যা)",
+			"Lodging (bent/broken stems)":     "হেলে পড়া (বাঁকা/ভাঙা কাণ্ড)",
+			"Weed infestation":                "আগাছার আক্রমণ",
+			"Disease symptoms":                "রোগের লক্ষণ",
+			"Other":                           "অন্যান্য",
+		},
+	},
+}
+
+// vocabularySynonyms lists other words observers use for the growth stages,
+// plant conditions and pests, in any supported language. Codes and their
+// labels in every locale are recognized without being listed here.
+var vocabularySynonyms = []models.VocabularyMatch{
+	{Kind: "growth_stage", Code: "Seedling", Terms: []string{"seedlings", "nursery", "বীজতলা"}},
+	{Kind: "growth_stage", Code: "Tillering", Terms: []string{"tiller", "tillers", "কুশি"}},
+	{Kind: "growth_stage", Code: "Panicle Initiation", Terms: []string{"PI", "booting", "থোড়"}},
+	{Kind: "growth_stage", Code: "Flowering", Terms: []string{"heading", "anthesis", "ফুল"}},
+	{Kind: "growth_stage", Code: "Maturity", Terms: []string{"mature", "ripening", "পাকা"}},
+	{Kind: "growth_stage", Code: "Harvested", Terms: []string{"harvest", "ধান কাটা"}},
+	{Kind: "condition", Code: "Signs of pest infestation", Terms: []string{"pest", "pests", "insects", "পোকা", "পোকামাকড়"}},
+	{Kind: "condition", Code: "Signs of nutrient deficiency", Terms: []string{"nutrient deficiency", "yellowing", "পুষ্টির অভাব"}},
+	{Kind: "condition", Code: "Water stress (drought or flood)", Terms: []string{"drought", "flood", "খরা", "বন্যা"}},
+	{Kind: "condition", Code: "Lodging (bent/broken stems)", Terms: []string{"lodging", "lodged", "হেলে পড়া"}},
+	{Kind: "condition", Code: "Weed infestation", Terms: []string{"weeds", "আগাছা"}},
+	{Kind: "condition", Code: "Disease symptoms", Terms: []string{"disease", "blast", "blight", "রোগ", "ব্লাস্ট"}},
+	{Kind: "pest", Code: "Stem borer", Terms: []string{"yellow stem borer", "dead heart", "white head", "মাজরা পোকা", "মাজরা"}},
+	{Kind: "pest", Code: "Brown planthopper", Terms: []string{"BPH", "planthopper", "hopper burn", "বাদামি গাছফড়িং", "কারেন্ট পোকা"}},
+	{Kind: "pest", Code: "Rice bug", Terms: []string{"gundhi bug", "গান্ধি পোকা"}},
+	{Kind: "pest", Code: "Leaf folder", Terms: []string{"leaf roller", "পাতা মোড়ানো পোকা"}},
+	{Kind: "pest", Code: "Rice hispa", Terms: []string{"hispa", "পামরি পোকা"}},
+	{Kind: "pest", Code: "Gall midge", Terms: []string{"onion shoot", "গলমাছি"}},
+}
+
+// vocabularyTerms indexes every code, label and synonym by its normalized term
+var vocabularyTerms = buildVocabularyTerms()
+
+// maxTermWords is the number of words in the longest term
+var maxTermWords = func() int {
+	longest := 1
+	for term := range vocabularyTerms {
+		if n := len(strings.Fields(term)); n > longest {
+			longest = n
+		}
+	}
+	return longest
+}()
+
+func buildVocabularyTerms() map[string]models.VocabularyMatch {
+	entries := map[string]*models.VocabularyMatch{}
+	entry := func(kind, code string) *models.VocabularyMatch {
+		key := kind + "\x00" + code
+		if entries[key] == nil {
+			entries[key] = &models.VocabularyMatch{Kind: kind, Code: code, Terms: []string{code}}
+		}
+		return entries[key]
+	}
+
+	for _, labels := range vocabulary {
+		for code, label := range labels.GrowthStages {
+			match := entry("growth_stage", code)
+			match.Terms = append(match.Terms, label)
+		}
+		for code, label := range labels.Conditions {
+			match := entry("condition", code)
+			match.Terms = append(match.Terms, label)
+		}
+	}
+	for _, synonym := range vocabularySynonyms {
+		match := entry(synonym.Kind, synonym.Code)
+		match.Terms = append(match.Terms, synonym.Terms...)
+	}
+
+	terms := map[string]models.VocabularyMatch{}
+	for _, match := range entries {
+		normalized := []string{}
+		for _, term := range match.Terms {
+			term = normalizeTerm(term)
+			if !utils.Contains(normalized, term) {
+				normalized = append(normalized, term)
+			}
+		}
+		sort.Strings(normalized)
+		match.Terms = normalized
+		for _, term := range normalized {
+			terms[term] = *match
+		}
+	}
+	return terms
+}
+
+// normalizeTerm lowercases term and collapses its whitespace
+func normalizeTerm(term string) string {
+	return strings.ToLower(strings.Join(strings.Fields(term), " "))
+}
+
+// VocabularyService provides display labels for submission statuses, growth
+// stages and plant conditions so clients don't have to hardcode translations,
+// and resolves the synonyms and local-language terms observers use for them
+type VocabularyService struct {
+	growthStages    []string
+	plantConditions []string
+}
+
+// NewVocabularyService uses the deployment's growth stages and plant
+// conditions, comma-separated in GROWTH_STAGES and PLANT_CONDITIONS, in place
+// of the built-in ones
+func NewVocabularyService() *VocabularyService {
+	vs := &VocabularyService{
+		growthStages:    utils.GrowthStages,
+		plantConditions: utils.PlantConditions,
+	}
+	if stages := vocabularyList("GROWTH_STAGES"); stages != nil {
+		vs.growthStages = stages
+	}
+	if conditions := vocabularyList("PLANT_CONDITIONS"); conditions != nil {
+		vs.plantConditions = conditions
+	}
+	return vs
+}
+
+// vocabularyList returns the comma-separated codes of an environment
+// variable, or nil when it is unset
+func vocabularyList(key string) []string {
+	var codes []string
+	for _, code := range strings.Split(os.Getenv(key), ",") {
+		if code = strings.Join(strings.Fields(code), " "); code != "" && !utils.Contains(codes, code) {
+			codes = append(codes, code)
+		}
+	}
+	return codes
+}
+
+// GrowthStages returns the deployment's growth stages in picker order
+func (vs *VocabularyService) GrowthStages() []string {
+	return vs.growthStages
+}
+
+// PlantConditions returns the deployment's plant conditions in picker order
+func (vs *VocabularyService) PlantConditions() []string {
+	return vs.plantConditions
+}
+
+// codes returns the canonical codes of a kind of vocabulary entry, or nil for
+// kinds that are not controlled, such as pests
+func (vs *VocabularyService) codes(kind string) []string {
+	switch kind {
+	case "growth_stage":
+		return vs.growthStages
+	case "condition":
+		return vs.plantConditions
+	}
+	return nil
+}
+
+// inUse reports whether the deployment's vocabulary still has match's code
+func (vs *VocabularyService) inUse(match models.VocabularyMatch) bool {
+	codes := vs.codes(match.Kind)
+	return codes == nil || utils.Contains(codes, match.Code)
+}
+
+// Canonical returns the growth_stage or condition code that value spells,
+// ignoring case and extra whitespace, or false when the vocabulary has none
+func (vs *VocabularyService) Canonical(kind, value string) (string, bool) {
+	for _, code := range vs.codes(kind) {
+		if normalizeTerm(code) == normalizeTerm(value) {
+			return code, true
+		}
+	}
+	return "", false
+}
+
+// Resolve returns the growth stage, plant condition or pest that term names in
+// any supported language, ignoring case and extra whitespace
+func (vs *VocabularyService) Resolve(term string) (models.VocabularyMatch, bool) {
+	match, ok := vocabularyTerms[normalizeTerm(term)]
+	if !ok || !vs.inUse(match) {
+		return models.VocabularyMatch{}, false
+	}
+	return match, true
+}
+
+// ResolvePrefix returns what the longest term formed by the leading words
+// names, and how many words it spans
+func (vs *VocabularyService) ResolvePrefix(words []string) (models.VocabularyMatch, int, bool) {
+	for n := min(len(words), maxTermWords); n > 0; n-- {
+		if match, ok := vs.Resolve(strings.Join(words[:n], " ")); ok {
+			return match, n, true
+		}
+	}
+	return models.VocabularyMatch{}, 0, false
+}
+
+// Synonyms returns every growth stage, plant condition and pest with all the
+// terms that name it, ordered by kind and code
+func (vs *VocabularyService) Synonyms() []models.VocabularyMatch {
+	entries := []models.VocabularyMatch{}
+	seen := map[string]bool{}
+	for _, match := range vocabularyTerms {
+		key := match.Kind + "\x00" + match.Code
+		if !seen[key] && vs.inUse(match) {
+			seen[key] = true
+			entries = append(entries, match)
+		}
+	}
+	sort.Slice(entries, func(i, j int) bool {
+		if entries[i].Kind != entries[j].Kind {
+			return entries[i].Kind < entries[j].Kind
+		}
+		return entries[i].Code < entries[j].Code
+	})
+	return entries
+}
+
+// Expand returns the normalized terms a search for query should match: every
+// term of the vocabulary entry it names, or just the query otherwise
+func (vs *VocabularyService) Expand(query string) []string {
+	if match, ok := vs.Resolve(query); ok {
+		return match.Terms
+	}
+	return []string{normalizeTerm(query)}
+}
+
+// Locales returns the supported locales in alphabetical order
+func (vs *VocabularyService) Locales() []string {
+	locales := make([]string, 0, len(vocabulary))
+	for locale := range vocabulary {
+		locales = append(locales, locale)
+	}
+	sort.Strings(locales)
+	return locales
+}
+
+// Labels returns the display labels for locale
+func (vs *VocabularyService) Labels(locale string) (*models.LocalizedLabels, error) {
+	labels, ok := vocabulary[strings.ToLower(locale)]
+	if !ok {
+		return nil, fmt.Errorf("unsupported locale %q, must be one of: %s", locale, strings.Join(vs.Locales(), ", "))
+	}
+	return &labels, nil
+}
@@ -0,0 +1,315 @@
+package services
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"log"
+	"math"
+	"net/http"
+	"net/url"
+	"os"
+	"strings"
+	"time"
+
+	"rice-monitor-api/models"
+	"rice-monitor-api/utils"
+
+	"cloud.google.com/go/firestore"
+)
+
+const (
+	// gddBaseTemp and gddMaxTemp bound the temperatures that count towards rice
+	// growing degree days, in °C
+	gddBaseTemp = 10.0
+	gddMaxTemp  = 30.0
+	// maxBatchWrites stays under Firestore's limit of 500 writes per batch
+	maxBatchWrites = 400
+)
+
+// WeatherService backfills the daily weather of every transplanted field from
+// the Open-Meteo archive and keeps each field's cumulative growing degree days
+// up to date
+type WeatherService struct {
+	firestoreService *FirestoreService
+	archiveURL       string
+	forecastURL      string
+	enabled          bool
+	snapshots        bool
+	client           *http.Client
+	stop             chan struct{}
+}
+
+func NewWeatherService(firestoreService *FirestoreService) *WeatherService {
+	return &WeatherService{
+		firestoreService: firestoreService,
+		archiveURL:       utils.GetEnvOrDefault("WEATHER_ARCHIVE_URL", "https://archive-api.open-meteo.com/v1/archive"),
+		forecastURL:      utils.GetEnvOrDefault("WEATHER_FORECAST_URL", "https://api.open-meteo.com/v1/forecast"),
+		enabled:          strings.ToLower(os.Getenv("WEATHER_BACKFILL")) != "off",
+		snapshots:        strings.ToLower(os.Getenv("WEATHER_SNAPSHOTS")) != "off",
+		client:           &http.Client{Timeout: 30 * time.Second},
+		stop:             make(chan struct{}),
+	}
+}
+
+// Start backfills once at startup and then every day at midnight UTC until
+// Stop is called
+func (ws *WeatherService) Start(ctx context.Context) error {
+	if !ws.enabled {
+		log.Println("Weather backfill disabled")
+		return nil
+	}
+
+	go func() {
+		next := time.Now().UTC()
+		for {
+			timer := time.NewTimer(time.Until(next))
+
+			select {
+			case <-ws.stop:
+				timer.Stop()
+				return
+			case <-timer.C:
+			}
+
+			if err := ws.Run(); err != nil {
+				log.Printf("Failed to backfill weather: %v", err)
+			}
+			next = nextReportTime("daily", time.Now().UTC())
+		}
+	}()
+	return nil
+}
+
+// Stop cancels the schedule. A backfill already running is not interrupted.
+func (ws *WeatherService) Stop(ctx context.Context) error {
+	close(ws.stop)
+	return nil
+}
+
+// Run backfills every transplanted field with coordinates
+func (ws *WeatherService) Run() error {
+	ctx := ws.firestoreService.Context()
+	docs, err := ws.firestoreService.Fields().Where("transplant_date", "!=", "").Documents(ctx).GetAll()
+	if err != nil {
+		return err
+	}
+
+	for _, doc := range docs {
+		var field models.Field
+		doc.DataTo(&field)
+
+		if err := ws.Backfill(&field); err != nil {
+			log.Printf("Failed to backfill weather for field %s: %v", field.ID, err)
+		}
+	}
+	return nil
+}
+
+// Backfill stores the daily weather of field from the day after its last
+// backfilled day (or its transplant date) to yesterday and adds the degree days
+// to its cumulative total. A changed transplant date restarts the total.
+func (ws *WeatherService) Backfill(field *models.Field) error {
+	if field.Coordinates.Latitude == 0 && field.Coordinates.Longitude == 0 {
+		return nil
+	}
+
+	transplanted, err := time.Parse("2006-01-02", field.TransplantDate)
+	if err != nil {
+		return err
+	}
+
+	start, total := transplanted, 0.0
+	if field.WeatherFrom == field.TransplantDate && field.WeatherThrough != "" {
+		through, err := time.Parse("2006-01-02", field.WeatherThrough)
+		if err != nil {
+			return err
+		}
+		start, total = through.AddDate(0, 0, 1), field.CumulativeGDD
+	}
+
+	now := time.Now().UTC()
+	end := time.Date(now.Year(), now.Month(), now.Day(), 0, 0, 0, 0, time.UTC).AddDate(0, 0, -1)
+	if start.After(end) {
+		return nil
+	}
+
+	days, err := ws.fetch(field, start, end)
+	if err != nil || len(days) == 0 {
+		return err
+	}
+
+	ctx := ws.firestoreService.Context()
+	for i := 0; i < len(days); i += maxBatchWrites {
+		batch := ws.firestoreService.Client.Batch()
+		for _, day := range days[i:min(i+maxBatchWrites, len(days))] {
+			total += day.GDD
+			batch.Set(ws.firestoreService.FieldWeather().Doc(field.ID+"-"+day.Date), day)
+		}
+		if _, err := batch.Commit(ctx); err != nil {
+			return err
+		}
+	}
+
+	field.CumulativeGDD = math.Round(total*10) / 10
+	field.WeatherFrom = field.TransplantDate
+	field.WeatherThrough = days[len(days)-1].Date
+	_, err = ws.firestoreService.Fields().Doc(field.ID).Update(ctx, []firestore.Update{
+		{Path: "cumulative_gdd", Value: field.CumulativeGDD},
+		{Path: "weather_from", Value: field.WeatherFrom},
+		{Path: "weather_through", Value: field.WeatherThrough},
+	})
+	return err
+}
+
+// fetch reads the daily temperatures and rainfall at field between start and
+// end, skipping days the archive has no data for yet
+func (ws *WeatherService) fetch(field *models.Field, start, end time.Time) ([]models.DailyWeather, error) {
+	params := url.Values{
+		"latitude":   {fmt.Sprintf("%f", field.Coordinates.Latitude)},
+		"longitude":  {fmt.Sprintf("%f", field.Coordinates.Longitude)},
+		"start_date": {utils.FormatDate(start)},
+		"end_date":   {utils.FormatDate(end)},
+		"daily":      {"temperature_2m_max,temperature_2m_min,precipitation_sum"},
+		"timezone":   {"UTC"},
+	}
+
+	resp, err := ws.client.Get(ws.archiveURL + "?" + params.Encode())
+	if err != nil {
+		return nil, err
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode != http.StatusOK {
+		return nil, fmt.Errorf("weather archive: unexpected status %d", resp.StatusCode)
+	}
+
+	var result struct {
+		Daily struct {
+			Time          []string   `json:"time"`
+			TempMax       []*float64 `json:"temperature_2m_max"`
+			TempMin       []*float64 `json:"temperature_2m_min"`
+			Precipitation []*float64 `json:"precipitation_sum"`
+		} `json:"daily"`
+	}
+	if err := json.NewDecoder(resp.Body).Decode(&result); err != nil {
+		return nil, err
+	}
+
+	daily := result.Daily
+	var days []models.DailyWeather
+	for i, date := range daily.Time {
+		// The archive lags a few days behind; stop at the first day without data
+		if i >= len(daily.TempMax) || i >= len(daily.TempMin) || daily.TempMax[i] == nil || daily.TempMin[i] == nil {
+			break
+		}
+
+		day := models.DailyWeather{
+			FieldID: field.ID,
+			Date:    date,
+			TempMax: *daily.TempMax[i],
+			TempMin: *daily.TempMin[i],
+			GDD:     DegreeDays(*daily.TempMax[i], *daily.TempMin[i]),
+		}
+		if i < len(daily.Precipitation) && daily.Precipitation[i] != nil {
+			day.Precipitation = *daily.Precipitation[i]
+		}
+		days = append(days, day)
+	}
+	return days, nil
+}
+
+// forecastHistory is how far back the forecast API serves hourly weather;
+// older hours are read from the archive
+const forecastHistory = 60 * 24 * time.Hour
+
+// Snapshot returns the temperature, humidity and rainfall at location in the
+// hour of at, with the rainfall of the 24 hours up to it, or nil when
+// snapshots are disabled
+func (ws *WeatherService) Snapshot(location models.Location, at time.Time) (*models.WeatherSnapshot, error) {
+	if !ws.snapshots {
+		return nil, nil
+	}
+
+	baseURL := ws.forecastURL
+	if time.Since(at) > forecastHistory {
+		baseURL = ws.archiveURL
+	}
+
+	at = at.UTC()
+	params := url.Values{
+		"latitude":   {fmt.Sprintf("%f", location.Latitude)},
+		"longitude":  {fmt.Sprintf("%f", location.Longitude)},
+		"start_date": {utils.FormatDate(at.AddDate(0, 0, -1))},
+		"end_date":   {utils.FormatDate(at)},
+		"hourly":     {"temperature_2m,relative_humidity_2m,precipitation"},
+		"timezone":   {"UTC"},
+	}
+
+	resp, err := ws.client.Get(baseURL + "?" + params.Encode())
+	if err != nil {
+		return nil, err
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode != http.StatusOK {
+		return nil, fmt.Errorf("weather lookup: unexpected status %d", resp.StatusCode)
+	}
+
+	var result struct {
+		Hourly struct {
+			Temperature   []*float64 `json:"temperature_2m"`
+			Humidity      []*float64 `json:"relative_humidity_2m"`
+			Precipitation []*float64 `json:"precipitation"`
+		} `json:"hourly"`
+	}
+	if err := json.NewDecoder(resp.Body).Decode(&result); err != nil {
+		return nil, err
+	}
+
+	// Hourly values start at midnight UTC of the day before
+	hour := 24 + at.Hour()
+	hourly := result.Hourly
+	snapshot := &models.WeatherSnapshot{
+		TemperatureC:  hourlyValue(hourly.Temperature, hour),
+		HumidityPct:   hourlyValue(hourly.Humidity, hour),
+		RainfallMM:    hourlyValue(hourly.Precipitation, hour),
+		Rainfall24hMM: hourlySum(hourly.Precipitation, hour-23, hour),
+		Source:        "open-meteo",
+	}
+	if snapshot.TemperatureC == nil && snapshot.HumidityPct == nil && snapshot.RainfallMM == nil {
+		return nil, fmt.Errorf("weather lookup: no data for %s", at.Format(time.RFC3339))
+	}
+	return snapshot, nil
+}
+
+func hourlyValue(values []*float64, hour int) *float64 {
+	if hour >= len(values) {
+		return nil
+	}
+	return values[hour]
+}
+
+// hourlySum adds up the hourly values from first to last, or returns nil when
+// any of them is missing
+func hourlySum(values []*float64, first, last int) *float64 {
+	var sum float64
+	for hour := first; hour <= last; hour++ {
+		value := hourlyValue(values, hour)
+		if value == nil {
+			return nil
+		}
+		sum += *value
+	}
+	sum = math.Round(sum*10) / 10
+	return &sum
+}
+
+// DegreeDays returns the rice growing degree days of one day, with
+// temperatures clamped between the base and maximum temperature
+func DegreeDays(tempMax, tempMin float64) float64 {
+	clamp := func(t float64) float64 {
+		return math.Max(gddBaseTemp, math.Min(gddMaxTemp, t))
+	}
+	return (clamp(tempMax)+clamp(tempMin))/2 - gddBaseTemp
+}
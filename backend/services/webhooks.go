@@ -0,0 +1,303 @@
+package services
+
+import (
+	"bytes"
+	"context"
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"log"
+	"net"
+	"net/http"
+	"strconv"
+	"syscall"
+	"time"
+
+	"rice-monitor-api/models"
+	"rice-monitor-api/utils"
+
+	"cloud.google.com/go/firestore"
+)
+
+// Submission events webhooks can subscribe to
+const (
+	WebhookEventSubmissionCreated  = "submission.created"
+	WebhookEventSubmissionUpdated  = "submission.updated"
+	WebhookEventSubmissionApproved = "submission.approved"
+)
+
+// WebhookEvents lists the events webhooks can subscribe to
+var WebhookEvents = []string{WebhookEventSubmissionCreated, WebhookEventSubmissionUpdated, WebhookEventSubmissionApproved}
+
+// webhookRetryDelays are the waits before each retry of a failed delivery;
+// a delivery still failing after the last one is given up
+var webhookRetryDelays = []time.Duration{time.Minute, 5 * time.Minute, 30 * time.Minute, 2 * time.Hour, 12 * time.Hour}
+
+// webhookLease is how long a delivery being retried is held, so other
+// instances running the retries leave it alone
+const webhookLease = 2 * time.Minute
+
+// errPrivateAddress is returned when a webhook URL resolves to an address on
+// the deployment's own network
+var errPrivateAddress = errors.New("webhook address is not public")
+
+// WebhookService delivers submission events to the webhooks subscribed to
+// them. Events are sent as they happen and failed deliveries are retried
+// every minute with a growing delay; every attempt is kept in the delivery log.
+type WebhookService struct {
+	firestoreService *FirestoreService
+	client           *http.Client
+	stop             chan struct{}
+}
+
+func NewWebhookService(firestoreService *FirestoreService) *WebhookService {
+	// Webhooks are registered by admins but point anywhere, so only public
+	// addresses are dialed, after redirects and DNS resolution too
+	dialer := &net.Dialer{
+		Timeout: 10 * time.Second,
+		Control: func(network, address string, _ syscall.RawConn) error {
+			host, _, err := net.SplitHostPort(address)
+			if err != nil {
+				return err
+			}
+			ip := net.ParseIP(host)
+			if ip == nil || ip.IsLoopback() || ip.IsPrivate() || ip.IsLinkLocalUnicast() || ip.IsLinkLocalMulticast() || ip.IsUnspecified() {
+				return errPrivateAddress
+			}
+			return nil
+		},
+	}
+	return &WebhookService{
+		firestoreService: firestoreService,
+		client: &http.Client{
+			Timeout:   15 * time.Second,
+			Transport: &http.Transport{DialContext: dialer.DialContext, TLSHandshakeTimeout: 10 * time.Second},
+		},
+		stop: make(chan struct{}),
+	}
+}
+
+// Start retries failed deliveries every minute until Stop is called
+func (ws *WebhookService) Start(ctx context.Context) error {
+	go func() {
+		ticker := time.NewTicker(time.Minute)
+		defer ticker.Stop()
+		for {
+			select {
+			case <-ws.stop:
+				return
+			case <-ticker.C:
+			}
+
+			if err := ws.Retry(time.Now()); err != nil {
+				log.Printf("Failed to retry webhook deliveries: %v", err)
+			}
+		}
+	}()
+	return nil
+}
+
+// Stop cancels the retries. Deliveries being sent are not interrupted.
+func (ws *WebhookService) Stop(ctx context.Context) error {
+	close(ws.stop)
+	return nil
+}
+
+// Dispatch sends event with data to every enabled webhook subscribed to it.
+// Failures are logged and retried, never returned to the caller.
+func (ws *WebhookService) Dispatch(event string, data interface{}) {
+	ctx := ws.firestoreService.Context()
+	docs, err := ws.firestoreService.Webhooks().
+		Where("enabled", "==", true).
+		Where("events", "array-contains", event).
+		Documents(ctx).GetAll()
+	if err != nil {
+		log.Printf("Failed to load %s webhooks: %v", event, err)
+		return
+	}
+
+	for _, doc := range docs {
+		var webhook models.Webhook
+		doc.DataTo(&webhook)
+
+		now := time.Now()
+		delivery := models.WebhookDelivery{
+			ID:            utils.GenerateID(),
+			WebhookID:     webhook.ID,
+			Event:         event,
+			Status:        "pending",
+			NextAttemptAt: &now,
+			CreatedAt:     now,
+		}
+		payload, err := json.Marshal(map[string]interface{}{
+			"id":         delivery.ID,
+			"event":      event,
+			"created_at": now,
+			"data":       data,
+		})
+		if err != nil {
+			log.Printf("Failed to encode %s event: %v", event, err)
+			return
+		}
+		delivery.Payload = string(payload)
+
+		if _, err := ws.firestoreService.WebhookDeliveries().Doc(delivery.ID).Set(ctx, delivery); err != nil {
+			log.Printf("Failed to log delivery to webhook %s: %v", webhook.ID, err)
+			continue
+		}
+		ws.deliver(&webhook, &delivery)
+	}
+}
+
+// Retry sends again the pending deliveries that are due at now
+func (ws *WebhookService) Retry(now time.Time) error {
+	ctx := ws.firestoreService.Context()
+	docs, err := ws.firestoreService.WebhookDeliveries().
+		Where("status", "==", "pending").
+		Where("next_attempt_at", "<=", now).
+		Documents(ctx).GetAll()
+	if err != nil {
+		return err
+	}
+
+	for _, doc := range docs {
+		delivery, ok, err := ws.claim(doc.Ref, now)
+		if err != nil {
+			log.Printf("Failed to claim webhook delivery %s: %v", doc.Ref.ID, err)
+			continue
+		}
+		if !ok {
+			continue
+		}
+
+		webhookDoc, err := ws.firestoreService.Webhooks().Doc(delivery.WebhookID).Get(ctx)
+		if err != nil {
+			ws.giveUp(delivery, "webhook was deleted")
+			continue
+		}
+		var webhook models.Webhook
+		webhookDoc.DataTo(&webhook)
+		if !webhook.Enabled {
+			ws.giveUp(delivery, "webhook is disabled")
+			continue
+		}
+		ws.deliver(&webhook, delivery)
+	}
+	return nil
+}
+
+// Redeliver queues a delivery to be sent again with the next retries,
+// whatever its status
+func (ws *WebhookService) Redeliver(delivery *models.WebhookDelivery) error {
+	now := time.Now()
+	delivery.Status, delivery.NextAttemptAt = "pending", &now
+	_, err := ws.firestoreService.WebhookDeliveries().Doc(delivery.ID).Update(ws.firestoreService.Context(), []firestore.Update{
+		{Path: "status", Value: delivery.Status},
+		{Path: "next_attempt_at", Value: delivery.NextAttemptAt},
+	})
+	return err
+}
+
+// Send posts payload to the webhook, signed with its secret. The signature is
+// the hex HMAC-SHA256 of the timestamp, a dot and the body. It returns the
+// response status code, 0 when none was received.
+func (ws *WebhookService) Send(webhook *models.Webhook, deliveryID, event string, payload []byte) (int, error) {
+	timestamp := strconv.FormatInt(time.Now().Unix(), 10)
+	mac := hmac.New(sha256.New, []byte(webhook.Secret))
+	mac.Write([]byte(timestamp + "."))
+	mac.Write(payload)
+
+	req, err := http.NewRequest(http.MethodPost, webhook.URL, bytes.NewReader(payload))
+	if err != nil {
+		return 0, err
+	}
+	req.Header.Set("Content-Type", "application/json; charset=utf-8")
+	req.Header.Set("User-Agent", "RiceMonitor-Webhooks/1.0")
+	req.Header.Set("X-Rice-Monitor-Event", event)
+	req.Header.Set("X-Rice-Monitor-Delivery", deliveryID)
+	req.Header.Set("X-Rice-Monitor-Timestamp", timestamp)
+	req.Header.Set("X-Rice-Monitor-Signature", "sha256="+hex.EncodeToString(mac.Sum(nil)))
+
+	resp, err := ws.client.Do(req)
+	if err != nil {
+		return 0, err
+	}
+	defer resp.Body.Close()
+	io.Copy(io.Discard, io.LimitReader(resp.Body, 64<<10))
+
+	if resp.StatusCode < 200 || resp.StatusCode >= 300 {
+		return resp.StatusCode, fmt.Errorf("webhook returned status %d", resp.StatusCode)
+	}
+	return resp.StatusCode, nil
+}
+
+// deliver sends a delivery and records the outcome on it and on the webhook.
+// A failed delivery is scheduled for its next retry, or given up after the last.
+func (ws *WebhookService) deliver(webhook *models.Webhook, delivery *models.WebhookDelivery) {
+	code, err := ws.Send(webhook, delivery.ID, delivery.Event, []byte(delivery.Payload))
+	now := time.Now()
+
+	delivery.Attempts++
+	delivery.ResponseCode = code
+	delivery.LastError = ""
+	switch {
+	case err == nil:
+		delivery.Status, delivery.NextAttemptAt, delivery.DeliveredAt = "delivered", nil, &now
+	case delivery.Attempts <= len(webhookRetryDelays):
+		next := now.Add(webhookRetryDelays[delivery.Attempts-1])
+		delivery.Status, delivery.NextAttemptAt, delivery.LastError = "pending", &next, err.Error()
+	default:
+		delivery.Status, delivery.NextAttemptAt, delivery.LastError = "failed", nil, err.Error()
+	}
+	if err != nil {
+		log.Printf("Failed to deliver %s to webhook %s: %v", delivery.Event, webhook.ID, err)
+	}
+
+	ctx := ws.firestoreService.Context()
+	if _, err := ws.firestoreService.WebhookDeliveries().Doc(delivery.ID).Set(ctx, delivery); err != nil {
+		log.Printf("Failed to record delivery %s: %v", delivery.ID, err)
+	}
+	_, err = ws.firestoreService.Webhooks().Doc(webhook.ID).Update(ctx, []firestore.Update{
+		{Path: "last_delivery_at", Value: now},
+		{Path: "last_error", Value: delivery.LastError},
+	})
+	if err != nil {
+		log.Printf("Failed to record delivery for webhook %s: %v", webhook.ID, err)
+	}
+}
+
+// claim leases a due pending delivery for this instance. It returns false
+// when the delivery was sent or claimed by another instance meanwhile.
+func (ws *WebhookService) claim(ref *firestore.DocumentRef, now time.Time) (*models.WebhookDelivery, bool, error) {
+	var delivery models.WebhookDelivery
+	claimed := false
+	err := ws.firestoreService.Client.RunTransaction(ws.firestoreService.Context(), func(ctx context.Context, tx *firestore.Transaction) error {
+		doc, err := tx.Get(ref)
+		if err != nil {
+			return err
+		}
+		doc.DataTo(&delivery)
+		if delivery.Status != "pending" || delivery.NextAttemptAt == nil || delivery.NextAttemptAt.After(now) {
+			return nil
+		}
+		claimed = true
+		return tx.Update(ref, []firestore.Update{{Path: "next_attempt_at", Value: now.Add(webhookLease)}})
+	})
+	return &delivery, claimed, err
+}
+
+// giveUp marks a delivery failed without sending it
+func (ws *WebhookService) giveUp(delivery *models.WebhookDelivery, reason string) {
+	_, err := ws.firestoreService.WebhookDeliveries().Doc(delivery.ID).Update(ws.firestoreService.Context(), []firestore.Update{
+		{Path: "status", Value: "failed"},
+		{Path: "next_attempt_at", Value: nil},
+		{Path: "last_error", Value: reason},
+	})
+	if err != nil {
+		log.Printf("Failed to record delivery %s: %v", delivery.ID, err)
+	}
+}
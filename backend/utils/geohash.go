@@ -0,0 +1,95 @@
+package utils
+
+import (
+	"math"
+	"strings"
+
+	"rice-monitor-api/models"
+)
+
+// FieldGeohashPrecision is the geohash length stored on fields, cells of
+// about 5 m by 5 m
+const FieldGeohashPrecision = 9
+
+const geohashAlphabet = "0123456789bcdefghjkmnpqrstuvwxyz"
+
+// EncodeGeohash returns the geohash of a position with precision characters
+func EncodeGeohash(latitude, longitude float64, precision int) string {
+	latRange := [2]float64{-90, 90}
+	lngRange := [2]float64{-180, 180}
+
+	var hash strings.Builder
+	bit, ch, even := 0, 0, true
+	for hash.Len() < precision {
+		// Bits alternate between longitude and latitude, longitude first
+		value, bounds := longitude, &lngRange
+		if !even {
+			value, bounds = latitude, &latRange
+		}
+		mid := (bounds[0] + bounds[1]) / 2
+		if value >= mid {
+			ch |= 1 << (4 - bit)
+			bounds[0] = mid
+		} else {
+			bounds[1] = mid
+		}
+		even = !even
+
+		if bit++; bit == 5 {
+			hash.WriteByte(geohashAlphabet[ch])
+			bit, ch = 0, 0
+		}
+	}
+	return hash.String()
+}
+
+// GeohashCells returns the geohash prefixes whose cells cover every position
+// within radiusKm of the given one: the cell holding it and its 8 neighbors,
+// at the longest precision whose cells are at least radiusKm across
+func GeohashCells(latitude, longitude, radiusKm float64) []string {
+	const kmPerDegree = 6371.0 * math.Pi / 180
+
+	precision := 1
+	for p := FieldGeohashPrecision; p > 1; p-- {
+		latDeg, lngDeg := geohashCellDegrees(p)
+		widthKm := lngDeg * kmPerDegree * math.Cos(math.Min(math.Abs(latitude)+latDeg, 90)*math.Pi/180)
+		if latDeg*kmPerDegree >= radiusKm && widthKm >= radiusKm {
+			precision = p
+			break
+		}
+	}
+
+	latDeg, lngDeg := geohashCellDegrees(precision)
+	seen := make(map[string]bool)
+	var cells []string
+	for _, dLat := range []float64{-latDeg, 0, latDeg} {
+		for _, dLng := range []float64{-lngDeg, 0, lngDeg} {
+			lat := math.Max(-90, math.Min(90, latitude+dLat))
+			lng := math.Mod(longitude+dLng+540, 360) - 180
+			cell := EncodeGeohash(lat, lng, precision)
+			if !seen[cell] {
+				seen[cell] = true
+				cells = append(cells, cell)
+			}
+		}
+	}
+	return cells
+}
+
+// geohashCellDegrees returns the height and width of geohash cells of a
+// precision in degrees
+func geohashCellDegrees(precision int) (float64, float64) {
+	bits := 5 * precision
+	lngBits := (bits + 1) / 2
+	latBits := bits / 2
+	return 180 / math.Pow(2, float64(latBits)), 360 / math.Pow(2, float64(lngBits))
+}
+
+// FieldGeohash returns the geohash stored on a field at coordinates, or ""
+// for a field without coordinates
+func FieldGeohash(coordinates models.Location) string {
+	if coordinates.Latitude == 0 && coordinates.Longitude == 0 {
+		return ""
+	}
+	return EncodeGeohash(coordinates.Latitude, coordinates.Longitude, FieldGeohashPrecision)
+}
@@ -0,0 +1,94 @@
+package utils
+
+import (
+	"encoding/json"
+	"fmt"
+	"io"
+
+	"rice-monitor-api/models"
+)
+
+// GeoJSONFeature is a point with its properties. Features without a point
+// have a null geometry, which GIS tools load as attribute-only rows.
+type GeoJSONFeature struct {
+	ID         string
+	Point      *models.Location
+	Properties map[string]interface{}
+}
+
+type geoJSONGeometry struct {
+	Type        string     `json:"type"`
+	Coordinates [2]float64 `json:"coordinates"` // longitude, latitude
+}
+
+type geoJSONFeature struct {
+	Type       string                 `json:"type"`
+	ID         string                 `json:"id,omitempty"`
+	Geometry   *geoJSONGeometry       `json:"geometry"`
+	Properties map[string]interface{} `json:"properties"`
+}
+
+// WriteGeoJSON writes the features as an RFC 7946 FeatureCollection
+func WriteGeoJSON(w io.Writer, features []GeoJSONFeature) error {
+	collection := struct {
+		Type     string           `json:"type"`
+		Features []geoJSONFeature `json:"features"`
+	}{Type: "FeatureCollection", Features: make([]geoJSONFeature, len(features))}
+
+	for i, feature := range features {
+		collection.Features[i] = geoJSONFeature{
+			Type:       "Feature",
+			ID:         feature.ID,
+			Properties: feature.Properties,
+		}
+		if feature.Point != nil {
+			collection.Features[i].Geometry = &geoJSONGeometry{
+				Type:        "Point",
+				Coordinates: [2]float64{feature.Point.Longitude, feature.Point.Latitude},
+			}
+		}
+	}
+
+	return json.NewEncoder(w).Encode(collection)
+}
+
+// GeoJSONPolygonOf returns a boundary as a GeoJSON polygon with its ring
+// closed, or nil for fields without one
+func GeoJSONPolygonOf(boundary []models.Location) *models.GeoJSONPolygon {
+	if len(boundary) < 3 {
+		return nil
+	}
+	ring := make([][]float64, 0, len(boundary)+1)
+	for _, point := range boundary {
+		ring = append(ring, []float64{point.Longitude, point.Latitude})
+	}
+	ring = append(ring, []float64{boundary[0].Longitude, boundary[0].Latitude})
+	return &models.GeoJSONPolygon{Type: "Polygon", Coordinates: [][][]float64{ring}}
+}
+
+// BoundaryFromGeoJSON returns the vertices of a GeoJSON polygon's ring, without
+// the closing position. Polygons with holes are not supported.
+func BoundaryFromGeoJSON(polygon *models.GeoJSONPolygon) ([]models.Location, error) {
+	if polygon.Type != "Polygon" {
+		return nil, fmt.Errorf("boundary_geojson must be a GeoJSON Polygon")
+	}
+	if len(polygon.Coordinates) != 1 {
+		return nil, fmt.Errorf("boundary_geojson must have exactly one ring, holes are not supported")
+	}
+
+	ring := polygon.Coordinates[0]
+	if len(ring) < 4 {
+		return nil, fmt.Errorf("boundary_geojson ring must have at least 4 positions")
+	}
+	boundary := make([]models.Location, len(ring))
+	for i, position := range ring {
+		if len(position) < 2 {
+			return nil, fmt.Errorf("boundary_geojson position %d must be [longitude, latitude]", i)
+		}
+		boundary[i] = models.Location{Latitude: position[1], Longitude: position[0]}
+	}
+	if boundary[0] != boundary[len(boundary)-1] {
+		return nil, fmt.Errorf("boundary_geojson ring must be closed, its last position repeating the first")
+	}
+	return boundary[:len(boundary)-1], nil
+}
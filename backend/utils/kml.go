@@ -0,0 +1,73 @@
+package utils
+
+import (
+	"bytes"
+	"encoding/xml"
+	"fmt"
+	"io"
+
+	"rice-monitor-api/models"
+)
+
+// KMLStyle colors the placemarks that use its ID. Colors are KML aabbggrr hex.
+type KMLStyle struct {
+	ID        string
+	LineColor string
+	FillColor string
+}
+
+// KMLPlacemark is a field drawn as its boundary polygon, or as a point at its
+// coordinates when it has no boundary. Description is HTML shown in the popup.
+type KMLPlacemark struct {
+	Name        string
+	Description string
+	StyleID     string
+	Point       models.Location
+	Boundary    []models.Location
+}
+
+// WriteKML writes the placemarks as a KML document for Google Earth
+func WriteKML(w io.Writer, name string, styles []KMLStyle, placemarks []KMLPlacemark) error {
+	var doc bytes.Buffer
+	doc.WriteString(`<?xml version="1.0" encoding="UTF-8"?>
+<kml xmlns="http://www.opengis.net/kml/2.2"><Document>`)
+	writeKMLElement(&doc, "name", name)
+
+	for _, style := range styles {
+		fmt.Fprintf(&doc, `<Style id="%s"><LineStyle><color>%s</color><width>2</width></LineStyle>`+
+			`<PolyStyle><color>%s</color></PolyStyle><IconStyle><color>%s</color></IconStyle></Style>`,
+			style.ID, style.LineColor, style.FillColor, style.LineColor)
+	}
+
+	for _, placemark := range placemarks {
+		doc.WriteString("<Placemark>")
+		writeKMLElement(&doc, "name", placemark.Name)
+		writeKMLElement(&doc, "description", placemark.Description)
+		writeKMLElement(&doc, "styleUrl", "#"+placemark.StyleID)
+
+		if len(placemark.Boundary) >= 3 {
+			doc.WriteString("<Polygon><outerBoundaryIs><LinearRing><coordinates>")
+			for _, point := range placemark.Boundary {
+				fmt.Fprintf(&doc, "%f,%f,0 ", point.Longitude, point.Latitude)
+			}
+			// KML rings repeat their first point to close
+			first := placemark.Boundary[0]
+			fmt.Fprintf(&doc, "%f,%f,0", first.Longitude, first.Latitude)
+			doc.WriteString("</coordinates></LinearRing></outerBoundaryIs></Polygon>")
+		} else {
+			fmt.Fprintf(&doc, "<Point><coordinates>%f,%f,0</coordinates></Point>",
+				placemark.Point.Longitude, placemark.Point.Latitude)
+		}
+		doc.WriteString("</Placemark>")
+	}
+	doc.WriteString("</Document></kml>")
+
+	_, err := w.Write(doc.Bytes())
+	return err
+}
+
+func writeKMLElement(doc *bytes.Buffer, tag, text string) {
+	doc.WriteString("<" + tag + ">")
+	xml.EscapeText(doc, []byte(text))
+	doc.WriteString("</" + tag + ">")
+}
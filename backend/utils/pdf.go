@@ -0,0 +1,249 @@
+package utils
+
+import (
+	"bytes"
+	"fmt"
+	"image"
+	"image/jpeg"
+	"io"
+	"strings"
+)
+
+// A4 page size and layout in points
+const (
+	pdfPageWidth  = 595.0
+	pdfPageHeight = 842.0
+	pdfMargin     = 50.0
+	pdfValueX     = 190.0 // where field values start, right of their labels
+)
+
+// pdfImage is an embedded JPEG
+type pdfImage struct {
+	data          []byte
+	width, height int
+}
+
+// PDF lays out a simple A4 document from top to bottom, starting a new page
+// when one is full. Text uses the standard Helvetica fonts, which only cover
+// Latin-1; other characters are printed as "?".
+type PDF struct {
+	pages  []*bytes.Buffer
+	images []pdfImage
+	y      float64
+}
+
+// NewPDF starts a document with one empty page
+func NewPDF() *PDF {
+	p := &PDF{}
+	p.newPage()
+	return p
+}
+
+func (p *PDF) newPage() {
+	p.pages = append(p.pages, &bytes.Buffer{})
+	p.y = pdfPageHeight - pdfMargin
+}
+
+// reserve moves down by height, on a new page if the current one is too full
+func (p *PDF) reserve(height float64) {
+	if p.y-height < pdfMargin {
+		p.newPage()
+	}
+	p.y -= height
+}
+
+func (p *PDF) page() *bytes.Buffer {
+	return p.pages[len(p.pages)-1]
+}
+
+func (p *PDF) text(x, size float64, bold bool, text string) {
+	font := "F1"
+	if bold {
+		font = "F2"
+	}
+	fmt.Fprintf(p.page(), "BT /%s %.1f Tf %.2f %.2f Td (%s) Tj ET\n", font, size, x, p.y, pdfString(text))
+}
+
+// Title writes a large bold line
+func (p *PDF) Title(text string) {
+	p.reserve(22)
+	p.text(pdfMargin, 18, true, text)
+	p.y -= 6
+}
+
+// Heading writes a bold section heading underlined across the page
+func (p *PDF) Heading(text string) {
+	p.reserve(30)
+	p.text(pdfMargin, 13, true, text)
+	fmt.Fprintf(p.page(), "0.5 w %.2f %.2f m %.2f %.2f l S\n", pdfMargin, p.y-4, pdfPageWidth-pdfMargin, p.y-4)
+	p.y -= 8
+}
+
+// Field writes a bold label with its value beside it, wrapping long values
+func (p *PDF) Field(label, value string) {
+	lines := wrapPDFText(value, pdfPageWidth-pdfMargin-pdfValueX, 10)
+	for i, line := range lines {
+		p.reserve(14)
+		if i == 0 {
+			p.text(pdfMargin, 10, true, label)
+		}
+		p.text(pdfValueX, 10, false, line)
+	}
+}
+
+// Paragraph writes text across the page, wrapping long lines
+func (p *PDF) Paragraph(text string) {
+	for _, line := range wrapPDFText(text, pdfPageWidth-2*pdfMargin, 10) {
+		p.reserve(14)
+		p.text(pdfMargin, 10, false, line)
+	}
+	p.y -= 4
+}
+
+// Thumbnails draws the images in rows of three, each scaled to fit a cell
+func (p *PDF) Thumbnails(images []image.Image) error {
+	const perRow, gap, cellHeight = 3, 10.0, 120.0
+	cellWidth := (pdfPageWidth - 2*pdfMargin - gap*(perRow-1)) / perRow
+
+	for i, img := range images {
+		if i%perRow == 0 {
+			p.reserve(cellHeight + gap)
+		}
+
+		var buf bytes.Buffer
+		if err := jpeg.Encode(&buf, img, &jpeg.Options{Quality: 80}); err != nil {
+			return err
+		}
+		bounds := img.Bounds()
+		p.images = append(p.images, pdfImage{data: buf.Bytes(), width: bounds.Dx(), height: bounds.Dy()})
+
+		scale := min(cellWidth/float64(bounds.Dx()), cellHeight/float64(bounds.Dy()))
+		width, height := float64(bounds.Dx())*scale, float64(bounds.Dy())*scale
+		x := pdfMargin + float64(i%perRow)*(cellWidth+gap)
+		fmt.Fprintf(p.page(), "q %.2f 0 0 %.2f %.2f %.2f cm /Im%d Do Q\n", width, height, x, p.y+cellHeight-height, len(p.images))
+	}
+	return nil
+}
+
+// Write writes the document as PDF 1.4
+func (p *PDF) Write(w io.Writer) error {
+	var out bytes.Buffer
+	var offsets []int
+	object := func(body string) {
+		offsets = append(offsets, out.Len())
+		fmt.Fprintf(&out, "%d 0 obj\n%s\nendobj\n", len(offsets), body)
+	}
+	stream := func(dict string, data []byte) {
+		offsets = append(offsets, out.Len())
+		fmt.Fprintf(&out, "%d 0 obj\n<< %s /Length %d >>\nstream\n", len(offsets), dict, len(data))
+		out.Write(data)
+		out.WriteString("\nendstream\nendobj\n")
+	}
+
+	out.WriteString("%PDF-1.4\n%\xe2\xe3\xcf\xd3\n")
+
+	// Objects 1-4 are the catalog, page tree and fonts, followed by the
+	// images, then each page and its content
+	firstImage := 5
+	firstPage := firstImage + len(p.images)
+	kids := make([]string, len(p.pages))
+	for i := range p.pages {
+		kids[i] = fmt.Sprintf("%d 0 R", firstPage+2*i)
+	}
+	xobjects := make([]string, len(p.images))
+	for i := range p.images {
+		xobjects[i] = fmt.Sprintf("/Im%d %d 0 R", i+1, firstImage+i)
+	}
+
+	object("<< /Type /Catalog /Pages 2 0 R >>")
+	object(fmt.Sprintf("<< /Type /Pages /Kids [%s] /Count %d >>", strings.Join(kids, " "), len(p.pages)))
+	object("<< /Type /Font /Subtype /Type1 /BaseFont /Helvetica /Encoding /WinAnsiEncoding >>")
+	object("<< /Type /Font /Subtype /Type1 /BaseFont /Helvetica-Bold /Encoding /WinAnsiEncoding >>")
+	for _, img := range p.images {
+		stream(fmt.Sprintf("/Type /XObject /Subtype /Image /Width %d /Height %d /ColorSpace /DeviceRGB /BitsPerComponent 8 /Filter /DCTDecode",
+			img.width, img.height), img.data)
+	}
+	resources := fmt.Sprintf("<< /Font << /F1 3 0 R /F2 4 0 R >> /XObject << %s >> >>", strings.Join(xobjects, " "))
+	for i, content := range p.pages {
+		object(fmt.Sprintf("<< /Type /Page /Parent 2 0 R /MediaBox [0 0 %.0f %.0f] /Resources %s /Contents %d 0 R >>",
+			pdfPageWidth, pdfPageHeight, resources, firstPage+2*i+1))
+		stream("", content.Bytes())
+	}
+
+	xref := out.Len()
+	fmt.Fprintf(&out, "xref\n0 %d\n0000000000 65535 f \n", len(offsets)+1)
+	for _, offset := range offsets {
+		fmt.Fprintf(&out, "%010d 00000 n \n", offset)
+	}
+	fmt.Fprintf(&out, "trailer\n<< /Size %d /Root 1 0 R >>\nstartxref\n%d\n%%%%EOF\n", len(offsets)+1, xref)
+
+	_, err := w.Write(out.Bytes())
+	return err
+}
+
+// pdfString escapes text for a PDF string in WinAnsi encoding
+func pdfString(text string) string {
+	var b strings.Builder
+	for _, r := range text {
+		switch {
+		case r == '(' || r == ')' || r == '\\':
+			b.WriteByte('\\')
+			b.WriteRune(r)
+		case r == '\t':
+			b.WriteByte(' ')
+		case r < 32 || r > 255 || (r >= 127 && r < 160):
+			b.WriteByte('?')
+		default:
+			b.WriteByte(byte(r))
+		}
+	}
+	return b.String()
+}
+
+// wrapPDFText breaks text into lines of at most width points, estimating
+// Helvetica's average character width. Line breaks in text are kept.
+func wrapPDFText(text string, width, size float64) []string {
+	maxChars := int(width / (0.5 * size))
+	var lines []string
+	for _, paragraph := range strings.Split(text, "\n") {
+		line := ""
+		for _, word := range strings.Fields(paragraph) {
+			for len([]rune(word)) > maxChars {
+				if line != "" {
+					lines = append(lines, line)
+					line = ""
+				}
+				lines = append(lines, string([]rune(word)[:maxChars]))
+				word = string([]rune(word)[maxChars:])
+			}
+			switch {
+			case line == "":
+				line = word
+			case len([]rune(line))+1+len([]rune(word)) <= maxChars:
+				line += " " + word
+			default:
+				lines = append(lines, line)
+				line = word
+			}
+		}
+		lines = append(lines, line)
+	}
+	return lines
+}
+
+// Thumbnail scales img down to fit within size pixels on its longer side,
+// sampling the nearest pixel, as an RGB image ready for JPEG encoding
+func Thumbnail(img image.Image, size int) image.Image {
+	bounds := img.Bounds()
+	scale := min(1, float64(size)/float64(max(bounds.Dx(), bounds.Dy())))
+	width := max(1, int(float64(bounds.Dx())*scale))
+	height := max(1, int(float64(bounds.Dy())*scale))
+
+	thumbnail := image.NewRGBA(image.Rect(0, 0, width, height))
+	for y := 0; y < height; y++ {
+		for x := 0; x < width; x++ {
+			thumbnail.Set(x, y, img.At(bounds.Min.X+int(float64(x)/scale), bounds.Min.Y+int(float64(y)/scale)))
+		}
+	}
+	return thumbnail
+}
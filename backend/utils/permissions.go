@@ -0,0 +1,189 @@
+package utils
+
+import "rice-monitor-api/models"
+
+// Permission names an action that can be granted to a role
+type Permission string
+
+const (
+	// PermSubmissionsReadAll allows reading and exporting submissions of every user
+	PermSubmissionsReadAll Permission = "submissions:read_all"
+	// PermSubmissionsUpdateAll allows editing submissions of every user
+	PermSubmissionsUpdateAll Permission = "submissions:update_all"
+	// PermSubmissionsDeleteAll allows deleting submissions of every user
+	PermSubmissionsDeleteAll Permission = "submissions:delete_all"
+	// PermSubmissionsApprove allows moving submissions through review
+	PermSubmissionsApprove Permission = "submissions:approve"
+	// PermSubmissionsAuditRead allows reading the change log of submissions
+	PermSubmissionsAuditRead Permission = "submissions:audit_read"
+
+	// PermFieldsReadAll allows reading fields owned by other users
+	PermFieldsReadAll Permission = "fields:read_all"
+	// PermFieldsUpdateAll allows editing fields owned by other users
+	PermFieldsUpdateAll Permission = "fields:update_all"
+	// PermFieldsDelete allows deleting fields owned by other users
+	PermFieldsDelete Permission = "fields:delete"
+	// PermFieldsAssign allows assigning users to fields and removing them
+	PermFieldsAssign Permission = "fields:assign"
+	// PermFarmsManage allows creating, editing and deleting the farms fields are grouped under
+	PermFarmsManage Permission = "farms:manage"
+
+	// PermUsersRead allows reading other users' profiles
+	PermUsersRead Permission = "users:read"
+	// PermUsersManage allows editing and deleting other users, including their role
+	PermUsersManage Permission = "users:manage"
+
+	// PermImagesDelete allows deleting uploaded images
+	PermImagesDelete Permission = "images:delete"
+	// PermAnalyticsReadAll allows analytics across every user's submissions
+	PermAnalyticsReadAll Permission = "analytics:read_all"
+	// PermAPIKeysManage allows listing and revoking other users' API keys
+	PermAPIKeysManage Permission = "api_keys:manage"
+	// PermDatasetsManage allows creating and publishing open data releases
+	PermDatasetsManage Permission = "datasets:manage"
+	// PermServiceAccountsManage allows creating and disabling service accounts
+	PermServiceAccountsManage Permission = "service_accounts:manage"
+	// PermEmbedsManage allows issuing and revoking embed tokens for dashboard widgets
+	PermEmbedsManage Permission = "embeds:manage"
+	// PermQualityReportsRead allows reading the scheduled data quality reports
+	PermQualityReportsRead Permission = "quality_reports:read"
+	// PermInvitesManage allows inviting new users and revoking invites
+	PermInvitesManage Permission = "invites:manage"
+	// PermAuthEventsRead allows reading the login and logout audit log
+	PermAuthEventsRead Permission = "auth_events:read"
+	// PermStressEventsManage allows declaring and removing drought and flood events
+	PermStressEventsManage Permission = "stress_events:manage"
+	// PermIntegrationsManage allows configuring the team chat integrations
+	PermIntegrationsManage Permission = "integrations:manage"
+	// PermOrganizationsManage allows creating organizations and assigning their members
+	PermOrganizationsManage Permission = "organizations:manage"
+	// PermCalibrationsManage allows registering and retiring instrument calibrations
+	PermCalibrationsManage Permission = "calibrations:manage"
+	// PermAgreementsManage allows publishing data access agreements and seeing who accepted them
+	PermAgreementsManage Permission = "agreements:manage"
+	// PermTraitsManage allows defining the deployment's custom traits
+	PermTraitsManage Permission = "traits:manage"
+)
+
+// Roles lists the roles a user can hold
+var Roles = []string{"admin", "org_admin", "coordinator", "agronomist", "researcher", "observer"}
+
+// rolePermissions is the permission matrix. Admins are granted everything.
+// Permissions not listed here only apply to a user's own resources.
+var rolePermissions = map[string][]Permission{
+	// org_admin manages the data and members of its own organization only
+	"org_admin": {
+		PermSubmissionsReadAll,
+		PermSubmissionsUpdateAll,
+		PermSubmissionsDeleteAll,
+		PermSubmissionsApprove,
+		PermSubmissionsAuditRead,
+		PermFieldsReadAll,
+		PermFieldsUpdateAll,
+		PermFieldsDelete,
+		PermFieldsAssign,
+		PermFarmsManage,
+		PermUsersRead,
+		PermUsersManage,
+		PermAnalyticsReadAll,
+		PermCalibrationsManage,
+		PermAgreementsManage,
+	},
+	// coordinator runs field work: fields and who is assigned to them, but not user accounts
+	"coordinator": {
+		PermFieldsReadAll,
+		PermFieldsUpdateAll,
+		PermFieldsDelete,
+		PermFieldsAssign,
+		PermFarmsManage,
+		PermUsersRead,
+	},
+	// agronomist reviews submissions, and sees the data needed to judge them
+	"agronomist": {
+		PermSubmissionsReadAll,
+		PermSubmissionsApprove,
+		PermFieldsReadAll,
+	},
+	"researcher": {
+		PermSubmissionsApprove,
+	},
+	"observer": {},
+}
+
+// HasPermission reports whether role grants perm
+func HasPermission(role string, perm Permission) bool {
+	if role == "admin" {
+		return true
+	}
+	for _, p := range rolePermissions[role] {
+		if p == perm {
+			return true
+		}
+	}
+	return false
+}
+
+// HasPermissionIn reports whether user's role grants perm over a resource
+// belonging to the organization orgID. Members of an organization only reach
+// its own resources; users outside every organization reach all of them.
+// An org_admin outside every organization holds no permissions.
+func HasPermissionIn(user *models.User, perm Permission, orgID string) bool {
+	if !HasPermission(user.Role, perm) {
+		return false
+	}
+	if user.OrgID == "" {
+		return user.Role != "org_admin"
+	}
+	return user.OrgID == orgID
+}
+
+// ServiceAccountRole is the role given to service account principals. It holds
+// no permissions; service accounts are limited to the routes their scopes open.
+const ServiceAccountRole = "service"
+
+// serviceAccountScopes maps each scope a service account or personal access
+// token can hold to the routes it opens
+var serviceAccountScopes = map[string][]string{
+	"submissions:write": {
+		"POST /api/v1/submissions",
+		"POST /api/v1/submissions/community",
+		"POST /api/v1/submissions/batch",
+		"POST /api/v1/images/upload",
+	},
+	"submissions:read": {
+		"GET /api/v1/submissions",
+		"GET /api/v1/submissions/:id",
+		"GET /api/v1/submissions/export",
+	},
+	"fields:read": {
+		"GET /api/v1/fields",
+		"GET /api/v1/fields/:id",
+	},
+	"analytics:read": {
+		"GET /api/v1/analytics/dashboard",
+		"GET /api/v1/analytics/trends",
+		"GET /api/v1/analytics/reports",
+		"GET /api/v1/analytics/jobs/:id",
+	},
+}
+
+// ValidScope reports whether scope can be granted to a service account or
+// personal access token
+func ValidScope(scope string) bool {
+	_, ok := serviceAccountScopes[scope]
+	return ok
+}
+
+// ScopeAllows reports whether any of scopes opens the route, given as the
+// request method and the gin route pattern
+func ScopeAllows(scopes []string, method, route string) bool {
+	target := method + " " + route
+	for _, scope := range scopes {
+		for _, allowed := range serviceAccountScopes[scope] {
+			if allowed == target {
+				return true
+			}
+		}
+	}
+	return false
+}
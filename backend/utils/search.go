@@ -0,0 +1,50 @@
+package utils
+
+import (
+	"strings"
+	"unicode"
+)
+
+// MaxSearchTermLength is the number of leading characters of a search that
+// are matched
+const MaxSearchTermLength = 20
+
+// SearchTerm normalizes a search for matching against SearchTerms: lowercase,
+// single spaces and at most MaxSearchTermLength characters
+func SearchTerm(query string) string {
+	term := []rune(strings.Join(strings.Fields(strings.ToLower(query)), " "))
+	if len(term) > MaxSearchTermLength {
+		term = term[:MaxSearchTermLength]
+	}
+	return string(term)
+}
+
+// SearchTerms returns the prefixes a record with texts is found by: those of
+// each whole text and of every word in them, so "North plot" is found by
+// "nor", "north p" and "plot". Firestore has no text search, so the prefixes
+// are stored on the record and searched with array-contains.
+func SearchTerms(texts ...string) []string {
+	seen := make(map[string]bool)
+	terms := []string{}
+	add := func(text string) {
+		runes := []rune(SearchTerm(text))
+		for i := 1; i <= len(runes); i++ {
+			prefix := string(runes[:i])
+			if !seen[prefix] {
+				seen[prefix] = true
+				terms = append(terms, prefix)
+			}
+		}
+	}
+
+	for _, text := range texts {
+		add(text)
+		words := strings.FieldsFunc(text, func(r rune) bool {
+			return !unicode.IsLetter(r) && !unicode.IsDigit(r)
+		})
+		for _, word := range words {
+			add(word)
+		}
+	}
+	return terms
+}
@@ -1,17 +1,41 @@
 package utils
 
 import (
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/hex"
 	"fmt"
+	"math"
 	"os"
+	"strings"
 	"time"
+	_ "time/tzdata" // user timezones must resolve on hosts without zoneinfo
 
 	"rice-monitor-api/models"
 
 	"github.com/golang-jwt/jwt/v4"
 	"github.com/google/uuid"
+	"golang.org/x/crypto/bcrypt"
 )
 
-var jwtSecret = []byte(getEnvOrDefault("JWT_SECRET", "your-secret-key"))
+// jwtSecret signs tokens. jwtIssuer and jwtAudience are set in every token and
+// required on validation, so tokens issued by one deployment are rejected by
+// another sharing its secret. All three are set from the environment by
+// LoadJWTSettings at startup.
+var (
+	jwtSecret   = []byte("your-secret-key")
+	jwtIssuer   = "rice-monitor-api"
+	jwtAudience = "rice-monitor"
+)
+
+// LoadJWTSettings reads JWT_SECRET, JWT_ISSUER and JWT_AUDIENCE. It runs at
+// startup rather than when the package is initialized, so values from .env,
+// loaded by main, are seen.
+func LoadJWTSettings() {
+	jwtSecret = []byte(getEnvOrDefault("JWT_SECRET", string(jwtSecret)))
+	jwtIssuer = getEnvOrDefault("JWT_ISSUER", jwtIssuer)
+	jwtAudience = getEnvOrDefault("JWT_AUDIENCE", jwtAudience)
+}
 
 // GenerateID generates a new UUID
 func GenerateID() string {
@@ -30,17 +54,56 @@ func getEnvOrDefault(key, defaultValue string) string {
 	return GetEnvOrDefault(key, defaultValue)
 }
 
-// GenerateTokens generates JWT access and refresh tokens
-func GenerateTokens(user *models.User) (string, string, error) {
-	// Access token (1 hour)
+// AccessTokenTTL is how long an access token stays valid, and RefreshTokenTTL
+// how long a refresh token, and so an idle session, does. Both are set from the
+// environment by LoadTokenLifetimes at startup.
+var (
+	AccessTokenTTL  = time.Hour
+	RefreshTokenTTL = 7 * 24 * time.Hour
+)
+
+// LoadTokenLifetimes reads ACCESS_TOKEN_TTL and REFRESH_TOKEN_TTL as durations
+// such as 15m or 720h. Refresh tokens must outlive access tokens.
+func LoadTokenLifetimes() error {
+	access, err := envDuration("ACCESS_TOKEN_TTL", AccessTokenTTL)
+	if err != nil {
+		return err
+	}
+	refresh, err := envDuration("REFRESH_TOKEN_TTL", RefreshTokenTTL)
+	if err != nil {
+		return err
+	}
+	if refresh <= access {
+		return fmt.Errorf("REFRESH_TOKEN_TTL (%s) must be longer than ACCESS_TOKEN_TTL (%s)", refresh, access)
+	}
+
+	AccessTokenTTL, RefreshTokenTTL = access, refresh
+	return nil
+}
+
+// envDuration parses the duration in key, or returns defaultValue when it is unset
+func envDuration(key string, defaultValue time.Duration) (time.Duration, error) {
+	value := os.Getenv(key)
+	if value == "" {
+		return defaultValue, nil
+	}
+	d, err := time.ParseDuration(value)
+	if err != nil || d < time.Minute {
+		return 0, fmt.Errorf("%s must be a duration of at least 1m, such as 15m or 720h, got %q", key, value)
+	}
+	return d, nil
+}
+
+// GenerateTokens generates JWT access and refresh tokens for a session
+func GenerateTokens(user *models.User, sessionID string) (string, string, error) {
+	// Access token
 	accessClaims := &models.Claims{
-		UserID: user.ID,
-		Email:  user.Email,
-		Role:   user.Role,
-		RegisteredClaims: jwt.RegisteredClaims{
-			ExpiresAt: jwt.NewNumericDate(time.Now().Add(time.Hour)),
-			IssuedAt:  jwt.NewNumericDate(time.Now()),
-		},
+		UserID:           user.ID,
+		Email:            user.Email,
+		Role:             user.Role,
+		SessionID:        sessionID,
+		Version:          user.TokenVersion,
+		RegisteredClaims: registeredClaims(AccessTokenTTL),
 	}
 
 	accessToken := jwt.NewWithClaims(jwt.SigningMethodHS256, accessClaims)
@@ -49,15 +112,15 @@ func GenerateTokens(user *models.User) (string, string, error) {
 		return "", "", err
 	}
 
-	// Refresh token (7 days)
+	// Refresh token
 	refreshClaims := &models.Claims{
-		UserID: user.ID,
-		Email:  user.Email,
-		Role:   user.Role,
-		RegisteredClaims: jwt.RegisteredClaims{
-			ExpiresAt: jwt.NewNumericDate(time.Now().Add(time.Hour * 24 * 7)),
-			IssuedAt:  jwt.NewNumericDate(time.Now()),
-		},
+		UserID:           user.ID,
+		Email:            user.Email,
+		Role:             user.Role,
+		Kind:             TokenKindRefresh,
+		SessionID:        sessionID,
+		Version:          user.TokenVersion,
+		RegisteredClaims: registeredClaims(RefreshTokenTTL),
 	}
 
 	refreshToken := jwt.NewWithClaims(jwt.SigningMethodHS256, refreshClaims)
@@ -69,8 +132,89 @@ func GenerateTokens(user *models.User) (string, string, error) {
 	return accessTokenString, refreshTokenString, nil
 }
 
-// ValidateToken validates a JWT token and returns claims
+// GenerateServiceAccountToken generates a 1 hour access token for a service account
+func GenerateServiceAccountToken(account *models.ServiceAccount) (string, error) {
+	claims := &models.Claims{
+		UserID:           account.ID,
+		Role:             ServiceAccountRole,
+		Kind:             "service_account",
+		Scopes:           account.Scopes,
+		RegisteredClaims: registeredClaims(time.Hour),
+	}
+
+	return jwt.NewWithClaims(jwt.SigningMethodHS256, claims).SignedString(jwtSecret)
+}
+
+// TokenKindRefresh marks refresh tokens, which only renew a session and
+// cannot authenticate requests
+const TokenKindRefresh = "refresh"
+
+// ValidateToken validates a JWT token that authenticates requests and returns
+// its claims. Refresh tokens are rejected.
 func ValidateToken(tokenString string) (*models.Claims, error) {
+	claims, err := parseToken(tokenString, jwtAudience)
+	if err != nil {
+		return nil, err
+	}
+	if claims.Kind == TokenKindRefresh {
+		return nil, fmt.Errorf("refresh tokens cannot authenticate requests")
+	}
+	return claims, nil
+}
+
+// ValidateRefreshToken validates a refresh token and returns its claims
+func ValidateRefreshToken(tokenString string) (*models.Claims, error) {
+	claims, err := parseToken(tokenString, jwtAudience)
+	if err != nil {
+		return nil, err
+	}
+	if claims.Kind != TokenKindRefresh {
+		return nil, fmt.Errorf("not a refresh token")
+	}
+	return claims, nil
+}
+
+// ShareScopeExportSubmissions lets a share token download a submissions export
+const ShareScopeExportSubmissions = "export:submissions"
+
+// shareAudience keeps share tokens apart from login tokens: ValidateToken
+// rejects them, so they can never authenticate as their creator
+func shareAudience() string {
+	return jwtAudience + "/share"
+}
+
+// GenerateShareToken generates a read-only token for a collaborator without an
+// account. It carries the creator's ID so their access is rechecked when used.
+func GenerateShareToken(user *models.User, scope, fieldID string, ttl time.Duration) (string, time.Time, error) {
+	registered := registeredClaims(ttl)
+	registered.Audience = jwt.ClaimStrings{shareAudience()}
+
+	claims := &models.Claims{
+		UserID:           user.ID,
+		Kind:             "share",
+		Scopes:           []string{scope},
+		FieldID:          fieldID,
+		Version:          user.TokenVersion,
+		RegisteredClaims: registered,
+	}
+
+	token, err := jwt.NewWithClaims(jwt.SigningMethodHS256, claims).SignedString(jwtSecret)
+	return token, registered.ExpiresAt.Time, err
+}
+
+// ValidateShareToken validates a share token and checks that it grants scope
+func ValidateShareToken(tokenString, scope string) (*models.Claims, error) {
+	claims, err := parseToken(tokenString, shareAudience())
+	if err != nil {
+		return nil, err
+	}
+	if claims.Kind != "share" || !Contains(claims.Scopes, scope) {
+		return nil, fmt.Errorf("token does not grant %s", scope)
+	}
+	return claims, nil
+}
+
+func parseToken(tokenString, audience string) (*models.Claims, error) {
 	token, err := jwt.ParseWithClaims(tokenString, &models.Claims{}, func(token *jwt.Token) (interface{}, error) {
 		if _, ok := token.Method.(*jwt.SigningMethodHMAC); !ok {
 			return nil, fmt.Errorf("unexpected signing method: %v", token.Header["alg"])
@@ -82,11 +226,140 @@ func ValidateToken(tokenString string) (*models.Claims, error) {
 		return nil, err
 	}
 
-	if claims, ok := token.Claims.(*models.Claims); ok && token.Valid {
-		return claims, nil
+	claims, ok := token.Claims.(*models.Claims)
+	if !ok || !token.Valid {
+		return nil, fmt.Errorf("invalid token")
+	}
+	if !claims.VerifyIssuer(jwtIssuer, true) || !claims.VerifyAudience(audience, true) {
+		return nil, fmt.Errorf("token was issued for another deployment")
+	}
+
+	return claims, nil
+}
+
+// registeredClaims returns the standard claims of a token valid for ttl
+func registeredClaims(ttl time.Duration) jwt.RegisteredClaims {
+	now := time.Now()
+	return jwt.RegisteredClaims{
+		Issuer:    jwtIssuer,
+		Audience:  jwt.ClaimStrings{jwtAudience},
+		ExpiresAt: jwt.NewNumericDate(now.Add(ttl)),
+		IssuedAt:  jwt.NewNumericDate(now),
 	}
+}
+
+// APIKeyPrefix marks keys issued by this API
+const APIKeyPrefix = "rmk_"
+
+// EmbedTokenPrefix marks dashboard widget embed tokens
+const EmbedTokenPrefix = "rme_"
+
+// PersonalAccessTokenPrefix marks personal access tokens, sent as Bearer tokens
+const PersonalAccessTokenPrefix = "rmp_"
+
+// SubmissionShareTokenPrefix marks public submission share links
+const SubmissionShareTokenPrefix = "rms_"
+
+// InviteCodePrefix marks invite codes
+const InviteCodePrefix = "rmi_"
 
-	return nil, fmt.Errorf("invalid token")
+// GenerateSecureToken generates a random hex encoded token of n bytes
+func GenerateSecureToken(n int) (string, error) {
+	b := make([]byte, n)
+	if _, err := rand.Read(b); err != nil {
+		return "", err
+	}
+	return hex.EncodeToString(b), nil
+}
+
+// GenerateAPIKey generates a new random API key
+func GenerateAPIKey() (string, error) {
+	token, err := GenerateSecureToken(32)
+	if err != nil {
+		return "", err
+	}
+	return APIKeyPrefix + token, nil
+}
+
+// GeneratePersonalAccessToken generates a new random personal access token
+func GeneratePersonalAccessToken() (string, error) {
+	token, err := GenerateSecureToken(32)
+	if err != nil {
+		return "", err
+	}
+	return PersonalAccessTokenPrefix + token, nil
+}
+
+// Session cookies set in cookie session mode. The CSRF cookie is readable by
+// the frontend, which echoes it in CSRFHeader on state-changing requests.
+const (
+	AccessTokenCookie  = "rm_access_token"
+	RefreshTokenCookie = "rm_refresh_token"
+	CSRFCookie         = "rm_csrf_token"
+	CSRFHeader         = "X-CSRF-Token"
+)
+
+// CookieSessionMode reports whether logins set their tokens as HttpOnly
+// cookies (AUTH_SESSION_MODE=cookie) instead of returning them for a Bearer header
+func CookieSessionMode() bool {
+	return strings.ToLower(os.Getenv("AUTH_SESSION_MODE")) == "cookie"
+}
+
+// HashToken returns the hex encoded SHA-256 hash of a secret token or API key
+func HashToken(token string) string {
+	sum := sha256.Sum256([]byte(token))
+	return hex.EncodeToString(sum[:])
+}
+
+// TokenRevoked reports whether the token was issued before the user's tokens
+// were invalidated, or for a token version the user has since logged out of
+func TokenRevoked(claims *models.Claims, user *models.User) bool {
+	if claims.Version != user.TokenVersion {
+		return true
+	}
+	if user.TokensValidAfter.IsZero() || claims.IssuedAt == nil {
+		return false
+	}
+	// IssuedAt only has second precision
+	return claims.IssuedAt.Time.Before(user.TokensValidAfter.Truncate(time.Second))
+}
+
+// AccountSuspended reports whether the user is deactivated, currently suspended
+// or deleted
+func AccountSuspended(user *models.User) bool {
+	if user.Deactivated || user.DeletedAt != nil {
+		return true
+	}
+	return user.SuspendedUntil != nil && time.Now().Before(*user.SuspendedUntil)
+}
+
+// SuspensionMessage describes why a suspended user is refused
+func SuspensionMessage(user *models.User) string {
+	if user.DeletedAt != nil {
+		return "Account has been deleted"
+	}
+	message := "Account has been deactivated"
+	if !user.Deactivated && user.SuspendedUntil != nil {
+		message = "Account is suspended until " + user.SuspendedUntil.Format(time.RFC3339)
+	}
+	if user.SuspensionReason != "" {
+		message += ": " + user.SuspensionReason
+	}
+	return message
+}
+
+// HashPassword hashes a password with bcrypt
+func HashPassword(password string) (string, error) {
+	hash, err := bcrypt.GenerateFromPassword([]byte(password), bcrypt.DefaultCost)
+	if err != nil {
+		return "", err
+	}
+	return string(hash), nil
+}
+
+// CheckPassword reports whether password matches the bcrypt hash
+func CheckPassword(hash, password string) bool {
+	return bcrypt.CompareHashAndPassword([]byte(hash), []byte(password)) == nil
 }
 
 // FormatDate formats time to date string
@@ -99,6 +372,19 @@ func ParseDate(dateStr string) (time.Time, error) {
 	return time.Parse("2006-01-02", dateStr)
 }
 
+// UserLocation returns the timezone from the user's preferences, or UTC when
+// none is set or the stored name is unknown
+func UserLocation(user *models.User) *time.Location {
+	if user == nil || user.Preferences.Timezone == "" {
+		return time.UTC
+	}
+	loc, err := time.LoadLocation(user.Preferences.Timezone)
+	if err != nil {
+		return time.UTC
+	}
+	return loc
+}
+
 // Contains checks if slice contains string
 func Contains(slice []string, item string) bool {
 	for _, s := range slice {
@@ -126,3 +412,215 @@ func ValidateFileType(filename string) bool {
 	}
 	return false
 }
+
+// DistanceKm returns the great-circle distance between two coordinates in kilometres
+func DistanceKm(a, b models.Location) float64 {
+	const earthRadiusKm = 6371.0
+
+	lat1 := a.Latitude * math.Pi / 180
+	lat2 := b.Latitude * math.Pi / 180
+	dLat := lat2 - lat1
+	dLng := (b.Longitude - a.Longitude) * math.Pi / 180
+
+	h := math.Sin(dLat/2)*math.Sin(dLat/2) + math.Cos(lat1)*math.Cos(lat2)*math.Sin(dLng/2)*math.Sin(dLng/2)
+	return 2 * earthRadiusKm * math.Asin(math.Sqrt(h))
+}
+
+// PointInPolygon reports whether point lies inside the polygon given by its
+// vertices, using ray casting on latitude and longitude
+func PointInPolygon(point models.Location, polygon []models.Location) bool {
+	inside := false
+	for i, j := 0, len(polygon)-1; i < len(polygon); j, i = i, i+1 {
+		a, b := polygon[i], polygon[j]
+		if (a.Latitude > point.Latitude) != (b.Latitude > point.Latitude) &&
+			point.Longitude < (b.Longitude-a.Longitude)*(point.Latitude-a.Latitude)/(b.Latitude-a.Latitude)+a.Longitude {
+			inside = !inside
+		}
+	}
+	return inside
+}
+
+// DistanceToPolygonKm returns how far point lies outside the polygon in
+// kilometres, or 0 inside it. Edges are measured on a flat projection around
+// the point, which is accurate at the scale of a field.
+func DistanceToPolygonKm(point models.Location, polygon []models.Location) float64 {
+	if PointInPolygon(point, polygon) {
+		return 0
+	}
+
+	const kmPerDegree = 6371.0 * math.Pi / 180
+	scale := math.Cos(point.Latitude * math.Pi / 180)
+	project := func(l models.Location) (float64, float64) {
+		return (l.Longitude - point.Longitude) * kmPerDegree * scale, (l.Latitude - point.Latitude) * kmPerDegree
+	}
+
+	nearest := math.Inf(1)
+	for i, j := 0, len(polygon)-1; i < len(polygon); j, i = i, i+1 {
+		ax, ay := project(polygon[i])
+		bx, by := project(polygon[j])
+		// Closest point of the edge to the origin, where the point lies
+		dx, dy := bx-ax, by-ay
+		t := 0.0
+		if length := dx*dx + dy*dy; length > 0 {
+			t = math.Max(0, math.Min(1, -(ax*dx+ay*dy)/length))
+		}
+		nearest = math.Min(nearest, math.Hypot(ax+t*dx, ay+t*dy))
+	}
+	return nearest
+}
+
+// MaxBoundaryVertices caps field boundaries, which are checked edge by edge
+const MaxBoundaryVertices = 1000
+
+// ValidateBoundary checks that a field boundary is a simple polygon: valid
+// coordinates, at least 3 distinct vertices and no crossing edges. A repeated
+// closing vertex is dropped.
+func ValidateBoundary(boundary []models.Location) ([]models.Location, error) {
+	if len(boundary) > 1 && boundary[0] == boundary[len(boundary)-1] {
+		boundary = boundary[:len(boundary)-1]
+	}
+	if len(boundary) < 3 {
+		return nil, fmt.Errorf("boundary must have at least 3 vertices")
+	}
+	if len(boundary) > MaxBoundaryVertices {
+		return nil, fmt.Errorf("boundary can have at most %d vertices", MaxBoundaryVertices)
+	}
+
+	distinct := make(map[models.Location]bool)
+	for i, point := range boundary {
+		if point.Latitude < -90 || point.Latitude > 90 || point.Longitude < -180 || point.Longitude > 180 {
+			return nil, fmt.Errorf("boundary vertex %d is not a valid latitude and longitude", i)
+		}
+		if point == boundary[(i+1)%len(boundary)] {
+			return nil, fmt.Errorf("boundary vertex %d repeats the one before it", (i+1)%len(boundary))
+		}
+		distinct[point] = true
+	}
+	if len(distinct) < 3 {
+		return nil, fmt.Errorf("boundary must have at least 3 distinct vertices")
+	}
+
+	n := len(boundary)
+	for i := 0; i < n; i++ {
+		for j := i + 1; j < n; j++ {
+			// Adjacent edges share a vertex
+			if j == i+1 || (i == 0 && j == n-1) {
+				continue
+			}
+			if segmentsIntersect(boundary[i], boundary[(i+1)%n], boundary[j], boundary[(j+1)%n]) {
+				return nil, fmt.Errorf("boundary edges %d and %d cross", i, j)
+			}
+		}
+	}
+
+	if PolygonAreaHectares(boundary) == 0 {
+		return nil, fmt.Errorf("boundary has no area")
+	}
+	return boundary, nil
+}
+
+// PolygonAreaHectares returns the area of a polygon on the earth's surface in
+// hectares, treating its edges as drawn on a map
+func PolygonAreaHectares(polygon []models.Location) float64 {
+	const earthRadiusM = 6371008.8
+
+	// Area of a spherical polygon from the signed sum over its edges
+	sum := 0.0
+	for i, j := 0, len(polygon)-1; i < len(polygon); j, i = i, i+1 {
+		a, b := polygon[j], polygon[i]
+		sum += (b.Longitude - a.Longitude) * math.Pi / 180 *
+			(2 + math.Sin(a.Latitude*math.Pi/180) + math.Sin(b.Latitude*math.Pi/180))
+	}
+	return math.Abs(sum) * earthRadiusM * earthRadiusM / 2 / 10000
+}
+
+// segmentsIntersect reports whether segments ab and cd touch or cross, on
+// latitude and longitude
+func segmentsIntersect(a, b, c, d models.Location) bool {
+	orientation := func(p, q, r models.Location) int {
+		v := (q.Longitude-p.Longitude)*(r.Latitude-p.Latitude) - (q.Latitude-p.Latitude)*(r.Longitude-p.Longitude)
+		switch {
+		case v > 0:
+			return 1
+		case v < 0:
+			return -1
+		}
+		return 0
+	}
+	onSegment := func(p, q, r models.Location) bool {
+		return math.Min(p.Longitude, r.Longitude) <= q.Longitude && q.Longitude <= math.Max(p.Longitude, r.Longitude) &&
+			math.Min(p.Latitude, r.Latitude) <= q.Latitude && q.Latitude <= math.Max(p.Latitude, r.Latitude)
+	}
+
+	o1, o2 := orientation(a, b, c), orientation(a, b, d)
+	o3, o4 := orientation(c, d, a), orientation(c, d, b)
+	if o1 != o2 && o3 != o4 {
+		return true
+	}
+	return (o1 == 0 && onSegment(a, c, b)) || (o2 == 0 && onSegment(a, d, b)) ||
+		(o3 == 0 && onSegment(c, a, d)) || (o4 == 0 && onSegment(c, b, d))
+}
+
+// NormalizePhone strips everything but digits from a phone number and prefixes
+// it with +, so numbers typed with spaces or dashes match gateway senders
+func NormalizePhone(phone string) string {
+	digits := strings.Map(func(r rune) rune {
+		if r >= '0' && r <= '9' {
+			return r
+		}
+		return -1
+	}, phone)
+	if digits == "" {
+		return ""
+	}
+	return "+" + digits
+}
+
+// Traits is the registry of trait measurements recorded on submissions
+var Traits = []models.TraitDefinition{
+	{Key: "culm_length", Label: "Culm Length", Type: "number", Unit: "cm", Description: "Length of the main culm from the soil surface to the panicle base"},
+	{Key: "panicle_length", Label: "Panicle Length", Type: "number", Unit: "cm", Description: "Length of the main panicle from its base to the tip"},
+	{Key: "panicles_per_hill", Label: "Panicles Per Hill", Type: "integer", Unit: "count", Description: "Number of panicles on one hill"},
+	{Key: "hills_observed", Label: "Hills Observed", Type: "integer", Unit: "count", Description: "Number of hills the measurements were taken on"},
+}
+
+// TraitKeys lists the trait measurements a photo can be linked to as evidence
+var TraitKeys = traitKeys()
+
+func traitKeys() []string {
+	keys := make([]string, len(Traits))
+	for i, trait := range Traits {
+		keys[i] = trait.Key
+	}
+	return keys
+}
+
+// SubmissionStatuses lists the statuses a submission moves through
+var SubmissionStatuses = []string{"draft", "submitted", "under_review", "approved", "rejected"}
+
+// GrowthStages lists the built-in growth stages offered by the stage picker.
+// Deployments can replace them, so read them from the vocabulary service.
+var GrowthStages = []string{
+	"Seedling",
+	"Tillering",
+	"Panicle Initiation",
+	"Flowering",
+	"Milk Stage",
+	"Dough Stage",
+	"Maturity",
+	"Harvested",
+}
+
+// PlantConditions lists the built-in plant conditions offered by the app.
+// Deployments can replace them, so read them from the vocabulary service.
+var PlantConditions = []string{
+	"Healthy",
+	"Unhealthy",
+	"Signs of pest infestation",
+	"Signs of nutrient deficiency",
+	"Water stress (drought or flood)",
+	"Lodging (bent/broken stems)",
+	"Weed infestation",
+	"Disease symptoms",
+	"Other",
+}
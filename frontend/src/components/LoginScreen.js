@@ -7,6 +7,25 @@ import apiService from "../services/apiService"; // Your API wrapper
 const LoginScreen = ({ onLogin }) => {
   const [error, setError] = useState("");
   const [isLoading, setIsLoading] = useState(false);
+  const [email, setEmail] = useState("");
+  const [linkSent, setLinkSent] = useState(false);
+
+  const completeLogin = useCallback(
+    (response) => {
+      if (!response.access_token || !response.user) {
+        console.log(response);
+        throw new Error(response.message || "Login failed");
+      }
+
+      // Store tokens
+      localStorage.setItem("access_token", response.access_token);
+      localStorage.setItem("refresh_token", response.refresh_token);
+      localStorage.setItem("user", JSON.stringify(response.user));
+
+      onLogin(response.user);
+    },
+    [onLogin]
+  );
 
   const handleGoogleLogin = useCallback(
     async (credentialResponse) => {
@@ -16,19 +35,12 @@ const LoginScreen = ({ onLogin }) => {
       try {
         const userInfo = extractGoogleUserInfo(credentialResponse);
 
-        const response = await apiService.googleLogin(userInfo.accessToken); // implement this on your backend
-
-        if (!response.access_token || !response.user) {
-          console.log(response);
-          throw new Error(response.message || "Login failed");
-        }
+        // Invite links open the app with ?invite=<code>
+        const inviteCode = new URLSearchParams(window.location.search).get("invite") || undefined;
 
-        // Store tokens
-        localStorage.setItem("access_token", response.access_token);
-        localStorage.setItem("refresh_token", response.refresh_token);
-        localStorage.setItem("user", JSON.stringify(response.user));
+        const response = await apiService.googleLogin(userInfo.accessToken, inviteCode); // implement this on your backend
 
-        onLogin(response.user);
+        completeLogin(response);
       } catch (err) {
         console.error("Login failed:", err);
         setError(err.message || "Login failed. Please try again.");
@@ -36,9 +48,44 @@ const LoginScreen = ({ onLogin }) => {
         setIsLoading(false);
       }
     },
-    [onLogin]
+    [completeLogin]
   );
 
+  // Magic links open the app with ?magic=<token>
+  useEffect(() => {
+    const params = new URLSearchParams(window.location.search);
+    const token = params.get("magic");
+    if (!token) return;
+
+    // Links are single use, so drop the token from the address bar right away
+    params.delete("magic");
+    const query = params.toString();
+    window.history.replaceState(null, "", window.location.pathname + (query ? `?${query}` : ""));
+
+    setIsLoading(true);
+    apiService
+      .verifyMagicLink(token)
+      .then(completeLogin)
+      .catch((err) => {
+        console.error("Magic link login failed:", err);
+        setError(err.message || "Login link is invalid or has expired");
+      })
+      .finally(() => setIsLoading(false));
+  }, [completeLogin]);
+
+  const handleMagicLink = async (e) => {
+    e.preventDefault();
+    setError("");
+
+    try {
+      await apiService.requestMagicLink(email);
+      setLinkSent(true);
+    } catch (err) {
+      console.error("Magic link request failed:", err);
+      setError(err.message || "Failed to send login link");
+    }
+  };
+
   useEffect(() => {
     initializeGoogleAuth(handleGoogleLogin).catch((err) => {
       console.error("Google init failed:", err);
@@ -68,6 +115,35 @@ const LoginScreen = ({ onLogin }) => {
         ) : (
           <div className="g_id_signin"></div> // Google button will render here
         )}
+
+        <div className="mt-6 pt-4 border-t">
+          {linkSent ? (
+            <p className="text-sm text-gray-700">
+              If an account exists for {email}, a login link is on its way.
+              Open it on this device.
+            </p>
+          ) : (
+            <form onSubmit={handleMagicLink}>
+              <label className="block text-sm text-gray-700 mb-2">
+                Or get a login link by email
+              </label>
+              <input
+                type="email"
+                required
+                value={email}
+                onChange={(e) => setEmail(e.target.value)}
+                placeholder="you@example.com"
+                className="w-full p-2 border rounded mb-2"
+              />
+              <button
+                type="submit"
+                className="w-full py-2 bg-green-600 text-white rounded"
+              >
+                Email me a login link
+              </button>
+            </form>
+          )}
+        </div>
       </div>
     </div>
   );
@@ -12,6 +12,14 @@ import apiService from "../services/apiService";
 import Card, { CardBody, CardFooter } from "./common/Card";
 import Button, { ButtonGroup } from "./common/Button";
 
+// Display names of the trait measurements a photo can be linked to
+const traitLabels = {
+  culm_length: "Culm length",
+  panicle_length: "Panicle length",
+  panicles_per_hill: "Panicles per hill",
+  hills_observed: "Hills observed",
+};
+
 /**
  * Submissions Screen Component
  * Displays the list of rice monitoring submissions for the current user
@@ -432,13 +440,28 @@ const SubmissionsScreen = ({
                       <strong>Images:</strong>
                       <div className="flex gap-2 mt-4 overflow-x-auto">
                         {selectedSubmission.images.map((image, index) => (
-                          <img
-                            key={index}
-                            src={image}
-                            alt={`Submission Image ${index + 1}`}
-                            className="w-auto h-20 rounded-lg cursor-pointer"
-                            onClick={() => window.open(image, "_blank")}
-                          />
+                          <div key={index} className="flex-shrink-0">
+                            <img
+                              src={image}
+                              alt={`Submission Image ${index + 1}`}
+                              className="w-auto h-20 rounded-lg cursor-pointer"
+                              onClick={() => window.open(image, "_blank")}
+                            />
+                            {(selectedSubmission.evidence || [])
+                              .filter((link) => link.image === image)
+                              .map((link, linkIndex) => (
+                                <div
+                                  key={linkIndex}
+                                  className="mt-1 text-xs text-gray-600"
+                                  title={link.note}
+                                >
+                                  Evidence:{" "}
+                                  {link.trait
+                                    ? traitLabels[link.trait] || link.trait
+                                    : link.condition}
+                                </div>
+                              ))}
+                          </div>
                         ))}
                       </div>
                     </div>
@@ -9,9 +9,9 @@ export const useLocations = () => {
   useEffect(() => {
     const fetchLocations = async () => {
       try {
-        const data = await apiService.getFields()
+        const data = await apiService.getFields({ limit: 100 })
         
-        setLocations(data?.data);
+        setLocations(data?.data?.fields);
       } catch (err) {
         setError(err.message);
       } finally {
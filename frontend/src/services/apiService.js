@@ -26,9 +26,29 @@ class ApiService {
   }
 
   // Authentication methods
-  async googleLogin(token) {
+  // inviteCode is required when the login creates a new account
+  async googleLogin(token, inviteCode) {
     console.log("token", token);
     const response = await fetch(`${API_BASE_URL}/auth/google`, {
+      method: "POST",
+      headers: { "Content-Type": "application/json" },
+      body: JSON.stringify({ token, invite_code: inviteCode }),
+    });
+    return this.handleResponse(response);
+  }
+
+  // Passwordless login: email a single-use link, then exchange its token
+  async requestMagicLink(email) {
+    const response = await fetch(`${API_BASE_URL}/auth/magic-link`, {
+      method: "POST",
+      headers: { "Content-Type": "application/json" },
+      body: JSON.stringify({ email }),
+    });
+    return this.handleResponse(response);
+  }
+
+  async verifyMagicLink(token) {
+    const response = await fetch(`${API_BASE_URL}/auth/magic-link/verify`, {
       method: "POST",
       headers: { "Content-Type": "application/json" },
       body: JSON.stringify({ token }),
@@ -139,8 +159,9 @@ class ApiService {
   }
 
   // Fields methods
-  async getFields() {
-    const response = await fetch(`${API_BASE_URL}/fields`, {
+  async getFields(params = {}) {
+    const queryParams = new URLSearchParams(params);
+    const response = await fetch(`${API_BASE_URL}/fields?${queryParams}`, {
       headers: this.getAuthHeaders(),
     });
     return this.handleResponse(response);